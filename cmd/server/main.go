@@ -2,13 +2,18 @@ package main
 
 import (
 	"bufio"
+	"bytes"
 	"context"
+	"crypto/rand"
 	"database/sql"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"log"
+	"net/http"
 	"os"
 	"strconv"
 	"strings"
@@ -19,6 +24,7 @@ import (
 	"github.com/shinyes/keer/internal/db"
 	"github.com/shinyes/keer/internal/models"
 	"github.com/shinyes/keer/internal/service"
+	"github.com/shinyes/keer/internal/storage"
 	"github.com/shinyes/keer/internal/store"
 )
 
@@ -61,7 +67,7 @@ func runServe(args []string) {
 	}
 	if *consoleMode {
 		log.Printf("runtime admin console enabled")
-		go runRuntimeConsole(cfg, container.UserService, container.StorageService)
+		go runRuntimeConsole(cfg, container.UserService, container.MemoService, container.StorageService)
 	}
 	log.Fatal(container.Router.Listen(container.Config.Addr))
 }
@@ -88,28 +94,44 @@ func runAdmin(args []string) error {
 	}
 
 	sqlStore := store.New(sqliteDB)
+	sqlStore.SetMillisecondTimestamps(cfg.MillisecondTimestamps)
+	sqlStore.SetTokenPrefixLength(cfg.TokenPrefixLength)
 	userService := service.NewUserService(sqlStore)
+	userService.SetPasswordResetTokenTTL(cfg.PasswordResetTokenTTL)
+	userService.SetRequireDistinctDisplayName(cfg.RequireDistinctDisplayName)
+	userService.SetBcryptCost(cfg.BcryptCost)
+	if err := userService.SetUsernamePattern(cfg.UsernamePattern); err != nil {
+		return err
+	}
+	memoService := service.NewMemoService(sqlStore)
+	if err := memoService.SetSearchTokenizer(cfg.SearchTokenizer); err != nil {
+		return err
+	}
 	storageService := service.NewStorageSettingsService(sqlStore)
-	return executeAdminCommand(context.Background(), cfg.AllowRegistration, userService, storageService, args, os.Stdin)
+	storageService.SetUploadsDir(cfg.UploadsDir)
+	userService.SetStoreResolver(storageService.OpenStoreForType)
+	return executeAdminCommand(context.Background(), cfg.AllowRegistration, userService, memoService, storageService, args, os.Stdin)
 }
 
-func executeAdminCommand(ctx context.Context, allowRegistrationFallback bool, userService *service.UserService, storageService *service.StorageSettingsService, args []string, interactiveInput io.Reader) error {
+func executeAdminCommand(ctx context.Context, allowRegistrationFallback bool, userService *service.UserService, memoService *service.MemoService, storageService *service.StorageSettingsService, args []string, interactiveInput io.Reader) error {
 	switch args[0] {
 	case "user":
-		return runAdminUser(ctx, userService, args[1:])
+		return runAdminUser(ctx, userService, args[1:], interactiveInput)
 	case "token":
 		return runAdminToken(ctx, userService, args[1:])
 	case "registration":
 		return runAdminRegistration(ctx, userService, allowRegistrationFallback, args[1:])
 	case "storage":
 		return runAdminStorage(ctx, storageService, args[1:], interactiveInput)
+	case "search":
+		return runAdminSearch(ctx, memoService, args[1:])
 	default:
 		printUsage()
 		return fmt.Errorf("unknown admin command: %s", args[0])
 	}
 }
 
-func runRuntimeConsole(cfg config.Config, userService *service.UserService, storageService *service.StorageSettingsService) {
+func runRuntimeConsole(cfg config.Config, userService *service.UserService, memoService *service.MemoService, storageService *service.StorageSettingsService) {
 	fmt.Println("Runtime Console: 输入命令，示例：user create demo demo-pass")
 	fmt.Println("Runtime Console: 输入 help 查看命令，输入 exit 退出控制台（不会停止服务）")
 
@@ -156,7 +178,7 @@ func runRuntimeConsole(cfg config.Config, userService *service.UserService, stor
 			}
 		}
 
-		if err := executeAdminCommand(context.Background(), cfg.AllowRegistration, userService, storageService, parsed, reader); err != nil {
+		if err := executeAdminCommand(context.Background(), cfg.AllowRegistration, userService, memoService, storageService, parsed, reader); err != nil {
 			fmt.Printf("command failed: %v\n", err)
 		}
 		if errors.Is(readErr, io.EOF) {
@@ -165,21 +187,45 @@ func runRuntimeConsole(cfg config.Config, userService *service.UserService, stor
 	}
 }
 
-func runAdminUser(ctx context.Context, userService *service.UserService, args []string) error {
-	if len(args) < 3 || args[0] != "create" {
+func runAdminUser(ctx context.Context, userService *service.UserService, args []string, interactiveInput io.Reader) error {
+	if len(args) == 0 {
+		printUsage()
+		return fmt.Errorf("usage: admin user <create|list|delete|set-password|reset-token|set-role> ...")
+	}
+	switch args[0] {
+	case "create":
+		return runAdminUserCreate(ctx, userService, args[1:])
+	case "list":
+		return runAdminUserList(ctx, userService, args[1:])
+	case "delete":
+		return runAdminUserDelete(ctx, userService, args[1:], interactiveInput)
+	case "set-password":
+		return runAdminUserSetPassword(ctx, userService, args[1:], interactiveInput)
+	case "reset-token":
+		return runAdminUserResetToken(ctx, userService, args[1:])
+	case "set-role":
+		return runAdminUserSetRole(ctx, userService, args[1:])
+	default:
+		printUsage()
+		return fmt.Errorf("unknown user subcommand: %s", args[0])
+	}
+}
+
+func runAdminUserCreate(ctx context.Context, userService *service.UserService, args []string) error {
+	if len(args) < 2 {
 		printUsage()
 		return fmt.Errorf("usage: admin user create <username> <password> [display_name] [role]")
 	}
 
-	username := strings.TrimSpace(args[1])
-	password := strings.TrimSpace(args[2])
+	username := strings.TrimSpace(args[0])
+	password := strings.TrimSpace(args[1])
 	displayName := ""
-	if len(args) >= 4 {
-		displayName = strings.TrimSpace(args[3])
+	if len(args) >= 3 {
+		displayName = strings.TrimSpace(args[2])
 	}
 	role := "USER"
-	if len(args) >= 5 {
-		role = strings.TrimSpace(args[4])
+	if len(args) >= 4 {
+		role = strings.TrimSpace(args[3])
 	}
 
 	admin := &models.User{Role: "ADMIN"}
@@ -196,10 +242,173 @@ func runAdminUser(ctx context.Context, userService *service.UserService, args []
 	return nil
 }
 
+func runAdminUserList(ctx context.Context, userService *service.UserService, args []string) error {
+	flagSet := flag.NewFlagSet("admin user list", flag.ContinueOnError)
+	flagSet.SetOutput(io.Discard)
+	limit := flagSet.Int("limit", 50, "max users to print")
+	offset := flagSet.Int("offset", 0, "number of users to skip")
+	if err := flagSet.Parse(args); err != nil {
+		return fmt.Errorf("parse user list args failed: %w", err)
+	}
+	if len(flagSet.Args()) > 0 {
+		return fmt.Errorf("unexpected positional args: %s", strings.Join(flagSet.Args(), " "))
+	}
+	if *limit <= 0 {
+		return fmt.Errorf("--limit must be greater than 0")
+	}
+	if *offset < 0 {
+		return fmt.Errorf("--offset must not be negative")
+	}
+
+	users, total, err := userService.ListUsers(ctx, *limit, *offset)
+	if err != nil {
+		return fmt.Errorf("list users failed: %w", err)
+	}
+	fmt.Printf("users total=%d, offset=%d, count=%d\n", total, *offset, len(users))
+	fmt.Println("id\tusername\trole\tdisplayName\tcreateTime")
+	for _, user := range users {
+		fmt.Printf(
+			"%d\t%s\t%s\t%s\t%s\n",
+			user.ID,
+			user.Username,
+			user.Role,
+			user.DisplayName,
+			user.CreateTime.UTC().Format(time.RFC3339),
+		)
+	}
+	if next := *offset + len(users); int64(next) < total {
+		fmt.Printf("next page: user list --limit %d --offset %d\n", *limit, next)
+	}
+	return nil
+}
+
+func runAdminUserDelete(ctx context.Context, userService *service.UserService, args []string, interactiveInput io.Reader) error {
+	if len(args) != 1 {
+		printUsage()
+		return fmt.Errorf("usage: admin user delete <username_or_id>")
+	}
+	identifier := strings.TrimSpace(args[0])
+
+	impact, err := userService.PreviewDeleteUser(ctx, identifier)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("user not found: %s", identifier)
+		}
+		return fmt.Errorf("delete user failed: %w", err)
+	}
+	fmt.Printf(
+		"about to delete user=%s(%d) role=%s, cascading memos=%d attachments=%d (tokens and group data are removed too)\n",
+		impact.User.Username,
+		impact.User.ID,
+		impact.User.Role,
+		impact.Memos,
+		impact.Attachments,
+	)
+	confirmed, err := confirmAction(interactiveInput, os.Stdout, fmt.Sprintf("type yes to delete user %s", impact.User.Username))
+	if err != nil {
+		return fmt.Errorf("interactive input failed: %w", err)
+	}
+	if !confirmed {
+		fmt.Println("user delete cancelled")
+		return nil
+	}
+
+	user, err := userService.DeleteUser(ctx, strconv.FormatInt(impact.User.ID, 10))
+	if err != nil {
+		if errors.Is(err, service.ErrLastAdmin) {
+			return fmt.Errorf("delete user failed: %s is the last admin user", user.Username)
+		}
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("user not found: %s", identifier)
+		}
+		return fmt.Errorf("delete user failed: %w", err)
+	}
+	fmt.Printf("user deleted: id=%d username=%s\n", user.ID, user.Username)
+	return nil
+}
+
+func runAdminUserSetPassword(ctx context.Context, userService *service.UserService, args []string, interactiveInput io.Reader) error {
+	if len(args) < 1 || len(args) > 2 {
+		printUsage()
+		return fmt.Errorf("usage: admin user set-password <username_or_id> [new_password]")
+	}
+	identifier := strings.TrimSpace(args[0])
+
+	password := ""
+	if len(args) == 2 {
+		password = strings.TrimSpace(args[1])
+	} else {
+		var err error
+		password, err = collectInteractivePassword(interactiveInput, os.Stdout)
+		if err != nil {
+			return fmt.Errorf("interactive input failed: %w", err)
+		}
+	}
+
+	user, err := userService.SetPassword(ctx, identifier, password)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("user not found: %s", identifier)
+		}
+		if errors.Is(err, service.ErrInvalidPassword) {
+			return fmt.Errorf("set password failed: password must be at least 8 characters")
+		}
+		return fmt.Errorf("set password failed: %w", err)
+	}
+	fmt.Printf("password updated: id=%d username=%s\n", user.ID, user.Username)
+	return nil
+}
+
+func runAdminUserResetToken(ctx context.Context, userService *service.UserService, args []string) error {
+	if len(args) != 1 {
+		printUsage()
+		return fmt.Errorf("usage: admin user reset-token <username_or_id>")
+	}
+	identifier := strings.TrimSpace(args[0])
+
+	token, expiresAt, err := userService.CreatePasswordResetToken(ctx, identifier)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("user not found: %s", identifier)
+		}
+		if errors.Is(err, service.ErrTokenAlreadyExists) {
+			return fmt.Errorf("create reset token failed: token collision, please retry")
+		}
+		return fmt.Errorf("create reset token failed: %w", err)
+	}
+	fmt.Printf("resetToken=%s\n", token)
+	fmt.Printf("expiresAt=%s\n", expiresAt.UTC().Format(time.RFC3339))
+	return nil
+}
+
+func runAdminUserSetRole(ctx context.Context, userService *service.UserService, args []string) error {
+	if len(args) != 2 {
+		printUsage()
+		return fmt.Errorf("usage: admin user set-role <username_or_id> <ADMIN|USER>")
+	}
+	identifier := strings.TrimSpace(args[0])
+
+	user, err := userService.SetRole(ctx, identifier, args[1])
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidRole) {
+			return fmt.Errorf("set role failed: role must be ADMIN or USER")
+		}
+		if errors.Is(err, service.ErrLastAdmin) {
+			return fmt.Errorf("set role failed: %s is the last admin user", user.Username)
+		}
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("user not found: %s", identifier)
+		}
+		return fmt.Errorf("set role failed: %w", err)
+	}
+	fmt.Printf("role updated: id=%d username=%s role=%s\n", user.ID, user.Username, user.Role)
+	return nil
+}
+
 func runAdminToken(ctx context.Context, userService *service.UserService, args []string) error {
 	if len(args) == 0 {
 		printUsage()
-		return fmt.Errorf("usage: admin token <create|list|revoke> ...")
+		return fmt.Errorf("usage: admin token <create|list|revoke|rotate> ...")
 	}
 	switch args[0] {
 	case "create":
@@ -208,6 +417,8 @@ func runAdminToken(ctx context.Context, userService *service.UserService, args [
 		return runAdminTokenList(ctx, userService, args[1:])
 	case "revoke":
 		return runAdminTokenRevoke(ctx, userService, args[1:])
+	case "rotate":
+		return runAdminTokenRotate(ctx, userService, args[1:])
 	default:
 		printUsage()
 		return fmt.Errorf("unknown token subcommand: %s", args[0])
@@ -217,7 +428,7 @@ func runAdminToken(ctx context.Context, userService *service.UserService, args [
 func runAdminTokenCreate(ctx context.Context, userService *service.UserService, args []string) error {
 	if len(args) < 1 {
 		printUsage()
-		return fmt.Errorf("usage: token create <username_or_id> [description] [--ttl 7d|24h] (default ttl: 7d)")
+		return fmt.Errorf("usage: token create <username_or_id> [description] [--ttl 7d|24h] [--scopes memos:read,attachments:write] (default ttl: 7d)")
 	}
 
 	identifier := strings.TrimSpace(args[0])
@@ -225,6 +436,7 @@ func runAdminTokenCreate(ctx context.Context, userService *service.UserService,
 	flagSet.SetOutput(io.Discard)
 	descriptionFlag := flagSet.String("description", "", "token description")
 	ttlFlag := flagSet.String("ttl", "", "token ttl, e.g. 24h")
+	scopesFlag := flagSet.String("scopes", "", "comma separated scopes, e.g. memos:read,attachments:write")
 	if err := flagSet.Parse(args[1:]); err != nil {
 		return fmt.Errorf("parse token args failed: %w", err)
 	}
@@ -243,7 +455,12 @@ func runAdminTokenCreate(ctx context.Context, userService *service.UserService,
 		return err
 	}
 
-	user, token, err := userService.CreateAccessTokenForUserWithExpiry(ctx, identifier, description, expiresAt)
+	scopes, err := service.ParseTokenScopes(*scopesFlag)
+	if err != nil {
+		return fmt.Errorf("invalid --scopes: %w (resources: %s; actions: read, write)", err, strings.Join(service.TokenScopeResources, ", "))
+	}
+
+	user, token, err := userService.CreateAccessTokenForUserWithScopes(ctx, identifier, description, expiresAt, scopes)
 	if err != nil {
 		if errors.Is(err, service.ErrTokenAlreadyExists) {
 			return fmt.Errorf("create token failed: token collision, please retry")
@@ -258,6 +475,9 @@ func runAdminTokenCreate(ctx context.Context, userService *service.UserService,
 	if expiresAt != nil {
 		fmt.Printf("expiresAt=%s\n", expiresAt.UTC().Format(time.RFC3339))
 	}
+	if len(scopes) > 0 {
+		fmt.Printf("scopes=%s\n", strings.Join(scopes, ","))
+	}
 	return nil
 }
 
@@ -277,80 +497,177 @@ func resolveTokenExpiresAt(ttlRaw string, now time.Time) (*time.Time, error) {
 	return &v, nil
 }
 
+type tokenListOptions struct {
+	Identifier string
+	IncludeAll bool
+	JSON       bool
+	Limit      int
+	Offset     int
+}
+
+type tokenListItem struct {
+	ID                int64    `json:"id"`
+	Prefix            string   `json:"prefix"`
+	CreatedAt         string   `json:"createdAt"`
+	ExpiresAt         *string  `json:"expiresAt"`
+	RevokedAt         *string  `json:"revokedAt"`
+	LastUsedAt        *string  `json:"lastUsedAt"`
+	LastUsedIP        string   `json:"lastUsedIp"`
+	LastUsedUserAgent string   `json:"lastUsedUserAgent"`
+	Description       string   `json:"description"`
+	Scopes            []string `json:"scopes"`
+	PrefixShared      bool     `json:"prefixShared,omitempty"`
+}
+
 func runAdminTokenList(ctx context.Context, userService *service.UserService, args []string) error {
-	identifier, includeAll, err := parseTokenListArgs(args)
+	opts, err := parseTokenListArgs(args)
 	if err != nil {
 		printUsage()
 		return err
 	}
-	user, tokens, err := userService.ListAccessTokensForUser(ctx, identifier)
+	user, tokens, total, err := userService.ListAccessTokensForUserPaged(ctx, opts.Identifier, opts.IncludeAll, opts.Limit, opts.Offset)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return fmt.Errorf("user not found: %s", identifier)
+			return fmt.Errorf("user not found: %s", opts.Identifier)
 		}
 		return fmt.Errorf("list tokens failed: %w", err)
 	}
 
-	filtered := tokens
-	if !includeAll {
-		filtered = make([]models.PersonalAccessToken, 0, len(tokens))
+	sharedPrefixes := sharedTokenPrefixes(tokens)
+	if opts.JSON {
+		items := make([]tokenListItem, 0, len(tokens))
 		for _, token := range tokens {
-			if token.RevokedAt == nil {
-				filtered = append(filtered, token)
-			}
+			items = append(items, tokenListItem{
+				ID:                token.ID,
+				Prefix:            token.TokenPrefix,
+				CreatedAt:         token.CreatedAt.UTC().Format(time.RFC3339),
+				ExpiresAt:         formatOptionalTimeJSON(token.ExpiresAt),
+				RevokedAt:         formatOptionalTimeJSON(token.RevokedAt),
+				LastUsedAt:        formatOptionalTimeJSON(token.LastUsedAt),
+				LastUsedIP:        token.LastUsedIP,
+				LastUsedUserAgent: token.LastUsedUserAgent,
+				Description:       strings.TrimSpace(token.Description),
+				Scopes:            token.Scopes,
+				PrefixShared:      len(sharedPrefixes[token.TokenPrefix]) > 1,
+			})
 		}
+		encoded, err := json.MarshalIndent(items, "", "  ")
+		if err != nil {
+			return fmt.Errorf("encode tokens failed: %w", err)
+		}
+		fmt.Println(string(encoded))
+		return nil
 	}
 
 	scope := "active"
-	if includeAll {
+	if opts.IncludeAll {
 		scope = "all"
 	}
-	fmt.Printf("tokens for user=%s(%d), count=%d, scope=%s\n", user.Username, user.ID, len(filtered), scope)
-	fmt.Println("id\tprefix\tcreatedAt\texpiresAt\trevokedAt\tlastUsedAt\tdescription")
-	for _, token := range filtered {
+	if opts.Limit > 0 || opts.Offset > 0 {
+		fmt.Printf("tokens for user=%s(%d), total=%d, offset=%d, count=%d, scope=%s\n", user.Username, user.ID, total, opts.Offset, len(tokens), scope)
+	} else {
+		fmt.Printf("tokens for user=%s(%d), count=%d, scope=%s\n", user.Username, user.ID, len(tokens), scope)
+	}
+	fmt.Println("id\tprefix\tcreatedAt\texpiresAt\trevokedAt\tlastUsedAt\tlastUsedIp\tlastUsedUserAgent\tscopes\tdescription")
+	for _, token := range tokens {
+		scopes := "*"
+		if len(token.Scopes) > 0 {
+			scopes = strings.Join(token.Scopes, ",")
+		}
 		fmt.Printf(
-			"%d\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			"%d\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
 			token.ID,
 			token.TokenPrefix,
 			token.CreatedAt.UTC().Format(time.RFC3339),
 			formatOptionalTime(token.ExpiresAt),
 			formatOptionalTime(token.RevokedAt),
 			formatOptionalTime(token.LastUsedAt),
+			formatOptionalString(token.LastUsedIP),
+			formatOptionalString(token.LastUsedUserAgent),
+			scopes,
 			strings.TrimSpace(token.Description),
 		)
 	}
+	for _, token := range tokens {
+		ids := sharedPrefixes[token.TokenPrefix]
+		if len(ids) < 2 || ids[0] != token.ID {
+			continue
+		}
+		idTexts := make([]string, 0, len(ids))
+		for _, id := range ids {
+			idTexts = append(idTexts, strconv.FormatInt(id, 10))
+		}
+		fmt.Printf("note: prefix %s is shared by tokens %s; use the id to identify a token\n", token.TokenPrefix, strings.Join(idTexts, ","))
+	}
+	if next := opts.Offset + len(tokens); opts.Limit > 0 && int64(next) < total {
+		fmt.Printf("next page: token list %s --limit %d --offset %d\n", opts.Identifier, opts.Limit, next)
+	}
 	return nil
 }
 
-func parseTokenListArgs(args []string) (string, bool, error) {
+// sharedTokenPrefixes 按前缀分组令牌 ID；前缀只是展示用的截断，不同令牌可能相同。
+func sharedTokenPrefixes(tokens []models.PersonalAccessToken) map[string][]int64 {
+	byPrefix := make(map[string][]int64, len(tokens))
+	for _, token := range tokens {
+		byPrefix[token.TokenPrefix] = append(byPrefix[token.TokenPrefix], token.ID)
+	}
+	return byPrefix
+}
+
+func parseTokenListArgs(args []string) (tokenListOptions, error) {
+	const usage = "usage: token list <username_or_id> [--all] [--json] [--limit N] [--offset N]"
 	if len(args) == 0 {
-		return "", false, fmt.Errorf("usage: token list <username_or_id> [--all]")
+		return tokenListOptions{}, fmt.Errorf(usage)
 	}
 
-	includeAll := false
-	identifier := ""
-	for _, arg := range args {
-		value := strings.TrimSpace(arg)
+	opts := tokenListOptions{}
+	for i := 0; i < len(args); i++ {
+		value := strings.TrimSpace(args[i])
 		if value == "" {
 			continue
 		}
 		if value == "--all" {
-			includeAll = true
+			opts.IncludeAll = true
+			continue
+		}
+		if value == "--json" {
+			opts.JSON = true
+			continue
+		}
+		name, inlineValue, hasInline := strings.Cut(value, "=")
+		if name == "--limit" || name == "--offset" {
+			raw := inlineValue
+			if !hasInline {
+				if i+1 >= len(args) {
+					return tokenListOptions{}, fmt.Errorf("missing value for %s", name)
+				}
+				i++
+				raw = args[i]
+			}
+			n, err := strconv.Atoi(strings.TrimSpace(raw))
+			if err != nil || n < 0 || (name == "--limit" && n == 0) {
+				return tokenListOptions{}, fmt.Errorf("invalid %s %q", name, raw)
+			}
+			if name == "--limit" {
+				opts.Limit = n
+			} else {
+				opts.Offset = n
+			}
 			continue
 		}
 		if strings.HasPrefix(value, "--") {
-			return "", false, fmt.Errorf("unknown option: %s", value)
+			return tokenListOptions{}, fmt.Errorf("unknown option: %s", value)
 		}
-		if identifier == "" {
-			identifier = value
+		if opts.Identifier == "" {
+			opts.Identifier = value
 			continue
 		}
-		return "", false, fmt.Errorf("unexpected argument: %s", value)
+		return tokenListOptions{}, fmt.Errorf("unexpected argument: %s", value)
 	}
-	if identifier == "" {
-		return "", false, fmt.Errorf("usage: token list <username_or_id> [--all]")
+	if opts.Identifier == "" {
+		return tokenListOptions{}, fmt.Errorf(usage)
 	}
-	return identifier, includeAll, nil
+	return opts, nil
 }
 
 func runAdminTokenRevoke(ctx context.Context, userService *service.UserService, args []string) error {
@@ -378,12 +695,46 @@ func runAdminTokenRevoke(ctx context.Context, userService *service.UserService,
 	return nil
 }
 
+func runAdminTokenRotate(ctx context.Context, userService *service.UserService, args []string) error {
+	if len(args) < 1 {
+		printUsage()
+		return fmt.Errorf("usage: admin token rotate <token_id>")
+	}
+	tokenID, err := strconv.ParseInt(strings.TrimSpace(args[0]), 10, 64)
+	if err != nil || tokenID <= 0 {
+		return fmt.Errorf("invalid token_id: %s", args[0])
+	}
+
+	token, rawToken, err := userService.RotateAccessToken(ctx, tokenID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("token not found: %d", tokenID)
+		}
+		if errors.Is(err, service.ErrTokenAlreadyRevoked) {
+			return fmt.Errorf("rotate token failed: token already revoked: id=%d revokedAt=%s", tokenID, formatOptionalTime(token.RevokedAt))
+		}
+		if errors.Is(err, service.ErrInvalidTokenExpiry) {
+			return fmt.Errorf("rotate token failed: token already expired: id=%d expiresAt=%s", tokenID, formatOptionalTime(token.ExpiresAt))
+		}
+		if errors.Is(err, service.ErrTokenAlreadyExists) {
+			return fmt.Errorf("rotate token failed: token collision, please retry")
+		}
+		return fmt.Errorf("rotate token failed: %w", err)
+	}
+	fmt.Printf("token rotated: old_id=%d new_id=%d user_id=%d\n", tokenID, token.ID, token.UserID)
+	fmt.Printf("accessToken=%s\n", rawToken)
+	fmt.Printf("expiresAt=%s\n", formatOptionalTime(token.ExpiresAt))
+	return nil
+}
+
 func runAdminRegistration(ctx context.Context, userService *service.UserService, fallback bool, args []string) error {
 	if len(args) < 1 {
 		printUsage()
-		return fmt.Errorf("usage: admin registration <status|enable|disable>")
+		return fmt.Errorf("usage: admin registration <status|enable|disable|invite create [--ttl 7d]>")
 	}
 	switch args[0] {
+	case "invite":
+		return runAdminRegistrationInvite(ctx, userService, args[1:])
 	case "status":
 		allow, err := userService.ResolveAllowRegistration(ctx, fallback)
 		if err != nil {
@@ -409,10 +760,58 @@ func runAdminRegistration(ctx context.Context, userService *service.UserService,
 	}
 }
 
+func runAdminRegistrationInvite(ctx context.Context, userService *service.UserService, args []string) error {
+	const usage = "usage: registration invite create [--ttl 7d|24h] (default ttl: 7d)"
+	if len(args) < 1 || args[0] != "create" {
+		return fmt.Errorf(usage)
+	}
+	flagSet := flag.NewFlagSet("admin registration invite create", flag.ContinueOnError)
+	flagSet.SetOutput(io.Discard)
+	ttlFlag := flagSet.String("ttl", "", "invite ttl, e.g. 24h")
+	if err := flagSet.Parse(args[1:]); err != nil {
+		return fmt.Errorf("parse invite args failed: %w", err)
+	}
+	if len(flagSet.Args()) > 0 {
+		return fmt.Errorf(usage)
+	}
+	now := time.Now().UTC()
+	expiresAt, err := resolveTokenExpiresAt(*ttlFlag, now)
+	if err != nil {
+		return err
+	}
+
+	token, inviteExpiresAt, err := userService.CreateInviteToken(ctx, expiresAt.Sub(now))
+	if err != nil {
+		if errors.Is(err, service.ErrTokenAlreadyExists) {
+			return fmt.Errorf("create invite failed: token collision, please retry")
+		}
+		return fmt.Errorf("create invite failed: %w", err)
+	}
+	fmt.Printf("inviteToken=%s\n", token)
+	fmt.Printf("expiresAt=%s\n", inviteExpiresAt.UTC().Format(time.RFC3339))
+	return nil
+}
+
+func runAdminSearch(ctx context.Context, memoService *service.MemoService, args []string) error {
+	if len(args) != 1 || args[0] != "reindex" {
+		printUsage()
+		return fmt.Errorf("usage: admin search reindex")
+	}
+
+	indexed, err := memoService.RebuildSearchIndex(ctx, func(indexed int64, total int64) {
+		fmt.Printf("search reindex progress: %d/%d\n", indexed, total)
+	})
+	if err != nil {
+		return fmt.Errorf("search reindex failed: %w", err)
+	}
+	fmt.Printf("search reindex completed: indexed=%d\n", indexed)
+	return nil
+}
+
 func runAdminStorage(ctx context.Context, storageService *service.StorageSettingsService, args []string, interactiveInput io.Reader) error {
 	if len(args) < 1 {
 		printUsage()
-		return fmt.Errorf("usage: admin storage <status|set-local|set-s3|wizard>")
+		return fmt.Errorf("usage: admin storage <status|test|migrate|set-local|set-s3|set-gcs|wizard>")
 	}
 
 	switch args[0] {
@@ -422,6 +821,9 @@ func runAdminStorage(ctx context.Context, storageService *service.StorageSetting
 			return fmt.Errorf("read storage setting failed: %w", err)
 		}
 		fmt.Printf("storage_backend=%s\n", resolved.Backend)
+		if resolved.Backend == config.StorageBackendLocal {
+			fmt.Printf("storage_local_path=%s\n", resolved.LocalPath)
+		}
 		if resolved.Backend == config.StorageBackendS3 {
 			fmt.Printf("storage_s3_endpoint=%s\n", resolved.S3.Endpoint)
 			fmt.Printf("storage_s3_region=%s\n", resolved.S3.Region)
@@ -430,16 +832,29 @@ func runAdminStorage(ctx context.Context, storageService *service.StorageSetting
 			fmt.Printf("storage_s3_access_key_secret=%s\n", maskSecret(resolved.S3.AccessSecret))
 			fmt.Printf("storage_s3_use_path_style=%t\n", resolved.S3.UsePathStyle)
 		}
-		return nil
-	case "set-local":
-		if err := storageService.SetLocal(ctx); err != nil {
-			return fmt.Errorf("set storage backend local failed: %w", err)
+		if resolved.Backend == config.StorageBackendGCS {
+			fmt.Printf("storage_gcs_bucket=%s\n", resolved.GCS.Bucket)
+			fmt.Printf("storage_gcs_credentials_file=%s\n", resolved.GCS.CredentialsFile)
+		}
+		checkCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		defer cancel()
+		if err := storageService.CheckBackend(checkCtx); err != nil {
+			fmt.Println("storage_check=unreachable")
+			fmt.Printf("storage_check_error=%v\n", err)
+		} else {
+			fmt.Println("storage_check=reachable")
 		}
-		fmt.Println("storage_backend=local")
-		fmt.Println("note: restart server to apply storage backend change")
 		return nil
+	case "test":
+		return runAdminStorageTest(ctx, storageService)
+	case "migrate":
+		return runAdminStorageMigrate(ctx, storageService, args[1:])
+	case "set-local":
+		return runAdminStorageSetLocal(ctx, storageService, args[1:])
 	case "set-s3":
 		return runAdminStorageSetS3(ctx, storageService, args[1:], interactiveInput)
+	case "set-gcs":
+		return runAdminStorageSetGCS(ctx, storageService, args[1:])
 	case "wizard":
 		return runAdminStorageWizard(ctx, storageService, interactiveInput)
 	default:
@@ -448,6 +863,161 @@ func runAdminStorage(ctx context.Context, storageService *service.StorageSetting
 	}
 }
 
+func runAdminStorageSetLocal(ctx context.Context, storageService *service.StorageSettingsService, args []string) error {
+	flagSet := flag.NewFlagSet("admin storage set-local", flag.ContinueOnError)
+	flagSet.SetOutput(io.Discard)
+	path := flagSet.String("path", "", "local upload directory (default: keep current)")
+	if err := flagSet.Parse(args); err != nil {
+		return fmt.Errorf("parse storage args failed: %w", err)
+	}
+	if len(flagSet.Args()) > 0 {
+		return fmt.Errorf("unexpected positional args: %s", strings.Join(flagSet.Args(), " "))
+	}
+
+	if err := storageService.SetLocal(ctx, *path); err != nil {
+		return fmt.Errorf("set storage backend local failed: %w", err)
+	}
+	resolved, err := storageService.Resolve(ctx)
+	if err != nil {
+		return fmt.Errorf("read storage setting failed: %w", err)
+	}
+	fmt.Println("storage_backend=local")
+	fmt.Printf("storage_local_path=%s\n", resolved.LocalPath)
+	fmt.Println("note: restart server to apply storage backend change")
+	return nil
+}
+
+func runAdminStorageMigrate(ctx context.Context, storageService *service.StorageSettingsService, args []string) error {
+	flagSet := flag.NewFlagSet("admin storage migrate", flag.ContinueOnError)
+	flagSet.SetOutput(io.Discard)
+	from := flagSet.String("from", "", "source storage backend (local|s3|gcs)")
+	to := flagSet.String("to", "", "target storage backend (local|s3|gcs)")
+	deleteSource := flagSet.Bool("delete-source", false, "delete source objects after migration")
+	if err := flagSet.Parse(args); err != nil {
+		return fmt.Errorf("parse storage args failed: %w", err)
+	}
+	if len(flagSet.Args()) > 0 {
+		return fmt.Errorf("unexpected positional args: %s", strings.Join(flagSet.Args(), " "))
+	}
+	if strings.TrimSpace(*from) == "" || strings.TrimSpace(*to) == "" {
+		return fmt.Errorf("usage: storage migrate --from <local|s3|gcs> --to <local|s3|gcs> [--delete-source]")
+	}
+
+	result, err := storageService.MigrateAttachments(
+		ctx,
+		config.StorageBackend(strings.ToLower(strings.TrimSpace(*from))),
+		config.StorageBackend(strings.ToLower(strings.TrimSpace(*to))),
+		*deleteSource,
+		func(format string, args ...any) {
+			fmt.Printf(format+"\n", args...)
+		},
+	)
+	fmt.Printf("storage_migrate_migrated=%d\n", result.Migrated)
+	fmt.Printf("storage_migrate_skipped=%d\n", result.Skipped)
+	fmt.Printf("storage_migrate_failed=%d\n", result.Failed)
+	fmt.Printf("storage_migrate_objects_copied=%d\n", result.Copied)
+	fmt.Printf("storage_migrate_avatars_copied=%d\n", result.Avatars)
+	if err != nil {
+		return fmt.Errorf("migrate storage failed: %w", err)
+	}
+	if result.Failed > 0 {
+		return fmt.Errorf("%d attachments failed to migrate, rerun the command to retry", result.Failed)
+	}
+	return nil
+}
+
+// storageProbeTimeout 限制 storage test 整体耗时，端点不可达时尽快失败。
+const storageProbeTimeout = 30 * time.Second
+
+func runAdminStorageTest(ctx context.Context, storageService *service.StorageSettingsService) error {
+	ctx, cancel := context.WithTimeout(ctx, storageProbeTimeout)
+	defer cancel()
+	backend, err := storageService.OpenBackend(ctx)
+	if err != nil {
+		return fmt.Errorf("open storage backend failed: %w", err)
+	}
+	if err := probeStorage(ctx, backend, os.Stdout); err != nil {
+		fmt.Println("storage_test=failed")
+		return err
+	}
+	fmt.Println("storage_test=ok")
+	return nil
+}
+
+// probeStorage 写入一个探测对象、读回比对、（S3 时）经预签名地址下载，最后删除，逐步打印结果；失败时返回出错的步骤。
+func probeStorage(ctx context.Context, backend storage.Store, out io.Writer) (err error) {
+	nonce := make([]byte, 8)
+	if _, err := rand.Read(nonce); err != nil {
+		return fmt.Errorf("generate probe key: %w", err)
+	}
+	key := fmt.Sprintf("keer-probe/%d-%s", time.Now().UnixNano(), hex.EncodeToString(nonce))
+	payload := []byte("keer storage probe " + key)
+
+	if _, err := backend.Put(ctx, key, "text/plain", payload); err != nil {
+		return fmt.Errorf("storage test failed at put: %w", err)
+	}
+	fmt.Fprintln(out, "storage_test_put=ok")
+	defer func() {
+		if deleteErr := backend.Delete(ctx, key); deleteErr != nil {
+			if err == nil {
+				err = fmt.Errorf("storage test failed at delete: %w", deleteErr)
+			} else {
+				fmt.Fprintf(out, "warning: probe object %s was not deleted: %v\n", key, deleteErr)
+			}
+			return
+		}
+		fmt.Fprintln(out, "storage_test_delete=ok")
+	}()
+
+	if err := verifyProbeContent(func() (io.ReadCloser, error) { return backend.Open(ctx, key) }, payload); err != nil {
+		return fmt.Errorf("storage test failed at read: %w", err)
+	}
+	fmt.Fprintln(out, "storage_test_read=ok")
+
+	if presigner, ok := backend.(storage.Presigner); ok {
+		directURL, err := presigner.PresignGetObjectURL(ctx, key, time.Minute)
+		if err != nil {
+			return fmt.Errorf("storage test failed at presign: %w", err)
+		}
+		openPresigned := func() (io.ReadCloser, error) {
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, directURL, nil)
+			if err != nil {
+				return nil, err
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				return nil, err
+			}
+			if resp.StatusCode != http.StatusOK {
+				_ = resp.Body.Close()
+				return nil, fmt.Errorf("presigned GET returned %s", resp.Status)
+			}
+			return resp.Body, nil
+		}
+		if err := verifyProbeContent(openPresigned, payload); err != nil {
+			return fmt.Errorf("storage test failed at presigned get: %w", err)
+		}
+		fmt.Fprintln(out, "storage_test_presigned_get=ok")
+	}
+	return nil
+}
+
+func verifyProbeContent(open func() (io.ReadCloser, error), want []byte) error {
+	rc, err := open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	got, err := io.ReadAll(io.LimitReader(rc, int64(len(want))+1))
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(got, want) {
+		return fmt.Errorf("content mismatch: wrote %d bytes, read back %d bytes", len(want), len(got))
+	}
+	return nil
+}
+
 func runAdminStorageSetS3(ctx context.Context, storageService *service.StorageSettingsService, args []string, interactiveInput io.Reader) error {
 	flagSet := flag.NewFlagSet("admin storage set-s3", flag.ContinueOnError)
 	flagSet.SetOutput(io.Discard)
@@ -498,6 +1068,30 @@ func runAdminStorageSetS3(ctx context.Context, storageService *service.StorageSe
 	return nil
 }
 
+func runAdminStorageSetGCS(ctx context.Context, storageService *service.StorageSettingsService, args []string) error {
+	flagSet := flag.NewFlagSet("admin storage set-gcs", flag.ContinueOnError)
+	flagSet.SetOutput(io.Discard)
+	bucket := flagSet.String("bucket", "", "GCS bucket")
+	credentialsFile := flagSet.String("credentials-file", "", "GCS service account JSON key file")
+	if err := flagSet.Parse(args); err != nil {
+		return fmt.Errorf("parse storage args failed: %w", err)
+	}
+	if len(flagSet.Args()) > 0 {
+		return fmt.Errorf("unexpected positional args: %s", strings.Join(flagSet.Args(), " "))
+	}
+
+	if err := storageService.SetGCS(ctx, config.GCSConfig{
+		Bucket:          *bucket,
+		CredentialsFile: *credentialsFile,
+	}); err != nil {
+		return fmt.Errorf("set storage backend gcs failed: %w", err)
+	}
+
+	fmt.Println("storage_backend=gcs")
+	fmt.Println("note: restart server to apply storage backend change")
+	return nil
+}
+
 func runAdminStorageWizard(ctx context.Context, storageService *service.StorageSettingsService, interactiveInput io.Reader) error {
 	return runAdminStorageSetS3Interactive(ctx, storageService, config.S3Config{}, false, interactiveInput)
 }
@@ -557,11 +1151,19 @@ func printUsage() {
 func printRuntimeConsoleUsage() {
 	fmt.Println("Runtime Console Commands:")
 	fmt.Println("  user create <username> <password> [display_name] [role]")
-	fmt.Println("  token create <username_or_id> [description] [--ttl 7d|24h]  # default ttl=7d")
-	fmt.Println("  token list <username_or_id> [--all]")
+	fmt.Println("  user list [--limit 50] [--offset 0]")
+	fmt.Println("  user delete <username_or_id>  # requires typing yes")
+	fmt.Println("  user set-password <username_or_id> [new_password]  # omit password to enter it interactively")
+	fmt.Println("  user reset-token <username_or_id>  # one-time token for POST /api/v1/auth/resetPassword")
+	fmt.Println("  user set-role <username_or_id> <ADMIN|USER>")
+	fmt.Println("  token create <username_or_id> [description] [--ttl 7d|24h] [--scopes memos:read,...]  # default ttl=7d, full access")
+	fmt.Println("  token list <username_or_id> [--all] [--json] [--limit N] [--offset N]")
 	fmt.Println("  token revoke <token_id>")
+	fmt.Println("  token rotate <token_id>")
 	fmt.Println("  registration status|enable|disable")
-	fmt.Println("  storage status|set-local|set-s3 ...|wizard")
+	fmt.Println("  registration invite create [--ttl 7d|24h]  # one-time invite, works while registration is disabled")
+	fmt.Println("  storage status|test|migrate --from local --to s3 [--delete-source]|set-local [--path DIR]|set-s3 ...|set-gcs ...|wizard")
+	fmt.Println("  search reindex")
 	fmt.Println("  help")
 	fmt.Println("  exit")
 }
@@ -573,6 +1175,21 @@ func formatOptionalTime(t *time.Time) string {
 	return t.UTC().Format(time.RFC3339)
 }
 
+func formatOptionalString(v string) string {
+	if strings.TrimSpace(v) == "" {
+		return "-"
+	}
+	return v
+}
+
+func formatOptionalTimeJSON(t *time.Time) *string {
+	if t == nil {
+		return nil
+	}
+	v := t.UTC().Format(time.RFC3339)
+	return &v
+}
+
 func maskSecret(raw string) string {
 	raw = strings.TrimSpace(raw)
 	if raw == "" {
@@ -628,6 +1245,45 @@ func collectInteractiveS3Config(input io.Reader, output io.Writer, defaults conf
 	}, nil
 }
 
+func collectInteractivePassword(input io.Reader, output io.Writer) (string, error) {
+	if input == nil {
+		return "", fmt.Errorf("interactive input is required")
+	}
+	if output == nil {
+		output = io.Discard
+	}
+	reader := bufio.NewReader(input)
+
+	password, err := promptRequiredString(reader, output, "New password", "")
+	if err != nil {
+		return "", err
+	}
+	confirm, err := promptRequiredString(reader, output, "Confirm password", "")
+	if err != nil {
+		return "", err
+	}
+	if password != confirm {
+		return "", fmt.Errorf("passwords do not match")
+	}
+	return password, nil
+}
+
+func confirmAction(input io.Reader, output io.Writer, label string) (bool, error) {
+	if input == nil {
+		return false, fmt.Errorf("interactive input is required")
+	}
+	if output == nil {
+		output = io.Discard
+	}
+	reader := bufio.NewReader(input)
+	fmt.Fprintf(output, "%s: ", label)
+	raw, err := reader.ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return false, err
+	}
+	return strings.TrimSpace(raw) == "yes", nil
+}
+
 func promptRequiredString(reader *bufio.Reader, output io.Writer, label string, defaultValue string) (string, error) {
 	for {
 		if strings.TrimSpace(defaultValue) == "" {
@@ -2,11 +2,17 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/shinyes/keer/internal/config"
+	"github.com/shinyes/keer/internal/models"
+	"github.com/shinyes/keer/internal/storage"
 )
 
 func TestParseTTL(t *testing.T) {
@@ -224,12 +230,52 @@ func TestParseBoolInput(t *testing.T) {
 	}
 }
 
+func TestConfirmAction(t *testing.T) {
+	tests := []struct {
+		input string
+		want  bool
+	}{
+		{input: "yes\n", want: true},
+		{input: "  yes  \n", want: true},
+		{input: "y\n", want: false},
+		{input: "YES\n", want: false},
+		{input: "", want: false},
+	}
+
+	for _, tc := range tests {
+		got, err := confirmAction(strings.NewReader(tc.input), &bytes.Buffer{}, "confirm")
+		if err != nil {
+			t.Fatalf("confirmAction(%q) unexpected error: %v", tc.input, err)
+		}
+		if got != tc.want {
+			t.Fatalf("confirmAction(%q) got=%v want %v", tc.input, got, tc.want)
+		}
+	}
+}
+
+func TestCollectInteractivePassword(t *testing.T) {
+	got, err := collectInteractivePassword(strings.NewReader("\nsecret-pass\nsecret-pass\n"), &bytes.Buffer{})
+	if err != nil {
+		t.Fatalf("collectInteractivePassword() error = %v", err)
+	}
+	if got != "secret-pass" {
+		t.Fatalf("collectInteractivePassword() got %q", got)
+	}
+
+	if _, err := collectInteractivePassword(strings.NewReader("secret-pass\nother-pass\n"), &bytes.Buffer{}); err == nil {
+		t.Fatalf("expected mismatch error, got nil")
+	}
+}
+
 func TestParseTokenListArgs(t *testing.T) {
 	tests := []struct {
 		name       string
 		args       []string
 		wantID     string
 		wantAll    bool
+		wantJSON   bool
+		wantLimit  int
+		wantOffset int
 		shouldFail bool
 	}{
 		{
@@ -250,6 +296,30 @@ func TestParseTokenListArgs(t *testing.T) {
 			wantID:  "1",
 			wantAll: true,
 		},
+		{
+			name:     "json with all",
+			args:     []string{"alice", "--json", "--all"},
+			wantID:   "alice",
+			wantAll:  true,
+			wantJSON: true,
+		},
+		{
+			name:       "limit and offset",
+			args:       []string{"alice", "--limit", "10", "--offset=20"},
+			wantID:     "alice",
+			wantLimit:  10,
+			wantOffset: 20,
+		},
+		{
+			name:       "zero limit",
+			args:       []string{"alice", "--limit", "0"},
+			shouldFail: true,
+		},
+		{
+			name:       "missing limit value",
+			args:       []string{"alice", "--limit"},
+			shouldFail: true,
+		},
 		{
 			name:       "missing identifier",
 			args:       []string{"--all"},
@@ -268,7 +338,7 @@ func TestParseTokenListArgs(t *testing.T) {
 	}
 
 	for _, tc := range tests {
-		gotID, gotAll, err := parseTokenListArgs(tc.args)
+		got, err := parseTokenListArgs(tc.args)
 		if tc.shouldFail {
 			if err == nil {
 				t.Fatalf("%s: expected error, got nil", tc.name)
@@ -278,11 +348,17 @@ func TestParseTokenListArgs(t *testing.T) {
 		if err != nil {
 			t.Fatalf("%s: unexpected error: %v", tc.name, err)
 		}
-		if gotID != tc.wantID {
-			t.Fatalf("%s: id got %q want %q", tc.name, gotID, tc.wantID)
+		if got.Identifier != tc.wantID {
+			t.Fatalf("%s: id got %q want %q", tc.name, got.Identifier, tc.wantID)
+		}
+		if got.IncludeAll != tc.wantAll {
+			t.Fatalf("%s: all got %v want %v", tc.name, got.IncludeAll, tc.wantAll)
 		}
-		if gotAll != tc.wantAll {
-			t.Fatalf("%s: all got %v want %v", tc.name, gotAll, tc.wantAll)
+		if got.JSON != tc.wantJSON {
+			t.Fatalf("%s: json got %v want %v", tc.name, got.JSON, tc.wantJSON)
+		}
+		if got.Limit != tc.wantLimit || got.Offset != tc.wantOffset {
+			t.Fatalf("%s: limit/offset got %d/%d want %d/%d", tc.name, got.Limit, got.Offset, tc.wantLimit, tc.wantOffset)
 		}
 	}
 }
@@ -337,3 +413,54 @@ func TestResolveTokenExpiresAt(t *testing.T) {
 func ptrTime(v time.Time) *time.Time {
 	return &v
 }
+
+func TestSharedTokenPrefixes(t *testing.T) {
+	shared := sharedTokenPrefixes([]models.PersonalAccessToken{
+		{ID: 1, TokenPrefix: "abcdefgh"},
+		{ID: 2, TokenPrefix: "zyxwvuts"},
+		{ID: 3, TokenPrefix: "abcdefgh"},
+	})
+	if ids := shared["abcdefgh"]; len(ids) != 2 || ids[0] != 1 || ids[1] != 3 {
+		t.Fatalf("expected shared prefix ids [1 3], got %v", ids)
+	}
+	if ids := shared["zyxwvuts"]; len(ids) != 1 {
+		t.Fatalf("expected unique prefix to map to one id, got %v", ids)
+	}
+}
+
+func TestProbeStorage(t *testing.T) {
+	baseDir := t.TempDir()
+	localStore, err := storage.NewLocalStore(baseDir)
+	if err != nil {
+		t.Fatalf("NewLocalStore() error = %v", err)
+	}
+
+	var out bytes.Buffer
+	if err := probeStorage(context.Background(), localStore, &out); err != nil {
+		t.Fatalf("probeStorage() error = %v", err)
+	}
+	for _, step := range []string{"storage_test_put=ok", "storage_test_read=ok", "storage_test_delete=ok"} {
+		if !strings.Contains(out.String(), step) {
+			t.Fatalf("expected %q in output, got %q", step, out.String())
+		}
+	}
+	if entries, err := os.ReadDir(filepath.Join(baseDir, "keer-probe")); err == nil && len(entries) > 0 {
+		t.Fatalf("expected probe object to be deleted, found %d entries", len(entries))
+	}
+
+	brokenDir := filepath.Join(t.TempDir(), "uploads")
+	brokenStore, err := storage.NewLocalStore(brokenDir)
+	if err != nil {
+		t.Fatalf("NewLocalStore() error = %v", err)
+	}
+	if err := os.RemoveAll(brokenDir); err != nil {
+		t.Fatalf("remove uploads dir failed: %v", err)
+	}
+	if err := os.WriteFile(brokenDir, []byte("not a dir"), 0o644); err != nil {
+		t.Fatalf("write placeholder failed: %v", err)
+	}
+	err = probeStorage(context.Background(), brokenStore, io.Discard)
+	if err == nil || !strings.Contains(err.Error(), "at put") {
+		t.Fatalf("expected failure at put step, got %v", err)
+	}
+}
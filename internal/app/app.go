@@ -3,6 +3,8 @@ package app
 import (
 	"context"
 	"fmt"
+	"log"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 
@@ -14,6 +16,9 @@ import (
 	"github.com/shinyes/keer/internal/store"
 )
 
+// storageCheckTimeout 限制启动时存储后端检查的耗时，端点不可达时不至于长时间卡住启动。
+const storageCheckTimeout = 10 * time.Second
+
 type Container struct {
 	Config            config.Config
 	Store             *store.SQLStore
@@ -40,24 +45,71 @@ func Build(ctx context.Context, cfg config.Config) (*Container, func() error, er
 	}
 
 	sqlStore := store.New(sqliteDB)
+	sqlStore.SetTokenPrefixLength(cfg.TokenPrefixLength)
+	if cfg.MillisecondTimestamps {
+		sqlStore.SetMillisecondTimestamps(true)
+		if err := sqlStore.NormalizeMemoTimestamps(ctx); err != nil {
+			_ = cleanup()
+			return nil, nil, fmt.Errorf("normalize memo timestamps: %w", err)
+		}
+	}
 	userService := service.NewUserService(sqlStore)
+	userService.SetPasswordResetTokenTTL(cfg.PasswordResetTokenTTL)
+	userService.SetAvatarURLTTL(cfg.S3PresignDownloadTTL)
+	userService.SetAvatarLimits(cfg.AvatarMaxSourceBytes, cfg.AvatarMaxDimension, cfg.AvatarMaxPixels)
+	userService.SetRequireDistinctDisplayName(cfg.RequireDistinctDisplayName)
+	userService.SetBcryptCost(cfg.BcryptCost)
+	userService.SetAvatarThumbnailsDisabled(cfg.DisableThumbnails)
+	if err := userService.SetUsernamePattern(cfg.UsernamePattern); err != nil {
+		_ = cleanup()
+		return nil, nil, err
+	}
 	storageService := service.NewStorageSettingsService(sqlStore)
+	storageService.SetUploadsDir(cfg.UploadsDir)
 	resolvedStorage, err := storageService.Resolve(ctx)
 	if err != nil {
 		_ = cleanup()
 		return nil, nil, fmt.Errorf("resolve storage settings: %w", err)
 	}
 	cfg.Storage = resolvedStorage.Backend
+	cfg.UploadsDir = resolvedStorage.LocalPath
 	cfg.S3 = resolvedStorage.S3
+	cfg.GCS = resolvedStorage.GCS
 	if err := userService.EnsureBootstrap(ctx, cfg.BootstrapUser, cfg.BootstrapToken); err != nil {
 		_ = cleanup()
 		return nil, nil, fmt.Errorf("bootstrap setup: %w", err)
 	}
 
 	memoService := service.NewMemoService(sqlStore)
+	if err := memoService.SetSearchTokenizer(cfg.SearchTokenizer); err != nil {
+		_ = cleanup()
+		return nil, nil, err
+	}
+	if err := memoService.SetSearchScope(cfg.SearchScope); err != nil {
+		_ = cleanup()
+		return nil, nil, err
+	}
+	memoService.SetMaxAttachmentsPerMemo(cfg.MaxAttachmentsPerMemo)
+	memoService.SetRestrictTagsToExisting(cfg.RestrictTagsToExisting)
+	memoService.SetRejectEmptyMemos(cfg.RejectEmptyMemos)
+	memoService.SetContentFilterFTS(cfg.ContentFilterFTS)
+	memoService.SetRestrictCollaboratorAttachments(cfg.RestrictCollabAttachments)
+	if err := memoService.SetDefaultMemoStates(cfg.DefaultMemoStates); err != nil {
+		_ = cleanup()
+		return nil, nil, err
+	}
+	rebuilt, err := memoService.SyncSearchTokenizer(ctx)
+	if err != nil {
+		_ = cleanup()
+		return nil, nil, fmt.Errorf("sync search tokenizer: %w", err)
+	}
+	if rebuilt {
+		log.Printf("search index rebuilt with tokenizer=%s", cfg.SearchTokenizer)
+	}
 	groupService := service.NewGroupService(sqlStore)
 
 	var fileStorage storage.Store
+	var shardingStore *storage.LocalStore
 	switch cfg.Storage {
 	case config.StorageBackendLocal:
 		localStore, err := storage.NewLocalStore(cfg.UploadsDir)
@@ -65,6 +117,10 @@ func Build(ctx context.Context, cfg config.Config) (*Container, func() error, er
 			_ = cleanup()
 			return nil, nil, err
 		}
+		localStore.SetSharding(cfg.LocalStorageSharding)
+		if cfg.LocalStorageSharding {
+			shardingStore = localStore
+		}
 		fileStorage = localStore
 	case config.StorageBackendS3:
 		s3Store, err := storage.NewS3Store(ctx, cfg.S3)
@@ -72,16 +128,91 @@ func Build(ctx context.Context, cfg config.Config) (*Container, func() error, er
 			_ = cleanup()
 			return nil, nil, err
 		}
+		s3Store.SetRetryPolicy(cfg.S3RetryMaxAttempts, cfg.S3RetryMaxElapsed)
 		fileStorage = s3Store
+	case config.StorageBackendGCS:
+		gcsStore, err := storage.NewGCSStore(ctx, cfg.GCS)
+		if err != nil {
+			_ = cleanup()
+			return nil, nil, err
+		}
+		fileStorage = gcsStore
 	default:
 		_ = cleanup()
 		return nil, nil, fmt.Errorf("unsupported storage backend %s", cfg.Storage)
 	}
 
-	attachmentService := service.NewAttachmentService(sqlStore, fileStorage)
+	checkCtx, cancelCheck := context.WithTimeout(ctx, storageCheckTimeout)
+	if err := fileStorage.Check(checkCtx); err != nil {
+		log.Printf("warning: storage backend %s is not reachable, uploads and downloads will fail until it is fixed: %v", cfg.Storage, err)
+	}
+	cancelCheck()
+
+	attachmentService := service.NewAttachmentService(sqlStore, fileStorage, cfg.UploadSessionTTL)
+	attachmentService.SetSizeLimitsByType(cfg.AttachmentSizeLimitsByType)
+	attachmentService.SetInlineUploadLimit(cfg.InlineAttachmentMaxSize)
+	attachmentService.SetMaxAttachmentSize(cfg.MaxAttachmentSizeBytes)
+	attachmentService.SetUserStorageQuota(cfg.UserStorageQuota)
+	attachmentService.SetAttachmentTypeRules(cfg.AttachmentAllowedTypes, cfg.AttachmentBlockedTypes)
+	attachmentService.SetThumbnailsDisabled(cfg.DisableThumbnails)
+	attachmentService.SetThumbnailProgressive(cfg.ThumbnailProgressive)
+	attachmentService.SetFFmpegPath(cfg.FFmpegPath)
+	attachmentService.SetPresignTTLs(cfg.S3PresignUploadTTL, cfg.S3PresignMultipartUploadTTL, cfg.S3PresignDownloadTTL)
 	userService.SetAvatarStorage(fileStorage)
-	_ = attachmentService.CleanupExpiredUploadSessions(ctx)
-	router := httpserver.NewRouter(cfg, userService, memoService, groupService, attachmentService)
+	userService.SetStoreResolver(storageService.OpenStoreForType)
+	_, _ = attachmentService.CleanupExpiredUploadSessions(ctx)
+	router := httpserver.NewRouter(cfg, userService, service.NewUserSettingsService(sqlStore), memoService, groupService, attachmentService)
+
+	if cfg.ArchivedRetentionDays > 0 {
+		sweepCtx, stopSweep := context.WithCancel(context.Background())
+		sweepDone := make(chan struct{})
+		go func() {
+			defer close(sweepDone)
+			runArchivedMemoSweeper(sweepCtx, memoService, time.Duration(cfg.ArchivedRetentionDays)*24*time.Hour, cfg.ArchivedSweepInterval)
+		}()
+		closeDB := cleanup
+		cleanup = func() error {
+			stopSweep()
+			<-sweepDone
+			return closeDB()
+		}
+	}
+
+	if cfg.UploadSessionCleanupInterval > 0 {
+		uploadSweepCtx, stopUploadSweep := context.WithCancel(context.Background())
+		uploadSweepDone := make(chan struct{})
+		go func() {
+			defer close(uploadSweepDone)
+			runUploadSessionSweeper(uploadSweepCtx, attachmentService, cfg.UploadSessionCleanupInterval)
+		}()
+		closeRest := cleanup
+		cleanup = func() error {
+			stopUploadSweep()
+			<-uploadSweepDone
+			return closeRest()
+		}
+	}
+
+	if shardingStore != nil {
+		migrateCtx, stopMigrate := context.WithCancel(context.Background())
+		migrateDone := make(chan struct{})
+		go func() {
+			defer close(migrateDone)
+			moved, err := shardingStore.MigrateToShardedLayout(migrateCtx)
+			if err != nil && migrateCtx.Err() == nil {
+				log.Printf("migrate local storage to sharded layout failed: %v", err)
+			}
+			if moved > 0 {
+				log.Printf("moved %d local storage files into shard directories", moved)
+			}
+		}()
+		closeRest := cleanup
+		cleanup = func() error {
+			stopMigrate()
+			<-migrateDone
+			return closeRest()
+		}
+	}
 
 	return &Container{
 		Config:            cfg,
@@ -94,3 +225,42 @@ func Build(ctx context.Context, cfg config.Config) (*Container, func() error, er
 		Router:            router,
 	}, cleanup, nil
 }
+
+// runArchivedMemoSweeper 启动时立即清理一次过期归档备忘录，之后按 interval 周期执行，直到 ctx 取消。
+func runArchivedMemoSweeper(ctx context.Context, memoService *service.MemoService, retention time.Duration, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		deleted, err := memoService.PurgeArchivedMemos(ctx, retention)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("purge archived memos failed: %v", err)
+		} else if deleted > 0 {
+			log.Printf("purged %d archived memos older than %s", deleted, retention)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// runUploadSessionSweeper 按 interval 周期清理过期的上传会话（启动时已同步清理过一次），直到 ctx 取消。
+func runUploadSessionSweeper(ctx context.Context, attachmentService *service.AttachmentService, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		removed, err := attachmentService.CleanupExpiredUploadSessions(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("cleanup expired upload sessions failed: %v", err)
+		}
+		if removed > 0 {
+			log.Printf("reclaimed %d expired upload sessions", removed)
+		}
+	}
+}
@@ -2,9 +2,18 @@ package config
 
 import (
 	"fmt"
+	"math"
+	"net"
 	"os"
+	"os/exec"
+	"path"
+	"regexp"
 	"strconv"
 	"strings"
+	"time"
+
+	"github.com/shinyes/keer/internal/models"
+	"golang.org/x/crypto/bcrypt"
 )
 
 type StorageBackend string
@@ -12,8 +21,23 @@ type StorageBackend string
 const (
 	StorageBackendLocal StorageBackend = "local"
 	StorageBackendS3    StorageBackend = "s3"
+	StorageBackendGCS   StorageBackend = "gcs"
 )
 
+// maxS3PresignTTL 为 S3 SigV4 预签名地址允许的最长有效期。
+const maxS3PresignTTL = 7 * 24 * time.Hour
+
+// GCSDefaultEndpoint 为 Google Cloud Storage 的 JSON API 端点。
+const GCSDefaultEndpoint = "https://storage.googleapis.com"
+
+// GCSConfig 为 Google Cloud Storage 配置，凭证是服务账号的 JSON 密钥文件。
+type GCSConfig struct {
+	Bucket          string
+	CredentialsFile string
+	// Endpoint 为空时使用 GCSDefaultEndpoint，仅供测试替换为本地模拟服务。
+	Endpoint string
+}
+
 type S3Config struct {
 	Endpoint     string
 	Region       string
@@ -24,31 +48,290 @@ type S3Config struct {
 }
 
 type Config struct {
-	Addr              string
-	BaseURL           string
-	DBPath            string
-	UploadsDir        string
-	BodyLimitMB       int
-	KeerAPIVersion    string
-	Storage           StorageBackend
-	S3                S3Config
-	AllowRegistration bool
-	BootstrapUser     string
-	BootstrapToken    string
+	Addr                       string
+	BaseURL                    string
+	DBPath                     string
+	UploadsDir                 string
+	BodyLimitMB                int
+	KeerAPIVersion             string
+	Storage                    StorageBackend
+	S3                         S3Config
+	GCS                        GCSConfig
+	AllowRegistration          bool
+	BootstrapUser              string
+	BootstrapToken             string
+	ReadTimeout                time.Duration
+	WriteTimeout               time.Duration
+	IdleTimeout                time.Duration
+	PasswordResetTokenTTL      time.Duration
+	UsernamePattern            string
+	RequireDistinctDisplayName bool
+	SearchTokenizer            string
+	SearchScope                string
+	ArchivedRetentionDays      int
+	ArchivedSweepInterval      time.Duration
+	MillisecondTimestamps      bool
+	// BcryptCost 为密码哈希的 bcrypt cost，0 表示使用 bcrypt.DefaultCost。
+	// 每加 1 哈希耗时翻倍：低配设备可调低以加快登录与建号，高配设备可调高以增加暴力破解成本。
+	BcryptCost       int
+	SignInRateLimit  int
+	SignInRateWindow time.Duration
+	// TrustedProxies 为可信反向代理的 IP 或 CIDR；只有来自这些地址的请求才按 ProxyHeader 取客户端 IP，
+	// 为空时一律使用连接的对端地址，限流等按 IP 区分的逻辑不会被伪造的请求头绕过。
+	TrustedProxies []string
+	ProxyHeader    string
+	// AttachmentSizeLimitsByType 按类型模式（image/png、image/*、*/*）限制附件大小，最具体的模式生效。
+	AttachmentSizeLimitsByType map[string]int64
+	MaxAttachmentsPerMemo      int
+	DisableThumbnails          bool
+	// ThumbnailProgressive 让新生成的 JPEG 缩略图使用渐进式编码，默认保持基线 JPEG。
+	ThumbnailProgressive bool
+	// FFmpegPath 非空时用 ffmpeg 为上传完成的视频抽帧生成缩略图，启动时解析为绝对路径。
+	FFmpegPath string
+	// MaxHeavyOperations 限制同时进行的导出、导入等重量级操作数量，0 表示不限制。
+	MaxHeavyOperations int
+	// DefaultMemoStates 为列表请求未指定 state 时默认包含的状态，默认只有 NORMAL。
+	DefaultMemoStates []models.MemoState
+	// MaxAttachmentSizeBytes 为单个附件的全局大小上限，0 表示不限制；对所有类型和上传方式生效。
+	MaxAttachmentSizeBytes int64
+	// AttachmentAllowedTypes 非空时只接受匹配其中任一模式（image/*、application/vnd.ms-* 等 glob）的附件类型。
+	AttachmentAllowedTypes []string
+	// AttachmentBlockedTypes 中的模式优先于白名单，匹配的附件类型一律拒绝。
+	AttachmentBlockedTypes []string
+	// UserStorageQuota 为每个用户附件可占用的存储字节数，0 表示不限制。
+	UserStorageQuota int64
+	// InlineAttachmentMaxSize 为 base64 内联上传解码后的字节上限，默认取请求体上限的 3/4。
+	InlineAttachmentMaxSize int64
+	// RestrictTagsToExisting 开启后 memo 只能引用已存在的标签，新标签需通过 POST /api/v1/tags 创建。
+	RestrictTagsToExisting bool
+	// RestrictCollabAttachments 开启后协作者编辑共享 memo 时只能引用创建者的附件。
+	RestrictCollabAttachments bool
+	// RejectEmptyMemos 开启后拒绝创建正文为空白且没有附件和标签的 memo。
+	RejectEmptyMemos bool
+	// ContentFilterFTS 允许过滤条件使用 content.contains("...")，借助全文索引预过滤。
+	ContentFilterFTS bool
+	// TokenPrefixLength 为新建令牌保存的展示前缀长度。
+	TokenPrefixLength int
+	// UploadSessionTTL 为分片上传会话的保留时长，超过该时长未更新的会话会被清理。
+	UploadSessionTTL time.Duration
+	// UploadSessionCleanupInterval 为后台清理过期上传会话的周期，0 表示只在启动和创建会话时清理。
+	UploadSessionCleanupInterval time.Duration
+	// LocalStorageSharding 让本地存储按键摘要前两位分子目录存放文件，启动时在后台搬迁旧的平铺文件。
+	LocalStorageSharding bool
+	// FileCacheMaxAge 为 /file/ 下附件与缩略图响应的 Cache-Control max-age，0 表示每次都需带 ETag 重新验证。
+	FileCacheMaxAge time.Duration
+	// S3PresignUploadTTL 为 S3 直传预签名 PUT 地址的有效期。
+	S3PresignUploadTTL time.Duration
+	// S3PresignMultipartUploadTTL 为分片直传中每个分片预签名地址的有效期。
+	S3PresignMultipartUploadTTL time.Duration
+	// S3PresignDownloadTTL 为附件、缩略图与头像预签名下载地址的有效期。
+	S3PresignDownloadTTL time.Duration
+	// S3RetryMaxAttempts 为 S3 调用的最大尝试次数（含首次），1 表示不额外重试。
+	S3RetryMaxAttempts int
+	// S3RetryMaxElapsed 限制单次 S3 调用连同重试的累计耗时，0 表示只受尝试次数限制。
+	S3RetryMaxElapsed time.Duration
+	// AvatarMaxSourceBytes 为上传头像解码后的原图大小上限。
+	AvatarMaxSourceBytes int64
+	// AvatarMaxDimension 为头像原图宽、高各自的像素上限。
+	AvatarMaxDimension int
+	// AvatarMaxPixels 为头像原图总像素数（宽 × 高）上限，防止解码超大图片耗尽内存。
+	AvatarMaxPixels int64
 }
 
 func Load() (Config, error) {
 	cfg := Config{
-		Addr:              env("APP_ADDR", ":12843"),
-		BaseURL:           strings.TrimRight(env("BASE_URL", "http://localhost:12843"), "/"),
-		DBPath:            env("DB_PATH", "./data/keer.db"),
-		UploadsDir:        env("UPLOADS_DIR", "./data/uploads"),
-		BodyLimitMB:       envInt("HTTP_BODY_LIMIT_MB", 64),
-		KeerAPIVersion:    env("KEER_API_VERSION", "0.1"),
-		Storage:           StorageBackendLocal,
-		AllowRegistration: envBool("ALLOW_REGISTRATION", true),
-		BootstrapUser:     env("BOOTSTRAP_USER", "demo"),
-		BootstrapToken:    env("BOOTSTRAP_TOKEN", ""),
+		Addr:                       env("APP_ADDR", ":12843"),
+		BaseURL:                    strings.TrimRight(env("BASE_URL", "http://localhost:12843"), "/"),
+		DBPath:                     env("DB_PATH", "./data/keer.db"),
+		UploadsDir:                 env("UPLOADS_DIR", "./data/uploads"),
+		BodyLimitMB:                envInt("HTTP_BODY_LIMIT_MB", 64),
+		KeerAPIVersion:             env("KEER_API_VERSION", "0.1"),
+		Storage:                    StorageBackendLocal,
+		AllowRegistration:          envBool("ALLOW_REGISTRATION", true),
+		BootstrapUser:              env("BOOTSTRAP_USER", "demo"),
+		BootstrapToken:             env("BOOTSTRAP_TOKEN", ""),
+		UsernamePattern:            env("USERNAME_PATTERN", ""),
+		RequireDistinctDisplayName: envBool("REQUIRE_DISTINCT_DISPLAY_NAME", false),
+		SearchTokenizer:            strings.ToLower(env("SEARCH_TOKENIZER", "unicode61")),
+		SearchScope:                strings.ToLower(env("SEARCH_SCOPE", "visible")),
+		ArchivedRetentionDays:      envInt("ARCHIVED_RETENTION_DAYS", 0),
+		MillisecondTimestamps:      envBool("MILLISECOND_TIMESTAMPS", false),
+		BcryptCost:                 envInt("BCRYPT_COST", 0),
+		SignInRateLimit:            envInt("SIGNIN_RATE_LIMIT", 10),
+		ProxyHeader:                env("PROXY_HEADER", "X-Forwarded-For"),
+		MaxAttachmentsPerMemo:      envInt("MAX_ATTACHMENTS_PER_MEMO", 100),
+		DisableThumbnails:          envBool("DISABLE_THUMBNAILS", false),
+		ThumbnailProgressive:       envBool("THUMBNAIL_PROGRESSIVE_JPEG", false),
+		FFmpegPath:                 env("FFMPEG_PATH", ""),
+		LocalStorageSharding:       envBool("LOCAL_STORAGE_SHARDING", false),
+		MaxHeavyOperations:         envInt("MAX_CONCURRENT_HEAVY_OPERATIONS", 2),
+		TokenPrefixLength:          envInt("TOKEN_PREFIX_LENGTH", 8),
+		RestrictTagsToExisting:     envBool("RESTRICT_TAGS_TO_EXISTING", false),
+		ContentFilterFTS:           envBool("FILTER_CONTENT_FTS", false),
+		RejectEmptyMemos:           envBool("REJECT_EMPTY_MEMOS", false),
+		RestrictCollabAttachments:  envBool("RESTRICT_COLLABORATOR_ATTACHMENTS", false),
+		S3RetryMaxAttempts:         envInt("S3_RETRY_MAX_ATTEMPTS", 1),
+	}
+	var err error
+	if cfg.ReadTimeout, err = envDuration("HTTP_READ_TIMEOUT", 5*time.Minute); err != nil {
+		return Config{}, err
+	}
+	if cfg.WriteTimeout, err = envDuration("HTTP_WRITE_TIMEOUT", 0); err != nil {
+		return Config{}, err
+	}
+	if cfg.IdleTimeout, err = envDuration("HTTP_IDLE_TIMEOUT", 2*time.Minute); err != nil {
+		return Config{}, err
+	}
+	if cfg.PasswordResetTokenTTL, err = envDuration("PASSWORD_RESET_TOKEN_TTL", time.Hour); err != nil {
+		return Config{}, err
+	}
+	if cfg.ArchivedSweepInterval, err = envDuration("ARCHIVED_SWEEP_INTERVAL", time.Hour); err != nil {
+		return Config{}, err
+	}
+	if cfg.SignInRateWindow, err = envDuration("SIGNIN_RATE_WINDOW", time.Minute); err != nil {
+		return Config{}, err
+	}
+	if cfg.S3RetryMaxElapsed, err = envDuration("S3_RETRY_MAX_ELAPSED", 30*time.Second); err != nil {
+		return Config{}, err
+	}
+	if cfg.S3PresignUploadTTL, err = envDuration("S3_PRESIGN_UPLOAD_TTL", 15*time.Minute); err != nil {
+		return Config{}, err
+	}
+	if cfg.S3PresignMultipartUploadTTL, err = envDuration("S3_PRESIGN_MULTIPART_UPLOAD_TTL", 15*time.Minute); err != nil {
+		return Config{}, err
+	}
+	if cfg.S3PresignDownloadTTL, err = envDuration("S3_PRESIGN_DOWNLOAD_TTL", 10*time.Minute); err != nil {
+		return Config{}, err
+	}
+	if cfg.UploadSessionTTL, err = envDuration("UPLOAD_SESSION_TTL", 24*time.Hour); err != nil {
+		return Config{}, err
+	}
+	if cfg.UploadSessionCleanupInterval, err = envDuration("UPLOAD_SESSION_CLEANUP_INTERVAL", time.Hour); err != nil {
+		return Config{}, err
+	}
+	if cfg.FileCacheMaxAge, err = envDuration("FILE_CACHE_MAX_AGE", time.Hour); err != nil {
+		return Config{}, err
+	}
+	if cfg.AttachmentSizeLimitsByType, err = parseAttachmentSizeLimits(env("ATTACHMENT_SIZE_LIMITS", "")); err != nil {
+		return Config{}, err
+	}
+	if cfg.AttachmentAllowedTypes, err = parseMediaTypeGlobs("ATTACHMENT_ALLOWED_TYPES", env("ATTACHMENT_ALLOWED_TYPES", "")); err != nil {
+		return Config{}, err
+	}
+	if cfg.AttachmentBlockedTypes, err = parseMediaTypeGlobs("ATTACHMENT_BLOCKED_TYPES", env("ATTACHMENT_BLOCKED_TYPES", "")); err != nil {
+		return Config{}, err
+	}
+	if raw := env("MAX_ATTACHMENT_SIZE", ""); raw != "" {
+		if cfg.MaxAttachmentSizeBytes, err = parseByteSize(raw); err != nil {
+			return Config{}, fmt.Errorf("invalid MAX_ATTACHMENT_SIZE: %w", err)
+		}
+	}
+	if raw := env("USER_STORAGE_QUOTA", ""); raw != "" {
+		if cfg.UserStorageQuota, err = parseByteSize(raw); err != nil {
+			return Config{}, fmt.Errorf("invalid USER_STORAGE_QUOTA: %w", err)
+		}
+	}
+	cfg.AvatarMaxSourceBytes = 10 << 20
+	if raw := env("AVATAR_MAX_SIZE", ""); raw != "" {
+		if cfg.AvatarMaxSourceBytes, err = parseByteSize(raw); err != nil {
+			return Config{}, fmt.Errorf("invalid AVATAR_MAX_SIZE: %w", err)
+		}
+		if cfg.AvatarMaxSourceBytes <= 0 {
+			return Config{}, fmt.Errorf("invalid AVATAR_MAX_SIZE %q: must be greater than zero", raw)
+		}
+	}
+	avatarMaxDimension, err := envPositiveInt64("AVATAR_MAX_DIMENSION", 4096)
+	if err != nil {
+		return Config{}, err
+	}
+	if avatarMaxDimension > math.MaxInt32 {
+		return Config{}, fmt.Errorf("invalid AVATAR_MAX_DIMENSION %d: too large", avatarMaxDimension)
+	}
+	cfg.AvatarMaxDimension = int(avatarMaxDimension)
+	if cfg.AvatarMaxPixels, err = envPositiveInt64("AVATAR_MAX_PIXELS", 12_000_000); err != nil {
+		return Config{}, err
+	}
+	if cfg.TrustedProxies, err = parseTrustedProxies(env("TRUSTED_PROXIES", "")); err != nil {
+		return Config{}, err
+	}
+	if cfg.DefaultMemoStates, err = parseMemoStates(env("DEFAULT_MEMO_STATES", string(models.MemoStateNormal))); err != nil {
+		return Config{}, err
+	}
+	// base64 比原始数据大约 4/3，请求体上限决定了内联上传实际能达到的最大文件
+	bodyLimitMB := cfg.BodyLimitMB
+	if bodyLimitMB <= 0 {
+		bodyLimitMB = 64
+	}
+	maxInlineSize := int64(bodyLimitMB) << 20 / 4 * 3
+	cfg.InlineAttachmentMaxSize = maxInlineSize
+	if raw := env("INLINE_ATTACHMENT_MAX_SIZE", ""); raw != "" {
+		if cfg.InlineAttachmentMaxSize, err = parseByteSize(raw); err != nil {
+			return Config{}, fmt.Errorf("invalid INLINE_ATTACHMENT_MAX_SIZE: %w", err)
+		}
+		if cfg.InlineAttachmentMaxSize > maxInlineSize {
+			return Config{}, fmt.Errorf("invalid INLINE_ATTACHMENT_MAX_SIZE %q: exceeds %d bytes allowed by HTTP_BODY_LIMIT_MB", raw, maxInlineSize)
+		}
+	}
+	if cfg.TokenPrefixLength < 4 || cfg.TokenPrefixLength > 32 {
+		return Config{}, fmt.Errorf("invalid TOKEN_PREFIX_LENGTH %d, expected 4-32", cfg.TokenPrefixLength)
+	}
+	if cfg.MaxHeavyOperations < 0 {
+		return Config{}, fmt.Errorf("invalid MAX_CONCURRENT_HEAVY_OPERATIONS %d: must not be negative", cfg.MaxHeavyOperations)
+	}
+	if cfg.MaxAttachmentsPerMemo < 0 {
+		return Config{}, fmt.Errorf("invalid MAX_ATTACHMENTS_PER_MEMO %d: must not be negative", cfg.MaxAttachmentsPerMemo)
+	}
+	if cfg.SignInRateLimit < 0 {
+		return Config{}, fmt.Errorf("invalid SIGNIN_RATE_LIMIT %d: must not be negative", cfg.SignInRateLimit)
+	}
+	if cfg.SignInRateLimit > 0 && cfg.SignInRateWindow == 0 {
+		return Config{}, fmt.Errorf("invalid SIGNIN_RATE_WINDOW: must be greater than zero when SIGNIN_RATE_LIMIT is set")
+	}
+	if cfg.ArchivedRetentionDays > 0 && cfg.ArchivedSweepInterval == 0 {
+		return Config{}, fmt.Errorf("invalid ARCHIVED_SWEEP_INTERVAL: must be greater than zero when ARCHIVED_RETENTION_DAYS is set")
+	}
+	if cfg.BcryptCost != 0 && (cfg.BcryptCost < bcrypt.MinCost || cfg.BcryptCost > bcrypt.MaxCost) {
+		return Config{}, fmt.Errorf("invalid BCRYPT_COST %d, expected %d-%d", cfg.BcryptCost, bcrypt.MinCost, bcrypt.MaxCost)
+	}
+	if cfg.PasswordResetTokenTTL == 0 {
+		return Config{}, fmt.Errorf("invalid PASSWORD_RESET_TOKEN_TTL: must be greater than zero")
+	}
+	if cfg.UploadSessionTTL == 0 {
+		return Config{}, fmt.Errorf("invalid UPLOAD_SESSION_TTL: must be greater than zero")
+	}
+	for _, presign := range []struct {
+		key string
+		ttl time.Duration
+	}{
+		{"S3_PRESIGN_UPLOAD_TTL", cfg.S3PresignUploadTTL},
+		{"S3_PRESIGN_MULTIPART_UPLOAD_TTL", cfg.S3PresignMultipartUploadTTL},
+		{"S3_PRESIGN_DOWNLOAD_TTL", cfg.S3PresignDownloadTTL},
+	} {
+		if presign.ttl == 0 || presign.ttl > maxS3PresignTTL {
+			return Config{}, fmt.Errorf("invalid %s %s: must be greater than zero and at most %s", presign.key, presign.ttl, maxS3PresignTTL)
+		}
+	}
+	if cfg.UsernamePattern != "" {
+		if _, err := regexp.Compile(cfg.UsernamePattern); err != nil {
+			return Config{}, fmt.Errorf("invalid USERNAME_PATTERN %q: %w", cfg.UsernamePattern, err)
+		}
+	}
+	switch cfg.SearchTokenizer {
+	case "unicode61", "porter", "trigram":
+	default:
+		return Config{}, fmt.Errorf("invalid SEARCH_TOKENIZER %q, expected unicode61|porter|trigram", cfg.SearchTokenizer)
+	}
+	switch cfg.SearchScope {
+	case "visible", "own":
+	default:
+		return Config{}, fmt.Errorf("invalid SEARCH_SCOPE %q, expected visible|own", cfg.SearchScope)
+	}
+	if cfg.FFmpegPath != "" {
+		resolved, err := exec.LookPath(cfg.FFmpegPath)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid FFMPEG_PATH %q: %w", cfg.FFmpegPath, err)
+		}
+		cfg.FFmpegPath = resolved
 	}
 	return cfg, nil
 }
@@ -72,6 +355,139 @@ func (c S3Config) Validate() error {
 	return nil
 }
 
+func (c GCSConfig) Validate() error {
+	if c.Bucket == "" {
+		return fmt.Errorf("gcs bucket is required when storage backend is gcs")
+	}
+	if c.CredentialsFile == "" {
+		return fmt.Errorf("gcs credentials file is required when storage backend is gcs")
+	}
+	return nil
+}
+
+// parseAttachmentSizeLimits 解析 "image/*=10MB,video/*=500MB" 形式的配置，大小支持 KB/MB/GB 后缀（按 1024 进位）。
+func parseAttachmentSizeLimits(raw string) (map[string]int64, error) {
+	limits := map[string]int64{}
+	for _, entry := range strings.Split(raw, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		pattern, rawSize, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid ATTACHMENT_SIZE_LIMITS entry %q, expected type=size", entry)
+		}
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		if !isValidMediaTypePattern(pattern) {
+			return nil, fmt.Errorf("invalid ATTACHMENT_SIZE_LIMITS type pattern %q", pattern)
+		}
+		size, err := parseByteSize(rawSize)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ATTACHMENT_SIZE_LIMITS size for %q: %w", pattern, err)
+		}
+		limits[pattern] = size
+	}
+	if len(limits) == 0 {
+		return nil, nil
+	}
+	return limits, nil
+}
+
+// parseMediaTypeGlobs 解析逗号分隔的类型模式列表，模式按 path.Match 的 glob 语法匹配，如 image/*、application/x-*。
+func parseMediaTypeGlobs(key string, raw string) ([]string, error) {
+	var patterns []string
+	for _, entry := range strings.Split(raw, ",") {
+		pattern := strings.ToLower(strings.TrimSpace(entry))
+		if pattern == "" {
+			continue
+		}
+		if _, err := path.Match(pattern, ""); err != nil || !strings.Contains(pattern, "/") {
+			return nil, fmt.Errorf("invalid %s pattern %q, expected type/subtype glob such as image/*", key, entry)
+		}
+		patterns = append(patterns, pattern)
+	}
+	return patterns, nil
+}
+
+// parseTrustedProxies 解析逗号分隔的可信代理列表，每项为 IP 或 CIDR。
+func parseTrustedProxies(raw string) ([]string, error) {
+	var proxies []string
+	for _, entry := range strings.Split(raw, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if net.ParseIP(entry) == nil {
+			if _, _, err := net.ParseCIDR(entry); err != nil {
+				return nil, fmt.Errorf("invalid TRUSTED_PROXIES entry %q, expected IP or CIDR", entry)
+			}
+		}
+		proxies = append(proxies, entry)
+	}
+	return proxies, nil
+}
+
+// parseMemoStates 解析 "NORMAL,ARCHIVED" 形式的状态列表，忽略大小写并去重。
+func parseMemoStates(raw string) ([]models.MemoState, error) {
+	states := make([]models.MemoState, 0, 2)
+	seen := map[models.MemoState]struct{}{}
+	for _, entry := range strings.Split(raw, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		state := models.MemoState(strings.ToUpper(entry))
+		if !state.IsValid() {
+			return nil, fmt.Errorf("invalid DEFAULT_MEMO_STATES entry %q, expected NORMAL|ARCHIVED", entry)
+		}
+		if _, ok := seen[state]; ok {
+			continue
+		}
+		seen[state] = struct{}{}
+		states = append(states, state)
+	}
+	if len(states) == 0 {
+		return nil, fmt.Errorf("invalid DEFAULT_MEMO_STATES: at least one state is required")
+	}
+	return states, nil
+}
+
+func isValidMediaTypePattern(pattern string) bool {
+	if pattern == "*/*" {
+		return true
+	}
+	major, minor, ok := strings.Cut(pattern, "/")
+	if !ok || major == "" || minor == "" || strings.ContainsAny(major, "*/") || strings.Contains(minor, "/") {
+		return false
+	}
+	return minor == "*" || !strings.Contains(minor, "*")
+}
+
+func parseByteSize(raw string) (int64, error) {
+	value := strings.ToUpper(strings.TrimSpace(raw))
+	multiplier := int64(1)
+	for _, unit := range []struct {
+		suffix     string
+		multiplier int64
+	}{
+		{suffix: "GB", multiplier: 1 << 30},
+		{suffix: "MB", multiplier: 1 << 20},
+		{suffix: "KB", multiplier: 1 << 10},
+		{suffix: "B", multiplier: 1},
+	} {
+		if strings.HasSuffix(value, unit.suffix) {
+			value = strings.TrimSpace(strings.TrimSuffix(value, unit.suffix))
+			multiplier = unit.multiplier
+			break
+		}
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("%q is not a positive size", raw)
+	}
+	return n * multiplier, nil
+}
+
 func env(key, fallback string) string {
 	v := strings.TrimSpace(os.Getenv(key))
 	if v == "" {
@@ -103,3 +519,34 @@ func envInt(key string, fallback int) int {
 	}
 	return parsed
 }
+
+// envPositiveInt64 与 envInt 不同，设置了非法或非正数的值时报错而不是静默回退默认值。
+func envPositiveInt64(key string, fallback int64) (int64, error) {
+	v := strings.TrimSpace(os.Getenv(key))
+	if v == "" {
+		return fallback, nil
+	}
+	parsed, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q: %w", key, v, err)
+	}
+	if parsed <= 0 {
+		return 0, fmt.Errorf("invalid %s %q: must be greater than zero", key, v)
+	}
+	return parsed, nil
+}
+
+func envDuration(key string, fallback time.Duration) (time.Duration, error) {
+	v := strings.TrimSpace(os.Getenv(key))
+	if v == "" {
+		return fallback, nil
+	}
+	parsed, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q: %w", key, v, err)
+	}
+	if parsed < 0 {
+		return 0, fmt.Errorf("invalid %s %q: must not be negative", key, v)
+	}
+	return parsed, nil
+}
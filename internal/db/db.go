@@ -13,7 +13,9 @@ func OpenSQLite(path string) (*sql.DB, error) {
 	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
 		return nil, fmt.Errorf("create db dir: %w", err)
 	}
-	db, err := sql.Open("sqlite", path)
+	// foreign_keys 与 busy_timeout 是连接级设置，通过 DSN 传入才能保证连接池重建的每个连接都生效；
+	// 否则 ON DELETE CASCADE 在新连接上会静默失效。
+	db, err := sql.Open("sqlite", path+"?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)")
 	if err != nil {
 		return nil, fmt.Errorf("open sqlite: %w", err)
 	}
@@ -26,13 +28,14 @@ func OpenSQLite(path string) (*sql.DB, error) {
 		_ = db.Close()
 		return nil, fmt.Errorf("set journal mode WAL: %w", err)
 	}
-	if _, err := db.Exec(`PRAGMA busy_timeout = 5000;`); err != nil {
+	var foreignKeys int
+	if err := db.QueryRow(`PRAGMA foreign_keys;`).Scan(&foreignKeys); err != nil {
 		_ = db.Close()
-		return nil, fmt.Errorf("set busy timeout: %w", err)
+		return nil, fmt.Errorf("check foreign keys: %w", err)
 	}
-	if _, err := db.Exec(`PRAGMA foreign_keys = ON;`); err != nil {
+	if foreignKeys != 1 {
 		_ = db.Close()
-		return nil, fmt.Errorf("enable foreign keys: %w", err)
+		return nil, fmt.Errorf("enable foreign keys: pragma not applied")
 	}
 	return db, nil
 }
@@ -1,8 +1,10 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"strings"
 )
 
 func Migrate(db *sql.DB) error {
@@ -77,7 +79,7 @@ func Migrate(db *sql.DB) error {
 			creator_id INTEGER NOT NULL,
 			event_type TEXT NOT NULL,
 			event_time TEXT NOT NULL,
-			FOREIGN KEY(creator_id) REFERENCES users(id) ON DELETE CASCADE
+			broadcast INTEGER NOT NULL DEFAULT 0
 		);`,
 		`CREATE INDEX IF NOT EXISTS idx_memo_change_events_event_time ON memo_change_events(event_time ASC, id ASC);`,
 		`CREATE INDEX IF NOT EXISTS idx_memo_change_events_memo_id ON memo_change_events(memo_id, event_time DESC);`,
@@ -161,6 +163,23 @@ func Migrate(db *sql.DB) error {
 		);`,
 		`CREATE INDEX IF NOT EXISTS idx_memo_tags_memo ON memo_tags(memo_id);`,
 		`CREATE INDEX IF NOT EXISTS idx_memo_tags_tag ON memo_tags(tag_id);`,
+		`CREATE TABLE IF NOT EXISTS memo_tag_pins (
+			memo_id INTEGER NOT NULL,
+			tag TEXT NOT NULL,
+			create_time TEXT NOT NULL,
+			PRIMARY KEY(memo_id, tag),
+			FOREIGN KEY(memo_id) REFERENCES memos(id) ON DELETE CASCADE
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_memo_tag_pins_tag ON memo_tag_pins(tag, memo_id);`,
+		`CREATE TABLE IF NOT EXISTS memo_group_shares (
+			memo_id INTEGER NOT NULL,
+			group_id INTEGER NOT NULL,
+			user_id INTEGER NOT NULL,
+			PRIMARY KEY(memo_id, group_id, user_id),
+			FOREIGN KEY(memo_id) REFERENCES memos(id) ON DELETE CASCADE,
+			FOREIGN KEY(group_id) REFERENCES groups(id) ON DELETE CASCADE,
+			FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+		);`,
 		`CREATE TABLE IF NOT EXISTS attachments (
 			id INTEGER PRIMARY KEY AUTOINCREMENT,
 			creator_id INTEGER NOT NULL,
@@ -201,6 +220,7 @@ func Migrate(db *sql.DB) error {
 			thumbnail_filename TEXT NOT NULL DEFAULT '',
 			thumbnail_type TEXT NOT NULL DEFAULT '',
 			thumbnail_temp_path TEXT NOT NULL DEFAULT '',
+			expected_sha256 TEXT NOT NULL DEFAULT '',
 			received_size INTEGER NOT NULL DEFAULT 0,
 			create_time TEXT NOT NULL,
 			update_time TEXT NOT NULL,
@@ -208,11 +228,37 @@ func Migrate(db *sql.DB) error {
 		);`,
 		`CREATE INDEX IF NOT EXISTS idx_attachment_upload_sessions_creator ON attachment_upload_sessions(creator_id);`,
 		`CREATE INDEX IF NOT EXISTS idx_attachment_upload_sessions_update_time ON attachment_upload_sessions(update_time);`,
+		`CREATE TABLE IF NOT EXISTS password_reset_tokens (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			token_hash TEXT NOT NULL UNIQUE,
+			created_at TEXT NOT NULL,
+			expires_at TEXT NOT NULL,
+			FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_password_reset_tokens_expires_at ON password_reset_tokens(expires_at);`,
+		`CREATE TABLE IF NOT EXISTS invite_tokens (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			token_hash TEXT NOT NULL UNIQUE,
+			created_at TEXT NOT NULL,
+			expires_at TEXT NOT NULL,
+			consumed_at TEXT,
+			consumed_by INTEGER,
+			FOREIGN KEY(consumed_by) REFERENCES users(id) ON DELETE SET NULL
+		);`,
 		`CREATE TABLE IF NOT EXISTS system_settings (
 			key TEXT PRIMARY KEY,
 			value TEXT NOT NULL,
 			update_time TEXT NOT NULL
 		);`,
+		`CREATE TABLE IF NOT EXISTS user_settings (
+			user_id INTEGER NOT NULL,
+			key TEXT NOT NULL,
+			value TEXT NOT NULL,
+			update_time TEXT NOT NULL,
+			PRIMARY KEY(user_id, key),
+			FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+		);`,
 	}
 
 	for _, stmt := range stmts {
@@ -229,6 +275,46 @@ func Migrate(db *sql.DB) error {
 	); err != nil {
 		return fmt.Errorf("migration failed: %w", err)
 	}
+	if err := ensureColumn(
+		db,
+		"users",
+		"default_page_size",
+		"INTEGER NOT NULL DEFAULT 0",
+	); err != nil {
+		return fmt.Errorf("migration failed: %w", err)
+	}
+	if err := ensureColumn(
+		db,
+		"tags",
+		"explicit",
+		"INTEGER NOT NULL DEFAULT 0",
+	); err != nil {
+		return fmt.Errorf("migration failed: %w", err)
+	}
+	if err := ensureColumn(
+		db,
+		"personal_access_tokens",
+		"scopes",
+		"TEXT NOT NULL DEFAULT ''",
+	); err != nil {
+		return fmt.Errorf("migration failed: %w", err)
+	}
+	if err := ensureColumn(
+		db,
+		"personal_access_tokens",
+		"last_used_ip",
+		"TEXT NOT NULL DEFAULT ''",
+	); err != nil {
+		return fmt.Errorf("migration failed: %w", err)
+	}
+	if err := ensureColumn(
+		db,
+		"personal_access_tokens",
+		"last_used_user_agent",
+		"TEXT NOT NULL DEFAULT ''",
+	); err != nil {
+		return fmt.Errorf("migration failed: %w", err)
+	}
 	if err := ensureColumn(
 		db,
 		"attachment_upload_sessions",
@@ -253,6 +339,14 @@ func Migrate(db *sql.DB) error {
 	); err != nil {
 		return fmt.Errorf("migration failed: %w", err)
 	}
+	if err := ensureColumn(
+		db,
+		"attachment_upload_sessions",
+		"expected_sha256",
+		"TEXT NOT NULL DEFAULT ''",
+	); err != nil {
+		return fmt.Errorf("migration failed: %w", err)
+	}
 	if err := ensureColumn(
 		db,
 		"memos",
@@ -301,6 +395,17 @@ func Migrate(db *sql.DB) error {
 	); err != nil {
 		return fmt.Errorf("migration failed: %w", err)
 	}
+	if err := ensureColumn(
+		db,
+		"memo_change_events",
+		"broadcast",
+		"INTEGER NOT NULL DEFAULT 0",
+	); err != nil {
+		return fmt.Errorf("migration failed: %w", err)
+	}
+	if err := dropMemoChangeEventsCreatorForeignKey(db); err != nil {
+		return fmt.Errorf("migration failed: %w", err)
+	}
 	if _, err := db.Exec(`CREATE INDEX IF NOT EXISTS idx_memos_has_task_list ON memos(has_task_list)`); err != nil {
 		return fmt.Errorf("migration failed: %w", err)
 	}
@@ -338,10 +443,80 @@ func Migrate(db *sql.DB) error {
 			return fmt.Errorf("migration failed: %w", err)
 		}
 	}
+	if err := ensureMemoSearchIndex(db); err != nil {
+		return fmt.Errorf("migration failed: %w", err)
+	}
+
+	return nil
+}
+
+const DefaultMemoSearchTokenizer = "unicode61"
+
+func IsValidMemoSearchTokenizer(tokenizer string) bool {
+	switch tokenizer {
+	case "unicode61", "porter", "trigram":
+		return true
+	default:
+		return false
+	}
+}
+
+func MemoSearchIndexSchema(tokenizer string) (string, error) {
+	if !IsValidMemoSearchTokenizer(tokenizer) {
+		return "", fmt.Errorf("unsupported search tokenizer %q", tokenizer)
+	}
+	return fmt.Sprintf(`CREATE VIRTUAL TABLE IF NOT EXISTS memos_fts USING fts5(content, tokenize='%s')`, tokenizer), nil
+}
+
+func ensureMemoSearchIndex(db *sql.DB) error {
+	exists, err := hasTable(db, "memos_fts")
+	if err != nil {
+		return err
+	}
+	if !exists {
+		schema, err := MemoSearchIndexSchema(DefaultMemoSearchTokenizer)
+		if err != nil {
+			return err
+		}
+		if _, err := db.Exec(schema); err != nil {
+			return err
+		}
+		if _, err := db.Exec(`INSERT INTO memos_fts (rowid, content) SELECT id, content FROM memos`); err != nil {
+			return err
+		}
+	}
 
+	triggers := []string{
+		`CREATE TRIGGER IF NOT EXISTS memos_fts_after_insert AFTER INSERT ON memos BEGIN
+			INSERT INTO memos_fts (rowid, content) VALUES (new.id, new.content);
+		END;`,
+		`CREATE TRIGGER IF NOT EXISTS memos_fts_after_update AFTER UPDATE OF content ON memos BEGIN
+			DELETE FROM memos_fts WHERE rowid = old.id;
+			INSERT INTO memos_fts (rowid, content) VALUES (new.id, new.content);
+		END;`,
+		`CREATE TRIGGER IF NOT EXISTS memos_fts_after_delete AFTER DELETE ON memos BEGIN
+			DELETE FROM memos_fts WHERE rowid = old.id;
+		END;`,
+	}
+	for _, stmt := range triggers {
+		if _, err := db.Exec(stmt); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+func hasTable(db *sql.DB, table string) (bool, error) {
+	var count int
+	if err := db.QueryRow(
+		`SELECT COUNT(1) FROM sqlite_master WHERE type = 'table' AND name = ?`,
+		table,
+	).Scan(&count); err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
 func ensureColumn(db *sql.DB, table string, column string, definition string) error {
 	exists, err := hasColumn(db, table, column)
 	if err != nil {
@@ -370,6 +545,83 @@ func removeMemoPayloadJSONColumn(db *sql.DB) error {
 	return nil
 }
 
+// dropMemoChangeEventsCreatorForeignKey 重建 memo_change_events，去掉 creator_id 对 users 的级联外键：
+// 删除用户时要为其备忘录写入 DELETE 事件，这些事件不能随用户一起被级联删除。
+// 重建期间在独占连接上关闭外键检查，否则 DROP TABLE 会级联清空 memo_change_event_recipients。
+func dropMemoChangeEventsCreatorForeignKey(db *sql.DB) error {
+	hasForeignKey, err := hasForeignKey(db, "memo_change_events", "users")
+	if err != nil || !hasForeignKey {
+		return err
+	}
+
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if _, err := conn.ExecContext(ctx, `PRAGMA foreign_keys = OFF`); err != nil {
+		return err
+	}
+	defer conn.ExecContext(ctx, `PRAGMA foreign_keys = ON`) //nolint:errcheck
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() //nolint:errcheck
+	stmts := []string{
+		`CREATE TABLE memo_change_events_rebuild (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			memo_id INTEGER NOT NULL,
+			memo_name TEXT NOT NULL,
+			creator_id INTEGER NOT NULL,
+			event_type TEXT NOT NULL,
+			event_time TEXT NOT NULL,
+			broadcast INTEGER NOT NULL DEFAULT 0
+		)`,
+		`INSERT INTO memo_change_events_rebuild (id, memo_id, memo_name, creator_id, event_type, event_time, broadcast)
+		SELECT id, memo_id, memo_name, creator_id, event_type, event_time, broadcast FROM memo_change_events`,
+		`DROP TABLE memo_change_events`,
+		`ALTER TABLE memo_change_events_rebuild RENAME TO memo_change_events`,
+		`CREATE INDEX IF NOT EXISTS idx_memo_change_events_event_time ON memo_change_events(event_time ASC, id ASC)`,
+		`CREATE INDEX IF NOT EXISTS idx_memo_change_events_memo_id ON memo_change_events(memo_id, event_time DESC)`,
+	}
+	for _, stmt := range stmts {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func hasForeignKey(db *sql.DB, table string, referencedTable string) (bool, error) {
+	rows, err := db.Query(fmt.Sprintf("PRAGMA foreign_key_list(%s)", table))
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return false, err
+	}
+	values := make([]any, len(columns))
+	for i := range values {
+		values[i] = new(sql.NullString)
+	}
+	for rows.Next() {
+		if err := rows.Scan(values...); err != nil {
+			return false, err
+		}
+		// foreign_key_list 的第 3 列为被引用的表名
+		if referenced := values[2].(*sql.NullString); referenced.Valid && strings.EqualFold(referenced.String, referencedTable) {
+			return true, nil
+		}
+	}
+	return false, rows.Err()
+}
+
 func hasColumn(db *sql.DB, table string, column string) (bool, error) {
 	rows, err := db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
 	if err != nil {
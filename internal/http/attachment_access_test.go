@@ -0,0 +1,74 @@
+package http
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/shinyes/keer/internal/service"
+)
+
+func TestAttachmentFileAccessFollowsMemoVisibility(t *testing.T) {
+	app, userService := newTestAppWithUserService(t, true, true)
+	token := "demo-token"
+
+	if _, err := userService.CreateUser(context.Background(), nil, service.CreateUserInput{Username: "other01", Password: "other-password"}, true); err != nil {
+		t.Fatalf("CreateUser(other01) error = %v", err)
+	}
+	_, otherToken, err := userService.CreateAccessTokenForUser(context.Background(), "other01", "test")
+	if err != nil {
+		t.Fatalf("CreateAccessTokenForUser(other01) error = %v", err)
+	}
+
+	upload := func(filename string) apiAttachment {
+		resp := postJSONForTest(t, app, token, "/api/v1/attachments", map[string]any{
+			"filename": filename,
+			"type":     "text/plain",
+			"content":  base64.StdEncoding.EncodeToString([]byte(filename)),
+		})
+		defer resp.Body.Close()
+		var attachment apiAttachment
+		if err := json.NewDecoder(resp.Body).Decode(&attachment); err != nil {
+			t.Fatalf("decode attachment response failed: %v", err)
+		}
+		return attachment
+	}
+	shared := upload("shared.txt")
+	private := upload("private.txt")
+	orphan := upload("orphan.txt")
+
+	createMemoForGet(t, app, token, map[string]any{"content": "shared", "visibility": "PROTECTED", "attachments": []map[string]any{{"name": shared.Name}}})
+	createMemoForGet(t, app, token, map[string]any{"content": "private", "visibility": "PRIVATE", "attachments": []map[string]any{{"name": private.Name}}})
+
+	fetch := func(authToken string, attachment apiAttachment) int {
+		id := strings.TrimPrefix(attachment.Name, "attachments/")
+		req := httptest.NewRequest(http.MethodGet, "/file/attachments/"+id+"/"+attachment.Filename, nil)
+		req.Header.Set("Authorization", "Bearer "+authToken)
+		resp, err := app.Test(req, 5000)
+		if err != nil {
+			t.Fatalf("fetch attachment request failed: %v", err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	cases := []struct {
+		token      string
+		attachment apiAttachment
+		want       int
+	}{
+		{token: otherToken, attachment: shared, want: http.StatusOK},
+		{token: otherToken, attachment: private, want: http.StatusForbidden},
+		{token: otherToken, attachment: orphan, want: http.StatusForbidden},
+		{token: token, attachment: orphan, want: http.StatusOK},
+	}
+	for _, tc := range cases {
+		if got := fetch(tc.token, tc.attachment); got != tc.want {
+			t.Fatalf("fetch %s: expected %d, got %d", tc.attachment.Filename, tc.want, got)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package http
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/shinyes/keer/internal/service"
+)
+
+func TestListAttachmentMemos(t *testing.T) {
+	app, userService := newTestAppWithUserService(t, true, true)
+	token := "demo-token"
+
+	if _, err := userService.CreateUser(context.Background(), nil, service.CreateUserInput{Username: "other01", Password: "other-password"}, true); err != nil {
+		t.Fatalf("CreateUser(other01) error = %v", err)
+	}
+	_, otherToken, err := userService.CreateAccessTokenForUser(context.Background(), "other01", "test")
+	if err != nil {
+		t.Fatalf("CreateAccessTokenForUser(other01) error = %v", err)
+	}
+
+	uploadResp := postJSONForTest(t, app, token, "/api/v1/attachments", map[string]any{
+		"filename": "notes.txt",
+		"type":     "text/plain",
+		"content":  base64.StdEncoding.EncodeToString([]byte("notes")),
+	})
+	var attachment apiAttachment
+	if err := json.NewDecoder(uploadResp.Body).Decode(&attachment); err != nil {
+		t.Fatalf("decode attachment response failed: %v", err)
+	}
+	uploadResp.Body.Close()
+
+	listMemos := func(authToken string) (int, []string) {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/"+attachment.Name+"/memos", nil)
+		req.Header.Set("Authorization", "Bearer "+authToken)
+		resp, err := app.Test(req, 5000)
+		if err != nil {
+			t.Fatalf("list attachment memos request failed: %v", err)
+		}
+		defer resp.Body.Close()
+		var out listAttachmentMemosResponse
+		if resp.StatusCode == http.StatusOK {
+			if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+				t.Fatalf("decode attachment memos response failed: %v", err)
+			}
+		}
+		return resp.StatusCode, out.Memos
+	}
+
+	if status, memos := listMemos(token); status != http.StatusOK || memos == nil || len(memos) != 0 {
+		t.Fatalf("expected empty memo list for unused attachment, got %d %v", status, memos)
+	}
+
+	first := createMemoForGet(t, app, token, map[string]any{"content": "first", "attachments": []map[string]any{{"name": attachment.Name}}})
+	second := createMemoForGet(t, app, token, map[string]any{"content": "second", "attachments": []map[string]any{{"name": attachment.Name}}})
+	createMemoForGet(t, app, token, map[string]any{"content": "unrelated"})
+
+	status, memos := listMemos(token)
+	if status != http.StatusOK || strings.Join(memos, ",") != "memos/"+first+",memos/"+second {
+		t.Fatalf("expected both referencing memos, got %d %v", status, memos)
+	}
+	if status, _ := listMemos(otherToken); status != http.StatusNotFound {
+		t.Fatalf("expected non-owner to get 404, got %d", status)
+	}
+}
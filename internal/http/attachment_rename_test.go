@@ -0,0 +1,95 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/shinyes/keer/internal/service"
+)
+
+func TestRenameAttachmentEndpoint(t *testing.T) {
+	app, userService := newTestAppWithUserService(t, true, true)
+	ctx := context.Background()
+	token := "demo-token"
+
+	if _, err := userService.CreateUser(ctx, nil, service.CreateUserInput{
+		Username: "other01",
+		Password: "other-password",
+	}, true); err != nil {
+		t.Fatalf("CreateUser(other01) error = %v", err)
+	}
+	_, otherToken, err := userService.CreateAccessTokenForUser(ctx, "other01", "test")
+	if err != nil {
+		t.Fatalf("CreateAccessTokenForUser(other01) error = %v", err)
+	}
+
+	createResp := postJSONForTest(t, app, token, "/api/v1/attachments", map[string]any{
+		"filename": "scan001.txt",
+		"type":     "text/plain",
+		"content":  base64.StdEncoding.EncodeToString([]byte("report body")),
+	})
+	var created apiAttachment
+	if err := json.NewDecoder(createResp.Body).Decode(&created); err != nil {
+		t.Fatalf("decode attachment response failed: %v", err)
+	}
+	createResp.Body.Close()
+
+	cases := []struct {
+		token    string
+		body     map[string]any
+		status   int
+		filename string
+	}{
+		{token: otherToken, body: map[string]any{"filename": "stolen.txt"}, status: http.StatusNotFound},
+		{token: token, body: map[string]any{}, status: http.StatusBadRequest},
+		{token: token, body: map[string]any{"filename": "  "}, status: http.StatusBadRequest},
+		{token: token, body: map[string]any{"filename": "../reports/Q3 report.txt"}, status: http.StatusOK, filename: "Q3 report.txt"},
+	}
+	for _, tc := range cases {
+		payload, _ := json.Marshal(tc.body)
+		req := httptest.NewRequest(http.MethodPatch, "/api/v1/"+created.Name, bytes.NewReader(payload))
+		req.Header.Set("Authorization", "Bearer "+tc.token)
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req, 5000)
+		if err != nil {
+			t.Fatalf("rename attachment request failed: %v", err)
+		}
+		if resp.StatusCode != tc.status {
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			t.Fatalf("PATCH %v: expected %d, got %d body=%s", tc.body, tc.status, resp.StatusCode, string(body))
+		}
+		if tc.status == http.StatusOK {
+			var renamed apiAttachment
+			if err := json.NewDecoder(resp.Body).Decode(&renamed); err != nil {
+				t.Fatalf("decode renamed attachment failed: %v", err)
+			}
+			if renamed.Filename != tc.filename {
+				t.Fatalf("expected filename %q, got %q", tc.filename, renamed.Filename)
+			}
+		}
+		resp.Body.Close()
+	}
+
+	downloadReq := httptest.NewRequest(http.MethodGet, "/file/"+created.Name+"/download", nil)
+	downloadReq.Header.Set("Authorization", "Bearer "+token)
+	downloadResp, err := app.Test(downloadReq, 5000)
+	if err != nil {
+		t.Fatalf("download request failed: %v", err)
+	}
+	body, _ := io.ReadAll(downloadResp.Body)
+	downloadResp.Body.Close()
+	if downloadResp.StatusCode != http.StatusOK || string(body) != "report body" {
+		t.Fatalf("expected original content after rename, got %d body=%s", downloadResp.StatusCode, string(body))
+	}
+	if disposition := downloadResp.Header.Get("Content-Disposition"); !strings.Contains(disposition, "Q3") {
+		t.Fatalf("expected Content-Disposition to use renamed file, got %q", disposition)
+	}
+}
@@ -0,0 +1,57 @@
+package http
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/shinyes/keer/internal/config"
+)
+
+func TestAttachmentSizeLimitEndpoints(t *testing.T) {
+	app, _ := newTestAppWithConfig(t, config.Config{
+		KeerAPIVersion:             "0.1",
+		AttachmentSizeLimitsByType: map[string]int64{"image/*": 4, "video/mp4": 1024},
+	}, true)
+	token := "demo-token"
+
+	profileResp, err := app.Test(httptest.NewRequest(http.MethodGet, "/api/v1/instance/profile", nil), 5000)
+	if err != nil {
+		t.Fatalf("profile request failed: %v", err)
+	}
+	var profile profileResponse
+	if err := json.NewDecoder(profileResp.Body).Decode(&profile); err != nil {
+		t.Fatalf("decode profile failed: %v", err)
+	}
+	profileResp.Body.Close()
+	if profile.AttachmentSizeLimits["image/*"] != 4 || profile.AttachmentSizeLimits["video/mp4"] != 1024 {
+		t.Fatalf("expected size limits in profile, got %+v", profile.AttachmentSizeLimits)
+	}
+
+	cases := []struct {
+		path    string
+		payload map[string]any
+		status  int
+	}{
+		{path: "/api/v1/attachments", payload: map[string]any{"filename": "a.png", "type": "image/png", "content": base64.StdEncoding.EncodeToString([]byte("12345"))}, status: http.StatusRequestEntityTooLarge},
+		{path: "/api/v1/attachments", payload: map[string]any{"filename": "a.txt", "type": "text/plain", "content": base64.StdEncoding.EncodeToString([]byte("12345"))}, status: http.StatusOK},
+		{path: "/api/v1/attachments/uploads", payload: map[string]any{"filename": "v.mp4", "type": "video/mp4", "size": 2048}, status: http.StatusRequestEntityTooLarge},
+	}
+	for _, tc := range cases {
+		resp := postJSONForTest(t, app, token, tc.path, tc.payload)
+		var body map[string]any
+		_ = json.NewDecoder(resp.Body).Decode(&body)
+		resp.Body.Close()
+		if resp.StatusCode != tc.status {
+			t.Fatalf("POST %s %v: expected %d, got %d body=%v", tc.path, tc.payload["type"], tc.status, resp.StatusCode, body)
+		}
+		if tc.status == http.StatusRequestEntityTooLarge {
+			if _, ok := body["limit"]; !ok || !strings.Contains(body["message"].(string), "size limit") {
+				t.Fatalf("expected applicable limit in 413 body, got %v", body)
+			}
+		}
+	}
+}
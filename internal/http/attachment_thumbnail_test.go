@@ -4,14 +4,21 @@ import (
 	"bytes"
 	"encoding/base64"
 	"encoding/json"
+	"fmt"
 	"image"
 	"image/color"
 	"image/jpeg"
 	"io"
+	"mime"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/shinyes/keer/internal/config"
 )
 
 func TestAttachmentThumbnailServing(t *testing.T) {
@@ -79,6 +86,144 @@ func TestAttachmentThumbnailServing(t *testing.T) {
 	}
 }
 
+func TestAttachmentThumbnailServesOriginalWhenDisabled(t *testing.T) {
+	app, _ := newTestAppWithConfig(t, config.Config{
+		KeerAPIVersion:    "0.1",
+		DisableThumbnails: true,
+	}, true)
+	token := "demo-token"
+
+	imageBytes := generateThumbnailTestJPEG(t, 1400, 900)
+	createResp := postJSONForTest(t, app, token, "/api/v1/attachments", map[string]any{
+		"filename": "scene.jpg",
+		"type":     "image/jpeg",
+		"content":  base64.StdEncoding.EncodeToString(imageBytes),
+	})
+	defer createResp.Body.Close()
+	if createResp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(createResp.Body)
+		t.Fatalf("expected 200, got %d body=%s", createResp.StatusCode, string(body))
+	}
+	var created apiAttachment
+	if err := json.NewDecoder(createResp.Body).Decode(&created); err != nil {
+		t.Fatalf("decode create attachment response failed: %v", err)
+	}
+	if created.ThumbnailName == "" || created.ThumbnailFilename != "scene.jpg" || created.ThumbnailType != "image/jpeg" {
+		t.Fatalf("expected original to be advertised as thumbnail, got %+v", created)
+	}
+
+	thumbnailReq := httptest.NewRequest(http.MethodGet, "/file/"+created.ThumbnailName+"/"+created.ThumbnailFilename, nil)
+	thumbnailReq.Header.Set("Authorization", "Bearer "+token)
+	thumbnailResp, err := app.Test(thumbnailReq, 5000)
+	if err != nil {
+		t.Fatalf("thumbnail request failed: %v", err)
+	}
+	defer thumbnailResp.Body.Close()
+	if thumbnailResp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(thumbnailResp.Body)
+		t.Fatalf("expected thumbnail 200, got %d body=%s", thumbnailResp.StatusCode, string(body))
+	}
+	thumbnailBody, err := io.ReadAll(thumbnailResp.Body)
+	if err != nil {
+		t.Fatalf("read thumbnail body failed: %v", err)
+	}
+	if !bytes.Equal(thumbnailBody, imageBytes) {
+		t.Fatalf("expected original image bytes, got %d bytes", len(thumbnailBody))
+	}
+}
+
+func TestAttachmentFileCachingHeaders(t *testing.T) {
+	app, _ := newTestAppWithConfig(t, config.Config{
+		KeerAPIVersion:  "0.1",
+		FileCacheMaxAge: 10 * time.Minute,
+	}, true)
+	token := "demo-token"
+
+	createResp := postJSONForTest(t, app, token, "/api/v1/attachments", map[string]any{
+		"filename": "scene.jpg",
+		"type":     "image/jpeg",
+		"content":  base64.StdEncoding.EncodeToString(generateThumbnailTestJPEG(t, 1400, 900)),
+	})
+	defer createResp.Body.Close()
+	if createResp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(createResp.Body)
+		t.Fatalf("expected 200, got %d body=%s", createResp.StatusCode, string(body))
+	}
+	var created apiAttachment
+	if err := json.NewDecoder(createResp.Body).Decode(&created); err != nil {
+		t.Fatalf("decode create attachment response failed: %v", err)
+	}
+
+	get := func(path string, headers map[string]string) *http.Response {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		for key, value := range headers {
+			req.Header.Set(key, value)
+		}
+		resp, err := app.Test(req, 5000)
+		if err != nil {
+			t.Fatalf("GET %s failed: %v", path, err)
+		}
+		t.Cleanup(func() { _ = resp.Body.Close() })
+		return resp
+	}
+
+	filePath := "/file/" + created.Name + "/" + created.Filename
+	thumbnailPath := "/file/" + created.ThumbnailName + "/" + created.ThumbnailFilename
+	etags := map[string]string{}
+	for _, path := range []string{filePath, thumbnailPath} {
+		resp := get(path, nil)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200 for %s, got %d", path, resp.StatusCode)
+		}
+		if got := resp.Header.Get("Cache-Control"); got != "private, max-age=600" {
+			t.Fatalf("unexpected Cache-Control for %s: %q", path, got)
+		}
+		etag := resp.Header.Get("ETag")
+		if !strings.HasPrefix(etag, `"`) || !strings.HasSuffix(etag, `"`) || len(etag) < 3 {
+			t.Fatalf("expected strong ETag for %s, got %q", path, etag)
+		}
+		etags[path] = etag
+
+		notModified := get(path, map[string]string{"If-None-Match": `"stale", ` + etag})
+		if notModified.StatusCode != http.StatusNotModified {
+			t.Fatalf("expected 304 for %s, got %d", path, notModified.StatusCode)
+		}
+		if body, _ := io.ReadAll(notModified.Body); len(body) != 0 {
+			t.Fatalf("expected empty 304 body for %s, got %d bytes", path, len(body))
+		}
+		if stale := get(path, map[string]string{"If-None-Match": `"stale"`}); stale.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200 for stale ETag on %s, got %d", path, stale.StatusCode)
+		}
+	}
+	if etags[filePath] == etags[thumbnailPath] {
+		t.Fatalf("expected thumbnail ETag to differ from file ETag, both %q", etags[filePath])
+	}
+
+	full, err := io.ReadAll(get(thumbnailPath, nil).Body)
+	if err != nil {
+		t.Fatalf("read thumbnail failed: %v", err)
+	}
+	ranged := get(thumbnailPath, map[string]string{"Range": "bytes=0-9"})
+	if ranged.StatusCode != http.StatusPartialContent {
+		t.Fatalf("expected 206 for thumbnail range, got %d", ranged.StatusCode)
+	}
+	if got := ranged.Header.Get("Content-Range"); got != fmt.Sprintf("bytes 0-9/%d", len(full)) {
+		t.Fatalf("unexpected Content-Range: %q", got)
+	}
+	part, err := io.ReadAll(ranged.Body)
+	if err != nil {
+		t.Fatalf("read thumbnail range failed: %v", err)
+	}
+	if !bytes.Equal(part, full[:10]) {
+		t.Fatalf("unexpected thumbnail range bytes")
+	}
+	if unsatisfiable := get(thumbnailPath, map[string]string{"Range": fmt.Sprintf("bytes=%d-", len(full))}); unsatisfiable.StatusCode != http.StatusRequestedRangeNotSatisfiable {
+		t.Fatalf("expected 416 for out-of-range thumbnail request, got %d", unsatisfiable.StatusCode)
+	}
+}
+
 func generateThumbnailTestJPEG(t *testing.T, width int, height int) []byte {
 	t.Helper()
 	img := image.NewRGBA(image.Rect(0, 0, width, height))
@@ -99,3 +244,135 @@ func generateThumbnailTestJPEG(t *testing.T, width int, height int) []byte {
 	}
 	return buf.Bytes()
 }
+
+func TestAttachmentFileDownloadDisposition(t *testing.T) {
+	app := newTestApp(t, true, true)
+	token := "demo-token"
+
+	createResp := postJSONForTest(t, app, token, "/api/v1/attachments", map[string]any{
+		"filename": "scene.jpg",
+		"type":     "image/jpeg",
+		"content":  base64.StdEncoding.EncodeToString(generateThumbnailTestJPEG(t, 1400, 900)),
+	})
+	defer createResp.Body.Close()
+	var created apiAttachment
+	if err := json.NewDecoder(createResp.Body).Decode(&created); err != nil {
+		t.Fatalf("decode create attachment response failed: %v", err)
+	}
+
+	filePath := "/file/" + created.Name + "/" + created.Filename
+	thumbnailPath := "/file/" + created.ThumbnailName + "/" + created.ThumbnailFilename
+	cases := []struct {
+		path string
+		want string
+	}{
+		{path: filePath, want: `inline; filename=scene.jpg`},
+		{path: filePath + "?download=0", want: `inline; filename=scene.jpg`},
+		{path: filePath + "?download=1", want: `attachment; filename=scene.jpg`},
+		{path: thumbnailPath + "?download=true", want: `attachment; filename=` + created.ThumbnailFilename},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := app.Test(req, 5000)
+		if err != nil {
+			t.Fatalf("GET %s failed: %v", tc.path, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200 for %s, got %d", tc.path, resp.StatusCode)
+		}
+		if got := resp.Header.Get("Content-Disposition"); got != tc.want {
+			t.Fatalf("unexpected Content-Disposition for %s: got %q want %q", tc.path, got, tc.want)
+		}
+	}
+}
+
+func TestAttachmentFileMultipartByteRanges(t *testing.T) {
+	app := newTestApp(t, true, true)
+	token := "demo-token"
+	content := []byte("0123456789abcdefghijklmnopqrstuvwxyz")
+
+	createResp := postJSONForTest(t, app, token, "/api/v1/attachments", map[string]any{
+		"filename": "letters.txt",
+		"type":     "text/plain",
+		"content":  base64.StdEncoding.EncodeToString(content),
+	})
+	defer createResp.Body.Close()
+	var created apiAttachment
+	if err := json.NewDecoder(createResp.Body).Decode(&created); err != nil {
+		t.Fatalf("decode create attachment response failed: %v", err)
+	}
+	filePath := "/file/" + created.Name + "/" + created.Filename
+
+	get := func(rangeHeader string) *http.Response {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, filePath, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Range", rangeHeader)
+		resp, err := app.Test(req, 5000)
+		if err != nil {
+			t.Fatalf("GET %s failed: %v", filePath, err)
+		}
+		t.Cleanup(func() { _ = resp.Body.Close() })
+		return resp
+	}
+
+	resp := get("bytes=0-3, 10-12,-2")
+	if resp.StatusCode != http.StatusPartialContent {
+		t.Fatalf("expected 206, got %d", resp.StatusCode)
+	}
+	mediaType, params, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/byteranges" {
+		t.Fatalf("unexpected Content-Type %q err=%v", resp.Header.Get("Content-Type"), err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if got := resp.Header.Get("Content-Length"); got != strconv.Itoa(len(body)) {
+		t.Fatalf("Content-Length %s does not match body length %d", got, len(body))
+	}
+	reader := multipart.NewReader(bytes.NewReader(body), params["boundary"])
+	wantParts := []struct {
+		contentRange string
+		data         string
+	}{
+		{contentRange: "bytes 0-3/36", data: "0123"},
+		{contentRange: "bytes 10-12/36", data: "abc"},
+		{contentRange: "bytes 34-35/36", data: "yz"},
+	}
+	for _, want := range wantParts {
+		part, err := reader.NextPart()
+		if err != nil {
+			t.Fatalf("NextPart() error = %v", err)
+		}
+		if got := part.Header.Get("Content-Range"); got != want.contentRange {
+			t.Fatalf("unexpected Content-Range %q, want %q", got, want.contentRange)
+		}
+		if got := part.Header.Get("Content-Type"); got != "text/plain" {
+			t.Fatalf("unexpected part Content-Type %q", got)
+		}
+		data, _ := io.ReadAll(part)
+		if string(data) != want.data {
+			t.Fatalf("unexpected part data %q, want %q", data, want.data)
+		}
+	}
+	if _, err := reader.NextPart(); err != io.EOF {
+		t.Fatalf("expected end of multipart body, got %v", err)
+	}
+
+	single := get("bytes=0-3,100-200")
+	if single.StatusCode != http.StatusPartialContent || single.Header.Get("Content-Range") != "bytes 0-3/36" {
+		t.Fatalf("expected single satisfiable range served as plain 206, got %d %q", single.StatusCode, single.Header.Get("Content-Range"))
+	}
+	if data, _ := io.ReadAll(single.Body); string(data) != "0123" {
+		t.Fatalf("unexpected single range body %q", data)
+	}
+
+	overlapping := get("bytes=0-35,0-35")
+	if overlapping.StatusCode != http.StatusOK {
+		t.Fatalf("expected overlapping ranges to fall back to 200, got %d", overlapping.StatusCode)
+	}
+
+	if unsatisfiable := get("bytes=40-50,60-"); unsatisfiable.StatusCode != http.StatusRequestedRangeNotSatisfiable {
+		t.Fatalf("expected 416, got %d", unsatisfiable.StatusCode)
+	}
+}
@@ -11,7 +11,11 @@ import (
 	"github.com/shinyes/keer/internal/service"
 )
 
-const currentUserKey = "currentUser"
+const (
+	currentUserKey   = "currentUser"
+	tokenScopesKey   = "tokenScopes"
+	accessTokenIDKey = "accessTokenID"
+)
 
 func AuthMiddleware(userService *service.UserService) fiber.Handler {
 	return func(c *fiber.Ctx) error {
@@ -24,7 +28,7 @@ func AuthMiddleware(userService *service.UserService) fiber.Handler {
 			return writeError(c, fiber.StatusUnauthorized, "UNAUTHORIZED", "invalid authorization header")
 		}
 		token := strings.TrimSpace(authz[len("Bearer "):])
-		user, err := userService.AuthenticateToken(c.Context(), token)
+		user, accessToken, err := userService.AuthenticateAccessTokenFromClient(c.Context(), token, c.IP(), c.Get(fiber.HeaderUserAgent))
 		if err != nil {
 			if errors.Is(err, sql.ErrNoRows) {
 				return writeError(c, fiber.StatusUnauthorized, "UNAUTHORIZED", "invalid access token")
@@ -32,8 +36,69 @@ func AuthMiddleware(userService *service.UserService) fiber.Handler {
 			return writeError(c, fiber.StatusInternalServerError, "INTERNAL_ERROR", "failed to authenticate")
 		}
 		c.Locals(currentUserKey, user)
+		c.Locals(tokenScopesKey, accessToken.Scopes)
+		c.Locals(accessTokenIDKey, accessToken.ID)
+		return c.Next()
+	}
+}
+
+// RequireScopes 要求当前令牌拥有资源的读（GET/HEAD）或写（其他方法）权限，需放在 AuthMiddleware 之后。
+func RequireScopes(resource string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		return checkTokenScope(c, resource)
+	}
+}
+
+// RequireAPIScopes 按 /api/v1 之后的第一段路径（如 /memos:export 中的 memos）确定资源并检查令牌权限；
+// /auth/me 与 /auth/signout 只作用于令牌自身，不需要额外权限；/auth/tokens 令牌管理归入 tokens 资源，
+// 其余 /auth 接口只允许完整权限的令牌访问。
+func RequireAPIScopes() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		rest := strings.TrimPrefix(c.Path(), "/api/v1/")
+		if rest == "auth/me" || rest == "auth/signout" {
+			return c.Next()
+		}
+		if rest == "auth/tokens" || strings.HasPrefix(rest, "auth/tokens/") {
+			return checkTokenScope(c, "tokens")
+		}
+		resource := rest
+		if idx := strings.IndexAny(rest, "/:"); idx >= 0 {
+			resource = rest[:idx]
+		}
+		if resource == "tags" {
+			// 标签是 memo 的一部分，拥有 memos 权限的令牌同样可以访问
+			return checkTokenScope(c, "tags", "memos")
+		}
+		return checkTokenScope(c, resource)
+	}
+}
+
+// checkTokenScope 要求令牌拥有 resource 的对应权限，aliases 中任一资源的同名权限也视为满足。
+func checkTokenScope(c *fiber.Ctx, resource string, aliases ...string) error {
+	action := service.TokenScopeActionWrite
+	if c.Method() == fiber.MethodGet || c.Method() == fiber.MethodHead {
+		action = service.TokenScopeActionRead
+	}
+	scopes := CurrentTokenScopes(c)
+	if service.TokenScopesAllow(scopes, resource, action) {
 		return c.Next()
 	}
+	for _, alias := range aliases {
+		if service.TokenScopesAllow(scopes, alias, action) {
+			return c.Next()
+		}
+	}
+	return writeError(c, fiber.StatusForbidden, "FORBIDDEN", "access token lacks scope "+resource+":"+action)
+}
+
+func CurrentAccessTokenID(c *fiber.Ctx) int64 {
+	id, _ := c.Locals(accessTokenIDKey).(int64)
+	return id
+}
+
+func CurrentTokenScopes(c *fiber.Ctx) []string {
+	scopes, _ := c.Locals(tokenScopesKey).([]string)
+	return scopes
 }
 
 func CurrentUser(c *fiber.Ctx) models.User {
@@ -45,6 +110,8 @@ func CurrentUser(c *fiber.Ctx) models.User {
 	return user
 }
 
+// OptionalAuthenticateToken 在请求携带 Authorization 时认证令牌，并与 AuthMiddleware 一样记录当前用户与令牌权限；
+// 未携带时返回 nil，调用方按匿名访问者（store.AnonymousViewerID）处理。
 func OptionalAuthenticateToken(c *fiber.Ctx, userService *service.UserService) (*models.User, error) {
 	authz := strings.TrimSpace(c.Get("Authorization"))
 	if authz == "" {
@@ -54,9 +121,12 @@ func OptionalAuthenticateToken(c *fiber.Ctx, userService *service.UserService) (
 		return nil, sql.ErrNoRows
 	}
 	token := strings.TrimSpace(authz[len("Bearer "):])
-	user, err := userService.AuthenticateToken(c.Context(), token)
+	user, accessToken, err := userService.AuthenticateAccessTokenFromClient(c.Context(), token, c.IP(), c.Get(fiber.HeaderUserAgent))
 	if err != nil {
 		return nil, err
 	}
+	c.Locals(currentUserKey, user)
+	c.Locals(tokenScopesKey, accessToken.Scopes)
+	c.Locals(accessTokenIDKey, accessToken.ID)
 	return &user, nil
 }
@@ -0,0 +1,52 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/shinyes/keer/internal/service"
+)
+
+func TestResetPasswordEndpoint_ConsumesTokenOnce(t *testing.T) {
+	app, userService := newTestAppWithUserService(t, true, true)
+	ctx := context.Background()
+
+	if _, err := userService.CreateUser(ctx, nil, service.CreateUserInput{Username: "reset01", Password: "old-password"}, true); err != nil {
+		t.Fatalf("CreateUser(reset01) error = %v", err)
+	}
+	resetToken, _, err := userService.CreatePasswordResetToken(ctx, "reset01")
+	if err != nil {
+		t.Fatalf("CreatePasswordResetToken() error = %v", err)
+	}
+
+	reset := func(token string, password string) (int, string) {
+		body, _ := json.Marshal(map[string]string{"token": token, "newPassword": password})
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/auth/resetPassword", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req, 5000)
+		if err != nil {
+			t.Fatalf("reset password request failed: %v", err)
+		}
+		defer resp.Body.Close()
+		respBody, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(respBody)
+	}
+
+	if status, body := reset(resetToken, "short"); status != http.StatusBadRequest {
+		t.Fatalf("expected short password 400, got %d body=%s", status, body)
+	}
+	if status, body := reset(resetToken, "new-password"); status != http.StatusNoContent {
+		t.Fatalf("expected reset 204, got %d body=%s", status, body)
+	}
+	if status, body := reset(resetToken, "another-password"); status != http.StatusBadRequest {
+		t.Fatalf("expected reused token 400, got %d body=%s", status, body)
+	}
+	if _, _, err := userService.SignInWithPassword(ctx, "reset01", "new-password"); err != nil {
+		t.Fatalf("expected sign in with new password, got %v", err)
+	}
+}
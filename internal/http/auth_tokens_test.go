@@ -0,0 +1,169 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/shinyes/keer/internal/service"
+	"github.com/shinyes/keer/internal/store"
+)
+
+func TestSelfServiceAccessTokens(t *testing.T) {
+	app, userService := newTestAppWithUserService(t, true, true)
+	ctx := context.Background()
+	token := "demo-token"
+
+	_, secondToken, err := userService.CreateAccessTokenForUser(ctx, "demo", "laptop")
+	if err != nil {
+		t.Fatalf("CreateAccessTokenForUser(demo) error = %v", err)
+	}
+	_, scopedToken, err := userService.CreateAccessTokenForUserWithScopes(ctx, "demo", "integration", nil, []string{"memos:read"})
+	if err != nil {
+		t.Fatalf("CreateAccessTokenForUserWithScopes(demo) error = %v", err)
+	}
+	if _, err := userService.CreateUser(ctx, nil, service.CreateUserInput{Username: "other01", Password: "other-password"}, true); err != nil {
+		t.Fatalf("CreateUser(other01) error = %v", err)
+	}
+	_, otherToken, err := userService.CreateAccessTokenForUser(ctx, "other01", "test")
+	if err != nil {
+		t.Fatalf("CreateAccessTokenForUser(other01) error = %v", err)
+	}
+	_, other, err := userService.AuthenticateAccessToken(ctx, otherToken)
+	if err != nil {
+		t.Fatalf("AuthenticateAccessToken(other01) error = %v", err)
+	}
+	_, second, err := userService.AuthenticateAccessToken(ctx, secondToken)
+	if err != nil {
+		t.Fatalf("AuthenticateAccessToken(second) error = %v", err)
+	}
+
+	request := func(method string, path string, bearer string) (int, string) {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", "Bearer "+bearer)
+		resp, err := app.Test(req, 5000)
+		if err != nil {
+			t.Fatalf("%s %s failed: %v", method, path, err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(body)
+	}
+
+	status, body := request(http.MethodGet, "/api/v1/auth/tokens", token)
+	if status != http.StatusOK {
+		t.Fatalf("expected list 200, got %d body=%s", status, body)
+	}
+	var listed listAccessTokensResponse
+	if err := json.Unmarshal([]byte(body), &listed); err != nil {
+		t.Fatalf("decode tokens failed: %v", err)
+	}
+	if len(listed.Tokens) != 3 {
+		t.Fatalf("expected 3 own tokens, got %+v", listed.Tokens)
+	}
+	currentCount := 0
+	for _, item := range listed.Tokens {
+		if item.Current {
+			currentCount++
+			if item.Prefix != token[:8] {
+				t.Fatalf("expected current token to be the request token, got %+v", item)
+			}
+		}
+	}
+	if currentCount != 1 || strings.Contains(body, store.HashToken(token)) || strings.Contains(body, secondToken) {
+		t.Fatalf("expected one current token and no secrets in response, body=%s", body)
+	}
+
+	if status, _ := request(http.MethodGet, "/api/v1/auth/tokens", scopedToken); status != http.StatusForbidden {
+		t.Fatalf("expected scoped token to be denied token management, got %d", status)
+	}
+	if status, _ := request(http.MethodDelete, "/api/v1/auth/tokens/"+strconv.FormatInt(other.ID, 10), token); status != http.StatusNotFound {
+		t.Fatalf("expected revoking another user's token to return 404, got %d", status)
+	}
+	if status, body := request(http.MethodDelete, "/api/v1/auth/tokens/"+strconv.FormatInt(second.ID, 10), token); status != http.StatusOK || !strings.Contains(body, "revokeTime") {
+		t.Fatalf("expected own token revoked, got %d body=%s", status, body)
+	}
+	if status, _ := request(http.MethodDelete, "/api/v1/auth/tokens/"+strconv.FormatInt(second.ID, 10), token); status != http.StatusConflict {
+		t.Fatalf("expected second revoke to conflict, got %d", status)
+	}
+	if status, _ := request(http.MethodGet, "/api/v1/auth/me", secondToken); status != http.StatusUnauthorized {
+		t.Fatalf("expected revoked token to be rejected, got %d", status)
+	}
+	if status, _ := request(http.MethodGet, "/api/v1/auth/me", otherToken); status != http.StatusOK {
+		t.Fatalf("expected other user's token to stay valid, got %d", status)
+	}
+}
+
+func TestSignOutRevokesPresentedToken(t *testing.T) {
+	app, userService := newTestAppWithUserService(t, true, true)
+	ctx := context.Background()
+	_, scopedToken, err := userService.CreateAccessTokenForUserWithScopes(ctx, "demo", "integration", nil, []string{"memos:read"})
+	if err != nil {
+		t.Fatalf("CreateAccessTokenForUserWithScopes(demo) error = %v", err)
+	}
+
+	request := func(method string, path string, bearer string) int {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", "Bearer "+bearer)
+		resp, err := app.Test(req, 5000)
+		if err != nil {
+			t.Fatalf("%s %s failed: %v", method, path, err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if status := request(http.MethodPost, "/api/v1/auth/signout", scopedToken); status != http.StatusNoContent {
+		t.Fatalf("expected signout 204, got %d", status)
+	}
+	if status := request(http.MethodGet, "/api/v1/auth/me", scopedToken); status != http.StatusUnauthorized {
+		t.Fatalf("expected signed-out token to be rejected, got %d", status)
+	}
+	if status := request(http.MethodPost, "/api/v1/auth/signout", scopedToken); status != http.StatusUnauthorized {
+		t.Fatalf("expected second signout to be rejected, got %d", status)
+	}
+	if status := request(http.MethodGet, "/api/v1/auth/me", "demo-token"); status != http.StatusOK {
+		t.Fatalf("expected other tokens to stay valid, got %d", status)
+	}
+}
+
+func TestAccessTokenRecordsLastUsedClient(t *testing.T) {
+	app := newTestApp(t, true, true)
+
+	meReq := httptest.NewRequest(http.MethodGet, "/api/v1/auth/me", nil)
+	meReq.Header.Set("Authorization", "Bearer demo-token")
+	meReq.Header.Set("User-Agent", "keer-test-client/1.0")
+	meResp, err := app.Test(meReq, 5000)
+	if err != nil {
+		t.Fatalf("auth/me request failed: %v", err)
+	}
+	meResp.Body.Close()
+
+	listReq := httptest.NewRequest(http.MethodGet, "/api/v1/auth/tokens", nil)
+	listReq.Header.Set("Authorization", "Bearer demo-token")
+	listResp, err := app.Test(listReq, 5000)
+	if err != nil {
+		t.Fatalf("list tokens request failed: %v", err)
+	}
+	defer listResp.Body.Close()
+	var listed listAccessTokensResponse
+	if err := json.NewDecoder(listResp.Body).Decode(&listed); err != nil {
+		t.Fatalf("decode tokens failed: %v", err)
+	}
+	if len(listed.Tokens) != 1 {
+		t.Fatalf("expected one token, got %+v", listed.Tokens)
+	}
+	token := listed.Tokens[0]
+	if token.LastUsedIP == "" || token.LastUsedTime == "" {
+		t.Fatalf("expected last used ip and time recorded, got %+v", token)
+	}
+	// 未带 User-Agent 的后续请求不会覆盖之前记录的值。
+	if token.LastUsedUserAgent != "keer-test-client/1.0" {
+		t.Fatalf("expected last used user agent preserved, got %q", token.LastUsedUserAgent)
+	}
+}
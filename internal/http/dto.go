@@ -19,6 +19,11 @@ type signInPasswordCredentials struct {
 	Password string `json:"password"`
 }
 
+type resetPasswordRequest struct {
+	Token       string `json:"token"`
+	NewPassword string `json:"newPassword"`
+}
+
 type signInResponse struct {
 	User                 apiUser `json:"user"`
 	AccessToken          string  `json:"accessToken"`
@@ -30,6 +35,7 @@ type createUserRequest struct {
 	UserID       string         `json:"userId"`
 	ValidateOnly bool           `json:"validateOnly"`
 	RequestID    string         `json:"requestId"`
+	InviteToken  string         `json:"inviteToken"`
 }
 
 type updateUserRequest struct {
@@ -69,6 +75,24 @@ type apiUser struct {
 	UpdateTime  string `json:"updateTime,omitempty"`
 }
 
+type apiAccessToken struct {
+	ID                int64    `json:"id"`
+	Prefix            string   `json:"prefix"`
+	Description       string   `json:"description"`
+	Scopes            []string `json:"scopes"`
+	Current           bool     `json:"current"`
+	CreateTime        string   `json:"createTime"`
+	LastUsedTime      string   `json:"lastUsedTime,omitempty"`
+	LastUsedIP        string   `json:"lastUsedIp,omitempty"`
+	LastUsedUserAgent string   `json:"lastUsedUserAgent,omitempty"`
+	ExpireTime        string   `json:"expireTime,omitempty"`
+	RevokeTime        string   `json:"revokeTime,omitempty"`
+}
+
+type listAccessTokensResponse struct {
+	Tokens []apiAccessToken `json:"tokens"`
+}
+
 type listUsersResponse struct {
 	Users []apiUser `json:"users"`
 }
@@ -89,6 +113,19 @@ type listMemoChangesResponse struct {
 	SyncAnchor       string    `json:"syncAnchor"`
 }
 
+type apiMemoEvent struct {
+	Name       string   `json:"name"`
+	Memo       string   `json:"memo"`
+	EventType  string   `json:"eventType"`
+	EventTime  string   `json:"eventTime"`
+	Recipients []string `json:"recipients"`
+	Broadcast  bool     `json:"broadcast,omitempty"`
+}
+
+type listMemoEventsResponse struct {
+	Events []apiMemoEvent `json:"events"`
+}
+
 type createMemoRequest struct {
 	Content     string          `json:"content"`
 	Visibility  string          `json:"visibility"`
@@ -99,6 +136,54 @@ type createMemoRequest struct {
 	Longitude   *float64        `json:"longitude,omitempty"`
 }
 
+type createMemoFromAttachmentRequest struct {
+	Attachment string                   `json:"attachment"`
+	UploadID   string                   `json:"uploadId"`
+	File       *createAttachmentRequest `json:"file"`
+	Content    string                   `json:"content"`
+	Visibility string                   `json:"visibility"`
+	Tags       []string                 `json:"tags,omitempty"`
+}
+
+type apiMemoExportLine struct {
+	Type        string   `json:"type"`
+	Name        string   `json:"name"`
+	Content     string   `json:"content"`
+	Tags        []string `json:"tags"`
+	Visibility  string   `json:"visibility"`
+	State       string   `json:"state"`
+	Pinned      bool     `json:"pinned"`
+	CreateTime  string   `json:"createTime"`
+	UpdateTime  string   `json:"updateTime"`
+	Attachments []string `json:"attachments"`
+}
+
+type apiMemoExportSummary struct {
+	Type       string `json:"type"`
+	Count      int64  `json:"count"`
+	ExportTime string `json:"exportTime"`
+}
+
+type apiMemoImportError struct {
+	Line    int    `json:"line"`
+	Message string `json:"message"`
+}
+
+type memoImportResponse struct {
+	Created int                  `json:"created"`
+	Skipped int                  `json:"skipped"`
+	Errors  []apiMemoImportError `json:"errors"`
+}
+
+type sharedUnreadCountResponse struct {
+	Count        int64  `json:"count"`
+	LastSeenTime string `json:"lastSeenTime,omitempty"`
+}
+
+type markSharedSeenResponse struct {
+	LastSeenTime string `json:"lastSeenTime"`
+}
+
 type updateMemoRequest struct {
 	Content     *string          `json:"content"`
 	Visibility  *string          `json:"visibility"`
@@ -108,6 +193,45 @@ type updateMemoRequest struct {
 	Attachments *[]apiAttachment `json:"attachments"`
 	Latitude    optionalFloat64  `json:"latitude"`
 	Longitude   optionalFloat64  `json:"longitude"`
+	CreateTime  *string          `json:"createTime"`
+}
+
+type batchSetMemoVisibilityRequest struct {
+	Filter     string `json:"filter"`
+	Visibility string `json:"visibility"`
+	Confirm    bool   `json:"confirm"`
+}
+
+type batchSetMemoVisibilityResponse struct {
+	ChangedCount int `json:"changedCount"`
+}
+
+type validateMemoFilterRequest struct {
+	Filter string `json:"filter"`
+}
+
+type validateMemoFilterResponse struct {
+	FullyPushedDown bool             `json:"fullyPushedDown"`
+	Prefilter       apiMemoPrefilter `json:"prefilter"`
+}
+
+// apiMemoPrefilter 为过滤条件下推到 SQL 的摘要，仅用于调试，字段含义可能随实现调整。
+type apiMemoPrefilter struct {
+	Unsatisfiable    bool            `json:"unsatisfiable,omitempty"`
+	Visibilities     []string        `json:"visibilities,omitempty"`
+	States           []string        `json:"states,omitempty"`
+	TagGroups        [][]apiTagMatch `json:"tagGroups,omitempty"`
+	ExcludeTagGroups [][]apiTagMatch `json:"excludeTagGroups,omitempty"`
+	ContentMatches   []string        `json:"contentMatches,omitempty"`
+}
+
+type apiTagMatch struct {
+	Kind  string `json:"kind"`
+	Value string `json:"value"`
+}
+
+type memoTagPinRequest struct {
+	Tag string `json:"tag"`
 }
 
 type apiMemo struct {
@@ -123,6 +247,8 @@ type apiMemo struct {
 	Longitude   *float64        `json:"longitude,omitempty"`
 	Attachments []apiAttachment `json:"attachments,omitempty"`
 	Tags        []string        `json:"tags,omitempty"`
+	// Collaborators 为协作者用户名（users/<id>），内部的 collab/<id> 标签不会出现在 Tags 中。
+	Collaborators []string `json:"collaborators,omitempty"`
 }
 
 type listGroupsResponse struct {
@@ -139,6 +265,10 @@ type updateGroupRequest struct {
 	Description *string `json:"description"`
 }
 
+type addGroupMemberRequest struct {
+	User string `json:"user"`
+}
+
 type apiGroupMember struct {
 	Name        string `json:"name"`
 	Username    string `json:"username"`
@@ -179,6 +309,10 @@ type listGroupTagsResponse struct {
 	Tags []string `json:"tags"`
 }
 
+type memoGroupShareRequest struct {
+	Group string `json:"group"`
+}
+
 type addGroupTagRequest struct {
 	Tag string `json:"tag"`
 }
@@ -190,12 +324,18 @@ type createAttachmentRequest struct {
 	Memo     *string `json:"memo"`
 }
 
+type updateAttachmentRequest struct {
+	Filename *string `json:"filename"`
+}
+
 type createAttachmentUploadSessionRequest struct {
 	Filename  string                                  `json:"filename"`
 	Type      string                                  `json:"type"`
 	Size      int64                                   `json:"size"`
 	Memo      *string                                 `json:"memo"`
 	Thumbnail *createAttachmentUploadThumbnailRequest `json:"thumbnail"`
+	// SHA256 为可选的十六进制内容摘要，完成会话时服务端据此校验。
+	SHA256 string `json:"sha256"`
 }
 
 type createAttachmentUploadThumbnailRequest struct {
@@ -215,6 +355,10 @@ type attachmentUploadSessionResponse struct {
 	DirectUploadURL    string  `json:"directUploadUrl,omitempty"`
 	DirectUploadMethod string  `json:"directUploadMethod,omitempty"`
 	MultipartPartSize  string  `json:"multipartPartSize,omitempty"`
+	// ChecksumHeader 为完成会话时可选的 SHA-256 校验请求头名称。
+	ChecksumHeader string `json:"checksumHeader"`
+	// SHA256 为创建会话时声明的内容摘要。
+	SHA256 string `json:"sha256,omitempty"`
 }
 
 type attachmentMultipartPartUploadResponse struct {
@@ -230,6 +374,10 @@ type listAttachmentsResponse struct {
 	Attachments []apiAttachment `json:"attachments"`
 }
 
+type listAttachmentMemosResponse struct {
+	Memos []string `json:"memos"`
+}
+
 type apiAttachment struct {
 	Name                  string `json:"name"`
 	CreateTime            string `json:"createTime,omitempty"`
@@ -244,20 +392,104 @@ type apiAttachment struct {
 	Memo                  string `json:"memo,omitempty"`
 }
 
+type createTagRequest struct {
+	Name string `json:"name"`
+}
+
+type renameTagRequest struct {
+	OldName string `json:"oldName"`
+	NewName string `json:"newName"`
+}
+
+type apiTag struct {
+	Name string `json:"name"`
+}
+
+type listTagsResponse struct {
+	Tags []apiTagCount `json:"tags"`
+}
+
+type apiTagCount struct {
+	Name      string `json:"name"`
+	MemoCount int64  `json:"memoCount"`
+}
+
 type userSettingResponse struct {
 	GeneralSetting generalSetting `json:"generalSetting"`
 }
 
 type generalSetting struct {
-	MemoVisibility string `json:"memoVisibility,omitempty"`
+	MemoVisibility  string `json:"memoVisibility,omitempty"`
+	DefaultPageSize int    `json:"defaultPageSize,omitempty"`
+}
+
+type updateUserSettingRequest struct {
+	GeneralSetting updateGeneralSettingBody `json:"generalSetting"`
+}
+
+type updateGeneralSettingBody struct {
+	DefaultPageSize *int `json:"defaultPageSize"`
+}
+
+type apiUserSettings struct {
+	DefaultVisibility       string `json:"defaultVisibility"`
+	DefaultPageSize         int    `json:"defaultPageSize"`
+	Timezone                string `json:"timezone"`
+	Locale                  string `json:"locale"`
+	SharedMemosLastSeenTime string `json:"sharedMemosLastSeenTime,omitempty"`
+}
+
+type updateUserSettingsRequest struct {
+	DefaultVisibility *string `json:"defaultVisibility"`
+	DefaultPageSize   *int    `json:"defaultPageSize"`
+	Timezone          *string `json:"timezone"`
+	Locale            *string `json:"locale"`
 }
 
 type userStatsResponse struct {
 	TagCount map[string]int `json:"tagCount"`
+	// StorageUsage 仅对本人和管理员返回。
+	StorageUsage *apiStorageUsage `json:"storageUsage,omitempty"`
+}
+
+type apiStorageUsage struct {
+	UsedBytes  int64 `json:"usedBytes"`
+	QuotaBytes int64 `json:"quotaBytes"`
+}
+
+type serverTimeResponse struct {
+	Time string `json:"time"`
 }
 
 type profileResponse struct {
-	KeerAPIVersion string `json:"keer_api_version"`
+	KeerAPIVersion       string           `json:"keer_api_version"`
+	AttachmentSizeLimits map[string]int64 `json:"attachment_size_limits,omitempty"`
+	// InlineAttachmentMaxSize 为 base64 内联上传解码后的上限，超过时客户端应改用分片上传会话。
+	InlineAttachmentMaxSize int64 `json:"inline_attachment_max_size,omitempty"`
+}
+
+type apiAttachmentDedupGroup struct {
+	ContentHash string `json:"contentHash"`
+	References  int64  `json:"references"`
+	Size        int64  `json:"size"`
+	BytesSaved  int64  `json:"bytesSaved"`
+}
+
+type attachmentDedupResponse struct {
+	Groups          []apiAttachmentDedupGroup `json:"groups"`
+	TotalGroups     int64                     `json:"totalGroups"`
+	TotalReferences int64                     `json:"totalReferences"`
+	TotalBytesSaved int64                     `json:"totalBytesSaved"`
+	NextPageToken   string                    `json:"nextPageToken,omitempty"`
+}
+
+type searchReindexResponse struct {
+	Indexed int64 `json:"indexed"`
+}
+
+type healthResponse struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
 }
 
 type optionalFloat64 struct {
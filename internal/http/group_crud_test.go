@@ -0,0 +1,88 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/shinyes/keer/internal/service"
+)
+
+func TestGroupCRUDEndpoints_CreatorOnly(t *testing.T) {
+	app, userService := newTestAppWithUserService(t, true, true)
+	ctx := context.Background()
+	token := "demo-token"
+
+	member, err := userService.CreateUser(ctx, nil, service.CreateUserInput{
+		Username: "member01",
+		Password: "member-password",
+	}, true)
+	if err != nil {
+		t.Fatalf("CreateUser(member01) error = %v", err)
+	}
+	_, memberToken, err := userService.CreateAccessTokenForUser(ctx, "member01", "test")
+	if err != nil {
+		t.Fatalf("CreateAccessTokenForUser(member01) error = %v", err)
+	}
+
+	groupBody, _ := json.Marshal(createGroupRequest{Name: "team"})
+	groupReq := httptest.NewRequest(http.MethodPost, "/api/v1/groups", bytes.NewReader(groupBody))
+	groupReq.Header.Set("Authorization", "Bearer "+token)
+	groupReq.Header.Set("Content-Type", "application/json")
+	groupResp, err := app.Test(groupReq, 5000)
+	if err != nil {
+		t.Fatalf("create group request failed: %v", err)
+	}
+	var group apiGroup
+	if err := json.NewDecoder(groupResp.Body).Decode(&group); err != nil {
+		t.Fatalf("decode group response failed: %v", err)
+	}
+	groupResp.Body.Close()
+
+	memberPath := fmt.Sprintf("/api/v1/%s/members/%d", group.Name, member.ID)
+	cases := []struct {
+		method string
+		path   string
+		token  string
+		body   any
+		status int
+	}{
+		{method: http.MethodGet, path: "/api/v1/" + group.Name, token: memberToken, status: http.StatusNotFound},
+		{method: http.MethodPost, path: "/api/v1/" + group.Name + "/members", token: memberToken, body: addGroupMemberRequest{User: "member01"}, status: http.StatusNotFound},
+		{method: http.MethodPost, path: "/api/v1/" + group.Name + "/members", token: token, body: addGroupMemberRequest{User: "nobody"}, status: http.StatusNotFound},
+		{method: http.MethodPost, path: "/api/v1/" + group.Name + "/members", token: token, body: addGroupMemberRequest{User: member.Name()}, status: http.StatusOK},
+		{method: http.MethodGet, path: "/api/v1/" + group.Name, token: memberToken, status: http.StatusOK},
+		{method: http.MethodPatch, path: "/api/v1/" + group.Name, token: memberToken, body: map[string]any{"name": "renamed"}, status: http.StatusForbidden},
+		{method: http.MethodDelete, path: memberPath, token: memberToken, status: http.StatusForbidden},
+		{method: http.MethodPatch, path: "/api/v1/" + group.Name, token: token, body: map[string]any{"name": "renamed"}, status: http.StatusOK},
+		{method: http.MethodDelete, path: memberPath, token: token, status: http.StatusOK},
+		{method: http.MethodGet, path: "/api/v1/" + group.Name, token: memberToken, status: http.StatusNotFound},
+	}
+	for _, tc := range cases {
+		var reader io.Reader
+		if tc.body != nil {
+			payload, _ := json.Marshal(tc.body)
+			reader = bytes.NewReader(payload)
+		}
+		req := httptest.NewRequest(tc.method, tc.path, reader)
+		req.Header.Set("Authorization", "Bearer "+tc.token)
+		if tc.body != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		resp, err := app.Test(req, 5000)
+		if err != nil {
+			t.Fatalf("%s %s request failed: %v", tc.method, tc.path, err)
+		}
+		if resp.StatusCode != tc.status {
+			respBody, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			t.Fatalf("%s %s: expected %d, got %d body=%s", tc.method, tc.path, tc.status, resp.StatusCode, string(respBody))
+		}
+		resp.Body.Close()
+	}
+}
@@ -0,0 +1,32 @@
+package http
+
+import "time"
+
+// heavyOperationRetryAfter 为并发名额用尽时建议客户端等待的时长。
+const heavyOperationRetryAfter = 10 * time.Second
+
+// concurrencyLimiter 用带缓冲的 channel 作为信号量，限制同时进行的重量级操作（导出、导入）数量。
+type concurrencyLimiter struct {
+	slots chan struct{}
+}
+
+func newConcurrencyLimiter(limit int) *concurrencyLimiter {
+	if limit <= 0 {
+		return nil
+	}
+	return &concurrencyLimiter{slots: make(chan struct{}, limit)}
+}
+
+// tryAcquire 不阻塞地占用一个名额；成功时返回的 release 必须且只能调用一次。
+// 流式响应在 handler 返回后仍在输出，因此由调用方在真正结束时释放，而不是做成中间件。
+func (l *concurrencyLimiter) tryAcquire() (func(), bool) {
+	if l == nil {
+		return func() {}, true
+	}
+	select {
+	case l.slots <- struct{}{}:
+		return func() { <-l.slots }, true
+	default:
+		return nil, false
+	}
+}
@@ -0,0 +1,32 @@
+package http
+
+import "testing"
+
+func TestConcurrencyLimiter_RejectsWhenSlotsExhausted(t *testing.T) {
+	limiter := newConcurrencyLimiter(2)
+
+	first, ok := limiter.tryAcquire()
+	if !ok {
+		t.Fatalf("expected first acquire to succeed")
+	}
+	if _, ok := limiter.tryAcquire(); !ok {
+		t.Fatalf("expected second acquire to succeed")
+	}
+	if _, ok := limiter.tryAcquire(); ok {
+		t.Fatalf("expected third acquire to be rejected")
+	}
+
+	first()
+	if _, ok := limiter.tryAcquire(); !ok {
+		t.Fatalf("expected acquire to succeed after release")
+	}
+}
+
+func TestNewConcurrencyLimiter_DisabledAllowsAll(t *testing.T) {
+	limiter := newConcurrencyLimiter(0)
+	for i := 0; i < 100; i++ {
+		if _, ok := limiter.tryAcquire(); !ok {
+			t.Fatalf("expected disabled limiter to allow acquire %d", i+1)
+		}
+	}
+}
@@ -52,6 +52,138 @@ func TestListMemoChanges_ReturnsChangedAndDeletedMemos(t *testing.T) {
 	}
 }
 
+func TestSyncMemosEndpoint(t *testing.T) {
+	app := newTestApp(t, true, true)
+	token := "demo-token"
+
+	sync := func(query string) (int, listMemoChangesResponse) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/memos:sync"+query, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := app.Test(req, 5000)
+		if err != nil {
+			t.Fatalf("sync memos request failed: %v", err)
+		}
+		defer resp.Body.Close()
+		var out listMemoChangesResponse
+		if resp.StatusCode == http.StatusOK {
+			if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+				t.Fatalf("decode sync response failed: %v", err)
+			}
+		}
+		return resp.StatusCode, out
+	}
+
+	first := createMemoWithCoordinates(t, app, token, 40.7128, -74.0060)
+	status, initial := sync("")
+	if status != http.StatusOK || len(initial.Memos) != 1 || initial.Memos[0].Name != first.Name || initial.SyncAnchor == "" {
+		t.Fatalf("expected full sync without since, got %d %+v", status, initial)
+	}
+
+	second := createMemoWithCoordinates(t, app, token, 40.7128, -74.0060)
+	status, next := sync("?since=" + url.QueryEscape(initial.SyncAnchor))
+	if status != http.StatusOK || len(next.Memos) != 1 || next.Memos[0].Name != second.Name {
+		t.Fatalf("expected only the new memo after anchor, got %d %+v", status, next)
+	}
+
+	// anchor 固定窗口上界：重放首次同步窗口时不包含之后创建的备忘录
+	status, replay := sync("?anchor=" + url.QueryEscape(initial.SyncAnchor))
+	if status != http.StatusOK || len(replay.Memos) != 1 || replay.Memos[0].Name != first.Name || replay.SyncAnchor != initial.SyncAnchor {
+		t.Fatalf("expected anchored window to match initial sync, got %d %+v", status, replay)
+	}
+
+	for _, query := range []string{"?since=yesterday", "?anchor=soon", "?since=" + url.QueryEscape(next.SyncAnchor) + "&anchor=" + url.QueryEscape(initial.SyncAnchor)} {
+		if status, _ := sync(query); status != http.StatusBadRequest {
+			t.Fatalf("expected 400 for %s, got %d", query, status)
+		}
+	}
+}
+
+func TestListMemoEvents_AvailableAfterDelete(t *testing.T) {
+	app := newTestApp(t, true, true)
+	token := "demo-token"
+
+	created := createMemoWithCoordinates(t, app, token, 40.7128, -74.0060)
+	memoID := strings.TrimPrefix(created.Name, "memos/")
+
+	deleteReq := httptest.NewRequest(http.MethodDelete, "/api/v1/memos/"+memoID, nil)
+	deleteReq.Header.Set("Authorization", "Bearer "+token)
+	deleteResp, err := app.Test(deleteReq, 5000)
+	if err != nil {
+		t.Fatalf("delete memo request failed: %v", err)
+	}
+	defer deleteResp.Body.Close()
+	if deleteResp.StatusCode != http.StatusNoContent {
+		t.Fatalf("expected delete memo 204, got %d", deleteResp.StatusCode)
+	}
+
+	eventsReq := httptest.NewRequest(http.MethodGet, "/api/v1/memos/"+memoID+"/events", nil)
+	eventsReq.Header.Set("Authorization", "Bearer "+token)
+	eventsResp, err := app.Test(eventsReq, 5000)
+	if err != nil {
+		t.Fatalf("list memo events request failed: %v", err)
+	}
+	defer eventsResp.Body.Close()
+	if eventsResp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(eventsResp.Body)
+		t.Fatalf("expected list memo events 200, got %d body=%s", eventsResp.StatusCode, string(body))
+	}
+	var payload listMemoEventsResponse
+	if err := json.NewDecoder(eventsResp.Body).Decode(&payload); err != nil {
+		t.Fatalf("decode memo events response failed: %v", err)
+	}
+	if len(payload.Events) != 1 || payload.Events[0].EventType != "DELETE" || payload.Events[0].Memo != created.Name {
+		t.Fatalf("expected a single DELETE event for %s, got %+v", created.Name, payload.Events)
+	}
+
+	missingReq := httptest.NewRequest(http.MethodGet, "/api/v1/memos/999999/events", nil)
+	missingReq.Header.Set("Authorization", "Bearer "+token)
+	missingResp, err := app.Test(missingReq, 5000)
+	if err != nil {
+		t.Fatalf("list missing memo events request failed: %v", err)
+	}
+	defer missingResp.Body.Close()
+	if missingResp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404 for unknown memo, got %d", missingResp.StatusCode)
+	}
+}
+
+func TestBatchSetMemoVisibilityEndpoint(t *testing.T) {
+	app := newTestApp(t, true, true)
+	token := "demo-token"
+	createMemoWithCoordinates(t, app, token, 40.7128, -74.0060)
+
+	post := func(payload string) (int, batchSetMemoVisibilityResponse) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/memos:batchSetVisibility", strings.NewReader(payload))
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req, 5000)
+		if err != nil {
+			t.Fatalf("batch set visibility request failed: %v", err)
+		}
+		defer resp.Body.Close()
+		var out batchSetMemoVisibilityResponse
+		if resp.StatusCode == http.StatusOK {
+			if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+				t.Fatalf("decode batch set visibility response failed: %v", err)
+			}
+		}
+		return resp.StatusCode, out
+	}
+
+	if status, _ := post(`{"visibility":"PUBLIC"}`); status != http.StatusBadRequest {
+		t.Fatalf("expected 400 without confirm, got %d", status)
+	}
+	status, out := post(`{"visibility":"PUBLIC","confirm":true}`)
+	if status != http.StatusOK {
+		t.Fatalf("expected 200 with confirm, got %d", status)
+	}
+	if out.ChangedCount != 1 {
+		t.Fatalf("expected changedCount=1, got %d", out.ChangedCount)
+	}
+}
+
 func getMemoChanges(t *testing.T, app *fiber.App, token string, since string) listMemoChangesResponse {
 	t.Helper()
 	endpoint := "/api/v1/memos/changes?since=" + url.QueryEscape(since)
@@ -0,0 +1,66 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/shinyes/keer/internal/models"
+	"github.com/shinyes/keer/internal/service"
+)
+
+func TestMemoResponseHidesCollaboratorTags(t *testing.T) {
+	app, userService := newTestAppWithUserService(t, true, true)
+	token := "demo-token"
+
+	reader, err := userService.CreateUser(context.Background(), nil, service.CreateUserInput{Username: "reader01", Password: "reader-password"}, true)
+	if err != nil {
+		t.Fatalf("CreateUser(reader01) error = %v", err)
+	}
+	readerName := fmt.Sprintf("users/%d", reader.ID)
+	memoID := createMemoForGet(t, app, token, map[string]any{"content": "shared", "tags": []string{"work", fmt.Sprintf("collab/%d", reader.ID)}})
+
+	request := func(method string, path string, payload any) apiMemo {
+		t.Helper()
+		var body bytes.Buffer
+		if payload != nil {
+			_ = json.NewEncoder(&body).Encode(payload)
+		}
+		req := httptest.NewRequest(method, path, &body)
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req, 5000)
+		if err != nil {
+			t.Fatalf("%s %s failed: %v", method, path, err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("%s %s: expected 200, got %d", method, path, resp.StatusCode)
+		}
+		var memo apiMemo
+		if err := json.NewDecoder(resp.Body).Decode(&memo); err != nil {
+			t.Fatalf("decode memo response failed: %v", err)
+		}
+		return memo
+	}
+
+	memo := request(http.MethodGet, "/api/v1/memos/"+memoID, nil)
+	if fmt.Sprint(memo.Tags) != "[work]" || fmt.Sprint(memo.Collaborators) != "["+readerName+"]" {
+		t.Fatalf("expected collaborator tag hidden from tags, got tags=%v collaborators=%v", memo.Tags, memo.Collaborators)
+	}
+
+	// 客户端按响应回传标签时不会丢失协作者
+	memo = request(http.MethodPatch, "/api/v1/memos/"+memoID, map[string]any{"tags": []string{"work", "later"}})
+	if fmt.Sprint(memo.Tags) != "[later work]" || fmt.Sprint(memo.Collaborators) != "["+readerName+"]" {
+		t.Fatalf("expected collaborator kept after tag update, got tags=%v collaborators=%v", memo.Tags, memo.Collaborators)
+	}
+
+	memo = request(http.MethodDelete, "/api/v1/memos/"+memoID+"/collaborators/"+models.Int64ToString(reader.ID), nil)
+	if len(memo.Collaborators) != 0 {
+		t.Fatalf("expected collaborator removed, got %v", memo.Collaborators)
+	}
+}
@@ -0,0 +1,208 @@
+package http
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestExportMemosNDJSON(t *testing.T) {
+	app := newTestApp(t, true, true)
+	token := "demo-token"
+
+	createMemoForGet(t, app, token, map[string]any{"content": "first", "visibility": "PRIVATE", "tags": []string{"a"}})
+	createMemoForGet(t, app, token, map[string]any{"content": "second", "visibility": "PUBLIC"})
+	archivedID := createMemoForGet(t, app, token, map[string]any{"content": "third", "visibility": "PRIVATE"})
+
+	patchBody, _ := json.Marshal(map[string]any{"state": "ARCHIVED"})
+	patchReq := httptest.NewRequest(http.MethodPatch, "/api/v1/memos/"+archivedID, bytes.NewReader(patchBody))
+	patchReq.Header.Set("Authorization", "Bearer "+token)
+	patchReq.Header.Set("Content-Type", "application/json")
+	patchResp, err := app.Test(patchReq, 5000)
+	if err != nil {
+		t.Fatalf("archive memo request failed: %v", err)
+	}
+	patchResp.Body.Close()
+
+	cases := []struct {
+		query    string
+		status   int
+		contents []string
+	}{
+		{query: "", status: http.StatusOK, contents: []string{"first", "second", "third"}},
+		{query: "?state=ARCHIVED", status: http.StatusOK, contents: []string{"third"}},
+		{query: "?state=DELETED", status: http.StatusBadRequest},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/memos:export"+tc.query, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := app.Test(req, 5000)
+		if err != nil {
+			t.Fatalf("export request failed: %v", err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != tc.status {
+			t.Fatalf("GET export%s: expected %d, got %d body=%s", tc.query, tc.status, resp.StatusCode, string(body))
+		}
+		if tc.status != http.StatusOK {
+			continue
+		}
+		if got := resp.Header.Get("Content-Type"); got != "application/x-ndjson" {
+			t.Fatalf("expected ndjson content type, got %q", got)
+		}
+
+		var lines []map[string]any
+		scanner := bufio.NewScanner(bytes.NewReader(body))
+		for scanner.Scan() {
+			var line map[string]any
+			if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+				t.Fatalf("decode export line %q failed: %v", scanner.Text(), err)
+			}
+			lines = append(lines, line)
+		}
+		if len(lines) != len(tc.contents)+1 {
+			t.Fatalf("GET export%s: expected %d lines, got %d body=%s", tc.query, len(tc.contents)+1, len(lines), string(body))
+		}
+		for i, content := range tc.contents {
+			if lines[i]["type"] != "memo" || lines[i]["content"] != content {
+				t.Fatalf("GET export%s: unexpected line %d: %v", tc.query, i, lines[i])
+			}
+		}
+		summary := lines[len(lines)-1]
+		if summary["type"] != "summary" || summary["count"] != float64(len(tc.contents)) {
+			t.Fatalf("GET export%s: unexpected summary %v", tc.query, summary)
+		}
+	}
+}
+
+func TestImportMemosNDJSON(t *testing.T) {
+	app := newTestApp(t, true, true)
+	token := "demo-token"
+
+	body := strings.Join([]string{
+		`{"type":"memo","content":"imported one","tags":["a"," a ","b"],"visibility":"PUBLIC","createTime":"2024-01-02T03:04:05.123Z"}`,
+		`not json`,
+		``,
+		`{"content":"bad visibility","visibility":"SECRET"}`,
+		`{"content":"archived","state":"ARCHIVED"}`,
+		`{"content":"missing attachment","attachments":["attachments/999999"]}`,
+		`{"type":"summary","count":3}`,
+	}, "\n")
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/memos:import", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	resp, err := app.Test(req, 5000)
+	if err != nil {
+		t.Fatalf("import request failed: %v", err)
+	}
+	var result memoImportResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		t.Fatalf("decode import response failed: %v", err)
+	}
+	resp.Body.Close()
+	if result.Created != 2 || result.Skipped != 3 {
+		t.Fatalf("expected 2 created and 3 skipped, got %+v", result)
+	}
+	skippedLines := make([]int, 0, len(result.Errors))
+	for _, lineErr := range result.Errors {
+		skippedLines = append(skippedLines, lineErr.Line)
+	}
+	if len(skippedLines) != 3 || skippedLines[0] != 2 || skippedLines[1] != 4 || skippedLines[2] != 6 {
+		t.Fatalf("expected skipped lines [2 4 6], got %v", skippedLines)
+	}
+
+	exportReq := httptest.NewRequest(http.MethodGet, "/api/v1/memos:export", nil)
+	exportReq.Header.Set("Authorization", "Bearer "+token)
+	exportResp, err := app.Test(exportReq, 5000)
+	if err != nil {
+		t.Fatalf("export request failed: %v", err)
+	}
+	defer exportResp.Body.Close()
+	scanner := bufio.NewScanner(exportResp.Body)
+	var memos []apiMemoExportLine
+	for scanner.Scan() {
+		var line apiMemoExportLine
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			t.Fatalf("decode export line failed: %v", err)
+		}
+		if line.Type == "memo" {
+			memos = append(memos, line)
+		}
+	}
+	if len(memos) != 2 {
+		t.Fatalf("expected 2 imported memos, got %+v", memos)
+	}
+	first := memos[0]
+	if first.Content != "imported one" || first.Visibility != "PUBLIC" || first.CreateTime != "2024-01-02T03:04:05.123Z" {
+		t.Fatalf("expected imported fields to be preserved, got %+v", first)
+	}
+	if len(first.Tags) != 2 {
+		t.Fatalf("expected normalized tags, got %v", first.Tags)
+	}
+	if memos[1].State != "ARCHIVED" {
+		t.Fatalf("expected archived state to be preserved, got %+v", memos[1])
+	}
+}
+
+func TestExportMemosNDJSON_StreamsAcrossBatches(t *testing.T) {
+	app := newTestApp(t, true, true)
+	token := "demo-token"
+
+	const total = 450
+	lines := make([]string, 0, total)
+	for i := 0; i < total; i++ {
+		lines = append(lines, fmt.Sprintf(`{"type":"memo","content":"bulk %03d","visibility":"PRIVATE"}`, i))
+	}
+	importReq := httptest.NewRequest(http.MethodPost, "/api/v1/memos:import", strings.NewReader(strings.Join(lines, "\n")))
+	importReq.Header.Set("Authorization", "Bearer "+token)
+	importReq.Header.Set("Content-Type", "application/x-ndjson")
+	importResp, err := app.Test(importReq, 10000)
+	if err != nil {
+		t.Fatalf("import request failed: %v", err)
+	}
+	var imported memoImportResponse
+	if err := json.NewDecoder(importResp.Body).Decode(&imported); err != nil {
+		t.Fatalf("decode import response failed: %v", err)
+	}
+	importResp.Body.Close()
+	if imported.Created != total {
+		t.Fatalf("expected %d imported memos, got %+v", total, imported)
+	}
+
+	exportReq := httptest.NewRequest(http.MethodGet, "/api/v1/memos:export", nil)
+	exportReq.Header.Set("Authorization", "Bearer "+token)
+	exportResp, err := app.Test(exportReq, 10000)
+	if err != nil {
+		t.Fatalf("export request failed: %v", err)
+	}
+	defer exportResp.Body.Close()
+	scanner := bufio.NewScanner(exportResp.Body)
+	count := 0
+	var summary apiMemoExportSummary
+	for scanner.Scan() {
+		var line apiMemoExportLine
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			t.Fatalf("decode export line failed: %v", err)
+		}
+		if line.Type == "summary" {
+			if err := json.Unmarshal(scanner.Bytes(), &summary); err != nil {
+				t.Fatalf("decode summary failed: %v", err)
+			}
+			continue
+		}
+		if want := fmt.Sprintf("bulk %03d", count); line.Content != want {
+			t.Fatalf("expected line %d to be %q in id order, got %q", count, want, line.Content)
+		}
+		count++
+	}
+	if count != total || summary.Count != total {
+		t.Fatalf("expected %d exported memos and matching summary, got count=%d summary=%+v", total, count, summary)
+	}
+}
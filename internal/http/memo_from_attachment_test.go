@@ -0,0 +1,110 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/shinyes/keer/internal/service"
+)
+
+func TestCreateMemoFromAttachment(t *testing.T) {
+	app, userService := newTestAppWithUserService(t, true, true)
+	ctx := context.Background()
+	token := "demo-token"
+
+	if _, err := userService.CreateUser(ctx, nil, service.CreateUserInput{
+		Username: "other01",
+		Password: "other-password",
+	}, true); err != nil {
+		t.Fatalf("CreateUser(other01) error = %v", err)
+	}
+	_, otherToken, err := userService.CreateAccessTokenForUser(ctx, "other01", "test")
+	if err != nil {
+		t.Fatalf("CreateAccessTokenForUser(other01) error = %v", err)
+	}
+
+	inline := postJSONForTest(t, app, token, "/api/v1/memos:fromAttachment", map[string]any{
+		"content":    "holiday photo",
+		"visibility": "PRIVATE",
+		"tags":       []string{"travel"},
+		"file": map[string]any{
+			"filename": "photo.jpg",
+			"type":     "image/jpeg",
+			"content":  base64.StdEncoding.EncodeToString(mustJPEGBytes(t, 64, 48)),
+		},
+	})
+	if inline.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(inline.Body)
+		t.Fatalf("expected inline upload 200, got %d body=%s", inline.StatusCode, string(body))
+	}
+	var memo apiMemo
+	if err := json.NewDecoder(inline.Body).Decode(&memo); err != nil {
+		t.Fatalf("decode memo response failed: %v", err)
+	}
+	inline.Body.Close()
+	if memo.Content != "holiday photo" || len(memo.Attachments) != 1 || memo.Attachments[0].Filename != "photo.jpg" {
+		t.Fatalf("expected memo with inline attachment, got %+v", memo)
+	}
+
+	otherResp := postJSONForTest(t, app, otherToken, "/api/v1/attachments", map[string]any{
+		"filename": "secret.txt",
+		"type":     "text/plain",
+		"content":  base64.StdEncoding.EncodeToString([]byte("secret")),
+	})
+	var otherAttachment apiAttachment
+	if err := json.NewDecoder(otherResp.Body).Decode(&otherAttachment); err != nil {
+		t.Fatalf("decode attachment response failed: %v", err)
+	}
+	otherResp.Body.Close()
+
+	cases := []struct {
+		name    string
+		payload map[string]any
+		status  int
+	}{
+		{name: "other user's attachment", payload: map[string]any{"attachment": otherAttachment.Name}, status: http.StatusNotFound},
+		{name: "unknown upload session", payload: map[string]any{"uploadId": "missing"}, status: http.StatusNotFound},
+		{name: "no source", payload: map[string]any{"content": "empty"}, status: http.StatusBadRequest},
+		{name: "two sources", payload: map[string]any{"attachment": otherAttachment.Name, "uploadId": "missing"}, status: http.StatusBadRequest},
+	}
+	for _, tc := range cases {
+		resp := postJSONForTest(t, app, token, "/api/v1/memos:fromAttachment", tc.payload)
+		if resp.StatusCode != tc.status {
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			t.Fatalf("%s: expected %d, got %d body=%s", tc.name, tc.status, resp.StatusCode, string(body))
+		}
+		resp.Body.Close()
+	}
+
+	reuse := postJSONForTest(t, app, otherToken, "/api/v1/memos:fromAttachment", map[string]any{
+		"attachment": otherAttachment.Name,
+		"content":    "my own file",
+	})
+	if reuse.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(reuse.Body)
+		t.Fatalf("expected owner to reuse attachment, got %d body=%s", reuse.StatusCode, string(body))
+	}
+	reuse.Body.Close()
+}
+
+func postJSONForTest(t *testing.T, app *fiber.App, token string, path string, payload any) *http.Response {
+	t.Helper()
+	body, _ := json.Marshal(payload)
+	req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req, 5000)
+	if err != nil {
+		t.Fatalf("POST %s failed: %v", path, err)
+	}
+	return resp
+}
@@ -0,0 +1,96 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/shinyes/keer/internal/service"
+)
+
+func TestGetMemoEndpoint_EnforcesVisibility(t *testing.T) {
+	app, userService := newTestAppWithUserService(t, true, true)
+	ctx := context.Background()
+
+	reader, err := userService.CreateUser(ctx, nil, service.CreateUserInput{
+		Username: "reader01",
+		Password: "reader-password",
+	}, true)
+	if err != nil {
+		t.Fatalf("CreateUser(reader01) error = %v", err)
+	}
+	_, readerToken, err := userService.CreateAccessTokenForUser(ctx, "reader01", "test")
+	if err != nil {
+		t.Fatalf("CreateAccessTokenForUser(reader01) error = %v", err)
+	}
+
+	privateID := createMemoForGet(t, app, "demo-token", map[string]any{"content": "private memo", "visibility": "PRIVATE"})
+	publicID := createMemoForGet(t, app, "demo-token", map[string]any{"content": "public memo", "visibility": "PUBLIC"})
+	sharedID := createMemoForGet(t, app, "demo-token", map[string]any{
+		"content":    "shared memo",
+		"visibility": "PRIVATE",
+		"tags":       []string{fmt.Sprintf("collab/%d", reader.ID)},
+	})
+
+	cases := []struct {
+		token  string
+		id     string
+		status int
+	}{
+		{token: "demo-token", id: privateID, status: http.StatusOK},
+		{token: readerToken, id: privateID, status: http.StatusNotFound},
+		{token: readerToken, id: publicID, status: http.StatusOK},
+		{token: readerToken, id: sharedID, status: http.StatusOK},
+		{token: readerToken, id: "999999", status: http.StatusNotFound},
+		{token: readerToken, id: "abc", status: http.StatusBadRequest},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/memos/"+tc.id, nil)
+		req.Header.Set("Authorization", "Bearer "+tc.token)
+		resp, err := app.Test(req, 5000)
+		if err != nil {
+			t.Fatalf("get memo request failed: %v", err)
+		}
+		if resp.StatusCode != tc.status {
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			t.Fatalf("GET memo %s: expected %d, got %d body=%s", tc.id, tc.status, resp.StatusCode, string(body))
+		}
+		if tc.status == http.StatusOK {
+			var memo apiMemo
+			if err := json.NewDecoder(resp.Body).Decode(&memo); err != nil {
+				t.Fatalf("decode memo response failed: %v", err)
+			}
+			if memo.Name != "memos/"+tc.id {
+				t.Fatalf("expected memo name memos/%s, got %q", tc.id, memo.Name)
+			}
+		}
+		resp.Body.Close()
+	}
+}
+
+func createMemoForGet(t *testing.T, app *fiber.App, token string, payload map[string]any) string {
+	t.Helper()
+	body, _ := json.Marshal(payload)
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/memos", bytes.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req, 5000)
+	if err != nil {
+		t.Fatalf("create memo request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	var created apiMemo
+	if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+		t.Fatalf("decode create memo response failed: %v", err)
+	}
+	return strings.TrimPrefix(created.Name, "memos/")
+}
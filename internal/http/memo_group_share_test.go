@@ -0,0 +1,105 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/shinyes/keer/internal/service"
+)
+
+func TestShareMemoToGroupEndpoints(t *testing.T) {
+	app, userService := newTestAppWithUserService(t, true, true)
+	ctx := context.Background()
+	token := "demo-token"
+
+	member, err := userService.CreateUser(ctx, nil, service.CreateUserInput{
+		Username: "member01",
+		Password: "member-password",
+	}, true)
+	if err != nil {
+		t.Fatalf("CreateUser(member01) error = %v", err)
+	}
+	_, memberToken, err := userService.CreateAccessTokenForUser(ctx, "member01", "test")
+	if err != nil {
+		t.Fatalf("CreateAccessTokenForUser(member01) error = %v", err)
+	}
+
+	groupBody, _ := json.Marshal(createGroupRequest{Name: "team"})
+	groupReq := httptest.NewRequest(http.MethodPost, "/api/v1/groups", bytes.NewReader(groupBody))
+	groupReq.Header.Set("Authorization", "Bearer "+token)
+	groupReq.Header.Set("Content-Type", "application/json")
+	groupResp, err := app.Test(groupReq, 5000)
+	if err != nil {
+		t.Fatalf("create group request failed: %v", err)
+	}
+	var group apiGroup
+	if err := json.NewDecoder(groupResp.Body).Decode(&group); err != nil {
+		t.Fatalf("decode group response failed: %v", err)
+	}
+	groupResp.Body.Close()
+
+	joinReq := httptest.NewRequest(http.MethodPost, "/api/v1/"+group.Name+"/join", nil)
+	joinReq.Header.Set("Authorization", "Bearer "+memberToken)
+	joinResp, err := app.Test(joinReq, 5000)
+	if err != nil {
+		t.Fatalf("join group request failed: %v", err)
+	}
+	joinResp.Body.Close()
+
+	memoID := createMemoForGet(t, app, token, map[string]any{"content": "team memo", "visibility": "PRIVATE"})
+
+	cases := []struct {
+		path   string
+		group  string
+		status int
+	}{
+		{path: "/api/v1/memos/" + memoID + ":shareToGroup", group: group.Name, status: http.StatusOK},
+		{path: "/api/v1/memos/" + memoID + ":resyncGroupShare", group: group.Name, status: http.StatusOK},
+		{path: "/api/v1/memos/" + memoID + ":shareToGroup", group: "groups/abc", status: http.StatusBadRequest},
+		{path: "/api/v1/memos/" + memoID + ":shareToGroup", group: "groups/999999", status: http.StatusNotFound},
+		{path: "/api/v1/memos/999999:shareToGroup", group: group.Name, status: http.StatusNotFound},
+	}
+	for _, tc := range cases {
+		body, _ := json.Marshal(memoGroupShareRequest{Group: tc.group})
+		req := httptest.NewRequest(http.MethodPost, tc.path, bytes.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req, 5000)
+		if err != nil {
+			t.Fatalf("share memo request failed: %v", err)
+		}
+		if resp.StatusCode != tc.status {
+			respBody, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			t.Fatalf("POST %s group=%s: expected %d, got %d body=%s", tc.path, tc.group, tc.status, resp.StatusCode, string(respBody))
+		}
+		if tc.status == http.StatusOK {
+			var memo apiMemo
+			if err := json.NewDecoder(resp.Body).Decode(&memo); err != nil {
+				t.Fatalf("decode memo response failed: %v", err)
+			}
+			if !slices.Contains(memo.Collaborators, fmt.Sprintf("users/%d", member.ID)) {
+				t.Fatalf("expected member collaborator, got %v", memo.Collaborators)
+			}
+		}
+		resp.Body.Close()
+	}
+
+	getReq := httptest.NewRequest(http.MethodGet, "/api/v1/memos/"+memoID, nil)
+	getReq.Header.Set("Authorization", "Bearer "+memberToken)
+	getResp, err := app.Test(getReq, 5000)
+	if err != nil {
+		t.Fatalf("get memo request failed: %v", err)
+	}
+	getResp.Body.Close()
+	if getResp.StatusCode != http.StatusOK {
+		t.Fatalf("expected group member to read shared memo, got %d", getResp.StatusCode)
+	}
+}
@@ -0,0 +1,82 @@
+package http
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPublicMemoEndpoints_AnonymousSeesOnlyPublic(t *testing.T) {
+	app, _ := newTestAppWithUserService(t, true, true)
+
+	publicID := createMemoForGet(t, app, "demo-token", map[string]any{"content": "public memo", "visibility": "PUBLIC"})
+	protectedID := createMemoForGet(t, app, "demo-token", map[string]any{"content": "protected memo", "visibility": "PROTECTED"})
+	privateID := createMemoForGet(t, app, "demo-token", map[string]any{"content": "private memo", "visibility": "PRIVATE"})
+
+	listNames := func(token string) (int, []string) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/public/users/demo/memos", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := app.Test(req, 5000)
+		if err != nil {
+			t.Fatalf("list public memos request failed: %v", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return resp.StatusCode, nil
+		}
+		var out listMemosResponse
+		if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+			t.Fatalf("decode list response failed: %v", err)
+		}
+		names := make([]string, 0, len(out.Memos))
+		for _, memo := range out.Memos {
+			names = append(names, memo.Name)
+		}
+		return resp.StatusCode, names
+	}
+
+	if status, names := listNames(""); status != http.StatusOK || len(names) != 1 || names[0] != "memos/"+publicID {
+		t.Fatalf("anonymous list: expected only memos/%s, got status=%d names=%v", publicID, status, names)
+	}
+	if status, names := listNames("demo-token"); status != http.StatusOK || len(names) != 3 {
+		t.Fatalf("owner list: expected 3 memos, got status=%d names=%v", status, names)
+	}
+	if status, _ := listNames("bad-token"); status != http.StatusUnauthorized {
+		t.Fatalf("invalid token list: expected 401, got %d", status)
+	}
+
+	cases := []struct {
+		id     string
+		status int
+	}{
+		{id: publicID, status: http.StatusOK},
+		{id: protectedID, status: http.StatusNotFound},
+		{id: privateID, status: http.StatusNotFound},
+	}
+	for _, tc := range cases {
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/api/v1/public/memos/"+tc.id, nil), 5000)
+		if err != nil {
+			t.Fatalf("get public memo request failed: %v", err)
+		}
+		if resp.StatusCode != tc.status {
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			t.Fatalf("GET public memo %s: expected %d, got %d body=%s", tc.id, tc.status, resp.StatusCode, string(body))
+		}
+		resp.Body.Close()
+	}
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/api/v1/memos/"+publicID, nil), 5000)
+	if err != nil {
+		t.Fatalf("get memo request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected authenticated memo endpoint to still require auth, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,67 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestListMemosSearchParameter(t *testing.T) {
+	app := newTestApp(t, true, true)
+	token := "demo-token"
+
+	for _, content := range []string{"buy milk", "write todo list", "todolist for tomorrow"} {
+		body, _ := json.Marshal(map[string]any{
+			"content":    content,
+			"visibility": "PRIVATE",
+		})
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/memos", bytes.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req, 5000)
+		if err != nil {
+			t.Fatalf("create memo request failed: %v", err)
+		}
+		resp.Body.Close()
+	}
+
+	cases := []struct {
+		query  url.Values
+		status int
+		count  int
+	}{
+		{query: url.Values{"search": {"todo*"}}, status: http.StatusOK, count: 2},
+		{query: url.Values{"search": {"milk OR todo"}}, status: http.StatusOK, count: 0},
+		{query: url.Values{"search": {"milk OR todo"}, "searchMode": {"advanced"}}, status: http.StatusOK, count: 2},
+		{query: url.Values{"search": {"milk OR"}, "searchMode": {"advanced"}}, status: http.StatusBadRequest},
+		{query: url.Values{"search": {"milk"}, "searchMode": {"fuzzy"}}, status: http.StatusBadRequest},
+		{query: url.Values{"search": {"milk"}, "filter": {`"a" in tags`}}, status: http.StatusBadRequest},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/memos?"+tc.query.Encode(), nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := app.Test(req, 5000)
+		if err != nil {
+			t.Fatalf("list memos request failed: %v", err)
+		}
+		if resp.StatusCode != tc.status {
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			t.Fatalf("GET %s: expected %d, got %d body=%s", tc.query.Encode(), tc.status, resp.StatusCode, string(body))
+		}
+		if tc.status == http.StatusOK {
+			var out listMemosResponse
+			if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+				t.Fatalf("decode list memos response failed: %v", err)
+			}
+			if len(out.Memos) != tc.count {
+				t.Fatalf("GET %s: expected %d memos, got %d", tc.query.Encode(), tc.count, len(out.Memos))
+			}
+		}
+		resp.Body.Close()
+	}
+}
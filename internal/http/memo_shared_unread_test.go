@@ -0,0 +1,90 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/shinyes/keer/internal/service"
+)
+
+func TestSharedUnreadCount(t *testing.T) {
+	app, userService := newTestAppWithUserService(t, true, true)
+	ctx := context.Background()
+	token := "demo-token"
+
+	viewer, err := userService.CreateUser(ctx, nil, service.CreateUserInput{
+		Username: "viewer01",
+		Password: "viewer-password",
+	}, true)
+	if err != nil {
+		t.Fatalf("CreateUser(viewer01) error = %v", err)
+	}
+	_, viewerToken, err := userService.CreateAccessTokenForUser(ctx, "viewer01", "test")
+	if err != nil {
+		t.Fatalf("CreateAccessTokenForUser(viewer01) error = %v", err)
+	}
+	collaboratorTag := fmt.Sprintf("collab/%d", viewer.ID)
+
+	sharedID := createMemoForGet(t, app, token, map[string]any{"content": "shared", "visibility": "PRIVATE", "tags": []string{collaboratorTag}})
+	createMemoForGet(t, app, token, map[string]any{"content": "not shared", "visibility": "PRIVATE"})
+	createMemoForGet(t, app, viewerToken, map[string]any{"content": "own memo", "visibility": "PRIVATE", "tags": []string{collaboratorTag}})
+
+	if got := sharedUnreadCountForTest(t, app, viewerToken); got.Count != 1 || got.LastSeenTime != "" {
+		t.Fatalf("expected 1 unread shared memo before first mark, got %+v", got)
+	}
+
+	markResp := postJSONForTest(t, app, viewerToken, "/api/v1/memos:markSharedSeen", map[string]any{})
+	var marked markSharedSeenResponse
+	if err := json.NewDecoder(markResp.Body).Decode(&marked); err != nil {
+		t.Fatalf("decode mark seen response failed: %v", err)
+	}
+	markResp.Body.Close()
+	if markResp.StatusCode != http.StatusOK || marked.LastSeenTime == "" {
+		t.Fatalf("expected mark seen to return lastSeenTime, got %d %+v", markResp.StatusCode, marked)
+	}
+	if got := sharedUnreadCountForTest(t, app, viewerToken); got.Count != 0 || got.LastSeenTime != marked.LastSeenTime {
+		t.Fatalf("expected no unread shared memos after mark, got %+v", got)
+	}
+
+	patchBody, _ := json.Marshal(map[string]any{"content": "shared and edited"})
+	patchReq := httptest.NewRequest(http.MethodPatch, "/api/v1/memos/"+sharedID, bytes.NewReader(patchBody))
+	patchReq.Header.Set("Authorization", "Bearer "+token)
+	patchReq.Header.Set("Content-Type", "application/json")
+	patchResp, err := app.Test(patchReq, 5000)
+	if err != nil {
+		t.Fatalf("patch memo request failed: %v", err)
+	}
+	patchResp.Body.Close()
+	if got := sharedUnreadCountForTest(t, app, viewerToken); got.Count != 1 {
+		t.Fatalf("expected edited shared memo to be unread again, got %+v", got)
+	}
+	if got := sharedUnreadCountForTest(t, app, token); got.Count != 0 {
+		t.Fatalf("expected owner to have no shared memos, got %+v", got)
+	}
+}
+
+func sharedUnreadCountForTest(t *testing.T, app *fiber.App, token string) sharedUnreadCountResponse {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/memos:sharedUnreadCount", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := app.Test(req, 5000)
+	if err != nil {
+		t.Fatalf("shared unread count request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected shared unread count 200, got %d", resp.StatusCode)
+	}
+	var result sharedUnreadCountResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		t.Fatalf("decode shared unread count failed: %v", err)
+	}
+	return result
+}
@@ -0,0 +1,69 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestPinMemoForTagEndpoints(t *testing.T) {
+	app := newTestApp(t, true, true)
+	token := "demo-token"
+
+	createBody, _ := json.Marshal(map[string]any{
+		"content":    "memo pinned for a tag",
+		"visibility": "PRIVATE",
+		"tags":       []string{"book"},
+	})
+	createReq := httptest.NewRequest(http.MethodPost, "/api/v1/memos", bytes.NewReader(createBody))
+	createReq.Header.Set("Authorization", "Bearer "+token)
+	createReq.Header.Set("Content-Type", "application/json")
+	createResp, err := app.Test(createReq, 5000)
+	if err != nil {
+		t.Fatalf("create memo request failed: %v", err)
+	}
+	defer createResp.Body.Close()
+	var created apiMemo
+	if err := json.NewDecoder(createResp.Body).Decode(&created); err != nil {
+		t.Fatalf("decode create memo response failed: %v", err)
+	}
+	memoID := strings.TrimPrefix(created.Name, "memos/")
+
+	cases := []struct {
+		path   string
+		tag    string
+		status int
+	}{
+		{path: "/api/v1/memos/" + memoID + ":pinForTag", tag: "book", status: http.StatusOK},
+		{path: "/api/v1/memos/" + memoID + ":pinForTag", tag: "travel", status: http.StatusBadRequest},
+		{path: "/api/v1/memos/" + memoID + ":unpinForTag", tag: "book", status: http.StatusOK},
+		{path: "/api/v1/memos/999999:pinForTag", tag: "book", status: http.StatusNotFound},
+	}
+	for _, tc := range cases {
+		resp := postMemoTagPin(t, app, token, tc.path, tc.tag)
+		if resp.StatusCode != tc.status {
+			body, _ := io.ReadAll(resp.Body)
+			t.Fatalf("POST %s tag=%s: expected %d, got %d body=%s", tc.path, tc.tag, tc.status, resp.StatusCode, string(body))
+		}
+		resp.Body.Close()
+	}
+}
+
+func postMemoTagPin(t *testing.T, app *fiber.App, token string, path string, tag string) *http.Response {
+	t.Helper()
+	body, _ := json.Marshal(memoTagPinRequest{Tag: tag})
+	req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req, 5000)
+	if err != nil {
+		t.Fatalf("memo tag pin request failed: %v", err)
+	}
+	return resp
+}
@@ -0,0 +1,53 @@
+package http
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestValidateMemoFilter(t *testing.T) {
+	app := newTestApp(t, true, true)
+	token := "demo-token"
+
+	validate := func(filter string) (int, validateMemoFilterResponse, string) {
+		resp := postJSONForTest(t, app, token, "/api/v1/memos:validateFilter", map[string]any{"filter": filter})
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		var out validateMemoFilterResponse
+		if resp.StatusCode == http.StatusOK {
+			if err := json.Unmarshal(body, &out); err != nil {
+				t.Fatalf("decode validate response failed: %v body=%s", err, body)
+			}
+		}
+		return resp.StatusCode, out, string(body)
+	}
+
+	status, out, body := validate(`visibility == "PUBLIC" && "work" in tags`)
+	if status != http.StatusOK || !out.FullyPushedDown {
+		t.Fatalf("expected pushdown-able filter, got %d body=%s", status, body)
+	}
+	if len(out.Prefilter.Visibilities) != 1 || out.Prefilter.Visibilities[0] != "PUBLIC" {
+		t.Fatalf("expected PUBLIC visibility in prefilter, got %+v", out.Prefilter)
+	}
+	if len(out.Prefilter.TagGroups) != 1 || out.Prefilter.TagGroups[0][0] != (apiTagMatch{Kind: "exact", Value: "work"}) {
+		t.Fatalf("expected exact work tag group, got %+v", out.Prefilter.TagGroups)
+	}
+
+	status, out, body = validate(`content.contains("todo")`)
+	if status != http.StatusOK || out.FullyPushedDown {
+		t.Fatalf("expected content filter to need in-memory evaluation, got %d body=%s", status, body)
+	}
+
+	status, out, body = validate("")
+	if status != http.StatusOK || !out.FullyPushedDown {
+		t.Fatalf("expected empty filter to be valid, got %d body=%s", status, body)
+	}
+
+	status, _, body = validate(`visibility ==`)
+	if status != http.StatusBadRequest || !strings.Contains(body, "invalid CEL filter") {
+		t.Fatalf("expected compile error 400, got %d body=%s", status, body)
+	}
+}
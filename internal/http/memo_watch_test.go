@@ -0,0 +1,105 @@
+package http
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWatchMemosStreamsChanges(t *testing.T) {
+	app := newTestApp(t, true, true)
+	token := "demo-token"
+
+	since := time.Now().UTC().Format(time.RFC3339Nano)
+	// 监听启动后 app.Test 与 Serve 共用同一个 fasthttp.Server，之后的请求都走真实连接
+	first := createMemoWithCoordinates(t, app, token, 40.7128, -74.0060)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+	go func() { _ = app.Listener(listener) }()
+	// 直接关闭监听器：Shutdown 会等待仍在推送的长连接结束
+	t.Cleanup(func() { _ = listener.Close() })
+
+	baseURL := "http://" + listener.Addr().String()
+
+	req, err := http.NewRequest(http.MethodGet, baseURL+"/api/v1/memos:watch?since="+url.QueryEscape(since), nil)
+	if err != nil {
+		t.Fatalf("build watch request failed: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("watch request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		t.Fatalf("expected event stream, got %d %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	events := make(chan listMemoChangesResponse)
+	go func() {
+		defer close(events)
+		reader := bufio.NewReader(resp.Body)
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			data, ok := strings.CutPrefix(strings.TrimRight(line, "\n"), "data: ")
+			if !ok {
+				continue
+			}
+			var changes listMemoChangesResponse
+			if err := json.Unmarshal([]byte(data), &changes); err != nil {
+				return
+			}
+			events <- changes
+		}
+	}()
+	next := func() listMemoChangesResponse {
+		t.Helper()
+		select {
+		case changes, ok := <-events:
+			if !ok {
+				t.Fatalf("event stream closed unexpectedly")
+			}
+			return changes
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for memo watch event")
+		}
+		return listMemoChangesResponse{}
+	}
+
+	catchUp := next()
+	if len(catchUp.Memos) != 1 || catchUp.Memos[0].Name != first.Name || catchUp.SyncAnchor == "" {
+		t.Fatalf("expected catch-up event with the first memo, got %+v", catchUp)
+	}
+
+	createReq, err := http.NewRequest(http.MethodPost, baseURL+"/api/v1/memos", strings.NewReader(`{"content":"live memo","visibility":"PRIVATE"}`))
+	if err != nil {
+		t.Fatalf("build create request failed: %v", err)
+	}
+	createReq.Header.Set("Authorization", "Bearer "+token)
+	createReq.Header.Set("Content-Type", "application/json")
+	createResp, err := http.DefaultClient.Do(createReq)
+	if err != nil {
+		t.Fatalf("create memo request failed: %v", err)
+	}
+	defer createResp.Body.Close()
+	var second apiMemo
+	if err := json.NewDecoder(createResp.Body).Decode(&second); err != nil || second.Name == "" {
+		t.Fatalf("decode create memo response failed: status=%d err=%v", createResp.StatusCode, err)
+	}
+
+	live := next()
+	if len(live.Memos) != 1 || live.Memos[0].Name != second.Name {
+		t.Fatalf("expected live event with the new memo, got %+v", live)
+	}
+}
@@ -0,0 +1,92 @@
+package http
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// tokenBucketLimiter 为每个 key 维护一个令牌桶：容量为 limit，每个 window 补满 limit 个令牌。
+// 空闲超过一个 window 的桶已经补满，与新建桶等价，会在定期清理时移除以避免内存增长。
+type tokenBucketLimiter struct {
+	limit     float64
+	window    time.Duration
+	buckets   sync.Map
+	sweepMu   sync.Mutex
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+type tokenBucket struct {
+	mu       sync.Mutex
+	tokens   float64
+	lastSeen time.Time
+	// evicted 在持有 mu 时由 sweep 设置；拿到已被清理的桶的调用方需要重新获取，避免这次尝试记在被丢弃的桶上。
+	evicted bool
+}
+
+func newTokenBucketLimiter(limit int, window time.Duration) *tokenBucketLimiter {
+	if limit <= 0 || window <= 0 {
+		return nil
+	}
+	return &tokenBucketLimiter{
+		limit:  float64(limit),
+		window: window,
+		now:    time.Now,
+	}
+}
+
+// allow 尝试为 key 消耗一个令牌；被拒绝时返回需要等待的时长。
+func (l *tokenBucketLimiter) allow(key string) (bool, time.Duration) {
+	if l == nil {
+		return true, 0
+	}
+	now := l.now()
+	l.sweep(now)
+
+	var bucket *tokenBucket
+	for {
+		raw, _ := l.buckets.LoadOrStore(key, &tokenBucket{tokens: l.limit, lastSeen: now})
+		bucket = raw.(*tokenBucket)
+		bucket.mu.Lock()
+		if !bucket.evicted {
+			break
+		}
+		bucket.mu.Unlock()
+	}
+	defer bucket.mu.Unlock()
+
+	refillPerSecond := l.limit / l.window.Seconds()
+	elapsed := now.Sub(bucket.lastSeen).Seconds()
+	if elapsed > 0 {
+		bucket.tokens = math.Min(l.limit, bucket.tokens+elapsed*refillPerSecond)
+	}
+	bucket.lastSeen = now
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		return true, 0
+	}
+	wait := time.Duration((1 - bucket.tokens) / refillPerSecond * float64(time.Second))
+	return false, wait
+}
+
+func (l *tokenBucketLimiter) sweep(now time.Time) {
+	l.sweepMu.Lock()
+	if now.Sub(l.lastSweep) < l.window {
+		l.sweepMu.Unlock()
+		return
+	}
+	l.lastSweep = now
+	l.sweepMu.Unlock()
+
+	l.buckets.Range(func(key, value any) bool {
+		bucket := value.(*tokenBucket)
+		bucket.mu.Lock()
+		if now.Sub(bucket.lastSeen) >= l.window {
+			bucket.evicted = true
+			l.buckets.CompareAndDelete(key, bucket)
+		}
+		bucket.mu.Unlock()
+		return true
+	})
+}
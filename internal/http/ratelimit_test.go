@@ -0,0 +1,142 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/shinyes/keer/internal/config"
+)
+
+func TestSignInRateLimit(t *testing.T) {
+	app, _ := newTestAppWithConfig(t, config.Config{
+		KeerAPIVersion:   "0.1",
+		SignInRateLimit:  3,
+		SignInRateWindow: time.Minute,
+	}, true)
+
+	for i, username := range []string{"demo", "someone", "another", "demo"} {
+		resp := postJSONForTest(t, app, "", "/api/v1/auth/signin", map[string]any{
+			"passwordCredentials": map[string]any{"username": username, "password": "wrong-password"},
+		})
+		resp.Body.Close()
+		if i < 3 {
+			if resp.StatusCode != http.StatusBadRequest {
+				t.Fatalf("attempt %d: expected 400, got %d", i+1, resp.StatusCode)
+			}
+			continue
+		}
+		if resp.StatusCode != http.StatusTooManyRequests {
+			t.Fatalf("attempt %d: expected 429, got %d", i+1, resp.StatusCode)
+		}
+		if got := resp.Header.Get("Retry-After"); got != "20" {
+			t.Fatalf("expected Retry-After 20, got %q", got)
+		}
+	}
+}
+
+func signInFromForTest(t *testing.T, app *fiber.App, forwardedFor string, username string) int {
+	t.Helper()
+	body, _ := json.Marshal(map[string]any{
+		"passwordCredentials": map[string]any{"username": username, "password": "wrong-password"},
+	})
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/auth/signin", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Forwarded-For", forwardedFor)
+	resp, err := app.Test(req, 5000)
+	if err != nil {
+		t.Fatalf("signin request failed: %v", err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestSignInRateLimit_IgnoresForwardedForWithoutTrustedProxies(t *testing.T) {
+	app, _ := newTestAppWithConfig(t, config.Config{
+		KeerAPIVersion:   "0.1",
+		SignInRateLimit:  2,
+		SignInRateWindow: time.Minute,
+	}, true)
+
+	// 伪造的转发头不能让同一连接换一个 IP 计数
+	for i, forwardedFor := range []string{"203.0.113.1", "203.0.113.2", "203.0.113.3"} {
+		want := http.StatusBadRequest
+		if i == 2 {
+			want = http.StatusTooManyRequests
+		}
+		if status := signInFromForTest(t, app, forwardedFor, "demo"); status != want {
+			t.Fatalf("attempt %d: expected %d, got %d", i+1, want, status)
+		}
+	}
+}
+
+func TestSignInRateLimit_TrustedProxyLimitsPerClientAndUsername(t *testing.T) {
+	app, _ := newTestAppWithConfig(t, config.Config{
+		KeerAPIVersion:   "0.1",
+		SignInRateLimit:  2,
+		SignInRateWindow: time.Minute,
+		TrustedProxies:   []string{"0.0.0.0"},
+		ProxyHeader:      "X-Forwarded-For",
+	}, true)
+
+	for i := 0; i < 3; i++ {
+		want := http.StatusBadRequest
+		if i == 2 {
+			want = http.StatusTooManyRequests
+		}
+		if status := signInFromForTest(t, app, "203.0.113.1", "demo"); status != want {
+			t.Fatalf("attempt %d from attacker: expected %d, got %d", i+1, want, status)
+		}
+	}
+	if status := signInFromForTest(t, app, "198.51.100.7", "demo"); status != http.StatusBadRequest {
+		t.Fatalf("expected another client to keep its own bucket for the same username, got %d", status)
+	}
+}
+
+func TestTokenBucketLimiter_RefillsAndEvictsIdleBuckets(t *testing.T) {
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	limiter := newTokenBucketLimiter(2, time.Minute)
+	limiter.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		if allowed, _ := limiter.allow("user:demo"); !allowed {
+			t.Fatalf("attempt %d: expected allowed", i+1)
+		}
+	}
+	allowed, wait := limiter.allow("user:demo")
+	if allowed || wait != 30*time.Second {
+		t.Fatalf("expected third attempt rejected with 30s wait, got allowed=%v wait=%s", allowed, wait)
+	}
+	if allowed, _ := limiter.allow("user:other"); !allowed {
+		t.Fatalf("expected separate key to have its own bucket")
+	}
+
+	now = now.Add(30 * time.Second)
+	if allowed, _ := limiter.allow("user:demo"); !allowed {
+		t.Fatalf("expected one token refilled after 30s")
+	}
+
+	now = now.Add(2 * time.Minute)
+	limiter.allow("ip:fresh")
+	count := 0
+	limiter.buckets.Range(func(key, _ any) bool {
+		count++
+		return true
+	})
+	if count != 1 {
+		t.Fatalf("expected idle buckets evicted, %d buckets left", count)
+	}
+}
+
+func TestNewTokenBucketLimiter_DisabledAllowsAll(t *testing.T) {
+	limiter := newTokenBucketLimiter(0, time.Minute)
+	for i := 0; i < 100; i++ {
+		if allowed, _ := limiter.allow("ip:0.0.0.0"); !allowed {
+			t.Fatalf("expected disabled limiter to allow attempt %d", i+1)
+		}
+	}
+}
@@ -354,6 +354,14 @@ func newTestApp(t *testing.T, allowRegistration bool, withBootstrap bool) *fiber
 }
 
 func newTestAppWithUserService(t *testing.T, allowRegistration bool, withBootstrap bool) (*fiber.App, *service.UserService) {
+	t.Helper()
+	return newTestAppWithConfig(t, config.Config{
+		KeerAPIVersion:    "0.1",
+		AllowRegistration: allowRegistration,
+	}, withBootstrap)
+}
+
+func newTestAppWithConfig(t *testing.T, cfg config.Config, withBootstrap bool) (*fiber.App, *service.UserService) {
 	t.Helper()
 	dbPath := filepath.Join(t.TempDir(), "http_test.db")
 	sqliteDB, err := db.OpenSQLite(dbPath)
@@ -375,16 +383,16 @@ func newTestAppWithUserService(t *testing.T, allowRegistration bool, withBootstr
 		}
 	}
 	memoService := service.NewMemoService(sqlStore)
+	memoService.SetMaxAttachmentsPerMemo(cfg.MaxAttachmentsPerMemo)
 	groupService := service.NewGroupService(sqlStore)
 	localStore, err := storage.NewLocalStore(filepath.Join(t.TempDir(), "uploads"))
 	if err != nil {
 		t.Fatalf("NewLocalStore() error = %v", err)
 	}
-	attachmentService := service.NewAttachmentService(sqlStore, localStore)
+	attachmentService := service.NewAttachmentService(sqlStore, localStore, 0)
+	attachmentService.SetSizeLimitsByType(cfg.AttachmentSizeLimitsByType)
+	attachmentService.SetThumbnailsDisabled(cfg.DisableThumbnails)
+	attachmentService.SetUserStorageQuota(cfg.UserStorageQuota)
 
-	cfg := config.Config{
-		KeerAPIVersion:    "0.1",
-		AllowRegistration: allowRegistration,
-	}
-	return NewRouter(cfg, userService, memoService, groupService, attachmentService), userService
+	return NewRouter(cfg, userService, service.NewUserSettingsService(sqlStore), memoService, groupService, attachmentService), userService
 }
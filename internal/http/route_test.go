@@ -2,13 +2,24 @@ package http
 
 import (
 	"bytes"
+	"encoding/json"
 	"io"
 	"log"
+	"net/http"
 	"net/http/httptest"
+	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
+
+	"github.com/shinyes/keer/internal/config"
+	"github.com/shinyes/keer/internal/db"
+	"github.com/shinyes/keer/internal/service"
+	"github.com/shinyes/keer/internal/storage"
+	"github.com/shinyes/keer/internal/store"
 )
 
 func TestFiberUserStatsRoutePattern(t *testing.T) {
@@ -146,6 +157,65 @@ func TestParseSingleByteRange(t *testing.T) {
 	}
 }
 
+func TestParseMultiByteRanges(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		size    int64
+		want    []byteRange
+		wantErr bool
+	}{
+		{
+			name: "two ranges",
+			raw:  "bytes=0-9, 20-29",
+			size: 100,
+			want: []byteRange{{start: 0, end: 9}, {start: 20, end: 29}},
+		},
+		{
+			name: "suffix and clamped end",
+			raw:  "bytes=0-0,-5,90-200",
+			size: 100,
+			want: []byteRange{{start: 0, end: 0}, {start: 95, end: 99}, {start: 90, end: 99}},
+		},
+		{
+			name: "unsatisfiable range skipped",
+			raw:  "bytes=0-1,500-600",
+			size: 100,
+			want: []byteRange{{start: 0, end: 1}},
+		},
+		{
+			name:    "all ranges unsatisfiable",
+			raw:     "bytes=100-,200-300",
+			size:    100,
+			wantErr: true,
+		},
+		{
+			name:    "malformed range",
+			raw:     "bytes=0-1,abc",
+			size:    100,
+			wantErr: true,
+		},
+		{
+			name: "overlapping ranges exceed size",
+			raw:  "bytes=0-99,0-99",
+			size: 100,
+			want: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseMultiByteRanges(tt.raw, tt.size)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("ranges = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestHTTPAccessLogMiddleware(t *testing.T) {
 	var logBuffer bytes.Buffer
 	previousWriter := log.Writer()
@@ -181,3 +251,89 @@ func TestHTTPAccessLogMiddleware(t *testing.T) {
 		t.Fatalf("log missing status, got %q", logLine)
 	}
 }
+
+func TestHealthzEndpoint(t *testing.T) {
+	var logBuffer bytes.Buffer
+	previousWriter := log.Writer()
+	log.SetOutput(&logBuffer)
+	t.Cleanup(func() {
+		log.SetOutput(previousWriter)
+	})
+
+	app := newTestApp(t, true, false)
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/healthz", nil), 5000)
+	if err != nil {
+		t.Fatalf("healthz request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected healthz 200, got %d", resp.StatusCode)
+	}
+	var health healthResponse
+	if err := json.NewDecoder(resp.Body).Decode(&health); err != nil {
+		t.Fatalf("decode healthz response failed: %v", err)
+	}
+	if health.Status != "ok" {
+		t.Fatalf("expected status ok, got %q", health.Status)
+	}
+	if strings.Contains(logBuffer.String(), "path=/healthz") {
+		t.Fatalf("expected healthz to be excluded from access log, got %q", logBuffer.String())
+	}
+
+	sqliteDB, err := db.OpenSQLite(filepath.Join(t.TempDir(), "closed.db"))
+	if err != nil {
+		t.Fatalf("OpenSQLite() error = %v", err)
+	}
+	sqlStore := store.New(sqliteDB)
+	localStore, err := storage.NewLocalStore(filepath.Join(t.TempDir(), "uploads"))
+	if err != nil {
+		t.Fatalf("NewLocalStore() error = %v", err)
+	}
+	_ = sqliteDB.Close()
+	brokenApp := NewRouter(
+		config.Config{},
+		service.NewUserService(sqlStore),
+		service.NewUserSettingsService(sqlStore),
+		service.NewMemoService(sqlStore),
+		service.NewGroupService(sqlStore),
+		service.NewAttachmentService(sqlStore, localStore, 0),
+	)
+	brokenResp, err := brokenApp.Test(httptest.NewRequest(http.MethodGet, "/healthz", nil), 5000)
+	if err != nil {
+		t.Fatalf("healthz request failed: %v", err)
+	}
+	defer brokenResp.Body.Close()
+	if brokenResp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("expected healthz 503 with closed database, got %d", brokenResp.StatusCode)
+	}
+	if err := json.NewDecoder(brokenResp.Body).Decode(&health); err != nil {
+		t.Fatalf("decode healthz response failed: %v", err)
+	}
+	if health.Error != "database_unavailable" {
+		t.Fatalf("expected database_unavailable category, got %q", health.Error)
+	}
+}
+
+func TestInstanceTimeEndpoint(t *testing.T) {
+	app := newTestApp(t, true, false)
+	before := time.Now().UTC()
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/api/v1/instance/time", nil), 5000)
+	if err != nil {
+		t.Fatalf("instance time request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected instance time 200 without auth, got %d", resp.StatusCode)
+	}
+	var body serverTimeResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatalf("decode instance time response failed: %v", err)
+	}
+	serverTime, err := time.Parse(time.RFC3339Nano, body.Time)
+	if err != nil {
+		t.Fatalf("expected RFC3339Nano time, got %q: %v", body.Time, err)
+	}
+	if serverTime.Before(before) || serverTime.After(time.Now().UTC()) || !strings.HasSuffix(body.Time, "Z") {
+		t.Fatalf("unexpected server time %q", body.Time)
+	}
+}
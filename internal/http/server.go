@@ -1,11 +1,19 @@
 package http
 
 import (
+	"bufio"
+	"bytes"
+	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"log"
+	"math"
 	"mime"
+	"mime/multipart"
+	"net/url"
 	"os"
 	"strconv"
 	"strings"
@@ -20,11 +28,20 @@ import (
 	"github.com/shinyes/keer/internal/config"
 	"github.com/shinyes/keer/internal/models"
 	"github.com/shinyes/keer/internal/service"
+	"github.com/shinyes/keer/internal/store"
+)
+
+const (
+	healthCheckPath    = "/healthz"
+	healthCheckTimeout = 2 * time.Second
+	// uploadChecksumHeader 为完成上传会话时可选的内容摘要头，值为十六进制 SHA-256。
+	uploadChecksumHeader = "X-Content-SHA256"
 )
 
 func NewRouter(
 	cfg config.Config,
 	userService *service.UserService,
+	userSettingsService *service.UserSettingsService,
 	memoService *service.MemoService,
 	groupService *service.GroupService,
 	attachmentService *service.AttachmentService,
@@ -33,9 +50,20 @@ func NewRouter(
 	if bodyLimit <= 0 {
 		bodyLimit = 64 * 1024 * 1024
 	}
-	app := fiber.New(fiber.Config{
-		BodyLimit: bodyLimit,
-	})
+	fiberConfig := fiber.Config{
+		BodyLimit:    bodyLimit,
+		ReadTimeout:  cfg.ReadTimeout,
+		WriteTimeout: cfg.WriteTimeout,
+		IdleTimeout:  cfg.IdleTimeout,
+	}
+	if len(cfg.TrustedProxies) > 0 {
+		// 只信任来自可信代理的转发头，c.IP() 取头中第一个合法 IP；其他来源仍用连接对端地址
+		fiberConfig.ProxyHeader = cfg.ProxyHeader
+		fiberConfig.EnableTrustedProxyCheck = true
+		fiberConfig.TrustedProxies = cfg.TrustedProxies
+		fiberConfig.EnableIPValidation = true
+	}
+	app := fiber.New(fiberConfig)
 	app.Use(recover.New())
 	app.Use(requestid.New(requestid.Config{
 		Header: "X-Request-ID",
@@ -47,12 +75,19 @@ func NewRouter(
 	app.Use(compress.New(compress.Config{
 		Level: compress.LevelBestSpeed,
 		Next: func(c *fiber.Ctx) bool {
-			return strings.HasPrefix(c.Path(), "/file/")
+			// SSE 需要逐条推送，压缩会把事件攒在缓冲区里
+			return strings.HasPrefix(c.Path(), "/file/") || c.Path() == "/api/v1/memos:watch"
 		},
 	}))
 
 	buildAPIAttachment := func(attachment models.Attachment, memoName string) apiAttachment {
-		return toAPIAttachment(attachment, memoName, "", "")
+		resp := toAPIAttachment(attachment, memoName, "", "")
+		if attachmentService.ServesOriginalAsThumbnail(attachment) {
+			resp.ThumbnailName = "attachments/" + models.Int64ToString(attachment.ID) + "/thumbnail"
+			resp.ThumbnailFilename = attachment.Filename
+			resp.ThumbnailType = attachment.Type
+		}
+		return resp
 	}
 
 	buildAPIMemo := func(memo service.MemoWithAttachments) apiMemo {
@@ -61,13 +96,39 @@ func NewRouter(
 		})
 	}
 
+	app.Get(healthCheckPath, func(c *fiber.Ctx) error {
+		ctx, cancel := context.WithTimeout(c.Context(), healthCheckTimeout)
+		defer cancel()
+		if err := userService.CheckDatabase(ctx); err != nil {
+			log.Printf("health check failed request_id=%s err=%v", requestID(c), err)
+			return c.Status(fiber.StatusServiceUnavailable).JSON(healthResponse{
+				Status: "unavailable",
+				Error:  healthErrorCategory(err),
+			})
+		}
+		return c.JSON(healthResponse{Status: "ok"})
+	})
+
 	app.Get("/api/v1/instance/profile", func(c *fiber.Ctx) error {
 		return c.JSON(profileResponse{
-			KeerAPIVersion: cfg.KeerAPIVersion,
+			KeerAPIVersion:          cfg.KeerAPIVersion,
+			AttachmentSizeLimits:    cfg.AttachmentSizeLimitsByType,
+			InlineAttachmentMaxSize: cfg.InlineAttachmentMaxSize,
 		})
 	})
 
+	// 客户端据此估算本地时钟偏差，避免用偏差的本地时间作为同步起点
+	app.Get("/api/v1/instance/time", func(c *fiber.Ctx) error {
+		return c.JSON(serverTimeResponse{Time: time.Now().UTC().Format(time.RFC3339Nano)})
+	})
+
+	signInLimiter := newTokenBucketLimiter(cfg.SignInRateLimit, cfg.SignInRateWindow)
+	heavyLimiter := newConcurrencyLimiter(cfg.MaxHeavyOperations)
 	app.Post("/api/v1/auth/signin", func(c *fiber.Ctx) error {
+		clientIP := c.IP()
+		if allowed, wait := signInLimiter.allow("ip:" + clientIP); !allowed {
+			return tooManyRequests(c, wait)
+		}
 		var req signInRequest
 		if err := c.BodyParser(&req); err != nil {
 			return badRequest(c, "invalid request body")
@@ -75,6 +136,11 @@ func NewRouter(
 		if req.PasswordCredentials == nil {
 			return badRequest(c, "passwordCredentials is required")
 		}
+		username := strings.ToLower(strings.TrimSpace(req.PasswordCredentials.Username))
+		// 用户名按来源 IP 分开计数：他人从别处反复猜密码不会把该用户自己的登录也限流
+		if allowed, wait := signInLimiter.allow("user:" + clientIP + "/" + username); !allowed {
+			return tooManyRequests(c, wait)
+		}
 
 		user, accessToken, err := userService.SignInWithPassword(
 			c.Context(),
@@ -96,6 +162,27 @@ func NewRouter(
 		})
 	})
 
+	app.Post("/api/v1/auth/resetPassword", func(c *fiber.Ctx) error {
+		if allowed, wait := signInLimiter.allow("ip:" + c.IP()); !allowed {
+			return tooManyRequests(c, wait)
+		}
+		var req resetPasswordRequest
+		if err := c.BodyParser(&req); err != nil {
+			return badRequest(c, "invalid request body")
+		}
+		if _, err := userService.ResetPasswordWithToken(c.Context(), req.Token, req.NewPassword); err != nil {
+			switch {
+			case errors.Is(err, service.ErrInvalidPassword):
+				return badRequest(c, "invalid password")
+			case errors.Is(err, service.ErrInvalidResetToken):
+				return badRequest(c, "invalid or expired reset token")
+			default:
+				return internalError(c, err)
+			}
+		}
+		return c.SendStatus(fiber.StatusNoContent)
+	})
+
 	app.Post("/api/v1/users", func(c *fiber.Ctx) error {
 		var req createUserRequest
 		if err := c.BodyParser(&req); err != nil {
@@ -123,6 +210,7 @@ func NewRouter(
 			Password:     req.User.Password,
 			Role:         req.User.Role,
 			ValidateOnly: req.ValidateOnly,
+			InviteToken:  req.InviteToken,
 		}, allowRegistration)
 		if err != nil {
 			switch {
@@ -138,6 +226,8 @@ func NewRouter(
 				return c.Status(fiber.StatusConflict).JSON(fiber.Map{"message": "username already exists"})
 			case errors.Is(err, service.ErrRegistrationDisabled):
 				return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"message": "user registration is not allowed"})
+			case errors.Is(err, service.ErrInvalidInviteToken):
+				return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"message": "invalid invite token"})
 			default:
 				return internalError(c, err)
 			}
@@ -146,7 +236,66 @@ func NewRouter(
 		return c.JSON(toAPIUser(user))
 	})
 
-	api := app.Group("/api/v1", AuthMiddleware(userService))
+	// 公开分享页使用的只读接口：未登录时只返回 PUBLIC 备忘录，携带令牌时按登录用户的可见性返回。
+	// 必须在 /api/v1 认证分组之前注册，才不会被其强制认证拦截。
+	optionalAuth := func(c *fiber.Ctx) error {
+		if _, err := OptionalAuthenticateToken(c, userService); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return writeError(c, fiber.StatusUnauthorized, "UNAUTHORIZED", "invalid access token")
+			}
+			return internalError(c, fmt.Errorf("authenticate optional token: %w", err))
+		}
+		return c.Next()
+	}
+	public := app.Group("/api/v1/public", optionalAuth, RequireScopes("memos"))
+	public.Get("/users/:name/memos", func(c *fiber.Ctx) error {
+		viewer := CurrentUser(c)
+		name := strings.TrimSpace(c.Params("name"))
+		if name == "" {
+			return badRequest(c, "invalid user name")
+		}
+		creator, err := userService.GetUserByIdentifier(c.Context(), name)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return notFound(c, "user not found")
+			}
+			return internalError(c, err)
+		}
+		pageSize, _ := strconv.Atoi(strings.TrimSpace(c.Query("pageSize", "50")))
+		memos, nextToken, err := memoService.ListCreatorMemos(c.Context(), viewer.ID, creator.ID, pageSize, c.Query("pageToken", ""))
+		if err != nil {
+			if strings.Contains(err.Error(), "pageToken") {
+				return badRequest(c, err.Error())
+			}
+			return internalError(c, err)
+		}
+		resp := listMemosResponse{
+			Memos:         make([]apiMemo, 0, len(memos)),
+			NextPageToken: nextToken,
+		}
+		for _, item := range memos {
+			resp.Memos = append(resp.Memos, buildAPIMemo(item))
+		}
+		return c.JSON(resp)
+	})
+
+	public.Get("/memos/:id", func(c *fiber.Ctx) error {
+		viewer := CurrentUser(c)
+		memoID, err := parseID(c.Params("id"))
+		if err != nil {
+			return badRequest(c, "invalid memo id")
+		}
+		memo, err := memoService.GetMemo(c.Context(), viewer.ID, memoID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return notFound(c, "memo not found")
+			}
+			return internalError(c, err)
+		}
+		return c.JSON(buildAPIMemo(memo))
+	})
+
+	api := app.Group("/api/v1", AuthMiddleware(userService), RequireAPIScopes())
 	api.Get("/auth/me", func(c *fiber.Ctx) error {
 		user := CurrentUser(c)
 		return c.JSON(getCurrentUserResponse{
@@ -154,27 +303,167 @@ func NewRouter(
 		})
 	})
 
-	api.Get("/users/:name/settings/GENERAL", func(c *fiber.Ctx) error {
+	api.Post("/auth/signout", func(c *fiber.Ctx) error {
+		if _, err := userService.RevokeAccessTokenByID(c.Context(), CurrentAccessTokenID(c)); err != nil {
+			return internalError(c, err)
+		}
+		return c.SendStatus(fiber.StatusNoContent)
+	})
+
+	toAPIAccessToken := func(token models.PersonalAccessToken, currentTokenID int64) apiAccessToken {
+		resp := apiAccessToken{
+			ID:                token.ID,
+			Prefix:            token.TokenPrefix,
+			Description:       token.Description,
+			Scopes:            token.Scopes,
+			Current:           token.ID == currentTokenID,
+			CreateTime:        formatTime(token.CreatedAt),
+			LastUsedIP:        token.LastUsedIP,
+			LastUsedUserAgent: token.LastUsedUserAgent,
+		}
+		if resp.Scopes == nil {
+			resp.Scopes = []string{}
+		}
+		if token.LastUsedAt != nil {
+			resp.LastUsedTime = formatTime(*token.LastUsedAt)
+		}
+		if token.ExpiresAt != nil {
+			resp.ExpireTime = formatTime(*token.ExpiresAt)
+		}
+		if token.RevokedAt != nil {
+			resp.RevokeTime = formatTime(*token.RevokedAt)
+		}
+		return resp
+	}
+
+	api.Get("/auth/tokens", func(c *fiber.Ctx) error {
+		currentUser := CurrentUser(c)
+		tokens, err := userService.ListOwnAccessTokens(c.Context(), currentUser.ID)
+		if err != nil {
+			return internalError(c, err)
+		}
+		resp := listAccessTokensResponse{Tokens: make([]apiAccessToken, 0, len(tokens))}
+		currentTokenID := CurrentAccessTokenID(c)
+		for _, token := range tokens {
+			resp.Tokens = append(resp.Tokens, toAPIAccessToken(token, currentTokenID))
+		}
+		return c.JSON(resp)
+	})
+
+	api.Delete("/auth/tokens/:id", func(c *fiber.Ctx) error {
 		currentUser := CurrentUser(c)
+		tokenID, err := strconv.ParseInt(strings.TrimSpace(c.Params("id")), 10, 64)
+		if err != nil || tokenID <= 0 {
+			return badRequest(c, "invalid token id")
+		}
+		token, err := userService.RevokeOwnAccessToken(c.Context(), currentUser.ID, tokenID)
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return notFound(c, "token not found")
+			case errors.Is(err, service.ErrTokenAlreadyRevoked):
+				return c.Status(fiber.StatusConflict).JSON(fiber.Map{"message": "token already revoked"})
+			default:
+				return internalError(c, err)
+			}
+		}
+		return c.JSON(toAPIAccessToken(token, CurrentAccessTokenID(c)))
+	})
+
+	// settingsOwner 解析 :name 并确认是当前用户本人，设置接口不允许访问他人的设置；ok 为 false 时响应已写入。
+	settingsOwner := func(c *fiber.Ctx) (user models.User, ok bool, err error) {
 		name := strings.TrimSpace(c.Params("name"))
 		if name == "" {
-			return badRequest(c, "invalid user name")
+			return models.User{}, false, badRequest(c, "invalid user name")
 		}
-		user, err := userService.GetUserByIdentifier(c.Context(), name)
+		user, err = userService.GetUserByIdentifier(c.Context(), name)
 		if err != nil {
 			if errors.Is(err, sql.ErrNoRows) {
-				return notFound(c, "user not found")
+				return models.User{}, false, notFound(c, "user not found")
 			}
+			return models.User{}, false, internalError(c, err)
+		}
+		if user.ID != CurrentUser(c).ID {
+			return models.User{}, false, c.Status(fiber.StatusForbidden).JSON(fiber.Map{"message": "forbidden"})
+		}
+		return user, true, nil
+	}
+
+	api.Get("/users/:name/settings", func(c *fiber.Ctx) error {
+		user, ok, err := settingsOwner(c)
+		if !ok {
+			return err
+		}
+		settings, err := userSettingsService.Get(c.Context(), user.ID)
+		if err != nil {
 			return internalError(c, err)
 		}
-		if user.ID != currentUser.ID {
-			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"message": "forbidden"})
+		return c.JSON(toAPIUserSettings(settings))
+	})
+
+	api.Patch("/users/:name/settings", func(c *fiber.Ctx) error {
+		user, ok, err := settingsOwner(c)
+		if !ok {
+			return err
 		}
-		return c.JSON(userSettingResponse{
-			GeneralSetting: generalSetting{
-				MemoVisibility: string(user.DefaultVisibility),
-			},
-		})
+		var req updateUserSettingsRequest
+		if err := c.BodyParser(&req); err != nil {
+			return badRequest(c, "invalid request body")
+		}
+		patch := service.UserSettingsPatch{
+			DefaultPageSize: req.DefaultPageSize,
+			Timezone:        req.Timezone,
+			Locale:          req.Locale,
+		}
+		if req.DefaultVisibility != nil {
+			visibility := models.Visibility(strings.ToUpper(strings.TrimSpace(*req.DefaultVisibility)))
+			patch.DefaultVisibility = &visibility
+		}
+		settings, err := userSettingsService.Update(c.Context(), user.ID, patch)
+		if err != nil {
+			switch {
+			case errors.Is(err, service.ErrInvalidPageSize):
+				return badRequest(c, "defaultPageSize must be between 0 and 200")
+			case errors.Is(err, service.ErrInvalidDefaultVisibility),
+				errors.Is(err, service.ErrInvalidTimezone),
+				errors.Is(err, service.ErrInvalidLocale):
+				return badRequest(c, err.Error())
+			default:
+				return internalError(c, err)
+			}
+		}
+		return c.JSON(toAPIUserSettings(settings))
+	})
+
+	api.Get("/users/:name/settings/GENERAL", func(c *fiber.Ctx) error {
+		user, ok, err := settingsOwner(c)
+		if !ok {
+			return err
+		}
+		return c.JSON(toUserSettingResponse(user))
+	})
+
+	api.Patch("/users/:name/settings/GENERAL", func(c *fiber.Ctx) error {
+		user, ok, err := settingsOwner(c)
+		if !ok {
+			return err
+		}
+
+		var req updateUserSettingRequest
+		if err := c.BodyParser(&req); err != nil {
+			return badRequest(c, "invalid request body")
+		}
+		if req.GeneralSetting.DefaultPageSize == nil {
+			return badRequest(c, "defaultPageSize is required")
+		}
+		updated, err := userService.UpdateDefaultPageSize(c.Context(), user.ID, *req.GeneralSetting.DefaultPageSize)
+		if err != nil {
+			if errors.Is(err, service.ErrInvalidPageSize) {
+				return badRequest(c, "defaultPageSize must be between 0 and 200")
+			}
+			return internalError(c, err)
+		}
+		return c.JSON(toUserSettingResponse(updated))
 	})
 
 	api.Get("/users/:name\\:getStats", func(c *fiber.Ctx) error {
@@ -194,9 +483,17 @@ func NewRouter(
 		if err != nil {
 			return internalError(c, err)
 		}
-		return c.JSON(userStatsResponse{
+		resp := userStatsResponse{
 			TagCount: tagCount,
-		})
+		}
+		if requestedUser.ID == currentUser.ID || isAdminUser(currentUser) {
+			used, quota, err := attachmentService.GetStorageUsage(c.Context(), requestedUser.ID)
+			if err != nil {
+				return internalError(c, err)
+			}
+			resp.StorageUsage = &apiStorageUsage{UsedBytes: used, QuotaBytes: quota}
+		}
+		return c.JSON(resp)
 	})
 
 	api.Get("/users/batch", func(c *fiber.Ctx) error {
@@ -335,9 +632,84 @@ func NewRouter(
 		return c.JSON(toAPIUser(updatedUser))
 	})
 
+	api.Get("/tags", func(c *fiber.Ctx) error {
+		currentUser := CurrentUser(c)
+		counts, err := memoService.ListTagCounts(c.Context(), currentUser.ID)
+		if err != nil {
+			return internalError(c, err)
+		}
+		resp := listTagsResponse{Tags: make([]apiTagCount, 0, len(counts))}
+		for _, count := range counts {
+			resp.Tags = append(resp.Tags, apiTagCount{Name: count.Name, MemoCount: count.MemoCount})
+		}
+		return c.JSON(resp)
+	})
+
+	api.Post("/tags\\:rename", func(c *fiber.Ctx) error {
+		currentUser := CurrentUser(c)
+		var req renameTagRequest
+		if err := c.BodyParser(&req); err != nil {
+			return badRequest(c, "invalid request body")
+		}
+		name, err := memoService.RenameTag(c.Context(), currentUser.ID, req.OldName, req.NewName)
+		if err != nil {
+			if errors.Is(err, service.ErrInvalidTagName) {
+				return badRequest(c, "invalid tag name")
+			}
+			if errors.Is(err, sql.ErrNoRows) {
+				return notFound(c, "tag not found")
+			}
+			return internalError(c, err)
+		}
+		return c.JSON(apiTag{Name: name})
+	})
+
+	api.Post("/tags", func(c *fiber.Ctx) error {
+		currentUser := CurrentUser(c)
+		var req createTagRequest
+		if err := c.BodyParser(&req); err != nil {
+			return badRequest(c, "invalid request body")
+		}
+		name, created, err := memoService.CreateTag(c.Context(), currentUser.ID, req.Name)
+		if err != nil {
+			if errors.Is(err, service.ErrInvalidTagName) {
+				return badRequest(c, "invalid tag name")
+			}
+			return internalError(c, err)
+		}
+		status := fiber.StatusOK
+		if created {
+			status = fiber.StatusCreated
+		}
+		return c.Status(status).JSON(apiTag{Name: name})
+	})
+
+	// 标签名可能包含 /（如 work/project），使用贪婪参数匹配剩余路径
+	api.Delete("/tags/+", func(c *fiber.Ctx) error {
+		currentUser := CurrentUser(c)
+		name, err := url.PathUnescape(c.Params("+"))
+		if err != nil {
+			return badRequest(c, "invalid tag name")
+		}
+		if err := memoService.DeleteTag(c.Context(), currentUser.ID, name); err != nil {
+			if errors.Is(err, service.ErrInvalidTagName) {
+				return badRequest(c, "invalid tag name")
+			}
+			if errors.Is(err, sql.ErrNoRows) {
+				return notFound(c, "tag not found")
+			}
+			return internalError(c, err)
+		}
+		return c.SendStatus(fiber.StatusNoContent)
+	})
+
 	api.Get("/memos", func(c *fiber.Ctx) error {
 		currentUser := CurrentUser(c)
-		pageSize, _ := strconv.Atoi(strings.TrimSpace(c.Query("pageSize", "50")))
+		// 未传 pageSize 时使用用户设置的默认分页大小，服务层仍会按 200 封顶
+		pageSize := currentUser.DefaultPageSize
+		if rawPageSize := strings.TrimSpace(c.Query("pageSize")); rawPageSize != "" {
+			pageSize, _ = strconv.Atoi(rawPageSize)
+		}
 		pageToken := c.Query("pageToken", "")
 		filter := c.Query("filter", "")
 		var state *models.MemoState
@@ -350,9 +722,33 @@ func NewRouter(
 			state = &s
 		}
 
-		memos, nextToken, err := memoService.ListMemos(c.Context(), currentUser.ID, state, filter, pageSize, pageToken)
-		if err != nil {
-			return badRequest(c, err.Error())
+		var memos []service.MemoWithAttachments
+		var nextToken string
+		var err error
+		if search := strings.TrimSpace(c.Query("search")); search != "" {
+			if strings.TrimSpace(filter) != "" {
+				return badRequest(c, "search cannot be combined with filter")
+			}
+			var advanced bool
+			switch strings.TrimSpace(c.Query("searchMode", "simple")) {
+			case "simple":
+			case "advanced":
+				advanced = true
+			default:
+				return badRequest(c, "invalid searchMode")
+			}
+			memos, nextToken, err = memoService.SearchVisibleMemos(c.Context(), currentUser.ID, state, search, advanced, pageSize, pageToken)
+			if err != nil {
+				if errors.Is(err, service.ErrInvalidSearchQuery) || strings.Contains(err.Error(), "pageToken") {
+					return badRequest(c, err.Error())
+				}
+				return internalError(c, err)
+			}
+		} else {
+			memos, nextToken, err = memoService.ListMemos(c.Context(), currentUser.ID, state, filter, pageSize, pageToken)
+			if err != nil {
+				return badRequest(c, err.Error())
+			}
 		}
 
 		resp := listMemosResponse{
@@ -365,19 +761,10 @@ func NewRouter(
 		return c.JSON(resp)
 	})
 
-	api.Get("/memos/changes", func(c *fiber.Ctx) error {
+	listMemoChanges := func(c *fiber.Ctx, since time.Time, syncAnchor time.Time) error {
 		currentUser := CurrentUser(c)
 		filter := c.Query("filter", "")
 
-		sinceRaw := strings.TrimSpace(c.Query("since"))
-		if sinceRaw == "" {
-			return badRequest(c, "since is required")
-		}
-		since, err := time.Parse(time.RFC3339Nano, sinceRaw)
-		if err != nil {
-			return badRequest(c, "invalid since")
-		}
-
 		var state *models.MemoState
 		stateRaw := strings.TrimSpace(c.Query("state"))
 		if stateRaw != "" {
@@ -388,7 +775,6 @@ func NewRouter(
 			state = &s
 		}
 
-		syncAnchor := time.Now().UTC()
 		changes, err := memoService.ListMemoChanges(
 			c.Context(),
 			currentUser.ID,
@@ -410,35 +796,175 @@ func NewRouter(
 			resp.Memos = append(resp.Memos, buildAPIMemo(item))
 		}
 		return c.JSON(resp)
-	})
+	}
 
-	api.Post("/memos", func(c *fiber.Ctx) error {
-		currentUser := CurrentUser(c)
-		var req createMemoRequest
-		if err := c.BodyParser(&req); err != nil {
-			return badRequest(c, "invalid request body")
+	api.Get("/memos/changes", func(c *fiber.Ctx) error {
+		sinceRaw := strings.TrimSpace(c.Query("since"))
+		if sinceRaw == "" {
+			return badRequest(c, "since is required")
 		}
-		attachmentNames := make([]string, 0, len(req.Attachments))
-		for _, attachment := range req.Attachments {
-			if attachment.Name == "" {
-				continue
-			}
-			attachmentNames = append(attachmentNames, attachment.Name)
+		since, err := time.Parse(time.RFC3339Nano, sinceRaw)
+		if err != nil {
+			return badRequest(c, "invalid since")
 		}
+		return listMemoChanges(c, since, time.Now().UTC())
+	})
 
-		visibility := models.Visibility(req.Visibility)
-		if req.Visibility == "" {
-			visibility = currentUser.DefaultVisibility
+	// memos:sync 是增量同步入口：since 缺省时从头同步，anchor 可把窗口上界固定为服务端此前返回的锚点
+	api.Get("/memos\\:sync", func(c *fiber.Ctx) error {
+		since := time.Unix(0, 0).UTC()
+		if sinceRaw := strings.TrimSpace(c.Query("since")); sinceRaw != "" {
+			parsed, err := time.Parse(time.RFC3339Nano, sinceRaw)
+			if err != nil {
+				return badRequest(c, "invalid since")
+			}
+			since = parsed
 		}
-		var createTime *time.Time
-		if req.CreateTime != nil {
-			if t, err := time.Parse(time.RFC3339Nano, *req.CreateTime); err == nil {
-				createTime = &t
+		now := time.Now().UTC()
+		syncAnchor := now
+		if anchorRaw := strings.TrimSpace(c.Query("anchor")); anchorRaw != "" {
+			parsed, err := time.Parse(time.RFC3339Nano, anchorRaw)
+			if err != nil {
+				return badRequest(c, "invalid anchor")
+			}
+			if parsed.Before(since) {
+				return badRequest(c, "anchor must not be before since")
+			}
+			// 上界不能超过服务端当前时间，否则之后写入的变更会落在已同步的窗口里
+			if parsed.Before(now) {
+				syncAnchor = parsed.UTC()
 			}
 		}
-		created, err := memoService.CreateMemo(
-			c.Context(),
-			currentUser.ID,
+		return listMemoChanges(c, since, syncAnchor)
+	})
+
+	// memos:watch 以 SSE 推送变更：先补发 since 之后的变更，之后每次备忘录写入提交时推送新的变更窗口。
+	// 每条 changes 事件的 id 为 syncAnchor，断线重连时浏览器会通过 Last-Event-ID 带回。
+	api.Get("/memos\\:watch", func(c *fiber.Ctx) error {
+		currentUser := CurrentUser(c)
+		filter := c.Query("filter", "")
+		var state *models.MemoState
+		if stateRaw := strings.TrimSpace(c.Query("state")); stateRaw != "" {
+			s := models.MemoState(stateRaw)
+			if !s.IsValid() {
+				return badRequest(c, "invalid state")
+			}
+			state = &s
+		}
+		now := time.Now().UTC()
+		since := now
+		sinceRaw := strings.TrimSpace(c.Query("since"))
+		if sinceRaw == "" {
+			sinceRaw = strings.TrimSpace(c.Get("Last-Event-ID"))
+		}
+		if sinceRaw != "" {
+			parsed, err := time.Parse(time.RFC3339Nano, sinceRaw)
+			if err != nil {
+				return badRequest(c, "invalid since")
+			}
+			since = parsed
+		}
+
+		// 先订阅再补发，补发查询之后提交的写入也会触发一次推送
+		changed, unsubscribe := memoService.SubscribeMemoChanges(currentUser.ID)
+		initial, err := memoService.ListMemoChanges(c.UserContext(), currentUser.ID, state, filter, since, now)
+		if err != nil {
+			unsubscribe()
+			return badRequest(c, err.Error())
+		}
+
+		c.Set(fiber.HeaderContentType, "text/event-stream")
+		c.Set(fiber.HeaderCacheControl, "no-cache")
+		c.Set(fiber.HeaderConnection, "keep-alive")
+		c.Set("X-Accel-Buffering", "no")
+		userID := currentUser.ID
+		// 流式写入在处理函数返回后才执行，后续查询不再绑定请求上下文；服务关闭信号在这里提前取出
+		shutdown := c.Context().Done()
+		c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+			defer unsubscribe()
+			writeChanges := func(changes service.MemoChanges) error {
+				resp := listMemoChangesResponse{
+					Memos:            make([]apiMemo, 0, len(changes.Memos)),
+					DeletedMemoNames: changes.DeletedMemoNames,
+					SyncAnchor:       changes.SyncAnchor.Format(time.RFC3339Nano),
+				}
+				for _, item := range changes.Memos {
+					resp.Memos = append(resp.Memos, buildAPIMemo(item))
+				}
+				data, err := json.Marshal(resp)
+				if err != nil {
+					return err
+				}
+				if _, err := fmt.Fprintf(w, "id: %s\nevent: changes\ndata: %s\n\n", resp.SyncAnchor, data); err != nil {
+					return err
+				}
+				return w.Flush()
+			}
+
+			if err := writeChanges(initial); err != nil {
+				return
+			}
+			anchor := initial.SyncAnchor
+			keepAlive := time.NewTicker(memoWatchKeepAliveInterval)
+			defer keepAlive.Stop()
+			for {
+				select {
+				case <-shutdown:
+					return
+				case <-keepAlive.C:
+					// 客户端断开后写入会失败，借此及时释放订阅
+					if _, err := w.WriteString(": keep-alive\n\n"); err != nil {
+						return
+					}
+					if err := w.Flush(); err != nil {
+						return
+					}
+				case <-changed:
+					changes, err := memoService.ListMemoChanges(context.Background(), userID, state, filter, anchor, time.Now().UTC())
+					if err != nil {
+						log.Printf("memo watch query failed user_id=%d err=%v", userID, err)
+						return
+					}
+					anchor = changes.SyncAnchor
+					if len(changes.Memos) == 0 && len(changes.DeletedMemoNames) == 0 {
+						continue
+					}
+					if err := writeChanges(changes); err != nil {
+						return
+					}
+				}
+			}
+		})
+		return nil
+	})
+
+	api.Post("/memos", func(c *fiber.Ctx) error {
+		currentUser := CurrentUser(c)
+		var req createMemoRequest
+		if err := c.BodyParser(&req); err != nil {
+			return badRequest(c, "invalid request body")
+		}
+		attachmentNames := make([]string, 0, len(req.Attachments))
+		for _, attachment := range req.Attachments {
+			if attachment.Name == "" {
+				continue
+			}
+			attachmentNames = append(attachmentNames, attachment.Name)
+		}
+
+		visibility := models.Visibility(req.Visibility)
+		if req.Visibility == "" {
+			visibility = currentUser.DefaultVisibility
+		}
+		var createTime *time.Time
+		if req.CreateTime != nil {
+			if t, err := time.Parse(time.RFC3339Nano, *req.CreateTime); err == nil {
+				createTime = &t
+			}
+		}
+		created, err := memoService.CreateMemo(
+			c.Context(),
+			currentUser.ID,
 			service.CreateMemoInput{
 				Content:         req.Content,
 				Visibility:      visibility,
@@ -455,6 +981,285 @@ func NewRouter(
 		return c.JSON(buildAPIMemo(created))
 	})
 
+	api.Post("/memos\\:fromAttachment", func(c *fiber.Ctx) error {
+		currentUser := CurrentUser(c)
+		var req createMemoFromAttachmentRequest
+		if err := c.BodyParser(&req); err != nil {
+			return badRequest(c, "invalid request body")
+		}
+		sources := 0
+		for _, provided := range []bool{strings.TrimSpace(req.Attachment) != "", strings.TrimSpace(req.UploadID) != "", req.File != nil} {
+			if provided {
+				sources++
+			}
+		}
+		if sources != 1 {
+			return badRequest(c, "exactly one of attachment, uploadId or file is required")
+		}
+
+		var attachment models.Attachment
+		created := false
+		switch {
+		case strings.TrimSpace(req.Attachment) != "":
+			attachmentID, err := parseID(strings.TrimPrefix(strings.TrimSpace(req.Attachment), "attachments/"))
+			if err != nil {
+				return badRequest(c, "invalid attachment")
+			}
+			attachment, err = attachmentService.GetAttachment(c.Context(), attachmentID)
+			if err != nil {
+				if errors.Is(err, sql.ErrNoRows) {
+					return notFound(c, "attachment not found")
+				}
+				return internalError(c, err)
+			}
+			if attachment.CreatorID != currentUser.ID {
+				return notFound(c, "attachment not found")
+			}
+		case strings.TrimSpace(req.UploadID) != "":
+			var err error
+			attachment, err = attachmentService.CompleteAttachmentUploadSession(c.Context(), currentUser.ID, strings.TrimSpace(req.UploadID), c.Get(uploadChecksumHeader))
+			if err != nil {
+				if errors.Is(err, service.ErrUploadSessionNotFound) || errors.Is(err, sql.ErrNoRows) {
+					return notFound(c, "upload session not found")
+				}
+				if errors.Is(err, service.ErrUploadNotComplete) {
+					return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+						"message": "upload not complete",
+					})
+				}
+				if errors.Is(err, service.ErrInvalidChecksum) {
+					return badRequest(c, err.Error())
+				}
+				if errors.Is(err, service.ErrChecksumMismatch) {
+					return writeError(c, fiber.StatusUnprocessableEntity, "CHECKSUM_MISMATCH", err.Error())
+				}
+				if errors.Is(err, service.ErrQuotaExceeded) {
+					return quotaExceeded(c)
+				}
+				var typeNotAllowed *service.AttachmentTypeNotAllowedError
+				if errors.As(err, &typeNotAllowed) {
+					return writeError(c, fiber.StatusUnsupportedMediaType, "UNSUPPORTED_MEDIA_TYPE", typeNotAllowed.Error())
+				}
+				return internalError(c, err)
+			}
+			created = true
+		default:
+			var err error
+			attachment, err = attachmentService.CreateAttachment(
+				c.Context(),
+				currentUser.ID,
+				service.CreateAttachmentInput{
+					Filename: req.File.Filename,
+					Type:     req.File.Type,
+					Content:  req.File.Content,
+				},
+			)
+			if err != nil {
+				var tooLarge *service.AttachmentTooLargeError
+				if errors.As(err, &tooLarge) {
+					return attachmentTooLarge(c, tooLarge)
+				}
+				if errors.Is(err, service.ErrQuotaExceeded) {
+					return quotaExceeded(c)
+				}
+				var typeNotAllowed *service.AttachmentTypeNotAllowedError
+				if errors.As(err, &typeNotAllowed) {
+					return writeError(c, fiber.StatusUnsupportedMediaType, "UNSUPPORTED_MEDIA_TYPE", typeNotAllowed.Error())
+				}
+				var inlineTooLarge *service.InlineAttachmentTooLargeError
+				if errors.As(err, &inlineTooLarge) {
+					return inlineAttachmentTooLarge(c, inlineTooLarge)
+				}
+				return badRequest(c, err.Error())
+			}
+			created = true
+		}
+
+		visibility := models.Visibility(req.Visibility)
+		if req.Visibility == "" {
+			visibility = currentUser.DefaultVisibility
+		}
+		memo, err := memoService.CreateMemo(
+			c.Context(),
+			currentUser.ID,
+			service.CreateMemoInput{
+				Content:         req.Content,
+				Visibility:      visibility,
+				Tags:            req.Tags,
+				AttachmentNames: []string{"attachments/" + models.Int64ToString(attachment.ID)},
+			},
+		)
+		if err != nil {
+			// 备忘录创建失败时回收本次请求新建的附件，避免留下孤立附件
+			if created {
+				_ = attachmentService.DeleteAttachment(c.Context(), currentUser.ID, attachment.ID)
+			}
+			return badRequest(c, err.Error())
+		}
+		return c.JSON(buildAPIMemo(memo))
+	})
+
+	api.Get("/memos\\:export", func(c *fiber.Ctx) error {
+		currentUser := CurrentUser(c)
+		var state *models.MemoState
+		if stateRaw := strings.TrimSpace(c.Query("state")); stateRaw != "" {
+			s := models.MemoState(stateRaw)
+			if !s.IsValid() {
+				return badRequest(c, "invalid state")
+			}
+			state = &s
+		}
+		release, ok := heavyLimiter.tryAcquire()
+		if !ok {
+			return serviceBusy(c, heavyOperationRetryAfter)
+		}
+
+		// 通过管道边查询边输出，避免把全部备忘录缓冲在内存里；
+		// 响应写完或客户端断开后读端被关闭，写入失败即停止遍历
+		reader, writer := io.Pipe()
+		go func() {
+			defer release()
+			encoder := json.NewEncoder(writer)
+			count, err := memoService.ExportMemos(context.Background(), currentUser.ID, state, func(memo service.MemoWithAttachments) error {
+				attachmentNames := make([]string, 0, len(memo.Attachments))
+				for _, attachment := range memo.Attachments {
+					attachmentNames = append(attachmentNames, "attachments/"+models.Int64ToString(attachment.ID))
+				}
+				return encoder.Encode(apiMemoExportLine{
+					Type:        "memo",
+					Name:        memo.Memo.Name(),
+					Content:     memo.Memo.Content,
+					Tags:        memo.Memo.Payload.Tags,
+					Visibility:  string(memo.Memo.Visibility),
+					State:       string(memo.Memo.State),
+					Pinned:      memo.Memo.Pinned,
+					CreateTime:  formatTime(memo.Memo.CreateTime),
+					UpdateTime:  formatTime(memo.Memo.UpdateTime),
+					Attachments: attachmentNames,
+				})
+			})
+			if err != nil {
+				if !errors.Is(err, io.ErrClosedPipe) {
+					log.Printf("memo export failed user_id=%d err=%v", currentUser.ID, err)
+				}
+				_ = writer.CloseWithError(err)
+				return
+			}
+			if err := encoder.Encode(apiMemoExportSummary{
+				Type:       "summary",
+				Count:      count,
+				ExportTime: formatTime(time.Now()),
+			}); err != nil {
+				_ = writer.CloseWithError(err)
+				return
+			}
+			_ = writer.Close()
+		}()
+
+		c.Set(fiber.HeaderContentType, "application/x-ndjson")
+		c.Set(fiber.HeaderContentDisposition, `attachment; filename="memos.ndjson"`)
+		return c.SendStream(reader)
+	})
+
+	api.Post("/memos\\:import", func(c *fiber.Ctx) error {
+		currentUser := CurrentUser(c)
+		release, ok := heavyLimiter.tryAcquire()
+		if !ok {
+			return serviceBusy(c, heavyOperationRetryAfter)
+		}
+		defer release()
+		result, err := memoService.ImportMemos(
+			c.Context(),
+			currentUser.ID,
+			currentUser.DefaultVisibility,
+			bytes.NewReader(c.Body()),
+		)
+		if err != nil {
+			return internalError(c, err)
+		}
+		resp := memoImportResponse{
+			Created: result.Created,
+			Skipped: result.Skipped,
+			Errors:  make([]apiMemoImportError, 0, len(result.Errors)),
+		}
+		for _, lineErr := range result.Errors {
+			resp.Errors = append(resp.Errors, apiMemoImportError{Line: lineErr.Line, Message: lineErr.Message})
+		}
+		return c.JSON(resp)
+	})
+
+	api.Get("/memos\\:sharedUnreadCount", func(c *fiber.Ctx) error {
+		currentUser := CurrentUser(c)
+		count, lastSeen, err := memoService.CountUnreadSharedMemos(c.Context(), currentUser.ID)
+		if err != nil {
+			return internalError(c, err)
+		}
+		resp := sharedUnreadCountResponse{Count: count}
+		if !lastSeen.IsZero() {
+			resp.LastSeenTime = formatTime(lastSeen)
+		}
+		return c.JSON(resp)
+	})
+
+	api.Post("/memos\\:markSharedSeen", func(c *fiber.Ctx) error {
+		currentUser := CurrentUser(c)
+		seenAt, err := memoService.MarkSharedMemosSeen(c.Context(), currentUser.ID)
+		if err != nil {
+			return internalError(c, err)
+		}
+		return c.JSON(markSharedSeenResponse{LastSeenTime: formatTime(seenAt)})
+	})
+
+	api.Post("/memos\\:batchSetVisibility", func(c *fiber.Ctx) error {
+		currentUser := CurrentUser(c)
+		var req batchSetMemoVisibilityRequest
+		if err := c.BodyParser(&req); err != nil {
+			return badRequest(c, "invalid request body")
+		}
+		changed, err := memoService.BatchSetMemoVisibility(
+			c.Context(),
+			currentUser.ID,
+			req.Filter,
+			models.Visibility(strings.TrimSpace(req.Visibility)),
+			req.Confirm,
+		)
+		if err != nil {
+			return badRequest(c, err.Error())
+		}
+		return c.JSON(batchSetMemoVisibilityResponse{ChangedCount: changed})
+	})
+
+	api.Post("/memos\\:validateFilter", func(c *fiber.Ctx) error {
+		var req validateMemoFilterRequest
+		if err := c.BodyParser(&req); err != nil {
+			return badRequest(c, "invalid request body")
+		}
+		filter, err := service.CompileMemoFilter(req.Filter)
+		if err != nil {
+			return badRequest(c, err.Error())
+		}
+		return c.JSON(validateMemoFilterResponse{
+			FullyPushedDown: filter.FullyPushedDown(),
+			Prefilter:       toAPIMemoPrefilter(filter.SQLPrefilter()),
+		})
+	})
+
+	api.Get("/memos/:id", func(c *fiber.Ctx) error {
+		currentUser := CurrentUser(c)
+		memoID, err := parseID(c.Params("id"))
+		if err != nil {
+			return badRequest(c, "invalid memo id")
+		}
+		memo, err := memoService.GetMemo(c.Context(), currentUser.ID, memoID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return notFound(c, "memo not found")
+			}
+			return internalError(c, err)
+		}
+		return c.JSON(buildAPIMemo(memo))
+	})
+
 	api.Patch("/memos/:id", func(c *fiber.Ctx) error {
 		currentUser := CurrentUser(c)
 		memoID, err := parseID(c.Params("id"))
@@ -477,6 +1282,14 @@ func NewRouter(
 			s := models.MemoState(*req.State)
 			state = &s
 		}
+		var createTime *time.Time
+		if req.CreateTime != nil {
+			t, err := time.Parse(time.RFC3339Nano, strings.TrimSpace(*req.CreateTime))
+			if err != nil {
+				return badRequest(c, "invalid createTime")
+			}
+			createTime = &t
+		}
 		var attachmentNames *[]string
 		if req.Attachments != nil {
 			names := make([]string, 0, len(*req.Attachments))
@@ -504,6 +1317,9 @@ func NewRouter(
 				Latitude:        req.Latitude.Value,
 				LongitudeSet:    req.Longitude.Set,
 				Longitude:       req.Longitude.Value,
+				CreateTime:      createTime,
+				// 响应中不含 collab/ 标签，回传的标签列表不能据此移除协作者
+				KeepCollaborators: true,
 			},
 		)
 		if err != nil {
@@ -530,6 +1346,163 @@ func NewRouter(
 		return c.SendStatus(fiber.StatusNoContent)
 	})
 
+	setMemoTagPin := func(pinned bool) fiber.Handler {
+		return func(c *fiber.Ctx) error {
+			currentUser := CurrentUser(c)
+			memoID, err := parseID(c.Params("id"))
+			if err != nil {
+				return badRequest(c, "invalid memo id")
+			}
+			var req memoTagPinRequest
+			if err := c.BodyParser(&req); err != nil {
+				return badRequest(c, "invalid request body")
+			}
+			memo, err := memoService.SetMemoTagPin(c.Context(), currentUser.ID, memoID, req.Tag, pinned)
+			if err != nil {
+				if errors.Is(err, sql.ErrNoRows) {
+					return notFound(c, "memo not found")
+				}
+				return badRequest(c, err.Error())
+			}
+			return c.JSON(buildAPIMemo(memo))
+		}
+	}
+	api.Post("/memos/:id\\:pinForTag", setMemoTagPin(true))
+	api.Post("/memos/:id\\:unpinForTag", setMemoTagPin(false))
+
+	shareMemoToGroup := func(resync bool) fiber.Handler {
+		return func(c *fiber.Ctx) error {
+			currentUser := CurrentUser(c)
+			memoID, err := parseID(c.Params("id"))
+			if err != nil {
+				return badRequest(c, "invalid memo id")
+			}
+			var req memoGroupShareRequest
+			if err := c.BodyParser(&req); err != nil {
+				return badRequest(c, "invalid request body")
+			}
+			groupID, err := parseID(strings.TrimPrefix(strings.TrimSpace(req.Group), "groups/"))
+			if err != nil {
+				return badRequest(c, "invalid group")
+			}
+			var memo service.MemoWithAttachments
+			if resync {
+				memo, err = memoService.ResyncMemoGroupShare(c.Context(), currentUser.ID, memoID, groupID)
+			} else {
+				memo, err = memoService.ShareMemoToGroup(c.Context(), currentUser.ID, memoID, groupID)
+			}
+			if err != nil {
+				if errors.Is(err, sql.ErrNoRows) {
+					return notFound(c, "memo or group not found")
+				}
+				return internalError(c, err)
+			}
+			return c.JSON(buildAPIMemo(memo))
+		}
+	}
+	api.Post("/memos/:id\\:shareToGroup", shareMemoToGroup(false))
+	api.Post("/memos/:id\\:resyncGroupShare", shareMemoToGroup(true))
+
+	setMemoCollaborator := func(add bool) fiber.Handler {
+		return func(c *fiber.Ctx) error {
+			currentUser := CurrentUser(c)
+			memoID, err := parseID(c.Params("id"))
+			if err != nil {
+				return badRequest(c, "invalid memo id")
+			}
+			collaboratorID, err := parseID(c.Params("userId"))
+			if err != nil {
+				return badRequest(c, "invalid user id")
+			}
+			var memo service.MemoWithAttachments
+			if add {
+				memo, err = memoService.AddCollaborator(c.Context(), currentUser.ID, memoID, collaboratorID)
+			} else {
+				memo, err = memoService.RemoveCollaborator(c.Context(), currentUser.ID, memoID, collaboratorID)
+			}
+			if err != nil {
+				switch {
+				case errors.Is(err, service.ErrInvalidCollaborator):
+					return badRequest(c, err.Error())
+				case errors.Is(err, service.ErrCollaboratorNotFound):
+					return notFound(c, "user not found")
+				case errors.Is(err, sql.ErrNoRows):
+					return notFound(c, "memo not found")
+				}
+				return internalError(c, err)
+			}
+			return c.JSON(buildAPIMemo(memo))
+		}
+	}
+	api.Post("/memos/:id/collaborators/:userId", setMemoCollaborator(true))
+	api.Delete("/memos/:id/collaborators/:userId", setMemoCollaborator(false))
+
+	api.Get("/memos/:id/events", func(c *fiber.Ctx) error {
+		currentUser := CurrentUser(c)
+		memoID, err := parseID(c.Params("id"))
+		if err != nil {
+			return badRequest(c, "invalid memo id")
+		}
+		events, err := memoService.ListMemoEvents(c.Context(), currentUser.ID, memoID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return notFound(c, "memo not found")
+			}
+			return internalError(c, err)
+		}
+
+		resp := listMemoEventsResponse{
+			Events: make([]apiMemoEvent, 0, len(events)),
+		}
+		for _, event := range events {
+			resp.Events = append(resp.Events, toAPIMemoEvent(event))
+		}
+		return c.JSON(resp)
+	})
+
+	api.Post("/search\\:reindex", func(c *fiber.Ctx) error {
+		currentUser := CurrentUser(c)
+		if !isAdminUser(currentUser) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"message": "forbidden"})
+		}
+		indexed, err := memoService.RebuildSearchIndex(c.Context(), nil)
+		if err != nil {
+			return internalError(c, err)
+		}
+		return c.JSON(searchReindexResponse{Indexed: indexed})
+	})
+
+	api.Get("/admin/storage/dedup", func(c *fiber.Ctx) error {
+		currentUser := CurrentUser(c)
+		if !isAdminUser(currentUser) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"message": "forbidden"})
+		}
+		pageSize, _ := strconv.Atoi(strings.TrimSpace(c.Query("pageSize", "50")))
+		groups, summary, nextToken, err := attachmentService.ListDedupReport(c.Context(), pageSize, c.Query("pageToken"))
+		if err != nil {
+			if strings.Contains(strings.ToLower(err.Error()), "pagetoken") {
+				return badRequest(c, "invalid pageToken")
+			}
+			return internalError(c, err)
+		}
+		resp := attachmentDedupResponse{
+			Groups:          make([]apiAttachmentDedupGroup, 0, len(groups)),
+			TotalGroups:     summary.Groups,
+			TotalReferences: summary.References,
+			TotalBytesSaved: summary.BytesSaved,
+			NextPageToken:   nextToken,
+		}
+		for _, group := range groups {
+			resp.Groups = append(resp.Groups, apiAttachmentDedupGroup{
+				ContentHash: group.ContentHash,
+				References:  group.References,
+				Size:        group.Size,
+				BytesSaved:  group.BytesSaved,
+			})
+		}
+		return c.JSON(resp)
+	})
+
 	api.Get("/groups", func(c *fiber.Ctx) error {
 		currentUser := CurrentUser(c)
 		groups, err := groupService.ListGroups(c.Context(), currentUser.ID)
@@ -577,42 +1550,114 @@ func NewRouter(
 			}
 			return internalError(c, err)
 		}
-		return c.JSON(toAPIGroup(group))
+		return c.JSON(toAPIGroup(group))
+	})
+
+	api.Get("/groups/:id", func(c *fiber.Ctx) error {
+		currentUser := CurrentUser(c)
+		groupID, err := parseID(c.Params("id"))
+		if err != nil {
+			return badRequest(c, "invalid group id")
+		}
+		group, err := groupService.GetGroup(c.Context(), currentUser.ID, groupID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return notFound(c, "group not found")
+			}
+			return internalError(c, err)
+		}
+		return c.JSON(toAPIGroup(group))
+	})
+
+	api.Patch("/groups/:id", func(c *fiber.Ctx) error {
+		currentUser := CurrentUser(c)
+		groupID, err := parseID(c.Params("id"))
+		if err != nil {
+			return badRequest(c, "invalid group id")
+		}
+		var req updateGroupRequest
+		if err := c.BodyParser(&req); err != nil {
+			return badRequest(c, "invalid request body")
+		}
+		group, err := groupService.UpdateGroup(c.Context(), currentUser.ID, groupID, req.Name, req.Description)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return notFound(c, "group not found")
+			}
+			if errors.Is(err, service.ErrGroupCreatorOnly) {
+				return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"message": "forbidden"})
+			}
+			return badRequest(c, err.Error())
+		}
+		return c.JSON(toAPIGroup(group))
+	})
+
+	api.Delete("/groups/:id", func(c *fiber.Ctx) error {
+		currentUser := CurrentUser(c)
+		groupID, err := parseID(c.Params("id"))
+		if err != nil {
+			return badRequest(c, "invalid group id")
+		}
+		if err := groupService.DeleteOrLeaveGroup(c.Context(), currentUser.ID, groupID); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return notFound(c, "group not found")
+			}
+			return internalError(c, err)
+		}
+		return c.SendStatus(fiber.StatusNoContent)
 	})
 
-	api.Patch("/groups/:id", func(c *fiber.Ctx) error {
+	api.Post("/groups/:id/members", func(c *fiber.Ctx) error {
 		currentUser := CurrentUser(c)
 		groupID, err := parseID(c.Params("id"))
 		if err != nil {
 			return badRequest(c, "invalid group id")
 		}
-		var req updateGroupRequest
+		var req addGroupMemberRequest
 		if err := c.BodyParser(&req); err != nil {
 			return badRequest(c, "invalid request body")
 		}
-		group, err := groupService.UpdateGroup(c.Context(), currentUser.ID, groupID, req.Name, req.Description)
+		member, err := userService.GetUserByIdentifier(c.Context(), req.User)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return notFound(c, "user not found")
+			}
+			return internalError(c, err)
+		}
+		group, err := groupService.AddGroupMember(c.Context(), currentUser.ID, groupID, member.ID)
 		if err != nil {
 			if errors.Is(err, sql.ErrNoRows) {
 				return notFound(c, "group not found")
 			}
-			return badRequest(c, err.Error())
+			if errors.Is(err, service.ErrGroupCreatorOnly) {
+				return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"message": "forbidden"})
+			}
+			return internalError(c, err)
 		}
 		return c.JSON(toAPIGroup(group))
 	})
 
-	api.Delete("/groups/:id", func(c *fiber.Ctx) error {
+	api.Delete("/groups/:id/members/:userId", func(c *fiber.Ctx) error {
 		currentUser := CurrentUser(c)
 		groupID, err := parseID(c.Params("id"))
 		if err != nil {
 			return badRequest(c, "invalid group id")
 		}
-		if err := groupService.DeleteOrLeaveGroup(c.Context(), currentUser.ID, groupID); err != nil {
+		memberID, err := parseID(c.Params("userId"))
+		if err != nil {
+			return badRequest(c, "invalid user id")
+		}
+		group, err := groupService.RemoveGroupMember(c.Context(), currentUser.ID, groupID, memberID)
+		if err != nil {
 			if errors.Is(err, sql.ErrNoRows) {
-				return notFound(c, "group not found")
+				return notFound(c, "group member not found")
 			}
-			return internalError(c, err)
+			if errors.Is(err, service.ErrGroupCreatorOnly) {
+				return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"message": "forbidden"})
+			}
+			return badRequest(c, err.Error())
 		}
-		return c.SendStatus(fiber.StatusNoContent)
+		return c.JSON(toAPIGroup(group))
 	})
 
 	api.Get("/groups/:id/messages", func(c *fiber.Ctx) error {
@@ -622,6 +1667,27 @@ func NewRouter(
 			return badRequest(c, "invalid group id")
 		}
 		pageSize, _ := strconv.Atoi(strings.TrimSpace(c.Query("pageSize", "50")))
+		if beforeRaw := strings.TrimSpace(c.Query("beforeId")); beforeRaw != "" {
+			beforeRaw = beforeRaw[strings.LastIndex(beforeRaw, "/")+1:]
+			beforeID, err := parseID(beforeRaw)
+			if err != nil || beforeID <= 0 {
+				return badRequest(c, "invalid beforeId")
+			}
+			messages, err := groupService.ListGroupMessagesBefore(c.Context(), currentUser.ID, groupID, pageSize, beforeID)
+			if err != nil {
+				if errors.Is(err, sql.ErrNoRows) {
+					return notFound(c, "group not found")
+				}
+				return internalError(c, err)
+			}
+			resp := listGroupMessagesResponse{
+				Messages: make([]apiGroupMessage, 0, len(messages)),
+			}
+			for _, msg := range messages {
+				resp.Messages = append(resp.Messages, toAPIGroupMessage(msg))
+			}
+			return c.JSON(resp)
+		}
 		pageToken := c.Query("pageToken", "")
 		messages, nextToken, err := groupService.ListGroupMessages(
 			c.Context(),
@@ -649,6 +1715,26 @@ func NewRouter(
 		return c.JSON(resp)
 	})
 
+	api.Get("/groups/:id/messages/:messageId", func(c *fiber.Ctx) error {
+		currentUser := CurrentUser(c)
+		groupID, err := parseID(c.Params("id"))
+		if err != nil {
+			return badRequest(c, "invalid group id")
+		}
+		messageID, err := parseID(c.Params("messageId"))
+		if err != nil {
+			return badRequest(c, "invalid message id")
+		}
+		message, err := groupService.GetGroupMessage(c.Context(), currentUser.ID, groupID, messageID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return notFound(c, "message not found")
+			}
+			return internalError(c, err)
+		}
+		return c.JSON(toAPIGroupMessage(message))
+	})
+
 	api.Post("/groups/:id/messages", func(c *fiber.Ctx) error {
 		currentUser := CurrentUser(c)
 		groupID, err := parseID(c.Params("id"))
@@ -743,6 +1829,21 @@ func NewRouter(
 			},
 		)
 		if err != nil {
+			var tooLarge *service.AttachmentTooLargeError
+			if errors.As(err, &tooLarge) {
+				return attachmentTooLarge(c, tooLarge)
+			}
+			if errors.Is(err, service.ErrQuotaExceeded) {
+				return quotaExceeded(c)
+			}
+			var typeNotAllowed *service.AttachmentTypeNotAllowedError
+			if errors.As(err, &typeNotAllowed) {
+				return writeError(c, fiber.StatusUnsupportedMediaType, "UNSUPPORTED_MEDIA_TYPE", typeNotAllowed.Error())
+			}
+			var inlineTooLarge *service.InlineAttachmentTooLargeError
+			if errors.As(err, &inlineTooLarge) {
+				return inlineAttachmentTooLarge(c, inlineTooLarge)
+			}
 			return badRequest(c, err.Error())
 		}
 		return c.JSON(buildAPIAttachment(attachment, ""))
@@ -772,9 +1873,21 @@ func NewRouter(
 				Size:      req.Size,
 				MemoName:  req.Memo,
 				Thumbnail: thumbnail,
+				SHA256:    req.SHA256,
 			},
 		)
 		if err != nil {
+			var tooLarge *service.AttachmentTooLargeError
+			if errors.As(err, &tooLarge) {
+				return attachmentTooLarge(c, tooLarge)
+			}
+			if errors.Is(err, service.ErrQuotaExceeded) {
+				return quotaExceeded(c)
+			}
+			var typeNotAllowed *service.AttachmentTypeNotAllowedError
+			if errors.As(err, &typeNotAllowed) {
+				return writeError(c, fiber.StatusUnsupportedMediaType, "UNSUPPORTED_MEDIA_TYPE", typeNotAllowed.Error())
+			}
 			return badRequest(c, err.Error())
 		}
 		progress, err := attachmentService.GetAttachmentUploadSessionProgress(c.Context(), session)
@@ -940,7 +2053,8 @@ func NewRouter(
 			if errors.Is(err, service.ErrUploadSessionNotFound) || errors.Is(err, sql.ErrNoRows) {
 				return notFound(c, "upload session not found")
 			}
-			if errors.Is(err, service.ErrUploadExceedsTotalSize) {
+			var exceedsMax *service.AttachmentExceedsMaxSizeError
+			if errors.Is(err, service.ErrUploadExceedsTotalSize) || errors.As(err, &exceedsMax) {
 				return badRequest(c, err.Error())
 			}
 			if errors.Is(err, service.ErrUploadChunkUnsupported) {
@@ -965,7 +2079,7 @@ func NewRouter(
 			return badRequest(c, "invalid upload id")
 		}
 
-		attachment, err := attachmentService.CompleteAttachmentUploadSession(c.Context(), currentUser.ID, uploadID)
+		attachment, err := attachmentService.CompleteAttachmentUploadSession(c.Context(), currentUser.ID, uploadID, c.Get(uploadChecksumHeader))
 		if err != nil {
 			if errors.Is(err, service.ErrUploadSessionNotFound) || errors.Is(err, sql.ErrNoRows) {
 				return notFound(c, "upload session not found")
@@ -975,6 +2089,19 @@ func NewRouter(
 					"message": "upload not complete",
 				})
 			}
+			if errors.Is(err, service.ErrInvalidChecksum) {
+				return badRequest(c, err.Error())
+			}
+			if errors.Is(err, service.ErrChecksumMismatch) {
+				return writeError(c, fiber.StatusUnprocessableEntity, "CHECKSUM_MISMATCH", err.Error())
+			}
+			if errors.Is(err, service.ErrQuotaExceeded) {
+				return quotaExceeded(c)
+			}
+			var typeNotAllowed *service.AttachmentTypeNotAllowedError
+			if errors.As(err, &typeNotAllowed) {
+				return writeError(c, fiber.StatusUnsupportedMediaType, "UNSUPPORTED_MEDIA_TYPE", typeNotAllowed.Error())
+			}
 			return internalError(c, err)
 		}
 		return c.JSON(buildAPIAttachment(attachment, ""))
@@ -997,6 +2124,49 @@ func NewRouter(
 		return c.SendStatus(fiber.StatusNoContent)
 	})
 
+	api.Patch("/attachments/:id", func(c *fiber.Ctx) error {
+		currentUser := CurrentUser(c)
+		attachmentID, err := parseID(c.Params("id"))
+		if err != nil {
+			return badRequest(c, "invalid attachment id")
+		}
+		var req updateAttachmentRequest
+		if err := c.BodyParser(&req); err != nil {
+			return badRequest(c, "invalid request body")
+		}
+		if req.Filename == nil {
+			return badRequest(c, "filename is required")
+		}
+		attachment, err := attachmentService.RenameAttachment(c.Context(), currentUser.ID, attachmentID, *req.Filename)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return notFound(c, "attachment not found")
+			}
+			return badRequest(c, err.Error())
+		}
+		return c.JSON(buildAPIAttachment(attachment, ""))
+	})
+
+	api.Get("/attachments/:id/memos", func(c *fiber.Ctx) error {
+		currentUser := CurrentUser(c)
+		attachmentID, err := parseID(c.Params("id"))
+		if err != nil {
+			return badRequest(c, "invalid attachment id")
+		}
+		memoIDs, err := attachmentService.ListAttachmentMemoIDs(c.Context(), currentUser.ID, attachmentID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return notFound(c, "attachment not found")
+			}
+			return internalError(c, err)
+		}
+		resp := listAttachmentMemosResponse{Memos: make([]string, 0, len(memoIDs))}
+		for _, memoID := range memoIDs {
+			resp.Memos = append(resp.Memos, models.Memo{ID: memoID}.Name())
+		}
+		return c.JSON(resp)
+	})
+
 	api.Delete("/attachments/:id", func(c *fiber.Ctx) error {
 		currentUser := CurrentUser(c)
 		attachmentID, err := parseID(c.Params("id"))
@@ -1012,7 +2182,7 @@ func NewRouter(
 		return c.SendStatus(fiber.StatusNoContent)
 	})
 
-	app.Get("/file/attachments/:id/thumbnail/:filename", AuthMiddleware(userService), func(c *fiber.Ctx) error {
+	app.Get("/file/attachments/:id/thumbnail/:filename", AuthMiddleware(userService), RequireScopes("attachments"), func(c *fiber.Ctx) error {
 		currentUser := CurrentUser(c)
 		attachmentID, err := parseID(c.Params("id"))
 		if err != nil {
@@ -1027,33 +2197,79 @@ func NewRouter(
 			return internalError(c, err)
 		}
 
-		if attachment.CreatorID != currentUser.ID {
+		if canView, err := attachmentService.CanViewAttachment(c.Context(), attachment, currentUser.ID); err != nil {
+			return internalError(c, err)
+		} else if !canView {
 			return c.SendStatus(fiber.StatusForbidden)
 		}
+		if attachmentService.ServesOriginalAsThumbnail(attachment) {
+			if directURL, ok, err := attachmentService.PresignAttachmentURL(c.Context(), attachment, presignContentDisposition(c, attachment.Filename)); err != nil {
+				return internalError(c, err)
+			} else if ok {
+				return c.Redirect(directURL, fiber.StatusTemporaryRedirect)
+			}
+			if setFileCacheHeaders(c, cfg.FileCacheMaxAge, attachmentETag(attachment)) {
+				return c.SendStatus(fiber.StatusNotModified)
+			}
+			rc, err := attachmentService.OpenAttachmentStream(c.Context(), attachment)
+			if err != nil {
+				return notFound(c, "thumbnail not found")
+			}
+			c.Set(fiber.HeaderContentType, attachment.Type)
+			c.Set(fiber.HeaderContentDisposition, fileContentDisposition(c, attachment.Filename))
+			c.Set(fiber.HeaderContentLength, models.Int64ToString(attachment.Size))
+			return c.SendStream(rc, int(attachment.Size))
+		}
 		if strings.TrimSpace(attachment.ThumbnailStorageKey) == "" {
 			return notFound(c, "thumbnail not found")
 		}
-		if directURL, ok, err := attachmentService.PresignAttachmentThumbnailURL(c.Context(), attachment); err != nil {
+
+		thumbnailType := strings.TrimSpace(attachment.ThumbnailType)
+		if thumbnailType == "" {
+			thumbnailType = "image/jpeg"
+		}
+		thumbnailFilename := strings.TrimSpace(attachment.ThumbnailFilename)
+		if thumbnailFilename == "" {
+			thumbnailFilename = attachment.Filename
+		}
+		if directURL, ok, err := attachmentService.PresignAttachmentThumbnailURL(c.Context(), attachment, presignContentDisposition(c, thumbnailFilename)); err != nil {
 			return internalError(c, err)
 		} else if ok {
 			return c.Redirect(directURL, fiber.StatusTemporaryRedirect)
 		}
 
+		if setFileCacheHeaders(c, cfg.FileCacheMaxAge, thumbnailETag(attachment)) {
+			return c.SendStatus(fiber.StatusNotModified)
+		}
+		if attachment.ThumbnailSize > 0 {
+			start, end, hasRange, err := parseSingleByteRange(c.Get(fiber.HeaderRange), attachment.ThumbnailSize)
+			if err != nil {
+				c.Set(fiber.HeaderAcceptRanges, "bytes")
+				c.Set(fiber.HeaderContentRange, fmt.Sprintf("bytes */%d", attachment.ThumbnailSize))
+				return c.SendStatus(fiber.StatusRequestedRangeNotSatisfiable)
+			}
+			c.Set(fiber.HeaderAcceptRanges, "bytes")
+			if hasRange {
+				rangedStream, err := attachmentService.OpenAttachmentThumbnailRangeStream(c.Context(), attachment, start, end)
+				if err != nil {
+					return notFound(c, "thumbnail not found")
+				}
+				length := end - start + 1
+				c.Set(fiber.HeaderContentType, thumbnailType)
+				c.Set(fiber.HeaderContentDisposition, fileContentDisposition(c, thumbnailFilename))
+				c.Set(fiber.HeaderContentRange, fmt.Sprintf("bytes %d-%d/%d", start, end, attachment.ThumbnailSize))
+				c.Set(fiber.HeaderContentLength, models.Int64ToString(length))
+				c.Status(fiber.StatusPartialContent)
+				return c.SendStream(rangedStream, int(length))
+			}
+		}
 		thumbnailStream, err := attachmentService.OpenAttachmentThumbnailStream(c.Context(), attachment)
 		if err != nil {
 			return notFound(c, "thumbnail not found")
 		}
 
-		thumbnailType := strings.TrimSpace(attachment.ThumbnailType)
-		if thumbnailType == "" {
-			thumbnailType = "image/jpeg"
-		}
-		thumbnailFilename := strings.TrimSpace(attachment.ThumbnailFilename)
-		if thumbnailFilename == "" {
-			thumbnailFilename = attachment.Filename
-		}
 		c.Set(fiber.HeaderContentType, thumbnailType)
-		c.Set(fiber.HeaderContentDisposition, inlineContentDisposition(thumbnailFilename))
+		c.Set(fiber.HeaderContentDisposition, fileContentDisposition(c, thumbnailFilename))
 		if attachment.ThumbnailSize > 0 {
 			c.Set(fiber.HeaderContentLength, models.Int64ToString(attachment.ThumbnailSize))
 			return c.SendStream(thumbnailStream, int(attachment.ThumbnailSize))
@@ -1061,7 +2277,7 @@ func NewRouter(
 		return c.SendStream(thumbnailStream)
 	})
 
-	app.Get("/file/avatars/:id", AuthMiddleware(userService), func(c *fiber.Ctx) error {
+	app.Get("/file/avatars/:id", AuthMiddleware(userService), RequireScopes("users"), func(c *fiber.Ctx) error {
 		userID, err := parseID(c.Params("id"))
 		if err != nil {
 			return badRequest(c, "invalid user id")
@@ -1084,19 +2300,19 @@ func NewRouter(
 			return c.Redirect(directURL, fiber.StatusTemporaryRedirect)
 		}
 
-		avatarStream, err := userService.OpenUserAvatarStream(c.Context(), userID)
+		avatarStream, avatarType, err := userService.OpenUserAvatarStream(c.Context(), userID)
 		if err != nil {
 			if errors.Is(err, os.ErrNotExist) {
 				return notFound(c, "avatar not found")
 			}
 			return internalError(c, err)
 		}
-		c.Set(fiber.HeaderContentType, "image/jpeg")
-		c.Set(fiber.HeaderContentDisposition, inlineContentDisposition(fmt.Sprintf("%d.jpg", userID)))
+		c.Set(fiber.HeaderContentType, avatarType)
+		c.Set(fiber.HeaderContentDisposition, inlineContentDisposition(fmt.Sprintf("%d%s", userID, avatarFileExtension(avatarType))))
 		return c.SendStream(avatarStream)
 	})
 
-	app.Get("/file/attachments/:id/:filename", AuthMiddleware(userService), func(c *fiber.Ctx) error {
+	app.Get("/file/attachments/:id/:filename", AuthMiddleware(userService), RequireScopes("attachments"), func(c *fiber.Ctx) error {
 		currentUser := CurrentUser(c)
 		attachmentID, err := parseID(c.Params("id"))
 		if err != nil {
@@ -1111,16 +2327,32 @@ func NewRouter(
 			return internalError(c, err)
 		}
 
-		if attachment.CreatorID != currentUser.ID {
+		if canView, err := attachmentService.CanViewAttachment(c.Context(), attachment, currentUser.ID); err != nil {
+			return internalError(c, err)
+		} else if !canView {
 			return c.SendStatus(fiber.StatusForbidden)
 		}
-		if directURL, ok, err := attachmentService.PresignAttachmentURL(c.Context(), attachment); err != nil {
+		if directURL, ok, err := attachmentService.PresignAttachmentURL(c.Context(), attachment, presignContentDisposition(c, attachment.Filename)); err != nil {
 			return internalError(c, err)
 		} else if ok {
 			return c.Redirect(directURL, fiber.StatusTemporaryRedirect)
 		}
 
-		start, end, hasRange, err := parseSingleByteRange(c.Get(fiber.HeaderRange), attachment.Size)
+		if setFileCacheHeaders(c, cfg.FileCacheMaxAge, attachmentETag(attachment)) {
+			return c.SendStatus(fiber.StatusNotModified)
+		}
+		rangeHeader := c.Get(fiber.HeaderRange)
+		var start, end int64
+		var hasRange bool
+		var ranges []byteRange
+		if isMultiByteRange(rangeHeader) {
+			ranges, err = parseMultiByteRanges(rangeHeader, attachment.Size)
+			if len(ranges) == 1 {
+				start, end, hasRange = ranges[0].start, ranges[0].end, true
+			}
+		} else {
+			start, end, hasRange, err = parseSingleByteRange(rangeHeader, attachment.Size)
+		}
 		if err != nil {
 			c.Set(fiber.HeaderAcceptRanges, "bytes")
 			c.Set(fiber.HeaderContentRange, fmt.Sprintf("bytes */%d", attachment.Size))
@@ -1129,8 +2361,13 @@ func NewRouter(
 
 		c.Set(fiber.HeaderAcceptRanges, "bytes")
 		c.Set(fiber.HeaderContentType, attachment.Type)
-		c.Set(fiber.HeaderContentDisposition, inlineContentDisposition(attachment.Filename))
+		c.Set(fiber.HeaderContentDisposition, fileContentDisposition(c, attachment.Filename))
 
+		if len(ranges) > 1 {
+			return sendMultipartByteRanges(c, ranges, attachment.Size, attachment.Type, func(start int64, end int64) (io.ReadCloser, error) {
+				return attachmentService.OpenAttachmentRangeStream(c.Context(), attachment, start, end)
+			})
+		}
 		if hasRange {
 			rangedStream, err := attachmentService.OpenAttachmentRangeStream(c.Context(), attachment, start, end)
 			if err != nil {
@@ -1159,6 +2396,9 @@ func NewRouter(
 
 func httpAccessLogMiddleware() fiber.Handler {
 	return func(c *fiber.Ctx) error {
+		if c.Path() == healthCheckPath {
+			return c.Next()
+		}
 		startedAt := time.Now()
 		err := c.Next()
 
@@ -1184,6 +2424,26 @@ func httpAccessLogMiddleware() fiber.Handler {
 	}
 }
 
+func healthErrorCategory(err error) string {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return "database_timeout"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	default:
+		return "database_unavailable"
+	}
+}
+
+func isAdminUser(user models.User) bool {
+	switch strings.ToUpper(strings.TrimSpace(user.Role)) {
+	case "HOST", "ADMIN":
+		return true
+	default:
+		return false
+	}
+}
+
 func toAPIUser(user models.User) apiUser {
 	role := strings.ToUpper(strings.TrimSpace(user.Role))
 	switch role {
@@ -1209,6 +2469,25 @@ func toAPIUser(user models.User) apiUser {
 	}
 }
 
+func toUserSettingResponse(user models.User) userSettingResponse {
+	return userSettingResponse{
+		GeneralSetting: generalSetting{
+			MemoVisibility:  string(user.DefaultVisibility),
+			DefaultPageSize: user.DefaultPageSize,
+		},
+	}
+}
+
+func toAPIUserSettings(settings service.UserSettings) apiUserSettings {
+	return apiUserSettings{
+		DefaultVisibility:       string(settings.DefaultVisibility),
+		DefaultPageSize:         settings.DefaultPageSize,
+		Timezone:                settings.Timezone,
+		Locale:                  settings.Locale,
+		SharedMemosLastSeenTime: formatMaybeTime(settings.SharedMemosLastSeenTime),
+	}
+}
+
 func toAPIGroup(group service.GroupWithMembers) apiGroup {
 	members := make([]apiGroupMember, 0, len(group.Members))
 	for _, member := range group.Members {
@@ -1257,23 +2536,42 @@ func toAPIMemo(
 		}
 		attachments = append(attachments, toAPIAttachment(attachment, memo.Memo.Name(), "", ""))
 	}
-	tags := memo.Memo.Payload.Tags
-	if tags == nil {
-		tags = []string{}
+	tags := make([]string, 0, len(memo.Memo.Payload.Tags))
+	var collaborators []string
+	for _, tag := range memo.Memo.Payload.Tags {
+		if rawID, ok := strings.CutPrefix(tag, "collab/"); ok {
+			collaborators = append(collaborators, "users/"+rawID)
+			continue
+		}
+		tags = append(tags, tag)
 	}
 	return apiMemo{
-		Name:        memo.Memo.Name(),
-		State:       string(memo.Memo.State),
-		Creator:     "users/" + models.Int64ToString(memo.Memo.CreatorID),
-		CreateTime:  formatTime(memo.Memo.CreateTime),
-		UpdateTime:  formatTime(memo.Memo.UpdateTime),
-		Content:     memo.Memo.Content,
-		Visibility:  string(memo.Memo.Visibility),
-		Pinned:      memo.Memo.Pinned,
-		Latitude:    memo.Memo.Latitude,
-		Longitude:   memo.Memo.Longitude,
-		Attachments: attachments,
-		Tags:        tags,
+		Name:          memo.Memo.Name(),
+		State:         string(memo.Memo.State),
+		Creator:       "users/" + models.Int64ToString(memo.Memo.CreatorID),
+		CreateTime:    formatTime(memo.Memo.CreateTime),
+		UpdateTime:    formatTime(memo.Memo.UpdateTime),
+		Content:       memo.Memo.Content,
+		Visibility:    string(memo.Memo.Visibility),
+		Pinned:        memo.Memo.Pinned,
+		Latitude:      memo.Memo.Latitude,
+		Longitude:     memo.Memo.Longitude,
+		Attachments:   attachments,
+		Tags:          tags,
+		Collaborators: collaborators,
+	}
+}
+
+func avatarFileExtension(contentType string) string {
+	switch contentType {
+	case "image/png":
+		return ".png"
+	case "image/gif":
+		return ".gif"
+	case "image/webp":
+		return ".webp"
+	default:
+		return ".jpg"
 	}
 }
 
@@ -1315,6 +2613,9 @@ func toAttachmentUploadSessionResponse(
 		Size:         models.Int64ToString(session.Size),
 		UploadedSize: models.Int64ToString(uploadedSize),
 		Memo:         session.MemoName,
+		// 完成时可通过该请求头提交内容摘要，服务端比对不一致会拒绝并丢弃会话
+		ChecksumHeader: uploadChecksumHeader,
+		SHA256:         session.ExpectedSHA256,
 	}
 	if multipart != nil {
 		resp.UploadMode = "DIRECT_MULTIPART"
@@ -1331,6 +2632,49 @@ func toAttachmentUploadSessionResponse(
 	return resp
 }
 
+func toAPIMemoEvent(event models.MemoChangeEvent) apiMemoEvent {
+	recipients := make([]string, 0, len(event.RecipientIDs))
+	for _, recipientID := range event.RecipientIDs {
+		recipients = append(recipients, "users/"+models.Int64ToString(recipientID))
+	}
+	return apiMemoEvent{
+		Name:       event.MemoName + "/events/" + models.Int64ToString(event.ID),
+		Memo:       event.MemoName,
+		EventType:  event.EventType,
+		EventTime:  formatTime(event.EventTime),
+		Recipients: recipients,
+		Broadcast:  event.Broadcast,
+	}
+}
+
+func toAPIMemoPrefilter(prefilter store.MemoSQLPrefilter) apiMemoPrefilter {
+	resp := apiMemoPrefilter{Unsatisfiable: prefilter.Unsatisfiable, ContentMatches: prefilter.ContentMatches}
+	for _, visibility := range prefilter.VisibilityIn {
+		resp.Visibilities = append(resp.Visibilities, string(visibility))
+	}
+	for _, state := range prefilter.StateIn {
+		resp.States = append(resp.States, string(state))
+	}
+	toGroups := func(groups []store.TagMatchGroup) [][]apiTagMatch {
+		var result [][]apiTagMatch
+		for _, group := range groups {
+			options := make([]apiTagMatch, 0, len(group.Options))
+			for _, option := range group.Options {
+				kind := "exact"
+				if option.Kind == store.TagMatchPrefix {
+					kind = "prefix"
+				}
+				options = append(options, apiTagMatch{Kind: kind, Value: option.Value})
+			}
+			result = append(result, options)
+		}
+		return result
+	}
+	resp.TagGroups = toGroups(prefilter.TagGroups)
+	resp.ExcludeTagGroups = toGroups(prefilter.ExcludeTagGroups)
+	return resp
+}
+
 func parseID(raw string) (int64, error) {
 	raw = strings.TrimSpace(raw)
 	if raw == "" {
@@ -1387,10 +2731,18 @@ func parseSingleByteRange(raw string, size int64) (start int64, end int64, hasRa
 	if spec == "" || strings.Contains(spec, ",") {
 		return 0, 0, true, fmt.Errorf("invalid range")
 	}
+	start, end, err = parseByteRangeSpec(spec, size)
+	if err != nil {
+		return 0, 0, true, err
+	}
+	return start, end, true, nil
+}
 
+// parseByteRangeSpec 解析单个 "start-end"、"start-" 或 "-suffix" 区间，结束位置超出资源大小时截断。
+func parseByteRangeSpec(spec string, size int64) (int64, int64, error) {
 	parts := strings.SplitN(spec, "-", 2)
 	if len(parts) != 2 {
-		return 0, 0, true, fmt.Errorf("invalid range")
+		return 0, 0, fmt.Errorf("invalid range")
 	}
 	left := strings.TrimSpace(parts[0])
 	right := strings.TrimSpace(parts[1])
@@ -1399,36 +2751,155 @@ func parseSingleByteRange(raw string, size int64) (start int64, end int64, hasRa
 		// Suffix-byte-range-spec: bytes=-N
 		suffixLength, parseErr := strconv.ParseInt(right, 10, 64)
 		if parseErr != nil || suffixLength <= 0 {
-			return 0, 0, true, fmt.Errorf("invalid suffix range")
+			return 0, 0, fmt.Errorf("invalid suffix range")
 		}
 		if suffixLength > size {
 			suffixLength = size
 		}
-		return size - suffixLength, size - 1, true, nil
+		return size - suffixLength, size - 1, nil
 	}
 
 	rangeStart, parseErr := strconv.ParseInt(left, 10, 64)
 	if parseErr != nil || rangeStart < 0 {
-		return 0, 0, true, fmt.Errorf("invalid range start")
+		return 0, 0, fmt.Errorf("invalid range start")
 	}
 	if rangeStart >= size {
-		return 0, 0, true, fmt.Errorf("range start out of bounds")
+		return 0, 0, errRangeNotSatisfiable
 	}
 
 	if right == "" {
-		return rangeStart, size - 1, true, nil
+		return rangeStart, size - 1, nil
 	}
 
 	rangeEnd, parseErr := strconv.ParseInt(right, 10, 64)
 	if parseErr != nil || rangeEnd < rangeStart {
-		return 0, 0, true, fmt.Errorf("invalid range end")
+		return 0, 0, fmt.Errorf("invalid range end")
 	}
 	if rangeEnd >= size {
 		rangeEnd = size - 1
 	}
-	return rangeStart, rangeEnd, true, nil
+	return rangeStart, rangeEnd, nil
+}
+
+var errRangeNotSatisfiable = errors.New("range start out of bounds")
+
+// maxByteRanges 限制单个请求的区间数量，超出时忽略 Range 返回完整内容，避免大量小区间放大开销。
+const maxByteRanges = 32
+
+type byteRange struct {
+	start int64
+	end   int64
+}
+
+func (r byteRange) length() int64 {
+	return r.end - r.start + 1
+}
+
+// isMultiByteRange 判断 Range 请求头是否包含多个区间。
+func isMultiByteRange(raw string) bool {
+	return strings.Contains(raw, ",")
+}
+
+// parseMultiByteRanges 解析逗号分隔的多个区间：越界的区间被忽略，全部越界时报错（416）；
+// 区间过多或合计长度超过资源本身（如大量重叠区间）时返回 nil，由调用方按完整内容响应。
+func parseMultiByteRanges(raw string, size int64) ([]byteRange, error) {
+	raw = strings.TrimSpace(raw)
+	if size <= 0 {
+		return nil, fmt.Errorf("invalid resource size")
+	}
+	if !strings.HasPrefix(raw, "bytes=") {
+		return nil, fmt.Errorf("unsupported range unit")
+	}
+	specs := strings.Split(strings.TrimPrefix(raw, "bytes="), ",")
+	ranges := make([]byteRange, 0, len(specs))
+	var total int64
+	for _, spec := range specs {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+		start, end, err := parseByteRangeSpec(spec, size)
+		if errors.Is(err, errRangeNotSatisfiable) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		ranges = append(ranges, byteRange{start: start, end: end})
+		total += end - start + 1
+	}
+	if len(ranges) == 0 {
+		return nil, errRangeNotSatisfiable
+	}
+	if len(ranges) > maxByteRanges || total > size {
+		return nil, nil
+	}
+	return ranges, nil
+}
+
+// sendMultipartByteRanges 以 multipart/byteranges 返回多个区间；各区间的读取流先全部打开，
+// 出错时还能正常返回错误，随后在后台按顺序写入边界与内容。
+func sendMultipartByteRanges(
+	c *fiber.Ctx,
+	ranges []byteRange,
+	size int64,
+	contentType string,
+	open func(start int64, end int64) (io.ReadCloser, error),
+) error {
+	readers := make([]io.ReadCloser, 0, len(ranges))
+	closeAll := func() {
+		for _, reader := range readers {
+			_ = reader.Close()
+		}
+	}
+	for _, r := range ranges {
+		reader, err := open(r.start, r.end)
+		if err != nil {
+			closeAll()
+			return internalError(c, err)
+		}
+		readers = append(readers, reader)
+	}
+
+	boundary := multipart.NewWriter(io.Discard).Boundary()
+	partHeaders := make([]string, len(ranges))
+	length := int64(0)
+	for i, r := range ranges {
+		partHeaders[i] = fmt.Sprintf(
+			"\r\n--%s\r\nContent-Type: %s\r\nContent-Range: bytes %d-%d/%d\r\n\r\n",
+			boundary, contentType, r.start, r.end, size,
+		)
+		length += int64(len(partHeaders[i])) + r.length()
+	}
+	closing := "\r\n--" + boundary + "--\r\n"
+	length += int64(len(closing))
+
+	pr, pw := io.Pipe()
+	go func() {
+		defer closeAll()
+		for i, r := range ranges {
+			if _, err := io.WriteString(pw, partHeaders[i]); err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+			if _, err := io.CopyN(pw, readers[i], r.length()); err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+		}
+		_, err := io.WriteString(pw, closing)
+		pw.CloseWithError(err)
+	}()
+
+	c.Set(fiber.HeaderContentType, "multipart/byteranges; boundary="+boundary)
+	c.Set(fiber.HeaderContentLength, models.Int64ToString(length))
+	c.Status(fiber.StatusPartialContent)
+	return c.SendStream(pr, int(length))
 }
 
+// memoWatchKeepAliveInterval 为 SSE 心跳间隔，用于穿过代理的空闲超时并检测客户端断开。
+var memoWatchKeepAliveInterval = 25 * time.Second
+
 func badRequest(c *fiber.Ctx, message string) error {
 	return writeError(c, fiber.StatusBadRequest, "BAD_REQUEST", message)
 }
@@ -1442,6 +2913,43 @@ func internalError(c *fiber.Ctx, err error) error {
 	return writeError(c, fiber.StatusInternalServerError, "INTERNAL_ERROR", "internal server error")
 }
 
+func attachmentTooLarge(c *fiber.Ctx, tooLarge *service.AttachmentTooLargeError) error {
+	return c.Status(fiber.StatusRequestEntityTooLarge).JSON(fiber.Map{
+		"code":      "PAYLOAD_TOO_LARGE",
+		"message":   tooLarge.Error(),
+		"requestId": requestID(c),
+		"pattern":   tooLarge.Pattern,
+		"limit":     tooLarge.Limit,
+	})
+}
+
+func quotaExceeded(c *fiber.Ctx) error {
+	return writeError(c, fiber.StatusRequestEntityTooLarge, "QUOTA_EXCEEDED", service.ErrQuotaExceeded.Error())
+}
+
+func inlineAttachmentTooLarge(c *fiber.Ctx, tooLarge *service.InlineAttachmentTooLargeError) error {
+	return c.Status(fiber.StatusRequestEntityTooLarge).JSON(fiber.Map{
+		"code":      "INLINE_UPLOAD_TOO_LARGE",
+		"message":   tooLarge.Error(),
+		"requestId": requestID(c),
+		"limit":     tooLarge.Limit,
+	})
+}
+
+func tooManyRequests(c *fiber.Ctx, retryAfter time.Duration) error {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	c.Set(fiber.HeaderRetryAfter, strconv.Itoa(seconds))
+	return writeError(c, fiber.StatusTooManyRequests, "TOO_MANY_REQUESTS", "too many sign-in attempts")
+}
+
+func serviceBusy(c *fiber.Ctx, retryAfter time.Duration) error {
+	c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	return writeError(c, fiber.StatusServiceUnavailable, "SERVICE_BUSY", "too many concurrent heavy operations, retry later")
+}
+
 func writeError(c *fiber.Ctx, status int, code string, message string) error {
 	return c.Status(status).JSON(fiber.Map{
 		"code":      code,
@@ -1462,14 +2970,80 @@ func requestID(c *fiber.Ctx) string {
 	return ""
 }
 
+// setFileCacheHeaders 为文件响应设置私有缓存与 ETag，请求的 If-None-Match 命中时返回 true，调用方应直接回 304。
+// 附件内容不可变，max-age 只用来限制权限变更（如 memo 改为私密）后浏览器缓存仍可见的时长。
+func setFileCacheHeaders(c *fiber.Ctx, maxAge time.Duration, etag string) bool {
+	if seconds := int64(maxAge / time.Second); seconds > 0 {
+		c.Set(fiber.HeaderCacheControl, fmt.Sprintf("private, max-age=%d", seconds))
+	} else {
+		c.Set(fiber.HeaderCacheControl, "private, no-cache")
+	}
+	if etag == "" {
+		return false
+	}
+	c.Set(fiber.HeaderETag, etag)
+	return etagMatches(c.Get(fiber.HeaderIfNoneMatch), etag)
+}
+
+// etagMatches 按 If-None-Match 的弱比较规则判断是否命中，支持逗号分隔的多个值与 *。
+func etagMatches(header string, etag string) bool {
+	target := strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == target {
+			return true
+		}
+	}
+	return false
+}
+
+// attachmentETag 以附件的内容摘要作为强 ETag；早期没有摘要的附件不返回 ETag。
+func attachmentETag(attachment models.Attachment) string {
+	contentHash := strings.TrimSpace(attachment.ContentHash)
+	if contentHash == "" {
+		return ""
+	}
+	return `"` + contentHash + `"`
+}
+
+// thumbnailETag 在内容摘要后附加缩略图大小，缩略图重新生成或转码为 variant 格式时 ETag 随之变化。
+func thumbnailETag(attachment models.Attachment) string {
+	contentHash := strings.TrimSpace(attachment.ContentHash)
+	if contentHash == "" {
+		return ""
+	}
+	return fmt.Sprintf(`"%s-thumb-%d"`, contentHash, attachment.ThumbnailSize)
+}
+
+// fileContentDisposition 返回 /file/ 附件响应的 Content-Disposition：默认 inline 便于浏览器预览，
+// 带 ?download=1 时改为 attachment 让浏览器直接下载。
+func fileContentDisposition(c *fiber.Ctx, filename string) string {
+	if c.QueryBool("download") {
+		return contentDisposition("attachment", filename)
+	}
+	return inlineContentDisposition(filename)
+}
+
+// presignContentDisposition 仅在要求下载时覆盖 S3 预签名响应的 Content-Disposition，预览沿用对象自身的元数据。
+func presignContentDisposition(c *fiber.Ctx, filename string) string {
+	if !c.QueryBool("download") {
+		return ""
+	}
+	return contentDisposition("attachment", filename)
+}
+
 func inlineContentDisposition(filename string) string {
+	return contentDisposition("inline", filename)
+}
+
+func contentDisposition(disposition string, filename string) string {
 	filename = sanitizeContentDispositionFilename(filename)
 	if filename == "" {
-		return "inline"
+		return disposition
 	}
-	value := mime.FormatMediaType("inline", map[string]string{"filename": filename})
+	value := mime.FormatMediaType(disposition, map[string]string{"filename": filename})
 	if value == "" {
-		return "inline"
+		return disposition
 	}
 	return value
 }
@@ -0,0 +1,70 @@
+package http
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/shinyes/keer/internal/config"
+)
+
+func TestUserStorageQuota(t *testing.T) {
+	app, _ := newTestAppWithConfig(t, config.Config{
+		KeerAPIVersion:   "0.1",
+		UserStorageQuota: 10,
+	}, true)
+	token := "demo-token"
+
+	upload := func(content []byte) (int, map[string]any) {
+		t.Helper()
+		body, _ := json.Marshal(map[string]any{
+			"filename": "blob.bin",
+			"type":     "application/octet-stream",
+			"content":  base64.StdEncoding.EncodeToString(content),
+		})
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/attachments", bytes.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req, 5000)
+		if err != nil {
+			t.Fatalf("create attachment request failed: %v", err)
+		}
+		defer resp.Body.Close()
+		raw, _ := io.ReadAll(resp.Body)
+		var payload map[string]any
+		_ = json.Unmarshal(raw, &payload)
+		return resp.StatusCode, payload
+	}
+
+	content := []byte("12345678")
+	if status, payload := upload(content); status != http.StatusOK {
+		t.Fatalf("expected first upload 200, got %d %v", status, payload)
+	}
+	// 相同内容去重复用已有对象，不额外占用配额
+	if status, payload := upload(content); status != http.StatusOK {
+		t.Fatalf("expected deduplicated upload 200, got %d %v", status, payload)
+	}
+	status, payload := upload([]byte("abcd"))
+	if status != http.StatusRequestEntityTooLarge || payload["code"] != "QUOTA_EXCEEDED" {
+		t.Fatalf("expected 413 QUOTA_EXCEEDED, got %d %v", status, payload)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/users/demo:getStats", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := app.Test(req, 5000)
+	if err != nil {
+		t.Fatalf("get user stats request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	var stats userStatsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+		t.Fatalf("decode user stats failed: %v", err)
+	}
+	if stats.StorageUsage == nil || stats.StorageUsage.UsedBytes != int64(len(content)) || stats.StorageUsage.QuotaBytes != 10 {
+		t.Fatalf("unexpected storage usage: %+v", stats.StorageUsage)
+	}
+}
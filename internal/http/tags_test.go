@@ -0,0 +1,130 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/shinyes/keer/internal/service"
+)
+
+func TestTagsEndpoints(t *testing.T) {
+	app, userService := newTestAppWithUserService(t, true, true)
+	token := "demo-token"
+
+	other, err := userService.CreateUser(context.Background(), nil, service.CreateUserInput{Username: "other01", Password: "other-password"}, true)
+	if err != nil {
+		t.Fatalf("CreateUser(other01) error = %v", err)
+	}
+	_, otherToken, err := userService.CreateAccessTokenForUser(context.Background(), "other01", "test")
+	if err != nil {
+		t.Fatalf("CreateAccessTokenForUser(other01) error = %v", err)
+	}
+
+	createMemoForGet(t, app, token, map[string]any{"content": "a", "tags": []string{"work", fmt.Sprintf("collab/%d", other.ID)}})
+	createMemoForGet(t, app, token, map[string]any{"content": "b", "tags": []string{"work", "book"}})
+	createMemoForGet(t, app, otherToken, map[string]any{"content": "c", "tags": []string{"work"}})
+
+	for _, want := range []int{http.StatusCreated, http.StatusOK} {
+		resp := postJSONForTest(t, app, token, "/api/v1/tags", map[string]any{"name": "someday"})
+		resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Fatalf("expected POST /tags %d, got %d", want, resp.StatusCode)
+		}
+	}
+
+	want := []apiTagCount{{Name: "book", MemoCount: 1}, {Name: "someday", MemoCount: 0}, {Name: "work", MemoCount: 2}}
+	if got := listTagsForTest(t, app, token); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+}
+
+func TestRenameTag(t *testing.T) {
+	app, _ := newTestAppWithUserService(t, true, true)
+	token := "demo-token"
+
+	createMemoForGet(t, app, token, map[string]any{"content": "a", "tags": []string{"work"}})
+	createMemoForGet(t, app, token, map[string]any{"content": "b", "tags": []string{"work", "wrok"}})
+	createMemoForGet(t, app, token, map[string]any{"content": "c", "tags": []string{"bok"}})
+
+	cases := []struct {
+		oldName string
+		newName string
+		want    int
+	}{
+		{oldName: "bok", newName: "book", want: http.StatusOK},
+		{oldName: "wrok", newName: "work", want: http.StatusOK},
+		{oldName: "missing", newName: "other", want: http.StatusNotFound},
+		{oldName: "book", newName: "collab/1", want: http.StatusBadRequest},
+		{oldName: "", newName: "book", want: http.StatusBadRequest},
+	}
+	for _, tc := range cases {
+		resp := postJSONForTest(t, app, token, "/api/v1/tags:rename", map[string]any{"oldName": tc.oldName, "newName": tc.newName})
+		resp.Body.Close()
+		if resp.StatusCode != tc.want {
+			t.Fatalf("rename %q -> %q: expected %d, got %d", tc.oldName, tc.newName, tc.want, resp.StatusCode)
+		}
+	}
+
+	want := []apiTagCount{{Name: "book", MemoCount: 1}, {Name: "work", MemoCount: 2}}
+	if got := listTagsForTest(t, app, token); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+}
+
+func TestDeleteTag(t *testing.T) {
+	app, _ := newTestAppWithUserService(t, true, true)
+	token := "demo-token"
+
+	createMemoForGet(t, app, token, map[string]any{"content": "a", "tags": []string{"work/project", "book"}})
+	createMemoForGet(t, app, token, map[string]any{"content": "b", "tags": []string{"work/project"}})
+
+	cases := []struct {
+		path string
+		want int
+	}{
+		{path: "/api/v1/tags/work/project", want: http.StatusNoContent},
+		{path: "/api/v1/tags/work%2Fproject", want: http.StatusNotFound},
+		{path: "/api/v1/tags/collab%2F1", want: http.StatusBadRequest},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodDelete, tc.path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := app.Test(req, 5000)
+		if err != nil {
+			t.Fatalf("DELETE %s failed: %v", tc.path, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tc.want {
+			t.Fatalf("DELETE %s: expected %d, got %d", tc.path, tc.want, resp.StatusCode)
+		}
+	}
+
+	want := []apiTagCount{{Name: "book", MemoCount: 1}}
+	if got := listTagsForTest(t, app, token); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+}
+
+func listTagsForTest(t *testing.T, app *fiber.App, token string) []apiTagCount {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/tags", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := app.Test(req, 5000)
+	if err != nil {
+		t.Fatalf("list tags request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected list tags 200, got %d", resp.StatusCode)
+	}
+	var listed listTagsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&listed); err != nil {
+		t.Fatalf("decode list tags response failed: %v", err)
+	}
+	return listed.Tags
+}
@@ -0,0 +1,121 @@
+package http
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestScopedTokenRestrictsEndpoints(t *testing.T) {
+	app, userService := newTestAppWithUserService(t, true, true)
+	ctx := context.Background()
+
+	_, scopedToken, err := userService.CreateAccessTokenForUserWithScopes(ctx, "demo", "integration", nil, []string{"memos:read", "memos:write"})
+	if err != nil {
+		t.Fatalf("CreateAccessTokenForUserWithScopes() error = %v", err)
+	}
+	_, tokensToken, err := userService.CreateAccessTokenForUserWithScopes(ctx, "demo", "token manager", nil, []string{"tokens:read"})
+	if err != nil {
+		t.Fatalf("CreateAccessTokenForUserWithScopes(tokens:read) error = %v", err)
+	}
+
+	cases := []struct {
+		method string
+		path   string
+		token  string
+		status int
+	}{
+		{method: http.MethodGet, path: "/api/v1/auth/me", token: scopedToken, status: http.StatusOK},
+		{method: http.MethodGet, path: "/api/v1/memos", token: scopedToken, status: http.StatusOK},
+		{method: http.MethodGet, path: "/api/v1/memos:export", token: scopedToken, status: http.StatusOK},
+		{method: http.MethodGet, path: "/api/v1/attachments", token: scopedToken, status: http.StatusForbidden},
+		{method: http.MethodGet, path: "/api/v1/groups", token: scopedToken, status: http.StatusForbidden},
+		{method: http.MethodGet, path: "/file/attachments/1/download", token: scopedToken, status: http.StatusForbidden},
+		{method: http.MethodGet, path: "/api/v1/attachments", token: "demo-token", status: http.StatusOK},
+		{method: http.MethodGet, path: "/api/v1/auth/tokens", token: scopedToken, status: http.StatusForbidden},
+		{method: http.MethodDelete, path: "/api/v1/auth/tokens/1", token: scopedToken, status: http.StatusForbidden},
+		{method: http.MethodGet, path: "/api/v1/auth/tokens", token: tokensToken, status: http.StatusOK},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(tc.method, tc.path, nil)
+		req.Header.Set("Authorization", "Bearer "+tc.token)
+		resp, err := app.Test(req, 5000)
+		if err != nil {
+			t.Fatalf("%s %s failed: %v", tc.method, tc.path, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tc.status {
+			t.Fatalf("%s %s: expected %d, got %d", tc.method, tc.path, tc.status, resp.StatusCode)
+		}
+	}
+
+	created := postJSONForTest(t, app, scopedToken, "/api/v1/memos", map[string]any{"content": "from integration"})
+	created.Body.Close()
+	if created.StatusCode != http.StatusOK {
+		t.Fatalf("expected memos:write token to create memo, got %d", created.StatusCode)
+	}
+	upload := postJSONForTest(t, app, scopedToken, "/api/v1/attachments", map[string]any{
+		"filename": "a.txt",
+		"type":     "text/plain",
+		"content":  base64.StdEncoding.EncodeToString([]byte("x")),
+	})
+	upload.Body.Close()
+	if upload.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected attachment upload without attachments:write to be forbidden, got %d", upload.StatusCode)
+	}
+}
+
+func TestScopedTokenTagsEndpoints(t *testing.T) {
+	app, userService := newTestAppWithUserService(t, true, true)
+	ctx := context.Background()
+
+	issue := func(scopes ...string) string {
+		t.Helper()
+		_, token, err := userService.CreateAccessTokenForUserWithScopes(ctx, "demo", "tags "+strings.Join(scopes, ","), nil, scopes)
+		if err != nil {
+			t.Fatalf("CreateAccessTokenForUserWithScopes(%v) error = %v", scopes, err)
+		}
+		return token
+	}
+	tagsRead := issue("tags:read")
+	tagsWrite := issue("tags:write")
+	memosWrite := issue("memos:write")
+	attachmentsRead := issue("attachments:read")
+
+	get := func(token string) int {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/tags", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := app.Test(req, 5000)
+		if err != nil {
+			t.Fatalf("GET /api/v1/tags failed: %v", err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	post := func(token string, name string) int {
+		t.Helper()
+		resp := postJSONForTest(t, app, token, "/api/v1/tags", map[string]any{"name": name})
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if status := get(tagsRead); status != http.StatusOK {
+		t.Fatalf("expected tags:read to list tags, got %d", status)
+	}
+	if status := get(attachmentsRead); status != http.StatusForbidden {
+		t.Fatalf("expected attachments:read to be forbidden on GET /tags, got %d", status)
+	}
+	if status := post(tagsRead, "reading"); status != http.StatusForbidden {
+		t.Fatalf("expected tags:read to be forbidden on POST /tags, got %d", status)
+	}
+	if status := post(tagsWrite, "reading"); status != http.StatusCreated {
+		t.Fatalf("expected tags:write to create tag, got %d", status)
+	}
+	if status := post(memosWrite, "books"); status != http.StatusCreated {
+		t.Fatalf("expected memos:write to keep creating tags, got %d", status)
+	}
+}
@@ -0,0 +1,76 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestListMemos_UsesUserDefaultPageSize(t *testing.T) {
+	app := newTestApp(t, true, true)
+	for i := 0; i < 3; i++ {
+		createMemoForGet(t, app, "demo-token", map[string]any{"content": fmt.Sprintf("memo %d", i)})
+	}
+
+	patchSetting := func(payload string) (int, userSettingResponse) {
+		req := httptest.NewRequest(http.MethodPatch, "/api/v1/users/demo/settings/GENERAL", bytes.NewBufferString(payload))
+		req.Header.Set("Authorization", "Bearer demo-token")
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req, 5000)
+		if err != nil {
+			t.Fatalf("patch setting request failed: %v", err)
+		}
+		defer resp.Body.Close()
+		var setting userSettingResponse
+		if resp.StatusCode == http.StatusOK {
+			if err := json.NewDecoder(resp.Body).Decode(&setting); err != nil {
+				t.Fatalf("decode setting response failed: %v", err)
+			}
+		}
+		return resp.StatusCode, setting
+	}
+	listMemos := func(query string) listMemosResponse {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/memos"+query, nil)
+		req.Header.Set("Authorization", "Bearer demo-token")
+		resp, err := app.Test(req, 5000)
+		if err != nil {
+			t.Fatalf("list memos request failed: %v", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			body, _ := io.ReadAll(resp.Body)
+			t.Fatalf("expected list 200, got %d body=%s", resp.StatusCode, string(body))
+		}
+		var listed listMemosResponse
+		if err := json.NewDecoder(resp.Body).Decode(&listed); err != nil {
+			t.Fatalf("decode list response failed: %v", err)
+		}
+		return listed
+	}
+
+	if status, _ := patchSetting(`{"generalSetting":{"defaultPageSize":201}}`); status != http.StatusBadRequest {
+		t.Fatalf("expected page size above cap to be rejected, got %d", status)
+	}
+	status, setting := patchSetting(`{"generalSetting":{"defaultPageSize":2}}`)
+	if status != http.StatusOK || setting.GeneralSetting.DefaultPageSize != 2 {
+		t.Fatalf("expected default page size 2, got status=%d setting=%+v", status, setting)
+	}
+
+	if listed := listMemos(""); len(listed.Memos) != 2 || listed.NextPageToken == "" {
+		t.Fatalf("expected user default page size 2 with next page, got %d memos token=%q", len(listed.Memos), listed.NextPageToken)
+	}
+	if listed := listMemos("?pageSize=3"); len(listed.Memos) != 3 {
+		t.Fatalf("expected explicit pageSize to override default, got %d memos", len(listed.Memos))
+	}
+
+	if status, setting := patchSetting(`{"generalSetting":{"defaultPageSize":0}}`); status != http.StatusOK || setting.GeneralSetting.DefaultPageSize != 0 {
+		t.Fatalf("expected default page size reset, got status=%d setting=%+v", status, setting)
+	}
+	if listed := listMemos(""); len(listed.Memos) != 3 {
+		t.Fatalf("expected server default page size after reset, got %d memos", len(listed.Memos))
+	}
+}
@@ -0,0 +1,81 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/shinyes/keer/internal/service"
+)
+
+func TestUserSettingsEndpoint(t *testing.T) {
+	app, userService := newTestAppWithUserService(t, true, true)
+	token := "demo-token"
+
+	settingsRequest := func(method string, user string, authToken string, payload string) (int, apiUserSettings, string) {
+		var body io.Reader
+		if payload != "" {
+			body = bytes.NewBufferString(payload)
+		}
+		req := httptest.NewRequest(method, "/api/v1/users/"+user+"/settings", body)
+		req.Header.Set("Authorization", "Bearer "+authToken)
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req, 5000)
+		if err != nil {
+			t.Fatalf("%s settings request failed: %v", method, err)
+		}
+		defer resp.Body.Close()
+		raw, _ := io.ReadAll(resp.Body)
+		var settings apiUserSettings
+		if resp.StatusCode == http.StatusOK {
+			if err := json.Unmarshal(raw, &settings); err != nil {
+				t.Fatalf("decode settings response failed: %v body=%s", err, raw)
+			}
+		}
+		return resp.StatusCode, settings, string(raw)
+	}
+
+	status, settings, body := settingsRequest(http.MethodGet, "demo", token, "")
+	if status != http.StatusOK || settings.DefaultVisibility != "PRIVATE" || settings.Timezone != "" || settings.Locale != "" {
+		t.Fatalf("unexpected initial settings: %d body=%s", status, body)
+	}
+
+	status, settings, body = settingsRequest(http.MethodPatch, "demo", token, `{"defaultVisibility":"public","timezone":"UTC","locale":"zh-CN"}`)
+	if status != http.StatusOK || settings.DefaultVisibility != "PUBLIC" || settings.Timezone != "UTC" || settings.Locale != "zh-CN" {
+		t.Fatalf("unexpected patched settings: %d body=%s", status, body)
+	}
+
+	status, settings, body = settingsRequest(http.MethodPatch, "demo", token, `{"defaultPageSize":5,"locale":""}`)
+	if status != http.StatusOK || settings.DefaultPageSize != 5 || settings.Locale != "" || settings.Timezone != "UTC" {
+		t.Fatalf("expected partial update to keep other fields, got %d body=%s", status, body)
+	}
+
+	for _, payload := range []string{
+		`{"timezone":"Mars/Olympus"}`,
+		`{"locale":"not a locale"}`,
+		`{"defaultVisibility":"SECRET"}`,
+		`{"defaultPageSize":500}`,
+	} {
+		if status, _, body := settingsRequest(http.MethodPatch, "demo", token, payload); status != http.StatusBadRequest {
+			t.Fatalf("expected %s to be rejected, got %d body=%s", payload, status, body)
+		}
+	}
+	if _, settings, _ := settingsRequest(http.MethodGet, "demo", token, ""); settings.Timezone != "UTC" || settings.DefaultVisibility != "PUBLIC" {
+		t.Fatalf("expected rejected patches to leave settings unchanged, got %+v", settings)
+	}
+
+	if _, err := userService.CreateUser(context.Background(), nil, service.CreateUserInput{Username: "other01", Password: "other-password"}, true); err != nil {
+		t.Fatalf("CreateUser(other01) error = %v", err)
+	}
+	_, otherToken, err := userService.CreateAccessTokenForUser(context.Background(), "other01", "test")
+	if err != nil {
+		t.Fatalf("CreateAccessTokenForUser(other01) error = %v", err)
+	}
+	if status, _, body := settingsRequest(http.MethodGet, "demo", otherToken, ""); status != http.StatusForbidden {
+		t.Fatalf("expected other user to be forbidden, got %d body=%s", status, body)
+	}
+}
@@ -48,20 +48,26 @@ type User struct {
 	PasswordHash      string
 	Role              string
 	DefaultVisibility Visibility
-	CreateTime        time.Time
-	UpdateTime        time.Time
+	// DefaultPageSize 为用户偏好的列表分页大小，0 表示使用服务端默认值。
+	DefaultPageSize int
+	CreateTime      time.Time
+	UpdateTime      time.Time
 }
 
 type PersonalAccessToken struct {
-	ID          int64
-	UserID      int64
-	TokenPrefix string
-	TokenHash   string
-	Description string
-	CreatedAt   time.Time
-	LastUsedAt  *time.Time
-	ExpiresAt   *time.Time
-	RevokedAt   *time.Time
+	ID     int64
+	UserID int64
+	// TokenPrefix 只用于展示，不同令牌可能前缀相同，定位令牌应使用 ID。
+	TokenPrefix       string
+	TokenHash         string
+	Description       string
+	CreatedAt         time.Time
+	LastUsedAt        *time.Time
+	ExpiresAt         *time.Time
+	RevokedAt         *time.Time
+	Scopes            []string
+	LastUsedIP        string
+	LastUsedUserAgent string
 }
 
 type Memo struct {
@@ -78,6 +84,18 @@ type Memo struct {
 	Payload    MemoPayload
 }
 
+type MemoChangeEvent struct {
+	ID           int64
+	MemoID       int64
+	MemoName     string
+	CreatorID    int64
+	EventType    string
+	EventTime    time.Time
+	RecipientIDs []int64
+	// Broadcast 表示事件面向所有用户（如公开备忘录变为私有），不逐个记录接收者。
+	Broadcast bool
+}
+
 type Group struct {
 	ID          int64
 	GroupName   string
@@ -112,12 +130,14 @@ type GroupMessage struct {
 }
 
 type Attachment struct {
-	ID                   int64
-	CreatorID            int64
-	Filename             string
-	ExternalLink         string
-	Type                 string
-	Size                 int64
+	ID           int64
+	CreatorID    int64
+	Filename     string
+	ExternalLink string
+	Type         string
+	Size         int64
+	// ContentHash 为内容摘要（直传时为上传引用摘要），附件内容不可变，可直接用作 ETag。
+	ContentHash          string
 	StorageType          string
 	StorageKey           string
 	ThumbnailFilename    string
@@ -139,6 +159,7 @@ type AttachmentUploadSession struct {
 	ThumbnailFilename string
 	ThumbnailType     string
 	ThumbnailTempPath string
+	ExpectedSHA256    string
 	ReceivedSize      int64
 	CreateTime        time.Time
 	UpdateTime        time.Time
@@ -0,0 +1,158 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+type AttachmentTooLargeError struct {
+	Type    string
+	Pattern string
+	Limit   int64
+}
+
+func (e *AttachmentTooLargeError) Error() string {
+	return fmt.Sprintf("attachment of type %s exceeds size limit of %d bytes for %s", e.Type, e.Limit, e.Pattern)
+}
+
+// InlineAttachmentTooLargeError 表示 base64 内联上传解码后超出内联上限，客户端应改用分片上传会话。
+type InlineAttachmentTooLargeError struct {
+	Size  int64
+	Limit int64
+}
+
+func (e *InlineAttachmentTooLargeError) Error() string {
+	return fmt.Sprintf("inline attachment of %d bytes exceeds inline upload limit of %d bytes; use an upload session instead", e.Size, e.Limit)
+}
+
+// AttachmentExceedsMaxSizeError 表示附件大小超过全局上限，与按类型的上限不同，它对所有类型生效。
+type AttachmentExceedsMaxSizeError struct {
+	Size  int64
+	Limit int64
+}
+
+func (e *AttachmentExceedsMaxSizeError) Error() string {
+	return fmt.Sprintf("attachment of %d bytes exceeds maximum attachment size of %d bytes", e.Size, e.Limit)
+}
+
+// SetMaxAttachmentSize 设置单个附件的全局大小上限，0 表示不限制。
+func (s *AttachmentService) SetMaxAttachmentSize(limit int64) {
+	if limit < 0 {
+		limit = 0
+	}
+	s.maxAttachmentSize = limit
+}
+
+func (s *AttachmentService) checkMaxAttachmentSize(size int64) error {
+	if s.maxAttachmentSize <= 0 || size <= s.maxAttachmentSize {
+		return nil
+	}
+	return &AttachmentExceedsMaxSizeError{Size: size, Limit: s.maxAttachmentSize}
+}
+
+// SetUserStorageQuota 设置每个用户附件可占用的存储字节数，0 表示不限制。
+func (s *AttachmentService) SetUserStorageQuota(quota int64) {
+	if quota < 0 {
+		quota = 0
+	}
+	s.userStorageQuota = quota
+}
+
+// GetStorageUsage 返回用户附件已占用的字节数与配额，配额为 0 表示不限制。
+func (s *AttachmentService) GetStorageUsage(ctx context.Context, userID int64) (int64, int64, error) {
+	used, err := s.store.SumAttachmentSizesByCreator(ctx, userID)
+	if err != nil {
+		return 0, 0, err
+	}
+	return used, s.userStorageQuota, nil
+}
+
+// checkStorageQuota 检查写入 incoming 字节的新存储对象后是否超出配额；复用已有对象的去重上传不应调用。
+func (s *AttachmentService) checkStorageQuota(ctx context.Context, userID int64, incoming int64) error {
+	if s.userStorageQuota <= 0 {
+		return nil
+	}
+	used, err := s.store.SumAttachmentSizesByCreator(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if used+incoming > s.userStorageQuota {
+		return ErrQuotaExceeded
+	}
+	return nil
+}
+
+// SetInlineUploadLimit 设置 base64 内联上传解码后的大小上限，0 表示不单独限制。
+func (s *AttachmentService) SetInlineUploadLimit(limit int64) {
+	if limit < 0 {
+		limit = 0
+	}
+	s.inlineUploadLimit = limit
+}
+
+// checkInlineUploadLimit 在解码前按 base64 长度推算解码后的大小，超限时不再分配解码缓冲区。
+func (s *AttachmentService) checkInlineUploadLimit(payload string) error {
+	if s.inlineUploadLimit <= 0 {
+		return nil
+	}
+	size := base64DecodedSize(payload)
+	if size <= s.inlineUploadLimit {
+		return nil
+	}
+	return &InlineAttachmentTooLargeError{Size: size, Limit: s.inlineUploadLimit}
+}
+
+// base64DecodedSize 计算标准 base64 解码后的字节数；解码器会跳过换行，这里同样不计入。
+func base64DecodedSize(payload string) int64 {
+	n := int64(len(payload) - strings.Count(payload, "\n") - strings.Count(payload, "\r"))
+	size := n / 4 * 3
+	trimmed := strings.TrimRight(payload, "\r\n")
+	switch {
+	case strings.HasSuffix(trimmed, "=="):
+		size -= 2
+	case strings.HasSuffix(trimmed, "="):
+		size--
+	}
+	return size
+}
+
+// SetSizeLimitsByType 设置按类型的附件大小上限，键为 image/png、image/* 或 */* 形式的类型模式。
+func (s *AttachmentService) SetSizeLimitsByType(limits map[string]int64) {
+	normalized := make(map[string]int64, len(limits))
+	for pattern, limit := range limits {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		if pattern == "" || limit <= 0 {
+			continue
+		}
+		normalized[pattern] = limit
+	}
+	s.sizeLimitsByType = normalized
+}
+
+// sizeLimitForType 按最具体的模式匹配：完整类型优先，其次 type/*，最后 */*。
+func (s *AttachmentService) sizeLimitForType(contentType string) (string, int64, bool) {
+	if len(s.sizeLimitsByType) == 0 {
+		return "", 0, false
+	}
+	mediaType := normalizeMediaType(contentType)
+	candidates := []string{mediaType}
+	if major, _, ok := strings.Cut(mediaType, "/"); ok {
+		candidates = append(candidates, major+"/*")
+	}
+	candidates = append(candidates, "*/*")
+	for _, pattern := range candidates {
+		if limit, ok := s.sizeLimitsByType[pattern]; ok {
+			return pattern, limit, true
+		}
+	}
+	return "", 0, false
+}
+
+func (s *AttachmentService) checkSizeLimit(contentType string, size int64) error {
+	pattern, limit, ok := s.sizeLimitForType(contentType)
+	if !ok || size <= limit {
+		return nil
+	}
+	return &AttachmentTooLargeError{Type: contentType, Pattern: pattern, Limit: limit}
+}
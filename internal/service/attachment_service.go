@@ -23,31 +23,71 @@ import (
 )
 
 type AttachmentService struct {
-	store   *store.SQLStore
-	storage storage.Store
-	tempDir string
+	store              *store.SQLStore
+	storage            storage.Store
+	tempDir            string
+	thumbnailsDisabled bool
+	// thumbnailProgressive 为 true 时 JPEG 缩略图以渐进式编码。
+	thumbnailProgressive bool
+	sizeLimitsByType     map[string]int64
+	inlineUploadLimit    int64
+	maxAttachmentSize    int64
+	// userStorageQuota 为每个用户附件可占用的字节数，0 表示不限制。
+	userStorageQuota int64
+	allowedTypes     []string
+	blockedTypes     []string
+	// ffmpegPath 非空时为视频附件在服务端抽帧生成缩略图。
+	ffmpegPath string
+	// uploadSessionTTL 为上传会话自最后一次更新起的保留时长，超时后由清理任务回收。
+	uploadSessionTTL time.Duration
+	// 以下为 S3 预签名地址的有效期。
+	directUploadURLTTL    time.Duration
+	multipartUploadURLTTL time.Duration
+	directDownloadURLTTL  time.Duration
 }
 
 const (
-	attachmentNanoIDLength     = 8
-	attachmentStorageKeyTries  = 8
-	attachmentNanoIDAlphabet   = "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
-	uploadSessionTTL           = 24 * time.Hour
-	uploadSessionCleanupBatch  = 200
-	directUploadURLTTL         = 15 * time.Minute
-	multipartUploadURLTTL      = 15 * time.Minute
-	directDownloadURLTTL       = 10 * time.Minute
-	directSessionPathPrefix    = "__S3_DIRECT__:"
-	multipartSessionPathPrefix = "__S3_MULTIPART__:"
-	s3MultipartPartSizeBytes   = 8 * 1024 * 1024
+	attachmentNanoIDLength       = 8
+	attachmentStorageKeyTries    = 8
+	attachmentNanoIDAlphabet     = "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	defaultUploadSessionTTL      = 24 * time.Hour
+	uploadSessionCleanupBatch    = 200
+	defaultDirectUploadURLTTL    = 15 * time.Minute
+	defaultMultipartUploadURLTTL = 15 * time.Minute
+	defaultDirectDownloadURLTTL  = 10 * time.Minute
+	directSessionPathPrefix      = "__S3_DIRECT__:"
+	multipartSessionPathPrefix   = "__S3_MULTIPART__:"
+	s3MultipartPartSizeBytes     = 8 * 1024 * 1024
 )
 
-func NewAttachmentService(s *store.SQLStore, fileStorage storage.Store) *AttachmentService {
+// NewAttachmentService 创建附件服务；uploadSessionTTL 非正数时使用默认的 24 小时。
+func NewAttachmentService(s *store.SQLStore, fileStorage storage.Store, uploadSessionTTL time.Duration) *AttachmentService {
 	tempDir := filepath.Join(os.TempDir(), "keer", "upload_sessions")
+	if uploadSessionTTL <= 0 {
+		uploadSessionTTL = defaultUploadSessionTTL
+	}
 	return &AttachmentService{
-		store:   s,
-		storage: fileStorage,
-		tempDir: tempDir,
+		store:            s,
+		storage:          fileStorage,
+		tempDir:          tempDir,
+		uploadSessionTTL: uploadSessionTTL,
+
+		directUploadURLTTL:    defaultDirectUploadURLTTL,
+		multipartUploadURLTTL: defaultMultipartUploadURLTTL,
+		directDownloadURLTTL:  defaultDirectDownloadURLTTL,
+	}
+}
+
+// SetPresignTTLs 设置 S3 直传、分片直传与下载预签名地址的有效期，非正数保留默认值。
+func (s *AttachmentService) SetPresignTTLs(upload time.Duration, multipartUpload time.Duration, download time.Duration) {
+	if upload > 0 {
+		s.directUploadURLTTL = upload
+	}
+	if multipartUpload > 0 {
+		s.multipartUploadURLTTL = multipartUpload
+	}
+	if download > 0 {
+		s.directDownloadURLTTL = download
 	}
 }
 
@@ -64,6 +104,8 @@ type CreateAttachmentUploadSessionInput struct {
 	Size      int64
 	MemoName  *string
 	Thumbnail *CreateAttachmentUploadSessionThumbnailInput
+	// SHA256 为客户端预先声明的十六进制内容摘要，完成上传时据此校验，空串表示不校验。
+	SHA256 string
 }
 
 type CreateAttachmentUploadSessionThumbnailInput struct {
@@ -79,6 +121,10 @@ var (
 	ErrUploadNotComplete      = errors.New("upload not complete")
 	ErrUploadChunkUnsupported = errors.New("upload chunk is not supported for this session")
 	ErrMultipartPartInvalid   = errors.New("multipart upload part is invalid")
+	ErrTooManyAttachments     = errors.New("too many attachments")
+	ErrQuotaExceeded          = errors.New("storage quota exceeded")
+	ErrInvalidChecksum        = errors.New("checksum must be a hex-encoded sha256 digest")
+	ErrChecksumMismatch       = errors.New("uploaded content does not match checksum")
 )
 
 type UploadOffsetMismatchError struct {
@@ -121,14 +167,30 @@ func (s *AttachmentService) CreateAttachment(ctx context.Context, userID int64,
 	if contentType == "" {
 		contentType = "application/octet-stream"
 	}
+	if err := s.checkAttachmentType(contentType); err != nil {
+		return models.Attachment{}, err
+	}
 	payload := strings.TrimSpace(input.Content)
 	if payload == "" {
 		return models.Attachment{}, fmt.Errorf("content cannot be empty")
 	}
+	if err := s.checkInlineUploadLimit(payload); err != nil {
+		return models.Attachment{}, err
+	}
+	// 按 base64 长度推算解码后的大小，超过全局上限时不必再解码
+	if err := s.checkMaxAttachmentSize(base64DecodedSize(payload)); err != nil {
+		return models.Attachment{}, err
+	}
 	data, err := base64.StdEncoding.DecodeString(payload)
 	if err != nil {
 		return models.Attachment{}, fmt.Errorf("invalid base64 content")
 	}
+	if err := s.checkSizeLimit(contentType, int64(len(data))); err != nil {
+		return models.Attachment{}, err
+	}
+	if err := s.checkSniffedAttachmentType(data); err != nil {
+		return models.Attachment{}, err
+	}
 	contentHash := hashAttachmentContent(data)
 
 	var memoID *int64
@@ -155,6 +217,9 @@ func (s *AttachmentService) CreateAttachment(ctx context.Context, userID int64,
 		storageKey = existing.StorageKey
 		size = existing.Size
 	} else {
+		if err := s.checkStorageQuota(ctx, userID, int64(len(data))); err != nil {
+			return models.Attachment{}, err
+		}
 		storageKey, err = s.newAttachmentStorageKey(ctx, userID, filename)
 		if err != nil {
 			return models.Attachment{}, err
@@ -202,7 +267,7 @@ func (s *AttachmentService) CreateAttachment(ctx context.Context, userID int64,
 }
 
 func (s *AttachmentService) CreateAttachmentUploadSession(ctx context.Context, userID int64, input CreateAttachmentUploadSessionInput) (models.AttachmentUploadSession, error) {
-	_ = s.CleanupExpiredUploadSessions(ctx)
+	_, _ = s.CleanupExpiredUploadSessions(ctx)
 
 	filename := sanitizeFilename(input.Filename)
 	if filename == "" {
@@ -212,9 +277,26 @@ func (s *AttachmentService) CreateAttachmentUploadSession(ctx context.Context, u
 	if contentType == "" {
 		contentType = "application/octet-stream"
 	}
+	if err := s.checkAttachmentType(contentType); err != nil {
+		return models.AttachmentUploadSession{}, err
+	}
 	if input.Size <= 0 {
 		return models.AttachmentUploadSession{}, fmt.Errorf("size must be positive")
 	}
+	if err := s.checkMaxAttachmentSize(input.Size); err != nil {
+		return models.AttachmentUploadSession{}, err
+	}
+	// 创建会话时先按声明大小预检配额，避免传完大文件才被拒绝；完成时会按去重结果再校验
+	if err := s.checkStorageQuota(ctx, userID, input.Size); err != nil {
+		return models.AttachmentUploadSession{}, err
+	}
+	if err := s.checkSizeLimit(contentType, input.Size); err != nil {
+		return models.AttachmentUploadSession{}, err
+	}
+	expectedSHA256, err := normalizeSHA256Checksum(input.SHA256)
+	if err != nil {
+		return models.AttachmentUploadSession{}, err
+	}
 
 	thumbnailFilename := ""
 	thumbnailType := ""
@@ -305,6 +387,7 @@ func (s *AttachmentService) CreateAttachmentUploadSession(ctx context.Context, u
 			ThumbnailFilename: thumbnailFilename,
 			ThumbnailType:     thumbnailType,
 			ThumbnailTempPath: thumbnailTempPath,
+			ExpectedSHA256:    expectedSHA256,
 			ReceivedSize:      0,
 			CreateTime:        now,
 			UpdateTime:        now,
@@ -346,6 +429,7 @@ func (s *AttachmentService) CreateAttachmentUploadSession(ctx context.Context, u
 		ThumbnailFilename: thumbnailFilename,
 		ThumbnailType:     thumbnailType,
 		ThumbnailTempPath: thumbnailTempPath,
+		ExpectedSHA256:    expectedSHA256,
 		ReceivedSize:      0,
 		CreateTime:        now,
 		UpdateTime:        now,
@@ -360,9 +444,11 @@ func (s *AttachmentService) CreateAttachmentUploadSession(ctx context.Context, u
 	return session, nil
 }
 
-func (s *AttachmentService) CleanupExpiredUploadSessions(ctx context.Context) error {
-	cutoff := time.Now().UTC().Add(-uploadSessionTTL)
+// CleanupExpiredUploadSessions 删除超过保留时长未更新的上传会话及其临时数据，返回回收的会话数。
+func (s *AttachmentService) CleanupExpiredUploadSessions(ctx context.Context) (int, error) {
+	cutoff := time.Now().UTC().Add(-s.uploadSessionTTL)
 	var firstErr error
+	removed := 0
 
 	for {
 		sessions, err := s.store.ListAttachmentUploadSessionsUpdatedBefore(ctx, cutoff, uploadSessionCleanupBatch)
@@ -383,6 +469,7 @@ func (s *AttachmentService) CleanupExpiredUploadSessions(ctx context.Context) er
 				}
 				continue
 			}
+			removed++
 			if multipart, ok := decodeMultipartSessionPath(session.TempPath); ok {
 				if s3Store, s3OK := s.storage.(*storage.S3Store); s3OK {
 					_ = s3Store.AbortMultipartUpload(ctx, multipart.StorageKey, multipart.MultipartUploadID)
@@ -402,7 +489,7 @@ func (s *AttachmentService) CleanupExpiredUploadSessions(ctx context.Context) er
 		}
 	}
 
-	return firstErr
+	return removed, firstErr
 }
 
 func (s *AttachmentService) GetAttachmentUploadSession(ctx context.Context, userID int64, uploadID string) (models.AttachmentUploadSession, error) {
@@ -428,7 +515,7 @@ func (s *AttachmentService) GetDirectUploadSession(ctx context.Context, session
 	if !ok {
 		return nil, nil
 	}
-	uploadURL, err := s3Store.PresignPutObjectURL(ctx, storageKey, session.Type, directUploadURLTTL)
+	uploadURL, err := s3Store.PresignPutObjectURL(ctx, storageKey, session.Type, s.directUploadURLTTL)
 	if err != nil {
 		return nil, err
 	}
@@ -521,7 +608,7 @@ func (s *AttachmentService) CreateMultipartPartUploadURL(
 		multipart.StorageKey,
 		multipart.MultipartUploadID,
 		requestedPartNumber,
-		multipartUploadURLTTL,
+		s.multipartUploadURLTTL,
 	)
 	if err != nil {
 		return nil, err
@@ -536,37 +623,43 @@ func (s *AttachmentService) CreateMultipartPartUploadURL(
 	}, nil
 }
 
-func (s *AttachmentService) PresignAttachmentURL(ctx context.Context, attachment models.Attachment) (string, bool, error) {
-	if !strings.EqualFold(strings.TrimSpace(attachment.StorageType), "S3") {
+// PresignAttachmentURL 为 S3/GCS 上的附件生成预签名下载地址；contentDisposition 非空时覆盖响应的 Content-Disposition。
+// 附件记录的存储类型须与当前后端一致，否则对象不在当前存储桶中，返回 false 由服务端回退处理。
+func (s *AttachmentService) PresignAttachmentURL(ctx context.Context, attachment models.Attachment, contentDisposition string) (string, bool, error) {
+	if !strings.EqualFold(strings.TrimSpace(attachment.StorageType), storageTypeName(s.storage)) {
 		return "", false, nil
 	}
-	s3Store, ok := s.storage.(*storage.S3Store)
+	presigner, ok := s.storage.(storage.Presigner)
 	if !ok {
 		return "", false, nil
 	}
 	if strings.TrimSpace(attachment.StorageKey) == "" {
 		return "", false, nil
 	}
-	url, err := s3Store.PresignGetObjectURL(ctx, attachment.StorageKey, directDownloadURLTTL)
+	url, err := presigner.PresignGetObjectURLWithDisposition(ctx, attachment.StorageKey, s.directDownloadURLTTL, contentDisposition)
 	if err != nil {
 		return "", false, err
 	}
 	return url, true, nil
 }
 
-func (s *AttachmentService) PresignAttachmentThumbnailURL(ctx context.Context, attachment models.Attachment) (string, bool, error) {
+// PresignAttachmentThumbnailURL 为 S3/GCS 上的缩略图生成预签名下载地址，contentDisposition 含义同 PresignAttachmentURL。
+func (s *AttachmentService) PresignAttachmentThumbnailURL(ctx context.Context, attachment models.Attachment, contentDisposition string) (string, bool, error) {
 	if strings.TrimSpace(attachment.ThumbnailStorageKey) == "" {
 		return "", false, nil
 	}
-	if !strings.EqualFold(strings.TrimSpace(attachment.ThumbnailStorageType), "S3") &&
-		!strings.EqualFold(strings.TrimSpace(attachment.StorageType), "S3") {
+	thumbnailType := strings.TrimSpace(attachment.ThumbnailStorageType)
+	if thumbnailType == "" {
+		thumbnailType = strings.TrimSpace(attachment.StorageType)
+	}
+	if !strings.EqualFold(thumbnailType, storageTypeName(s.storage)) {
 		return "", false, nil
 	}
-	s3Store, ok := s.storage.(*storage.S3Store)
+	presigner, ok := s.storage.(storage.Presigner)
 	if !ok {
 		return "", false, nil
 	}
-	url, err := s3Store.PresignGetObjectURL(ctx, attachment.ThumbnailStorageKey, directDownloadURLTTL)
+	url, err := presigner.PresignGetObjectURLWithDisposition(ctx, attachment.ThumbnailStorageKey, s.directDownloadURLTTL, contentDisposition)
 	if err != nil {
 		return "", false, err
 	}
@@ -592,6 +685,10 @@ func (s *AttachmentService) AppendAttachmentUploadChunk(ctx context.Context, use
 	if int64(len(chunk)) > remaining {
 		return models.AttachmentUploadSession{}, ErrUploadExceedsTotalSize
 	}
+	// 上限可能在会话创建后被调低，续传时按当前配置再校验一次
+	if err := s.checkMaxAttachmentSize(session.ReceivedSize + int64(len(chunk))); err != nil {
+		return models.AttachmentUploadSession{}, err
+	}
 
 	file, err := os.OpenFile(session.TempPath, os.O_WRONLY, 0o644)
 	if err != nil {
@@ -625,6 +722,11 @@ func (s *AttachmentService) CancelAttachmentUploadSession(ctx context.Context, u
 	if err != nil {
 		return err
 	}
+	return s.discardUploadSession(ctx, session)
+}
+
+// discardUploadSession 删除上传会话记录，并清理已上传的临时文件、直传对象或未完成的分片上传。
+func (s *AttachmentService) discardUploadSession(ctx context.Context, session models.AttachmentUploadSession) error {
 	if err := s.store.DeleteAttachmentUploadSessionByID(ctx, session.ID); err != nil {
 		return err
 	}
@@ -643,25 +745,49 @@ func (s *AttachmentService) CancelAttachmentUploadSession(ctx context.Context, u
 	return nil
 }
 
-func (s *AttachmentService) CompleteAttachmentUploadSession(ctx context.Context, userID int64, uploadID string) (models.Attachment, error) {
+// CompleteAttachmentUploadSession 完成上传会话并创建附件；expectedSHA256 或创建会话时声明的摘要非空时先校验内容，
+// 不一致则丢弃会话。两处摘要同时给出却互不相同时直接拒绝。
+func (s *AttachmentService) CompleteAttachmentUploadSession(ctx context.Context, userID int64, uploadID string, expectedSHA256 string) (models.Attachment, error) {
+	expectedSHA256, err := normalizeSHA256Checksum(expectedSHA256)
+	if err != nil {
+		return models.Attachment{}, err
+	}
 	session, err := s.GetAttachmentUploadSession(ctx, userID, uploadID)
 	if err != nil {
 		return models.Attachment{}, err
 	}
+	if session.ExpectedSHA256 != "" {
+		if expectedSHA256 != "" && expectedSHA256 != session.ExpectedSHA256 {
+			return models.Attachment{}, ErrChecksumMismatch
+		}
+		expectedSHA256 = session.ExpectedSHA256
+	}
 	if multipart, ok := decodeMultipartSessionPath(session.TempPath); ok {
-		return s.completeMultipartAttachmentUploadSession(ctx, userID, session, multipart)
+		return s.completeMultipartAttachmentUploadSession(ctx, userID, session, multipart, expectedSHA256)
 	}
 	if storageKey, direct := decodeDirectSessionPath(session.TempPath); direct {
-		return s.completeDirectAttachmentUploadSession(ctx, userID, session, storageKey)
+		return s.completeDirectAttachmentUploadSession(ctx, userID, session, storageKey, expectedSHA256)
 	}
 	if session.ReceivedSize != session.Size {
 		return models.Attachment{}, ErrUploadNotComplete
 	}
+	if err := s.checkUploadedFileType(session.TempPath); err != nil {
+		// 内容被类型规则拒绝时重传也无济于事，直接丢弃会话
+		var notAllowed *AttachmentTypeNotAllowedError
+		if errors.As(err, &notAllowed) {
+			_ = s.discardUploadSession(ctx, session)
+		}
+		return models.Attachment{}, err
+	}
 
 	contentHash, err := hashFileSHA256(session.TempPath)
 	if err != nil {
 		return models.Attachment{}, err
 	}
+	if expectedSHA256 != "" && contentHash != expectedSHA256 {
+		_ = s.discardUploadSession(ctx, session)
+		return models.Attachment{}, ErrChecksumMismatch
+	}
 
 	existing, found, err := s.store.FindAttachmentByContentHash(ctx, userID, contentHash)
 	if err != nil {
@@ -696,6 +822,9 @@ func (s *AttachmentService) CompleteAttachmentUploadSession(ctx context.Context,
 			)
 		}
 	} else {
+		if err := s.checkStorageQuota(ctx, userID, session.Size); err != nil {
+			return models.Attachment{}, err
+		}
 		storageKey, err := s.newAttachmentStorageKey(ctx, userID, session.Filename)
 		if err != nil {
 			return models.Attachment{}, err
@@ -732,6 +861,8 @@ func (s *AttachmentService) CompleteAttachmentUploadSession(ctx context.Context,
 				session.ThumbnailFilename,
 				session.ThumbnailTempPath,
 			)
+		} else if isVideoAttachment(session.Type, session.Filename) {
+			s.ensureVideoThumbnail(ctx, attachment, session.Type, session.Filename, session.TempPath)
 		} else {
 			s.ensureThumbnailFromFile(ctx, attachment, session.Type, session.Filename, session.TempPath)
 		}
@@ -765,6 +896,7 @@ func (s *AttachmentService) completeDirectAttachmentUploadSession(
 	userID int64,
 	session models.AttachmentUploadSession,
 	storageKey string,
+	expectedSHA256 string,
 ) (models.Attachment, error) {
 	s3Store, ok := s.storage.(*storage.S3Store)
 	if !ok {
@@ -778,6 +910,22 @@ func (s *AttachmentService) completeDirectAttachmentUploadSession(
 	if size != session.Size {
 		return models.Attachment{}, ErrUploadNotComplete
 	}
+	if err := s.checkStoredObjectType(ctx, storageKey); err != nil {
+		var notAllowed *AttachmentTypeNotAllowedError
+		if errors.As(err, &notAllowed) {
+			_ = s.discardUploadSession(ctx, session)
+		}
+		return models.Attachment{}, err
+	}
+	if err := s.verifyStoredObjectChecksum(ctx, storageKey, expectedSHA256); err != nil {
+		if errors.Is(err, ErrChecksumMismatch) {
+			_ = s.discardUploadSession(ctx, session)
+		}
+		return models.Attachment{}, err
+	}
+	if err := s.checkStorageQuota(ctx, userID, size); err != nil {
+		return models.Attachment{}, err
+	}
 
 	contentHash := hashDirectUploadReference(userID, session.ID, storageKey, size)
 	attachment, err := s.store.CreateAttachment(
@@ -803,6 +951,8 @@ func (s *AttachmentService) completeDirectAttachmentUploadSession(
 			session.ThumbnailFilename,
 			session.ThumbnailTempPath,
 		)
+	} else {
+		s.ensureVideoThumbnailFromStorage(ctx, attachment)
 	}
 	if refreshed, refreshErr := s.store.GetAttachmentByID(ctx, attachment.ID); refreshErr == nil {
 		attachment = refreshed
@@ -832,6 +982,7 @@ func (s *AttachmentService) completeMultipartAttachmentUploadSession(
 	userID int64,
 	session models.AttachmentUploadSession,
 	multipart multipartSessionInfo,
+	expectedSHA256 string,
 ) (models.Attachment, error) {
 	s3Store, ok := s.storage.(*storage.S3Store)
 	if !ok {
@@ -845,9 +996,28 @@ func (s *AttachmentService) completeMultipartAttachmentUploadSession(
 	if uploadedSize != session.Size {
 		return models.Attachment{}, ErrUploadNotComplete
 	}
+	if err := s.checkStorageQuota(ctx, userID, uploadedSize); err != nil {
+		return models.Attachment{}, err
+	}
 	if err := s3Store.CompleteMultipartUpload(ctx, multipart.StorageKey, multipart.MultipartUploadID, parts); err != nil {
 		return models.Attachment{}, err
 	}
+	// 分片在合并前无法读取，只能在合并后嗅探；与建记录失败时一样，出错时删除合并出的对象
+	if err := s.checkStoredObjectType(ctx, multipart.StorageKey); err != nil {
+		_ = s.storage.Delete(ctx, multipart.StorageKey)
+		var notAllowed *AttachmentTypeNotAllowedError
+		if errors.As(err, &notAllowed) {
+			_ = s.discardUploadSession(ctx, session)
+		}
+		return models.Attachment{}, err
+	}
+	if err := s.verifyStoredObjectChecksum(ctx, multipart.StorageKey, expectedSHA256); err != nil {
+		_ = s.storage.Delete(ctx, multipart.StorageKey)
+		if errors.Is(err, ErrChecksumMismatch) {
+			_ = s.discardUploadSession(ctx, session)
+		}
+		return models.Attachment{}, err
+	}
 
 	contentHash := hashMultipartUploadReference(userID, session.ID, multipart.StorageKey, uploadedSize, parts)
 	attachment, err := s.store.CreateAttachment(
@@ -874,6 +1044,8 @@ func (s *AttachmentService) completeMultipartAttachmentUploadSession(
 			session.ThumbnailFilename,
 			session.ThumbnailTempPath,
 		)
+	} else {
+		s.ensureVideoThumbnailFromStorage(ctx, attachment)
 	}
 	if refreshed, refreshErr := s.store.GetAttachmentByID(ctx, attachment.ID); refreshErr == nil {
 		attachment = refreshed
@@ -898,6 +1070,30 @@ func (s *AttachmentService) completeMultipartAttachmentUploadSession(
 	return attachment, nil
 }
 
+func (s *AttachmentService) ListDedupReport(
+	ctx context.Context,
+	pageSize int,
+	pageToken string,
+) ([]store.AttachmentDedupGroup, store.AttachmentDedupSummary, string, error) {
+	offset, err := parseGroupPageToken(pageToken)
+	if err != nil {
+		return nil, store.AttachmentDedupSummary{}, "", fmt.Errorf("invalid pageToken")
+	}
+	groups, nextOffset, err := s.store.ListAttachmentDedupGroups(ctx, pageSize, offset)
+	if err != nil {
+		return nil, store.AttachmentDedupSummary{}, "", err
+	}
+	summary, err := s.store.SummarizeAttachmentDedup(ctx)
+	if err != nil {
+		return nil, store.AttachmentDedupSummary{}, "", err
+	}
+	nextToken := ""
+	if nextOffset >= 0 {
+		nextToken = strconv.Itoa(nextOffset)
+	}
+	return groups, summary, nextToken, nil
+}
+
 func (s *AttachmentService) ListAttachments(ctx context.Context, userID int64) ([]models.Attachment, error) {
 	return s.store.ListAttachmentsByCreator(ctx, userID)
 }
@@ -926,6 +1122,46 @@ func (s *AttachmentService) DeleteAttachment(ctx context.Context, userID int64,
 	return s.store.DeleteAttachment(ctx, attachmentID)
 }
 
+// ListAttachmentMemoIDs 返回引用附件的 memo id，仅附件所有者可查询，结果限定为其可管理的 memo。
+func (s *AttachmentService) ListAttachmentMemoIDs(ctx context.Context, userID int64, attachmentID int64) ([]int64, error) {
+	attachment, err := s.store.GetAttachmentByID(ctx, attachmentID)
+	if err != nil {
+		return nil, err
+	}
+	if attachment.CreatorID != userID {
+		return nil, sql.ErrNoRows
+	}
+	return s.store.ListMemoIDsByAttachmentID(ctx, attachmentID, userID)
+}
+
+// CanViewAttachment 判断用户能否读取附件文件：附件所有者，或附件挂在用户能看到的 memo 上
+// （创建者、协作者，或公开/登录可见）。未被引用的附件只有所有者能读取。
+func (s *AttachmentService) CanViewAttachment(ctx context.Context, attachment models.Attachment, userID int64) (bool, error) {
+	if attachment.CreatorID == userID {
+		return true, nil
+	}
+	return s.store.CanUserAccessAttachment(ctx, attachment.ID, userID)
+}
+
+// RenameAttachment 只修改附件的显示文件名，存储对象与 storage key 保持不变。
+func (s *AttachmentService) RenameAttachment(ctx context.Context, userID int64, attachmentID int64, filename string) (models.Attachment, error) {
+	attachment, err := s.store.GetAttachmentByID(ctx, attachmentID)
+	if err != nil {
+		return models.Attachment{}, err
+	}
+	if attachment.CreatorID != userID {
+		return models.Attachment{}, sql.ErrNoRows
+	}
+	sanitized := sanitizeFilename(filename)
+	if sanitized == "" {
+		return models.Attachment{}, fmt.Errorf("filename cannot be empty")
+	}
+	if err := s.store.UpdateAttachmentFilename(ctx, attachmentID, sanitized); err != nil {
+		return models.Attachment{}, err
+	}
+	return s.store.GetAttachmentByID(ctx, attachmentID)
+}
+
 func (s *AttachmentService) GetAttachment(ctx context.Context, attachmentID int64) (models.Attachment, error) {
 	return s.store.GetAttachmentByID(ctx, attachmentID)
 }
@@ -945,6 +1181,13 @@ func (s *AttachmentService) OpenAttachmentThumbnailStream(ctx context.Context, a
 	return s.storage.Open(ctx, attachment.ThumbnailStorageKey)
 }
 
+func (s *AttachmentService) OpenAttachmentThumbnailRangeStream(ctx context.Context, attachment models.Attachment, start int64, end int64) (io.ReadCloser, error) {
+	if strings.TrimSpace(attachment.ThumbnailStorageKey) == "" {
+		return nil, os.ErrNotExist
+	}
+	return s.storage.OpenRange(ctx, attachment.ThumbnailStorageKey, start, end)
+}
+
 func (s *AttachmentService) OpenAttachment(ctx context.Context, attachmentID int64) (models.Attachment, io.ReadCloser, error) {
 	attachment, err := s.GetAttachment(ctx, attachmentID)
 	if err != nil {
@@ -1071,12 +1314,21 @@ func generateNanoID(length int) (string, error) {
 	return string(buf), nil
 }
 
+// 附件记录中的 storage_type 取值，标识对象所在的存储后端。
+const (
+	storageTypeLocal = "LOCAL"
+	storageTypeS3    = "S3"
+	storageTypeGCS   = "GCS"
+)
+
 func storageTypeName(s storage.Store) string {
 	switch s.(type) {
 	case *storage.S3Store:
-		return "S3"
+		return storageTypeS3
+	case *storage.GCSStore:
+		return storageTypeGCS
 	default:
-		return "LOCAL"
+		return storageTypeLocal
 	}
 }
 
@@ -1094,6 +1346,51 @@ func hashFileSHA256(path string) (string, error) {
 	return hex.EncodeToString(hasher.Sum(nil)), nil
 }
 
+// normalizeSHA256Checksum 校验并规范化客户端提供的十六进制 SHA-256 摘要，空串表示不校验。
+func normalizeSHA256Checksum(raw string) (string, error) {
+	checksum := strings.ToLower(strings.TrimSpace(raw))
+	if checksum == "" {
+		return "", nil
+	}
+	if decoded, err := hex.DecodeString(checksum); err != nil || len(decoded) != sha256.Size {
+		return "", ErrInvalidChecksum
+	}
+	return checksum, nil
+}
+
+// verifyStoredObjectChecksum 校验直传到存储中的对象 SHA-256 是否与 expected 一致；expected 为空时跳过。
+// S3 已保存整对象校验和时直接比对，否则读取完整对象重新计算。
+func (s *AttachmentService) verifyStoredObjectChecksum(ctx context.Context, storageKey string, expected string) error {
+	if expected == "" {
+		return nil
+	}
+	if s3Store, ok := s.storage.(*storage.S3Store); ok {
+		checksum, err := s3Store.HeadChecksumSHA256(ctx, storageKey)
+		if err != nil {
+			return err
+		}
+		if checksum != "" {
+			if checksum != expected {
+				return ErrChecksumMismatch
+			}
+			return nil
+		}
+	}
+	reader, err := s.storage.Open(ctx, storageKey)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, reader); err != nil {
+		return fmt.Errorf("hash uploaded object: %w", err)
+	}
+	if hex.EncodeToString(hasher.Sum(nil)) != expected {
+		return ErrChecksumMismatch
+	}
+	return nil
+}
+
 func encodeDirectSessionPath(storageKey string) string {
 	return directSessionPathPrefix + strings.TrimSpace(storageKey)
 }
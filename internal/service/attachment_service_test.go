@@ -3,16 +3,25 @@ package service
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"database/sql"
 	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
 	"image"
 	"image/color"
 	"image/jpeg"
 	"os"
 	"path/filepath"
 	"regexp"
+	"runtime"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/shinyes/keer/internal/config"
+	"github.com/shinyes/keer/internal/models"
 	"github.com/shinyes/keer/internal/storage"
 )
 
@@ -79,7 +88,7 @@ func TestCreateAttachment_DeduplicateStorageSameContent(t *testing.T) {
 	if err != nil {
 		t.Fatalf("NewLocalStore() error = %v", err)
 	}
-	attachmentService := NewAttachmentService(services.store, localStore)
+	attachmentService := NewAttachmentService(services.store, localStore, 0)
 	user := mustCreateUser(t, services.store, "attach-dedupe")
 
 	content := base64.StdEncoding.EncodeToString([]byte("same-image-bytes"))
@@ -121,13 +130,257 @@ func TestCreateAttachment_DeduplicateStorageSameContent(t *testing.T) {
 	}
 }
 
+func TestListDedupReport(t *testing.T) {
+	services := setupTestServices(t)
+	ctx := context.Background()
+	localStore, err := storage.NewLocalStore(filepath.Join(t.TempDir(), "uploads"))
+	if err != nil {
+		t.Fatalf("NewLocalStore() error = %v", err)
+	}
+	attachmentService := NewAttachmentService(services.store, localStore, 0)
+	user := mustCreateUser(t, services.store, "attach-dedup-report")
+
+	upload := func(content string, times int) {
+		for i := 0; i < times; i++ {
+			if _, err := attachmentService.CreateAttachment(ctx, user.ID, CreateAttachmentInput{
+				Filename: "file.txt",
+				Type:     "text/plain",
+				Content:  base64.StdEncoding.EncodeToString([]byte(content)),
+			}); err != nil {
+				t.Fatalf("CreateAttachment(%q) error = %v", content, err)
+			}
+		}
+	}
+	upload("small", 2)
+	upload("a much larger payload", 3)
+	upload("unique", 1)
+
+	groups, summary, nextToken, err := attachmentService.ListDedupReport(ctx, 1, "")
+	if err != nil {
+		t.Fatalf("ListDedupReport() error = %v", err)
+	}
+	largeSize := int64(len("a much larger payload"))
+	if len(groups) != 1 || groups[0].References != 3 || groups[0].BytesSaved != largeSize*2 {
+		t.Fatalf("expected largest savings first, got %+v", groups)
+	}
+	if nextToken != "1" {
+		t.Fatalf("expected next page token 1, got %q", nextToken)
+	}
+	if summary.Groups != 2 || summary.References != 5 || summary.BytesSaved != largeSize*2+int64(len("small")) {
+		t.Fatalf("unexpected summary %+v", summary)
+	}
+
+	groups, _, nextToken, err = attachmentService.ListDedupReport(ctx, 1, nextToken)
+	if err != nil {
+		t.Fatalf("ListDedupReport(page 2) error = %v", err)
+	}
+	if len(groups) != 1 || groups[0].References != 2 || nextToken != "" {
+		t.Fatalf("unexpected second page groups=%+v next=%q", groups, nextToken)
+	}
+	if _, _, _, err := attachmentService.ListDedupReport(ctx, 1, "abc"); err == nil {
+		t.Fatalf("expected invalid page token error")
+	}
+}
+
+func TestAttachmentSizeLimitsByType(t *testing.T) {
+	services := setupTestServices(t)
+	ctx := context.Background()
+	localStore, err := storage.NewLocalStore(filepath.Join(t.TempDir(), "uploads"))
+	if err != nil {
+		t.Fatalf("NewLocalStore() error = %v", err)
+	}
+	attachmentService := NewAttachmentService(services.store, localStore, 0)
+	attachmentService.SetSizeLimitsByType(map[string]int64{
+		"*/*":       100,
+		"image/*":   10,
+		"image/gif": 20,
+	})
+	user := mustCreateUser(t, services.store, "attach-size-limit")
+
+	cases := []struct {
+		fileType string
+		size     int
+		pattern  string
+	}{
+		{fileType: "image/png", size: 10},
+		{fileType: "image/png", size: 11, pattern: "image/*"},
+		{fileType: "IMAGE/GIF", size: 20},
+		{fileType: "image/gif", size: 21, pattern: "image/gif"},
+		{fileType: "text/plain; charset=utf-8", size: 100},
+		{fileType: "", size: 101, pattern: "*/*"},
+	}
+	for _, tc := range cases {
+		_, err := attachmentService.CreateAttachment(ctx, user.ID, CreateAttachmentInput{
+			Filename: "file.bin",
+			Type:     tc.fileType,
+			Content:  base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{byte(tc.size)}, tc.size)),
+		})
+		var tooLarge *AttachmentTooLargeError
+		if tc.pattern == "" {
+			if err != nil {
+				t.Fatalf("CreateAttachment(%q, %d) error = %v", tc.fileType, tc.size, err)
+			}
+			continue
+		}
+		if !errors.As(err, &tooLarge) || tooLarge.Pattern != tc.pattern {
+			t.Fatalf("CreateAttachment(%q, %d): expected limit from %q, got %v", tc.fileType, tc.size, tc.pattern, err)
+		}
+	}
+
+	var tooLarge *AttachmentTooLargeError
+	_, err = attachmentService.CreateAttachmentUploadSession(ctx, user.ID, CreateAttachmentUploadSessionInput{
+		Filename: "video.mp4",
+		Type:     "video/mp4",
+		Size:     101,
+	})
+	if !errors.As(err, &tooLarge) || tooLarge.Limit != 100 {
+		t.Fatalf("expected upload session to be rejected by */* limit, got %v", err)
+	}
+}
+
+func TestMaxAttachmentSize(t *testing.T) {
+	services := setupTestServices(t)
+	ctx := context.Background()
+	localStore, err := storage.NewLocalStore(filepath.Join(t.TempDir(), "uploads"))
+	if err != nil {
+		t.Fatalf("NewLocalStore() error = %v", err)
+	}
+	attachmentService := NewAttachmentService(services.store, localStore, 0)
+	attachmentService.tempDir = t.TempDir()
+	attachmentService.SetMaxAttachmentSize(16)
+	user := mustCreateUser(t, services.store, "attach-max-size")
+
+	var exceeds *AttachmentExceedsMaxSizeError
+	_, err = attachmentService.CreateAttachment(ctx, user.ID, CreateAttachmentInput{
+		Filename: "big.bin",
+		Type:     "application/octet-stream",
+		Content:  base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, 17)),
+	})
+	if !errors.As(err, &exceeds) || exceeds.Size != 17 || exceeds.Limit != 16 {
+		t.Fatalf("expected inline attachment to exceed max size, got %v", err)
+	}
+	if _, err := attachmentService.CreateAttachment(ctx, user.ID, CreateAttachmentInput{
+		Filename: "fits.bin",
+		Type:     "application/octet-stream",
+		Content:  base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, 16)),
+	}); err != nil {
+		t.Fatalf("CreateAttachment() at max size error = %v", err)
+	}
+
+	_, err = attachmentService.CreateAttachmentUploadSession(ctx, user.ID, CreateAttachmentUploadSessionInput{
+		Filename: "big.mp4",
+		Type:     "video/mp4",
+		Size:     17,
+	})
+	if !errors.As(err, &exceeds) {
+		t.Fatalf("expected upload session to exceed max size, got %v", err)
+	}
+
+	session, err := attachmentService.CreateAttachmentUploadSession(ctx, user.ID, CreateAttachmentUploadSessionInput{
+		Filename: "clip.mp4",
+		Type:     "video/mp4",
+		Size:     16,
+	})
+	if err != nil {
+		t.Fatalf("CreateAttachmentUploadSession() error = %v", err)
+	}
+	// 会话创建后调低上限，续传的分片同样受限
+	attachmentService.SetMaxAttachmentSize(8)
+	if _, err := attachmentService.AppendAttachmentUploadChunk(ctx, user.ID, session.ID, 0, bytes.Repeat([]byte{2}, 8)); err != nil {
+		t.Fatalf("AppendAttachmentUploadChunk() within max size error = %v", err)
+	}
+	_, err = attachmentService.AppendAttachmentUploadChunk(ctx, user.ID, session.ID, 8, []byte{3})
+	if !errors.As(err, &exceeds) || exceeds.Size != 9 {
+		t.Fatalf("expected chunk to exceed max size, got %v", err)
+	}
+}
+
+func TestAttachmentTypeRules(t *testing.T) {
+	services := setupTestServices(t)
+	ctx := context.Background()
+	localStore, err := storage.NewLocalStore(filepath.Join(t.TempDir(), "uploads"))
+	if err != nil {
+		t.Fatalf("NewLocalStore() error = %v", err)
+	}
+	attachmentService := NewAttachmentService(services.store, localStore, 0)
+	attachmentService.tempDir = t.TempDir()
+	attachmentService.SetThumbnailsDisabled(true)
+	attachmentService.SetAttachmentTypeRules([]string{"image/*", "application/pdf"}, []string{"image/svg+xml"})
+	user := mustCreateUser(t, services.store, "attach-type-rules")
+
+	jpegData := generateTestJPEGBytes(t, 4, 4)
+	pdfData := []byte("%PDF-1.4\n1 0 obj\n<<>>\nendobj\n")
+	cases := []struct {
+		fileType string
+		data     []byte
+		rejected string
+		detected bool
+	}{
+		{fileType: "image/jpeg", data: jpegData},
+		{fileType: "Application/PDF", data: pdfData},
+		{fileType: "application/x-msdownload", data: []byte("MZ"), rejected: "application/x-msdownload"},
+		{fileType: "image/svg+xml; charset=utf-8", data: []byte("<svg/>"), rejected: "image/svg+xml"},
+		{fileType: "image/png", data: []byte("<html><body>hi</body></html>"), rejected: "text/html", detected: true},
+	}
+	for _, tc := range cases {
+		_, err := attachmentService.CreateAttachment(ctx, user.ID, CreateAttachmentInput{
+			Filename: "file.bin",
+			Type:     tc.fileType,
+			Content:  base64.StdEncoding.EncodeToString(tc.data),
+		})
+		if tc.rejected == "" {
+			if err != nil {
+				t.Fatalf("CreateAttachment(%q) error = %v", tc.fileType, err)
+			}
+			continue
+		}
+		var notAllowed *AttachmentTypeNotAllowedError
+		if !errors.As(err, &notAllowed) || notAllowed.Type != tc.rejected || notAllowed.Detected != tc.detected {
+			t.Fatalf("CreateAttachment(%q): expected %q to be rejected (detected=%v), got %v", tc.fileType, tc.rejected, tc.detected, err)
+		}
+	}
+
+	// 声明为图片的会话在完成时按内容嗅探，伪装的 HTML 会被拒绝且会话被丢弃
+	htmlData := []byte("<!DOCTYPE html><html></html>")
+	session, err := attachmentService.CreateAttachmentUploadSession(ctx, user.ID, CreateAttachmentUploadSessionInput{
+		Filename: "photo.jpg",
+		Type:     "image/jpeg",
+		Size:     int64(len(htmlData)),
+	})
+	if err != nil {
+		t.Fatalf("CreateAttachmentUploadSession() error = %v", err)
+	}
+	if _, err := attachmentService.AppendAttachmentUploadChunk(ctx, user.ID, session.ID, 0, htmlData); err != nil {
+		t.Fatalf("AppendAttachmentUploadChunk() error = %v", err)
+	}
+	var notAllowed *AttachmentTypeNotAllowedError
+	if _, err := attachmentService.CompleteAttachmentUploadSession(ctx, user.ID, session.ID, ""); !errors.As(err, &notAllowed) || !notAllowed.Detected {
+		t.Fatalf("expected completion to reject sniffed content, got %v", err)
+	}
+	if _, err := services.store.GetAttachmentUploadSessionByID(ctx, session.ID); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("expected rejected session discarded, err=%v", err)
+	}
+	if _, err := os.Stat(session.TempPath); !os.IsNotExist(err) {
+		t.Fatalf("expected rejected temp file removed, stat err=%v", err)
+	}
+
+	_, err = attachmentService.CreateAttachmentUploadSession(ctx, user.ID, CreateAttachmentUploadSessionInput{
+		Filename: "setup.exe",
+		Type:     "application/x-msdownload",
+		Size:     2,
+	})
+	if !errors.As(err, &notAllowed) || notAllowed.Detected {
+		t.Fatalf("expected declared type to be rejected at session creation, got %v", err)
+	}
+}
+
 func TestCreateAttachment_DedupStorageForDifferentFilename(t *testing.T) {
 	services := setupTestServices(t)
 	localStore, err := storage.NewLocalStore(filepath.Join(t.TempDir(), "uploads"))
 	if err != nil {
 		t.Fatalf("NewLocalStore() error = %v", err)
 	}
-	attachmentService := NewAttachmentService(services.store, localStore)
+	attachmentService := NewAttachmentService(services.store, localStore, 0)
 	user := mustCreateUser(t, services.store, "attach-no-dedupe")
 
 	content := base64.StdEncoding.EncodeToString([]byte("same-image-bytes"))
@@ -169,7 +422,7 @@ func TestDeleteAttachment_KeepFileWhenSharedStorageKey(t *testing.T) {
 	if err != nil {
 		t.Fatalf("NewLocalStore() error = %v", err)
 	}
-	attachmentService := NewAttachmentService(services.store, localStore)
+	attachmentService := NewAttachmentService(services.store, localStore, 0)
 	user := mustCreateUser(t, services.store, "attach-delete-shared")
 
 	content := base64.StdEncoding.EncodeToString([]byte("same-image-bytes"))
@@ -218,7 +471,7 @@ func TestCreateAttachment_GeneratesThumbnailForImage(t *testing.T) {
 	if err != nil {
 		t.Fatalf("NewLocalStore() error = %v", err)
 	}
-	attachmentService := NewAttachmentService(services.store, localStore)
+	attachmentService := NewAttachmentService(services.store, localStore, 0)
 	user := mustCreateUser(t, services.store, "attach-thumbnail-image")
 
 	content := base64.StdEncoding.EncodeToString(generateTestJPEGBytes(t, 1200, 900))
@@ -241,13 +494,71 @@ func TestCreateAttachment_GeneratesThumbnailForImage(t *testing.T) {
 	_ = thumbnailReader.Close()
 }
 
+func TestCreateAttachment_GeneratesThumbnailForWebP(t *testing.T) {
+	services := setupTestServices(t)
+	localStore, err := storage.NewLocalStore(filepath.Join(t.TempDir(), "uploads"))
+	if err != nil {
+		t.Fatalf("NewLocalStore() error = %v", err)
+	}
+	attachmentService := NewAttachmentService(services.store, localStore, 0)
+	user := mustCreateUser(t, services.store, "attach-thumbnail-webp")
+
+	attachment, err := attachmentService.CreateAttachment(context.Background(), user.ID, CreateAttachmentInput{
+		Filename: "photo.webp",
+		Type:     "image/webp",
+		Content:  base64.StdEncoding.EncodeToString(generateTestWebPBytes(800, 600)),
+	})
+	if err != nil {
+		t.Fatalf("CreateAttachment() error = %v", err)
+	}
+	if attachment.ThumbnailStorageKey == "" {
+		t.Fatalf("expected thumbnail storage key to be populated for webp")
+	}
+
+	thumbnailReader, err := localStore.Open(context.Background(), attachment.ThumbnailStorageKey)
+	if err != nil {
+		t.Fatalf("expected thumbnail to exist, open error = %v", err)
+	}
+	defer thumbnailReader.Close()
+	thumbnail, err := jpeg.Decode(thumbnailReader)
+	if err != nil {
+		t.Fatalf("decode thumbnail failed: %v", err)
+	}
+	if bounds := thumbnail.Bounds(); bounds.Dx() != thumbnailMaxDimension || bounds.Dy() != 480 {
+		t.Fatalf("unexpected thumbnail size %dx%d", bounds.Dx(), bounds.Dy())
+	}
+}
+
+func TestCreateAttachment_SkipsThumbnailForUndecodableImage(t *testing.T) {
+	services := setupTestServices(t)
+	localStore, err := storage.NewLocalStore(filepath.Join(t.TempDir(), "uploads"))
+	if err != nil {
+		t.Fatalf("NewLocalStore() error = %v", err)
+	}
+	attachmentService := NewAttachmentService(services.store, localStore, 0)
+	user := mustCreateUser(t, services.store, "attach-thumbnail-heic")
+
+	// HEIC 仍没有解码器，附件照常创建，只是没有缩略图
+	attachment, err := attachmentService.CreateAttachment(context.Background(), user.ID, CreateAttachmentInput{
+		Filename: "photo.heic",
+		Type:     "image/heic",
+		Content:  base64.StdEncoding.EncodeToString([]byte("\x00\x00\x00\x18ftypheic\x00\x00\x00\x00mif1heic")),
+	})
+	if err != nil {
+		t.Fatalf("CreateAttachment() error = %v", err)
+	}
+	if attachment.ThumbnailStorageKey != "" {
+		t.Fatalf("expected no thumbnail for undecodable heic, got %q", attachment.ThumbnailStorageKey)
+	}
+}
+
 func TestCreateAttachment_DoesNotGenerateThumbnailForNonImage(t *testing.T) {
 	services := setupTestServices(t)
 	localStore, err := storage.NewLocalStore(filepath.Join(t.TempDir(), "uploads"))
 	if err != nil {
 		t.Fatalf("NewLocalStore() error = %v", err)
 	}
-	attachmentService := NewAttachmentService(services.store, localStore)
+	attachmentService := NewAttachmentService(services.store, localStore, 0)
 	user := mustCreateUser(t, services.store, "attach-thumbnail-text")
 
 	content := base64.StdEncoding.EncodeToString([]byte("plain text data"))
@@ -270,7 +581,7 @@ func TestCompleteAttachmentUploadSession_UsesClientProvidedThumbnail(t *testing.
 	if err != nil {
 		t.Fatalf("NewLocalStore() error = %v", err)
 	}
-	attachmentService := NewAttachmentService(services.store, localStore)
+	attachmentService := NewAttachmentService(services.store, localStore, 0)
 	user := mustCreateUser(t, services.store, "attach-upload-thumbnail-video")
 
 	videoData := []byte("video binary data")
@@ -310,7 +621,7 @@ func TestCompleteAttachmentUploadSession_UsesClientProvidedThumbnail(t *testing.
 		t.Fatalf("unexpected upload offset, got %d", session.ReceivedSize)
 	}
 
-	attachment, err := attachmentService.CompleteAttachmentUploadSession(context.Background(), user.ID, session.ID)
+	attachment, err := attachmentService.CompleteAttachmentUploadSession(context.Background(), user.ID, session.ID, "")
 	if err != nil {
 		t.Fatalf("CompleteAttachmentUploadSession() error = %v", err)
 	}
@@ -335,6 +646,310 @@ func TestCompleteAttachmentUploadSession_UsesClientProvidedThumbnail(t *testing.
 	}
 }
 
+func TestCompleteAttachmentUploadSession_ExtractsVideoThumbnailWithFFmpeg(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake ffmpeg is a shell script")
+	}
+	services := setupTestServices(t)
+	localStore, err := storage.NewLocalStore(filepath.Join(t.TempDir(), "uploads"))
+	if err != nil {
+		t.Fatalf("NewLocalStore() error = %v", err)
+	}
+	attachmentService := NewAttachmentService(services.store, localStore, 0)
+	attachmentService.tempDir = t.TempDir()
+	user := mustCreateUser(t, services.store, "attach-upload-ffmpeg")
+	ctx := context.Background()
+
+	// 用脚本代替 ffmpeg：成功时把预先准备的帧写到标准输出，失败时直接退出。
+	binDir := t.TempDir()
+	framePath := filepath.Join(binDir, "frame.jpg")
+	if err := os.WriteFile(framePath, generateTestJPEGBytes(t, 1280, 720), 0o644); err != nil {
+		t.Fatalf("write frame failed: %v", err)
+	}
+	writeScript := func(name string, body string) string {
+		t.Helper()
+		scriptPath := filepath.Join(binDir, name)
+		if err := os.WriteFile(scriptPath, []byte("#!/bin/sh\n"+body+"\n"), 0o755); err != nil {
+			t.Fatalf("write script failed: %v", err)
+		}
+		return scriptPath
+	}
+	upload := func(filename string, contentType string, data []byte) models.Attachment {
+		t.Helper()
+		session, err := attachmentService.CreateAttachmentUploadSession(ctx, user.ID, CreateAttachmentUploadSessionInput{
+			Filename: filename,
+			Type:     contentType,
+			Size:     int64(len(data)),
+		})
+		if err != nil {
+			t.Fatalf("CreateAttachmentUploadSession() error = %v", err)
+		}
+		if _, err := attachmentService.AppendAttachmentUploadChunk(ctx, user.ID, session.ID, 0, data); err != nil {
+			t.Fatalf("AppendAttachmentUploadChunk() error = %v", err)
+		}
+		attachment, err := attachmentService.CompleteAttachmentUploadSession(ctx, user.ID, session.ID, "")
+		if err != nil {
+			t.Fatalf("CompleteAttachmentUploadSession() error = %v", err)
+		}
+		return attachment
+	}
+
+	argsPath := filepath.Join(binDir, "args.txt")
+	attachmentService.SetFFmpegPath(writeScript("ffmpeg-ok", `echo "$@" > `+argsPath+"\ncat "+framePath))
+	mp4Header := []byte("\x00\x00\x00\x18ftypmp42\x00\x00\x00\x00mp42isom")
+	attachment := upload("clip.mp4", "video/mp4", append(mp4Header, []byte("video binary data")...))
+	if attachment.ThumbnailStorageKey == "" {
+		t.Fatalf("expected ffmpeg thumbnail to be stored")
+	}
+	args, err := os.ReadFile(argsPath)
+	if err != nil {
+		t.Fatalf("read ffmpeg args failed: %v", err)
+	}
+	// 协议限定为本地文件，demuxer 固定为文件头识别出的容器
+	if !strings.Contains(string(args), "-protocol_whitelist file -f mov -i file:") {
+		t.Fatalf("expected pinned demuxer and protocol whitelist, got %q", args)
+	}
+	if attachment.ThumbnailFilename != "clip_thumb.jpg" || attachment.ThumbnailType != "image/jpeg" {
+		t.Fatalf("unexpected thumbnail metadata: %s %s", attachment.ThumbnailFilename, attachment.ThumbnailType)
+	}
+	reader, err := localStore.Open(ctx, attachment.ThumbnailStorageKey)
+	if err != nil {
+		t.Fatalf("open thumbnail failed: %v", err)
+	}
+	thumbnail, _, err := image.Decode(reader)
+	_ = reader.Close()
+	if err != nil {
+		t.Fatalf("decode thumbnail failed: %v", err)
+	}
+	if bounds := thumbnail.Bounds(); bounds.Dx() != 640 || bounds.Dy() != 360 {
+		t.Fatalf("unexpected thumbnail size: %dx%d", bounds.Dx(), bounds.Dy())
+	}
+
+	// 无法识别容器的文件不交给 ffmpeg
+	if err := os.Remove(argsPath); err != nil {
+		t.Fatalf("remove ffmpeg args failed: %v", err)
+	}
+	attachment = upload("unknown.mp4", "video/mp4", []byte("#EXTM3U\n#EXT-X-VERSION:3\n"))
+	if attachment.ThumbnailStorageKey != "" {
+		t.Fatalf("expected no thumbnail for unrecognized container, got %s", attachment.ThumbnailStorageKey)
+	}
+	if _, err := os.Stat(argsPath); !os.IsNotExist(err) {
+		t.Fatalf("expected ffmpeg not to run for unrecognized container, stat err=%v", err)
+	}
+
+	attachmentService.SetFFmpegPath(writeScript("ffmpeg-fail", "exit 1"))
+	attachment = upload("broken.mov", "video/quicktime", append(mp4Header, []byte("another video")...))
+	if attachment.ThumbnailStorageKey != "" {
+		t.Fatalf("expected no thumbnail when ffmpeg fails, got %s", attachment.ThumbnailStorageKey)
+	}
+
+	attachmentService.SetFFmpegPath(writeScript("ffmpeg-unused", "exit 1"))
+	attachment = upload("notes.txt", "text/plain", []byte("plain text"))
+	if attachment.ThumbnailStorageKey != "" {
+		t.Fatalf("expected no thumbnail for non-video attachment")
+	}
+}
+
+func TestCleanupExpiredUploadSessions_UsesConfiguredTTL(t *testing.T) {
+	services := setupTestServices(t)
+	localStore, err := storage.NewLocalStore(filepath.Join(t.TempDir(), "uploads"))
+	if err != nil {
+		t.Fatalf("NewLocalStore() error = %v", err)
+	}
+	attachmentService := NewAttachmentService(services.store, localStore, time.Hour)
+	attachmentService.tempDir = t.TempDir()
+	user := mustCreateUser(t, services.store, "attach-upload-ttl")
+	ctx := context.Background()
+
+	createSession := func(filename string) models.AttachmentUploadSession {
+		t.Helper()
+		session, err := attachmentService.CreateAttachmentUploadSession(ctx, user.ID, CreateAttachmentUploadSessionInput{
+			Filename: filename,
+			Type:     "application/octet-stream",
+			Size:     16,
+		})
+		if err != nil {
+			t.Fatalf("CreateAttachmentUploadSession() error = %v", err)
+		}
+		return session
+	}
+	stale := createSession("stale.bin")
+	fresh := createSession("fresh.bin")
+	staleTime := time.Now().UTC().Add(-2 * time.Hour).Format(time.RFC3339Nano)
+	if _, err := services.store.DB().ExecContext(ctx, `UPDATE attachment_upload_sessions SET update_time = ? WHERE id = ?`, staleTime, stale.ID); err != nil {
+		t.Fatalf("backdate upload session failed: %v", err)
+	}
+
+	removed, err := attachmentService.CleanupExpiredUploadSessions(ctx)
+	if err != nil {
+		t.Fatalf("CleanupExpiredUploadSessions() error = %v", err)
+	}
+	if removed != 1 {
+		t.Fatalf("expected 1 session reclaimed, got %d", removed)
+	}
+	if _, err := services.store.GetAttachmentUploadSessionByID(ctx, stale.ID); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("expected stale session removed, err=%v", err)
+	}
+	if _, err := os.Stat(stale.TempPath); !os.IsNotExist(err) {
+		t.Fatalf("expected stale temp file removed, stat err=%v", err)
+	}
+	if _, err := services.store.GetAttachmentUploadSessionByID(ctx, fresh.ID); err != nil {
+		t.Fatalf("expected fresh session kept, err=%v", err)
+	}
+}
+
+func TestCompleteAttachmentUploadSession_VerifiesChecksum(t *testing.T) {
+	services := setupTestServices(t)
+	localStore, err := storage.NewLocalStore(filepath.Join(t.TempDir(), "uploads"))
+	if err != nil {
+		t.Fatalf("NewLocalStore() error = %v", err)
+	}
+	attachmentService := NewAttachmentService(services.store, localStore, 0)
+	attachmentService.tempDir = t.TempDir()
+	user := mustCreateUser(t, services.store, "attach-upload-checksum")
+	ctx := context.Background()
+
+	data := []byte("resumable upload payload")
+	sum := sha256.Sum256(data)
+	upload := func() models.AttachmentUploadSession {
+		t.Helper()
+		session, err := attachmentService.CreateAttachmentUploadSession(ctx, user.ID, CreateAttachmentUploadSessionInput{
+			Filename: "payload.bin",
+			Type:     "application/octet-stream",
+			Size:     int64(len(data)),
+		})
+		if err != nil {
+			t.Fatalf("CreateAttachmentUploadSession() error = %v", err)
+		}
+		if _, err := attachmentService.AppendAttachmentUploadChunk(ctx, user.ID, session.ID, 0, data); err != nil {
+			t.Fatalf("AppendAttachmentUploadChunk() error = %v", err)
+		}
+		return session
+	}
+
+	session := upload()
+	if _, err := attachmentService.CompleteAttachmentUploadSession(ctx, user.ID, session.ID, "not-a-digest"); !errors.Is(err, ErrInvalidChecksum) {
+		t.Fatalf("expected ErrInvalidChecksum, got %v", err)
+	}
+	wrong := sha256.Sum256([]byte("something else"))
+	if _, err := attachmentService.CompleteAttachmentUploadSession(ctx, user.ID, session.ID, hex.EncodeToString(wrong[:])); !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("expected ErrChecksumMismatch, got %v", err)
+	}
+	if _, err := services.store.GetAttachmentUploadSessionByID(ctx, session.ID); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("expected mismatched session discarded, err=%v", err)
+	}
+
+	session = upload()
+	attachment, err := attachmentService.CompleteAttachmentUploadSession(ctx, user.ID, session.ID, strings.ToUpper(hex.EncodeToString(sum[:])))
+	if err != nil {
+		t.Fatalf("CompleteAttachmentUploadSession() with matching checksum error = %v", err)
+	}
+	if attachment.Size != int64(len(data)) {
+		t.Fatalf("unexpected attachment size %d", attachment.Size)
+	}
+}
+
+func TestCompleteAttachmentUploadSession_VerifiesSessionChecksum(t *testing.T) {
+	services := setupTestServices(t)
+	localStore, err := storage.NewLocalStore(filepath.Join(t.TempDir(), "uploads"))
+	if err != nil {
+		t.Fatalf("NewLocalStore() error = %v", err)
+	}
+	attachmentService := NewAttachmentService(services.store, localStore, 0)
+	attachmentService.tempDir = t.TempDir()
+	user := mustCreateUser(t, services.store, "attach-upload-session-checksum")
+	ctx := context.Background()
+
+	data := []byte("declared checksum payload")
+	sum := sha256.Sum256(data)
+	wrong := sha256.Sum256([]byte("something else"))
+	upload := func(checksum string) models.AttachmentUploadSession {
+		t.Helper()
+		session, err := attachmentService.CreateAttachmentUploadSession(ctx, user.ID, CreateAttachmentUploadSessionInput{
+			Filename: "payload.bin",
+			Type:     "application/octet-stream",
+			Size:     int64(len(data)),
+			SHA256:   checksum,
+		})
+		if err != nil {
+			t.Fatalf("CreateAttachmentUploadSession() error = %v", err)
+		}
+		if _, err := attachmentService.AppendAttachmentUploadChunk(ctx, user.ID, session.ID, 0, data); err != nil {
+			t.Fatalf("AppendAttachmentUploadChunk() error = %v", err)
+		}
+		return session
+	}
+
+	if _, err := attachmentService.CreateAttachmentUploadSession(ctx, user.ID, CreateAttachmentUploadSessionInput{
+		Filename: "payload.bin",
+		Type:     "application/octet-stream",
+		Size:     int64(len(data)),
+		SHA256:   "not-a-digest",
+	}); !errors.Is(err, ErrInvalidChecksum) {
+		t.Fatalf("expected ErrInvalidChecksum, got %v", err)
+	}
+
+	session := upload(hex.EncodeToString(wrong[:]))
+	if session.ExpectedSHA256 != hex.EncodeToString(wrong[:]) {
+		t.Fatalf("expected session checksum persisted, got %q", session.ExpectedSHA256)
+	}
+	if _, err := attachmentService.CompleteAttachmentUploadSession(ctx, user.ID, session.ID, ""); !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("expected ErrChecksumMismatch, got %v", err)
+	}
+	if _, err := services.store.GetAttachmentUploadSessionByID(ctx, session.ID); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("expected mismatched session discarded, err=%v", err)
+	}
+
+	session = upload(strings.ToUpper(hex.EncodeToString(sum[:])))
+	if _, err := attachmentService.CompleteAttachmentUploadSession(ctx, user.ID, session.ID, hex.EncodeToString(wrong[:])); !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("expected conflicting completion checksum rejected, got %v", err)
+	}
+	attachment, err := attachmentService.CompleteAttachmentUploadSession(ctx, user.ID, session.ID, "")
+	if err != nil {
+		t.Fatalf("CompleteAttachmentUploadSession() with declared checksum error = %v", err)
+	}
+	if attachment.ContentHash != hex.EncodeToString(sum[:]) {
+		t.Fatalf("unexpected content hash %q", attachment.ContentHash)
+	}
+}
+
+func TestAttachmentPresignTTLs(t *testing.T) {
+	services := setupTestServices(t)
+	s3Store, err := storage.NewS3Store(context.Background(), config.S3Config{
+		Endpoint:     "http://127.0.0.1:9",
+		Region:       "us-east-1",
+		Bucket:       "keer",
+		AccessKeyID:  "key",
+		AccessSecret: "secret",
+		UsePathStyle: true,
+	})
+	if err != nil {
+		t.Fatalf("NewS3Store() error = %v", err)
+	}
+	attachmentService := NewAttachmentService(services.store, s3Store, 0)
+	attachment := models.Attachment{StorageType: "S3", StorageKey: "attachments/1/a.txt"}
+
+	url, ok, err := attachmentService.PresignAttachmentURL(context.Background(), attachment, "")
+	if err != nil || !ok {
+		t.Fatalf("PresignAttachmentURL() ok=%v err=%v", ok, err)
+	}
+	if !strings.Contains(url, "X-Amz-Expires=600") {
+		t.Fatalf("expected default 10m expiry, got %s", url)
+	}
+
+	attachmentService.SetPresignTTLs(0, 0, 2*time.Hour)
+	if attachmentService.directUploadURLTTL != defaultDirectUploadURLTTL {
+		t.Fatalf("expected non-positive upload TTL to keep default, got %s", attachmentService.directUploadURLTTL)
+	}
+	url, _, err = attachmentService.PresignAttachmentURL(context.Background(), attachment, "")
+	if err != nil {
+		t.Fatalf("PresignAttachmentURL() error = %v", err)
+	}
+	if !strings.Contains(url, "X-Amz-Expires=7200") {
+		t.Fatalf("expected configured 2h expiry, got %s", url)
+	}
+}
+
 func TestMultipartSessionPathEncodeDecode_RoundTrip(t *testing.T) {
 	encoded := encodeMultipartSessionPath(
 		"attachments/1/demo|video.mp4",
@@ -393,3 +1008,90 @@ func generateTestJPEGBytes(t *testing.T, width int, height int) []byte {
 	}
 	return buf.Bytes()
 }
+
+func TestCreateAttachment_InlineUploadLimit(t *testing.T) {
+	services := setupTestServices(t)
+	ctx := context.Background()
+	localStore, err := storage.NewLocalStore(filepath.Join(t.TempDir(), "uploads"))
+	if err != nil {
+		t.Fatalf("NewLocalStore() error = %v", err)
+	}
+	attachmentService := NewAttachmentService(services.store, localStore, 0)
+	attachmentService.SetInlineUploadLimit(10)
+	user := mustCreateUser(t, services.store, "attach-inline-limit")
+
+	for size := 8; size <= 10; size++ {
+		if _, err := attachmentService.CreateAttachment(ctx, user.ID, CreateAttachmentInput{
+			Filename: "small.bin",
+			Content:  base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{byte(size)}, size)),
+		}); err != nil {
+			t.Fatalf("CreateAttachment(%d bytes) error = %v", size, err)
+		}
+	}
+
+	_, err = attachmentService.CreateAttachment(ctx, user.ID, CreateAttachmentInput{
+		Filename: "large.bin",
+		Content:  base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, 11)),
+	})
+	var tooLarge *InlineAttachmentTooLargeError
+	if !errors.As(err, &tooLarge) || tooLarge.Size != 11 || tooLarge.Limit != 10 {
+		t.Fatalf("expected inline limit error for 11 bytes, got %v", err)
+	}
+
+	if _, err := attachmentService.CreateAttachmentUploadSession(ctx, user.ID, CreateAttachmentUploadSessionInput{
+		Filename: "large.bin",
+		Type:     "application/octet-stream",
+		Size:     11,
+	}); err != nil {
+		t.Fatalf("expected upload session to ignore inline limit, got %v", err)
+	}
+}
+
+// generateTestWebPBytes 构造一张纯色的无损 WebP（VP8L）：每个通道都用单符号的简单前缀码，像素本身不占比特。
+func generateTestWebPBytes(width int, height int) []byte {
+	var bits []byte
+	var acc uint64
+	var n uint
+	write := func(value uint64, count uint) {
+		acc |= value << n
+		n += count
+		for n >= 8 {
+			bits = append(bits, byte(acc))
+			acc >>= 8
+			n -= 8
+		}
+	}
+	write(0x2f, 8)
+	write(uint64(width-1), 14)
+	write(uint64(height-1), 14)
+	write(0, 1) // alpha_is_used
+	write(0, 3) // version
+	write(0, 1) // 无变换
+	write(0, 1) // 无颜色缓存
+	write(0, 1) // 无元前缀码
+	// 绿、红、蓝、透明度各一个 8 位符号，距离码一个 1 位符号
+	for _, symbol := range []uint64{0x80, 0x40, 0xc0, 0xff} {
+		write(1, 1) // 简单前缀码
+		write(0, 1) // 1 个符号
+		write(1, 1) // 8 位符号
+		write(symbol, 8)
+	}
+	write(1, 1)
+	write(0, 1)
+	write(0, 1)
+	write(0, 1)
+	if n > 0 {
+		bits = append(bits, byte(acc))
+	}
+	if len(bits)%2 == 1 {
+		bits = append(bits, 0)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("RIFF")
+	_ = binary.Write(&buf, binary.LittleEndian, uint32(4+8+len(bits)))
+	buf.WriteString("WEBPVP8L")
+	_ = binary.Write(&buf, binary.LittleEndian, uint32(len(bits)))
+	buf.Write(bits)
+	return buf.Bytes()
+}
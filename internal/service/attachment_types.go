@@ -0,0 +1,133 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+)
+
+// sniffLength 为 http.DetectContentType 实际参考的最大字节数。
+const sniffLength = 512
+
+// AttachmentTypeNotAllowedError 表示附件类型被白名单或黑名单拒绝；Detected 为 true 时类型来自内容嗅探而非客户端声明。
+type AttachmentTypeNotAllowedError struct {
+	Type     string
+	Detected bool
+}
+
+func (e *AttachmentTypeNotAllowedError) Error() string {
+	if e.Detected {
+		return fmt.Sprintf("attachment content detected as %s is not allowed", e.Type)
+	}
+	return fmt.Sprintf("attachment type %s is not allowed", e.Type)
+}
+
+// SetAttachmentTypeRules 设置附件类型的白名单与黑名单，模式为 image/*、application/x-* 形式的 glob；黑名单优先。
+func (s *AttachmentService) SetAttachmentTypeRules(allowed []string, blocked []string) {
+	s.allowedTypes = normalizeMediaTypePatterns(allowed)
+	s.blockedTypes = normalizeMediaTypePatterns(blocked)
+}
+
+func normalizeMediaTypePatterns(patterns []string) []string {
+	normalized := make([]string, 0, len(patterns))
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		if pattern == "" {
+			continue
+		}
+		normalized = append(normalized, pattern)
+	}
+	return normalized
+}
+
+func (s *AttachmentService) hasAttachmentTypeRules() bool {
+	return len(s.allowedTypes) > 0 || len(s.blockedTypes) > 0
+}
+
+func (s *AttachmentService) isAttachmentTypeAllowed(mediaType string) bool {
+	if matchesMediaTypePattern(mediaType, s.blockedTypes) {
+		return false
+	}
+	return len(s.allowedTypes) == 0 || matchesMediaTypePattern(mediaType, s.allowedTypes)
+}
+
+func matchesMediaTypePattern(mediaType string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, mediaType); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// checkAttachmentType 校验客户端声明的类型。
+func (s *AttachmentService) checkAttachmentType(contentType string) error {
+	mediaType := normalizeMediaType(contentType)
+	if s.isAttachmentTypeAllowed(mediaType) {
+		return nil
+	}
+	return &AttachmentTypeNotAllowedError{Type: mediaType}
+}
+
+// checkSniffedAttachmentType 按内容开头嗅探真实类型，防止把被拒绝的文件伪装成允许的类型上传。
+func (s *AttachmentService) checkSniffedAttachmentType(head []byte) error {
+	if !s.hasAttachmentTypeRules() {
+		return nil
+	}
+	mediaType := normalizeMediaType(http.DetectContentType(head))
+	// 嗅探只认识少数格式，无法识别时得到的泛化类型说明不了真实内容，不据此拒绝
+	if mediaType == "application/octet-stream" || mediaType == "text/plain" {
+		return nil
+	}
+	if s.isAttachmentTypeAllowed(mediaType) {
+		return nil
+	}
+	return &AttachmentTypeNotAllowedError{Type: mediaType, Detected: true}
+}
+
+// checkUploadedFileType 嗅探上传临时文件的类型。
+func (s *AttachmentService) checkUploadedFileType(filePath string) error {
+	if !s.hasAttachmentTypeRules() {
+		return nil
+	}
+	file, err := os.Open(filePath)
+	if err != nil {
+		return fmt.Errorf("open upload temp file: %w", err)
+	}
+	defer file.Close()
+	head, err := io.ReadAll(io.LimitReader(file, sniffLength))
+	if err != nil {
+		return fmt.Errorf("read upload temp file: %w", err)
+	}
+	return s.checkSniffedAttachmentType(head)
+}
+
+// checkStoredObjectType 嗅探已直传到存储中的对象类型，只读取开头的一小段。
+func (s *AttachmentService) checkStoredObjectType(ctx context.Context, storageKey string) error {
+	if !s.hasAttachmentTypeRules() {
+		return nil
+	}
+	reader, err := s.storage.OpenRange(ctx, storageKey, 0, sniffLength-1)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	head, err := io.ReadAll(io.LimitReader(reader, sniffLength))
+	if err != nil {
+		return err
+	}
+	return s.checkSniffedAttachmentType(head)
+}
+
+// normalizeMediaType 去掉参数并转为小写，如 "Text/Plain; charset=utf-8" 得到 "text/plain"。
+func normalizeMediaType(contentType string) string {
+	mediaType := strings.ToLower(strings.TrimSpace(contentType))
+	if idx := strings.Index(mediaType, ";"); idx >= 0 {
+		mediaType = strings.TrimSpace(mediaType[:idx])
+	}
+	return mediaType
+}
@@ -7,6 +7,8 @@ import (
 	"regexp"
 	"slices"
 	"strings"
+	"time"
+	"unicode"
 
 	"github.com/google/cel-go/cel"
 	"github.com/google/cel-go/checker/decls"
@@ -18,8 +20,11 @@ import (
 )
 
 type CELMemoFilter struct {
-	program      cel.Program
-	sqlPrefilter store.MemoSQLPrefilter
+	program         cel.Program
+	sqlPrefilter    store.MemoSQLPrefilter
+	fullyPushedDown bool
+	// contentPushedDown 表示对 content 的引用全部是以 AND 组合的 content.contains("...")，可以交给 FTS 预过滤。
+	contentPushedDown bool
 }
 
 var legacyTagInExpr = regexp.MustCompile(`(?i)\btag\s+in\s+\[((?:\s*"[^"\\]*(?:\\.[^"\\]*)*"\s*,?)*)\]`)
@@ -61,6 +66,9 @@ func CompileMemoFilter(raw string) (*CELMemoFilter, error) {
 			decls.NewVar("has_task_list", decls.Bool),
 			decls.NewVar("has_code", decls.Bool),
 			decls.NewVar("has_incomplete_tasks", decls.Bool),
+			decls.NewVar("create_time", decls.Timestamp),
+			decls.NewVar("update_time", decls.Timestamp),
+			decls.NewVar("display_time", decls.Timestamp),
 		),
 	)
 	if err != nil {
@@ -78,8 +86,10 @@ func CompileMemoFilter(raw string) (*CELMemoFilter, error) {
 	}
 
 	return &CELMemoFilter{
-		program:      program,
-		sqlPrefilter: buildSQLPrefilter(ast.Expr()),
+		program:           program,
+		sqlPrefilter:      buildSQLPrefilter(ast.Expr()),
+		fullyPushedDown:   isPrefilterExact(ast.Expr()),
+		contentPushedDown: countConjunctiveContentContains(ast.Expr()) == countIdentRefs(ast.Expr(), "content"),
 	}, nil
 }
 
@@ -106,6 +116,9 @@ func (f *CELMemoFilter) Matches(memo models.Memo) (bool, error) {
 		"has_task_list":        memo.Payload.Property.HasTaskList,
 		"has_code":             memo.Payload.Property.HasCode,
 		"has_incomplete_tasks": memo.Payload.Property.HasIncompleteTasks,
+		"create_time":          memo.CreateTime,
+		"update_time":          memo.UpdateTime,
+		"display_time":         memo.CreateTime,
 	})
 	if err != nil {
 		return false, fmt.Errorf("evaluate CEL filter: %w", err)
@@ -124,6 +137,21 @@ func (f *CELMemoFilter) SQLPrefilter() store.MemoSQLPrefilter {
 	return f.sqlPrefilter
 }
 
+func (f *CELMemoFilter) FullyPushedDown() bool {
+	if f == nil {
+		return true
+	}
+	return f.fullyPushedDown
+}
+
+// ContentPushedDown 判断过滤条件中对正文的引用能否全部转换为 FTS 预过滤；不引用 content 时为 true。
+func (f *CELMemoFilter) ContentPushedDown() bool {
+	if f == nil {
+		return true
+	}
+	return f.contentPushedDown
+}
+
 func asBool(v ref.Val) (bool, error) {
 	switch val := v.Value().(type) {
 	case bool:
@@ -224,11 +252,15 @@ func derivePrefilter(expr *exprpb.Expr) store.MemoSQLPrefilter {
 			return deriveAtomicNeq(call)
 		case "@in":
 			return deriveAtomicIn(call)
+		case "_<_", "_<=_", "_>_", "_>=_":
+			return deriveAtomicTimeRange(call, false)
 		case "!_":
 			if len(call.Args) != 1 {
 				return store.EmptyMemoPrefilter()
 			}
 			return deriveNegatedPrefilter(call.Args[0])
+		case "contains":
+			return deriveContentContains(call)
 		default:
 			return store.EmptyMemoPrefilter()
 		}
@@ -245,6 +277,94 @@ func derivePrefilter(expr *exprpb.Expr) store.MemoSQLPrefilter {
 	return store.EmptyMemoPrefilter()
 }
 
+func isPrefilterExact(expr *exprpb.Expr) bool {
+	if expr == nil {
+		return false
+	}
+	if c := expr.GetConstExpr(); c != nil {
+		_, ok := constBool(c)
+		return ok
+	}
+	if call := expr.GetCallExpr(); call != nil {
+		switch call.Function {
+		case "_&&_":
+			return len(call.Args) == 2 && isPrefilterExact(call.Args[0]) && isPrefilterExact(call.Args[1])
+		case "_==_":
+			return isAtomicPrefilterExact(deriveAtomicEq(call))
+		case "_!=_":
+			return isAtomicPrefilterExact(deriveAtomicNeq(call))
+		case "@in":
+			return isAtomicPrefilterExact(deriveAtomicIn(call))
+		case "!_":
+			if len(call.Args) != 1 {
+				return false
+			}
+			if inner := call.Args[0].GetCallExpr(); inner != nil {
+				switch inner.Function {
+				case "_==_":
+					return isAtomicPrefilterExact(deriveAtomicNeq(inner))
+				case "_!=_":
+					return isAtomicPrefilterExact(deriveAtomicEq(inner))
+				case "@in":
+					return isAtomicPrefilterExact(deriveAtomicNotIn(inner))
+				}
+				return false
+			}
+			if comp := call.Args[0].GetComprehensionExpr(); comp != nil {
+				group, ok := extractTagExistsGroup(comp)
+				return ok && isExactTagGroup(group)
+			}
+			return false
+		}
+		return false
+	}
+	if comp := expr.GetComprehensionExpr(); comp != nil {
+		group, ok := extractTagExistsGroup(comp)
+		return ok && isExactTagGroup(group)
+	}
+	return false
+}
+
+func isAtomicPrefilterExact(pf store.MemoSQLPrefilter) bool {
+	if pf.Unsatisfiable {
+		return true
+	}
+	for _, group := range pf.TagGroups {
+		if !isExactTagGroup(group) {
+			return false
+		}
+	}
+	for _, group := range pf.ExcludeTagGroups {
+		if !isExactTagGroup(group) {
+			return false
+		}
+	}
+	return len(pf.CreatorIDs) > 0 ||
+		len(pf.VisibilityIn) > 0 ||
+		len(pf.StateIn) > 0 ||
+		pf.Pinned != nil ||
+		pf.HasLink != nil ||
+		pf.HasTaskList != nil ||
+		pf.HasCode != nil ||
+		pf.HasIncompleteTasks != nil ||
+		len(pf.TagGroups) > 0 ||
+		len(pf.ExcludeTagGroups) > 0
+}
+
+// Prefix options map to SQL LIKE, which is case-insensitive and treats % and _
+// as wildcards, so only exact tag matches are equivalent to the CEL semantics.
+func isExactTagGroup(group store.TagMatchGroup) bool {
+	if len(group.Options) == 0 {
+		return false
+	}
+	for _, option := range group.Options {
+		if option.Kind != store.TagMatchExact {
+			return false
+		}
+	}
+	return true
+}
+
 func deriveAtomicEq(call *exprpb.Expr_Call) store.MemoSQLPrefilter {
 	if len(call.Args) != 2 {
 		return store.EmptyMemoPrefilter()
@@ -533,6 +653,115 @@ func deriveAtomicNeq(call *exprpb.Expr_Call) store.MemoSQLPrefilter {
 	}
 }
 
+// deriveAtomicTimeRange 把 create_time/update_time/display_time 与 timestamp("...") 常量的比较转换为时间区间；
+// negated 表示外层有 !，此时比较方向取反。严格与非严格比较都放宽为闭区间，交由内存复核。
+func deriveAtomicTimeRange(call *exprpb.Expr_Call, negated bool) store.MemoSQLPrefilter {
+	if len(call.Args) != 2 {
+		return store.EmptyMemoPrefilter()
+	}
+	lowerBound := call.Function == "_>_" || call.Function == "_>=_"
+	name, t, ok := identAndTimestamp(call.Args[0], call.Args[1])
+	if !ok {
+		// timestamp 在左侧时比较方向相反
+		name, t, ok = identAndTimestamp(call.Args[1], call.Args[0])
+		lowerBound = !lowerBound
+	}
+	if !ok {
+		return store.EmptyMemoPrefilter()
+	}
+	if negated {
+		lowerBound = !lowerBound
+	}
+
+	r := store.TimeRange{To: &t}
+	if lowerBound {
+		r = store.TimeRange{From: &t}
+	}
+	pf := store.EmptyMemoPrefilter()
+	switch name {
+	case "create_time", "display_time":
+		// display_time 始终与 create_time 同步写入，保留它只为兼容 memos 客户端的过滤表达式
+		pf.CreateTime = r
+	case "update_time":
+		pf.UpdateTime = r
+	}
+	return pf
+}
+
+// deriveContentContains 将 content.contains("x") 转换为 FTS 短语匹配。FTS 按分词匹配，
+// 与子串语义不完全一致，因此从不视为精确下推，结果始终在内存中用 CEL 复核。
+func deriveContentContains(call *exprpb.Expr_Call) store.MemoSQLPrefilter {
+	needle, ok := contentContainsNeedle(call)
+	if !ok || strings.IndexFunc(needle, func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }) < 0 {
+		return store.EmptyMemoPrefilter()
+	}
+	return store.MemoSQLPrefilter{
+		ContentMatches: []string{`"` + strings.ReplaceAll(needle, `"`, `""`) + `"`},
+	}
+}
+
+func contentContainsNeedle(call *exprpb.Expr_Call) (string, bool) {
+	if call.Function != "contains" || call.Target == nil || !isIdent(call.Target, "content") || len(call.Args) != 1 {
+		return "", false
+	}
+	return constString(call.Args[0].GetConstExpr())
+}
+
+// countConjunctiveContentContains 统计只经由 && 连接到根节点的 content.contains("...") 调用数量。
+func countConjunctiveContentContains(expr *exprpb.Expr) int {
+	call := expr.GetCallExpr()
+	if call == nil {
+		return 0
+	}
+	if call.Function == "_&&_" && len(call.Args) == 2 {
+		return countConjunctiveContentContains(call.Args[0]) + countConjunctiveContentContains(call.Args[1])
+	}
+	if _, ok := contentContainsNeedle(call); ok {
+		return 1
+	}
+	return 0
+}
+
+func countIdentRefs(expr *exprpb.Expr, name string) int {
+	if expr == nil {
+		return 0
+	}
+	switch kind := expr.ExprKind.(type) {
+	case *exprpb.Expr_IdentExpr:
+		if kind.IdentExpr.Name == name {
+			return 1
+		}
+	case *exprpb.Expr_SelectExpr:
+		return countIdentRefs(kind.SelectExpr.Operand, name)
+	case *exprpb.Expr_CallExpr:
+		count := countIdentRefs(kind.CallExpr.Target, name)
+		for _, arg := range kind.CallExpr.Args {
+			count += countIdentRefs(arg, name)
+		}
+		return count
+	case *exprpb.Expr_ListExpr:
+		count := 0
+		for _, elem := range kind.ListExpr.Elements {
+			count += countIdentRefs(elem, name)
+		}
+		return count
+	case *exprpb.Expr_StructExpr:
+		count := 0
+		for _, entry := range kind.StructExpr.Entries {
+			count += countIdentRefs(entry.GetMapKey(), name) + countIdentRefs(entry.Value, name)
+		}
+		return count
+	case *exprpb.Expr_ComprehensionExpr:
+		comp := kind.ComprehensionExpr
+		return countIdentRefs(comp.IterRange, name) +
+			countIdentRefs(comp.AccuInit, name) +
+			countIdentRefs(comp.LoopCondition, name) +
+			countIdentRefs(comp.LoopStep, name) +
+			countIdentRefs(comp.Result, name)
+	}
+	return 0
+}
+
 func deriveNegatedPrefilter(expr *exprpb.Expr) store.MemoSQLPrefilter {
 	if expr == nil {
 		return store.EmptyMemoPrefilter()
@@ -556,6 +785,8 @@ func deriveNegatedPrefilter(expr *exprpb.Expr) store.MemoSQLPrefilter {
 			return deriveAtomicEq(call)
 		case "@in":
 			return deriveAtomicNotIn(call)
+		case "_<_", "_<=_", "_>_", "_>=_":
+			return deriveAtomicTimeRange(call, true)
 		case "!_":
 			if len(call.Args) != 1 {
 				return store.EmptyMemoPrefilter()
@@ -748,8 +979,18 @@ func mergePrefilterAnd(a store.MemoSQLPrefilter, b store.MemoSQLPrefilter) store
 		return out
 	}
 
+	out.CreateTime, out.Unsatisfiable = mergeTimeRangeAnd(a.CreateTime, b.CreateTime)
+	if out.Unsatisfiable {
+		return out
+	}
+	out.UpdateTime, out.Unsatisfiable = mergeTimeRangeAnd(a.UpdateTime, b.UpdateTime)
+	if out.Unsatisfiable {
+		return out
+	}
+
 	out.TagGroups = append(copyTagGroups(a.TagGroups), b.TagGroups...)
 	out.ExcludeTagGroups = append(copyTagGroups(a.ExcludeTagGroups), b.ExcludeTagGroups...)
+	out.ContentMatches = append(slices.Clone(a.ContentMatches), b.ContentMatches...)
 	return out
 }
 
@@ -776,6 +1017,8 @@ func mergePrefilterOr(a store.MemoSQLPrefilter, b store.MemoSQLPrefilter) store.
 	out.HasIncompleteTasks = mergeBoolPtrOr(a.HasIncompleteTasks, b.HasIncompleteTasks)
 	out.TagGroups = mergeTagGroupsOr(a.TagGroups, b.TagGroups)
 	out.ExcludeTagGroups = intersectTagGroups(a.ExcludeTagGroups, b.ExcludeTagGroups)
+	out.CreateTime = mergeTimeRangeOr(a.CreateTime, b.CreateTime)
+	out.UpdateTime = mergeTimeRangeOr(a.UpdateTime, b.UpdateTime)
 
 	return out
 }
@@ -789,6 +1032,8 @@ func normalizePrefilter(pf store.MemoSQLPrefilter) store.MemoSQLPrefilter {
 	pf.StateIn = uniqueState(pf.StateIn)
 	pf.TagGroups = normalizeTagGroups(pf.TagGroups)
 	pf.ExcludeTagGroups = normalizeTagGroups(pf.ExcludeTagGroups)
+	slices.Sort(pf.ContentMatches)
+	pf.ContentMatches = slices.Compact(pf.ContentMatches)
 	for _, group := range pf.TagGroups {
 		if len(group.Options) == 0 {
 			pf.Unsatisfiable = true
@@ -863,6 +1108,30 @@ func mergeBoolPtrAnd(a *bool, b *bool) (*bool, bool) {
 	}
 }
 
+// mergeTimeRangeAnd 取两个区间的交集，交集为空时返回 true。
+func mergeTimeRangeAnd(a store.TimeRange, b store.TimeRange) (store.TimeRange, bool) {
+	out := store.TimeRange{
+		From: laterTime(a.From, b.From),
+		To:   earlierTime(a.To, b.To),
+	}
+	if out.From != nil && out.To != nil && out.From.After(*out.To) {
+		return store.TimeRange{}, true
+	}
+	return out, false
+}
+
+// mergeTimeRangeOr 取能覆盖两个区间的最小区间，任一侧不限时结果该侧也不限。
+func mergeTimeRangeOr(a store.TimeRange, b store.TimeRange) store.TimeRange {
+	var out store.TimeRange
+	if a.From != nil && b.From != nil {
+		out.From = earlierTime(a.From, b.From)
+	}
+	if a.To != nil && b.To != nil {
+		out.To = laterTime(a.To, b.To)
+	}
+	return out
+}
+
 func mergeSetOr(a []int64, b []int64) []int64 {
 	if len(a) == 0 || len(b) == 0 {
 		return nil
@@ -1016,6 +1285,50 @@ func ptrBool(v bool) *bool {
 	return &v
 }
 
+// identAndTimestamp 匹配 ident 与 timestamp("RFC3339") 常量调用。
+func identAndTimestamp(left *exprpb.Expr, right *exprpb.Expr) (string, time.Time, bool) {
+	id := left.GetIdentExpr()
+	call := right.GetCallExpr()
+	if id == nil || call == nil || call.Function != "timestamp" || call.Target != nil || len(call.Args) != 1 {
+		return "", time.Time{}, false
+	}
+	raw, ok := constString(call.Args[0].GetConstExpr())
+	if !ok {
+		return "", time.Time{}, false
+	}
+	t, err := time.Parse(time.RFC3339Nano, raw)
+	if err != nil {
+		return "", time.Time{}, false
+	}
+	return id.Name, t.UTC(), true
+}
+
+func laterTime(a *time.Time, b *time.Time) *time.Time {
+	switch {
+	case a == nil:
+		return b
+	case b == nil:
+		return a
+	case b.After(*a):
+		return b
+	default:
+		return a
+	}
+}
+
+func earlierTime(a *time.Time, b *time.Time) *time.Time {
+	switch {
+	case a == nil:
+		return b
+	case b == nil:
+		return a
+	case b.Before(*a):
+		return b
+	default:
+		return a
+	}
+}
+
 func identAndConst(left *exprpb.Expr, right *exprpb.Expr) (string, *exprpb.Constant, bool) {
 	id := left.GetIdentExpr()
 	if id == nil {
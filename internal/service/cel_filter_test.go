@@ -2,6 +2,7 @@ package service
 
 import (
 	"testing"
+	"time"
 
 	"github.com/shinyes/keer/internal/models"
 	"github.com/shinyes/keer/internal/store"
@@ -148,6 +149,32 @@ func TestCompileMemoFilter_SQLPrefilterNegatedTagMembership(t *testing.T) {
 	}
 }
 
+func TestCompileMemoFilter_FullyPushedDown(t *testing.T) {
+	cases := []struct {
+		filter string
+		want   bool
+	}{
+		{filter: `creator_id == 7 && visibility in ["PRIVATE","PROTECTED"] && "book" in tags`, want: true},
+		{filter: `pinned != true && !("work" in tags) && property.hasLink == true`, want: true},
+		{filter: `tags.exists(t, t == "book")`, want: true},
+		{filter: `creator_id == 1 && creator_id == 2`, want: true},
+		{filter: `creator_id == 1 || creator_id == 2`, want: false},
+		{filter: `tags.exists(t, t.startsWith("book"))`, want: false},
+		{filter: `tag in ["book"]`, want: false},
+		{filter: `creator_id != 3`, want: false},
+		{filter: `!(creator_id == 1 && pinned == true)`, want: false},
+	}
+	for _, tc := range cases {
+		filter, err := CompileMemoFilter(tc.filter)
+		if err != nil {
+			t.Fatalf("CompileMemoFilter(%q) error = %v", tc.filter, err)
+		}
+		if got := filter.FullyPushedDown(); got != tc.want {
+			t.Fatalf("FullyPushedDown(%q) = %v, want %v", tc.filter, got, tc.want)
+		}
+	}
+}
+
 func containsVisibility(values []models.Visibility, target models.Visibility) bool {
 	for _, v := range values {
 		if v == target {
@@ -156,3 +183,106 @@ func containsVisibility(values []models.Visibility, target models.Visibility) bo
 	}
 	return false
 }
+
+func TestCompileMemoFilter_CreateTimeRangePrefilter(t *testing.T) {
+	filter, err := CompileMemoFilter(`create_time >= timestamp("2024-01-01T00:00:00Z") && timestamp("2024-01-08T00:00:00Z") > create_time`)
+	if err != nil {
+		t.Fatalf("CompileMemoFilter() error = %v", err)
+	}
+	pf := filter.SQLPrefilter()
+	if pf.CreateTime.From == nil || !pf.CreateTime.From.Equal(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected CreateTime.From: %v", pf.CreateTime.From)
+	}
+	if pf.CreateTime.To == nil || !pf.CreateTime.To.Equal(time.Date(2024, 1, 8, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected CreateTime.To: %v", pf.CreateTime.To)
+	}
+	if filter.FullyPushedDown() {
+		t.Fatalf("expected create_time comparisons to be re-checked in memory")
+	}
+
+	negated, err := CompileMemoFilter(`!(create_time > timestamp("2024-01-01T00:00:00Z"))`)
+	if err != nil {
+		t.Fatalf("CompileMemoFilter(negated) error = %v", err)
+	}
+	if pf := negated.SQLPrefilter(); pf.CreateTime.From != nil || pf.CreateTime.To == nil {
+		t.Fatalf("expected negated lower bound to become upper bound, got %+v", pf)
+	}
+
+	empty, err := CompileMemoFilter(`create_time > timestamp("2024-02-01T00:00:00Z") && create_time < timestamp("2024-01-01T00:00:00Z")`)
+	if err != nil {
+		t.Fatalf("CompileMemoFilter(empty) error = %v", err)
+	}
+	if !empty.SQLPrefilter().Unsatisfiable {
+		t.Fatalf("expected disjoint create_time range to be unsatisfiable")
+	}
+}
+
+func TestCompileMemoFilter_UpdateTimeRangeOR(t *testing.T) {
+	filter, err := CompileMemoFilter(`update_time > timestamp("2024-03-01T00:00:00Z") || update_time > timestamp("2024-02-01T00:00:00Z")`)
+	if err != nil {
+		t.Fatalf("CompileMemoFilter() error = %v", err)
+	}
+	pf := filter.SQLPrefilter()
+	if pf.UpdateTime.From == nil || !pf.UpdateTime.From.Equal(time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("expected OR to keep the looser lower bound, got %v", pf.UpdateTime.From)
+	}
+	if !pf.CreateTime.IsEmpty() {
+		t.Fatalf("expected create_time range to stay empty, got %+v", pf.CreateTime)
+	}
+
+	alias, err := CompileMemoFilter(`display_time < timestamp("2024-02-01T00:00:00Z")`)
+	if err != nil {
+		t.Fatalf("CompileMemoFilter(display_time) error = %v", err)
+	}
+	if pf := alias.SQLPrefilter(); pf.CreateTime.To == nil {
+		t.Fatalf("expected display_time to push down as create_time, got %+v", pf)
+	}
+}
+
+func TestCompileMemoFilter_NegatedMultiPrefixExcludeGroup(t *testing.T) {
+	filter, err := CompileMemoFilter(`!tags.exists(t, t.startsWith("a/") || t.startsWith("b/"))`)
+	if err != nil {
+		t.Fatalf("CompileMemoFilter() error = %v", err)
+	}
+	pf := filter.SQLPrefilter()
+	if len(pf.TagGroups) != 0 || len(pf.ExcludeTagGroups) != 1 {
+		t.Fatalf("expected a single exclude group, got %+v", pf)
+	}
+	options := pf.ExcludeTagGroups[0].Options
+	if len(options) != 2 {
+		t.Fatalf("expected two prefix options, got %+v", options)
+	}
+	for i, want := range []string{"a/", "b/"} {
+		if options[i].Kind != store.TagMatchPrefix || options[i].Value != want {
+			t.Fatalf("unexpected option %d: %+v", i, options[i])
+		}
+	}
+}
+
+func TestCompileMemoFilter_ContentContainsPrefilter(t *testing.T) {
+	filter, err := CompileMemoFilter(`content.contains("say \"hi\"") && visibility == "PUBLIC"`)
+	if err != nil {
+		t.Fatalf("CompileMemoFilter() error = %v", err)
+	}
+	pf := filter.SQLPrefilter()
+	if len(pf.ContentMatches) != 1 || pf.ContentMatches[0] != `"say ""hi"""` {
+		t.Fatalf("expected quoted FTS phrase, got %+v", pf.ContentMatches)
+	}
+	if filter.FullyPushedDown() || !filter.ContentPushedDown() {
+		t.Fatalf("expected FTS prefilter to need in-memory recheck")
+	}
+
+	for _, raw := range []string{
+		`content.contains("a") || pinned`,
+		`!content.contains("a")`,
+		`content.size() > 3`,
+	} {
+		filter, err := CompileMemoFilter(raw)
+		if err != nil {
+			t.Fatalf("CompileMemoFilter(%s) error = %v", raw, err)
+		}
+		if filter.ContentPushedDown() || len(filter.SQLPrefilter().ContentMatches) != 0 {
+			t.Fatalf("expected %s not to push content down, got %+v", raw, filter.SQLPrefilter())
+		}
+	}
+}
@@ -3,7 +3,10 @@ package service
 import (
 	"context"
 	"fmt"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/shinyes/keer/internal/models"
 	"github.com/shinyes/keer/internal/store"
@@ -209,3 +212,307 @@ func TestListMemos_CELNotAndNotEqual(t *testing.T) {
 	_ = m1
 	_ = m3
 }
+
+func TestListMemos_PushedDownFilterPaginates(t *testing.T) {
+	services := setupTestServices(t)
+	ctx := context.Background()
+	user := mustCreateUser(t, services.store, "u-paging")
+
+	for i := 0; i < 5; i++ {
+		if _, err := services.memoService.CreateMemo(ctx, user.ID, CreateMemoInput{
+			Content:    "#book",
+			Tags:       []string{"book"},
+			Visibility: models.VisibilityPrivate,
+		}); err != nil {
+			t.Fatalf("CreateMemo() error = %v", err)
+		}
+	}
+	if _, err := services.memoService.CreateMemo(ctx, user.ID, CreateMemoInput{
+		Content:    "#work",
+		Tags:       []string{"work"},
+		Visibility: models.VisibilityPrivate,
+	}); err != nil {
+		t.Fatalf("CreateMemo() error = %v", err)
+	}
+
+	seen := map[int64]struct{}{}
+	pageToken := ""
+	for pages := 0; pages < 5; pages++ {
+		list, nextToken, err := services.memoService.ListMemos(ctx, user.ID, nil, `"book" in tags`, 2, pageToken)
+		if err != nil {
+			t.Fatalf("ListMemos() error = %v", err)
+		}
+		for _, item := range list {
+			seen[item.Memo.ID] = struct{}{}
+		}
+		if nextToken == "" {
+			break
+		}
+		pageToken = nextToken
+	}
+	if len(seen) != 5 {
+		t.Fatalf("expected 5 distinct book memos across pages, got %d", len(seen))
+	}
+
+	// 旧版整数偏移量 token 仍然可用
+	legacy, _, err := services.memoService.ListMemos(ctx, user.ID, nil, `"book" in tags`, 2, "4")
+	if err != nil {
+		t.Fatalf("ListMemos(legacy token) error = %v", err)
+	}
+	if len(legacy) != 1 {
+		t.Fatalf("expected legacy offset token to return 1 memo, got %d", len(legacy))
+	}
+}
+
+func TestListMemos_CursorStableAcrossInserts(t *testing.T) {
+	services := setupTestServices(t)
+	ctx := context.Background()
+	user := mustCreateUser(t, services.store, "u-cursor")
+
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 4; i++ {
+		createTime := base.Add(time.Duration(i) * time.Minute)
+		if _, err := services.memoService.CreateMemo(ctx, user.ID, CreateMemoInput{
+			Content:    fmt.Sprintf("memo %d", i),
+			CreateTime: &createTime,
+		}); err != nil {
+			t.Fatalf("CreateMemo() error = %v", err)
+		}
+	}
+
+	first, nextToken, err := services.memoService.ListMemos(ctx, user.ID, nil, "", 2, "")
+	if err != nil {
+		t.Fatalf("ListMemos() error = %v", err)
+	}
+	if len(first) != 2 || nextToken == "" {
+		t.Fatalf("expected first page of 2 with next token, got %d %q", len(first), nextToken)
+	}
+	if _, err := strconv.Atoi(nextToken); err == nil {
+		t.Fatalf("expected opaque cursor token, got integer %q", nextToken)
+	}
+
+	// 新插入的 memo 排在最前，不应导致下一页重复返回已看过的记录
+	newest := base.Add(time.Hour)
+	if _, err := services.memoService.CreateMemo(ctx, user.ID, CreateMemoInput{
+		Content:    "inserted later",
+		CreateTime: &newest,
+	}); err != nil {
+		t.Fatalf("CreateMemo() error = %v", err)
+	}
+
+	second, nextToken, err := services.memoService.ListMemos(ctx, user.ID, nil, "", 2, nextToken)
+	if err != nil {
+		t.Fatalf("ListMemos(cursor) error = %v", err)
+	}
+	if nextToken != "" {
+		t.Fatalf("expected last page, got next token %q", nextToken)
+	}
+	got := []string{second[0].Memo.Content, second[1].Memo.Content}
+	if got[0] != "memo 1" || got[1] != "memo 0" {
+		t.Fatalf("expected [memo 1 memo 0], got %v", got)
+	}
+
+	if _, _, err := services.memoService.ListMemos(ctx, user.ID, nil, "", 2, "not-a-cursor"); err == nil {
+		t.Fatalf("expected invalid cursor to be rejected")
+	}
+}
+
+func TestScanFilteredMemos_ReturnsCursorAtScanLimit(t *testing.T) {
+	services := setupTestServices(t)
+	ctx := context.Background()
+	user := mustCreateUser(t, services.store, "u-scan-limit")
+
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 10; i++ {
+		tag := "work"
+		if i < 3 {
+			tag = "book"
+		}
+		createTime := base.Add(time.Duration(i) * time.Minute)
+		if _, err := services.memoService.CreateMemo(ctx, user.ID, CreateMemoInput{
+			Content:    fmt.Sprintf("#%s %d", tag, i),
+			Tags:       []string{tag},
+			CreateTime: &createTime,
+		}); err != nil {
+			t.Fatalf("CreateMemo() error = %v", err)
+		}
+	}
+	filter, err := services.memoService.compileMemoFilter(`tags.exists(t, t.startsWith("book"))`)
+	if err != nil {
+		t.Fatalf("compileMemoFilter() error = %v", err)
+	}
+	state := models.MemoStateNormal
+	// 不带 SQL 预过滤，模拟条件只能在内存中判断的情形
+	prefilter := store.EmptyMemoPrefilter()
+
+	// 最新的 7 条都不匹配：扫描上限为 4 时第一页为空，但必须带上游标而不是被截断
+	page, nextToken, err := services.memoService.scanFilteredMemos(ctx, user.ID, &state, prefilter, filter, 0, 2, nil, 4)
+	if err != nil {
+		t.Fatalf("scanFilteredMemos() error = %v", err)
+	}
+	if len(page) != 0 || nextToken == "" {
+		t.Fatalf("expected empty page with cursor at scan limit, got %d memos, token %q", len(page), nextToken)
+	}
+
+	var contents []string
+	for pages := 0; nextToken != "" && pages < 10; pages++ {
+		_, cursor, err := parsePageToken(nextToken)
+		if err != nil {
+			t.Fatalf("parsePageToken() error = %v", err)
+		}
+		page, nextToken, err = services.memoService.scanFilteredMemos(ctx, user.ID, &state, prefilter, filter, 0, 2, cursor, 4)
+		if err != nil {
+			t.Fatalf("scanFilteredMemos(cursor) error = %v", err)
+		}
+		for _, memo := range page {
+			contents = append(contents, memo.Content)
+		}
+	}
+	if strings.Join(contents, ",") != "#book 2,#book 1,#book 0" {
+		t.Fatalf("expected every book memo exactly once in order, got %v", contents)
+	}
+}
+
+func TestListMemos_CreateTimeRange(t *testing.T) {
+	services := setupTestServices(t)
+	ctx := context.Background()
+	user := mustCreateUser(t, services.store, "create-time-range")
+
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	for i, offset := range []time.Duration{-48 * time.Hour, 500 * time.Millisecond, 24 * time.Hour, 10 * 24 * time.Hour} {
+		createTime := base.Add(offset)
+		if _, err := services.memoService.CreateMemo(ctx, user.ID, CreateMemoInput{
+			Content:    fmt.Sprintf("memo %d", i),
+			Visibility: models.VisibilityPrivate,
+			CreateTime: &createTime,
+		}); err != nil {
+			t.Fatalf("CreateMemo(%d) error = %v", i, err)
+		}
+	}
+
+	list, _, err := services.memoService.ListMemos(ctx, user.ID, nil, `create_time > timestamp("2024-01-01T12:00:00Z") && create_time < timestamp("2024-01-08T00:00:00Z")`, 200, "")
+	if err != nil {
+		t.Fatalf("ListMemos(create_time range) error = %v", err)
+	}
+	if len(list) != 2 || list[0].Memo.Content != "memo 2" || list[1].Memo.Content != "memo 1" {
+		t.Fatalf("unexpected memos in create_time range: %+v", list)
+	}
+
+	// 秒级放宽后仍需在内存中精确比较，同一秒内早于边界的 memo 不应返回
+	list, _, err = services.memoService.ListMemos(ctx, user.ID, nil, `create_time >= timestamp("2024-01-01T12:00:00.600Z")`, 200, "")
+	if err != nil {
+		t.Fatalf("ListMemos(sub-second bound) error = %v", err)
+	}
+	if len(list) != 2 {
+		t.Fatalf("expected 2 memos after sub-second bound, got %d", len(list))
+	}
+}
+
+func TestListMemos_UpdateTimeFilterComposesWithSyncBounds(t *testing.T) {
+	services := setupTestServices(t)
+	ctx := context.Background()
+	user := mustCreateUser(t, services.store, "update-time-filter")
+
+	beforeCreate := time.Now().UTC().Add(-time.Second)
+	stale, err := services.memoService.CreateMemo(ctx, user.ID, CreateMemoInput{Content: "stale", Visibility: models.VisibilityPrivate})
+	if err != nil {
+		t.Fatalf("CreateMemo(stale) error = %v", err)
+	}
+	edited, err := services.memoService.CreateMemo(ctx, user.ID, CreateMemoInput{Content: "edited", Visibility: models.VisibilityPrivate})
+	if err != nil {
+		t.Fatalf("CreateMemo(edited) error = %v", err)
+	}
+	mark := time.Now().UTC()
+	time.Sleep(5 * time.Millisecond)
+	content := "edited again"
+	if _, err := services.memoService.UpdateMemo(ctx, user.ID, edited.Memo.ID, UpdateMemoInput{Content: &content}); err != nil {
+		t.Fatalf("UpdateMemo() error = %v", err)
+	}
+
+	filter := fmt.Sprintf(`update_time > timestamp(%q)`, mark.Format(time.RFC3339Nano))
+	list, _, err := services.memoService.ListMemos(ctx, user.ID, nil, filter, 200, "")
+	if err != nil {
+		t.Fatalf("ListMemos(update_time) error = %v", err)
+	}
+	if len(list) != 1 || list[0].Memo.ID != edited.Memo.ID {
+		t.Fatalf("expected only the edited memo, got %+v", list)
+	}
+
+	changes, err := services.memoService.ListMemoChanges(ctx, user.ID, nil, filter, beforeCreate, time.Now().UTC())
+	if err != nil {
+		t.Fatalf("ListMemoChanges(update_time) error = %v", err)
+	}
+	if len(changes.Memos) != 1 || changes.Memos[0].Memo.ID != edited.Memo.ID {
+		t.Fatalf("expected sync window and update_time filter to intersect, got %+v", changes.Memos)
+	}
+
+	list, _, err = services.memoService.ListMemos(ctx, user.ID, nil, fmt.Sprintf(`display_time <= timestamp(%q)`, mark.Format(time.RFC3339Nano)), 200, "")
+	if err != nil {
+		t.Fatalf("ListMemos(display_time) error = %v", err)
+	}
+	if len(list) != 2 || list[1].Memo.ID != stale.Memo.ID {
+		t.Fatalf("expected both memos by display_time, got %+v", list)
+	}
+}
+
+func TestListMemos_ExcludeMultipleTagPrefixes(t *testing.T) {
+	services := setupTestServices(t)
+	ctx := context.Background()
+	user := mustCreateUser(t, services.store, "exclude-prefixes")
+
+	for _, tag := range []string{"a/one", "b/two", "c/three"} {
+		if _, err := services.memoService.CreateMemo(ctx, user.ID, CreateMemoInput{
+			Content:    "#" + tag,
+			Tags:       []string{tag},
+			Visibility: models.VisibilityPrivate,
+		}); err != nil {
+			t.Fatalf("CreateMemo(%s) error = %v", tag, err)
+		}
+	}
+
+	list, _, err := services.memoService.ListMemos(ctx, user.ID, nil, `!tags.exists(t, t.startsWith("a/") || t.startsWith("b/"))`, 200, "")
+	if err != nil {
+		t.Fatalf("ListMemos() error = %v", err)
+	}
+	if len(list) != 1 || list[0].Memo.Content != "#c/three" {
+		t.Fatalf("expected only the c/ memo, got %+v", list)
+	}
+}
+
+func TestListMemos_ContentContainsWithFTS(t *testing.T) {
+	services := setupTestServices(t)
+	ctx := context.Background()
+	user := mustCreateUser(t, services.store, "content-fts")
+
+	for _, content := range []string{"buy a book today", "Book club notes", "my notebook", "unrelated"} {
+		if _, err := services.memoService.CreateMemo(ctx, user.ID, CreateMemoInput{Content: content, Visibility: models.VisibilityPrivate}); err != nil {
+			t.Fatalf("CreateMemo(%q) error = %v", content, err)
+		}
+	}
+
+	if _, _, err := services.memoService.ListMemos(ctx, user.ID, nil, `content.contains("book")`, 200, ""); err == nil {
+		t.Fatalf("expected content filter to be rejected while FTS filtering is off")
+	}
+
+	services.memoService.SetContentFilterFTS(true)
+	// FTS 按词匹配后再用 CEL 复核：大小写不同的 Book 被复核排除，notebook 不是独立词不会被 FTS 命中。
+	list, _, err := services.memoService.ListMemos(ctx, user.ID, nil, `content.contains("book") && pinned == false`, 200, "")
+	if err != nil {
+		t.Fatalf("ListMemos(content.contains) error = %v", err)
+	}
+	if len(list) != 1 || list[0].Memo.Content != "buy a book today" {
+		t.Fatalf("expected only the exact word match, got %+v", list)
+	}
+
+	for _, filter := range []string{
+		`!content.contains("book")`,
+		`content.contains("book") || pinned`,
+		`content.startsWith("buy")`,
+		`content == "unrelated"`,
+		`property.hasLink == true`,
+	} {
+		if _, _, err := services.memoService.ListMemos(ctx, user.ID, nil, filter, 200, ""); err == nil {
+			t.Fatalf("expected %s to be rejected", filter)
+		}
+	}
+}
@@ -3,16 +3,21 @@ package service
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"strconv"
 	"strings"
 
+	"github.com/shinyes/keer/internal/markdown"
 	"github.com/shinyes/keer/internal/models"
 	"github.com/shinyes/keer/internal/store"
 )
 
+var ErrGroupCreatorOnly = errors.New("only the group creator can manage the group")
+
 type GroupService struct {
-	store *store.SQLStore
+	store    *store.SQLStore
+	markdown *markdown.Service
 }
 
 type GroupWithMembers struct {
@@ -26,7 +31,10 @@ type GroupMessageWithCreator struct {
 }
 
 func NewGroupService(s *store.SQLStore) *GroupService {
-	return &GroupService{store: s}
+	return &GroupService{
+		store:    s,
+		markdown: markdown.NewService(),
+	}
 }
 
 func (s *GroupService) CreateGroup(
@@ -70,6 +78,9 @@ func (s *GroupService) UpdateGroup(
 	if err := s.ensureGroupMember(ctx, groupID, userID); err != nil {
 		return GroupWithMembers{}, err
 	}
+	if group.CreatorID != userID {
+		return GroupWithMembers{}, ErrGroupCreatorOnly
+	}
 
 	nextName := group.GroupName
 	if name != nil {
@@ -103,6 +114,39 @@ func (s *GroupService) DeleteOrLeaveGroup(ctx context.Context, userID int64, gro
 	return s.store.RemoveGroupMember(ctx, groupID, userID)
 }
 
+func (s *GroupService) GetGroup(ctx context.Context, userID int64, groupID int64) (GroupWithMembers, error) {
+	if err := s.ensureGroupMember(ctx, groupID, userID); err != nil {
+		return GroupWithMembers{}, err
+	}
+	return s.loadGroupWithMembers(ctx, groupID)
+}
+
+func (s *GroupService) AddGroupMember(ctx context.Context, userID int64, groupID int64, memberID int64) (GroupWithMembers, error) {
+	if err := s.ensureGroupCreator(ctx, groupID, userID); err != nil {
+		return GroupWithMembers{}, err
+	}
+	if _, err := s.store.GetUserByID(ctx, memberID); err != nil {
+		return GroupWithMembers{}, err
+	}
+	if err := s.store.AddGroupMember(ctx, groupID, memberID); err != nil {
+		return GroupWithMembers{}, err
+	}
+	return s.loadGroupWithMembers(ctx, groupID)
+}
+
+func (s *GroupService) RemoveGroupMember(ctx context.Context, userID int64, groupID int64, memberID int64) (GroupWithMembers, error) {
+	if err := s.ensureGroupCreator(ctx, groupID, userID); err != nil {
+		return GroupWithMembers{}, err
+	}
+	if memberID == userID {
+		return GroupWithMembers{}, fmt.Errorf("group creator cannot be removed")
+	}
+	if err := s.store.RemoveGroupMember(ctx, groupID, memberID); err != nil {
+		return GroupWithMembers{}, err
+	}
+	return s.loadGroupWithMembers(ctx, groupID)
+}
+
 func (s *GroupService) ListGroups(ctx context.Context, userID int64) ([]GroupWithMembers, error) {
 	groups, err := s.store.ListGroupsByUser(ctx, userID)
 	if err != nil {
@@ -161,26 +205,9 @@ func (s *GroupService) ListGroupMessages(
 	if err != nil {
 		return nil, "", err
 	}
-	if len(msgs) == 0 {
-		return []GroupMessageWithCreator{}, "", nil
-	}
-
-	creatorMap := make(map[int64]models.User)
-	result := make([]GroupMessageWithCreator, 0, len(msgs))
-	for _, msg := range msgs {
-		creator, ok := creatorMap[msg.CreatorID]
-		if !ok {
-			user, err := s.store.GetUserByID(ctx, msg.CreatorID)
-			if err != nil {
-				return nil, "", err
-			}
-			creator = user
-			creatorMap[msg.CreatorID] = user
-		}
-		result = append(result, GroupMessageWithCreator{
-			Message: msg,
-			Creator: creator,
-		})
+	result, err := s.withMessageCreators(ctx, msgs)
+	if err != nil {
+		return nil, "", err
 	}
 
 	nextToken := ""
@@ -190,6 +217,44 @@ func (s *GroupService) ListGroupMessages(
 	return result, nextToken, nil
 }
 
+func (s *GroupService) ListGroupMessagesBefore(
+	ctx context.Context,
+	userID int64,
+	groupID int64,
+	limit int,
+	beforeID int64,
+) ([]GroupMessageWithCreator, error) {
+	if err := s.ensureGroupMember(ctx, groupID, userID); err != nil {
+		return nil, err
+	}
+	msgs, err := s.store.ListGroupMessages(ctx, groupID, limit, beforeID)
+	if err != nil {
+		return nil, err
+	}
+	return s.withMessageCreators(ctx, msgs)
+}
+
+func (s *GroupService) GetGroupMessage(ctx context.Context, userID int64, groupID int64, messageID int64) (GroupMessageWithCreator, error) {
+	if err := s.ensureGroupMember(ctx, groupID, userID); err != nil {
+		return GroupMessageWithCreator{}, err
+	}
+	msg, err := s.store.GetGroupMessageByID(ctx, messageID)
+	if err != nil {
+		return GroupMessageWithCreator{}, err
+	}
+	if msg.GroupID != groupID {
+		return GroupMessageWithCreator{}, sql.ErrNoRows
+	}
+	creator, err := s.store.GetUserByID(ctx, msg.CreatorID)
+	if err != nil {
+		return GroupMessageWithCreator{}, err
+	}
+	return GroupMessageWithCreator{
+		Message: msg,
+		Creator: creator,
+	}, nil
+}
+
 func (s *GroupService) CreateGroupMessage(
 	ctx context.Context,
 	userID int64,
@@ -204,7 +269,15 @@ func (s *GroupService) CreateGroupMessage(
 	if normalizedContent == "" {
 		return GroupMessageWithCreator{}, fmt.Errorf("message content is required")
 	}
-	msg, err := s.store.CreateGroupMessage(ctx, groupID, userID, normalizedContent, tags)
+	// 正文中的 #标签 与请求中显式传入的标签合并，由 store 负责去重
+	extracted, err := s.markdown.ExtractTags(normalizedContent)
+	if err != nil {
+		return GroupMessageWithCreator{}, err
+	}
+	mergedTags := make([]string, 0, len(tags)+len(extracted))
+	mergedTags = append(mergedTags, tags...)
+	mergedTags = append(mergedTags, extracted...)
+	msg, err := s.store.CreateGroupMessage(ctx, groupID, userID, normalizedContent, mergedTags)
 	if err != nil {
 		return GroupMessageWithCreator{}, err
 	}
@@ -218,6 +291,27 @@ func (s *GroupService) CreateGroupMessage(
 	}, nil
 }
 
+func (s *GroupService) withMessageCreators(ctx context.Context, msgs []models.GroupMessage) ([]GroupMessageWithCreator, error) {
+	creatorMap := make(map[int64]models.User)
+	result := make([]GroupMessageWithCreator, 0, len(msgs))
+	for _, msg := range msgs {
+		creator, ok := creatorMap[msg.CreatorID]
+		if !ok {
+			user, err := s.store.GetUserByID(ctx, msg.CreatorID)
+			if err != nil {
+				return nil, err
+			}
+			creator = user
+			creatorMap[msg.CreatorID] = user
+		}
+		result = append(result, GroupMessageWithCreator{
+			Message: msg,
+			Creator: creator,
+		})
+	}
+	return result, nil
+}
+
 func (s *GroupService) ensureGroupMember(ctx context.Context, groupID int64, userID int64) error {
 	member, err := s.store.IsGroupMember(ctx, groupID, userID)
 	if err != nil {
@@ -229,6 +323,21 @@ func (s *GroupService) ensureGroupMember(ctx context.Context, groupID int64, use
 	return nil
 }
 
+// ensureGroupCreator 非成员返回 sql.ErrNoRows，成员但非创建者返回 ErrGroupCreatorOnly
+func (s *GroupService) ensureGroupCreator(ctx context.Context, groupID int64, userID int64) error {
+	group, err := s.store.GetGroupByID(ctx, groupID)
+	if err != nil {
+		return err
+	}
+	if err := s.ensureGroupMember(ctx, groupID, userID); err != nil {
+		return err
+	}
+	if group.CreatorID != userID {
+		return ErrGroupCreatorOnly
+	}
+	return nil
+}
+
 func (s *GroupService) loadGroupWithMembers(ctx context.Context, groupID int64) (GroupWithMembers, error) {
 	group, err := s.store.GetGroupByID(ctx, groupID)
 	if err != nil {
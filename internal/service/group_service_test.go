@@ -0,0 +1,170 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func TestListGroupMessagesBefore(t *testing.T) {
+	services := setupTestServices(t)
+	ctx := context.Background()
+	groupService := NewGroupService(services.store)
+	owner := mustCreateUser(t, services.store, "group-msg-owner")
+	outsider := mustCreateUser(t, services.store, "group-msg-outsider")
+
+	group, err := groupService.CreateGroup(ctx, owner.ID, "team", "")
+	if err != nil {
+		t.Fatalf("CreateGroup() error = %v", err)
+	}
+	groupID := group.Group.ID
+	messageIDs := make([]int64, 0, 5)
+	for i := 0; i < 5; i++ {
+		msg, err := groupService.CreateGroupMessage(ctx, owner.ID, groupID, fmt.Sprintf("message %d", i), []string{"daily"})
+		if err != nil {
+			t.Fatalf("CreateGroupMessage() error = %v", err)
+		}
+		messageIDs = append(messageIDs, msg.Message.ID)
+	}
+
+	latest, err := groupService.ListGroupMessagesBefore(ctx, owner.ID, groupID, 2, 0)
+	if err != nil {
+		t.Fatalf("ListGroupMessagesBefore(latest) error = %v", err)
+	}
+	if len(latest) != 2 || latest[0].Message.Content != "message 3" || latest[1].Message.Content != "message 4" {
+		t.Fatalf("expected latest two messages in chronological order, got %+v", latest)
+	}
+	if latest[0].Creator.ID != owner.ID || len(latest[0].Message.Tags) != 1 || latest[0].Message.Tags[0] != "daily" {
+		t.Fatalf("expected creator and tags on listed message, got %+v", latest[0])
+	}
+
+	older, err := groupService.ListGroupMessagesBefore(ctx, owner.ID, groupID, 2, latest[0].Message.ID)
+	if err != nil {
+		t.Fatalf("ListGroupMessagesBefore(older) error = %v", err)
+	}
+	if len(older) != 2 || older[0].Message.Content != "message 1" || older[1].Message.Content != "message 2" {
+		t.Fatalf("expected messages 1 and 2 before cursor, got %+v", older)
+	}
+
+	if _, err := groupService.ListGroupMessagesBefore(ctx, outsider.ID, groupID, 2, 0); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("expected non-member listing to fail with sql.ErrNoRows, got %v", err)
+	}
+
+	got, err := groupService.GetGroupMessage(ctx, owner.ID, groupID, messageIDs[0])
+	if err != nil {
+		t.Fatalf("GetGroupMessage() error = %v", err)
+	}
+	if got.Message.Content != "message 0" || got.Creator.ID != owner.ID {
+		t.Fatalf("unexpected message %+v", got)
+	}
+	if _, err := groupService.GetGroupMessage(ctx, outsider.ID, groupID, messageIDs[0]); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("expected non-member get to fail with sql.ErrNoRows, got %v", err)
+	}
+
+	otherGroup, err := groupService.CreateGroup(ctx, owner.ID, "other", "")
+	if err != nil {
+		t.Fatalf("CreateGroup(other) error = %v", err)
+	}
+	if _, err := groupService.GetGroupMessage(ctx, owner.ID, otherGroup.Group.ID, messageIDs[0]); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("expected message lookup through another group to fail, got %v", err)
+	}
+}
+
+func TestGroupMemberManagement_CreatorOnly(t *testing.T) {
+	services := setupTestServices(t)
+	ctx := context.Background()
+	groupService := NewGroupService(services.store)
+	owner := mustCreateUser(t, services.store, "group-crud-owner")
+	member := mustCreateUser(t, services.store, "group-crud-member")
+	outsider := mustCreateUser(t, services.store, "group-crud-outsider")
+
+	group, err := groupService.CreateGroup(ctx, owner.ID, "team", "")
+	if err != nil {
+		t.Fatalf("CreateGroup() error = %v", err)
+	}
+	groupID := group.Group.ID
+
+	added, err := groupService.AddGroupMember(ctx, owner.ID, groupID, member.ID)
+	if err != nil {
+		t.Fatalf("AddGroupMember() error = %v", err)
+	}
+	if len(added.Members) != 2 {
+		t.Fatalf("expected 2 members after add, got %+v", added.Members)
+	}
+
+	got, err := groupService.GetGroup(ctx, member.ID, groupID)
+	if err != nil {
+		t.Fatalf("GetGroup(member) error = %v", err)
+	}
+	if got.Group.ID != groupID {
+		t.Fatalf("unexpected group %+v", got.Group)
+	}
+	if _, err := groupService.GetGroup(ctx, outsider.ID, groupID); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("expected outsider get to fail with sql.ErrNoRows, got %v", err)
+	}
+
+	renamed := "renamed"
+	if _, err := groupService.UpdateGroup(ctx, member.ID, groupID, &renamed, nil); !errors.Is(err, ErrGroupCreatorOnly) {
+		t.Fatalf("expected member update to fail with ErrGroupCreatorOnly, got %v", err)
+	}
+	if _, err := groupService.AddGroupMember(ctx, member.ID, groupID, outsider.ID); !errors.Is(err, ErrGroupCreatorOnly) {
+		t.Fatalf("expected member add to fail with ErrGroupCreatorOnly, got %v", err)
+	}
+	if _, err := groupService.AddGroupMember(ctx, outsider.ID, groupID, outsider.ID); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("expected outsider add to fail with sql.ErrNoRows, got %v", err)
+	}
+	if _, err := groupService.RemoveGroupMember(ctx, owner.ID, groupID, owner.ID); err == nil {
+		t.Fatalf("expected removing the creator to fail")
+	}
+
+	removed, err := groupService.RemoveGroupMember(ctx, owner.ID, groupID, member.ID)
+	if err != nil {
+		t.Fatalf("RemoveGroupMember() error = %v", err)
+	}
+	if len(removed.Members) != 1 || removed.Members[0].ID != owner.ID {
+		t.Fatalf("expected only creator after removal, got %+v", removed.Members)
+	}
+	if _, err := groupService.RemoveGroupMember(ctx, owner.ID, groupID, member.ID); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("expected removing a non-member to fail with sql.ErrNoRows, got %v", err)
+	}
+}
+
+func TestCreateGroupMessage_ExtractsHashtags(t *testing.T) {
+	services := setupTestServices(t)
+	ctx := context.Background()
+	groupService := NewGroupService(services.store)
+	owner := mustCreateUser(t, services.store, "group-tag-owner")
+
+	group, err := groupService.CreateGroup(ctx, owner.ID, "team", "")
+	if err != nil {
+		t.Fatalf("CreateGroup() error = %v", err)
+	}
+	msg, err := groupService.CreateGroupMessage(ctx, owner.ID, group.Group.ID, "  standup notes #daily #work/plan `#notatag`  ", []string{"daily", "manual"})
+	if err != nil {
+		t.Fatalf("CreateGroupMessage() error = %v", err)
+	}
+	if msg.Message.Content != "standup notes #daily #work/plan `#notatag`" {
+		t.Fatalf("expected trimmed content, got %q", msg.Message.Content)
+	}
+	want := []string{"daily", "manual", "work/plan"}
+	if !slices.Equal(sortedCopy(msg.Message.Tags), want) {
+		t.Fatalf("expected merged tags %v, got %v", want, msg.Message.Tags)
+	}
+
+	groupTags, err := groupService.ListGroupTags(ctx, owner.ID, group.Group.ID)
+	if err != nil {
+		t.Fatalf("ListGroupTags() error = %v", err)
+	}
+	if !slices.Equal(sortedCopy(groupTags), want) {
+		t.Fatalf("expected group tags %v, got %v", want, groupTags)
+	}
+}
+
+func sortedCopy(values []string) []string {
+	out := slices.Clone(values)
+	slices.Sort(out)
+	return out
+}
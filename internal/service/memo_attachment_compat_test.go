@@ -2,6 +2,7 @@ package service
 
 import (
 	"context"
+	"errors"
 	"testing"
 
 	"github.com/shinyes/keer/internal/models"
@@ -152,3 +153,57 @@ func TestUpdateMemo_AttachmentResolutionFailureIsAtomic(t *testing.T) {
 		t.Fatalf("expected memo content unchanged after failed update, got %q", got.Content)
 	}
 }
+
+func TestCreateMemo_RejectsTooManyOrForeignAttachments(t *testing.T) {
+	services := setupTestServices(t)
+	ctx := context.Background()
+	owner := mustCreateUser(t, services.store, "memo-attach-limit")
+	other := mustCreateUser(t, services.store, "memo-attach-other")
+	services.memoService.SetMaxAttachmentsPerMemo(2)
+
+	names := make([]string, 0, 2)
+	for i, creatorID := range []int64{owner.ID, other.ID} {
+		attachment, err := services.store.CreateAttachment(
+			ctx,
+			creatorID,
+			"file.txt",
+			"",
+			"text/plain",
+			8,
+			"memo-attach-limit-hash-"+models.Int64ToString(int64(i)),
+			"LOCAL",
+			"attachments/test/file.txt",
+		)
+		if err != nil {
+			t.Fatalf("CreateAttachment() error = %v", err)
+		}
+		names = append(names, "attachments/"+models.Int64ToString(attachment.ID))
+	}
+
+	_, err := services.memoService.CreateMemo(ctx, owner.ID, CreateMemoInput{
+		Content:         "too many",
+		AttachmentNames: []string{names[0], names[0], names[0]},
+	})
+	if !errors.Is(err, ErrTooManyAttachments) {
+		t.Fatalf("expected ErrTooManyAttachments, got %v", err)
+	}
+
+	_, err = services.memoService.CreateMemo(ctx, owner.ID, CreateMemoInput{
+		Content:         "foreign",
+		AttachmentNames: names,
+	})
+	if err == nil || err.Error() != "attachment "+names[1][len("attachments/"):]+" not found" {
+		t.Fatalf("expected foreign attachment to be rejected, got %v", err)
+	}
+
+	created, err := services.memoService.CreateMemo(ctx, owner.ID, CreateMemoInput{
+		Content:         "owned",
+		AttachmentNames: names[:1],
+	})
+	if err != nil {
+		t.Fatalf("CreateMemo() error = %v", err)
+	}
+	if len(created.Attachments) != 1 {
+		t.Fatalf("expected one attachment, got %+v", created.Attachments)
+	}
+}
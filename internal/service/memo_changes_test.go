@@ -5,6 +5,9 @@ import (
 	"fmt"
 	"testing"
 	"time"
+
+	"github.com/shinyes/keer/internal/models"
+	"github.com/shinyes/keer/internal/store"
 )
 
 func TestListMemoChanges_IncludesCreateAndDeleteEvents(t *testing.T) {
@@ -160,3 +163,366 @@ func containsString(items []string, target string) bool {
 	}
 	return false
 }
+
+func TestBatchSetMemoVisibility_EmitsRevokedEvents(t *testing.T) {
+	services := setupTestServices(t)
+	ctx := context.Background()
+
+	owner := mustCreateUser(t, services.store, "owner-batch-visibility")
+	collaborator := mustCreateUser(t, services.store, "collab-batch-visibility")
+	outsider := mustCreateUser(t, services.store, "outsider-batch-visibility")
+
+	collaboratorTag := fmt.Sprintf("collab/%d", collaborator.ID)
+	shared, err := services.memoService.CreateMemo(ctx, owner.ID, CreateMemoInput{
+		Content:    "public shared memo",
+		Visibility: "PUBLIC",
+		Tags:       []string{collaboratorTag, "journal"},
+	})
+	if err != nil {
+		t.Fatalf("CreateMemo(shared) error = %v", err)
+	}
+	other, err := services.memoService.CreateMemo(ctx, owner.ID, CreateMemoInput{
+		Content:    "public other memo",
+		Visibility: "PROTECTED",
+		Tags:       []string{"work"},
+	})
+	if err != nil {
+		t.Fatalf("CreateMemo(other) error = %v", err)
+	}
+	if _, err := services.memoService.CreateMemo(ctx, outsider.ID, CreateMemoInput{
+		Content:    "outsider memo",
+		Visibility: "PUBLIC",
+	}); err != nil {
+		t.Fatalf("CreateMemo(outsider) error = %v", err)
+	}
+
+	if _, err := services.memoService.BatchSetMemoVisibility(ctx, owner.ID, "", "PRIVATE", false); err == nil {
+		t.Fatalf("expected confirm to be required when no filter is given")
+	}
+
+	beforeBatch := time.Now().UTC().Add(-time.Second)
+	changed, err := services.memoService.BatchSetMemoVisibility(ctx, owner.ID, `"journal" in tags`, "PRIVATE", false)
+	if err != nil {
+		t.Fatalf("BatchSetMemoVisibility(filter) error = %v", err)
+	}
+	if changed != 1 {
+		t.Fatalf("expected 1 memo changed by filter, got %d", changed)
+	}
+
+	outsiderChanges, err := services.memoService.ListMemoChanges(ctx, outsider.ID, nil, "", beforeBatch, time.Now().UTC())
+	if err != nil {
+		t.Fatalf("ListMemoChanges() outsider error = %v", err)
+	}
+	if !containsString(outsiderChanges.DeletedMemoNames, shared.Memo.Name()) {
+		t.Fatalf("expected outsider to lose access to %q, got %v", shared.Memo.Name(), outsiderChanges.DeletedMemoNames)
+	}
+	collaboratorChanges, err := services.memoService.ListMemoChanges(ctx, collaborator.ID, nil, "", beforeBatch, time.Now().UTC())
+	if err != nil {
+		t.Fatalf("ListMemoChanges() collaborator error = %v", err)
+	}
+	if containsString(collaboratorChanges.DeletedMemoNames, shared.Memo.Name()) {
+		t.Fatalf("expected collaborator to keep access to %q", shared.Memo.Name())
+	}
+	events, err := services.memoService.ListMemoEvents(ctx, owner.ID, shared.Memo.ID)
+	if err != nil {
+		t.Fatalf("ListMemoEvents() error = %v", err)
+	}
+	if len(events) != 1 || !events[0].Broadcast || len(events[0].RecipientIDs) != 0 {
+		t.Fatalf("expected a single broadcast revocation without per-user recipients, got %+v", events)
+	}
+
+	changed, err = services.memoService.BatchSetMemoVisibility(ctx, owner.ID, "", "PRIVATE", true)
+	if err != nil {
+		t.Fatalf("BatchSetMemoVisibility(all) error = %v", err)
+	}
+	if changed != 1 {
+		t.Fatalf("expected only the remaining owned memo to change, got %d", changed)
+	}
+	reloaded, err := services.store.GetMemoByID(ctx, other.Memo.ID)
+	if err != nil {
+		t.Fatalf("GetMemoByID() error = %v", err)
+	}
+	if reloaded.Visibility != "PRIVATE" {
+		t.Fatalf("expected memo to become PRIVATE, got %s", reloaded.Visibility)
+	}
+}
+
+func TestListMemoChanges_ArchiveRemovesMemoFromNormalSync(t *testing.T) {
+	services := setupTestServices(t)
+	ctx := context.Background()
+
+	owner := mustCreateUser(t, services.store, "owner-archive-sync")
+	collaborator := mustCreateUser(t, services.store, "collab-archive-sync")
+
+	collaboratorTag := fmt.Sprintf("collab/%d", collaborator.ID)
+	created, err := services.memoService.CreateMemo(ctx, owner.ID, CreateMemoInput{
+		Content:    "archived shared memo",
+		Visibility: "PRIVATE",
+		Tags:       []string{collaboratorTag},
+	})
+	if err != nil {
+		t.Fatalf("CreateMemo() error = %v", err)
+	}
+
+	normal := models.MemoStateNormal
+	archived := models.MemoStateArchived
+	beforeArchive := time.Now().UTC().Add(-time.Second)
+	if _, err := services.memoService.UpdateMemo(ctx, owner.ID, created.Memo.ID, UpdateMemoInput{State: &archived}); err != nil {
+		t.Fatalf("UpdateMemo(archive) error = %v", err)
+	}
+
+	normalChanges, err := services.memoService.ListMemoChanges(ctx, collaborator.ID, &normal, "", beforeArchive, time.Now().UTC())
+	if err != nil {
+		t.Fatalf("ListMemoChanges(NORMAL) error = %v", err)
+	}
+	if !containsString(normalChanges.DeletedMemoNames, created.Memo.Name()) {
+		t.Fatalf("expected archived memo %q to leave NORMAL sync, got %v", created.Memo.Name(), normalChanges.DeletedMemoNames)
+	}
+
+	allChanges, err := services.memoService.ListMemoChanges(ctx, collaborator.ID, nil, "", beforeArchive, time.Now().UTC())
+	if err != nil {
+		t.Fatalf("ListMemoChanges(all states) error = %v", err)
+	}
+	if containsString(allChanges.DeletedMemoNames, created.Memo.Name()) {
+		t.Fatalf("expected archived memo to stay in unfiltered sync")
+	}
+	if len(allChanges.Memos) != 1 || allChanges.Memos[0].Memo.State != models.MemoStateArchived {
+		t.Fatalf("expected archived memo in changed memos, got %+v", allChanges.Memos)
+	}
+
+	beforeRestore := time.Now().UTC().Add(-time.Second)
+	if _, err := services.memoService.UpdateMemo(ctx, owner.ID, created.Memo.ID, UpdateMemoInput{State: &normal}); err != nil {
+		t.Fatalf("UpdateMemo(restore) error = %v", err)
+	}
+	archivedChanges, err := services.memoService.ListMemoChanges(ctx, owner.ID, &archived, "", beforeRestore, time.Now().UTC())
+	if err != nil {
+		t.Fatalf("ListMemoChanges(ARCHIVED) error = %v", err)
+	}
+	if !containsString(archivedChanges.DeletedMemoNames, created.Memo.Name()) {
+		t.Fatalf("expected restored memo %q to leave ARCHIVED sync, got %v", created.Memo.Name(), archivedChanges.DeletedMemoNames)
+	}
+}
+
+func TestPurgeArchivedMemos_DeletesExpiredAndEmitsEvents(t *testing.T) {
+	services := setupTestServices(t)
+	ctx := context.Background()
+
+	owner := mustCreateUser(t, services.store, "owner-purge")
+	collaborator := mustCreateUser(t, services.store, "collab-purge")
+	archivedState := models.MemoStateArchived
+
+	createMemo := func(content string, tags []string, archive bool) MemoWithAttachments {
+		created, err := services.memoService.CreateMemo(ctx, owner.ID, CreateMemoInput{
+			Content:    content,
+			Visibility: models.VisibilityPrivate,
+			Tags:       tags,
+		})
+		if err != nil {
+			t.Fatalf("CreateMemo(%s) error = %v", content, err)
+		}
+		if archive {
+			if _, err := services.memoService.UpdateMemo(ctx, owner.ID, created.Memo.ID, UpdateMemoInput{State: &archivedState}); err != nil {
+				t.Fatalf("UpdateMemo(archive %s) error = %v", content, err)
+			}
+		}
+		return created
+	}
+	expired := createMemo("old archived", []string{fmt.Sprintf("collab/%d", collaborator.ID)}, true)
+	recent := createMemo("recent archived", nil, true)
+	normal := createMemo("old normal", nil, false)
+
+	old := time.Now().UTC().AddDate(0, 0, -40).Format(time.RFC3339Nano)
+	for _, id := range []int64{expired.Memo.ID, normal.Memo.ID} {
+		if _, err := services.store.DB().ExecContext(ctx, `UPDATE memos SET update_time = ? WHERE id = ?`, old, id); err != nil {
+			t.Fatalf("backdate memo %d error = %v", id, err)
+		}
+	}
+
+	if deleted, err := services.memoService.PurgeArchivedMemos(ctx, 0); err != nil || deleted != 0 {
+		t.Fatalf("expected zero retention to skip purge, deleted=%d err=%v", deleted, err)
+	}
+
+	beforePurge := time.Now().UTC().Add(-time.Second)
+	deleted, err := services.memoService.PurgeArchivedMemos(ctx, 30*24*time.Hour)
+	if err != nil {
+		t.Fatalf("PurgeArchivedMemos() error = %v", err)
+	}
+	if deleted != 1 {
+		t.Fatalf("expected 1 purged memo, got %d", deleted)
+	}
+	if _, err := services.store.GetMemoByID(ctx, expired.Memo.ID); err == nil {
+		t.Fatalf("expected expired archived memo to be deleted")
+	}
+	for _, kept := range []MemoWithAttachments{recent, normal} {
+		if _, err := services.store.GetMemoByID(ctx, kept.Memo.ID); err != nil {
+			t.Fatalf("expected memo %d to be kept, got %v", kept.Memo.ID, err)
+		}
+	}
+
+	for _, viewer := range []models.User{owner, collaborator} {
+		changes, err := services.memoService.ListMemoChanges(ctx, viewer.ID, &archivedState, "", beforePurge, time.Now().UTC())
+		if err != nil {
+			t.Fatalf("ListMemoChanges() error = %v", err)
+		}
+		if !containsString(changes.DeletedMemoNames, expired.Memo.Name()) {
+			t.Fatalf("expected user %d to receive purged memo %q, got %v", viewer.ID, expired.Memo.Name(), changes.DeletedMemoNames)
+		}
+	}
+}
+
+func TestDeleteArchivedMemosOlderThan_BatchesAndComparesTimesNumerically(t *testing.T) {
+	services := setupTestServices(t)
+	ctx := context.Background()
+	owner := mustCreateUser(t, services.store, "owner-purge-batch")
+
+	// 超过单批数量，确认分批删除会处理完所有过期备忘录
+	memos := make([]store.MemoCreate, 0, 205)
+	for i := 0; i < 205; i++ {
+		memos = append(memos, store.MemoCreate{
+			Content:    fmt.Sprintf("archived %d", i),
+			Visibility: models.VisibilityPrivate,
+			State:      models.MemoStateArchived,
+			CreateTime: time.Now().UTC(),
+		})
+	}
+	ids, err := services.store.CreateMemosBatch(ctx, owner.ID, memos)
+	if err != nil {
+		t.Fatalf("CreateMemosBatch() error = %v", err)
+	}
+	// 秒级时间与带小数的 cutoff 按字符串比较会把同一秒内更早的时间判为更晚
+	if _, err := services.store.DB().ExecContext(ctx, `UPDATE memos SET update_time = ? WHERE id <> ?`, "2024-05-01T10:00:00Z", ids[0]); err != nil {
+		t.Fatalf("backdate memos error = %v", err)
+	}
+	if _, err := services.store.DB().ExecContext(ctx, `UPDATE memos SET update_time = ? WHERE id = ?`, "2024-05-01T10:00:01.000Z", ids[0]); err != nil {
+		t.Fatalf("backdate kept memo error = %v", err)
+	}
+
+	cutoff := time.Date(2024, 5, 1, 10, 0, 0, 500_000_000, time.UTC)
+	deleted, err := services.store.DeleteArchivedMemosOlderThan(ctx, cutoff)
+	if err != nil {
+		t.Fatalf("DeleteArchivedMemosOlderThan() error = %v", err)
+	}
+	if deleted != 204 {
+		t.Fatalf("expected 204 purged memos, got %d", deleted)
+	}
+	if _, err := services.store.GetMemoByID(ctx, ids[0]); err != nil {
+		t.Fatalf("expected memo updated after cutoff to be kept, got %v", err)
+	}
+}
+
+func TestListMemoChanges_MillisecondBoundaryReturnedOnce(t *testing.T) {
+	services := setupTestServices(t)
+	ctx := context.Background()
+	services.store.SetMillisecondTimestamps(true)
+
+	owner := mustCreateUser(t, services.store, "owner-boundary")
+	created, err := services.memoService.CreateMemo(ctx, owner.ID, CreateMemoInput{
+		Content:    "boundary memo",
+		Visibility: models.VisibilityPrivate,
+	})
+	if err != nil {
+		t.Fatalf("CreateMemo() error = %v", err)
+	}
+
+	countInWindow := func(since time.Time, anchor time.Time) int {
+		t.Helper()
+		changes, err := services.memoService.ListMemoChanges(ctx, owner.ID, nil, "", since, anchor)
+		if err != nil {
+			t.Fatalf("ListMemoChanges() error = %v", err)
+		}
+		count := 0
+		for _, memo := range changes.Memos {
+			if memo.Memo.ID == created.Memo.ID {
+				count++
+			}
+		}
+		return count
+	}
+
+	content := "boundary memo updated"
+	updated, err := services.memoService.UpdateMemo(ctx, owner.ID, created.Memo.ID, UpdateMemoInput{Content: &content})
+	if err != nil {
+		t.Fatalf("UpdateMemo() error = %v", err)
+	}
+	updateTime := updated.Memo.UpdateTime
+	if updateTime.Nanosecond()%int(time.Millisecond) != 0 {
+		t.Fatalf("expected update time truncated to milliseconds, got %s", updateTime.Format(time.RFC3339Nano))
+	}
+
+	// 旧数据使用变长的 RFC3339Nano（".12Z" 按字符串比较大于 ".1201Z"），启动时会被改写为定长毫秒格式。
+	if _, err := services.store.DB().ExecContext(ctx, `UPDATE memos SET update_time = ? WHERE id = ?`, "2024-01-02T03:04:05.12Z", created.Memo.ID); err != nil {
+		t.Fatalf("seed legacy update_time error = %v", err)
+	}
+	if err := services.store.NormalizeMemoTimestamps(ctx); err != nil {
+		t.Fatalf("NormalizeMemoTimestamps() error = %v", err)
+	}
+	legacyTime := time.Date(2024, 1, 2, 3, 4, 5, 120*int(time.Millisecond), time.UTC)
+
+	cases := []struct {
+		name     string
+		boundary time.Time
+		anchor   time.Time
+	}{
+		{name: "anchor equals update time", boundary: legacyTime, anchor: legacyTime},
+		{name: "anchor within same millisecond", boundary: legacyTime, anchor: legacyTime.Add(100 * time.Microsecond)},
+		{name: "anchor at end of millisecond", boundary: legacyTime, anchor: legacyTime.Add(999_999 * time.Nanosecond)},
+	}
+	for _, tc := range cases {
+		first := countInWindow(tc.boundary.Add(-time.Second), tc.anchor)
+		second := countInWindow(tc.anchor, tc.boundary.Add(time.Second))
+		if first+second != 1 || first != 1 {
+			t.Fatalf("%s: expected memo exactly once in first window, got first=%d second=%d", tc.name, first, second)
+		}
+	}
+	if got := countInWindow(legacyTime.Add(-time.Second), legacyTime.Add(-time.Microsecond)); got != 0 {
+		t.Fatalf("expected memo outside window ending before its millisecond, got %d", got)
+	}
+}
+
+func TestSubscribeMemoChanges_WakesOnlyAffectedViewers(t *testing.T) {
+	services := setupTestServices(t)
+	ctx := context.Background()
+
+	owner := mustCreateUser(t, services.store, "watch-owner")
+	collaborator := mustCreateUser(t, services.store, "watch-collab")
+	outsider := mustCreateUser(t, services.store, "watch-outsider")
+
+	ownerChanged, cancelOwner := services.memoService.SubscribeMemoChanges(owner.ID)
+	defer cancelOwner()
+	collaboratorChanged, cancelCollaborator := services.memoService.SubscribeMemoChanges(collaborator.ID)
+	defer cancelCollaborator()
+	outsiderChanged, cancelOutsider := services.memoService.SubscribeMemoChanges(outsider.ID)
+	defer cancelOutsider()
+
+	woken := func(ch <-chan struct{}) bool {
+		select {
+		case <-ch:
+			return true
+		default:
+			return false
+		}
+	}
+
+	private, err := services.memoService.CreateMemo(ctx, owner.ID, CreateMemoInput{
+		Content:    "private watch memo",
+		Visibility: models.VisibilityPrivate,
+		Tags:       []string{fmt.Sprintf("collab/%d", collaborator.ID)},
+	})
+	if err != nil {
+		t.Fatalf("CreateMemo() error = %v", err)
+	}
+	if !woken(ownerChanged) || !woken(collaboratorChanged) {
+		t.Fatalf("expected creator and collaborator to be woken by private memo")
+	}
+	if woken(outsiderChanged) {
+		t.Fatalf("expected outsider not to be woken by private memo")
+	}
+
+	public := models.VisibilityPublic
+	if _, err := services.memoService.UpdateMemo(ctx, owner.ID, private.Memo.ID, UpdateMemoInput{Visibility: &public}); err != nil {
+		t.Fatalf("UpdateMemo() error = %v", err)
+	}
+	if !woken(ownerChanged) || !woken(collaboratorChanged) || !woken(outsiderChanged) {
+		t.Fatalf("expected every viewer to be woken when memo becomes public")
+	}
+}
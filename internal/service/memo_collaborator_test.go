@@ -3,10 +3,16 @@ package service
 import (
 	"context"
 	"database/sql"
+	"encoding/base64"
+	"errors"
 	"fmt"
+	"path/filepath"
+	"slices"
 	"testing"
+	"time"
 
 	"github.com/shinyes/keer/internal/models"
+	"github.com/shinyes/keer/internal/storage"
 )
 
 func TestCollaboratorCanManageMemo(t *testing.T) {
@@ -84,3 +90,185 @@ func TestCollaboratorCanSeePrivateMemo(t *testing.T) {
 		t.Fatalf("expected outsider cannot see private collab memo, got %d", len(outsiderView))
 	}
 }
+
+func TestUpdateMemoCreateTime_CreatorOnly(t *testing.T) {
+	t.Parallel()
+
+	services := setupTestServices(t)
+	ctx := context.Background()
+	owner := mustCreateUser(t, services.store, "memo-backdate-owner")
+	collaborator := mustCreateUser(t, services.store, "memo-backdate-editor")
+
+	created, err := services.memoService.CreateMemo(ctx, owner.ID, CreateMemoInput{
+		Content:    "imported note",
+		Visibility: models.VisibilityPrivate,
+		Tags:       []string{fmt.Sprintf("collab/%d", collaborator.ID)},
+	})
+	if err != nil {
+		t.Fatalf("CreateMemo() error = %v", err)
+	}
+
+	backdated := time.Date(2019, 5, 4, 3, 2, 1, 0, time.UTC)
+	if _, err := services.memoService.UpdateMemo(ctx, collaborator.ID, created.Memo.ID, UpdateMemoInput{CreateTime: &backdated}); err == nil {
+		t.Fatalf("expected collaborator createTime change to be rejected")
+	}
+	future := time.Now().Add(48 * time.Hour)
+	if _, err := services.memoService.UpdateMemo(ctx, owner.ID, created.Memo.ID, UpdateMemoInput{CreateTime: &future}); err == nil {
+		t.Fatalf("expected far-future createTime to be rejected")
+	}
+
+	updated, err := services.memoService.UpdateMemo(ctx, owner.ID, created.Memo.ID, UpdateMemoInput{CreateTime: &backdated})
+	if err != nil {
+		t.Fatalf("UpdateMemo() createTime error = %v", err)
+	}
+	if !updated.Memo.CreateTime.Equal(backdated) {
+		t.Fatalf("expected createTime %s, got %s", backdated, updated.Memo.CreateTime)
+	}
+	var displayTime string
+	if err := services.store.DB().QueryRowContext(ctx, `SELECT display_time FROM memos WHERE id = ?`, created.Memo.ID).Scan(&displayTime); err != nil {
+		t.Fatalf("query display_time error = %v", err)
+	}
+	if parsed, err := time.Parse(time.RFC3339Nano, displayTime); err != nil || !parsed.Equal(backdated) {
+		t.Fatalf("expected display_time re-derived from createTime, got %q", displayTime)
+	}
+}
+
+func TestCollaboratorAttachments(t *testing.T) {
+	t.Parallel()
+
+	services := setupTestServices(t)
+	ctx := context.Background()
+	localStore, err := storage.NewLocalStore(filepath.Join(t.TempDir(), "uploads"))
+	if err != nil {
+		t.Fatalf("NewLocalStore() error = %v", err)
+	}
+	attachmentService := NewAttachmentService(services.store, localStore, 0)
+	owner := mustCreateUser(t, services.store, "memo-collab-attach-owner")
+	collaborator := mustCreateUser(t, services.store, "memo-collab-attach-editor")
+	outsider := mustCreateUser(t, services.store, "memo-collab-attach-outsider")
+
+	createAttachment := func(userID int64, name string) models.Attachment {
+		attachment, err := attachmentService.CreateAttachment(ctx, userID, CreateAttachmentInput{
+			Filename: name,
+			Type:     "text/plain",
+			Content:  base64.StdEncoding.EncodeToString([]byte(name)),
+		})
+		if err != nil {
+			t.Fatalf("CreateAttachment(%s) error = %v", name, err)
+		}
+		return attachment
+	}
+	ownerFile := createAttachment(owner.ID, "owner.txt")
+	collabFile := createAttachment(collaborator.ID, "collab.txt")
+	laterFile := createAttachment(collaborator.ID, "later.txt")
+
+	created, err := services.memoService.CreateMemo(ctx, owner.ID, CreateMemoInput{
+		Content:         "shared memo",
+		Visibility:      models.VisibilityPrivate,
+		Tags:            []string{fmt.Sprintf("collab/%d", collaborator.ID)},
+		AttachmentNames: []string{"attachments/" + models.Int64ToString(ownerFile.ID)},
+	})
+	if err != nil {
+		t.Fatalf("CreateMemo() error = %v", err)
+	}
+
+	names := []string{"attachments/" + models.Int64ToString(ownerFile.ID), "attachments/" + models.Int64ToString(collabFile.ID)}
+	if _, err := services.memoService.UpdateMemo(ctx, collaborator.ID, created.Memo.ID, UpdateMemoInput{AttachmentNames: &names}); err != nil {
+		t.Fatalf("UpdateMemo(add own attachment) error = %v", err)
+	}
+
+	for _, tc := range []struct {
+		user       models.User
+		attachment models.Attachment
+		want       bool
+	}{
+		{owner, collabFile, true},
+		{collaborator, ownerFile, true},
+		{outsider, collabFile, false},
+		{owner, laterFile, false},
+	} {
+		got, err := attachmentService.CanViewAttachment(ctx, tc.attachment, tc.user.ID)
+		if err != nil || got != tc.want {
+			t.Fatalf("CanViewAttachment(user=%s, file=%s) = %v, %v; want %v", tc.user.Username, tc.attachment.Filename, got, err, tc.want)
+		}
+	}
+
+	services.memoService.SetRestrictCollaboratorAttachments(true)
+	withLater := append(append([]string{}, names...), "attachments/"+models.Int64ToString(laterFile.ID))
+	if _, err := services.memoService.UpdateMemo(ctx, collaborator.ID, created.Memo.ID, UpdateMemoInput{AttachmentNames: &withLater}); !errors.Is(err, ErrCollaboratorAttachmentNotAllowed) {
+		t.Fatalf("expected restricted collaborator attachment to be rejected, got %v", err)
+	}
+	content := "still editable"
+	if _, err := services.memoService.UpdateMemo(ctx, collaborator.ID, created.Memo.ID, UpdateMemoInput{Content: &content, AttachmentNames: &names}); err != nil {
+		t.Fatalf("expected existing attachments to be kept under restriction, got %v", err)
+	}
+}
+
+func TestAddAndRemoveCollaborator(t *testing.T) {
+	t.Parallel()
+
+	services := setupTestServices(t)
+	ctx := context.Background()
+	owner := mustCreateUser(t, services.store, "memo-collab-api-owner")
+	collaborator := mustCreateUser(t, services.store, "memo-collab-api-editor")
+	outsider := mustCreateUser(t, services.store, "memo-collab-api-outsider")
+
+	created, err := services.memoService.CreateMemo(ctx, owner.ID, CreateMemoInput{
+		Content:    "owner memo",
+		Visibility: models.VisibilityPrivate,
+		Tags:       []string{"work"},
+	})
+	if err != nil {
+		t.Fatalf("CreateMemo() error = %v", err)
+	}
+	memoID := created.Memo.ID
+	collaboratorTag := fmt.Sprintf("collab/%d", collaborator.ID)
+
+	if _, err := services.memoService.AddCollaborator(ctx, outsider.ID, memoID, collaborator.ID); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("expected outsider to be rejected with sql.ErrNoRows, got %v", err)
+	}
+	if _, err := services.memoService.AddCollaborator(ctx, owner.ID, memoID, owner.ID); !errors.Is(err, ErrInvalidCollaborator) {
+		t.Fatalf("expected creator to be rejected as collaborator, got %v", err)
+	}
+	if _, err := services.memoService.AddCollaborator(ctx, owner.ID, memoID, 999999); !errors.Is(err, ErrCollaboratorNotFound) {
+		t.Fatalf("expected unknown user to be rejected, got %v", err)
+	}
+
+	for range 2 {
+		added, err := services.memoService.AddCollaborator(ctx, owner.ID, memoID, collaborator.ID)
+		if err != nil {
+			t.Fatalf("AddCollaborator() error = %v", err)
+		}
+		if fmt.Sprint(added.Memo.Payload.Tags) != fmt.Sprint([]string{collaboratorTag, "work"}) {
+			t.Fatalf("expected collaborator tag once, got %v", added.Memo.Payload.Tags)
+		}
+	}
+	if _, err := services.memoService.GetMemo(ctx, collaborator.ID, memoID); err != nil {
+		t.Fatalf("expected collaborator to see memo, got %v", err)
+	}
+
+	removed, err := services.memoService.RemoveCollaborator(ctx, owner.ID, memoID, collaborator.ID)
+	if err != nil {
+		t.Fatalf("RemoveCollaborator() error = %v", err)
+	}
+	if fmt.Sprint(removed.Memo.Payload.Tags) != fmt.Sprint([]string{"work"}) {
+		t.Fatalf("expected collaborator tag removed, got %v", removed.Memo.Payload.Tags)
+	}
+	if _, err := services.memoService.GetMemo(ctx, collaborator.ID, memoID); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("expected removed collaborator to lose access, got %v", err)
+	}
+
+	events, err := services.memoService.ListMemoEvents(ctx, owner.ID, memoID)
+	if err != nil {
+		t.Fatalf("ListMemoEvents() error = %v", err)
+	}
+	revoked := false
+	for _, event := range events {
+		if event.EventType == "VISIBILITY_REVOKED" && slices.Contains(event.RecipientIDs, collaborator.ID) {
+			revoked = true
+		}
+	}
+	if !revoked {
+		t.Fatalf("expected VISIBILITY_REVOKED event for removed collaborator, got %+v", events)
+	}
+}
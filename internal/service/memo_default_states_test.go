@@ -0,0 +1,70 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/shinyes/keer/internal/models"
+)
+
+func TestListMemos_DefaultMemoStatesIncludeArchived(t *testing.T) {
+	t.Parallel()
+
+	services := setupTestServices(t)
+	ctx := context.Background()
+	owner := mustCreateUser(t, services.store, "default-states-owner")
+
+	if _, err := services.memoService.CreateMemo(ctx, owner.ID, CreateMemoInput{Content: "normal memo", Visibility: models.VisibilityPrivate}); err != nil {
+		t.Fatalf("CreateMemo(normal) error = %v", err)
+	}
+	archivedMemo, err := services.memoService.CreateMemo(ctx, owner.ID, CreateMemoInput{Content: "archived memo", Visibility: models.VisibilityPrivate})
+	if err != nil {
+		t.Fatalf("CreateMemo(archived) error = %v", err)
+	}
+	archived := models.MemoStateArchived
+	if _, err := services.memoService.UpdateMemo(ctx, owner.ID, archivedMemo.Memo.ID, UpdateMemoInput{State: &archived}); err != nil {
+		t.Fatalf("UpdateMemo(archive) error = %v", err)
+	}
+
+	filter := fmt.Sprintf("creator_id == %d", owner.ID)
+	listed, _, err := services.memoService.ListMemos(ctx, owner.ID, nil, filter, 50, "")
+	if err != nil {
+		t.Fatalf("ListMemos(default) error = %v", err)
+	}
+	if len(listed) != 1 {
+		t.Fatalf("expected only NORMAL memo by default, got %d", len(listed))
+	}
+
+	if err := services.memoService.SetDefaultMemoStates([]models.MemoState{models.MemoStateNormal, models.MemoStateArchived}); err != nil {
+		t.Fatalf("SetDefaultMemoStates() error = %v", err)
+	}
+	listed, _, err = services.memoService.ListMemos(ctx, owner.ID, nil, filter, 50, "")
+	if err != nil {
+		t.Fatalf("ListMemos(normal+archived) error = %v", err)
+	}
+	if len(listed) != 2 {
+		t.Fatalf("expected NORMAL and ARCHIVED memos, got %d", len(listed))
+	}
+
+	normal := models.MemoStateNormal
+	listed, _, err = services.memoService.ListMemos(ctx, owner.ID, &normal, filter, 50, "")
+	if err != nil {
+		t.Fatalf("ListMemos(state=NORMAL) error = %v", err)
+	}
+	if len(listed) != 1 || listed[0].Memo.State != models.MemoStateNormal {
+		t.Fatalf("expected explicit state to override defaults, got %+v", listed)
+	}
+
+	listed, _, err = services.memoService.ListMemos(ctx, owner.ID, nil, filter+` && state == "ARCHIVED"`, 50, "")
+	if err != nil {
+		t.Fatalf("ListMemos(filter state) error = %v", err)
+	}
+	if len(listed) != 1 || listed[0].Memo.State != models.MemoStateArchived {
+		t.Fatalf("expected filter state to narrow defaults, got %+v", listed)
+	}
+
+	if err := services.memoService.SetDefaultMemoStates([]models.MemoState{"DELETED"}); err == nil {
+		t.Fatalf("expected invalid default state to be rejected")
+	}
+}
@@ -0,0 +1,109 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/shinyes/keer/internal/models"
+)
+
+func TestShareMemoToGroupAndResync(t *testing.T) {
+	services := setupTestServices(t)
+	ctx := context.Background()
+	owner := mustCreateUser(t, services.store, "share-owner")
+	alice := mustCreateUser(t, services.store, "share-alice")
+	bob := mustCreateUser(t, services.store, "share-bob")
+	carol := mustCreateUser(t, services.store, "share-carol")
+	outsider := mustCreateUser(t, services.store, "share-outsider")
+
+	group, err := services.store.CreateGroup(ctx, owner.ID, "team", "")
+	if err != nil {
+		t.Fatalf("CreateGroup() error = %v", err)
+	}
+	for _, member := range []models.User{alice, bob} {
+		if err := services.store.AddGroupMember(ctx, group.ID, member.ID); err != nil {
+			t.Fatalf("AddGroupMember() error = %v", err)
+		}
+	}
+
+	carolTag := fmt.Sprintf("collab/%d", carol.ID)
+	created, err := services.memoService.CreateMemo(ctx, owner.ID, CreateMemoInput{
+		Content:    "team memo",
+		Visibility: models.VisibilityPrivate,
+		Tags:       []string{"work", carolTag},
+	})
+	if err != nil {
+		t.Fatalf("CreateMemo() error = %v", err)
+	}
+	memoID := created.Memo.ID
+
+	if _, err := services.memoService.ShareMemoToGroup(ctx, outsider.ID, memoID, group.ID); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("expected outsider share to be rejected with sql.ErrNoRows, got %v", err)
+	}
+
+	shared, err := services.memoService.ShareMemoToGroup(ctx, owner.ID, memoID, group.ID)
+	if err != nil {
+		t.Fatalf("ShareMemoToGroup() error = %v", err)
+	}
+	aliceTag := fmt.Sprintf("collab/%d", alice.ID)
+	bobTag := fmt.Sprintf("collab/%d", bob.ID)
+	ownerTag := fmt.Sprintf("collab/%d", owner.ID)
+	for _, tag := range []string{"work", carolTag, aliceTag, bobTag} {
+		if !slices.Contains(shared.Memo.Payload.Tags, tag) {
+			t.Fatalf("expected tag %q after share, got %v", tag, shared.Memo.Payload.Tags)
+		}
+	}
+	if slices.Contains(shared.Memo.Payload.Tags, ownerTag) {
+		t.Fatalf("expected creator to be skipped, got %v", shared.Memo.Payload.Tags)
+	}
+
+	// bob 离开群组、carol 加入群组后重新同步：bob 的协作者标签被移除，carol 的单独分享保留
+	if err := services.store.RemoveGroupMember(ctx, group.ID, bob.ID); err != nil {
+		t.Fatalf("RemoveGroupMember() error = %v", err)
+	}
+	if err := services.store.AddGroupMember(ctx, group.ID, carol.ID); err != nil {
+		t.Fatalf("AddGroupMember(carol) error = %v", err)
+	}
+	resynced, err := services.memoService.ResyncMemoGroupShare(ctx, alice.ID, memoID, group.ID)
+	if err != nil {
+		t.Fatalf("ResyncMemoGroupShare() error = %v", err)
+	}
+	if slices.Contains(resynced.Memo.Payload.Tags, bobTag) {
+		t.Fatalf("expected bob's collaborator tag removed after resync, got %v", resynced.Memo.Payload.Tags)
+	}
+	for _, tag := range []string{"work", carolTag, aliceTag} {
+		if !slices.Contains(resynced.Memo.Payload.Tags, tag) {
+			t.Fatalf("expected tag %q after resync, got %v", tag, resynced.Memo.Payload.Tags)
+		}
+	}
+
+	events, err := services.memoService.ListMemoEvents(ctx, owner.ID, memoID)
+	if err != nil {
+		t.Fatalf("ListMemoEvents() error = %v", err)
+	}
+	revokedForBob := false
+	for _, event := range events {
+		if event.EventType == "VISIBILITY_REVOKED" && slices.Contains(event.RecipientIDs, bob.ID) {
+			revokedForBob = true
+		}
+	}
+	if !revokedForBob {
+		t.Fatalf("expected VISIBILITY_REVOKED event for bob, got %+v", events)
+	}
+
+	// carol 不再是群组成员后，由于她是单独分享的协作者，重新同步也不会移除她
+	if err := services.store.RemoveGroupMember(ctx, group.ID, carol.ID); err != nil {
+		t.Fatalf("RemoveGroupMember(carol) error = %v", err)
+	}
+	resynced, err = services.memoService.ResyncMemoGroupShare(ctx, owner.ID, memoID, group.ID)
+	if err != nil {
+		t.Fatalf("ResyncMemoGroupShare() error = %v", err)
+	}
+	if !slices.Contains(resynced.Memo.Payload.Tags, carolTag) {
+		t.Fatalf("expected individually shared collaborator to be kept, got %v", resynced.Memo.Payload.Tags)
+	}
+}
@@ -0,0 +1,50 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/shinyes/keer/internal/models"
+	"github.com/shinyes/keer/internal/storage"
+)
+
+func TestRejectEmptyMemos(t *testing.T) {
+	t.Parallel()
+
+	services := setupTestServices(t)
+	ctx := context.Background()
+	owner := mustCreateUser(t, services.store, "reject-empty-owner")
+
+	if _, err := services.memoService.CreateMemo(ctx, owner.ID, CreateMemoInput{Content: "  \n\t"}); err != nil {
+		t.Fatalf("expected blank memo to be allowed by default, got %v", err)
+	}
+
+	services.memoService.SetRejectEmptyMemos(true)
+	if _, err := services.memoService.CreateMemo(ctx, owner.ID, CreateMemoInput{Content: "  \n\t"}); !errors.Is(err, ErrEmptyMemo) {
+		t.Fatalf("expected ErrEmptyMemo, got %v", err)
+	}
+	if _, err := services.memoService.CreateMemo(ctx, owner.ID, CreateMemoInput{Content: " ", Tags: []string{"todo"}}); err != nil {
+		t.Fatalf("expected tagged blank memo to be allowed, got %v", err)
+	}
+
+	localStore, err := storage.NewLocalStore(filepath.Join(t.TempDir(), "uploads"))
+	if err != nil {
+		t.Fatalf("NewLocalStore() error = %v", err)
+	}
+	attachment, err := NewAttachmentService(services.store, localStore, 0).CreateAttachment(ctx, owner.ID, CreateAttachmentInput{
+		Filename: "photo.txt",
+		Type:     "text/plain",
+		Content:  base64.StdEncoding.EncodeToString([]byte("photo")),
+	})
+	if err != nil {
+		t.Fatalf("CreateAttachment() error = %v", err)
+	}
+	if _, err := services.memoService.CreateMemo(ctx, owner.ID, CreateMemoInput{
+		AttachmentNames: []string{"attachments/" + models.Int64ToString(attachment.ID)},
+	}); err != nil {
+		t.Fatalf("expected attachment-only memo to be allowed, got %v", err)
+	}
+}
@@ -1,26 +1,139 @@
 package service
 
 import (
+	"bufio"
 	"context"
 	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 	"unicode"
 
+	"github.com/shinyes/keer/internal/db"
 	"github.com/shinyes/keer/internal/models"
 	"github.com/shinyes/keer/internal/store"
 )
 
 type MemoService struct {
-	store *store.SQLStore
+	store                 *store.SQLStore
+	searchTokenizer       string
+	searchScope           store.MemoSearchScope
+	maxAttachmentsPerMemo int
+	defaultMemoStates     []models.MemoState
+	restrictTags          bool
+	contentFilterFTS      bool
+	rejectEmptyMemos      bool
+	// restrictCollaboratorAttachments 为 true 时协作者只能保留或引用创建者的附件，不能添加自己上传的附件。
+	restrictCollaboratorAttachments bool
 }
 
+var (
+	ErrInvalidSearchQuery = errors.New("invalid search query")
+	ErrUnknownTag         = errors.New("unknown tag")
+	ErrInvalidTagName     = errors.New("invalid tag name")
+	ErrEmptyMemo          = errors.New("memo content is empty")
+	// ErrMemoScanLimit 表示过滤条件需要在内存中排序的备忘录超过扫描上限，应缩小过滤范围。
+	ErrMemoScanLimit = errors.New("too many memos match the filter; narrow the filter")
+
+	ErrCollaboratorAttachmentNotAllowed = errors.New("collaborators cannot add their own attachments to this memo")
+	ErrCollaboratorNotFound             = errors.New("collaborator user not found")
+	ErrInvalidCollaborator              = errors.New("memo creator cannot be a collaborator")
+)
+
+const searchReindexBatchSize = 500
+
+// 过滤条件无法完全下推到 SQL 时按游标分批扫描、在内存中过滤；单次请求最多扫描 memoFilterScanLimit 行，
+// 超出时以最后扫描的行作为下一页游标返回，避免一次性加载过多 memo 到内存。
+const (
+	memoFilterScanLimit     = 10000
+	memoFilterScanBatchSize = 500
+)
+
+const memoCreateTimeMaxFutureSkew = 24 * time.Hour
+
 func NewMemoService(s *store.SQLStore) *MemoService {
 	return &MemoService{
-		store: s,
+		store:             s,
+		searchTokenizer:   db.DefaultMemoSearchTokenizer,
+		searchScope:       store.MemoSearchScopeVisible,
+		defaultMemoStates: []models.MemoState{models.MemoStateNormal},
+	}
+}
+
+func (s *MemoService) SetSearchTokenizer(tokenizer string) error {
+	tokenizer = strings.ToLower(strings.TrimSpace(tokenizer))
+	if tokenizer == "" {
+		tokenizer = db.DefaultMemoSearchTokenizer
+	}
+	if !db.IsValidMemoSearchTokenizer(tokenizer) {
+		return fmt.Errorf("unsupported search tokenizer %q", tokenizer)
 	}
+	s.searchTokenizer = tokenizer
+	return nil
+}
+
+// SetMaxAttachmentsPerMemo 限制单条 memo 请求可携带的附件数量，0 表示不限制。
+func (s *MemoService) SetMaxAttachmentsPerMemo(limit int) {
+	if limit < 0 {
+		limit = 0
+	}
+	s.maxAttachmentsPerMemo = limit
+}
+
+// SetDefaultMemoStates 设置列表请求未指定 state 时默认包含的状态，空列表表示只含 NORMAL。
+func (s *MemoService) SetDefaultMemoStates(states []models.MemoState) error {
+	if len(states) == 0 {
+		s.defaultMemoStates = []models.MemoState{models.MemoStateNormal}
+		return nil
+	}
+	for _, state := range states {
+		if !state.IsValid() {
+			return fmt.Errorf("unsupported default memo state %q", state)
+		}
+	}
+	s.defaultMemoStates = append([]models.MemoState{}, states...)
+	return nil
+}
+
+// SetRestrictTagsToExisting 开启后创建、更新与导入 memo 时只能使用创建者已有的标签，
+// 新标签需先通过 CreateTag 显式创建；collab/ 协作标签不受限制。
+func (s *MemoService) SetRestrictTagsToExisting(enabled bool) {
+	s.restrictTags = enabled
+}
+
+// SetRejectEmptyMemos 开启后拒绝创建正文为空白、且没有附件和标签的 memo。
+func (s *MemoService) SetRejectEmptyMemos(enabled bool) {
+	s.rejectEmptyMemos = enabled
+}
+
+// SetRestrictCollaboratorAttachments 控制协作者编辑共享 memo 时能否添加自己上传的附件，默认允许。
+func (s *MemoService) SetRestrictCollaboratorAttachments(restricted bool) {
+	s.restrictCollaboratorAttachments = restricted
+}
+
+// SetContentFilterFTS 开启后过滤条件允许使用 content.contains("...")，通过全文索引预过滤后再在内存中复核。
+func (s *MemoService) SetContentFilterFTS(enabled bool) {
+	s.contentFilterFTS = enabled
+}
+
+func (s *MemoService) SetSearchScope(scope string) error {
+	scope = strings.ToLower(strings.TrimSpace(scope))
+	if scope == "" {
+		s.searchScope = store.MemoSearchScopeVisible
+		return nil
+	}
+	if !store.MemoSearchScope(scope).IsValid() {
+		return fmt.Errorf("unsupported search scope %q", scope)
+	}
+	s.searchScope = store.MemoSearchScope(scope)
+	return nil
 }
 
 type CreateMemoInput struct {
@@ -44,6 +157,10 @@ type UpdateMemoInput struct {
 	Latitude        *float64
 	LongitudeSet    bool
 	Longitude       *float64
+	CreateTime      *time.Time
+	// KeepCollaborators 为 true 时，Tags 中不含 collab/ 标签则保留 memo 现有的协作者。
+	// API 响应不返回 collab/ 标签，客户端回传标签时据此避免误删协作者。
+	KeepCollaborators bool
 }
 
 type MemoWithAttachments struct {
@@ -70,11 +187,17 @@ func (s *MemoService) CreateMemo(ctx context.Context, creatorID int64, input Cre
 	payload := models.MemoPayload{
 		Tags: normalizeMemoTags(input.Tags),
 	}
+	if err := s.checkKnownTags(ctx, creatorID, payload.Tags); err != nil {
+		return MemoWithAttachments{}, err
+	}
 
 	attachmentIDs, err := s.resolveAttachmentIDsFromNames(ctx, creatorID, input.AttachmentNames)
 	if err != nil {
 		return MemoWithAttachments{}, err
 	}
+	if s.rejectEmptyMemos && strings.TrimSpace(content) == "" && len(attachmentIDs) == 0 && len(payload.Tags) == 0 {
+		return MemoWithAttachments{}, ErrEmptyMemo
+	}
 
 	createTime := time.Now().UTC()
 	if input.CreateTime != nil && !input.CreateTime.IsZero() {
@@ -97,15 +220,15 @@ func (s *MemoService) CreateMemo(ctx context.Context, creatorID int64, input Cre
 	if err != nil {
 		return MemoWithAttachments{}, err
 	}
-	attachmentsMap, err := s.store.ListAttachmentsByMemoIDs(ctx, []int64{memo.ID})
+	return s.hydrateMemo(ctx, memo)
+}
+
+func (s *MemoService) GetMemo(ctx context.Context, viewerID int64, memoID int64) (MemoWithAttachments, error) {
+	memo, err := s.store.GetVisibleMemoByID(ctx, viewerID, memoID)
 	if err != nil {
 		return MemoWithAttachments{}, err
 	}
-
-	return MemoWithAttachments{
-		Memo:        memo,
-		Attachments: attachmentsMap[memo.ID],
-	}, nil
+	return s.hydrateMemo(ctx, memo)
 }
 
 func (s *MemoService) UpdateMemo(ctx context.Context, updaterID int64, memoID int64, input UpdateMemoInput) (MemoWithAttachments, error) {
@@ -130,6 +253,18 @@ func (s *MemoService) UpdateMemo(ctx context.Context, updaterID int64, memoID in
 	}
 	if input.Tags != nil {
 		nextTags := normalizeMemoTags(*input.Tags)
+		if input.KeepCollaborators && !slices.ContainsFunc(nextTags, isCollaboratorTag) {
+			for _, tag := range current.Payload.Tags {
+				if isCollaboratorTag(tag) {
+					nextTags = append(nextTags, tag)
+				}
+			}
+			nextTags = normalizeMemoTags(nextTags)
+		}
+		// 标签归属于 memo 创建者，协作者编辑时同样按创建者的标签表校验
+		if err := s.checkKnownTags(ctx, current.CreatorID, nextTags); err != nil {
+			return MemoWithAttachments{}, err
+		}
 		if update.Payload != nil {
 			update.Payload.Tags = nextTags
 		} else {
@@ -161,6 +296,17 @@ func (s *MemoService) UpdateMemo(ctx context.Context, updaterID int64, memoID in
 		update.LongitudeSet = true
 		update.Longitude = input.Longitude
 	}
+	if input.CreateTime != nil {
+		// 只有创建者可以修改创建时间，协作者不能改写他人备忘录的时间线。
+		if current.CreatorID != updaterID {
+			return MemoWithAttachments{}, fmt.Errorf("only the memo creator can change createTime")
+		}
+		if input.CreateTime.IsZero() || input.CreateTime.After(time.Now().Add(memoCreateTimeMaxFutureSkew)) {
+			return MemoWithAttachments{}, fmt.Errorf("invalid createTime")
+		}
+		createTime := input.CreateTime.UTC()
+		update.CreateTime = &createTime
+	}
 
 	var attachmentIDs *[]int64
 	if input.AttachmentNames != nil {
@@ -174,77 +320,735 @@ func (s *MemoService) UpdateMemo(ctx context.Context, updaterID int64, memoID in
 		if err != nil {
 			return MemoWithAttachments{}, err
 		}
-		attachmentIDs = &ids
+		attachmentIDs = &ids
+	}
+
+	updatedMemo, err := s.store.UpdateMemoWithAttachments(ctx, memoID, update, attachmentIDs)
+	if err != nil {
+		return MemoWithAttachments{}, err
+	}
+
+	return s.hydrateMemo(ctx, updatedMemo)
+}
+
+func (s *MemoService) DeleteMemo(ctx context.Context, requesterID int64, memoID int64) error {
+	memo, err := s.store.GetMemoByID(ctx, memoID)
+	if err != nil {
+		return err
+	}
+	if !canManageMemo(memo, requesterID) {
+		return sql.ErrNoRows
+	}
+	return s.store.DeleteMemo(ctx, memoID)
+}
+
+// PurgeArchivedMemos 删除归档后超过 retention 未更新的备忘录，retention <= 0 时不做任何事。
+func (s *MemoService) PurgeArchivedMemos(ctx context.Context, retention time.Duration) (int64, error) {
+	if retention <= 0 {
+		return 0, nil
+	}
+	return s.store.DeleteArchivedMemosOlderThan(ctx, time.Now().UTC().Add(-retention))
+}
+
+const userSettingKeySharedLastSeen = "shared_memos_last_seen_time"
+
+// CountUnreadSharedMemos 返回上次标记已读之后被共享或更新过的协作备忘录数量，以及上次标记已读的时间（从未标记时为零值）。
+func (s *MemoService) CountUnreadSharedMemos(ctx context.Context, userID int64) (int64, time.Time, error) {
+	var lastSeen time.Time
+	raw, err := s.store.GetUserSetting(ctx, userID, userSettingKeySharedLastSeen)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return 0, time.Time{}, err
+	}
+	if err == nil {
+		lastSeen, err = time.Parse(time.RFC3339Nano, raw)
+		if err != nil {
+			return 0, time.Time{}, fmt.Errorf("parse shared memos last seen time: %w", err)
+		}
+	}
+	count, err := s.store.CountSharedMemosUpdatedAfter(ctx, userID, lastSeen)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	return count, lastSeen, nil
+}
+
+func (s *MemoService) MarkSharedMemosSeen(ctx context.Context, userID int64) (time.Time, error) {
+	now := time.Now().UTC()
+	if err := s.store.UpsertUserSetting(ctx, userID, userSettingKeySharedLastSeen, now.Format(time.RFC3339Nano)); err != nil {
+		return time.Time{}, err
+	}
+	return now, nil
+}
+
+const memoExportBatchSize = 200
+
+// ExportMemos 按 id 升序分批遍历用户本人的备忘录并逐条交给 emit，state 为 nil 时导出全部状态。
+func (s *MemoService) ExportMemos(
+	ctx context.Context,
+	userID int64,
+	state *models.MemoState,
+	emit func(MemoWithAttachments) error,
+) (int64, error) {
+	var exported int64
+	err := s.store.StreamMemosByCreator(ctx, userID, state, memoExportBatchSize, func(memos []models.Memo) error {
+		hydrated, err := s.hydrateMemos(ctx, memos)
+		if err != nil {
+			return err
+		}
+		for _, memo := range hydrated {
+			if err := emit(memo); err != nil {
+				return err
+			}
+			exported++
+		}
+		return nil
+	})
+	return exported, err
+}
+
+type MemoImportLineError struct {
+	Line    int
+	Message string
+}
+
+type MemoImportResult struct {
+	Created int
+	Skipped int
+	Errors  []MemoImportLineError
+}
+
+// memoImportRecord 与导出的 NDJSON 行格式一致，导出文件可以直接导入。
+type memoImportRecord struct {
+	Type        string   `json:"type"`
+	Content     string   `json:"content"`
+	Tags        []string `json:"tags"`
+	Visibility  string   `json:"visibility"`
+	State       string   `json:"state"`
+	Pinned      bool     `json:"pinned"`
+	CreateTime  string   `json:"createTime"`
+	Attachments []string `json:"attachments"`
+	Latitude    *float64 `json:"latitude"`
+	Longitude   *float64 `json:"longitude"`
+}
+
+// ImportMemos 逐行解析 NDJSON，无效行记录错误后跳过，有效记录在同一个事务中批量创建。
+func (s *MemoService) ImportMemos(
+	ctx context.Context,
+	userID int64,
+	defaultVisibility models.Visibility,
+	r io.Reader,
+) (MemoImportResult, error) {
+	if !defaultVisibility.IsValid() {
+		defaultVisibility = models.VisibilityPrivate
+	}
+	result := MemoImportResult{Errors: []MemoImportLineError{}}
+	skip := func(line int, message string) {
+		result.Skipped++
+		result.Errors = append(result.Errors, MemoImportLineError{Line: line, Message: message})
+	}
+
+	creates := make([]store.MemoCreate, 0)
+	reader := bufio.NewReader(r)
+	for lineNumber := 1; ; lineNumber++ {
+		raw, readErr := reader.ReadBytes('\n')
+		if readErr != nil && !errors.Is(readErr, io.EOF) {
+			return MemoImportResult{}, readErr
+		}
+		if line := strings.TrimSpace(string(raw)); line != "" {
+			create, ok, err := s.parseMemoImportLine(ctx, userID, defaultVisibility, line)
+			if err != nil {
+				skip(lineNumber, err.Error())
+			} else if ok {
+				creates = append(creates, create)
+			}
+		}
+		if errors.Is(readErr, io.EOF) {
+			break
+		}
+	}
+
+	if len(creates) > 0 {
+		if _, err := s.store.CreateMemosBatch(ctx, userID, creates); err != nil {
+			return MemoImportResult{}, err
+		}
+	}
+	result.Created = len(creates)
+	return result, nil
+}
+
+// parseMemoImportLine 返回 ok=false 表示该行不是备忘录记录（如导出的 summary 行），直接忽略。
+func (s *MemoService) parseMemoImportLine(
+	ctx context.Context,
+	userID int64,
+	defaultVisibility models.Visibility,
+	line string,
+) (store.MemoCreate, bool, error) {
+	var record memoImportRecord
+	if err := json.Unmarshal([]byte(line), &record); err != nil {
+		return store.MemoCreate{}, false, fmt.Errorf("invalid json")
+	}
+	if record.Type != "" && record.Type != "memo" {
+		return store.MemoCreate{}, false, nil
+	}
+	if strings.TrimSpace(record.Content) == "" && len(record.Attachments) == 0 {
+		return store.MemoCreate{}, false, fmt.Errorf("content is required")
+	}
+
+	visibility := defaultVisibility
+	if record.Visibility != "" {
+		visibility = models.Visibility(record.Visibility)
+		if !visibility.IsValid() {
+			return store.MemoCreate{}, false, fmt.Errorf("invalid visibility %q", record.Visibility)
+		}
+	}
+	state := models.MemoStateNormal
+	if record.State != "" {
+		state = models.MemoState(record.State)
+		if !state.IsValid() {
+			return store.MemoCreate{}, false, fmt.Errorf("invalid state %q", record.State)
+		}
+	}
+	createTime := time.Now().UTC()
+	if record.CreateTime != "" {
+		parsed, err := time.Parse(time.RFC3339Nano, record.CreateTime)
+		if err != nil {
+			return store.MemoCreate{}, false, fmt.Errorf("invalid createTime %q", record.CreateTime)
+		}
+		createTime = parsed.UTC()
+	}
+	if err := validateCoordinates(record.Latitude, record.Longitude); err != nil {
+		return store.MemoCreate{}, false, err
+	}
+	attachmentIDs, err := s.resolveAttachmentIDsFromNames(ctx, userID, record.Attachments)
+	if err != nil {
+		return store.MemoCreate{}, false, err
+	}
+	tags := normalizeMemoTags(record.Tags)
+	if err := s.checkKnownTags(ctx, userID, tags); err != nil {
+		return store.MemoCreate{}, false, err
+	}
+
+	return store.MemoCreate{
+		Content:       record.Content,
+		Visibility:    visibility,
+		State:         state,
+		Pinned:        record.Pinned,
+		Payload:       models.MemoPayload{Tags: tags},
+		CreateTime:    createTime,
+		Latitude:      record.Latitude,
+		Longitude:     record.Longitude,
+		AttachmentIDs: attachmentIDs,
+	}, true, nil
+}
+
+func (s *MemoService) ListMemos(ctx context.Context, viewerID int64, state *models.MemoState, rawFilter string, pageSize int, pageToken string) ([]MemoWithAttachments, string, error) {
+	filter, err := s.compileMemoFilter(rawFilter)
+	if err != nil {
+		return nil, "", err
+	}
+
+	prefilter := store.EmptyMemoPrefilter()
+	if filter != nil {
+		prefilter = filter.SQLPrefilter()
+	}
+	if state == nil {
+		if len(s.defaultMemoStates) == 1 {
+			defaultState := s.defaultMemoStates[0]
+			state = &defaultState
+		} else {
+			// 默认包含多个状态时交给 StateIn 预过滤，与过滤表达式中的 state 条件取交集
+			states, unsatisfiable := mergeStateAnd(s.defaultMemoStates, prefilter.StateIn)
+			prefilter.StateIn = states
+			prefilter.Unsatisfiable = prefilter.Unsatisfiable || unsatisfiable
+		}
+	}
+
+	offset, cursor, err := parsePageToken(pageToken)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid pageToken")
+	}
+	if pageSize <= 0 {
+		pageSize = 50
+	}
+	if pageSize > 200 {
+		pageSize = 200
+	}
+
+	var pinnedIDs map[int64]struct{}
+	if tag, ok := singleTagFilter(prefilter); ok {
+		pinnedIDs, err = s.store.ListVisibleMemoIDsPinnedForTag(ctx, viewerID, state, tag)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+	if cursor != nil && len(pinnedIDs) > 0 {
+		// 标签置顶排序不是按创建时间，只能使用偏移量分页
+		return nil, "", fmt.Errorf("invalid pageToken")
+	}
+
+	var page []models.Memo
+	nextToken := ""
+	if filter.FullyPushedDown() && len(pinnedIDs) == 0 {
+		// 过滤条件可完全下推到 SQL 时直接分页查询，多取一条用于判断是否有下一页
+		page, err = s.store.ListVisibleMemos(ctx, viewerID, state, prefilter, pageSize+1, offset, cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		if len(page) > pageSize {
+			page = page[:pageSize]
+			nextToken = encodeMemoPageCursor(page[len(page)-1])
+		}
+	} else if len(pinnedIDs) > 0 {
+		// 置顶项要排到最前，只能载入全部候选后排序；超过扫描上限时报错而不是静默截断
+		allVisible, err := s.store.ListVisibleMemos(ctx, viewerID, state, prefilter, memoFilterScanLimit+1, 0, nil)
+		if err != nil {
+			return nil, "", err
+		}
+		if len(allVisible) > memoFilterScanLimit {
+			return nil, "", ErrMemoScanLimit
+		}
+		if err := s.store.LoadMemoTags(ctx, allVisible); err != nil {
+			return nil, "", err
+		}
+
+		filtered := make([]models.Memo, 0, len(allVisible))
+		for _, memo := range allVisible {
+			matched, err := filter.Matches(memo)
+			if err != nil {
+				return nil, "", err
+			}
+			if !matched {
+				continue
+			}
+			filtered = append(filtered, memo)
+		}
+		sort.SliceStable(filtered, func(i, j int) bool {
+			_, pinnedI := pinnedIDs[filtered[i].ID]
+			_, pinnedJ := pinnedIDs[filtered[j].ID]
+			return pinnedI && !pinnedJ
+		})
+
+		if offset >= len(filtered) {
+			return []MemoWithAttachments{}, "", nil
+		}
+		end := min(offset+pageSize, len(filtered))
+		page = filtered[offset:end]
+		if end < len(filtered) {
+			nextToken = strconv.Itoa(end)
+		}
+	} else {
+		page, nextToken, err = s.scanFilteredMemos(ctx, viewerID, state, prefilter, filter, offset, pageSize, cursor, memoFilterScanLimit)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+
+	out, err := s.hydrateMemos(ctx, page)
+	if err != nil {
+		return nil, "", err
+	}
+	return out, nextToken, nil
+}
+
+// scanFilteredMemos 从 cursor 起按列表顺序分批读取可见 memo 并用 filter 在内存中过滤，凑满一页后返回。
+// skip 为旧式偏移量令牌需要跳过的匹配数。扫描 scanLimit 行仍未凑满时，以最后扫描的行作为下一页游标，
+// 返回的页可能不足 pageSize 甚至为空，调用方按 nextPageToken 继续翻页即可。
+func (s *MemoService) scanFilteredMemos(
+	ctx context.Context,
+	viewerID int64,
+	state *models.MemoState,
+	prefilter store.MemoSQLPrefilter,
+	filter *CELMemoFilter,
+	skip int,
+	pageSize int,
+	cursor *store.MemoQueryBounds,
+	scanLimit int,
+) ([]models.Memo, string, error) {
+	page := make([]models.Memo, 0, pageSize)
+	scanned := 0
+	for {
+		batchSize := min(memoFilterScanBatchSize, scanLimit-scanned)
+		batch, err := s.store.ListVisibleMemos(ctx, viewerID, state, prefilter, batchSize, 0, cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		if err := s.store.LoadMemoTags(ctx, batch); err != nil {
+			return nil, "", err
+		}
+		for _, memo := range batch {
+			matched, err := filter.Matches(memo)
+			if err != nil {
+				return nil, "", err
+			}
+			if !matched {
+				continue
+			}
+			if skip > 0 {
+				skip--
+				continue
+			}
+			if len(page) == pageSize {
+				return page, encodeMemoPageCursor(page[len(page)-1]), nil
+			}
+			page = append(page, memo)
+		}
+		scanned += len(batch)
+		if len(batch) < batchSize {
+			return page, "", nil
+		}
+		last := batch[len(batch)-1]
+		if scanned >= scanLimit {
+			if skip > 0 {
+				// 偏移量令牌尚未跳完时游标无法表达剩余的偏移
+				return nil, "", ErrMemoScanLimit
+			}
+			return page, encodeMemoPageCursor(last), nil
+		}
+		createTime := last.CreateTime
+		cursor = &store.MemoQueryBounds{BeforeCreateTime: &createTime, BeforeID: last.ID}
+	}
+}
+
+// ListCreatorMemos 列出某个创建者处于正常状态、且对 viewerID 可见的备忘录；
+// 未登录访问者（store.AnonymousViewerID）只能看到 PUBLIC 备忘录。分页令牌只接受游标。
+func (s *MemoService) ListCreatorMemos(ctx context.Context, viewerID int64, creatorID int64, pageSize int, pageToken string) ([]MemoWithAttachments, string, error) {
+	offset, cursor, err := parsePageToken(pageToken)
+	if err != nil || offset > 0 {
+		return nil, "", fmt.Errorf("invalid pageToken")
+	}
+	if pageSize <= 0 {
+		pageSize = 50
+	}
+	if pageSize > 200 {
+		pageSize = 200
+	}
+
+	var page []models.Memo
+	if viewerID == store.AnonymousViewerID {
+		page, err = s.store.ListPublicMemosByCreator(ctx, creatorID, pageSize+1, cursor)
+	} else {
+		state := models.MemoStateNormal
+		prefilter := store.EmptyMemoPrefilter()
+		prefilter.CreatorIDs = []int64{creatorID}
+		page, err = s.store.ListVisibleMemos(ctx, viewerID, &state, prefilter, pageSize+1, 0, cursor)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	nextToken := ""
+	if len(page) > pageSize {
+		page = page[:pageSize]
+		nextToken = encodeMemoPageCursor(page[len(page)-1])
+	}
+
+	out, err := s.hydrateMemos(ctx, page)
+	if err != nil {
+		return nil, "", err
+	}
+	return out, nextToken, nil
+}
+
+func (s *MemoService) BatchSetMemoVisibility(ctx context.Context, userID int64, rawFilter string, visibility models.Visibility, confirm bool) (int, error) {
+	if !visibility.IsValid() {
+		return 0, fmt.Errorf("invalid visibility")
+	}
+	if strings.TrimSpace(rawFilter) == "" && !confirm {
+		return 0, fmt.Errorf("confirm is required to change visibility of all memos")
+	}
+	filter, err := s.compileMemoFilter(rawFilter)
+	if err != nil {
+		return 0, err
+	}
+
+	prefilter := store.EmptyMemoPrefilter()
+	if filter != nil {
+		prefilter = filter.SQLPrefilter()
+	}
+	if len(prefilter.CreatorIDs) == 0 {
+		prefilter.CreatorIDs = []int64{userID}
+	}
+	owned, err := s.store.ListVisibleMemos(ctx, userID, nil, prefilter, 0, 0, nil)
+	if err != nil {
+		return 0, err
+	}
+	if err := s.store.LoadMemoTags(ctx, owned); err != nil {
+		return 0, err
+	}
+
+	changed := 0
+	for _, memo := range owned {
+		if memo.CreatorID != userID || memo.Visibility == visibility {
+			continue
+		}
+		matched, err := filter.Matches(memo)
+		if err != nil {
+			return changed, err
+		}
+		if !matched {
+			continue
+		}
+		updated, err := s.store.SetMemoVisibility(ctx, memo.ID, visibility)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				continue
+			}
+			return changed, err
+		}
+		if updated {
+			changed++
+		}
+	}
+	return changed, nil
+}
+
+func (s *MemoService) SetMemoTagPin(ctx context.Context, userID int64, memoID int64, rawTag string, pinned bool) (MemoWithAttachments, error) {
+	memo, err := s.store.GetMemoByID(ctx, memoID)
+	if err != nil {
+		return MemoWithAttachments{}, err
+	}
+	if !canManageMemo(memo, userID) {
+		return MemoWithAttachments{}, sql.ErrNoRows
+	}
+	tag := strings.TrimPrefix(strings.TrimSpace(rawTag), "#")
+	if tag == "" {
+		return MemoWithAttachments{}, fmt.Errorf("tag is required")
+	}
+
+	if pinned {
+		hasTag := false
+		for _, memoTag := range memo.Payload.Tags {
+			if memoTag == tag {
+				hasTag = true
+				break
+			}
+		}
+		if !hasTag {
+			return MemoWithAttachments{}, fmt.Errorf("memo does not have tag %q", tag)
+		}
+		err = s.store.PinMemoForTag(ctx, memoID, tag)
+	} else {
+		err = s.store.UnpinMemoForTag(ctx, memoID, tag)
+	}
+	if err != nil {
+		return MemoWithAttachments{}, err
+	}
+
+	return s.hydrateMemo(ctx, memo)
+}
+
+func (s *MemoService) ShareMemoToGroup(ctx context.Context, userID int64, memoID int64, groupID int64) (MemoWithAttachments, error) {
+	return s.applyMemoGroupShare(ctx, userID, memoID, groupID, false)
+}
+
+func (s *MemoService) ResyncMemoGroupShare(ctx context.Context, userID int64, memoID int64, groupID int64) (MemoWithAttachments, error) {
+	return s.applyMemoGroupShare(ctx, userID, memoID, groupID, true)
+}
+
+// applyMemoGroupShare adds collab/<memberId> tags for every group member. With
+// resync, collaborators previously added by this group who are no longer
+// members are removed again, unless another group share still covers them.
+func (s *MemoService) applyMemoGroupShare(ctx context.Context, userID int64, memoID int64, groupID int64, resync bool) (MemoWithAttachments, error) {
+	memo, err := s.store.GetMemoByID(ctx, memoID)
+	if err != nil {
+		return MemoWithAttachments{}, err
+	}
+	if !canManageMemo(memo, userID) {
+		return MemoWithAttachments{}, sql.ErrNoRows
+	}
+	if _, err := s.store.GetGroupByID(ctx, groupID); err != nil {
+		return MemoWithAttachments{}, err
+	}
+	members, err := s.store.ListGroupMembers(ctx, groupID)
+	if err != nil {
+		return MemoWithAttachments{}, err
+	}
+	requesterIsMember := false
+	memberIDs := make([]int64, 0, len(members))
+	memberSet := make(map[int64]struct{}, len(members))
+	for _, member := range members {
+		if member.ID == userID {
+			requesterIsMember = true
+		}
+		if member.ID == memo.CreatorID {
+			continue
+		}
+		memberIDs = append(memberIDs, member.ID)
+		memberSet[member.ID] = struct{}{}
+	}
+	if !requesterIsMember && memo.CreatorID != userID {
+		return MemoWithAttachments{}, sql.ErrNoRows
+	}
+
+	shares, err := s.store.ListMemoGroupShares(ctx, memoID)
+	if err != nil {
+		return MemoWithAttachments{}, err
+	}
+	previous := shares[groupID]
+	sharedByOtherGroup := func(collaboratorID int64) bool {
+		for otherGroupID, userIDs := range shares {
+			if otherGroupID == groupID {
+				continue
+			}
+			if _, ok := userIDs[collaboratorID]; ok {
+				return true
+			}
+		}
+		return false
 	}
 
-	updatedMemo, err := s.store.UpdateMemoWithAttachments(ctx, memoID, update, attachmentIDs)
-	if err != nil {
-		return MemoWithAttachments{}, err
+	changed := false
+	removeTags := make(map[string]struct{})
+	recorded := make(map[int64]struct{}, len(memberIDs))
+	for collaboratorID := range previous {
+		if _, stillMember := memberSet[collaboratorID]; stillMember || !resync {
+			recorded[collaboratorID] = struct{}{}
+			continue
+		}
+		changed = true
+		if !sharedByOtherGroup(collaboratorID) {
+			removeTags["collab/"+strconv.FormatInt(collaboratorID, 10)] = struct{}{}
+		}
 	}
 
-	attachmentsMap, err := s.store.ListAttachmentsByMemoIDs(ctx, []int64{memoID})
+	currentTags := make(map[string]struct{}, len(memo.Payload.Tags))
+	for _, tag := range memo.Payload.Tags {
+		currentTags[tag] = struct{}{}
+	}
+	nextTags := make([]string, 0, len(memo.Payload.Tags)+len(memberIDs))
+	for _, tag := range memo.Payload.Tags {
+		if _, removed := removeTags[tag]; removed {
+			continue
+		}
+		nextTags = append(nextTags, tag)
+	}
+	for _, memberID := range memberIDs {
+		tag := "collab/" + strconv.FormatInt(memberID, 10)
+		if _, exists := currentTags[tag]; exists {
+			continue
+		}
+		nextTags = append(nextTags, tag)
+		recorded[memberID] = struct{}{}
+		changed = true
+	}
+	if !changed {
+		return s.GetMemo(ctx, userID, memoID)
+	}
+
+	recordedIDs := make([]int64, 0, len(recorded))
+	for collaboratorID := range recorded {
+		recordedIDs = append(recordedIDs, collaboratorID)
+	}
+	sort.Slice(recordedIDs, func(i, j int) bool { return recordedIDs[i] < recordedIDs[j] })
+	payload := memo.Payload
+	payload.Tags = normalizeMemoTags(nextTags)
+	updated, err := s.store.UpdateMemoWithAttachments(ctx, memoID, store.MemoUpdate{
+		Payload: &payload,
+		GroupShare: &store.MemoGroupShare{
+			GroupID: groupID,
+			UserIDs: recordedIDs,
+		},
+	}, nil)
 	if err != nil {
 		return MemoWithAttachments{}, err
 	}
+	return s.hydrateMemo(ctx, updated)
+}
 
-	return MemoWithAttachments{
-		Memo:        updatedMemo,
-		Attachments: attachmentsMap[memoID],
-	}, nil
+// AddCollaborator 将用户加为 memo 协作者，内部仍以 collab/<id> 标签表示；已是协作者时直接返回 memo。
+func (s *MemoService) AddCollaborator(ctx context.Context, userID int64, memoID int64, collaboratorID int64) (MemoWithAttachments, error) {
+	return s.setCollaborator(ctx, userID, memoID, collaboratorID, true)
 }
 
-func (s *MemoService) DeleteMemo(ctx context.Context, requesterID int64, memoID int64) error {
+// RemoveCollaborator 移除 memo 协作者，被移除的用户会收到 VISIBILITY_REVOKED 变更事件。
+func (s *MemoService) RemoveCollaborator(ctx context.Context, userID int64, memoID int64, collaboratorID int64) (MemoWithAttachments, error) {
+	return s.setCollaborator(ctx, userID, memoID, collaboratorID, false)
+}
+
+func (s *MemoService) setCollaborator(ctx context.Context, userID int64, memoID int64, collaboratorID int64, add bool) (MemoWithAttachments, error) {
 	memo, err := s.store.GetMemoByID(ctx, memoID)
 	if err != nil {
-		return err
+		return MemoWithAttachments{}, err
 	}
-	if !canManageMemo(memo, requesterID) {
-		return sql.ErrNoRows
+	if !canManageMemo(memo, userID) {
+		return MemoWithAttachments{}, sql.ErrNoRows
+	}
+	if collaboratorID == memo.CreatorID {
+		return MemoWithAttachments{}, ErrInvalidCollaborator
+	}
+	if add {
+		if _, err := s.store.GetUserByID(ctx, collaboratorID); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return MemoWithAttachments{}, ErrCollaboratorNotFound
+			}
+			return MemoWithAttachments{}, err
+		}
 	}
-	return s.store.DeleteMemo(ctx, memoID)
-}
 
-func (s *MemoService) ListMemos(ctx context.Context, viewerID int64, state *models.MemoState, rawFilter string, pageSize int, pageToken string) ([]MemoWithAttachments, string, error) {
-	if containsContentDrivenFilter(rawFilter) {
-		return nil, "", fmt.Errorf("content-based filter is disabled")
+	collaboratorTag := "collab/" + strconv.FormatInt(collaboratorID, 10)
+	present := false
+	nextTags := make([]string, 0, len(memo.Payload.Tags)+1)
+	for _, tag := range memo.Payload.Tags {
+		if tag == collaboratorTag {
+			present = true
+			if !add {
+				continue
+			}
+		}
+		nextTags = append(nextTags, tag)
+	}
+	if present == add {
+		return s.hydrateMemo(ctx, memo)
+	}
+	if add {
+		nextTags = append(nextTags, collaboratorTag)
 	}
 
-	filter, err := CompileMemoFilter(rawFilter)
+	payload := memo.Payload
+	payload.Tags = normalizeMemoTags(nextTags)
+	updated, err := s.store.UpdateMemo(ctx, memoID, store.MemoUpdate{Payload: &payload})
 	if err != nil {
-		return nil, "", err
+		return MemoWithAttachments{}, err
 	}
+	return s.hydrateMemo(ctx, updated)
+}
 
-	if state == nil {
-		defaultState := models.MemoStateNormal
-		state = &defaultState
-	}
+// SubscribeMemoChanges 订阅 viewerID 可能看到的备忘录写入信号，收到后由调用方通过 ListMemoChanges 按可见性拉取变更。
+func (s *MemoService) SubscribeMemoChanges(viewerID int64) (<-chan struct{}, func()) {
+	return s.store.SubscribeMemoChanges(viewerID)
+}
 
-	prefilter := store.EmptyMemoPrefilter()
-	if filter != nil {
-		prefilter = filter.SQLPrefilter()
-	}
+func (s *MemoService) RebuildSearchIndex(ctx context.Context, progress func(indexed int64, total int64)) (int64, error) {
+	return s.store.RebuildMemoSearchIndex(ctx, s.searchTokenizer, searchReindexBatchSize, progress)
+}
 
-	// 设置安全上限，避免一次性加载过多 memo 到内存
-	const maxMemoQueryLimit = 10000
-	allVisible, err := s.store.ListVisibleMemos(ctx, viewerID, state, prefilter, maxMemoQueryLimit, 0, nil)
+// SyncSearchTokenizer rebuilds memos_fts when it was created with a tokenizer
+// other than the configured one, and reports whether a rebuild happened.
+func (s *MemoService) SyncSearchTokenizer(ctx context.Context) (bool, error) {
+	current, err := s.store.MemoSearchIndexTokenizer(ctx)
 	if err != nil {
-		return nil, "", err
+		return false, err
 	}
-
-	filtered := make([]models.Memo, 0, len(allVisible))
-	for _, memo := range allVisible {
-		matched, err := filter.Matches(memo)
-		if err != nil {
-			return nil, "", err
-		}
-		if !matched {
-			continue
-		}
-		filtered = append(filtered, memo)
+	if current == s.searchTokenizer {
+		return false, nil
 	}
+	if _, err := s.RebuildSearchIndex(ctx, nil); err != nil {
+		return false, err
+	}
+	return true, nil
+}
 
-	offset, err := parsePageToken(pageToken)
-	if err != nil {
+func (s *MemoService) SearchVisibleMemos(
+	ctx context.Context,
+	viewerID int64,
+	state *models.MemoState,
+	rawQuery string,
+	advanced bool,
+	pageSize int,
+	pageToken string,
+) ([]MemoWithAttachments, string, error) {
+	// 搜索结果按相关度排序，只能使用偏移量分页
+	offset, cursor, err := parsePageToken(pageToken)
+	if err != nil || cursor != nil {
 		return nil, "", fmt.Errorf("invalid pageToken")
 	}
 	if pageSize <= 0 {
@@ -253,34 +1057,60 @@ func (s *MemoService) ListMemos(ctx context.Context, viewerID int64, state *mode
 	if pageSize > 200 {
 		pageSize = 200
 	}
+	if state == nil {
+		defaultState := models.MemoStateNormal
+		state = &defaultState
+	}
 
-	if offset >= len(filtered) {
+	match := sanitizeFTSQuery(rawQuery, advanced)
+	if match == "" {
 		return []MemoWithAttachments{}, "", nil
 	}
-	end := min(offset+pageSize, len(filtered))
-	page := filtered[offset:end]
+	if advanced {
+		if err := s.store.ValidateMemoSearchMatch(ctx, match); err != nil {
+			if ctx.Err() != nil {
+				return nil, "", err
+			}
+			return nil, "", ErrInvalidSearchQuery
+		}
+	}
+	memos, err := s.store.SearchMemos(ctx, viewerID, s.searchScope, state, match, pageSize+1, offset)
+	if err != nil {
+		return nil, "", err
+	}
 	nextToken := ""
-	if end < len(filtered) {
-		nextToken = strconv.Itoa(end)
+	if len(memos) > pageSize {
+		memos = memos[:pageSize]
+		nextToken = strconv.Itoa(offset + pageSize)
 	}
 
-	memoIDs := make([]int64, 0, len(page))
-	for _, memo := range page {
-		memoIDs = append(memoIDs, memo.ID)
-	}
-	attachmentsMap, err := s.store.ListAttachmentsByMemoIDs(ctx, memoIDs)
+	out, err := s.hydrateMemos(ctx, memos)
 	if err != nil {
 		return nil, "", err
 	}
+	return out, nextToken, nil
+}
 
-	out := make([]MemoWithAttachments, 0, len(page))
-	for _, memo := range page {
-		out = append(out, MemoWithAttachments{
-			Memo:        memo,
-			Attachments: attachmentsMap[memo.ID],
-		})
+func (s *MemoService) ListMemoEvents(ctx context.Context, viewerID int64, memoID int64) ([]models.MemoChangeEvent, error) {
+	memoExists := true
+	memo, err := s.store.GetMemoByID(ctx, memoID)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+		memoExists = false
+	} else if memo.CreatorID != viewerID {
+		return nil, sql.ErrNoRows
 	}
-	return out, nextToken, nil
+
+	events, err := s.store.ListMemoChangeEventsByMemoID(ctx, memoID, viewerID)
+	if err != nil {
+		return nil, err
+	}
+	if !memoExists && len(events) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return events, nil
 }
 
 func (s *MemoService) ListMemoChanges(
@@ -291,11 +1121,7 @@ func (s *MemoService) ListMemoChanges(
 	since time.Time,
 	syncAnchor time.Time,
 ) (MemoChanges, error) {
-	if containsContentDrivenFilter(rawFilter) {
-		return MemoChanges{}, fmt.Errorf("content-based filter is disabled")
-	}
-
-	filter, err := CompileMemoFilter(rawFilter)
+	filter, err := s.compileMemoFilter(rawFilter)
 	if err != nil {
 		return MemoChanges{}, err
 	}
@@ -332,6 +1158,9 @@ func (s *MemoService) ListMemoChanges(
 	if err != nil {
 		return MemoChanges{}, err
 	}
+	if err := s.store.LoadMemoTags(ctx, allVisible); err != nil {
+		return MemoChanges{}, err
+	}
 
 	filtered := make([]models.Memo, 0, len(allVisible))
 	for _, memo := range allVisible {
@@ -345,27 +1174,15 @@ func (s *MemoService) ListMemoChanges(
 		filtered = append(filtered, memo)
 	}
 
-	memoIDs := make([]int64, 0, len(filtered))
-	for _, memo := range filtered {
-		memoIDs = append(memoIDs, memo.ID)
-	}
-
-	attachmentsMap, err := s.store.ListAttachmentsByMemoIDs(ctx, memoIDs)
+	changedMemos, err := s.hydrateMemos(ctx, filtered)
 	if err != nil {
 		return MemoChanges{}, err
 	}
 
-	changedMemos := make([]MemoWithAttachments, 0, len(filtered))
-	for _, memo := range filtered {
-		changedMemos = append(changedMemos, MemoWithAttachments{
-			Memo:        memo,
-			Attachments: attachmentsMap[memo.ID],
-		})
-	}
-
 	deletedMemoNames, err := s.store.ListDeletedVisibleMemoNames(
 		ctx,
 		viewerID,
+		state,
 		normalizedSince,
 		normalizedAnchor,
 		noQueryLimit,
@@ -373,6 +1190,21 @@ func (s *MemoService) ListMemoChanges(
 	if err != nil {
 		return MemoChanges{}, err
 	}
+	if len(deletedMemoNames) > 0 && len(changedMemos) > 0 {
+		// A memo archived and restored within the window is still visible.
+		changedNames := make(map[string]struct{}, len(changedMemos))
+		for _, item := range changedMemos {
+			changedNames[item.Memo.Name()] = struct{}{}
+		}
+		stillDeleted := make([]string, 0, len(deletedMemoNames))
+		for _, name := range deletedMemoNames {
+			if _, visible := changedNames[name]; visible {
+				continue
+			}
+			stillDeleted = append(stillDeleted, name)
+		}
+		deletedMemoNames = stillDeleted
+	}
 
 	return MemoChanges{
 		Memos:            changedMemos,
@@ -386,6 +1218,9 @@ func (s *MemoService) GetUserTagCount(ctx context.Context, requestedUserID int64
 	if err != nil {
 		return nil, err
 	}
+	if err := s.store.LoadMemoTags(ctx, memos); err != nil {
+		return nil, err
+	}
 
 	tagCount := make(map[string]int)
 	for _, memo := range memos {
@@ -396,19 +1231,95 @@ func (s *MemoService) GetUserTagCount(ctx context.Context, requestedUserID int64
 	return tagCount, nil
 }
 
-func parsePageToken(pageToken string) (int, error) {
+// ListTagCounts 返回用户自己的标签及其正常状态 memo 数量，直接在数据库中聚合。
+func (s *MemoService) ListTagCounts(ctx context.Context, userID int64) ([]store.TagCount, error) {
+	return s.store.CountTagsByCreator(ctx, userID)
+}
+
+// parsePageToken accepts both legacy integer offsets and keyset cursors
+// produced by encodeMemoPageCursor.
+func parsePageToken(pageToken string) (int, *store.MemoQueryBounds, error) {
 	pageToken = strings.TrimSpace(pageToken)
 	if pageToken == "" {
-		return 0, nil
+		return 0, nil, nil
+	}
+	if offset, err := strconv.Atoi(pageToken); err == nil {
+		if offset < 0 {
+			return 0, nil, fmt.Errorf("invalid page token")
+		}
+		return offset, nil, nil
 	}
-	offset, err := strconv.Atoi(pageToken)
-	if err != nil || offset < 0 {
-		return 0, fmt.Errorf("invalid page token")
+
+	raw, err := base64.RawURLEncoding.DecodeString(pageToken)
+	if err != nil {
+		return 0, nil, fmt.Errorf("invalid page token")
+	}
+	createTimeRaw, idRaw, ok := strings.Cut(string(raw), "|")
+	if !ok {
+		return 0, nil, fmt.Errorf("invalid page token")
+	}
+	createTime, err := time.Parse(time.RFC3339Nano, createTimeRaw)
+	if err != nil {
+		return 0, nil, fmt.Errorf("invalid page token")
+	}
+	id, err := strconv.ParseInt(idRaw, 10, 64)
+	if err != nil || id <= 0 {
+		return 0, nil, fmt.Errorf("invalid page token")
+	}
+	return 0, &store.MemoQueryBounds{
+		BeforeCreateTime: &createTime,
+		BeforeID:         id,
+	}, nil
+}
+
+func encodeMemoPageCursor(memo models.Memo) string {
+	raw := memo.CreateTime.UTC().Format(time.RFC3339Nano) + "|" + strconv.FormatInt(memo.ID, 10)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// sanitizeFTSQuery turns free text into an FTS5 MATCH expression. Every term
+// is quoted so operators and punctuation are matched literally; a trailing `*`
+// is kept outside the quotes as a prefix query. Advanced queries are passed
+// through as FTS5 syntax and must be validated before use.
+func sanitizeFTSQuery(rawQuery string, advanced bool) string {
+	if advanced {
+		return strings.TrimSpace(rawQuery)
+	}
+	terms := strings.Fields(rawQuery)
+	parts := make([]string, 0, len(terms))
+	for _, term := range terms {
+		prefix := strings.HasSuffix(term, "*")
+		term = strings.TrimRight(term, "*")
+		if term == "" {
+			continue
+		}
+		part := `"` + strings.ReplaceAll(term, `"`, `""`) + `"`
+		if prefix {
+			part += "*"
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, " ")
+}
+
+// compileMemoFilter 编译列表类接口的过滤条件，并拒绝依赖正文内容、无法下推的条件。
+func (s *MemoService) compileMemoFilter(rawFilter string) (*CELMemoFilter, error) {
+	if containsContentDrivenFilter(rawFilter, s.contentFilterFTS) {
+		return nil, fmt.Errorf("content-based filter is disabled")
+	}
+	filter, err := CompileMemoFilter(rawFilter)
+	if err != nil {
+		return nil, err
 	}
-	return offset, nil
+	if !filter.ContentPushedDown() {
+		return nil, fmt.Errorf(`content filter only supports content.contains("...") joined with &&`)
+	}
+	return filter, nil
 }
 
-func containsContentDrivenFilter(rawFilter string) bool {
+// containsContentDrivenFilter 判断过滤条件是否引用正文或由正文派生的属性；allowContent 为 true 时
+// 不检查 content 本身，由编译后的 ContentPushedDown 进一步限定用法。
+func containsContentDrivenFilter(rawFilter string, allowContent bool) bool {
 	trimmed := strings.TrimSpace(rawFilter)
 	if trimmed == "" {
 		return false
@@ -420,7 +1331,7 @@ func containsContentDrivenFilter(rawFilter string) bool {
 	}
 
 	for _, ident := range identifiers {
-		if ident == "content" || strings.HasPrefix(ident, "content.") {
+		if !allowContent && (ident == "content" || strings.HasPrefix(ident, "content.")) {
 			return true
 		}
 		if ident == "property" || strings.HasPrefix(ident, "property.") {
@@ -500,10 +1411,14 @@ func isFilterIdentifierPart(ch rune) bool {
 	return ch == '_' || unicode.IsLetter(ch) || unicode.IsDigit(ch)
 }
 
-func (s *MemoService) resolveAttachmentIDsFromNames(ctx context.Context, userID int64, names []string) ([]int64, error) {
-	if len(names) == 0 {
-		return []int64{}, nil
+func (s *MemoService) checkAttachmentCount(names []string) error {
+	if s.maxAttachmentsPerMemo > 0 && len(names) > s.maxAttachmentsPerMemo {
+		return fmt.Errorf("%w: %d exceeds limit of %d", ErrTooManyAttachments, len(names), s.maxAttachmentsPerMemo)
 	}
+	return nil
+}
+
+func parseAttachmentNames(names []string) ([]int64, error) {
 	ids := make([]int64, 0, len(names))
 	seen := make(map[int64]struct{})
 	for _, name := range names {
@@ -514,19 +1429,59 @@ func (s *MemoService) resolveAttachmentIDsFromNames(ctx context.Context, userID
 		if _, ok := seen[id]; ok {
 			continue
 		}
-		belongs, err := s.store.AttachmentBelongsToUser(ctx, id, userID)
-		if err != nil {
-			return nil, err
-		}
-		if !belongs {
-			return nil, fmt.Errorf("attachment %d not found", id)
-		}
 		seen[id] = struct{}{}
 		ids = append(ids, id)
 	}
 	return ids, nil
 }
 
+// hydrateMemos 通过 SQLStore.HydrateMemos 一次性补齐标签与附件。
+func (s *MemoService) hydrateMemos(ctx context.Context, memos []models.Memo) ([]MemoWithAttachments, error) {
+	attachmentsMap, err := s.store.HydrateMemos(ctx, memos)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]MemoWithAttachments, 0, len(memos))
+	for _, memo := range memos {
+		out = append(out, MemoWithAttachments{
+			Memo:        memo,
+			Attachments: attachmentsMap[memo.ID],
+		})
+	}
+	return out, nil
+}
+
+func (s *MemoService) hydrateMemo(ctx context.Context, memo models.Memo) (MemoWithAttachments, error) {
+	hydrated, err := s.hydrateMemos(ctx, []models.Memo{memo})
+	if err != nil {
+		return MemoWithAttachments{}, err
+	}
+	return hydrated[0], nil
+}
+
+func (s *MemoService) resolveAttachmentIDsFromNames(ctx context.Context, userID int64, names []string) ([]int64, error) {
+	if len(names) == 0 {
+		return []int64{}, nil
+	}
+	if err := s.checkAttachmentCount(names); err != nil {
+		return nil, err
+	}
+	ids, err := parseAttachmentNames(names)
+	if err != nil {
+		return nil, err
+	}
+	owned, err := s.store.FilterAttachmentIDsOwnedBy(ctx, ids, userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		if _, ok := owned[id]; !ok {
+			return nil, fmt.Errorf("attachment %d not found", id)
+		}
+	}
+	return ids, nil
+}
+
 func (s *MemoService) resolveAttachmentIDsForMemoUpdate(
 	ctx context.Context,
 	updaterID int64,
@@ -537,19 +1492,12 @@ func (s *MemoService) resolveAttachmentIDsForMemoUpdate(
 	if len(names) == 0 {
 		return []int64{}, nil
 	}
-
-	ids := make([]int64, 0, len(names))
-	seen := make(map[int64]struct{})
-	for _, name := range names {
-		id, err := parseResourceID(name)
-		if err != nil {
-			return nil, err
-		}
-		if _, ok := seen[id]; ok {
-			continue
-		}
-		seen[id] = struct{}{}
-		ids = append(ids, id)
+	if err := s.checkAttachmentCount(names); err != nil {
+		return nil, err
+	}
+	ids, err := parseAttachmentNames(names)
+	if err != nil {
+		return nil, err
 	}
 
 	existingMap, err := s.store.ListAttachmentsByMemoIDs(ctx, []int64{memoID})
@@ -561,35 +1509,59 @@ func (s *MemoService) resolveAttachmentIDsForMemoUpdate(
 		existingAttachmentIDs[attachment.ID] = struct{}{}
 	}
 
+	pending := make([]int64, 0, len(ids))
 	for _, id := range ids {
-		if _, alreadyAttached := existingAttachmentIDs[id]; alreadyAttached {
-			continue
+		if _, alreadyAttached := existingAttachmentIDs[id]; !alreadyAttached {
+			pending = append(pending, id)
 		}
+	}
+	if len(pending) == 0 {
+		return ids, nil
+	}
 
-		belongsToUpdater, err := s.store.AttachmentBelongsToUser(ctx, id, updaterID)
+	ownedByUpdater, err := s.store.FilterAttachmentIDsOwnedBy(ctx, pending, updaterID)
+	if err != nil {
+		return nil, err
+	}
+	ownedByCreator := map[int64]struct{}{}
+	if memoCreatorID != updaterID {
+		ownedByCreator, err = s.store.FilterAttachmentIDsOwnedBy(ctx, pending, memoCreatorID)
 		if err != nil {
 			return nil, err
 		}
-		if belongsToUpdater {
+	}
+	// 已挂在 memo 上的附件无论归属都可保留；新增的附件须属于创建者，或在允许时属于协作者本人。
+	for _, id := range pending {
+		if _, ok := ownedByCreator[id]; ok {
 			continue
 		}
-
-		if memoCreatorID != updaterID {
-			belongsToCreator, err := s.store.AttachmentBelongsToUser(ctx, id, memoCreatorID)
-			if err != nil {
-				return nil, err
-			}
-			if belongsToCreator {
-				continue
+		if _, ok := ownedByUpdater[id]; ok {
+			if memoCreatorID != updaterID && s.restrictCollaboratorAttachments {
+				return nil, fmt.Errorf("%w: attachment %d", ErrCollaboratorAttachmentNotAllowed, id)
 			}
+			continue
 		}
-
 		return nil, fmt.Errorf("attachment %d not found", id)
 	}
 
 	return ids, nil
 }
 
+func singleTagFilter(prefilter store.MemoSQLPrefilter) (string, bool) {
+	if len(prefilter.TagGroups) != 1 || len(prefilter.TagGroups[0].Options) != 1 {
+		return "", false
+	}
+	option := prefilter.TagGroups[0].Options[0]
+	if option.Kind != store.TagMatchExact || option.Value == "" {
+		return "", false
+	}
+	return option.Value, true
+}
+
+func isCollaboratorTag(tag string) bool {
+	return strings.HasPrefix(tag, "collab/")
+}
+
 func canManageMemo(memo models.Memo, userID int64) bool {
 	if memo.CreatorID == userID {
 		return true
@@ -623,6 +1595,64 @@ func parseResourceID(name string) (int64, error) {
 	return id, nil
 }
 
+// CreateTag 显式创建标签，供开启 RestrictTagsToExisting 的实例维护标签表；标签已存在时 created=false。
+func (s *MemoService) CreateTag(ctx context.Context, userID int64, rawName string) (string, bool, error) {
+	name := strings.TrimSpace(rawName)
+	if name == "" || strings.HasPrefix(name, "collab/") {
+		return "", false, ErrInvalidTagName
+	}
+	created, err := s.store.CreateExplicitTag(ctx, userID, name)
+	if err != nil {
+		return "", false, err
+	}
+	return name, created, nil
+}
+
+// RenameTag 将用户的标签全局改名；目标名已存在时合并到目标标签。collab/ 协作标签不可改名。
+func (s *MemoService) RenameTag(ctx context.Context, userID int64, rawOldName string, rawNewName string) (string, error) {
+	oldName := strings.TrimSpace(rawOldName)
+	newName := strings.TrimSpace(rawNewName)
+	if oldName == "" || newName == "" || strings.HasPrefix(oldName, "collab/") || strings.HasPrefix(newName, "collab/") {
+		return "", ErrInvalidTagName
+	}
+	if err := s.store.RenameTag(ctx, userID, oldName, newName); err != nil {
+		return "", err
+	}
+	return newName, nil
+}
+
+// DeleteTag 删除用户的标签并从所有 memo 上移除；标签不从正文解析，memo 再次保存也不会恢复。
+// collab/ 协作标签需通过协作者接口管理，这里不可删除。
+func (s *MemoService) DeleteTag(ctx context.Context, userID int64, rawName string) error {
+	name := strings.TrimSpace(rawName)
+	if name == "" || strings.HasPrefix(name, "collab/") {
+		return ErrInvalidTagName
+	}
+	return s.store.DeleteTag(ctx, userID, name)
+}
+
+// checkKnownTags 在限制标签表时拒绝创建者尚未拥有的标签。
+func (s *MemoService) checkKnownTags(ctx context.Context, creatorID int64, tags []string) error {
+	if !s.restrictTags || len(tags) == 0 {
+		return nil
+	}
+	candidates := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		if strings.HasPrefix(tag, "collab/") {
+			continue
+		}
+		candidates = append(candidates, tag)
+	}
+	missing, err := s.store.ListMissingTagNames(ctx, creatorID, candidates)
+	if err != nil {
+		return err
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%w: %s", ErrUnknownTag, strings.Join(missing, ", "))
+	}
+	return nil
+}
+
 func normalizeMemoTags(tags []string) []string {
 	if len(tags) == 0 {
 		return []string{}
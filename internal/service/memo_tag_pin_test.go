@@ -0,0 +1,80 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/shinyes/keer/internal/models"
+)
+
+func TestListMemos_TagPinnedMemosFirstWhenFilteredByTag(t *testing.T) {
+	t.Parallel()
+
+	services := setupTestServices(t)
+	ctx := context.Background()
+	owner := mustCreateUser(t, services.store, "memo-tag-pin-owner")
+	outsider := mustCreateUser(t, services.store, "memo-tag-pin-outsider")
+
+	createMemo := func(content string, tags ...string) int64 {
+		t.Helper()
+		created, err := services.memoService.CreateMemo(ctx, owner.ID, CreateMemoInput{
+			Content:    content,
+			Visibility: models.VisibilityPrivate,
+			Tags:       tags,
+		})
+		if err != nil {
+			t.Fatalf("CreateMemo() error = %v", err)
+		}
+		return created.Memo.ID
+	}
+	oldest := createMemo("oldest", "book", "work")
+	createMemo("middle", "book")
+	createMemo("newest", "book", "work")
+
+	if _, err := services.memoService.SetMemoTagPin(ctx, owner.ID, oldest, "book", true); err != nil {
+		t.Fatalf("SetMemoTagPin() error = %v", err)
+	}
+	if _, err := services.memoService.SetMemoTagPin(ctx, owner.ID, oldest, "travel", true); err == nil {
+		t.Fatalf("expected pinning for a tag the memo does not have to fail")
+	}
+	if _, err := services.memoService.SetMemoTagPin(ctx, outsider.ID, oldest, "book", true); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("expected sql.ErrNoRows for non-owner, got %v", err)
+	}
+
+	list, _, err := services.memoService.ListMemos(ctx, owner.ID, nil, `"book" in tags`, 50, "")
+	if err != nil {
+		t.Fatalf("ListMemos(book) error = %v", err)
+	}
+	if len(list) != 3 || list[0].Memo.ID != oldest {
+		t.Fatalf("expected memo pinned for book first, got %+v", list)
+	}
+
+	list, _, err = services.memoService.ListMemos(ctx, owner.ID, nil, `"work" in tags`, 50, "")
+	if err != nil {
+		t.Fatalf("ListMemos(work) error = %v", err)
+	}
+	if len(list) != 2 || list[0].Memo.ID == oldest {
+		t.Fatalf("expected book pin to be ignored for work filter, got %+v", list)
+	}
+
+	list, _, err = services.memoService.ListMemos(ctx, owner.ID, nil, "", 50, "")
+	if err != nil {
+		t.Fatalf("ListMemos() error = %v", err)
+	}
+	if len(list) != 3 || list[0].Memo.ID == oldest {
+		t.Fatalf("expected tag pin to be ignored without tag filter, got %+v", list)
+	}
+
+	if _, err := services.memoService.SetMemoTagPin(ctx, owner.ID, oldest, "#book", false); err != nil {
+		t.Fatalf("SetMemoTagPin(unpin) error = %v", err)
+	}
+	list, _, err = services.memoService.ListMemos(ctx, owner.ID, nil, `"book" in tags`, 50, "")
+	if err != nil {
+		t.Fatalf("ListMemos(book) after unpin error = %v", err)
+	}
+	if list[0].Memo.ID == oldest {
+		t.Fatalf("expected unpinned memo to return to chronological order")
+	}
+}
@@ -0,0 +1,68 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/shinyes/keer/internal/models"
+)
+
+func TestRestrictTagsToExisting(t *testing.T) {
+	t.Parallel()
+
+	services := setupTestServices(t)
+	ctx := context.Background()
+	owner := mustCreateUser(t, services.store, "tag-restrict-owner")
+	collaborator := mustCreateUser(t, services.store, "tag-restrict-collab")
+	services.memoService.SetRestrictTagsToExisting(true)
+
+	if _, err := services.memoService.CreateMemo(ctx, owner.ID, CreateMemoInput{Content: "draft", Tags: []string{"work"}}); !errors.Is(err, ErrUnknownTag) {
+		t.Fatalf("expected ErrUnknownTag for new tag, got %v", err)
+	}
+
+	if _, created, err := services.memoService.CreateTag(ctx, owner.ID, "work"); err != nil || !created {
+		t.Fatalf("CreateTag(work) created=%v err=%v", created, err)
+	}
+	if _, created, err := services.memoService.CreateTag(ctx, owner.ID, " work "); err != nil || created {
+		t.Fatalf("expected existing tag to be reported, created=%v err=%v", created, err)
+	}
+	if _, _, err := services.memoService.CreateTag(ctx, owner.ID, "collab/1"); !errors.Is(err, ErrInvalidTagName) {
+		t.Fatalf("expected collab/ tag creation to be rejected, got %v", err)
+	}
+
+	collabTag := fmt.Sprintf("collab/%d", collaborator.ID)
+	memo, err := services.memoService.CreateMemo(ctx, owner.ID, CreateMemoInput{
+		Content:    "shared",
+		Visibility: models.VisibilityPrivate,
+		Tags:       []string{"work", collabTag},
+	})
+	if err != nil {
+		t.Fatalf("CreateMemo(known tags) error = %v", err)
+	}
+
+	unknown := []string{"work", "personal", collabTag}
+	_, err = services.memoService.UpdateMemo(ctx, collaborator.ID, memo.Memo.ID, UpdateMemoInput{Tags: &unknown})
+	if !errors.Is(err, ErrUnknownTag) || !strings.Contains(err.Error(), "personal") {
+		t.Fatalf("expected collaborator update to be checked against creator tags, got %v", err)
+	}
+
+	// 显式创建的标签在不再被引用后仍保留
+	onlyCollab := []string{collabTag}
+	if _, err := services.memoService.UpdateMemo(ctx, owner.ID, memo.Memo.ID, UpdateMemoInput{Tags: &onlyCollab}); err != nil {
+		t.Fatalf("UpdateMemo(remove work) error = %v", err)
+	}
+	if _, err := services.memoService.CreateMemo(ctx, owner.ID, CreateMemoInput{Content: "again", Tags: []string{"work"}}); err != nil {
+		t.Fatalf("expected explicit tag to survive cleanup, got %v", err)
+	}
+
+	result, err := services.memoService.ImportMemos(ctx, owner.ID, models.VisibilityPrivate, strings.NewReader(`{"content":"imported","tags":["unknown"]}`+"\n"))
+	if err != nil {
+		t.Fatalf("ImportMemos() error = %v", err)
+	}
+	if result.Created != 0 || result.Skipped != 1 {
+		t.Fatalf("expected import with unknown tag to be skipped, got %+v", result)
+	}
+}
@@ -0,0 +1,77 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/shinyes/keer/internal/models"
+	"github.com/shinyes/keer/internal/store"
+)
+
+func TestMemoVisibility_ProtectedRequiresAuthenticatedViewer(t *testing.T) {
+	t.Parallel()
+
+	services := setupTestServices(t)
+	ctx := context.Background()
+	owner := mustCreateUser(t, services.store, "memo-visibility-owner")
+	member := mustCreateUser(t, services.store, "memo-visibility-member")
+
+	memoIDs := make(map[models.Visibility]int64)
+	for _, visibility := range []models.Visibility{models.VisibilityPublic, models.VisibilityProtected, models.VisibilityPrivate} {
+		created, err := services.memoService.CreateMemo(ctx, owner.ID, CreateMemoInput{
+			Content:    string(visibility) + " memo",
+			Visibility: visibility,
+			Tags:       []string{"visibility"},
+		})
+		if err != nil {
+			t.Fatalf("CreateMemo(%s) error = %v", visibility, err)
+		}
+		memoIDs[visibility] = created.Memo.ID
+	}
+
+	filter := fmt.Sprintf("creator_id == %d", owner.ID)
+	cases := []struct {
+		name     string
+		viewerID int64
+		visible  []models.Visibility
+	}{
+		{name: "creator", viewerID: owner.ID, visible: []models.Visibility{models.VisibilityPublic, models.VisibilityProtected, models.VisibilityPrivate}},
+		{name: "authenticated non-creator", viewerID: member.ID, visible: []models.Visibility{models.VisibilityPublic, models.VisibilityProtected}},
+		{name: "anonymous", viewerID: store.AnonymousViewerID, visible: []models.Visibility{models.VisibilityPublic}},
+	}
+	for _, tc := range cases {
+		listed, _, err := services.memoService.ListMemos(ctx, tc.viewerID, nil, filter, 50, "")
+		if err != nil {
+			t.Fatalf("%s: ListMemos() error = %v", tc.name, err)
+		}
+		if len(listed) != len(tc.visible) {
+			t.Fatalf("%s: expected %d memos, got %d", tc.name, len(tc.visible), len(listed))
+		}
+
+		tagCount, err := services.memoService.GetUserTagCount(ctx, owner.ID, tc.viewerID)
+		if err != nil {
+			t.Fatalf("%s: GetUserTagCount() error = %v", tc.name, err)
+		}
+		if tagCount["visibility"] != len(tc.visible) {
+			t.Fatalf("%s: expected tag count %d, got %d", tc.name, len(tc.visible), tagCount["visibility"])
+		}
+
+		visibleSet := make(map[models.Visibility]struct{}, len(tc.visible))
+		for _, visibility := range tc.visible {
+			visibleSet[visibility] = struct{}{}
+		}
+		for visibility, memoID := range memoIDs {
+			_, err := services.memoService.GetMemo(ctx, tc.viewerID, memoID)
+			if _, ok := visibleSet[visibility]; ok {
+				if err != nil {
+					t.Fatalf("%s: expected %s memo visible, got %v", tc.name, visibility, err)
+				}
+			} else if !errors.Is(err, sql.ErrNoRows) {
+				t.Fatalf("%s: expected %s memo hidden, got %v", tc.name, visibility, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,307 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"testing"
+
+	"github.com/shinyes/keer/internal/models"
+)
+
+func TestRebuildSearchIndex_RestoresDriftedIndex(t *testing.T) {
+	services := setupTestServices(t)
+	ctx := context.Background()
+	user := mustCreateUser(t, services.store, "search-reindex")
+
+	for _, content := range []string{"alpha note", "beta note", "gamma note"} {
+		if _, err := services.memoService.CreateMemo(ctx, user.ID, CreateMemoInput{Content: content}); err != nil {
+			t.Fatalf("CreateMemo() error = %v", err)
+		}
+	}
+
+	countMatches := func(query string) int {
+		t.Helper()
+		var count int
+		if err := services.store.DB().QueryRowContext(ctx, `SELECT COUNT(1) FROM memos_fts WHERE memos_fts MATCH ?`, query).Scan(&count); err != nil {
+			t.Fatalf("query memos_fts error = %v", err)
+		}
+		return count
+	}
+	if got := countMatches("note"); got != 3 {
+		t.Fatalf("expected triggers to index 3 memos, got %d", got)
+	}
+
+	if _, err := services.store.DB().ExecContext(ctx, `DELETE FROM memos_fts`); err != nil {
+		t.Fatalf("clear memos_fts error = %v", err)
+	}
+	var calls int
+	indexed, err := services.memoService.RebuildSearchIndex(ctx, func(indexed int64, total int64) {
+		calls++
+		if total != 3 {
+			t.Fatalf("expected progress total 3, got %d", total)
+		}
+	})
+	if err != nil {
+		t.Fatalf("RebuildSearchIndex() error = %v", err)
+	}
+	if indexed != 3 || calls == 0 {
+		t.Fatalf("expected 3 memos indexed with progress reported, got indexed=%d calls=%d", indexed, calls)
+	}
+	if got := countMatches("beta"); got != 1 {
+		t.Fatalf("expected rebuilt index to match beta once, got %d", got)
+	}
+}
+
+func TestSearchVisibleMemos_PrefixMatch(t *testing.T) {
+	services := setupTestServices(t)
+	ctx := context.Background()
+	user := mustCreateUser(t, services.store, "search-prefix")
+
+	for _, content := range []string{"todo: buy milk", "todolist for today", "nothing relevant"} {
+		if _, err := services.memoService.CreateMemo(ctx, user.ID, CreateMemoInput{Content: content}); err != nil {
+			t.Fatalf("CreateMemo() error = %v", err)
+		}
+	}
+
+	exact, _, err := services.memoService.SearchVisibleMemos(ctx, user.ID, nil, "todo", false, 10, "")
+	if err != nil {
+		t.Fatalf("SearchVisibleMemos(todo) error = %v", err)
+	}
+	if len(exact) != 1 || exact[0].Memo.Content != "todo: buy milk" {
+		t.Fatalf("expected exact term to match one memo, got %+v", exact)
+	}
+
+	prefixed, _, err := services.memoService.SearchVisibleMemos(ctx, user.ID, nil, "todo*", false, 10, "")
+	if err != nil {
+		t.Fatalf("SearchVisibleMemos(todo*) error = %v", err)
+	}
+	if len(prefixed) != 2 {
+		t.Fatalf("expected prefix query to match 2 memos, got %d", len(prefixed))
+	}
+
+	firstPage, nextToken, err := services.memoService.SearchVisibleMemos(ctx, user.ID, nil, "todo*", false, 1, "")
+	if err != nil {
+		t.Fatalf("SearchVisibleMemos(page 1) error = %v", err)
+	}
+	secondPage, lastToken, err := services.memoService.SearchVisibleMemos(ctx, user.ID, nil, "todo*", false, 1, nextToken)
+	if err != nil {
+		t.Fatalf("SearchVisibleMemos(page 2) error = %v", err)
+	}
+	if len(firstPage) != 1 || len(secondPage) != 1 || nextToken != "1" || lastToken != "" {
+		t.Fatalf("unexpected search paging: first=%d second=%d next=%q last=%q", len(firstPage), len(secondPage), nextToken, lastToken)
+	}
+	if firstPage[0].Memo.ID == secondPage[0].Memo.ID {
+		t.Fatalf("expected distinct memos across search pages")
+	}
+}
+
+func TestSearchVisibleMemos_EscapesSpecialCharacters(t *testing.T) {
+	services := setupTestServices(t)
+	ctx := context.Background()
+	user := mustCreateUser(t, services.store, "search-escape")
+
+	for _, content := range []string{`say "hello" NEAR the door`, "hello world"} {
+		if _, err := services.memoService.CreateMemo(ctx, user.ID, CreateMemoInput{Content: content}); err != nil {
+			t.Fatalf("CreateMemo() error = %v", err)
+		}
+	}
+
+	for _, query := range []string{`"hello`, `NEAR(hello door)`, `hello OR`, `content:hello`, `-hello ^door`, `*`} {
+		if _, _, err := services.memoService.SearchVisibleMemos(ctx, user.ID, nil, query, false, 10, ""); err != nil {
+			t.Fatalf("SearchVisibleMemos(%q) error = %v", query, err)
+		}
+	}
+
+	got, _, err := services.memoService.SearchVisibleMemos(ctx, user.ID, nil, `"hello" NEAR`, false, 10, "")
+	if err != nil {
+		t.Fatalf("SearchVisibleMemos() error = %v", err)
+	}
+	if len(got) != 1 || got[0].Memo.Content != `say "hello" NEAR the door` {
+		t.Fatalf("expected NEAR to be matched as a literal term, got %+v", got)
+	}
+}
+
+func TestSyncSearchTokenizer_RebuildsOnChange(t *testing.T) {
+	services := setupTestServices(t)
+	ctx := context.Background()
+	user := mustCreateUser(t, services.store, "search-tokenizer")
+
+	if _, err := services.memoService.CreateMemo(ctx, user.ID, CreateMemoInput{Content: "今天的待办事项"}); err != nil {
+		t.Fatalf("CreateMemo() error = %v", err)
+	}
+
+	rebuilt, err := services.memoService.SyncSearchTokenizer(ctx)
+	if err != nil {
+		t.Fatalf("SyncSearchTokenizer() error = %v", err)
+	}
+	if rebuilt {
+		t.Fatalf("expected no rebuild when tokenizer is unchanged")
+	}
+
+	if err := services.memoService.SetSearchTokenizer("trigram"); err != nil {
+		t.Fatalf("SetSearchTokenizer() error = %v", err)
+	}
+	rebuilt, err = services.memoService.SyncSearchTokenizer(ctx)
+	if err != nil {
+		t.Fatalf("SyncSearchTokenizer() error = %v", err)
+	}
+	if !rebuilt {
+		t.Fatalf("expected rebuild after tokenizer change")
+	}
+	tokenizer, err := services.store.MemoSearchIndexTokenizer(ctx)
+	if err != nil {
+		t.Fatalf("MemoSearchIndexTokenizer() error = %v", err)
+	}
+	if tokenizer != "trigram" {
+		t.Fatalf("expected trigram tokenizer, got %q", tokenizer)
+	}
+
+	got, _, err := services.memoService.SearchVisibleMemos(ctx, user.ID, nil, "待办事", false, 10, "")
+	if err != nil {
+		t.Fatalf("SearchVisibleMemos() error = %v", err)
+	}
+	if len(got) != 1 {
+		t.Fatalf("expected trigram tokenizer to match CJK substring, got %d", len(got))
+	}
+
+	if err := services.memoService.SetSearchTokenizer("icu"); err == nil {
+		t.Fatalf("expected unsupported tokenizer to be rejected")
+	}
+}
+
+func TestSanitizeFTSQuery(t *testing.T) {
+	cases := []struct {
+		raw      string
+		advanced bool
+		want     string
+	}{
+		{raw: "  ", want: ""},
+		{raw: "hello world", want: `"hello" "world"`},
+		{raw: "todo*", want: `"todo"*`},
+		{raw: "***", want: ""},
+		{raw: `say "hi"`, want: `"say" """hi"""`},
+		{raw: "a OR b NEAR(c)", want: `"a" "OR" "b" "NEAR(c)"`},
+		{raw: "content:x -y ^z", want: `"content:x" "-y" "^z"`},
+		{raw: " todo OR milk ", advanced: true, want: "todo OR milk"},
+	}
+	for _, tc := range cases {
+		if got := sanitizeFTSQuery(tc.raw, tc.advanced); got != tc.want {
+			t.Fatalf("sanitizeFTSQuery(%q, %v) = %q, want %q", tc.raw, tc.advanced, got, tc.want)
+		}
+	}
+}
+
+func TestSearchVisibleMemos_AdvancedMode(t *testing.T) {
+	services := setupTestServices(t)
+	ctx := context.Background()
+	user := mustCreateUser(t, services.store, "search-advanced")
+
+	for _, content := range []string{"buy milk", "write todo list", "read a book"} {
+		if _, err := services.memoService.CreateMemo(ctx, user.ID, CreateMemoInput{Content: content}); err != nil {
+			t.Fatalf("CreateMemo() error = %v", err)
+		}
+	}
+
+	got, _, err := services.memoService.SearchVisibleMemos(ctx, user.ID, nil, "milk OR todo", true, 10, "")
+	if err != nil {
+		t.Fatalf("SearchVisibleMemos(advanced) error = %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("expected advanced OR query to match 2 memos, got %d", len(got))
+	}
+
+	plain, _, err := services.memoService.SearchVisibleMemos(ctx, user.ID, nil, "milk OR todo", false, 10, "")
+	if err != nil {
+		t.Fatalf("SearchVisibleMemos(plain) error = %v", err)
+	}
+	if len(plain) != 0 {
+		t.Fatalf("expected plain query to treat OR as a literal term, got %d", len(plain))
+	}
+
+	for _, query := range []string{`"milk`, "AND", "milk OR", "NEAR(milk todo", "unknown:milk", "*"} {
+		_, _, err := services.memoService.SearchVisibleMemos(ctx, user.ID, nil, query, true, 10, "")
+		if !errors.Is(err, ErrInvalidSearchQuery) {
+			t.Fatalf("SearchVisibleMemos(%q, advanced) error = %v, want ErrInvalidSearchQuery", query, err)
+		}
+		if _, _, err := services.memoService.SearchVisibleMemos(ctx, user.ID, nil, query, false, 10, ""); err != nil {
+			t.Fatalf("SearchVisibleMemos(%q, plain) error = %v", query, err)
+		}
+	}
+}
+
+func TestSearchVisibleMemos_NeverLeaksOtherUsersPrivateMemos(t *testing.T) {
+	services := setupTestServices(t)
+	ctx := context.Background()
+	alice := mustCreateUser(t, services.store, "search-alice")
+	bob := mustCreateUser(t, services.store, "search-bob")
+
+	memos := []struct {
+		creator    models.User
+		content    string
+		visibility models.Visibility
+		tags       []string
+	}{
+		{creator: alice, content: "secret plan alice", visibility: models.VisibilityPrivate},
+		{creator: bob, content: "secret plan bob private", visibility: models.VisibilityPrivate},
+		{creator: bob, content: "secret plan bob public", visibility: models.VisibilityPublic},
+		{creator: bob, content: "secret plan bob shared", visibility: models.VisibilityPrivate, tags: []string{fmt.Sprintf("collab/%d", alice.ID)}},
+	}
+	for _, memo := range memos {
+		if _, err := services.memoService.CreateMemo(ctx, memo.creator.ID, CreateMemoInput{
+			Content:    memo.content,
+			Visibility: memo.visibility,
+			Tags:       memo.tags,
+		}); err != nil {
+			t.Fatalf("CreateMemo() error = %v", err)
+		}
+	}
+
+	searchContents := func(scope string) []string {
+		t.Helper()
+		if err := services.memoService.SetSearchScope(scope); err != nil {
+			t.Fatalf("SetSearchScope(%q) error = %v", scope, err)
+		}
+		got, _, err := services.memoService.SearchVisibleMemos(ctx, alice.ID, nil, "secret plan", false, 50, "")
+		if err != nil {
+			t.Fatalf("SearchVisibleMemos() error = %v", err)
+		}
+		contents := make([]string, 0, len(got))
+		for _, item := range got {
+			contents = append(contents, item.Memo.Content)
+		}
+		sort.Strings(contents)
+		return contents
+	}
+
+	listed, _, err := services.memoService.ListMemos(ctx, alice.ID, nil, "", 50, "")
+	if err != nil {
+		t.Fatalf("ListMemos() error = %v", err)
+	}
+	listedContents := make([]string, 0, len(listed))
+	for _, item := range listed {
+		listedContents = append(listedContents, item.Memo.Content)
+	}
+	sort.Strings(listedContents)
+
+	visible := searchContents("visible")
+	if fmt.Sprint(visible) != fmt.Sprint(listedContents) {
+		t.Fatalf("expected search to see exactly the listed memos %v, got %v", listedContents, visible)
+	}
+	own := searchContents("own")
+	if fmt.Sprint(own) != fmt.Sprint([]string{"secret plan alice"}) {
+		t.Fatalf("expected own scope to return only alice's memo, got %v", own)
+	}
+	for _, contents := range [][]string{visible, own} {
+		for _, content := range contents {
+			if content == "secret plan bob private" {
+				t.Fatalf("bob's private memo leaked into alice's search results: %v", contents)
+			}
+		}
+	}
+
+	if err := services.memoService.SetSearchScope("everyone"); err == nil {
+		t.Fatalf("expected unsupported search scope to be rejected")
+	}
+}
@@ -0,0 +1,243 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"strings"
+
+	"github.com/shinyes/keer/internal/config"
+	"github.com/shinyes/keer/internal/storage"
+)
+
+const storageMigrationPageSize = 200
+
+// StorageMigrationResult 汇总一次存储迁移。去重后多条附件可能共用同一个对象，Copied 统计实际复制的对象数。
+type StorageMigrationResult struct {
+	Migrated int
+	Skipped  int
+	Failed   int
+	Copied   int
+	Avatars  int
+}
+
+// MigrateAttachments 把 from 后端上的附件（含缩略图）与头像复制到 to 后端，并改写附件记录的存储类型；
+// deleteSource 为 true 时在对应记录全部改写成功后删除源对象。
+// 已在目标后端的附件会被跳过，中途失败或服务期间新上传的附件可以重跑补齐。
+// logf 非空时用于输出进度和单条失败原因。
+func (s *StorageSettingsService) MigrateAttachments(
+	ctx context.Context,
+	from config.StorageBackend,
+	to config.StorageBackend,
+	deleteSource bool,
+	logf func(format string, args ...any),
+) (StorageMigrationResult, error) {
+	fromType, err := attachmentStorageType(from)
+	if err != nil {
+		return StorageMigrationResult{}, err
+	}
+	toType, err := attachmentStorageType(to)
+	if err != nil {
+		return StorageMigrationResult{}, err
+	}
+	if fromType == toType {
+		return StorageMigrationResult{}, fmt.Errorf("cannot migrate from %s to %s: attachments on both backends are recorded as %s", from, to, fromType)
+	}
+	src, err := s.OpenStore(ctx, from)
+	if err != nil {
+		return StorageMigrationResult{}, fmt.Errorf("open source storage %s: %w", from, err)
+	}
+	dst, err := s.OpenStore(ctx, to)
+	if err != nil {
+		return StorageMigrationResult{}, fmt.Errorf("open target storage %s: %w", to, err)
+	}
+	return s.migrateAttachments(ctx, src, dst, fromType, toType, deleteSource, logf)
+}
+
+func (s *StorageSettingsService) migrateAttachments(
+	ctx context.Context,
+	src storage.Store,
+	dst storage.Store,
+	fromType string,
+	toType string,
+	deleteSource bool,
+	logf func(format string, args ...any),
+) (StorageMigrationResult, error) {
+	if logf == nil {
+		logf = func(string, ...any) {}
+	}
+	var result StorageMigrationResult
+	copied := map[string]bool{}
+	// 任一引用该对象的记录迁移失败，源对象都要保留
+	keepSource := map[string]bool{}
+	copyOnce := func(key string, contentType string, size int64) error {
+		if copied[key] {
+			return nil
+		}
+		if err := copyStorageObject(ctx, src, dst, key, contentType, size); err != nil {
+			return err
+		}
+		copied[key] = true
+		result.Copied++
+		return nil
+	}
+
+	var afterID int64
+	for {
+		attachments, err := s.store.ListAttachmentsAfterID(ctx, afterID, storageMigrationPageSize)
+		if err != nil {
+			return result, err
+		}
+		if len(attachments) == 0 {
+			break
+		}
+		for _, attachment := range attachments {
+			afterID = attachment.ID
+			storageType := attachment.StorageType
+			thumbnailType := attachment.ThumbnailStorageType
+			if strings.TrimSpace(thumbnailType) == "" {
+				thumbnailType = storageType
+			}
+			moveMain := attachment.StorageKey != "" && strings.EqualFold(storageType, fromType)
+			moveThumbnail := attachment.ThumbnailStorageKey != "" && strings.EqualFold(thumbnailType, fromType)
+			if !moveMain && !moveThumbnail {
+				result.Skipped++
+				continue
+			}
+
+			if moveMain {
+				if err := copyOnce(attachment.StorageKey, attachment.Type, attachment.Size); err != nil {
+					logf("attachment %d: copy %s failed: %v", attachment.ID, attachment.StorageKey, err)
+					keepSource[attachment.StorageKey] = true
+					keepSource[attachment.ThumbnailStorageKey] = true
+					result.Failed++
+					continue
+				}
+				storageType = toType
+			}
+			if moveThumbnail {
+				if err := copyOnce(attachment.ThumbnailStorageKey, attachment.ThumbnailType, attachment.ThumbnailSize); err != nil {
+					logf("attachment %d: copy thumbnail %s failed: %v", attachment.ID, attachment.ThumbnailStorageKey, err)
+					keepSource[attachment.StorageKey] = true
+					keepSource[attachment.ThumbnailStorageKey] = true
+					result.Failed++
+					continue
+				}
+				thumbnailType = toType
+			}
+			if attachment.ThumbnailStorageKey == "" {
+				thumbnailType = attachment.ThumbnailStorageType
+			}
+			if err := s.store.UpdateAttachmentStorage(ctx, attachment.ID, storageType, attachment.StorageKey, thumbnailType, attachment.ThumbnailStorageKey); err != nil {
+				return result, fmt.Errorf("update attachment %d storage: %w", attachment.ID, err)
+			}
+			result.Migrated++
+			if result.Migrated%100 == 0 {
+				logf("migrated %d attachments", result.Migrated)
+			}
+		}
+	}
+
+	avatarKeys, err := s.migrateAvatars(ctx, src, dst, logf)
+	result.Avatars = len(avatarKeys)
+	if err != nil {
+		return result, err
+	}
+
+	if deleteSource {
+		for key := range copied {
+			if keepSource[key] {
+				continue
+			}
+			if err := src.Delete(ctx, key); err != nil {
+				logf("delete source %s failed: %v", key, err)
+			}
+		}
+		for _, key := range avatarKeys {
+			if err := src.Delete(ctx, key); err != nil {
+				logf("delete source %s failed: %v", key, err)
+			}
+		}
+	}
+	return result, nil
+}
+
+// migrateAvatars 复制设置了头像的用户的头像对象。头像没有记录所在后端，源上不存在时视为已迁移；返回已复制的键。
+func (s *StorageSettingsService) migrateAvatars(ctx context.Context, src storage.Store, dst storage.Store, logf func(format string, args ...any)) ([]string, error) {
+	var keys []string
+	for offset := 0; ; offset += storageMigrationPageSize {
+		users, err := s.store.ListUsers(ctx, storageMigrationPageSize, offset)
+		if err != nil {
+			return keys, err
+		}
+		for _, user := range users {
+			if strings.TrimSpace(user.AvatarURL) != avatarPublicURL(user.ID) {
+				continue
+			}
+			key := avatarStorageKey(user.ID)
+			if err := copyStorageObject(ctx, src, dst, key, "", -1); err != nil {
+				if !errors.Is(err, fs.ErrNotExist) {
+					logf("avatar of user %d: copy failed: %v", user.ID, err)
+				}
+				continue
+			}
+			keys = append(keys, key)
+		}
+		if len(users) < storageMigrationPageSize {
+			return keys, nil
+		}
+	}
+}
+
+// copyStorageObject 把 key 从 src 流式复制到 dst；大小未知时先读入内存，以便上传时带上 Content-Length 并识别类型。
+func copyStorageObject(ctx context.Context, src storage.Store, dst storage.Store, key string, contentType string, size int64) error {
+	rc, err := src.Open(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	if size > 0 {
+		_, err = dst.PutStream(ctx, key, contentType, rc, size)
+		return err
+	}
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		return err
+	}
+	if contentType == "" {
+		contentType = http.DetectContentType(data)
+	}
+	_, err = dst.PutStream(ctx, key, contentType, bytes.NewReader(data), int64(len(data)))
+	return err
+}
+
+func attachmentStorageType(backend config.StorageBackend) (string, error) {
+	switch backend {
+	case config.StorageBackendLocal:
+		return storageTypeLocal, nil
+	case config.StorageBackendS3:
+		return storageTypeS3, nil
+	case config.StorageBackendGCS:
+		return storageTypeGCS, nil
+	default:
+		return "", fmt.Errorf("unsupported storage backend %q", backend)
+	}
+}
+
+// storageBackendForType 为 attachmentStorageType 的逆映射。
+func storageBackendForType(storageType string) (config.StorageBackend, error) {
+	switch strings.ToUpper(strings.TrimSpace(storageType)) {
+	case storageTypeLocal:
+		return config.StorageBackendLocal, nil
+	case storageTypeS3:
+		return config.StorageBackendS3, nil
+	case storageTypeGCS:
+		return config.StorageBackendGCS, nil
+	default:
+		return "", fmt.Errorf("unsupported attachment storage type %q", storageType)
+	}
+}
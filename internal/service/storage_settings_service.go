@@ -5,30 +5,40 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"path/filepath"
 	"strconv"
 	"strings"
 
 	"github.com/shinyes/keer/internal/config"
+	"github.com/shinyes/keer/internal/storage"
 	"github.com/shinyes/keer/internal/store"
 )
 
 const (
 	settingKeyStorageBackend    = "storage_backend"
+	settingKeyStorageLocalPath  = "storage_local_path"
 	settingKeyStorageS3Endpoint = "storage_s3_endpoint"
 	settingKeyStorageS3Region   = "storage_s3_region"
 	settingKeyStorageS3Bucket   = "storage_s3_bucket"
 	settingKeyStorageS3KeyID    = "storage_s3_access_key_id"
 	settingKeyStorageS3Secret   = "storage_s3_access_key_secret"
 	settingKeyStorageS3Path     = "storage_s3_use_path_style"
+	settingKeyStorageGCSBucket  = "storage_gcs_bucket"
+	settingKeyStorageGCSCreds   = "storage_gcs_credentials_file"
 )
 
 type StorageSettings struct {
 	Backend config.StorageBackend
-	S3      config.S3Config
+	// LocalPath 为 local 后端的存储目录：数据库中保存的路径优先，未设置时为 UPLOADS_DIR。
+	LocalPath string
+	S3        config.S3Config
+	GCS       config.GCSConfig
 }
 
 type StorageSettingsService struct {
 	store *store.SQLStore
+	// uploadsDir 为 local 后端的存储目录，供 CheckBackend 使用。
+	uploadsDir string
 }
 
 func NewStorageSettingsService(s *store.SQLStore) *StorageSettingsService {
@@ -41,22 +51,108 @@ func (s *StorageSettingsService) Resolve(ctx context.Context) (StorageSettings,
 		return StorageSettings{}, err
 	}
 
+	localPath, err := s.resolveLocalPath(ctx)
+	if err != nil {
+		return StorageSettings{}, err
+	}
 	resolved := StorageSettings{
-		Backend: backend,
+		Backend:   backend,
+		LocalPath: localPath,
 	}
-	if backend == config.StorageBackendLocal {
-		return resolved, nil
+	switch backend {
+	case config.StorageBackendS3:
+		s3Cfg, err := s.resolveS3Config(ctx)
+		if err != nil {
+			return StorageSettings{}, err
+		}
+		resolved.S3 = s3Cfg
+	case config.StorageBackendGCS:
+		gcsCfg, err := s.resolveGCSConfig(ctx)
+		if err != nil {
+			return StorageSettings{}, err
+		}
+		resolved.GCS = gcsCfg
 	}
+	return resolved, nil
+}
+
+// SetUploadsDir 设置 local 后端的默认目录（UPLOADS_DIR），数据库中保存了路径时以后者为准。
+func (s *StorageSettingsService) SetUploadsDir(dir string) {
+	s.uploadsDir = dir
+}
 
-	s3Cfg, err := s.resolveS3Config(ctx)
+// OpenBackend 按数据库中保存的设置（而非运行中的后端）构造存储，用于修改配置后、重启前确认。
+func (s *StorageSettingsService) OpenBackend(ctx context.Context) (storage.Store, error) {
+	backend, err := s.resolveBackend(ctx)
 	if err != nil {
-		return StorageSettings{}, err
+		return nil, err
 	}
-	resolved.S3 = s3Cfg
-	return resolved, nil
+	return s.OpenStore(ctx, backend)
 }
 
-func (s *StorageSettingsService) SetLocal(ctx context.Context) error {
+// OpenStore 按已保存的设置构造指定后端的存储，与当前选用的后端无关；用于迁移时同时访问新旧两个后端。
+func (s *StorageSettingsService) OpenStore(ctx context.Context, backend config.StorageBackend) (storage.Store, error) {
+	switch backend {
+	case config.StorageBackendLocal:
+		localPath, err := s.resolveLocalPath(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return storage.NewLocalStore(localPath)
+	case config.StorageBackendS3:
+		s3Cfg, err := s.resolveS3Config(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return storage.NewS3Store(ctx, s3Cfg)
+	case config.StorageBackendGCS:
+		gcsCfg, err := s.resolveGCSConfig(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return storage.NewGCSStore(ctx, gcsCfg)
+	default:
+		return nil, fmt.Errorf("unsupported storage backend %q", backend)
+	}
+}
+
+// OpenStoreForType 按附件记录的存储类型（LOCAL/S3/GCS）以已保存的配置打开对应后端。
+func (s *StorageSettingsService) OpenStoreForType(ctx context.Context, storageType string) (storage.Store, error) {
+	backend, err := storageBackendForType(storageType)
+	if err != nil {
+		return nil, err
+	}
+	return s.OpenStore(ctx, backend)
+}
+
+// CheckBackend 检查已保存的存储设置对应的后端是否可用。
+func (s *StorageSettingsService) CheckBackend(ctx context.Context) error {
+	backend, err := s.OpenBackend(ctx)
+	if err != nil {
+		return err
+	}
+	return backend.Check(ctx)
+}
+
+// SetLocal 切换到 local 后端；path 非空时先确认目录可创建且可写，再一并保存为新的存储目录，为空则沿用已保存的目录。
+func (s *StorageSettingsService) SetLocal(ctx context.Context, path string) error {
+	path = strings.TrimSpace(path)
+	if path != "" {
+		absPath, err := filepath.Abs(path)
+		if err != nil {
+			return fmt.Errorf("resolve local storage path: %w", err)
+		}
+		localStore, err := storage.NewLocalStore(absPath)
+		if err != nil {
+			return err
+		}
+		if err := localStore.Check(ctx); err != nil {
+			return err
+		}
+		if err := s.store.UpsertSetting(ctx, settingKeyStorageLocalPath, absPath); err != nil {
+			return err
+		}
+	}
 	return s.store.UpsertSetting(ctx, settingKeyStorageBackend, string(config.StorageBackendLocal))
 }
 
@@ -92,6 +188,39 @@ func (s *StorageSettingsService) SetS3(ctx context.Context, cfg config.S3Config)
 	return s.store.UpsertSetting(ctx, settingKeyStorageBackend, string(config.StorageBackendS3))
 }
 
+// SetGCS 保存 GCS 配置；凭证文件路径转为绝对路径保存，并在保存前确认文件是可用的服务账号密钥。
+func (s *StorageSettingsService) SetGCS(ctx context.Context, cfg config.GCSConfig) error {
+	normalized := config.GCSConfig{
+		Bucket:          strings.TrimSpace(cfg.Bucket),
+		CredentialsFile: strings.TrimSpace(cfg.CredentialsFile),
+	}
+	if err := normalized.Validate(); err != nil {
+		return err
+	}
+	absPath, err := filepath.Abs(normalized.CredentialsFile)
+	if err != nil {
+		return fmt.Errorf("resolve gcs credentials file: %w", err)
+	}
+	normalized.CredentialsFile = absPath
+	if _, err := storage.NewGCSStore(ctx, normalized); err != nil {
+		return err
+	}
+
+	settings := []struct {
+		key   string
+		value string
+	}{
+		{settingKeyStorageGCSBucket, normalized.Bucket},
+		{settingKeyStorageGCSCreds, normalized.CredentialsFile},
+	}
+	for _, item := range settings {
+		if err := s.store.UpsertSetting(ctx, item.key, item.value); err != nil {
+			return err
+		}
+	}
+	return s.store.UpsertSetting(ctx, settingKeyStorageBackend, string(config.StorageBackendGCS))
+}
+
 func (s *StorageSettingsService) resolveBackend(ctx context.Context) (config.StorageBackend, error) {
 	raw, err := s.store.GetSetting(ctx, settingKeyStorageBackend)
 	if err != nil {
@@ -106,31 +235,45 @@ func (s *StorageSettingsService) resolveBackend(ctx context.Context) (config.Sto
 
 	backend := config.StorageBackend(strings.ToLower(strings.TrimSpace(raw)))
 	switch backend {
-	case config.StorageBackendLocal, config.StorageBackendS3:
+	case config.StorageBackendLocal, config.StorageBackendS3, config.StorageBackendGCS:
 		return backend, nil
 	default:
 		return "", fmt.Errorf("unsupported storage backend %q in setting %s", raw, settingKeyStorageBackend)
 	}
 }
 
+func (s *StorageSettingsService) resolveLocalPath(ctx context.Context) (string, error) {
+	raw, err := s.store.GetSetting(ctx, settingKeyStorageLocalPath)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return s.uploadsDir, nil
+		}
+		return "", err
+	}
+	if value := strings.TrimSpace(raw); value != "" {
+		return value, nil
+	}
+	return s.uploadsDir, nil
+}
+
 func (s *StorageSettingsService) resolveS3Config(ctx context.Context) (config.S3Config, error) {
-	endpoint, err := s.getRequiredSetting(ctx, settingKeyStorageS3Endpoint)
+	endpoint, err := s.getRequiredSetting(ctx, config.StorageBackendS3, settingKeyStorageS3Endpoint)
 	if err != nil {
 		return config.S3Config{}, err
 	}
-	region, err := s.getRequiredSetting(ctx, settingKeyStorageS3Region)
+	region, err := s.getRequiredSetting(ctx, config.StorageBackendS3, settingKeyStorageS3Region)
 	if err != nil {
 		return config.S3Config{}, err
 	}
-	bucket, err := s.getRequiredSetting(ctx, settingKeyStorageS3Bucket)
+	bucket, err := s.getRequiredSetting(ctx, config.StorageBackendS3, settingKeyStorageS3Bucket)
 	if err != nil {
 		return config.S3Config{}, err
 	}
-	accessKeyID, err := s.getRequiredSetting(ctx, settingKeyStorageS3KeyID)
+	accessKeyID, err := s.getRequiredSetting(ctx, config.StorageBackendS3, settingKeyStorageS3KeyID)
 	if err != nil {
 		return config.S3Config{}, err
 	}
-	accessSecret, err := s.getRequiredSetting(ctx, settingKeyStorageS3Secret)
+	accessSecret, err := s.getRequiredSetting(ctx, config.StorageBackendS3, settingKeyStorageS3Secret)
 	if err != nil {
 		return config.S3Config{}, err
 	}
@@ -153,17 +296,32 @@ func (s *StorageSettingsService) resolveS3Config(ctx context.Context) (config.S3
 	return cfg, nil
 }
 
-func (s *StorageSettingsService) getRequiredSetting(ctx context.Context, key string) (string, error) {
+func (s *StorageSettingsService) resolveGCSConfig(ctx context.Context) (config.GCSConfig, error) {
+	bucket, err := s.getRequiredSetting(ctx, config.StorageBackendGCS, settingKeyStorageGCSBucket)
+	if err != nil {
+		return config.GCSConfig{}, err
+	}
+	credentialsFile, err := s.getRequiredSetting(ctx, config.StorageBackendGCS, settingKeyStorageGCSCreds)
+	if err != nil {
+		return config.GCSConfig{}, err
+	}
+	return config.GCSConfig{
+		Bucket:          bucket,
+		CredentialsFile: credentialsFile,
+	}, nil
+}
+
+func (s *StorageSettingsService) getRequiredSetting(ctx context.Context, backend config.StorageBackend, key string) (string, error) {
 	raw, err := s.store.GetSetting(ctx, key)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return "", fmt.Errorf("setting %s is required when storage backend is s3", key)
+			return "", fmt.Errorf("setting %s is required when storage backend is %s", key, backend)
 		}
 		return "", err
 	}
 	value := strings.TrimSpace(raw)
 	if value == "" {
-		return "", fmt.Errorf("setting %s is required when storage backend is s3", key)
+		return "", fmt.Errorf("setting %s is required when storage backend is %s", key, backend)
 	}
 	return value, nil
 }
@@ -2,10 +2,19 @@ package service
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
 	"github.com/shinyes/keer/internal/config"
+	"github.com/shinyes/keer/internal/storage"
 )
 
 func TestStorageSettingsResolveDefaultLocal(t *testing.T) {
@@ -59,6 +68,73 @@ func TestStorageSettingsSetS3AndResolve(t *testing.T) {
 	}
 }
 
+func TestStorageSettingsSetGCSAndResolve(t *testing.T) {
+	services := setupTestServices(t)
+	storageService := NewStorageSettingsService(services.store)
+	ctx := context.Background()
+
+	if err := storageService.SetGCS(ctx, config.GCSConfig{Bucket: "memos"}); err == nil {
+		t.Fatalf("expected SetGCS() to reject missing credentials file")
+	}
+	missing := filepath.Join(t.TempDir(), "missing.json")
+	if err := storageService.SetGCS(ctx, config.GCSConfig{Bucket: "memos", CredentialsFile: missing}); err == nil {
+		t.Fatalf("expected SetGCS() to reject unreadable credentials file")
+	}
+
+	want := config.GCSConfig{
+		Bucket:          "memos",
+		CredentialsFile: writeTestGCSCredentials(t),
+	}
+	if err := storageService.SetGCS(ctx, want); err != nil {
+		t.Fatalf("SetGCS() error = %v", err)
+	}
+
+	resolved, err := storageService.Resolve(ctx)
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+	if resolved.Backend != config.StorageBackendGCS {
+		t.Fatalf("expected gcs backend, got %s", resolved.Backend)
+	}
+	if resolved.GCS != want {
+		t.Fatalf("resolved gcs config mismatch: got %+v want %+v", resolved.GCS, want)
+	}
+	backend, err := storageService.OpenBackend(ctx)
+	if err != nil {
+		t.Fatalf("OpenBackend() error = %v", err)
+	}
+	if storageTypeName(backend) != storageTypeGCS {
+		t.Fatalf("expected gcs store, got %T", backend)
+	}
+}
+
+// writeTestGCSCredentials 生成一个临时的服务账号密钥文件。
+func writeTestGCSCredentials(t *testing.T) string {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("MarshalPKCS8PrivateKey() error = %v", err)
+	}
+	data, err := json.Marshal(map[string]string{
+		"type":         "service_account",
+		"client_email": "keer@test-project.iam.gserviceaccount.com",
+		"private_key":  string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		"token_uri":    "https://oauth2.googleapis.com/token",
+	})
+	if err != nil {
+		t.Fatalf("marshal credentials: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "credentials.json")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("write credentials: %v", err)
+	}
+	return path
+}
+
 func TestStorageSettingsSetLocal(t *testing.T) {
 	services := setupTestServices(t)
 	storageService := NewStorageSettingsService(services.store)
@@ -75,7 +151,7 @@ func TestStorageSettingsSetLocal(t *testing.T) {
 		t.Fatalf("SetS3() error = %v", err)
 	}
 
-	if err := storageService.SetLocal(ctx); err != nil {
+	if err := storageService.SetLocal(ctx, ""); err != nil {
 		t.Fatalf("SetLocal() error = %v", err)
 	}
 
@@ -88,6 +164,48 @@ func TestStorageSettingsSetLocal(t *testing.T) {
 	}
 }
 
+func TestStorageSettingsSetLocalPath(t *testing.T) {
+	services := setupTestServices(t)
+	storageService := NewStorageSettingsService(services.store)
+	storageService.SetUploadsDir("./data/uploads")
+	ctx := context.Background()
+
+	resolved, err := storageService.Resolve(ctx)
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+	if resolved.LocalPath != "./data/uploads" {
+		t.Fatalf("expected UPLOADS_DIR fallback, got %q", resolved.LocalPath)
+	}
+
+	mount := filepath.Join(t.TempDir(), "mnt", "uploads")
+	if err := storageService.SetLocal(ctx, mount); err != nil {
+		t.Fatalf("SetLocal(path) error = %v", err)
+	}
+	if info, err := os.Stat(mount); err != nil || !info.IsDir() {
+		t.Fatalf("expected local path to be created, stat err=%v", err)
+	}
+
+	blocker := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(blocker, []byte("x"), 0o644); err != nil {
+		t.Fatalf("write blocker failed: %v", err)
+	}
+	if err := storageService.SetLocal(ctx, filepath.Join(blocker, "uploads")); err == nil {
+		t.Fatalf("expected SetLocal() to reject unusable path")
+	}
+	if err := storageService.SetLocal(ctx, ""); err != nil {
+		t.Fatalf("SetLocal() error = %v", err)
+	}
+
+	resolved, err = storageService.Resolve(ctx)
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+	if resolved.LocalPath != mount {
+		t.Fatalf("expected saved local path %q to be kept, got %q", mount, resolved.LocalPath)
+	}
+}
+
 func TestStorageSettingsResolveS3MissingField(t *testing.T) {
 	services := setupTestServices(t)
 	storageService := NewStorageSettingsService(services.store)
@@ -117,3 +235,102 @@ func TestStorageSettingsResolveS3MissingField(t *testing.T) {
 		t.Fatalf("expected missing endpoint error, got %v", err)
 	}
 }
+
+func TestStorageSettingsMigrateAttachments(t *testing.T) {
+	services := setupTestServices(t)
+	storageService := NewStorageSettingsService(services.store)
+	ctx := context.Background()
+
+	src, err := storage.NewLocalStore(filepath.Join(t.TempDir(), "src"))
+	if err != nil {
+		t.Fatalf("NewLocalStore(src) error = %v", err)
+	}
+	dst, err := storage.NewLocalStore(filepath.Join(t.TempDir(), "dst"))
+	if err != nil {
+		t.Fatalf("NewLocalStore(dst) error = %v", err)
+	}
+	attachmentService := NewAttachmentService(services.store, src, 0)
+	user := mustCreateUser(t, services.store, "storage-migrate")
+
+	image, err := attachmentService.CreateAttachment(ctx, user.ID, CreateAttachmentInput{
+		Filename: "scene.jpg",
+		Type:     "image/jpeg",
+		Content:  base64.StdEncoding.EncodeToString(generateTestJPEGBytes(t, 800, 600)),
+	})
+	if err != nil {
+		t.Fatalf("CreateAttachment(image) error = %v", err)
+	}
+	if image.ThumbnailStorageKey == "" {
+		t.Fatalf("expected image thumbnail")
+	}
+	text, err := attachmentService.CreateAttachment(ctx, user.ID, CreateAttachmentInput{
+		Filename: "notes.txt",
+		Type:     "text/plain",
+		Content:  base64.StdEncoding.EncodeToString([]byte("hello migration")),
+	})
+	if err != nil {
+		t.Fatalf("CreateAttachment(text) error = %v", err)
+	}
+	if _, err := src.Put(ctx, avatarStorageKey(user.ID), "image/png", []byte("avatar")); err != nil {
+		t.Fatalf("Put(avatar) error = %v", err)
+	}
+	if _, err := services.store.UpdateUserAvatar(ctx, user.ID, avatarPublicURL(user.ID)); err != nil {
+		t.Fatalf("UpdateUserAvatar() error = %v", err)
+	}
+
+	result, err := storageService.migrateAttachments(ctx, src, dst, "LOCAL", "S3", true, nil)
+	if err != nil {
+		t.Fatalf("migrateAttachments() error = %v", err)
+	}
+	if result.Migrated != 2 || result.Failed != 0 || result.Copied != 3 || result.Avatars != 1 {
+		t.Fatalf("unexpected migration result: %+v", result)
+	}
+	for _, key := range []string{image.StorageKey, image.ThumbnailStorageKey, text.StorageKey, avatarStorageKey(user.ID)} {
+		rc, err := dst.Open(ctx, key)
+		if err != nil {
+			t.Fatalf("expected %s on target, err=%v", key, err)
+		}
+		_ = rc.Close()
+		if _, err := src.Open(ctx, key); !os.IsNotExist(err) {
+			t.Fatalf("expected %s removed from source, err=%v", key, err)
+		}
+	}
+	migrated, err := services.store.GetAttachmentByID(ctx, image.ID)
+	if err != nil {
+		t.Fatalf("GetAttachmentByID() error = %v", err)
+	}
+	if migrated.StorageType != "S3" || migrated.ThumbnailStorageType != "S3" || migrated.StorageKey != image.StorageKey {
+		t.Fatalf("unexpected migrated attachment: %+v", migrated)
+	}
+
+	rerun, err := storageService.migrateAttachments(ctx, src, dst, "LOCAL", "S3", true, nil)
+	if err != nil {
+		t.Fatalf("second migrateAttachments() error = %v", err)
+	}
+	if rerun.Migrated != 0 || rerun.Skipped != 2 || rerun.Copied != 0 {
+		t.Fatalf("expected rerun to skip migrated attachments, got %+v", rerun)
+	}
+
+	// S3 与 GCS 记录为不同的存储类型，两者之间可以互相迁移
+	gcsDst, err := storage.NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocalStore() error = %v", err)
+	}
+	toGCS, err := storageService.migrateAttachments(ctx, dst, gcsDst, storageTypeS3, storageTypeGCS, false, nil)
+	if err != nil {
+		t.Fatalf("migrateAttachments(S3 -> GCS) error = %v", err)
+	}
+	if toGCS.Migrated != 2 || toGCS.Failed != 0 {
+		t.Fatalf("unexpected s3 -> gcs migration result: %+v", toGCS)
+	}
+	migrated, err = services.store.GetAttachmentByID(ctx, image.ID)
+	if err != nil {
+		t.Fatalf("GetAttachmentByID() error = %v", err)
+	}
+	if migrated.StorageType != storageTypeGCS || migrated.ThumbnailStorageType != storageTypeGCS {
+		t.Fatalf("expected attachment recorded as GCS, got %+v", migrated)
+	}
+	if _, err := storageService.MigrateAttachments(ctx, config.StorageBackendGCS, config.StorageBackendGCS, false, nil); err == nil {
+		t.Fatalf("expected migration between identical backends to be rejected")
+	}
+}
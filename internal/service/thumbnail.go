@@ -13,6 +13,9 @@ import (
 
 	"github.com/shinyes/keer/internal/models"
 
+	// 标准库之外再注册 BMP 与 WebP 解码器；HEIC/HEIF/AVIF 仍无解码器，image.Decode 失败时跳过缩略图生成
+	_ "golang.org/x/image/bmp"
+	_ "golang.org/x/image/webp"
 	_ "image/gif"
 	_ "image/png"
 )
@@ -37,6 +40,41 @@ var thumbnailImageExtensions = map[string]struct{}{
 	".avif": {},
 }
 
+type thumbnailEncoder func(w io.Writer, img image.Image) error
+
+func baselineJPEGThumbnailEncoder(w io.Writer, img image.Image) error {
+	return jpeg.Encode(w, img, &jpeg.Options{Quality: thumbnailJPEGQuality})
+}
+
+func progressiveJPEGThumbnailEncoder(w io.Writer, img image.Image) error {
+	return encodeProgressiveJPEG(w, img, thumbnailJPEGQuality)
+}
+
+// SetThumbnailsDisabled 关闭服务端缩略图生成，图片附件的缩略图接口改为直接返回原图。
+func (s *AttachmentService) SetThumbnailsDisabled(disabled bool) {
+	s.thumbnailsDisabled = disabled
+}
+
+// SetThumbnailProgressive 切换 JPEG 缩略图的渐进式编码，只影响之后生成的缩略图。
+func (s *AttachmentService) SetThumbnailProgressive(progressive bool) {
+	s.thumbnailProgressive = progressive
+}
+
+// ServesOriginalAsThumbnail 判断附件没有缩略图时是否以原图代替：仅在关闭缩略图生成时对图片附件生效。
+func (s *AttachmentService) ServesOriginalAsThumbnail(attachment models.Attachment) bool {
+	return s.thumbnailsDisabled &&
+		strings.TrimSpace(attachment.ThumbnailStorageKey) == "" &&
+		shouldGenerateThumbnail(attachment.Type, attachment.Filename)
+}
+
+// thumbnailEncoding 返回服务端生成缩略图的类型与编码器；缩略图始终为 JPEG，开启渐进式时改用渐进编码。
+func (s *AttachmentService) thumbnailEncoding() (string, thumbnailEncoder) {
+	if s.thumbnailProgressive {
+		return thumbnailContentType, progressiveJPEGThumbnailEncoder
+	}
+	return thumbnailContentType, baselineJPEGThumbnailEncoder
+}
+
 func thumbnailStorageKey(storageKey string) string {
 	storageKey = strings.TrimSpace(storageKey)
 	if storageKey == "" {
@@ -101,13 +139,14 @@ func (s *AttachmentService) ensureThumbnailFromBytes(
 	filename string,
 	data []byte,
 ) {
-	if !shouldGenerateThumbnail(contentType, filename) {
+	if s.thumbnailsDisabled || !shouldGenerateThumbnail(contentType, filename) {
 		return
 	}
 	if len(data) == 0 || len(data) > thumbnailMaxSourceSize {
 		return
 	}
-	thumbnailData, err := buildThumbnailJPEG(bytes.NewReader(data))
+	thumbnailType, encoder := s.thumbnailEncoding()
+	thumbnailData, err := buildThumbnail(bytes.NewReader(data), encoder)
 	if err != nil || len(thumbnailData) == 0 {
 		return
 	}
@@ -115,7 +154,7 @@ func (s *AttachmentService) ensureThumbnailFromBytes(
 	if thumbnailKey == "" {
 		return
 	}
-	thumbnailSize, err := s.storage.Put(ctx, thumbnailKey, thumbnailContentType, thumbnailData)
+	thumbnailSize, err := s.storage.Put(ctx, thumbnailKey, thumbnailType, thumbnailData)
 	if err != nil || thumbnailSize <= 0 {
 		return
 	}
@@ -123,7 +162,7 @@ func (s *AttachmentService) ensureThumbnailFromBytes(
 		ctx,
 		attachment.ID,
 		buildThumbnailFilename(filename),
-		thumbnailContentType,
+		thumbnailType,
 		thumbnailSize,
 		storageTypeName(s.storage),
 		thumbnailKey,
@@ -137,7 +176,7 @@ func (s *AttachmentService) ensureThumbnailFromFile(
 	filename string,
 	path string,
 ) {
-	if !shouldGenerateThumbnail(contentType, filename) {
+	if s.thumbnailsDisabled || !shouldGenerateThumbnail(contentType, filename) {
 		return
 	}
 	stat, err := os.Stat(path)
@@ -153,7 +192,8 @@ func (s *AttachmentService) ensureThumbnailFromFile(
 	}
 	defer f.Close()
 
-	thumbnailData, err := buildThumbnailJPEG(f)
+	thumbnailType, encoder := s.thumbnailEncoding()
+	thumbnailData, err := buildThumbnail(f, encoder)
 	if err != nil || len(thumbnailData) == 0 {
 		return
 	}
@@ -161,7 +201,7 @@ func (s *AttachmentService) ensureThumbnailFromFile(
 	if thumbnailKey == "" {
 		return
 	}
-	thumbnailSize, err := s.storage.Put(ctx, thumbnailKey, thumbnailContentType, thumbnailData)
+	thumbnailSize, err := s.storage.Put(ctx, thumbnailKey, thumbnailType, thumbnailData)
 	if err != nil || thumbnailSize <= 0 {
 		return
 	}
@@ -169,7 +209,7 @@ func (s *AttachmentService) ensureThumbnailFromFile(
 		ctx,
 		attachment.ID,
 		buildThumbnailFilename(filename),
-		thumbnailContentType,
+		thumbnailType,
 		thumbnailSize,
 		storageTypeName(s.storage),
 		thumbnailKey,
@@ -228,6 +268,10 @@ func (s *AttachmentService) ensureThumbnailFromUploadSession(
 }
 
 func buildThumbnailJPEG(reader io.Reader) ([]byte, error) {
+	return buildThumbnail(reader, baselineJPEGThumbnailEncoder)
+}
+
+func buildThumbnail(reader io.Reader, encoder thumbnailEncoder) ([]byte, error) {
 	src, _, err := image.Decode(reader)
 	if err != nil {
 		return nil, err
@@ -236,7 +280,7 @@ func buildThumbnailJPEG(reader io.Reader) ([]byte, error) {
 	resized := resizeImageNearest(src, thumbnailMaxDimension, thumbnailMaxDimension)
 
 	var buf bytes.Buffer
-	if err := jpeg.Encode(&buf, resized, &jpeg.Options{Quality: thumbnailJPEGQuality}); err != nil {
+	if err := encoder(&buf, resized); err != nil {
 		return nil, err
 	}
 	return buf.Bytes(), nil
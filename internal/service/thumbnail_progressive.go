@@ -0,0 +1,450 @@
+package service
+
+import (
+	"bufio"
+	"errors"
+	"image"
+	"image/color"
+	"io"
+	"math"
+)
+
+var errInvalidThumbnailDimensions = errors.New("invalid thumbnail dimensions")
+
+// encodeProgressiveJPEG 以渐进式 JPEG 编码缩略图。标准库只支持基线编码，这里实现最小的频谱选择渐进模式：
+// 先输出全部分量的 DC，再按频段输出 AC；色度按 4:2:0 采样，每个扫描使用按实际符号频率生成的最优哈夫曼表。
+// 输出不含任何 EXIF 等元数据段。
+func encodeProgressiveJPEG(w io.Writer, img image.Image, quality int) error {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width <= 0 || height <= 0 || width > 0xffff || height > 0xffff {
+		return errInvalidThumbnailDimensions
+	}
+
+	quant := [2][64]int{scaleQuantTable(jpegLuminanceQuant, quality), scaleQuantTable(jpegChrominanceQuant, quality)}
+	mcuCols, mcuRows := (width+15)/16, (height+15)/16
+	planes := buildYCbCrPlanes(img, mcuCols*16, mcuRows*16)
+	comps := [3]progressiveComponent{
+		{blocksX: mcuCols * 2, blocksY: mcuRows * 2},
+		{blocksX: mcuCols, blocksY: mcuRows},
+		{blocksX: mcuCols, blocksY: mcuRows},
+	}
+	for i := range comps {
+		comps[i].blocks = quantizePlane(planes[i], comps[i].blocksX, comps[i].blocksY, &quant[min(i, 1)])
+	}
+
+	bw := bufio.NewWriter(w)
+	writeMarker(bw, 0xd8, nil)
+	dqt := make([]byte, 0, 130)
+	for i := range quant {
+		dqt = append(dqt, byte(i))
+		for k := 0; k < 64; k++ {
+			dqt = append(dqt, byte(quant[i][jpegZigzag[k]]))
+		}
+	}
+	writeMarker(bw, 0xdb, dqt)
+	writeMarker(bw, 0xc2, []byte{
+		8, byte(height >> 8), byte(height), byte(width >> 8), byte(width), 3,
+		1, 0x22, 0,
+		2, 0x11, 1,
+		3, 0x11, 1,
+	})
+
+	// DC 扫描为交错扫描，按 MCU 顺序访问：每个 MCU 含 4 个亮度块和各 1 个色度块。
+	var dcSymbols []huffmanSymbol
+	var predictors [3]int
+	for my := 0; my < mcuRows; my++ {
+		for mx := 0; mx < mcuCols; mx++ {
+			for j := 0; j < 4; j++ {
+				block := comps[0].block(2*mx+j%2, 2*my+j/2)
+				dcSymbols = appendDCSymbol(dcSymbols, block[0]-predictors[0])
+				predictors[0] = block[0]
+			}
+			for c := 1; c < 3; c++ {
+				block := comps[c].block(mx, my)
+				dcSymbols = appendDCSymbol(dcSymbols, block[0]-predictors[c])
+				predictors[c] = block[0]
+			}
+		}
+	}
+	writeProgressiveScan(bw, 0, []byte{1, 2, 3}, 0, 0, dcSymbols)
+
+	// AC 扫描只能包含单个分量，按块行列顺序访问，且只覆盖落在图像像素范围内的块。
+	scans := []struct {
+		comp   int
+		ss, se int
+	}{
+		{0, 1, 5},
+		{2, 1, 63},
+		{1, 1, 63},
+		{0, 6, 63},
+	}
+	for _, scan := range scans {
+		comp := comps[scan.comp]
+		cols, rows := (width+7)/8, (height+7)/8
+		if scan.comp > 0 {
+			cols, rows = comp.blocksX, comp.blocksY
+		}
+		var acSymbols []huffmanSymbol
+		for by := 0; by < rows; by++ {
+			for bx := 0; bx < cols; bx++ {
+				acSymbols = appendACSymbols(acSymbols, comp.block(bx, by), scan.ss, scan.se)
+			}
+		}
+		writeProgressiveScan(bw, 1, []byte{byte(scan.comp + 1)}, scan.ss, scan.se, acSymbols)
+	}
+
+	writeMarker(bw, 0xd9, nil)
+	return bw.Flush()
+}
+
+type progressiveComponent struct {
+	blocksX, blocksY int
+	blocks           [][64]int
+}
+
+func (c progressiveComponent) block(bx, by int) *[64]int {
+	return &c.blocks[by*c.blocksX+bx]
+}
+
+// huffmanSymbol 为待编码的符号及其附加位，先收集整个扫描的符号再统计频率生成哈夫曼表。
+type huffmanSymbol struct {
+	symbol    byte
+	extra     uint32
+	extraBits uint8
+}
+
+var (
+	jpegLuminanceQuant = [64]int{
+		16, 11, 10, 16, 24, 40, 51, 61,
+		12, 12, 14, 19, 26, 58, 60, 55,
+		14, 13, 16, 24, 40, 57, 69, 56,
+		14, 17, 22, 29, 51, 87, 80, 62,
+		18, 22, 37, 56, 68, 109, 103, 77,
+		24, 35, 55, 64, 81, 104, 113, 92,
+		49, 64, 78, 87, 103, 121, 120, 101,
+		72, 92, 95, 98, 112, 100, 103, 99,
+	}
+	jpegChrominanceQuant = [64]int{
+		17, 18, 24, 47, 99, 99, 99, 99,
+		18, 21, 26, 66, 99, 99, 99, 99,
+		24, 26, 56, 99, 99, 99, 99, 99,
+		47, 66, 99, 99, 99, 99, 99, 99,
+		99, 99, 99, 99, 99, 99, 99, 99,
+		99, 99, 99, 99, 99, 99, 99, 99,
+		99, 99, 99, 99, 99, 99, 99, 99,
+		99, 99, 99, 99, 99, 99, 99, 99,
+	}
+	// jpegZigzag 将之字形序号映射为 8x8 块内的自然序号。
+	jpegZigzag = [64]int{
+		0, 1, 8, 16, 9, 2, 3, 10,
+		17, 24, 32, 25, 18, 11, 4, 5,
+		12, 19, 26, 33, 40, 48, 41, 34,
+		27, 20, 13, 6, 7, 14, 21, 28,
+		35, 42, 49, 56, 57, 50, 43, 36,
+		29, 22, 15, 23, 30, 37, 44, 51,
+		58, 59, 52, 45, 38, 31, 39, 46,
+		53, 60, 61, 54, 47, 55, 62, 63,
+	}
+	dctCosines = func() (table [8][8]float64) {
+		for x := 0; x < 8; x++ {
+			for u := 0; u < 8; u++ {
+				table[x][u] = math.Cos(float64(2*x+1) * float64(u) * math.Pi / 16)
+			}
+		}
+		return table
+	}()
+)
+
+// scaleQuantTable 按 libjpeg 的规则将标准量化表缩放到指定质量。
+func scaleQuantTable(base [64]int, quality int) [64]int {
+	quality = max(1, min(quality, 100))
+	scale := 200 - 2*quality
+	if quality < 50 {
+		scale = 5000 / quality
+	}
+	var table [64]int
+	for i, v := range base {
+		table[i] = max(1, min((v*scale+50)/100, 255))
+	}
+	return table
+}
+
+// buildYCbCrPlanes 将图像转换为 YCbCr 并补边到 MCU 对齐的尺寸，色度平面按 2x2 取平均下采样。
+func buildYCbCrPlanes(img image.Image, paddedWidth, paddedHeight int) [3][]float64 {
+	bounds := img.Bounds()
+	y := make([]float64, paddedWidth*paddedHeight)
+	cb := make([]float64, paddedWidth*paddedHeight)
+	cr := make([]float64, paddedWidth*paddedHeight)
+	for py := 0; py < paddedHeight; py++ {
+		sy := bounds.Min.Y + min(py, bounds.Dy()-1)
+		for px := 0; px < paddedWidth; px++ {
+			sx := bounds.Min.X + min(px, bounds.Dx()-1)
+			r, g, b, _ := img.At(sx, sy).RGBA()
+			yy, cbb, crr := color.RGBToYCbCr(uint8(r>>8), uint8(g>>8), uint8(b>>8))
+			i := py*paddedWidth + px
+			y[i], cb[i], cr[i] = float64(yy), float64(cbb), float64(crr)
+		}
+	}
+
+	halfWidth, halfHeight := paddedWidth/2, paddedHeight/2
+	subsample := func(plane []float64) []float64 {
+		out := make([]float64, halfWidth*halfHeight)
+		for py := 0; py < halfHeight; py++ {
+			for px := 0; px < halfWidth; px++ {
+				i := 2*py*paddedWidth + 2*px
+				out[py*halfWidth+px] = (plane[i] + plane[i+1] + plane[i+paddedWidth] + plane[i+paddedWidth+1]) / 4
+			}
+		}
+		return out
+	}
+	return [3][]float64{y, subsample(cb), subsample(cr)}
+}
+
+// quantizePlane 对平面逐块做 DCT 与量化，结果按自然序存放。
+func quantizePlane(plane []float64, blocksX, blocksY int, quant *[64]int) [][64]int {
+	stride := blocksX * 8
+	blocks := make([][64]int, blocksX*blocksY)
+	var samples, rows [64]float64
+	for by := 0; by < blocksY; by++ {
+		for bx := 0; bx < blocksX; bx++ {
+			for y := 0; y < 8; y++ {
+				for x := 0; x < 8; x++ {
+					samples[y*8+x] = plane[(by*8+y)*stride+bx*8+x] - 128
+				}
+			}
+			// 可分离的二维 DCT：先按行变换，再按列变换。
+			for y := 0; y < 8; y++ {
+				for u := 0; u < 8; u++ {
+					var sum float64
+					for x := 0; x < 8; x++ {
+						sum += samples[y*8+x] * dctCosines[x][u]
+					}
+					rows[y*8+u] = sum
+				}
+			}
+			block := &blocks[by*blocksX+bx]
+			for v := 0; v < 8; v++ {
+				for u := 0; u < 8; u++ {
+					var sum float64
+					for y := 0; y < 8; y++ {
+						sum += rows[y*8+u] * dctCosines[y][v]
+					}
+					cu, cv := 1.0, 1.0
+					if u == 0 {
+						cu = math.Sqrt2 / 2
+					}
+					if v == 0 {
+						cv = math.Sqrt2 / 2
+					}
+					coefficient := sum * cu * cv / 4
+					block[v*8+u] = int(math.Round(coefficient / float64(quant[v*8+u])))
+				}
+			}
+		}
+	}
+	return blocks
+}
+
+func magnitudeBits(value int) (uint8, uint32) {
+	abs := value
+	if abs < 0 {
+		abs = -abs
+	}
+	var size uint8
+	for abs > 0 {
+		size++
+		abs >>= 1
+	}
+	if value < 0 {
+		value += 1<<size - 1
+	}
+	return size, uint32(value)
+}
+
+func appendDCSymbol(symbols []huffmanSymbol, diff int) []huffmanSymbol {
+	size, extra := magnitudeBits(diff)
+	return append(symbols, huffmanSymbol{symbol: size, extra: extra, extraBits: size})
+}
+
+// appendACSymbols 编码渐进首遍 AC 扫描的一个块：末尾连续为零时以 EOB0（本块结束）收尾。
+func appendACSymbols(symbols []huffmanSymbol, block *[64]int, ss, se int) []huffmanSymbol {
+	run := 0
+	for k := ss; k <= se; k++ {
+		value := block[jpegZigzag[k]]
+		if value == 0 {
+			run++
+			continue
+		}
+		for run > 15 {
+			symbols = append(symbols, huffmanSymbol{symbol: 0xf0})
+			run -= 16
+		}
+		size, extra := magnitudeBits(value)
+		symbols = append(symbols, huffmanSymbol{symbol: byte(run<<4) | size, extra: extra, extraBits: size})
+		run = 0
+	}
+	if run > 0 {
+		symbols = append(symbols, huffmanSymbol{symbol: 0x00})
+	}
+	return symbols
+}
+
+// writeProgressiveScan 为扫描生成最优哈夫曼表并写出 DHT、SOS 与熵编码数据；tableClass 0 为 DC，1 为 AC。
+func writeProgressiveScan(w *bufio.Writer, tableClass byte, componentIDs []byte, ss, se int, symbols []huffmanSymbol) {
+	var freq [256]int
+	for _, s := range symbols {
+		freq[s.symbol]++
+	}
+	counts, values := buildOptimalHuffmanTable(freq)
+	dht := append([]byte{tableClass << 4}, counts[:]...)
+	writeMarker(w, 0xc4, append(dht, values...))
+
+	sos := []byte{byte(len(componentIDs))}
+	for _, id := range componentIDs {
+		sos = append(sos, id, 0x00)
+	}
+	writeMarker(w, 0xda, append(sos, byte(ss), byte(se), 0))
+
+	var codes [256]uint32
+	var sizes [256]uint8
+	code, k := uint32(0), 0
+	for length := 1; length <= 16; length++ {
+		for i := 0; i < int(counts[length-1]); i++ {
+			codes[values[k]], sizes[values[k]] = code, uint8(length)
+			code++
+			k++
+		}
+		code <<= 1
+	}
+
+	bits := jpegBitWriter{w: w}
+	for _, s := range symbols {
+		bits.write(codes[s.symbol], sizes[s.symbol])
+		if s.extraBits > 0 {
+			bits.write(s.extra, s.extraBits)
+		}
+	}
+	bits.flush()
+}
+
+// buildOptimalHuffmanTable 按 JPEG 规范附录 K.2 的方法生成码长不超过 16 位的哈夫曼表，
+// 保留一个全 1 码字不分配给任何符号。
+func buildOptimalHuffmanTable(symbolFreq [256]int) ([16]byte, []byte) {
+	var freq [257]int
+	copy(freq[:], symbolFreq[:])
+	freq[256] = 1
+	var codeSize [257]int
+	var others [257]int
+	for i := range others {
+		others[i] = -1
+	}
+
+	for {
+		c1, c2 := -1, -1
+		for i := range freq {
+			if freq[i] > 0 && (c1 < 0 || freq[i] <= freq[c1]) {
+				c1 = i
+			}
+		}
+		for i := range freq {
+			if freq[i] > 0 && i != c1 && (c2 < 0 || freq[i] <= freq[c2]) {
+				c2 = i
+			}
+		}
+		if c2 < 0 {
+			break
+		}
+		freq[c1] += freq[c2]
+		freq[c2] = 0
+		codeSize[c1]++
+		for others[c1] >= 0 {
+			c1 = others[c1]
+			codeSize[c1]++
+		}
+		others[c1] = c2
+		codeSize[c2]++
+		for others[c2] >= 0 {
+			c2 = others[c2]
+			codeSize[c2]++
+		}
+	}
+
+	bits := make([]int, 258)
+	for _, size := range codeSize {
+		if size > 0 {
+			bits[size]++
+		}
+	}
+	for i := len(bits) - 1; i > 16; i-- {
+		for bits[i] > 0 {
+			j := i - 2
+			for bits[j] == 0 {
+				j--
+			}
+			bits[i] -= 2
+			bits[i-1]++
+			bits[j+1] += 2
+			bits[j]--
+		}
+	}
+	i := 16
+	for bits[i] == 0 {
+		i--
+	}
+	bits[i]--
+
+	var counts [16]byte
+	for length := 1; length <= 16; length++ {
+		counts[length-1] = byte(bits[length])
+	}
+	values := make([]byte, 0, 256)
+	for length := 1; length < len(bits); length++ {
+		for symbol := 0; symbol < 256; symbol++ {
+			if codeSize[symbol] == length {
+				values = append(values, byte(symbol))
+			}
+		}
+	}
+	return counts, values
+}
+
+func writeMarker(w *bufio.Writer, marker byte, payload []byte) {
+	_ = w.WriteByte(0xff)
+	_ = w.WriteByte(marker)
+	if marker == 0xd8 || marker == 0xd9 {
+		return
+	}
+	length := len(payload) + 2
+	_ = w.WriteByte(byte(length >> 8))
+	_ = w.WriteByte(byte(length))
+	_, _ = w.Write(payload)
+}
+
+// jpegBitWriter 按高位在前写出熵编码数据，0xFF 字节后补 0x00 以免被识别为标记。
+type jpegBitWriter struct {
+	w     *bufio.Writer
+	acc   uint32
+	nBits uint8
+}
+
+func (b *jpegBitWriter) write(code uint32, size uint8) {
+	for size > 0 {
+		size--
+		b.acc = b.acc<<1 | (code>>size)&1
+		b.nBits++
+		if b.nBits == 8 {
+			_ = b.w.WriteByte(byte(b.acc))
+			if byte(b.acc) == 0xff {
+				_ = b.w.WriteByte(0x00)
+			}
+			b.acc, b.nBits = 0, 0
+		}
+	}
+}
+
+func (b *jpegBitWriter) flush() {
+	if b.nBits > 0 {
+		b.write(1<<(8-b.nBits)-1, 8-b.nBits)
+	}
+}
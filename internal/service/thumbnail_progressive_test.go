@@ -0,0 +1,174 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io"
+	"path/filepath"
+	"testing"
+
+	"github.com/shinyes/keer/internal/storage"
+)
+
+func TestBuildThumbnail_ProgressiveJPEG(t *testing.T) {
+	source := generateTestJPEGBytes(t, 1203, 897)
+	// 在 SOI 之后插入一个 EXIF 段，缩略图中不应保留。
+	exif := append([]byte{0xff, 0xe1, 0x00, 0x10}, []byte("Exif\x00\x00keer-test")...)
+	withExif := append(append(append([]byte{}, source[:2]...), exif...), source[2:]...)
+
+	baseline, err := buildThumbnailJPEG(bytes.NewReader(withExif))
+	if err != nil {
+		t.Fatalf("buildThumbnailJPEG() error = %v", err)
+	}
+	progressive, err := buildThumbnail(bytes.NewReader(withExif), progressiveJPEGThumbnailEncoder)
+	if err != nil {
+		t.Fatalf("buildThumbnail(progressive) error = %v", err)
+	}
+
+	if !bytes.Contains(progressive, []byte{0xff, 0xc2}) {
+		t.Fatalf("expected progressive SOF2 marker in thumbnail")
+	}
+	if bytes.Contains(progressive, []byte("Exif")) {
+		t.Fatalf("expected EXIF metadata to be stripped")
+	}
+	if len(progressive) >= len(baseline) {
+		t.Fatalf("expected progressive thumbnail to be smaller than baseline, got %d >= %d", len(progressive), len(baseline))
+	}
+
+	decoded, err := jpeg.Decode(bytes.NewReader(progressive))
+	if err != nil {
+		t.Fatalf("jpeg.Decode(progressive) error = %v", err)
+	}
+	reference, err := jpeg.Decode(bytes.NewReader(baseline))
+	if err != nil {
+		t.Fatalf("jpeg.Decode(baseline) error = %v", err)
+	}
+	if decoded.Bounds() != reference.Bounds() || decoded.Bounds().Dx() != 640 {
+		t.Fatalf("unexpected progressive bounds %v, baseline %v", decoded.Bounds(), reference.Bounds())
+	}
+	if diff := meanAbsDiff(decoded, reference); diff > 6 {
+		t.Fatalf("expected progressive thumbnail to match baseline closely, mean diff %.2f", diff)
+	}
+}
+
+func TestCreateAttachment_ProgressiveThumbnail(t *testing.T) {
+	services := setupTestServices(t)
+	localStore, err := storage.NewLocalStore(filepath.Join(t.TempDir(), "uploads"))
+	if err != nil {
+		t.Fatalf("NewLocalStore() error = %v", err)
+	}
+	attachmentService := NewAttachmentService(services.store, localStore, 0)
+	attachmentService.SetThumbnailProgressive(true)
+	user := mustCreateUser(t, services.store, "attach-thumbnail-progressive")
+
+	attachment, err := attachmentService.CreateAttachment(context.Background(), user.ID, CreateAttachmentInput{
+		Filename: "large.jpg",
+		Type:     "image/jpeg",
+		Content:  base64.StdEncoding.EncodeToString(generateTestJPEGBytes(t, 900, 700)),
+	})
+	if err != nil {
+		t.Fatalf("CreateAttachment() error = %v", err)
+	}
+	rc, err := localStore.Open(context.Background(), attachment.ThumbnailStorageKey)
+	if err != nil {
+		t.Fatalf("open thumbnail error = %v", err)
+	}
+	defer rc.Close()
+	data, _ := io.ReadAll(rc)
+	if attachment.ThumbnailType != "image/jpeg" || !bytes.Contains(data, []byte{0xff, 0xc2}) {
+		t.Fatalf("expected progressive JPEG thumbnail, type=%q", attachment.ThumbnailType)
+	}
+}
+
+func TestEncodeProgressiveJPEG_RoundTrip(t *testing.T) {
+	sources := map[string]func(w, h int) image.Image{
+		"rgba": func(w, h int) image.Image {
+			img := image.NewRGBA(image.Rect(0, 0, w, h))
+			for y := 0; y < h; y++ {
+				for x := 0; x < w; x++ {
+					img.Set(x, y, color.RGBA{R: uint8(x * 255 / w), G: uint8(y * 255 / h), B: 160, A: 255})
+				}
+			}
+			return img
+		},
+		"gray": func(w, h int) image.Image {
+			img := image.NewGray(image.Rect(0, 0, w, h))
+			for y := 0; y < h; y++ {
+				for x := 0; x < w; x++ {
+					img.SetGray(x, y, color.Gray{Y: uint8((x + y) * 255 / (w + h))})
+				}
+			}
+			return img
+		},
+		"ycbcr420": func(w, h int) image.Image {
+			img := image.NewYCbCr(image.Rect(0, 0, w, h), image.YCbCrSubsampleRatio420)
+			for y := 0; y < h; y++ {
+				for x := 0; x < w; x++ {
+					img.Y[img.YOffset(x, y)] = uint8(40 + x*160/w)
+					img.Cb[img.COffset(x, y)] = uint8(96 + y*64/h)
+					img.Cr[img.COffset(x, y)] = 150
+				}
+			}
+			return img
+		},
+	}
+	sizes := [][2]int{{1, 1}, {7, 5}, {15, 17}, {33, 9}, {641, 3}}
+
+	for name, source := range sources {
+		for _, size := range sizes {
+			t.Run(fmt.Sprintf("%s_%dx%d", name, size[0], size[1]), func(t *testing.T) {
+				img := source(size[0], size[1])
+				var buf bytes.Buffer
+				if err := encodeProgressiveJPEG(&buf, img, thumbnailJPEGQuality); err != nil {
+					t.Fatalf("encodeProgressiveJPEG() error = %v", err)
+				}
+				if !bytes.Contains(buf.Bytes(), []byte{0xff, 0xc2}) {
+					t.Fatalf("expected progressive SOF2 marker")
+				}
+				decoded, err := jpeg.Decode(bytes.NewReader(buf.Bytes()))
+				if err != nil {
+					t.Fatalf("jpeg.Decode() error = %v", err)
+				}
+				if decoded.Bounds() != img.Bounds() {
+					t.Fatalf("decoded bounds %v, want %v", decoded.Bounds(), img.Bounds())
+				}
+				// 以标准库同质量的基线编码为参照：两者量化与采样一致，解码结果应几乎相同。
+				var reference bytes.Buffer
+				if err := jpeg.Encode(&reference, img, &jpeg.Options{Quality: thumbnailJPEGQuality}); err != nil {
+					t.Fatalf("jpeg.Encode() error = %v", err)
+				}
+				referenceImage, err := jpeg.Decode(&reference)
+				if err != nil {
+					t.Fatalf("jpeg.Decode(reference) error = %v", err)
+				}
+				if diff := meanAbsDiff(decoded, referenceImage); diff > 2 {
+					t.Fatalf("progressive output differs from baseline reference, mean diff %.2f", diff)
+				}
+			})
+		}
+	}
+}
+
+func meanAbsDiff(a, b image.Image) float64 {
+	bounds := a.Bounds()
+	var total float64
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			r1, g1, b1, _ := a.At(x, y).RGBA()
+			r2, g2, b2, _ := b.At(x, y).RGBA()
+			for _, pair := range [][2]uint32{{r1, r2}, {g1, g2}, {b1, b2}} {
+				d := float64(pair[0]>>8) - float64(pair[1]>>8)
+				if d < 0 {
+					d = -d
+				}
+				total += d
+			}
+		}
+	}
+	return total / float64(bounds.Dx()*bounds.Dy()*3)
+}
@@ -0,0 +1,186 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/shinyes/keer/internal/models"
+	"github.com/shinyes/keer/internal/storage"
+)
+
+// videoThumbnailTimeout 限制单次 ffmpeg 抽帧的耗时。抽帧在上传完成请求内同步执行，
+// 限时要短，超时即放弃，附件照常创建。
+const videoThumbnailTimeout = 8 * time.Second
+
+// videoSniffLength 为识别视频容器格式读取的文件头长度。
+const videoSniffLength = 16
+
+var videoExtensions = map[string]struct{}{
+	".mp4":  {},
+	".m4v":  {},
+	".mov":  {},
+	".webm": {},
+	".mkv":  {},
+	".avi":  {},
+	".3gp":  {},
+}
+
+// SetFFmpegPath 设置 ffmpeg 可执行文件路径，非空时为未附带缩略图的视频在服务端抽帧生成缩略图。
+func (s *AttachmentService) SetFFmpegPath(path string) {
+	s.ffmpegPath = strings.TrimSpace(path)
+}
+
+func isVideoAttachment(contentType string, filename string) bool {
+	if strings.HasPrefix(strings.ToLower(strings.TrimSpace(contentType)), "video/") {
+		return true
+	}
+	_, ok := videoExtensions[strings.ToLower(filepath.Ext(strings.TrimSpace(filename)))]
+	return ok
+}
+
+// ensureVideoThumbnail 用 ffmpeg 从 input（本地路径或可读取的 URL）中挑一帧作为缩略图；任何失败都只是不生成缩略图。
+func (s *AttachmentService) ensureVideoThumbnail(
+	ctx context.Context,
+	attachment models.Attachment,
+	contentType string,
+	filename string,
+	input string,
+) {
+	if s.ffmpegPath == "" || s.thumbnailsDisabled || input == "" || !isVideoAttachment(contentType, filename) {
+		return
+	}
+	header, err := s.readVideoHeader(ctx, attachment.StorageKey, input)
+	if err != nil {
+		return
+	}
+	demuxer := sniffVideoDemuxer(header)
+	if demuxer == "" {
+		return
+	}
+	frame, err := s.extractVideoFrame(ctx, demuxer, input)
+	if err != nil || len(frame) == 0 {
+		return
+	}
+	thumbnailType, encoder := s.thumbnailEncoding()
+	thumbnailData, err := buildThumbnail(bytes.NewReader(frame), encoder)
+	if err != nil || len(thumbnailData) == 0 {
+		return
+	}
+	thumbnailKey := thumbnailStorageKey(attachment.StorageKey)
+	if thumbnailKey == "" {
+		return
+	}
+	thumbnailSize, err := s.storage.Put(ctx, thumbnailKey, thumbnailType, thumbnailData)
+	if err != nil || thumbnailSize <= 0 {
+		return
+	}
+	_ = s.store.UpdateAttachmentThumbnail(
+		ctx,
+		attachment.ID,
+		buildThumbnailFilename(filename),
+		thumbnailType,
+		thumbnailSize,
+		storageTypeName(s.storage),
+		thumbnailKey,
+	)
+}
+
+// ensureVideoThumbnailFromStorage 为已直传到 S3/GCS 的视频抽帧，ffmpeg 通过短时预签名地址按需读取对象；
+// 只接受 https 地址，使用 http 端点的存储不在服务端抽帧。
+func (s *AttachmentService) ensureVideoThumbnailFromStorage(ctx context.Context, attachment models.Attachment) {
+	if s.ffmpegPath == "" || s.thumbnailsDisabled || !isVideoAttachment(attachment.Type, attachment.Filename) {
+		return
+	}
+	presigner, ok := s.storage.(storage.Presigner)
+	if !ok {
+		return
+	}
+	url, err := presigner.PresignGetObjectURL(ctx, attachment.StorageKey, videoThumbnailTimeout+time.Minute)
+	if err != nil || !isRemoteVideoInput(url) {
+		return
+	}
+	s.ensureVideoThumbnail(ctx, attachment, attachment.Type, attachment.Filename, url)
+}
+
+// readVideoHeader 读取视频开头的若干字节用于识别容器：本地文件直接读取，预签名地址改为从存储按范围读取对象。
+func (s *AttachmentService) readVideoHeader(ctx context.Context, storageKey string, input string) ([]byte, error) {
+	var rc io.ReadCloser
+	var err error
+	if isRemoteVideoInput(input) {
+		rc, err = s.storage.OpenRange(ctx, storageKey, 0, videoSniffLength-1)
+	} else {
+		rc, err = os.Open(input)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	header := make([]byte, videoSniffLength)
+	n, err := io.ReadFull(rc, header)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, err
+	}
+	return header[:n], nil
+}
+
+// sniffVideoDemuxer 按文件头识别容器并返回对应的 ffmpeg demuxer 名；无法识别时返回空，不交给 ffmpeg 自动探测。
+func sniffVideoDemuxer(header []byte) string {
+	switch {
+	case len(header) >= 8 && string(header[4:8]) == "ftyp":
+		// MP4、MOV、M4V、3GP 都由 mov demuxer 处理
+		return "mov"
+	case len(header) >= 4 && bytes.Equal(header[:4], []byte{0x1A, 0x45, 0xDF, 0xA3}):
+		// EBML 头：Matroska 与 WebM
+		return "matroska"
+	case len(header) >= 12 && string(header[:4]) == "RIFF" && string(header[8:12]) == "AVI ":
+		return "avi"
+	default:
+		return ""
+	}
+}
+
+func isRemoteVideoInput(input string) bool {
+	return strings.HasPrefix(input, "https://")
+}
+
+// extractVideoFrame 让 ffmpeg 用 thumbnail 滤镜在开头若干帧里挑一张代表帧（避开片头黑屏），以 PNG 输出到标准输出。
+// demuxer 固定为文件头识别出的容器，协议限定为本地文件或 https 预签名地址，
+// 避免恶意文件借 HLS/concat 等格式让 ffmpeg 读取其他本地文件或访问内网地址。
+func (s *AttachmentService) extractVideoFrame(ctx context.Context, demuxer string, input string) ([]byte, error) {
+	protocols := "file"
+	if isRemoteVideoInput(input) {
+		protocols = "https,tls,tcp"
+	} else {
+		input = "file:" + input
+	}
+	ctx, cancel := context.WithTimeout(ctx, videoThumbnailTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(
+		ctx,
+		s.ffmpegPath,
+		"-hide_banner",
+		"-loglevel", "error",
+		"-nostdin",
+		"-protocol_whitelist", protocols,
+		"-f", demuxer,
+		"-i", input,
+		"-vf", "thumbnail",
+		"-frames:v", "1",
+		"-f", "image2pipe",
+		"-vcodec", "png",
+		"pipe:1",
+	)
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+	if err := cmd.Run(); err != nil {
+		return nil, err
+	}
+	return stdout.Bytes(), nil
+}
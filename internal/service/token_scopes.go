@@ -0,0 +1,64 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// TokenScopeResources 为可授权的资源，令牌权限形如 memos:read、attachments:write。
+var TokenScopeResources = []string{"memos", "attachments", "users", "groups", "tags", "search", "tokens", "admin"}
+
+const (
+	TokenScopeActionRead  = "read"
+	TokenScopeActionWrite = "write"
+)
+
+// ParseTokenScopes 解析逗号分隔的权限列表，去重并排序；空字符串表示不限制（完整权限）。
+func ParseTokenScopes(raw string) ([]string, error) {
+	seen := map[string]struct{}{}
+	scopes := make([]string, 0)
+	for _, part := range strings.Split(raw, ",") {
+		scope := strings.ToLower(strings.TrimSpace(part))
+		if scope == "" {
+			continue
+		}
+		resource, action, ok := strings.Cut(scope, ":")
+		if !ok || !isTokenScopeResource(resource) || (action != TokenScopeActionRead && action != TokenScopeActionWrite) {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidTokenScope, part)
+		}
+		if _, exists := seen[scope]; exists {
+			continue
+		}
+		seen[scope] = struct{}{}
+		scopes = append(scopes, scope)
+	}
+	if len(scopes) == 0 {
+		return nil, nil
+	}
+	sort.Strings(scopes)
+	return scopes, nil
+}
+
+// TokenScopesAllow 判断令牌权限是否允许对资源执行读或写操作；未设置权限的旧令牌拥有完整权限。
+func TokenScopesAllow(scopes []string, resource string, action string) bool {
+	if len(scopes) == 0 {
+		return true
+	}
+	required := resource + ":" + action
+	for _, scope := range scopes {
+		if scope == required {
+			return true
+		}
+	}
+	return false
+}
+
+func isTokenScopeResource(resource string) bool {
+	for _, candidate := range TokenScopeResources {
+		if candidate == resource {
+			return true
+		}
+	}
+	return false
+}
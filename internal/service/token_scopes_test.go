@@ -0,0 +1,80 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseTokenScopes(t *testing.T) {
+	cases := []struct {
+		raw     string
+		want    []string
+		wantErr bool
+	}{
+		{raw: "", want: nil},
+		{raw: " , ", want: nil},
+		{raw: "memos:read, Attachments:WRITE,memos:read", want: []string{"attachments:write", "memos:read"}},
+		{raw: "memos", wantErr: true},
+		{raw: "memos:delete", wantErr: true},
+		{raw: "unknown:read", wantErr: true},
+	}
+	for _, tc := range cases {
+		got, err := ParseTokenScopes(tc.raw)
+		if tc.wantErr {
+			if !errors.Is(err, ErrInvalidTokenScope) {
+				t.Fatalf("ParseTokenScopes(%q): expected ErrInvalidTokenScope, got %v", tc.raw, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("ParseTokenScopes(%q) error = %v", tc.raw, err)
+		}
+		if strings.Join(got, ",") != strings.Join(tc.want, ",") {
+			t.Fatalf("ParseTokenScopes(%q) = %v, want %v", tc.raw, got, tc.want)
+		}
+	}
+}
+
+func TestScopedAccessTokenRoundTrip(t *testing.T) {
+	services := setupTestServices(t)
+	userService := NewUserService(services.store)
+	ctx := context.Background()
+	mustCreateUser(t, services.store, "scoped-user")
+
+	_, rawToken, err := userService.CreateAccessTokenForUserWithScopes(ctx, "scoped-user", "integration", nil, []string{"memos:read"})
+	if err != nil {
+		t.Fatalf("CreateAccessTokenForUserWithScopes() error = %v", err)
+	}
+	_, token, err := userService.AuthenticateAccessToken(ctx, rawToken)
+	if err != nil {
+		t.Fatalf("AuthenticateAccessToken() error = %v", err)
+	}
+	if strings.Join(token.Scopes, ",") != "memos:read" {
+		t.Fatalf("expected stored scopes, got %v", token.Scopes)
+	}
+	if !TokenScopesAllow(token.Scopes, "memos", TokenScopeActionRead) || TokenScopesAllow(token.Scopes, "memos", TokenScopeActionWrite) {
+		t.Fatalf("expected memos:read only, got %v", token.Scopes)
+	}
+
+	rotated, _, err := userService.RotateAccessToken(ctx, token.ID)
+	if err != nil {
+		t.Fatalf("RotateAccessToken() error = %v", err)
+	}
+	if strings.Join(rotated.Scopes, ",") != "memos:read" {
+		t.Fatalf("expected rotation to keep scopes, got %v", rotated.Scopes)
+	}
+
+	_, legacyToken, err := userService.CreateAccessTokenForUser(ctx, "scoped-user", "legacy")
+	if err != nil {
+		t.Fatalf("CreateAccessTokenForUser() error = %v", err)
+	}
+	_, legacy, err := userService.AuthenticateAccessToken(ctx, legacyToken)
+	if err != nil {
+		t.Fatalf("AuthenticateAccessToken(legacy) error = %v", err)
+	}
+	if len(legacy.Scopes) != 0 || !TokenScopesAllow(legacy.Scopes, "admin", TokenScopeActionWrite) {
+		t.Fatalf("expected token without scopes to have full access, got %v", legacy.Scopes)
+	}
+}
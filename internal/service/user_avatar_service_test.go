@@ -65,6 +65,10 @@ func (s *memoryAvatarStore) Delete(_ context.Context, key string) error {
 	return nil
 }
 
+func (s *memoryAvatarStore) Check(context.Context) error {
+	return nil
+}
+
 var _ storage.Store = (*memoryAvatarStore)(nil)
 
 func TestUpdateUserAvatarThumbnail_StoresAvatarToDedicatedPath(t *testing.T) {
@@ -133,7 +137,7 @@ func TestUpdateUserAvatarThumbnail_RejectsLargeDimensions(t *testing.T) {
 		t.Fatalf("CreateUser() error = %v", err)
 	}
 
-	content := encodeBase64(makePNG(t, avatarMaxDimension+1, 16))
+	content := encodeBase64(makePNG(t, defaultAvatarMaxDimension+1, 16))
 	if _, err := userService.UpdateUserAvatarThumbnail(ctx, user.ID, content, "image/png"); err == nil {
 		t.Fatalf("expected validation error for oversized dimensions")
 	}
@@ -142,6 +146,45 @@ func TestUpdateUserAvatarThumbnail_RejectsLargeDimensions(t *testing.T) {
 	}
 }
 
+func TestUpdateUserAvatarThumbnail_ConfigurableLimits(t *testing.T) {
+	services := setupTestServices(t)
+	userService := NewUserService(services.store)
+	avatarStore := newMemoryAvatarStore()
+	userService.SetAvatarStorage(avatarStore)
+	ctx := context.Background()
+
+	user, err := services.store.CreateUser(ctx, "avatarcase05", "avatarcase05", "USER")
+	if err != nil {
+		t.Fatalf("CreateUser() error = %v", err)
+	}
+
+	userService.SetAvatarLimits(0, 64, 0)
+	if _, err := userService.UpdateUserAvatarThumbnail(ctx, user.ID, encodeBase64(makePNG(t, 65, 16)), "image/png"); err == nil || err.Error() != "avatar dimensions exceed limit" {
+		t.Fatalf("expected dimension limit error, got %v", err)
+	}
+	if userService.avatarLimits.maxSourceBytes != defaultAvatarMaxSourceBytes || userService.avatarLimits.maxPixels != defaultAvatarMaxPixels {
+		t.Fatalf("expected non-positive limits to keep defaults, got %+v", userService.avatarLimits)
+	}
+
+	userService.SetAvatarLimits(0, 0, 1000)
+	if _, err := userService.UpdateUserAvatarThumbnail(ctx, user.ID, encodeBase64(makePNG(t, 40, 40)), "image/png"); err == nil || err.Error() != "avatar pixel count exceed limit" {
+		t.Fatalf("expected pixel limit error, got %v", err)
+	}
+
+	userService.SetAvatarLimits(16, 0, 0)
+	if _, err := userService.UpdateUserAvatarThumbnail(ctx, user.ID, encodeBase64(makePNG(t, 8, 8)), "image/png"); err == nil || err.Error() != "avatar content too large" {
+		t.Fatalf("expected size limit error, got %v", err)
+	}
+	if len(avatarStore.objects) != 0 {
+		t.Fatalf("expected no avatar file written when validation fails")
+	}
+
+	userService.SetAvatarLimits(1<<20, 8192, defaultAvatarMaxPixels)
+	if _, err := userService.UpdateUserAvatarThumbnail(ctx, user.ID, encodeBase64(makePNG(t, defaultAvatarMaxDimension+1, 16)), "image/png"); err != nil {
+		t.Fatalf("expected raised dimension limit to accept avatar, got %v", err)
+	}
+}
+
 func TestClearUserAvatar_DeleteFailureDoesNotUpdateAvatarURL(t *testing.T) {
 	services := setupTestServices(t)
 	userService := NewUserService(services.store)
@@ -170,6 +213,41 @@ func TestClearUserAvatar_DeleteFailureDoesNotUpdateAvatarURL(t *testing.T) {
 	}
 }
 
+func TestUpdateUserAvatarThumbnail_StoresOriginalWhenThumbnailsDisabled(t *testing.T) {
+	services := setupTestServices(t)
+	userService := NewUserService(services.store)
+	avatarStore := newMemoryAvatarStore()
+	userService.SetAvatarStorage(avatarStore)
+	userService.SetAvatarThumbnailsDisabled(true)
+	ctx := context.Background()
+
+	user, err := services.store.CreateUser(ctx, "avatarcase05", "avatarcase05", "USER")
+	if err != nil {
+		t.Fatalf("CreateUser() error = %v", err)
+	}
+
+	original := makePNG(t, 256, 256)
+	if _, err := userService.UpdateUserAvatarThumbnail(ctx, user.ID, encodeBase64(original), "image/png"); err != nil {
+		t.Fatalf("UpdateUserAvatarThumbnail() error = %v", err)
+	}
+	if !bytes.Equal(avatarStore.objects[avatarStorageKey(user.ID)], original) {
+		t.Fatalf("expected original avatar bytes to be stored unchanged")
+	}
+
+	rc, contentType, err := userService.OpenUserAvatarStream(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("OpenUserAvatarStream() error = %v", err)
+	}
+	defer rc.Close()
+	served, err := io.ReadAll(rc)
+	if err != nil {
+		t.Fatalf("read avatar stream error = %v", err)
+	}
+	if contentType != "image/png" || !bytes.Equal(served, original) {
+		t.Fatalf("expected original png served, got type=%q len=%d", contentType, len(served))
+	}
+}
+
 func makePNG(t *testing.T, width int, height int) []byte {
 	t.Helper()
 	img := image.NewRGBA(image.Rect(0, 0, width, height))
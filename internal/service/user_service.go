@@ -1,6 +1,7 @@
 package service
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"crypto/rand"
@@ -28,7 +29,16 @@ import (
 type UserService struct {
 	store         *store.SQLStore
 	avatarStorage storage.Store
-	avatarLocks   sync.Map
+	// storeForType 按附件记录的存储类型打开后端，为 nil 时只能清理当前后端上的附件对象。
+	storeForType               func(ctx context.Context, storageType string) (storage.Store, error)
+	avatarLocks                sync.Map
+	passwordResetTokenTTL      time.Duration
+	usernamePattern            *regexp.Regexp
+	requireDistinctDisplayName bool
+	bcryptCost                 int
+	avatarThumbnailsDisabled   bool
+	avatarURLTTL               time.Duration
+	avatarLimits               avatarLimits
 }
 
 var (
@@ -42,23 +52,51 @@ var (
 	ErrTokenAlreadyRevoked   = errors.New("access token already revoked")
 	ErrInvalidTokenExpiry    = errors.New("invalid token expiry")
 	ErrRegistrationDisabled  = errors.New("registration is disabled")
-	usernamePattern          = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{2,31}$`)
+	ErrLastAdmin             = errors.New("cannot remove the last admin user")
+	ErrInvalidResetToken     = errors.New("invalid password reset token")
+	ErrInvalidTokenScope     = errors.New("invalid token scope")
+	ErrInvalidInviteToken    = errors.New("invalid invite token")
+	ErrInvalidPageSize       = errors.New("invalid default page size")
+	defaultUsernamePattern   = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{2,31}$`)
 )
 
 const settingKeyAllowRegistration = "allow_registration"
 
+const passwordMinLength = 8
+
+// maxDefaultPageSize 与列表接口的分页硬上限一致，用户默认值不能突破它。
+const maxDefaultPageSize = 200
+
+const defaultPasswordResetTokenTTL = time.Hour
+
+const accessTokenUserAgentMaxLen = 512
+
 const (
-	avatarMaxSourceBytes = 10 * 1024 * 1024
-	avatarMaxDimension   = 4096
-	avatarMaxPixels      = 12_000_000
+	defaultAvatarMaxSourceBytes = 10 * 1024 * 1024
+	defaultAvatarMaxDimension   = 4096
+	defaultAvatarMaxPixels      = 12_000_000
 )
 
+// avatarLimits 为头像原图的校验上限。
+type avatarLimits struct {
+	maxSourceBytes int64
+	maxDimension   int
+	maxPixels      int64
+}
+
 type CreateUserInput struct {
 	Username     string
 	DisplayName  string
 	Password     string
 	Role         string
 	ValidateOnly bool
+	InviteToken  string
+}
+
+type UserDeletionImpact struct {
+	User        models.User
+	Memos       int64
+	Attachments int64
 }
 
 type UserChanges struct {
@@ -67,13 +105,91 @@ type UserChanges struct {
 }
 
 func NewUserService(s *store.SQLStore) *UserService {
-	return &UserService{store: s}
+	return &UserService{
+		store:                 s,
+		passwordResetTokenTTL: defaultPasswordResetTokenTTL,
+		avatarURLTTL:          defaultDirectDownloadURLTTL,
+		avatarLimits: avatarLimits{
+			maxSourceBytes: defaultAvatarMaxSourceBytes,
+			maxDimension:   defaultAvatarMaxDimension,
+			maxPixels:      defaultAvatarMaxPixels,
+		},
+		usernamePattern: defaultUsernamePattern,
+		bcryptCost:      bcrypt.DefaultCost,
+	}
+}
+
+func (s *UserService) SetUsernamePattern(pattern string) error {
+	pattern = strings.TrimSpace(pattern)
+	if pattern == "" {
+		s.usernamePattern = defaultUsernamePattern
+		return nil
+	}
+	compiled, err := regexp.Compile(pattern)
+	if err != nil {
+		return fmt.Errorf("invalid username pattern %q: %w", pattern, err)
+	}
+	s.usernamePattern = compiled
+	return nil
+}
+
+func (s *UserService) SetRequireDistinctDisplayName(required bool) {
+	s.requireDistinctDisplayName = required
+}
+
+func (s *UserService) SetBcryptCost(cost int) {
+	if cost == 0 {
+		cost = bcrypt.DefaultCost
+	}
+	s.bcryptCost = cost
 }
 
 func (s *UserService) SetAvatarStorage(store storage.Store) {
 	s.avatarStorage = store
 }
 
+// SetStoreResolver 设置按附件存储类型（LOCAL/S3/GCS）打开对应后端的方法，删除用户时据此清理附件对象。
+func (s *UserService) SetStoreResolver(resolve func(ctx context.Context, storageType string) (storage.Store, error)) {
+	s.storeForType = resolve
+}
+
+// SetAvatarThumbnailsDisabled 关闭头像缩放重编码，校验通过后直接保存原图。
+func (s *UserService) SetAvatarThumbnailsDisabled(disabled bool) {
+	s.avatarThumbnailsDisabled = disabled
+}
+
+// SetAvatarLimits 设置头像原图的大小、单边尺寸与总像素上限，非正数保留默认值。
+func (s *UserService) SetAvatarLimits(maxSourceBytes int64, maxDimension int, maxPixels int64) {
+	if maxSourceBytes > 0 {
+		s.avatarLimits.maxSourceBytes = maxSourceBytes
+	}
+	if maxDimension > 0 {
+		s.avatarLimits.maxDimension = maxDimension
+	}
+	if maxPixels > 0 {
+		s.avatarLimits.maxPixels = maxPixels
+	}
+}
+
+// SetAvatarURLTTL 设置头像预签名下载地址的有效期，非正数时使用默认值。
+func (s *UserService) SetAvatarURLTTL(ttl time.Duration) {
+	if ttl <= 0 {
+		ttl = defaultDirectDownloadURLTTL
+	}
+	s.avatarURLTTL = ttl
+}
+
+func (s *UserService) SetPasswordResetTokenTTL(ttl time.Duration) {
+	if ttl <= 0 {
+		ttl = defaultPasswordResetTokenTTL
+	}
+	s.passwordResetTokenTTL = ttl
+}
+
+func (s *UserService) CheckDatabase(ctx context.Context) error {
+	return s.store.Ping(ctx)
+}
+
 func (s *UserService) GetUser(ctx context.Context, userID int64) (models.User, error) {
 	return s.store.GetUserByID(ctx, userID)
 }
@@ -89,6 +205,214 @@ func (s *UserService) GetUserByIdentifier(ctx context.Context, identifier string
 	return s.store.GetUserByUsername(ctx, normalizeUsername(identifier))
 }
 
+func (s *UserService) ListUsers(ctx context.Context, limit int, offset int) ([]models.User, int64, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	total, err := s.store.CountUsers(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	users, err := s.store.ListUsers(ctx, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	return users, total, nil
+}
+
+// normalizePassword 去掉首尾空白并校验长度；建号、改密与重置密码共用同一规则。
+func normalizePassword(raw string) (string, error) {
+	password := strings.TrimSpace(raw)
+	if len([]rune(password)) < passwordMinLength {
+		return "", ErrInvalidPassword
+	}
+	return password, nil
+}
+
+func (s *UserService) SetPassword(ctx context.Context, identifier string, newPassword string) (models.User, error) {
+	password, err := normalizePassword(newPassword)
+	if err != nil {
+		return models.User{}, err
+	}
+	user, err := s.GetUserByIdentifier(ctx, identifier)
+	if err != nil {
+		return models.User{}, err
+	}
+	passwordHash, err := bcrypt.GenerateFromPassword([]byte(password), s.bcryptCost)
+	if err != nil {
+		return models.User{}, fmt.Errorf("hash password: %w", err)
+	}
+	if err := s.store.UpdateUserPasswordHash(ctx, user.ID, string(passwordHash)); err != nil {
+		return models.User{}, err
+	}
+	return s.store.GetUserByID(ctx, user.ID)
+}
+
+func (s *UserService) SetRole(ctx context.Context, identifier string, role string) (models.User, error) {
+	normalizedRole := normalizeUserRole(role)
+	if normalizedRole == "" {
+		return models.User{}, ErrInvalidRole
+	}
+	user, err := s.GetUserByIdentifier(ctx, identifier)
+	if err != nil {
+		return models.User{}, err
+	}
+	if user.Role == normalizedRole {
+		return user, nil
+	}
+	// 降级最后一个管理员的判断由存储层在同一条 UPDATE 中完成，并发降级两个管理员时至多一个成功
+	if err := s.store.UpdateUserRole(ctx, user.ID, normalizedRole); err != nil {
+		if errors.Is(err, store.ErrLastSuperUser) {
+			return user, ErrLastAdmin
+		}
+		return models.User{}, err
+	}
+	return s.store.GetUserByID(ctx, user.ID)
+}
+
+func (s *UserService) CreatePasswordResetToken(ctx context.Context, identifier string) (string, time.Time, error) {
+	user, err := s.GetUserByIdentifier(ctx, identifier)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	_, _ = s.CleanupExpiredPasswordResetTokens(ctx)
+
+	expiresAt := time.Now().UTC().Add(s.passwordResetTokenTTL)
+	for i := 0; i < 5; i++ {
+		token, err := generateAccessToken()
+		if err != nil {
+			return "", time.Time{}, err
+		}
+		if err := s.store.CreatePasswordResetToken(ctx, user.ID, token, expiresAt); err == nil {
+			return token, expiresAt, nil
+		} else if !isUniqueConstraintErr(err) {
+			return "", time.Time{}, err
+		}
+	}
+	return "", time.Time{}, ErrTokenAlreadyExists
+}
+
+func (s *UserService) ResetPasswordWithToken(ctx context.Context, rawToken string, newPassword string) (models.User, error) {
+	password, err := normalizePassword(newPassword)
+	if err != nil {
+		return models.User{}, err
+	}
+	rawToken = strings.TrimSpace(rawToken)
+	if rawToken == "" {
+		return models.User{}, ErrInvalidResetToken
+	}
+	passwordHash, err := bcrypt.GenerateFromPassword([]byte(password), s.bcryptCost)
+	if err != nil {
+		return models.User{}, fmt.Errorf("hash password: %w", err)
+	}
+	userID, err := s.store.ResetPasswordWithToken(ctx, rawToken, string(passwordHash))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.User{}, ErrInvalidResetToken
+		}
+		return models.User{}, err
+	}
+	return s.store.GetUserByID(ctx, userID)
+}
+
+func (s *UserService) CleanupExpiredPasswordResetTokens(ctx context.Context) (int64, error) {
+	return s.store.DeleteExpiredPasswordResetTokens(ctx, time.Now().UTC())
+}
+
+func (s *UserService) PreviewDeleteUser(ctx context.Context, identifier string) (UserDeletionImpact, error) {
+	user, err := s.GetUserByIdentifier(ctx, identifier)
+	if err != nil {
+		return UserDeletionImpact{}, err
+	}
+	memos, err := s.store.CountMemosByCreator(ctx, user.ID)
+	if err != nil {
+		return UserDeletionImpact{}, err
+	}
+	attachments, err := s.store.CountAttachmentsByCreator(ctx, user.ID)
+	if err != nil {
+		return UserDeletionImpact{}, err
+	}
+	return UserDeletionImpact{
+		User:        user,
+		Memos:       memos,
+		Attachments: attachments,
+	}, nil
+}
+
+func (s *UserService) DeleteUser(ctx context.Context, identifier string) (models.User, error) {
+	user, err := s.GetUserByIdentifier(ctx, identifier)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	attachments, err := s.store.ListAttachmentsByCreator(ctx, user.ID)
+	if err != nil {
+		return models.User{}, err
+	}
+	// 是否为最后一个管理员由存储层在删除语句中一并判断，避免并发删除两个管理员时都通过检查
+	if err := s.store.DeleteUser(ctx, user.ID); err != nil {
+		if errors.Is(err, store.ErrLastSuperUser) {
+			return user, ErrLastAdmin
+		}
+		return models.User{}, err
+	}
+
+	if s.avatarStorage != nil {
+		_ = s.avatarStorage.Delete(ctx, avatarStorageKey(user.ID))
+	}
+	s.deleteOrphanedAttachmentObjects(ctx, attachments)
+	return user, nil
+}
+
+// deleteOrphanedAttachmentObjects 删除已无记录引用的附件对象与缩略图；每个对象按记录中的存储类型
+// 找到所在后端，切换后端后尚未迁移的旧附件也会从原后端删除。无法打开对应后端时跳过。
+func (s *UserService) deleteOrphanedAttachmentObjects(ctx context.Context, attachments []models.Attachment) {
+	stores := make(map[string]storage.Store)
+	storeFor := func(storageType string) storage.Store {
+		storageType = strings.ToUpper(strings.TrimSpace(storageType))
+		if cached, ok := stores[storageType]; ok {
+			return cached
+		}
+		var resolved storage.Store
+		if s.avatarStorage != nil && storageType == storageTypeName(s.avatarStorage) {
+			resolved = s.avatarStorage
+		} else if s.storeForType != nil {
+			opened, err := s.storeForType(ctx, storageType)
+			if err == nil {
+				resolved = opened
+			}
+		}
+		stores[storageType] = resolved
+		return resolved
+	}
+
+	for _, attachment := range attachments {
+		if strings.TrimSpace(attachment.StorageKey) == "" {
+			continue
+		}
+		refCount, err := s.store.CountAttachmentsByStorageKey(ctx, attachment.StorageKey)
+		if err != nil || refCount > 0 {
+			continue
+		}
+		if objectStore := storeFor(attachment.StorageType); objectStore != nil {
+			_ = objectStore.Delete(ctx, attachment.StorageKey)
+		}
+		if attachment.ThumbnailStorageKey == "" {
+			continue
+		}
+		thumbnailType := attachment.ThumbnailStorageType
+		if strings.TrimSpace(thumbnailType) == "" {
+			thumbnailType = attachment.StorageType
+		}
+		if objectStore := storeFor(thumbnailType); objectStore != nil {
+			_ = objectStore.Delete(ctx, attachment.ThumbnailStorageKey)
+		}
+	}
+}
+
 func (s *UserService) ListUserChanges(
 	ctx context.Context,
 	identifiers []string,
@@ -156,16 +480,23 @@ func (s *UserService) UpdateUserAvatarThumbnail(ctx context.Context, userID int6
 		if err != nil {
 			return models.User{}, fmt.Errorf("invalid avatar content: %w", err)
 		}
-		if err := validateAvatarImage(content, declaredType); err != nil {
+		if err := validateAvatarImage(content, declaredType, s.avatarLimits); err != nil {
 			return models.User{}, err
 		}
 
-		thumbnailData, err := buildThumbnailJPEG(bytes.NewReader(content))
-		if err != nil || len(thumbnailData) == 0 {
-			return models.User{}, fmt.Errorf("invalid avatar image")
+		avatarType := thumbnailContentType
+		avatarData := content
+		if s.avatarThumbnailsDisabled {
+			avatarType = strings.ToLower(http.DetectContentType(content))
+		} else {
+			thumbnailData, err := buildThumbnailJPEG(bytes.NewReader(content))
+			if err != nil || len(thumbnailData) == 0 {
+				return models.User{}, fmt.Errorf("invalid avatar image")
+			}
+			avatarData = thumbnailData
 		}
 
-		if _, err := s.avatarStorage.Put(ctx, avatarStorageKey(userID), thumbnailContentType, thumbnailData); err != nil {
+		if _, err := s.avatarStorage.Put(ctx, avatarStorageKey(userID), avatarType, avatarData); err != nil {
 			return models.User{}, fmt.Errorf("store avatar: %w", err)
 		}
 		return s.store.UpdateUserAvatar(ctx, userID, avatarPublicURL(userID))
@@ -183,19 +514,39 @@ func (s *UserService) ClearUserAvatar(ctx context.Context, userID int64) (models
 	})
 }
 
-func (s *UserService) OpenUserAvatarStream(ctx context.Context, userID int64) (io.ReadCloser, error) {
+// UpdateDefaultPageSize 设置用户列表请求的默认分页大小，0 表示恢复服务端默认值。
+func (s *UserService) UpdateDefaultPageSize(ctx context.Context, userID int64, pageSize int) (models.User, error) {
+	if pageSize < 0 || pageSize > maxDefaultPageSize {
+		return models.User{}, ErrInvalidPageSize
+	}
+	return s.store.UpdateUserDefaultPageSize(ctx, userID, pageSize)
+}
+
+// OpenUserAvatarStream 打开头像并返回按内容识别的类型；关闭缩略图生成后保存的原图不一定是 JPEG。
+func (s *UserService) OpenUserAvatarStream(ctx context.Context, userID int64) (io.ReadCloser, string, error) {
 	if s.avatarStorage == nil {
-		return nil, fmt.Errorf("avatar storage is not configured")
+		return nil, "", fmt.Errorf("avatar storage is not configured")
 	}
-	return s.avatarStorage.Open(ctx, avatarStorageKey(userID))
+	rc, err := s.avatarStorage.Open(ctx, avatarStorageKey(userID))
+	if err != nil {
+		return nil, "", err
+	}
+	br := bufio.NewReader(rc)
+	head, _ := br.Peek(512)
+	return avatarReadCloser{Reader: br, Closer: rc}, strings.ToLower(http.DetectContentType(head)), nil
+}
+
+type avatarReadCloser struct {
+	io.Reader
+	io.Closer
 }
 
 func (s *UserService) PresignUserAvatarURL(ctx context.Context, userID int64) (string, bool, error) {
-	s3Store, ok := s.avatarStorage.(*storage.S3Store)
+	presigner, ok := s.avatarStorage.(storage.Presigner)
 	if !ok {
 		return "", false, nil
 	}
-	url, err := s3Store.PresignGetObjectURL(ctx, avatarStorageKey(userID), directDownloadURLTTL)
+	url, err := presigner.PresignGetObjectURL(ctx, avatarStorageKey(userID), s.avatarURLTTL)
 	if err != nil {
 		return "", false, err
 	}
@@ -203,16 +554,31 @@ func (s *UserService) PresignUserAvatarURL(ctx context.Context, userID int64) (s
 }
 
 func (s *UserService) AuthenticateToken(ctx context.Context, rawToken string) (models.User, error) {
+	user, _, err := s.AuthenticateAccessToken(ctx, rawToken)
+	return user, err
+}
+
+// AuthenticateAccessToken 与 AuthenticateToken 相同，但同时返回令牌本身，便于调用方检查令牌权限范围。
+func (s *UserService) AuthenticateAccessToken(ctx context.Context, rawToken string) (models.User, models.PersonalAccessToken, error) {
+	return s.AuthenticateAccessTokenFromClient(ctx, rawToken, "", "")
+}
+
+// AuthenticateAccessTokenFromClient 在认证的同时记录发起请求的 IP 与 User-Agent，便于用户识别可疑会话。
+func (s *UserService) AuthenticateAccessTokenFromClient(ctx context.Context, rawToken string, ip string, userAgent string) (models.User, models.PersonalAccessToken, error) {
 	rawToken = strings.TrimSpace(rawToken)
 	if rawToken == "" {
-		return models.User{}, sql.ErrNoRows
+		return models.User{}, models.PersonalAccessToken{}, sql.ErrNoRows
 	}
 	user, token, err := s.store.GetUserByToken(ctx, rawToken)
 	if err != nil {
-		return models.User{}, err
+		return models.User{}, models.PersonalAccessToken{}, err
 	}
-	_ = s.store.TouchPersonalAccessToken(ctx, token.ID)
-	return user, nil
+	userAgent = strings.TrimSpace(userAgent)
+	if len(userAgent) > accessTokenUserAgentMaxLen {
+		userAgent = userAgent[:accessTokenUserAgentMaxLen]
+	}
+	_ = s.store.TouchPersonalAccessToken(ctx, token.ID, strings.TrimSpace(ip), userAgent)
+	return user, token, nil
 }
 
 func (s *UserService) EnsureBootstrap(ctx context.Context, username string, rawToken string) error {
@@ -248,20 +614,28 @@ func (s *UserService) EnsureBootstrap(ctx context.Context, username string, rawT
 func (s *UserService) CreateUser(ctx context.Context, creator *models.User, input CreateUserInput, allowRegistration bool) (models.User, error) {
 	username := normalizeUsername(input.Username)
 	displayName := strings.TrimSpace(input.DisplayName)
-	password := strings.TrimSpace(input.Password)
 	role := normalizeUserRole(input.Role)
 
-	if !usernamePattern.MatchString(username) {
+	if !s.usernamePattern.MatchString(username) {
 		return models.User{}, ErrInvalidUsername
 	}
+	if s.requireDistinctDisplayName {
+		if displayName == "" {
+			return models.User{}, fmt.Errorf("%w: display name is required", ErrInvalidDisplayName)
+		}
+		if strings.EqualFold(displayName, username) {
+			return models.User{}, fmt.Errorf("%w: display name must differ from username", ErrInvalidDisplayName)
+		}
+	}
 	if displayName == "" {
 		displayName = username
 	}
 	if len([]rune(displayName)) > 64 {
 		return models.User{}, ErrInvalidDisplayName
 	}
-	if password == "" {
-		return models.User{}, ErrInvalidPassword
+	password, err := normalizePassword(input.Password)
+	if err != nil {
+		return models.User{}, err
 	}
 	if role == "" && strings.TrimSpace(input.Role) != "" && !strings.EqualFold(strings.TrimSpace(input.Role), "ROLE_UNSPECIFIED") {
 		return models.User{}, ErrInvalidRole
@@ -273,8 +647,19 @@ func (s *UserService) CreateUser(ctx context.Context, creator *models.User, inpu
 	}
 	isFirstUser := totalUsers == 0
 	isSuperUser := creator != nil && isSuperUserRole(creator.Role)
+	inviteToken := ""
 	if !isFirstUser && !allowRegistration && !isSuperUser {
-		return models.User{}, ErrRegistrationDisabled
+		inviteToken = strings.TrimSpace(input.InviteToken)
+		if inviteToken == "" {
+			return models.User{}, ErrRegistrationDisabled
+		}
+		usable, err := s.store.InviteTokenUsable(ctx, inviteToken)
+		if err != nil {
+			return models.User{}, err
+		}
+		if !usable {
+			return models.User{}, ErrInvalidInviteToken
+		}
 	}
 
 	roleToAssign := "USER"
@@ -299,21 +684,49 @@ func (s *UserService) CreateUser(ctx context.Context, creator *models.User, inpu
 		return models.User{}, err
 	}
 
-	passwordHash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	passwordHash, err := bcrypt.GenerateFromPassword([]byte(password), s.bcryptCost)
 	if err != nil {
 		return models.User{}, fmt.Errorf("hash password: %w", err)
 	}
 
-	user, err := s.store.CreateUserWithProfile(ctx, username, displayName, string(passwordHash), roleToAssign)
+	var user models.User
+	if inviteToken != "" {
+		user, err = s.store.CreateUserWithInviteToken(ctx, inviteToken, username, displayName, string(passwordHash), roleToAssign)
+	} else {
+		user, err = s.store.CreateUserWithProfile(ctx, username, displayName, string(passwordHash), roleToAssign)
+	}
 	if err != nil {
 		if isUniqueConstraintErr(err) {
 			return models.User{}, ErrUsernameAlreadyExists
 		}
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.User{}, ErrInvalidInviteToken
+		}
 		return models.User{}, err
 	}
 	return user, nil
 }
 
+// CreateInviteToken 生成一次性邀请令牌，关闭公开注册时凭此令牌仍可注册一个账号。
+func (s *UserService) CreateInviteToken(ctx context.Context, ttl time.Duration) (string, time.Time, error) {
+	if ttl <= 0 {
+		return "", time.Time{}, ErrInvalidTokenExpiry
+	}
+	expiresAt := time.Now().UTC().Add(ttl)
+	for i := 0; i < 5; i++ {
+		token, err := generateAccessToken()
+		if err != nil {
+			return "", time.Time{}, err
+		}
+		if err := s.store.CreateInviteToken(ctx, token, expiresAt); err == nil {
+			return token, expiresAt, nil
+		} else if !isUniqueConstraintErr(err) {
+			return "", time.Time{}, err
+		}
+	}
+	return "", time.Time{}, ErrTokenAlreadyExists
+}
+
 func (s *UserService) ResolveAllowRegistration(ctx context.Context, fallback bool) (bool, error) {
 	raw, err := s.store.GetSetting(ctx, settingKeyAllowRegistration)
 	if err != nil {
@@ -345,6 +758,10 @@ func (s *UserService) CreateAccessTokenForUser(ctx context.Context, identifier s
 }
 
 func (s *UserService) CreateAccessTokenForUserWithExpiry(ctx context.Context, identifier string, description string, expiresAt *time.Time) (models.User, string, error) {
+	return s.CreateAccessTokenForUserWithScopes(ctx, identifier, description, expiresAt, nil)
+}
+
+func (s *UserService) CreateAccessTokenForUserWithScopes(ctx context.Context, identifier string, description string, expiresAt *time.Time, scopes []string) (models.User, string, error) {
 	user, err := s.GetUserByIdentifier(ctx, identifier)
 	if err != nil {
 		return models.User{}, "", err
@@ -353,7 +770,7 @@ func (s *UserService) CreateAccessTokenForUserWithExpiry(ctx context.Context, id
 	if description == "" {
 		description = "admin generated token"
 	}
-	token, err := s.createAccessToken(ctx, user.ID, description, expiresAt)
+	token, err := s.createAccessToken(ctx, user.ID, description, expiresAt, scopes)
 	if err != nil {
 		return models.User{}, "", err
 	}
@@ -372,6 +789,51 @@ func (s *UserService) ListAccessTokensForUser(ctx context.Context, identifier st
 	return user, tokens, nil
 }
 
+func (s *UserService) ListAccessTokensForUserPaged(ctx context.Context, identifier string, includeRevoked bool, limit int, offset int) (models.User, []models.PersonalAccessToken, int64, error) {
+	user, err := s.GetUserByIdentifier(ctx, identifier)
+	if err != nil {
+		return models.User{}, nil, 0, err
+	}
+	if limit <= 0 {
+		limit = -1
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	tokens, total, err := s.store.ListPersonalAccessTokensByUserIDPaged(ctx, user.ID, includeRevoked, limit, offset)
+	if err != nil {
+		return models.User{}, nil, 0, err
+	}
+	return user, tokens, total, nil
+}
+
+// ListOwnAccessTokens 返回用户自己的未撤销令牌，供自助管理使用。
+func (s *UserService) ListOwnAccessTokens(ctx context.Context, userID int64) ([]models.PersonalAccessToken, error) {
+	tokens, err := s.store.ListPersonalAccessTokensByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	active := make([]models.PersonalAccessToken, 0, len(tokens))
+	for _, token := range tokens {
+		if token.RevokedAt == nil {
+			active = append(active, token)
+		}
+	}
+	return active, nil
+}
+
+// RevokeOwnAccessToken 撤销用户自己的令牌；令牌不存在或属于其他用户时返回 sql.ErrNoRows。
+func (s *UserService) RevokeOwnAccessToken(ctx context.Context, userID int64, tokenID int64) (models.PersonalAccessToken, error) {
+	token, err := s.store.GetPersonalAccessTokenByID(ctx, tokenID)
+	if err != nil {
+		return models.PersonalAccessToken{}, err
+	}
+	if token.UserID != userID {
+		return models.PersonalAccessToken{}, sql.ErrNoRows
+	}
+	return s.RevokeAccessTokenByID(ctx, tokenID)
+}
+
 func (s *UserService) RevokeAccessTokenByID(ctx context.Context, tokenID int64) (models.PersonalAccessToken, error) {
 	token, err := s.store.GetPersonalAccessTokenByID(ctx, tokenID)
 	if err != nil {
@@ -389,6 +851,43 @@ func (s *UserService) RevokeAccessTokenByID(ctx context.Context, tokenID int64)
 	return s.store.GetPersonalAccessTokenByID(ctx, tokenID)
 }
 
+func (s *UserService) RotateAccessToken(ctx context.Context, tokenID int64) (models.PersonalAccessToken, string, error) {
+	token, err := s.store.GetPersonalAccessTokenByID(ctx, tokenID)
+	if err != nil {
+		return models.PersonalAccessToken{}, "", err
+	}
+	if token.RevokedAt != nil {
+		return token, "", ErrTokenAlreadyRevoked
+	}
+
+	var expiresAt *time.Time
+	if token.ExpiresAt != nil {
+		if !token.ExpiresAt.After(time.Now().UTC()) {
+			return token, "", ErrInvalidTokenExpiry
+		}
+		expires := token.ExpiresAt.UTC()
+		expiresAt = &expires
+	}
+
+	for i := 0; i < 5; i++ {
+		rawToken, err := generateAccessToken()
+		if err != nil {
+			return models.PersonalAccessToken{}, "", err
+		}
+		rotated, err := s.store.RotatePersonalAccessToken(ctx, tokenID, rawToken, expiresAt)
+		if err == nil {
+			return rotated, rawToken, nil
+		}
+		if errors.Is(err, sql.ErrNoRows) {
+			return token, "", ErrTokenAlreadyRevoked
+		}
+		if !isUniqueConstraintErr(err) {
+			return models.PersonalAccessToken{}, "", err
+		}
+	}
+	return models.PersonalAccessToken{}, "", ErrTokenAlreadyExists
+}
+
 func (s *UserService) SignInWithPassword(ctx context.Context, username string, password string) (models.User, string, error) {
 	username = normalizeUsername(username)
 	if username == "" || password == "" {
@@ -409,7 +908,7 @@ func (s *UserService) SignInWithPassword(ctx context.Context, username string, p
 		return models.User{}, "", ErrInvalidCredentials
 	}
 
-	token, err := s.createAccessToken(ctx, user.ID, "signin token", nil)
+	token, err := s.createAccessToken(ctx, user.ID, "signin token", nil, nil)
 	if err != nil {
 		return models.User{}, "", err
 	}
@@ -438,7 +937,7 @@ func normalizeUserIdentifier(raw string) string {
 	return strings.TrimSpace(identifier)
 }
 
-func (s *UserService) createAccessToken(ctx context.Context, userID int64, description string, expiresAt *time.Time) (string, error) {
+func (s *UserService) createAccessToken(ctx context.Context, userID int64, description string, expiresAt *time.Time, scopes []string) (string, error) {
 	var normalizedExpiresAt *time.Time
 	if expiresAt != nil {
 		expires := expiresAt.UTC()
@@ -453,7 +952,7 @@ func (s *UserService) createAccessToken(ctx context.Context, userID int64, descr
 		if err != nil {
 			return "", err
 		}
-		if _, err := s.store.CreatePersonalAccessTokenWithExpiry(ctx, userID, token, description, normalizedExpiresAt); err == nil {
+		if _, err := s.store.CreatePersonalAccessTokenWithScopes(ctx, userID, token, description, normalizedExpiresAt, scopes); err == nil {
 			return token, nil
 		} else if !isUniqueConstraintErr(err) {
 			return "", err
@@ -515,11 +1014,11 @@ func decodeBase64Payload(content string) ([]byte, error) {
 	return nil, fmt.Errorf("decode base64 failed")
 }
 
-func validateAvatarImage(content []byte, declaredType string) error {
+func validateAvatarImage(content []byte, declaredType string, limits avatarLimits) error {
 	if len(content) == 0 {
 		return fmt.Errorf("avatar content is empty")
 	}
-	if len(content) > avatarMaxSourceBytes {
+	if int64(len(content)) > limits.maxSourceBytes {
 		return fmt.Errorf("avatar content too large")
 	}
 
@@ -543,10 +1042,10 @@ func validateAvatarImage(content []byte, declaredType string) error {
 	if config.Width <= 0 || config.Height <= 0 {
 		return fmt.Errorf("invalid avatar dimensions")
 	}
-	if config.Width > avatarMaxDimension || config.Height > avatarMaxDimension {
+	if config.Width > limits.maxDimension || config.Height > limits.maxDimension {
 		return fmt.Errorf("avatar dimensions exceed limit")
 	}
-	if int64(config.Width)*int64(config.Height) > avatarMaxPixels {
+	if int64(config.Width)*int64(config.Height) > limits.maxPixels {
 		return fmt.Errorf("avatar pixel count exceed limit")
 	}
 	return nil
@@ -4,9 +4,17 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
+	"os"
 	"strconv"
+	"sync"
 	"testing"
 	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/shinyes/keer/internal/models"
+	"github.com/shinyes/keer/internal/storage"
 )
 
 func TestCreateUser_FirstUserIsAdmin(t *testing.T) {
@@ -77,6 +85,48 @@ func TestCreateUser_RegistrationDisabledForSecondUser(t *testing.T) {
 	}
 }
 
+func TestCreateUser_InviteTokenBypassesDisabledRegistrationOnce(t *testing.T) {
+	services := setupTestServices(t)
+	userService := NewUserService(services.store)
+	ctx := context.Background()
+
+	if _, err := userService.CreateUser(ctx, nil, CreateUserInput{Username: "owner01", Password: "pass-123"}, true); err != nil {
+		t.Fatalf("first CreateUser() error = %v", err)
+	}
+	invite, _, err := userService.CreateInviteToken(ctx, time.Hour)
+	if err != nil {
+		t.Fatalf("CreateInviteToken() error = %v", err)
+	}
+
+	if _, err := userService.CreateUser(ctx, nil, CreateUserInput{Username: "user02", Password: "pass-123", InviteToken: "bogus"}, false); !errors.Is(err, ErrInvalidInviteToken) {
+		t.Fatalf("expected ErrInvalidInviteToken for unknown invite, got %v", err)
+	}
+	if _, err := userService.CreateUser(ctx, nil, CreateUserInput{Username: "owner01", Password: "pass-123", InviteToken: invite}, false); !errors.Is(err, ErrUsernameAlreadyExists) {
+		t.Fatalf("expected ErrUsernameAlreadyExists, got %v", err)
+	}
+	user, err := userService.CreateUser(ctx, nil, CreateUserInput{Username: "user02", Password: "pass-123", InviteToken: invite}, false)
+	if err != nil {
+		t.Fatalf("CreateUser() with invite error = %v", err)
+	}
+	if user.Role != "USER" {
+		t.Fatalf("expected invited user role USER, got %s", user.Role)
+	}
+	if _, err := userService.CreateUser(ctx, nil, CreateUserInput{Username: "user03", Password: "pass-123", InviteToken: invite}, false); !errors.Is(err, ErrInvalidInviteToken) {
+		t.Fatalf("expected consumed invite to be rejected, got %v", err)
+	}
+
+	expired, _, err := userService.CreateInviteToken(ctx, time.Hour)
+	if err != nil {
+		t.Fatalf("CreateInviteToken() error = %v", err)
+	}
+	if _, err := services.store.DB().ExecContext(ctx, `UPDATE invite_tokens SET expires_at = ? WHERE consumed_at IS NULL`, time.Now().UTC().Add(-time.Minute).Format(time.RFC3339Nano)); err != nil {
+		t.Fatalf("expire invite error = %v", err)
+	}
+	if _, err := userService.CreateUser(ctx, nil, CreateUserInput{Username: "user04", Password: "pass-123", InviteToken: expired}, false); !errors.Is(err, ErrInvalidInviteToken) {
+		t.Fatalf("expected expired invite to be rejected, got %v", err)
+	}
+}
+
 func TestCreateUser_ValidateOnlyDoesNotPersist(t *testing.T) {
 	services := setupTestServices(t)
 	userService := NewUserService(services.store)
@@ -140,6 +190,26 @@ func TestCreateUser_EmptyPassword(t *testing.T) {
 	}
 }
 
+func TestCreateUser_ShortPassword(t *testing.T) {
+	services := setupTestServices(t)
+	userService := NewUserService(services.store)
+	ctx := context.Background()
+
+	// 首尾空白不计入长度，与改密、重置密码的规则一致
+	if _, err := userService.CreateUser(ctx, nil, CreateUserInput{
+		Username: "shortpass01",
+		Password: "  pass-12  ",
+	}, true); !errors.Is(err, ErrInvalidPassword) {
+		t.Fatalf("expected ErrInvalidPassword, got %v", err)
+	}
+	if _, err := userService.CreateUser(ctx, nil, CreateUserInput{
+		Username: "shortpass01",
+		Password: "pass-123",
+	}, true); err != nil {
+		t.Fatalf("CreateUser() with minimum length password error = %v", err)
+	}
+}
+
 func TestSignInWithPassword_Success(t *testing.T) {
 	services := setupTestServices(t)
 	userService := NewUserService(services.store)
@@ -229,6 +299,518 @@ func TestResolveAllowRegistration(t *testing.T) {
 	}
 }
 
+func TestListUsers_Paginates(t *testing.T) {
+	services := setupTestServices(t)
+	userService := NewUserService(services.store)
+	ctx := context.Background()
+
+	for _, username := range []string{"list-user01", "list-user02", "list-user03"} {
+		mustCreateUser(t, services.store, username)
+	}
+
+	firstPage, total, err := userService.ListUsers(ctx, 2, 0)
+	if err != nil {
+		t.Fatalf("ListUsers(page1) error = %v", err)
+	}
+	if total != 3 {
+		t.Fatalf("expected total=3, got %d", total)
+	}
+	if len(firstPage) != 2 || firstPage[0].Username != "list-user01" || firstPage[1].Username != "list-user02" {
+		t.Fatalf("unexpected first page: %+v", firstPage)
+	}
+
+	secondPage, _, err := userService.ListUsers(ctx, 2, 2)
+	if err != nil {
+		t.Fatalf("ListUsers(page2) error = %v", err)
+	}
+	if len(secondPage) != 1 || secondPage[0].Username != "list-user03" {
+		t.Fatalf("unexpected second page: %+v", secondPage)
+	}
+}
+
+func TestDeleteUser_CascadesAndProtectsLastAdmin(t *testing.T) {
+	services := setupTestServices(t)
+	userService := NewUserService(services.store)
+	ctx := context.Background()
+
+	admin, err := userService.CreateUser(ctx, nil, CreateUserInput{
+		Username: "delete-admin01",
+		Password: "pass-123",
+	}, true)
+	if err != nil {
+		t.Fatalf("CreateUser(admin) error = %v", err)
+	}
+	member, err := userService.CreateUser(ctx, &admin, CreateUserInput{
+		Username: "delete-user01",
+		Password: "pass-123",
+	}, true)
+	if err != nil {
+		t.Fatalf("CreateUser(member) error = %v", err)
+	}
+	if _, err := services.memoService.CreateMemo(ctx, member.ID, CreateMemoInput{
+		Content:    "doomed memo",
+		Visibility: models.VisibilityPrivate,
+	}); err != nil {
+		t.Fatalf("CreateMemo() error = %v", err)
+	}
+
+	impact, err := userService.PreviewDeleteUser(ctx, member.Username)
+	if err != nil {
+		t.Fatalf("PreviewDeleteUser() error = %v", err)
+	}
+	if impact.Memos != 1 || impact.Attachments != 0 {
+		t.Fatalf("unexpected impact: memos=%d attachments=%d", impact.Memos, impact.Attachments)
+	}
+
+	if _, err := userService.DeleteUser(ctx, member.Username); err != nil {
+		t.Fatalf("DeleteUser(member) error = %v", err)
+	}
+	if _, err := userService.GetUser(ctx, member.ID); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("expected deleted user to be gone, got %v", err)
+	}
+	if count, err := services.store.CountMemosByCreator(ctx, member.ID); err != nil || count != 0 {
+		t.Fatalf("expected memos to cascade, count=%d err=%v", count, err)
+	}
+
+	if _, err := userService.DeleteUser(ctx, admin.Username); !errors.Is(err, ErrLastAdmin) {
+		t.Fatalf("expected ErrLastAdmin, got %v", err)
+	}
+}
+
+func TestDeleteUser_RemovesCollaboratorTags(t *testing.T) {
+	services := setupTestServices(t)
+	userService := NewUserService(services.store)
+	ctx := context.Background()
+
+	owner := mustCreateUser(t, services.store, "collab-owner")
+	collaborator := mustCreateUser(t, services.store, "collab-gone")
+	collaboratorTag := fmt.Sprintf("collab/%d", collaborator.ID)
+	created, err := services.memoService.CreateMemo(ctx, owner.ID, CreateMemoInput{
+		Content:    "shared memo",
+		Visibility: models.VisibilityPrivate,
+		Tags:       []string{"work", collaboratorTag},
+	})
+	if err != nil {
+		t.Fatalf("CreateMemo() error = %v", err)
+	}
+	eventsBefore, err := services.memoService.ListMemoEvents(ctx, owner.ID, created.Memo.ID)
+	if err != nil {
+		t.Fatalf("ListMemoEvents() error = %v", err)
+	}
+
+	if _, err := userService.DeleteUser(ctx, collaborator.Username); err != nil {
+		t.Fatalf("DeleteUser() error = %v", err)
+	}
+
+	memo, err := services.memoService.GetMemo(ctx, owner.ID, created.Memo.ID)
+	if err != nil {
+		t.Fatalf("GetMemo() error = %v", err)
+	}
+	if len(memo.Memo.Payload.Tags) != 1 || memo.Memo.Payload.Tags[0] != "work" {
+		t.Fatalf("expected collaborator tag removed, got %v", memo.Memo.Payload.Tags)
+	}
+	eventsAfter, err := services.memoService.ListMemoEvents(ctx, owner.ID, created.Memo.ID)
+	if err != nil {
+		t.Fatalf("ListMemoEvents() error = %v", err)
+	}
+	if len(eventsAfter) != len(eventsBefore) {
+		t.Fatalf("expected no events for collaborator cleanup, before=%d after=%d", len(eventsBefore), len(eventsAfter))
+	}
+}
+
+func TestSetUsernamePattern(t *testing.T) {
+	services := setupTestServices(t)
+	userService := NewUserService(services.store)
+	ctx := context.Background()
+
+	input := CreateUserInput{Username: "alice.smith", Password: "pass-123"}
+	if _, err := userService.CreateUser(ctx, nil, input, true); !errors.Is(err, ErrInvalidUsername) {
+		t.Fatalf("expected default pattern to reject dotted username, got %v", err)
+	}
+	if err := userService.SetUsernamePattern(`^[a-z0-9][a-z0-9._-]{2,63}$`); err != nil {
+		t.Fatalf("SetUsernamePattern() error = %v", err)
+	}
+	if _, err := userService.CreateUser(ctx, nil, input, true); err != nil {
+		t.Fatalf("CreateUser() with custom pattern error = %v", err)
+	}
+	if err := userService.SetUsernamePattern(`^[a-z(`); err == nil {
+		t.Fatalf("expected invalid pattern to be rejected")
+	}
+}
+
+func TestDeleteUser_CascadesDependentRows(t *testing.T) {
+	services := setupTestServices(t)
+	ctx := context.Background()
+	sqliteDB := services.store.DB()
+
+	// 不保留空闲连接，强制后续每次查询都使用新连接，验证外键约束在每个连接上都已开启
+	sqliteDB.SetMaxIdleConns(0)
+	t.Cleanup(func() { sqliteDB.SetMaxIdleConns(1) })
+
+	owner := mustCreateUser(t, services.store, "cascade-owner")
+	if _, err := services.store.CreatePersonalAccessToken(ctx, owner.ID, "cascade-token", "test"); err != nil {
+		t.Fatalf("CreatePersonalAccessToken() error = %v", err)
+	}
+	attachment, err := services.store.CreateAttachment(ctx, owner.ID, "a.txt", "", "text/plain", 1, "hash", "LOCAL", "cascade/a.txt")
+	if err != nil {
+		t.Fatalf("CreateAttachment() error = %v", err)
+	}
+	if _, err := services.memoService.CreateMemo(ctx, owner.ID, CreateMemoInput{
+		Content:         "cascade memo #work",
+		Visibility:      models.VisibilityPrivate,
+		AttachmentNames: []string{"attachments/" + models.Int64ToString(attachment.ID)},
+	}); err != nil {
+		t.Fatalf("CreateMemo() error = %v", err)
+	}
+
+	if err := services.store.DeleteUser(ctx, owner.ID); err != nil {
+		t.Fatalf("DeleteUser() error = %v", err)
+	}
+
+	for _, table := range []string{"personal_access_tokens", "memos", "attachments", "memo_attachments", "tags", "memo_tags"} {
+		var count int
+		if err := sqliteDB.QueryRowContext(ctx, `SELECT COUNT(1) FROM `+table).Scan(&count); err != nil {
+			t.Fatalf("count %s error = %v", table, err)
+		}
+		if count != 0 {
+			t.Fatalf("expected %s rows to be removed by cascade, got %d", table, count)
+		}
+	}
+}
+
+func TestDeleteUser_EmitsDeleteEventsAndCleansObjectsByStorageType(t *testing.T) {
+	services := setupTestServices(t)
+	ctx := context.Background()
+	userService := NewUserService(services.store)
+
+	localStore, err := storage.NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocalStore() error = %v", err)
+	}
+	// 模拟切换后端前留在 S3 上、尚未迁移的附件
+	legacyStore, err := storage.NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocalStore() error = %v", err)
+	}
+	userService.SetAvatarStorage(localStore)
+	userService.SetStoreResolver(func(_ context.Context, storageType string) (storage.Store, error) {
+		if storageType == storageTypeS3 {
+			return legacyStore, nil
+		}
+		return nil, fmt.Errorf("unexpected storage type %s", storageType)
+	})
+
+	viewer := mustCreateUser(t, services.store, "delete-viewer")
+	owner := mustCreateUser(t, services.store, "delete-owner")
+	memo, err := services.memoService.CreateMemo(ctx, owner.ID, CreateMemoInput{
+		Content:    "public memo",
+		Visibility: models.VisibilityPublic,
+	})
+	if err != nil {
+		t.Fatalf("CreateMemo() error = %v", err)
+	}
+	for _, item := range []struct {
+		store storage.Store
+		typ   string
+		key   string
+	}{
+		{localStore, storageTypeLocal, "owner/local.txt"},
+		{legacyStore, storageTypeS3, "owner/s3.txt"},
+	} {
+		if _, err := item.store.Put(ctx, item.key, "text/plain", []byte("x")); err != nil {
+			t.Fatalf("Put(%s) error = %v", item.key, err)
+		}
+		if _, err := services.store.CreateAttachment(ctx, owner.ID, "a.txt", "", "text/plain", 1, "", item.typ, item.key); err != nil {
+			t.Fatalf("CreateAttachment(%s) error = %v", item.key, err)
+		}
+	}
+
+	since := time.Now().UTC().Add(-time.Second)
+	changed, unsubscribe := services.store.SubscribeMemoChanges(viewer.ID)
+	defer unsubscribe()
+	if _, err := userService.DeleteUser(ctx, owner.Username); err != nil {
+		t.Fatalf("DeleteUser() error = %v", err)
+	}
+
+	select {
+	case <-changed:
+	default:
+		t.Fatalf("expected watchers to be notified of deleted memos")
+	}
+	changes, err := services.memoService.ListMemoChanges(ctx, viewer.ID, nil, "", since, time.Now().UTC().Add(time.Second))
+	if err != nil {
+		t.Fatalf("ListMemoChanges() error = %v", err)
+	}
+	if len(changes.DeletedMemoNames) != 1 || changes.DeletedMemoNames[0] != memo.Memo.Name() {
+		t.Fatalf("expected delete event for %s, got %v", memo.Memo.Name(), changes.DeletedMemoNames)
+	}
+	if _, err := localStore.Open(ctx, "owner/local.txt"); !os.IsNotExist(err) {
+		t.Fatalf("expected local attachment object removed, err=%v", err)
+	}
+	if _, err := legacyStore.Open(ctx, "owner/s3.txt"); !os.IsNotExist(err) {
+		t.Fatalf("expected s3 attachment object removed from its own store, err=%v", err)
+	}
+}
+
+func TestSetRequireDistinctDisplayName(t *testing.T) {
+	services := setupTestServices(t)
+	userService := NewUserService(services.store)
+	ctx := context.Background()
+
+	if _, err := userService.CreateUser(ctx, nil, CreateUserInput{Username: "plain-user01", Password: "pass-123"}, true); err != nil {
+		t.Fatalf("expected default to allow empty display name, got %v", err)
+	}
+
+	userService.SetRequireDistinctDisplayName(true)
+	cases := []CreateUserInput{
+		{Username: "plain-user02", Password: "pass-123"},
+		{Username: "plain-user02", DisplayName: "Plain-User02", Password: "pass-123"},
+	}
+	for _, input := range cases {
+		if _, err := userService.CreateUser(ctx, nil, input, true); !errors.Is(err, ErrInvalidDisplayName) {
+			t.Fatalf("expected ErrInvalidDisplayName for display name %q, got %v", input.DisplayName, err)
+		}
+	}
+	created, err := userService.CreateUser(ctx, nil, CreateUserInput{
+		Username:    "plain-user02",
+		DisplayName: "Plain User",
+		Password:    "pass-123",
+	}, true)
+	if err != nil {
+		t.Fatalf("CreateUser() with distinct display name error = %v", err)
+	}
+	if created.DisplayName != "Plain User" {
+		t.Fatalf("expected display name to be kept, got %q", created.DisplayName)
+	}
+}
+
+func TestSetPassword(t *testing.T) {
+	services := setupTestServices(t)
+	userService := NewUserService(services.store)
+	ctx := context.Background()
+
+	created, err := userService.CreateUser(ctx, nil, CreateUserInput{
+		Username: "reset-pass01",
+		Password: "pass-123",
+	}, true)
+	if err != nil {
+		t.Fatalf("CreateUser() error = %v", err)
+	}
+
+	if _, err := userService.SetPassword(ctx, created.Username, "short"); !errors.Is(err, ErrInvalidPassword) {
+		t.Fatalf("expected ErrInvalidPassword for short password, got %v", err)
+	}
+	if _, err := userService.SetPassword(ctx, "missing-user", "new-pass-456"); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("expected sql.ErrNoRows for unknown user, got %v", err)
+	}
+	if _, err := userService.SetPassword(ctx, strconv.FormatInt(created.ID, 10), "new-pass-456"); err != nil {
+		t.Fatalf("SetPassword() error = %v", err)
+	}
+
+	if _, _, err := userService.SignInWithPassword(ctx, created.Username, "pass-123"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("expected old password to be rejected, got %v", err)
+	}
+	if _, _, err := userService.SignInWithPassword(ctx, created.Username, "new-pass-456"); err != nil {
+		t.Fatalf("SignInWithPassword(new) error = %v", err)
+	}
+}
+
+func TestSetRole_ProtectsLastAdmin(t *testing.T) {
+	services := setupTestServices(t)
+	userService := NewUserService(services.store)
+	ctx := context.Background()
+
+	admin, err := userService.CreateUser(ctx, nil, CreateUserInput{
+		Username: "role-admin01",
+		Password: "pass-123",
+		Role:     "ADMIN",
+	}, true)
+	if err != nil {
+		t.Fatalf("CreateUser(admin) error = %v", err)
+	}
+	member, err := userService.CreateUser(ctx, nil, CreateUserInput{
+		Username: "role-user01",
+		Password: "pass-123",
+	}, true)
+	if err != nil {
+		t.Fatalf("CreateUser(user) error = %v", err)
+	}
+
+	if _, err := userService.SetRole(ctx, member.Username, "OWNER"); !errors.Is(err, ErrInvalidRole) {
+		t.Fatalf("expected ErrInvalidRole, got %v", err)
+	}
+	if _, err := userService.SetRole(ctx, admin.Username, "user"); !errors.Is(err, ErrLastAdmin) {
+		t.Fatalf("expected ErrLastAdmin when demoting last admin, got %v", err)
+	}
+
+	promoted, err := userService.SetRole(ctx, member.Username, "admin")
+	if err != nil {
+		t.Fatalf("SetRole(promote) error = %v", err)
+	}
+	if promoted.Role != "ADMIN" {
+		t.Fatalf("expected promoted role ADMIN, got %s", promoted.Role)
+	}
+	demoted, err := userService.SetRole(ctx, admin.Username, "USER")
+	if err != nil {
+		t.Fatalf("SetRole(demote) error = %v", err)
+	}
+	if demoted.Role != "USER" {
+		t.Fatalf("expected demoted role USER, got %s", demoted.Role)
+	}
+}
+
+func TestSetRole_ConcurrentDemotionsKeepOneAdmin(t *testing.T) {
+	services := setupTestServices(t)
+	userService := NewUserService(services.store)
+	ctx := context.Background()
+
+	admins := make([]models.User, 0, 2)
+	for _, username := range []string{"race-admin01", "race-admin02"} {
+		admin, err := userService.CreateUser(ctx, nil, CreateUserInput{Username: username, Password: "pass-1234", Role: "ADMIN"}, true)
+		if err != nil {
+			t.Fatalf("CreateUser(%s) error = %v", username, err)
+		}
+		admins = append(admins, admin)
+	}
+
+	errs := make([]error, len(admins))
+	var wg sync.WaitGroup
+	for i, admin := range admins {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, errs[i] = userService.SetRole(ctx, admin.Username, "USER")
+		}()
+	}
+	wg.Wait()
+
+	lastAdminErrors := 0
+	for _, err := range errs {
+		switch {
+		case err == nil:
+		case errors.Is(err, ErrLastAdmin):
+			lastAdminErrors++
+		default:
+			t.Fatalf("SetRole() error = %v", err)
+		}
+	}
+	if lastAdminErrors != 1 {
+		t.Fatalf("expected exactly one demotion to be rejected, got errors %v", errs)
+	}
+	remaining := 0
+	for _, admin := range admins {
+		current, err := userService.GetUser(ctx, admin.ID)
+		if err != nil {
+			t.Fatalf("GetUser() error = %v", err)
+		}
+		if current.Role == "ADMIN" {
+			remaining++
+		}
+	}
+	if remaining != 1 {
+		t.Fatalf("expected one admin to remain, got %d", remaining)
+	}
+}
+
+func TestPasswordResetToken_SingleUseAndExpiry(t *testing.T) {
+	services := setupTestServices(t)
+	userService := NewUserService(services.store)
+	ctx := context.Background()
+
+	created, err := userService.CreateUser(ctx, nil, CreateUserInput{
+		Username: "reset-token01",
+		Password: "pass-123",
+	}, true)
+	if err != nil {
+		t.Fatalf("CreateUser() error = %v", err)
+	}
+
+	token, expiresAt, err := userService.CreatePasswordResetToken(ctx, created.Username)
+	if err != nil {
+		t.Fatalf("CreatePasswordResetToken() error = %v", err)
+	}
+	if len(token) < 43 {
+		t.Fatalf("expected 32-byte token, got %q", token)
+	}
+	if !expiresAt.After(time.Now().UTC().Add(59 * time.Minute)) {
+		t.Fatalf("expected default ttl of 1h, got expiresAt=%s", expiresAt)
+	}
+
+	var storedHash string
+	if err := services.store.DB().QueryRowContext(ctx, `SELECT token_hash FROM password_reset_tokens WHERE user_id = ?`, created.ID).Scan(&storedHash); err != nil {
+		t.Fatalf("query reset token: %v", err)
+	}
+	if storedHash == token {
+		t.Fatalf("expected reset token to be stored hashed")
+	}
+
+	if _, err := userService.ResetPasswordWithToken(ctx, token, "new-pass-456"); err != nil {
+		t.Fatalf("ResetPasswordWithToken() error = %v", err)
+	}
+	if _, err := userService.ResetPasswordWithToken(ctx, token, "other-pass-789"); !errors.Is(err, ErrInvalidResetToken) {
+		t.Fatalf("expected reused token to be rejected, got %v", err)
+	}
+	if _, _, err := userService.SignInWithPassword(ctx, created.Username, "new-pass-456"); err != nil {
+		t.Fatalf("SignInWithPassword(new) error = %v", err)
+	}
+
+	userService.SetPasswordResetTokenTTL(time.Millisecond)
+	expired, _, err := userService.CreatePasswordResetToken(ctx, created.Username)
+	if err != nil {
+		t.Fatalf("CreatePasswordResetToken(expired) error = %v", err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	if _, err := userService.ResetPasswordWithToken(ctx, expired, "new-pass-000"); !errors.Is(err, ErrInvalidResetToken) {
+		t.Fatalf("expected expired token to be rejected, got %v", err)
+	}
+	removed, err := userService.CleanupExpiredPasswordResetTokens(ctx)
+	if err != nil {
+		t.Fatalf("CleanupExpiredPasswordResetTokens() error = %v", err)
+	}
+	if removed != 1 {
+		t.Fatalf("expected 1 expired token removed, got %d", removed)
+	}
+}
+
+func TestResetPasswordWithToken_RevokesExistingCredentials(t *testing.T) {
+	services := setupTestServices(t)
+	userService := NewUserService(services.store)
+	ctx := context.Background()
+
+	created, err := userService.CreateUser(ctx, nil, CreateUserInput{
+		Username: "reset-revoke01",
+		Password: "pass-123",
+	}, true)
+	if err != nil {
+		t.Fatalf("CreateUser() error = %v", err)
+	}
+	_, sessionToken, err := userService.SignInWithPassword(ctx, created.Username, "pass-123")
+	if err != nil {
+		t.Fatalf("SignInWithPassword() error = %v", err)
+	}
+	token, _, err := userService.CreatePasswordResetToken(ctx, created.Username)
+	if err != nil {
+		t.Fatalf("CreatePasswordResetToken() error = %v", err)
+	}
+	other, _, err := userService.CreatePasswordResetToken(ctx, created.Username)
+	if err != nil {
+		t.Fatalf("CreatePasswordResetToken(other) error = %v", err)
+	}
+
+	if _, err := userService.ResetPasswordWithToken(ctx, token, "new-pass-456"); err != nil {
+		t.Fatalf("ResetPasswordWithToken() error = %v", err)
+	}
+	if _, err := userService.AuthenticateToken(ctx, sessionToken); err == nil {
+		t.Fatalf("expected access token issued before reset to be revoked")
+	}
+	if _, err := userService.ResetPasswordWithToken(ctx, other, "other-pass-789"); !errors.Is(err, ErrInvalidResetToken) {
+		t.Fatalf("expected other outstanding reset token to be invalidated, got %v", err)
+	}
+	if _, _, err := userService.SignInWithPassword(ctx, created.Username, "new-pass-456"); err != nil {
+		t.Fatalf("SignInWithPassword(new) error = %v", err)
+	}
+}
+
 func TestCreateAccessTokenForUser(t *testing.T) {
 	services := setupTestServices(t)
 	userService := NewUserService(services.store)
@@ -366,6 +948,51 @@ func TestListAccessTokensForUser(t *testing.T) {
 	}
 }
 
+func TestListAccessTokensForUserPaged(t *testing.T) {
+	services := setupTestServices(t)
+	userService := NewUserService(services.store)
+	ctx := context.Background()
+
+	created, err := userService.CreateUser(ctx, nil, CreateUserInput{
+		Username: "token-page01",
+		Password: "pass-123",
+	}, true)
+	if err != nil {
+		t.Fatalf("CreateUser() error = %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, _, err := userService.CreateAccessTokenForUser(ctx, created.Username, "page-token-"+strconv.Itoa(i)); err != nil {
+			t.Fatalf("CreateAccessTokenForUser(%d) error = %v", i, err)
+		}
+	}
+	_, all, err := userService.ListAccessTokensForUser(ctx, created.Username)
+	if err != nil {
+		t.Fatalf("ListAccessTokensForUser() error = %v", err)
+	}
+	if _, err := userService.RevokeAccessTokenByID(ctx, all[0].ID); err != nil {
+		t.Fatalf("RevokeAccessTokenByID() error = %v", err)
+	}
+
+	_, active, total, err := userService.ListAccessTokensForUserPaged(ctx, created.Username, false, 0, 0)
+	if err != nil {
+		t.Fatalf("ListAccessTokensForUserPaged(active) error = %v", err)
+	}
+	if total != 2 || len(active) != 2 {
+		t.Fatalf("expected 2 active tokens, got total=%d len=%d", total, len(active))
+	}
+
+	_, page, total, err := userService.ListAccessTokensForUserPaged(ctx, created.Username, true, 2, 2)
+	if err != nil {
+		t.Fatalf("ListAccessTokensForUserPaged(page) error = %v", err)
+	}
+	if total != 3 {
+		t.Fatalf("expected total=3, got %d", total)
+	}
+	if len(page) != 1 || page[0].ID != all[2].ID {
+		t.Fatalf("expected last token on second page, got %+v", page)
+	}
+}
+
 func TestRevokeAccessTokenByID(t *testing.T) {
 	services := setupTestServices(t)
 	userService := NewUserService(services.store)
@@ -405,6 +1032,68 @@ func TestRevokeAccessTokenByID(t *testing.T) {
 	}
 }
 
+func TestRotateAccessToken(t *testing.T) {
+	services := setupTestServices(t)
+	userService := NewUserService(services.store)
+	ctx := context.Background()
+
+	created, err := userService.CreateUser(ctx, nil, CreateUserInput{
+		Username: "token-rotate01",
+		Password: "pass-123",
+	}, true)
+	if err != nil {
+		t.Fatalf("CreateUser() error = %v", err)
+	}
+
+	expiresAt := time.Now().UTC().Add(48 * time.Hour)
+	_, oldRawToken, err := userService.CreateAccessTokenForUserWithExpiry(ctx, created.Username, "rotate-me", &expiresAt)
+	if err != nil {
+		t.Fatalf("CreateAccessTokenForUserWithExpiry() error = %v", err)
+	}
+	_, oldRecord, err := services.store.GetUserByToken(ctx, oldRawToken)
+	if err != nil {
+		t.Fatalf("GetUserByToken() error = %v", err)
+	}
+
+	rotated, newRawToken, err := userService.RotateAccessToken(ctx, oldRecord.ID)
+	if err != nil {
+		t.Fatalf("RotateAccessToken() error = %v", err)
+	}
+	if newRawToken == "" || newRawToken == oldRawToken {
+		t.Fatalf("expected a fresh token, got %q", newRawToken)
+	}
+	if rotated.ID == oldRecord.ID {
+		t.Fatalf("expected new token id, got %d", rotated.ID)
+	}
+	if rotated.UserID != created.ID {
+		t.Fatalf("expected token user_id=%d, got %d", created.ID, rotated.UserID)
+	}
+	if rotated.Description != "rotate-me" {
+		t.Fatalf("expected description to be copied, got %q", rotated.Description)
+	}
+	if rotated.ExpiresAt == nil {
+		t.Fatalf("expected expires_at to be set")
+	}
+	if diff := rotated.ExpiresAt.Sub(*oldRecord.ExpiresAt); diff < -time.Second || diff > time.Second {
+		t.Fatalf("expected expires_at to keep remaining lifetime, old=%s new=%s", oldRecord.ExpiresAt.Format(time.RFC3339Nano), rotated.ExpiresAt.Format(time.RFC3339Nano))
+	}
+
+	if _, err := userService.AuthenticateToken(ctx, oldRawToken); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("expected old token authentication to fail with sql.ErrNoRows, got %v", err)
+	}
+	user, err := userService.AuthenticateToken(ctx, newRawToken)
+	if err != nil {
+		t.Fatalf("AuthenticateToken(new) error = %v", err)
+	}
+	if user.ID != created.ID {
+		t.Fatalf("expected user ID %d, got %d", created.ID, user.ID)
+	}
+
+	if _, _, err := userService.RotateAccessToken(ctx, oldRecord.ID); !errors.Is(err, ErrTokenAlreadyRevoked) {
+		t.Fatalf("expected ErrTokenAlreadyRevoked on rotating revoked token, got %v", err)
+	}
+}
+
 func TestListUserChanges_UsesIncrementalWindowAndIdentifierForms(t *testing.T) {
 	services := setupTestServices(t)
 	userService := NewUserService(services.store)
@@ -471,3 +1160,38 @@ func TestListUserChanges_UsesIncrementalWindowAndIdentifierForms(t *testing.T) {
 		t.Fatalf("expected empty changes when since is after anchor, got %d", len(emptyWindow.Users))
 	}
 }
+
+func TestSetBcryptCost(t *testing.T) {
+	services := setupTestServices(t)
+	userService := NewUserService(services.store)
+	ctx := context.Background()
+
+	hashCost := func(username string) int {
+		t.Helper()
+		user, err := services.store.GetUserByUsername(ctx, username)
+		if err != nil {
+			t.Fatalf("GetUserByUsername(%s) error = %v", username, err)
+		}
+		cost, err := bcrypt.Cost([]byte(user.PasswordHash))
+		if err != nil {
+			t.Fatalf("bcrypt.Cost() error = %v", err)
+		}
+		return cost
+	}
+
+	userService.SetBcryptCost(bcrypt.MinCost)
+	if _, err := userService.CreateUser(ctx, nil, CreateUserInput{Username: "cost-user01", Password: "pass-123"}, true); err != nil {
+		t.Fatalf("CreateUser() error = %v", err)
+	}
+	if cost := hashCost("cost-user01"); cost != bcrypt.MinCost {
+		t.Fatalf("expected CreateUser to hash with cost %d, got %d", bcrypt.MinCost, cost)
+	}
+
+	userService.SetBcryptCost(0)
+	if _, err := userService.SetPassword(ctx, "cost-user01", "pass-456"); err != nil {
+		t.Fatalf("SetPassword() error = %v", err)
+	}
+	if cost := hashCost("cost-user01"); cost != bcrypt.DefaultCost {
+		t.Fatalf("expected zero cost to fall back to %d, got %d", bcrypt.DefaultCost, cost)
+	}
+}
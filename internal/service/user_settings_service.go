@@ -0,0 +1,132 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/shinyes/keer/internal/models"
+	"github.com/shinyes/keer/internal/store"
+)
+
+const (
+	userSettingKeyTimezone = "timezone"
+	userSettingKeyLocale   = "locale"
+)
+
+var (
+	ErrInvalidTimezone          = errors.New("invalid timezone")
+	ErrInvalidLocale            = errors.New("invalid locale")
+	ErrInvalidDefaultVisibility = errors.New("invalid default visibility")
+)
+
+// localePattern 只做 BCP 47 形式上的粗校验（如 zh-CN、en），不核对语言子标签是否真实存在。
+var localePattern = regexp.MustCompile(`^[A-Za-z]{2,3}(-[A-Za-z0-9]{2,8})*$`)
+
+// UserSettings 汇总用户维度的全部设置，字段为空表示未设置、由客户端自行决定。
+type UserSettings struct {
+	DefaultVisibility models.Visibility
+	DefaultPageSize   int
+	Timezone          string
+	Locale            string
+	// SharedMemosLastSeenTime 由 memos:markSharedSeen 维护，这里只读。
+	SharedMemosLastSeenTime time.Time
+}
+
+// UserSettingsPatch 为部分更新，nil 字段保持不变；Timezone、Locale 传空字符串表示清除。
+type UserSettingsPatch struct {
+	DefaultVisibility *models.Visibility
+	DefaultPageSize   *int
+	Timezone          *string
+	Locale            *string
+}
+
+type UserSettingsService struct {
+	store *store.SQLStore
+}
+
+func NewUserSettingsService(s *store.SQLStore) *UserSettingsService {
+	return &UserSettingsService{store: s}
+}
+
+func (s *UserSettingsService) Get(ctx context.Context, userID int64) (UserSettings, error) {
+	user, err := s.store.GetUserByID(ctx, userID)
+	if err != nil {
+		return UserSettings{}, err
+	}
+	values, err := s.store.ListUserSettings(ctx, userID)
+	if err != nil {
+		return UserSettings{}, err
+	}
+
+	settings := UserSettings{
+		DefaultVisibility: user.DefaultVisibility,
+		DefaultPageSize:   user.DefaultPageSize,
+		Timezone:          values[userSettingKeyTimezone],
+		Locale:            values[userSettingKeyLocale],
+	}
+	if raw := values[userSettingKeySharedLastSeen]; raw != "" {
+		settings.SharedMemosLastSeenTime, err = time.Parse(time.RFC3339Nano, raw)
+		if err != nil {
+			return UserSettings{}, fmt.Errorf("parse shared memos last seen time: %w", err)
+		}
+	}
+	return settings, nil
+}
+
+// Update 先校验全部字段再依次写入，任一字段非法时不会产生部分更新。
+func (s *UserSettingsService) Update(ctx context.Context, userID int64, patch UserSettingsPatch) (UserSettings, error) {
+	if patch.DefaultVisibility != nil && !patch.DefaultVisibility.IsValid() {
+		return UserSettings{}, ErrInvalidDefaultVisibility
+	}
+	if patch.DefaultPageSize != nil && (*patch.DefaultPageSize < 0 || *patch.DefaultPageSize > maxDefaultPageSize) {
+		return UserSettings{}, ErrInvalidPageSize
+	}
+	var timezone, locale string
+	if patch.Timezone != nil {
+		timezone = strings.TrimSpace(*patch.Timezone)
+		if timezone != "" {
+			if _, err := time.LoadLocation(timezone); err != nil {
+				return UserSettings{}, fmt.Errorf("%w: %s", ErrInvalidTimezone, timezone)
+			}
+		}
+	}
+	if patch.Locale != nil {
+		locale = strings.TrimSpace(*patch.Locale)
+		if locale != "" && !localePattern.MatchString(locale) {
+			return UserSettings{}, fmt.Errorf("%w: %s", ErrInvalidLocale, locale)
+		}
+	}
+
+	if patch.DefaultVisibility != nil {
+		if _, err := s.store.UpdateUserDefaultVisibility(ctx, userID, *patch.DefaultVisibility); err != nil {
+			return UserSettings{}, err
+		}
+	}
+	if patch.DefaultPageSize != nil {
+		if _, err := s.store.UpdateUserDefaultPageSize(ctx, userID, *patch.DefaultPageSize); err != nil {
+			return UserSettings{}, err
+		}
+	}
+	if patch.Timezone != nil {
+		if err := s.putUserSetting(ctx, userID, userSettingKeyTimezone, timezone); err != nil {
+			return UserSettings{}, err
+		}
+	}
+	if patch.Locale != nil {
+		if err := s.putUserSetting(ctx, userID, userSettingKeyLocale, locale); err != nil {
+			return UserSettings{}, err
+		}
+	}
+	return s.Get(ctx, userID)
+}
+
+func (s *UserSettingsService) putUserSetting(ctx context.Context, userID int64, key string, value string) error {
+	if value == "" {
+		return s.store.DeleteUserSetting(ctx, userID, key)
+	}
+	return s.store.UpsertUserSetting(ctx, userID, key, value)
+}
@@ -0,0 +1,296 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+
+	"github.com/shinyes/keer/internal/config"
+)
+
+const (
+	gcsReadWriteScope = "https://www.googleapis.com/auth/devstorage.read_write"
+	// gcsMaxSignedURLExpiry 为 V4 签名地址允许的最长有效期。
+	gcsMaxSignedURLExpiry = 7 * 24 * time.Hour
+)
+
+// GCSStore 通过 Cloud Storage JSON API 读写对象，凭证为服务账号密钥文件；
+// 同一密钥的私钥用于生成 V4 签名下载地址。
+type GCSStore struct {
+	client      *http.Client
+	endpoint    string
+	bucket      string
+	clientEmail string
+	privateKey  *rsa.PrivateKey
+}
+
+// NewGCSStore 读取服务账号密钥文件并创建 GCS 存储；不会访问网络，连通性由 Check 验证。
+func NewGCSStore(ctx context.Context, cfg config.GCSConfig) (*GCSStore, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(cfg.CredentialsFile)
+	if err != nil {
+		return nil, fmt.Errorf("read gcs credentials file: %w", err)
+	}
+	jwtConfig, err := google.JWTConfigFromJSON(data, gcsReadWriteScope)
+	if err != nil {
+		return nil, fmt.Errorf("parse gcs credentials file: %w", err)
+	}
+	privateKey, err := parseRSAPrivateKey(jwtConfig.PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("parse gcs credentials private key: %w", err)
+	}
+	endpoint := strings.TrimRight(strings.TrimSpace(cfg.Endpoint), "/")
+	if endpoint == "" {
+		endpoint = config.GCSDefaultEndpoint
+	}
+	// 令牌刷新可能发生在任意请求中，不能绑定到构造时的上下文
+	tokenSource := jwtConfig.TokenSource(context.WithoutCancel(ctx))
+	return &GCSStore{
+		client:      oauth2.NewClient(context.WithoutCancel(ctx), tokenSource),
+		endpoint:    endpoint,
+		bucket:      cfg.Bucket,
+		clientEmail: jwtConfig.Email,
+		privateKey:  privateKey,
+	}, nil
+}
+
+func (s *GCSStore) Put(ctx context.Context, key string, contentType string, data []byte) (int64, error) {
+	return s.PutStream(ctx, key, contentType, bytes.NewReader(data), int64(len(data)))
+}
+
+func (s *GCSStore) PutStream(ctx context.Context, key string, contentType string, reader io.Reader, size int64) (int64, error) {
+	query := url.Values{}
+	query.Set("uploadType", "media")
+	query.Set("name", key)
+	endpoint := fmt.Sprintf("%s/upload/storage/v1/b/%s/o?%s", s.endpoint, url.PathEscape(s.bucket), query.Encode())
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, reader)
+	if err != nil {
+		return 0, fmt.Errorf("put gcs object: %w", err)
+	}
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	req.Header.Set("Content-Type", contentType)
+	if size >= 0 {
+		req.ContentLength = size
+	}
+	resp, err := s.do(req)
+	if err != nil {
+		return 0, fmt.Errorf("put gcs object: %w", err)
+	}
+	defer resp.Body.Close()
+	var object struct {
+		Size string `json:"size"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&object); err != nil {
+		return 0, fmt.Errorf("decode gcs object metadata: %w", err)
+	}
+	var written int64
+	if _, err := fmt.Sscan(object.Size, &written); err != nil {
+		return size, nil
+	}
+	return written, nil
+}
+
+func (s *GCSStore) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	return s.OpenRange(ctx, key, 0, -1)
+}
+
+func (s *GCSStore) OpenRange(ctx context.Context, key string, start int64, end int64) (io.ReadCloser, error) {
+	if start < 0 {
+		return nil, fmt.Errorf("invalid range start")
+	}
+	if end >= 0 && end < start {
+		return nil, fmt.Errorf("invalid range end")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.objectURL(key)+"?alt=media", nil)
+	if err != nil {
+		return nil, fmt.Errorf("get gcs object: %w", err)
+	}
+	if end >= 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, end))
+	} else if start > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", start))
+	}
+	resp, err := s.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("get gcs object: %w", err)
+	}
+	return resp.Body, nil
+}
+
+func (s *GCSStore) Delete(ctx context.Context, key string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, s.objectURL(key), nil)
+	if err != nil {
+		return fmt.Errorf("delete gcs object: %w", err)
+	}
+	resp, err := s.do(req)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("delete gcs object: %w", err)
+	}
+	return resp.Body.Close()
+}
+
+// Check 读取存储桶元数据，验证凭证与存储桶是否可用。
+func (s *GCSStore) Check(ctx context.Context) error {
+	endpoint := fmt.Sprintf("%s/storage/v1/b/%s?fields=name", s.endpoint, url.PathEscape(s.bucket))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return fmt.Errorf("get gcs bucket %s: %w", s.bucket, err)
+	}
+	resp, err := s.do(req)
+	if err != nil {
+		return fmt.Errorf("get gcs bucket %s: %w", s.bucket, err)
+	}
+	return resp.Body.Close()
+}
+
+// PresignGetObjectURL 生成 V4 签名（GOOG4-RSA-SHA256）下载地址，使用服务账号私钥在本地签名。
+func (s *GCSStore) PresignGetObjectURL(ctx context.Context, key string, expires time.Duration) (string, error) {
+	return s.PresignGetObjectURLWithDisposition(ctx, key, expires, "")
+}
+
+// PresignGetObjectURLWithDisposition 同 PresignGetObjectURL；contentDisposition 非空时让 GCS 以该值作为响应的 Content-Disposition。
+func (s *GCSStore) PresignGetObjectURLWithDisposition(_ context.Context, key string, expires time.Duration, contentDisposition string) (string, error) {
+	if expires <= 0 {
+		expires = 5 * time.Minute
+	}
+	if expires > gcsMaxSignedURLExpiry {
+		expires = gcsMaxSignedURLExpiry
+	}
+	base, err := url.Parse(s.endpoint)
+	if err != nil {
+		return "", fmt.Errorf("presign gcs object: %w", err)
+	}
+	now := time.Now().UTC()
+	datestamp := now.Format("20060102")
+	credentialScope := datestamp + "/auto/storage/goog4_request"
+
+	query := map[string]string{
+		"X-Goog-Algorithm":     "GOOG4-RSA-SHA256",
+		"X-Goog-Credential":    s.clientEmail + "/" + credentialScope,
+		"X-Goog-Date":          now.Format("20060102T150405Z"),
+		"X-Goog-Expires":       fmt.Sprintf("%d", int64(expires/time.Second)),
+		"X-Goog-SignedHeaders": "host",
+	}
+	if contentDisposition != "" {
+		query["response-content-disposition"] = contentDisposition
+	}
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	pairs := make([]string, 0, len(names))
+	for _, name := range names {
+		pairs = append(pairs, gcsURIEscape(name)+"="+gcsURIEscape(query[name]))
+	}
+	canonicalQuery := strings.Join(pairs, "&")
+
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		segments[i] = gcsURIEscape(segment)
+	}
+	canonicalPath := base.Path + "/" + gcsURIEscape(s.bucket) + "/" + strings.Join(segments, "/")
+
+	canonicalRequest := strings.Join([]string{
+		http.MethodGet,
+		canonicalPath,
+		canonicalQuery,
+		"host:" + base.Host + "\n",
+		"host",
+		"UNSIGNED-PAYLOAD",
+	}, "\n")
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		"GOOG4-RSA-SHA256",
+		query["X-Goog-Date"],
+		credentialScope,
+		hex.EncodeToString(requestHash[:]),
+	}, "\n")
+	digest := sha256.Sum256([]byte(stringToSign))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, s.privateKey, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("sign gcs url: %w", err)
+	}
+	return fmt.Sprintf(
+		"%s://%s%s?%s&X-Goog-Signature=%s",
+		base.Scheme, base.Host, canonicalPath, canonicalQuery, hex.EncodeToString(signature),
+	), nil
+}
+
+func (s *GCSStore) objectURL(key string) string {
+	return fmt.Sprintf("%s/storage/v1/b/%s/o/%s", s.endpoint, url.PathEscape(s.bucket), url.PathEscape(key))
+}
+
+// do 发送请求，非 2xx 响应转为错误；404 包装 fs.ErrNotExist，便于调用方按对象不存在处理。
+func (s *GCSStore) do(req *http.Request) (*http.Response, error) {
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp, nil
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	_ = resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("gcs responded %s: %w", resp.Status, fs.ErrNotExist)
+	}
+	return nil, fmt.Errorf("gcs responded %s: %s", resp.Status, strings.TrimSpace(string(body)))
+}
+
+// gcsURIEscape 按 V4 签名要求编码：除 A-Z a-z 0-9 - . _ ~ 外全部百分号编码。
+func gcsURIEscape(value string) string {
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if ('A' <= c && c <= 'Z') || ('a' <= c && c <= 'z') || ('0' <= c && c <= '9') ||
+			c == '-' || c == '.' || c == '_' || c == '~' {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
+
+func parseRSAPrivateKey(data []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block != nil {
+		data = block.Bytes
+	}
+	if key, err := x509.ParsePKCS8PrivateKey(data); err == nil {
+		rsaKey, ok := key.(*rsa.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("private key is not RSA")
+		}
+		return rsaKey, nil
+	}
+	return x509.ParsePKCS1PrivateKey(data)
+}
@@ -0,0 +1,183 @@
+package storage
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/shinyes/keer/internal/config"
+)
+
+// fakeGCS 模拟 Cloud Storage JSON API 与 OAuth2 令牌端点的最小子集。
+type fakeGCS struct {
+	mu      sync.Mutex
+	bucket  string
+	objects map[string][]byte
+}
+
+func (f *fakeGCS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/token" {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{"access_token":"test-token","token_type":"Bearer","expires_in":3600}`)
+		return
+	}
+	if r.Header.Get("Authorization") != "Bearer test-token" {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	uploadPrefix := "/upload/storage/v1/b/" + f.bucket + "/o"
+	objectPrefix := "/storage/v1/b/" + f.bucket + "/o/"
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == uploadPrefix:
+		data, _ := io.ReadAll(r.Body)
+		f.objects[r.URL.Query().Get("name")] = data
+		_, _ = fmt.Fprintf(w, `{"size":"%d"}`, len(data))
+	case r.Method == http.MethodGet && r.URL.Path == "/storage/v1/b/"+f.bucket:
+		_, _ = io.WriteString(w, `{"name":"`+f.bucket+`"}`)
+	case strings.HasPrefix(r.URL.Path, objectPrefix):
+		key := strings.TrimPrefix(r.URL.Path, objectPrefix)
+		data, ok := f.objects[key]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method == http.MethodDelete {
+			delete(f.objects, key)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		var start, end int
+		if _, err := fmt.Sscanf(r.Header.Get("Range"), "bytes=%d-%d", &start, &end); err == nil {
+			w.WriteHeader(http.StatusPartialContent)
+			data = data[start : end+1]
+		}
+		_, _ = w.Write(data)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func writeTestGCSCredentials(t *testing.T, tokenURI string) string {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("MarshalPKCS8PrivateKey() error = %v", err)
+	}
+	data, err := json.Marshal(map[string]string{
+		"type":           "service_account",
+		"client_email":   "keer@test-project.iam.gserviceaccount.com",
+		"private_key_id": "test-key",
+		"private_key":    string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		"token_uri":      tokenURI,
+	})
+	if err != nil {
+		t.Fatalf("marshal credentials: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "credentials.json")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("write credentials: %v", err)
+	}
+	return path
+}
+
+func TestGCSStoreRoundTrip(t *testing.T) {
+	fake := &fakeGCS{bucket: "memos", objects: map[string][]byte{}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	ctx := context.Background()
+	store, err := NewGCSStore(ctx, config.GCSConfig{
+		Bucket:          "memos",
+		CredentialsFile: writeTestGCSCredentials(t, server.URL+"/token"),
+		Endpoint:        server.URL,
+	})
+	if err != nil {
+		t.Fatalf("NewGCSStore() error = %v", err)
+	}
+	if err := store.Check(ctx); err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+
+	key := "attachments/2026/hello world.txt"
+	if n, err := store.Put(ctx, key, "text/plain", []byte("hello gcs")); err != nil || n != 9 {
+		t.Fatalf("Put() = %d, %v", n, err)
+	}
+	rc, err := store.OpenRange(ctx, key, 6, 8)
+	if err != nil {
+		t.Fatalf("OpenRange() error = %v", err)
+	}
+	data, _ := io.ReadAll(rc)
+	_ = rc.Close()
+	if string(data) != "gcs" {
+		t.Fatalf("OpenRange() = %q, want gcs", data)
+	}
+
+	if err := store.Delete(ctx, key); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if err := store.Delete(ctx, key); err != nil {
+		t.Fatalf("Delete() of missing object error = %v", err)
+	}
+	if _, err := store.Open(ctx, key); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Open() after delete error = %v, want fs.ErrNotExist", err)
+	}
+}
+
+func TestGCSStorePresignGetObjectURL(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewGCSStore(ctx, config.GCSConfig{
+		Bucket:          "memos",
+		CredentialsFile: writeTestGCSCredentials(t, "http://127.0.0.1/token"),
+	})
+	if err != nil {
+		t.Fatalf("NewGCSStore() error = %v", err)
+	}
+	var _ Presigner = store
+
+	raw, err := store.PresignGetObjectURLWithDisposition(ctx, "attachments/a b.png", 10*time.Minute, `attachment; filename="a b.png"`)
+	if err != nil {
+		t.Fatalf("PresignGetObjectURLWithDisposition() error = %v", err)
+	}
+	parsed, err := url.Parse(raw)
+	if err != nil {
+		t.Fatalf("parse presigned url: %v", err)
+	}
+	if parsed.Host != "storage.googleapis.com" || parsed.EscapedPath() != "/memos/attachments/a%20b.png" {
+		t.Fatalf("unexpected presigned url: %s", raw)
+	}
+	query := parsed.Query()
+	if query.Get("X-Goog-Algorithm") != "GOOG4-RSA-SHA256" || query.Get("X-Goog-Expires") != "600" {
+		t.Fatalf("unexpected signing params: %v", query)
+	}
+	if !strings.HasPrefix(query.Get("X-Goog-Credential"), "keer@test-project.iam.gserviceaccount.com/") {
+		t.Fatalf("unexpected credential: %s", query.Get("X-Goog-Credential"))
+	}
+	if query.Get("response-content-disposition") != `attachment; filename="a b.png"` {
+		t.Fatalf("unexpected disposition: %s", query.Get("response-content-disposition"))
+	}
+	if len(query.Get("X-Goog-Signature")) != 512 {
+		t.Fatalf("unexpected signature length: %d", len(query.Get("X-Goog-Signature")))
+	}
+}
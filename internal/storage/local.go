@@ -3,15 +3,22 @@ package storage
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 )
 
 type LocalStore struct {
 	baseDir string
+	// sharded 为 true 时对象写入 baseDir/<键摘要前两位>/<key>，避免单个目录下文件过多。
+	sharded bool
 }
 
 func NewLocalStore(baseDir string) (*LocalStore, error) {
@@ -21,6 +28,12 @@ func NewLocalStore(baseDir string) (*LocalStore, error) {
 	return &LocalStore{baseDir: baseDir}, nil
 }
 
+// SetSharding 切换分片目录布局，存储键本身不变。开启后读取和删除仍会兼顾旧的平铺路径，
+// 已有文件可以之后再用 MigrateToShardedLayout 搬迁。
+func (s *LocalStore) SetSharding(enabled bool) {
+	s.sharded = enabled
+}
+
 func (s *LocalStore) Put(_ context.Context, key string, _ string, data []byte) (int64, error) {
 	return s.PutStream(context.Background(), key, "", bytes.NewReader(data), int64(len(data)))
 }
@@ -50,11 +63,7 @@ func (s *LocalStore) PutStream(_ context.Context, key string, _ string, reader i
 }
 
 func (s *LocalStore) Open(_ context.Context, key string) (io.ReadCloser, error) {
-	path, err := s.pathFor(key)
-	if err != nil {
-		return nil, err
-	}
-	f, err := os.Open(path)
+	f, err := s.openExisting(key)
 	if err != nil {
 		return nil, err
 	}
@@ -69,11 +78,7 @@ func (s *LocalStore) OpenRange(_ context.Context, key string, start int64, end i
 		return nil, fmt.Errorf("invalid range end")
 	}
 
-	path, err := s.pathFor(key)
-	if err != nil {
-		return nil, err
-	}
-	f, err := os.Open(path)
+	f, err := s.openExisting(key)
 	if err != nil {
 		return nil, err
 	}
@@ -93,34 +98,168 @@ func (s *LocalStore) OpenRange(_ context.Context, key string, start int64, end i
 }
 
 func (s *LocalStore) Delete(_ context.Context, key string) error {
-	path, err := s.pathFor(key)
+	paths, err := s.candidatePaths(key)
 	if err != nil {
 		return err
 	}
-	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
-		return err
+	for _, path := range paths {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
 	}
 	return nil
 }
 
+// Check 确认存储目录存在且可写：创建并删除一个临时文件。
+func (s *LocalStore) Check(_ context.Context) error {
+	info, err := os.Stat(s.baseDir)
+	if err != nil {
+		return fmt.Errorf("stat uploads dir: %w", err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("uploads dir %s is not a directory", s.baseDir)
+	}
+	f, err := os.CreateTemp(s.baseDir, ".keer-check-*")
+	if err != nil {
+		return fmt.Errorf("uploads dir is not writable: %w", err)
+	}
+	name := f.Name()
+	_ = f.Close()
+	return os.Remove(name)
+}
+
+// MigrateToShardedLayout 把平铺布局下的文件搬到分片目录，返回搬迁的文件数。
+// 可在服务运行时执行：读取会回退到旧路径，单个文件的搬迁是原子的 rename；ctx 取消时停止并返回已搬迁数量。
+func (s *LocalStore) MigrateToShardedLayout(ctx context.Context) (int, error) {
+	entries, err := os.ReadDir(s.baseDir)
+	if err != nil {
+		return 0, err
+	}
+	moved := 0
+	for _, entry := range entries {
+		if entry.IsDir() && isShardDirName(entry.Name()) {
+			continue
+		}
+		var dirs []string
+		root := filepath.Join(s.baseDir, entry.Name())
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, walkErr error) error {
+			if walkErr != nil {
+				return walkErr
+			}
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if d.IsDir() {
+				dirs = append(dirs, path)
+				return nil
+			}
+			if !d.Type().IsRegular() {
+				return nil
+			}
+			rel, err := filepath.Rel(s.baseDir, path)
+			if err != nil {
+				return err
+			}
+			target := s.shardedPath(filepath.ToSlash(rel))
+			if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+				return fmt.Errorf("create shard dir: %w", err)
+			}
+			if err := os.Rename(path, target); err != nil {
+				return fmt.Errorf("move %s: %w", rel, err)
+			}
+			moved++
+			return nil
+		})
+		// 深层目录先删；非空目录删除失败是预期的，直接忽略
+		sort.Sort(sort.Reverse(sort.StringSlice(dirs)))
+		for _, dir := range dirs {
+			_ = os.Remove(dir)
+		}
+		if err != nil {
+			return moved, err
+		}
+	}
+	return moved, nil
+}
+
 type readerWithCloser struct {
 	io.Reader
 	io.Closer
 }
 
+// openExisting 先按当前布局打开文件，不存在时再尝试另一种布局，兼容尚未搬迁的旧文件。
+func (s *LocalStore) openExisting(key string) (*os.File, error) {
+	paths, err := s.candidatePaths(key)
+	if err != nil {
+		return nil, err
+	}
+	var firstErr error
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if err == nil {
+			return f, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			break
+		}
+	}
+	return nil, firstErr
+}
+
 func (s *LocalStore) pathFor(key string) (string, error) {
+	cleanKey, err := cleanStorageKey(key)
+	if err != nil {
+		return "", err
+	}
+	if s.sharded {
+		return s.shardedPath(cleanKey), nil
+	}
+	return filepath.Join(s.baseDir, filepath.FromSlash(cleanKey)), nil
+}
+
+// candidatePaths 返回键可能所在的路径，当前布局优先；搬迁过程中文件可能刚被移走，所以最后再查一次当前布局。
+func (s *LocalStore) candidatePaths(key string) ([]string, error) {
+	cleanKey, err := cleanStorageKey(key)
+	if err != nil {
+		return nil, err
+	}
+	flat := filepath.Join(s.baseDir, filepath.FromSlash(cleanKey))
+	sharded := s.shardedPath(cleanKey)
+	if s.sharded {
+		return []string{sharded, flat, sharded}, nil
+	}
+	return []string{flat, sharded}, nil
+}
+
+func (s *LocalStore) shardedPath(cleanKey string) string {
+	sum := sha256.Sum256([]byte(cleanKey))
+	return filepath.Join(s.baseDir, hex.EncodeToString(sum[:1]), filepath.FromSlash(cleanKey))
+}
+
+func cleanStorageKey(key string) (string, error) {
 	cleanKey := filepath.ToSlash(filepath.Clean(strings.TrimSpace(key)))
 	cleanKey = strings.TrimPrefix(cleanKey, "/")
 	if cleanKey == "" || cleanKey == "." {
 		return "", fmt.Errorf("invalid storage key")
 	}
-	path := filepath.Join(s.baseDir, filepath.FromSlash(cleanKey))
-	rel, err := filepath.Rel(s.baseDir, path)
-	if err != nil {
-		return "", err
-	}
-	if strings.HasPrefix(rel, "..") {
+	if cleanKey == ".." || strings.HasPrefix(cleanKey, "../") {
 		return "", fmt.Errorf("invalid storage key traversal")
 	}
-	return path, nil
+	return cleanKey, nil
+}
+
+// isShardDirName 判断 baseDir 下的目录是否是分片目录（两位小写十六进制）；存储键的首段都是 attachments 这类单词，不会与之冲突。
+func isShardDirName(name string) bool {
+	if len(name) != 2 {
+		return false
+	}
+	for _, c := range name {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f') {
+			return false
+		}
+	}
+	return true
 }
@@ -0,0 +1,122 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLocalStoreShardedLayout(t *testing.T) {
+	ctx := context.Background()
+	baseDir := t.TempDir()
+	store, err := NewLocalStore(baseDir)
+	if err != nil {
+		t.Fatalf("NewLocalStore() error = %v", err)
+	}
+
+	const legacyKey = "attachments/1/legacy_a.txt"
+	if _, err := store.Put(ctx, legacyKey, "text/plain", []byte("legacy")); err != nil {
+		t.Fatalf("Put() legacy error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(baseDir, "attachments", "1", "legacy_a.txt")); err != nil {
+		t.Fatalf("expected flat file before sharding, stat err=%v", err)
+	}
+
+	store.SetSharding(true)
+	const newKey = "attachments/1/new_b.txt"
+	if _, err := store.Put(ctx, newKey, "text/plain", []byte("0123456789")); err != nil {
+		t.Fatalf("Put() sharded error = %v", err)
+	}
+	if _, err := os.Stat(store.shardedPath(newKey)); err != nil {
+		t.Fatalf("expected new file in shard dir, stat err=%v", err)
+	}
+	if _, err := os.Stat(filepath.Join(baseDir, "attachments", "1", "new_b.txt")); !os.IsNotExist(err) {
+		t.Fatalf("expected no flat copy of new file, stat err=%v", err)
+	}
+	assertLocalContent(t, store, legacyKey, "legacy")
+
+	rc, err := store.OpenRange(ctx, newKey, 2, 5)
+	if err != nil {
+		t.Fatalf("OpenRange() error = %v", err)
+	}
+	ranged, _ := io.ReadAll(rc)
+	_ = rc.Close()
+	if string(ranged) != "2345" {
+		t.Fatalf("unexpected range content: %q", ranged)
+	}
+
+	moved, err := store.MigrateToShardedLayout(ctx)
+	if err != nil {
+		t.Fatalf("MigrateToShardedLayout() error = %v", err)
+	}
+	if moved != 1 {
+		t.Fatalf("expected 1 file moved, got %d", moved)
+	}
+	if _, err := os.Stat(filepath.Join(baseDir, "attachments")); !os.IsNotExist(err) {
+		t.Fatalf("expected empty flat directories removed, stat err=%v", err)
+	}
+	assertLocalContent(t, store, legacyKey, "legacy")
+	assertLocalContent(t, store, newKey, "0123456789")
+	if moved, err := store.MigrateToShardedLayout(ctx); err != nil || moved != 0 {
+		t.Fatalf("expected second migration to be a no-op, moved=%d err=%v", moved, err)
+	}
+
+	store.SetSharding(false)
+	assertLocalContent(t, store, legacyKey, "legacy")
+	if err := store.Delete(ctx, legacyKey); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if _, err := store.Open(ctx, legacyKey); !os.IsNotExist(err) {
+		t.Fatalf("expected deleted key to be gone, err=%v", err)
+	}
+
+	if _, err := store.Open(ctx, "../outside"); err == nil {
+		t.Fatalf("expected traversal key to be rejected")
+	}
+}
+
+func assertLocalContent(t *testing.T, store *LocalStore, key string, want string) {
+	t.Helper()
+	rc, err := store.Open(context.Background(), key)
+	if err != nil {
+		t.Fatalf("Open(%s) error = %v", key, err)
+	}
+	defer rc.Close()
+	got, err := io.ReadAll(rc)
+	if err != nil {
+		t.Fatalf("read %s failed: %v", key, err)
+	}
+	if string(got) != want {
+		t.Fatalf("unexpected content for %s: %q", key, got)
+	}
+}
+
+func TestLocalStoreCheck(t *testing.T) {
+	baseDir := filepath.Join(t.TempDir(), "uploads")
+	store, err := NewLocalStore(baseDir)
+	if err != nil {
+		t.Fatalf("NewLocalStore() error = %v", err)
+	}
+	if err := store.Check(context.Background()); err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	entries, err := os.ReadDir(baseDir)
+	if err != nil {
+		t.Fatalf("read uploads dir failed: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Fatalf("expected check to leave no files behind, got %d entries", len(entries))
+	}
+
+	if err := os.RemoveAll(baseDir); err != nil {
+		t.Fatalf("remove uploads dir failed: %v", err)
+	}
+	if err := os.WriteFile(baseDir, []byte("not a dir"), 0o644); err != nil {
+		t.Fatalf("write placeholder file failed: %v", err)
+	}
+	if err := store.Check(context.Background()); err == nil {
+		t.Fatalf("expected Check() to fail when uploads dir is a file")
+	}
+}
@@ -3,6 +3,9 @@ package storage
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
@@ -32,6 +35,7 @@ type S3Store struct {
 	client        *s3.Client
 	presignClient *s3.PresignClient
 	bucket        string
+	retry         s3RetryPolicy
 }
 
 func NewS3Store(ctx context.Context, cfg config.S3Config) (*S3Store, error) {
@@ -73,12 +77,25 @@ func (s *S3Store) PutStream(ctx context.Context, key string, contentType string,
 		input.ContentLength = aws.Int64(size)
 	}
 
-	_, err := s.client.PutObject(ctx, &s3.PutObjectInput{
-		Bucket:        input.Bucket,
-		Key:           input.Key,
-		ContentType:   input.ContentType,
-		Body:          input.Body,
-		ContentLength: input.ContentLength,
+	// 只有能倒回起点的请求体才能安全重放
+	policy := s.retry
+	if _, ok := reader.(io.Seeker); !ok {
+		policy.maxAttempts = 1
+	}
+	attempt := 0
+	err := policy.do(ctx, func() error {
+		attempt++
+		if attempt > 1 && !rewindForRetry(reader) {
+			return fmt.Errorf("rewind s3 object body for retry")
+		}
+		_, err := s.client.PutObject(ctx, &s3.PutObjectInput{
+			Bucket:        input.Bucket,
+			Key:           input.Key,
+			ContentType:   input.ContentType,
+			Body:          input.Body,
+			ContentLength: input.ContentLength,
+		})
+		return err
 	})
 	if err != nil {
 		return 0, fmt.Errorf("put s3 object: %w", err)
@@ -87,9 +104,14 @@ func (s *S3Store) PutStream(ctx context.Context, key string, contentType string,
 }
 
 func (s *S3Store) Open(ctx context.Context, key string) (io.ReadCloser, error) {
-	obj, err := s.client.GetObject(ctx, &s3.GetObjectInput{
-		Bucket: aws.String(s.bucket),
-		Key:    aws.String(key),
+	var obj *s3.GetObjectOutput
+	err := s.retry.do(ctx, func() error {
+		var err error
+		obj, err = s.client.GetObject(ctx, &s3.GetObjectInput{
+			Bucket: aws.String(s.bucket),
+			Key:    aws.String(key),
+		})
+		return err
 	})
 	if err != nil {
 		return nil, fmt.Errorf("get s3 object: %w", err)
@@ -117,7 +139,12 @@ func (s *S3Store) OpenRange(ctx context.Context, key string, start int64, end in
 		}
 	}
 
-	obj, err := s.client.GetObject(ctx, input)
+	var obj *s3.GetObjectOutput
+	err := s.retry.do(ctx, func() error {
+		var err error
+		obj, err = s.client.GetObject(ctx, input)
+		return err
+	})
 	if err != nil {
 		return nil, fmt.Errorf("get s3 object with range: %w", err)
 	}
@@ -125,9 +152,12 @@ func (s *S3Store) OpenRange(ctx context.Context, key string, start int64, end in
 }
 
 func (s *S3Store) Delete(ctx context.Context, key string) error {
-	_, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
-		Bucket: aws.String(s.bucket),
-		Key:    aws.String(key),
+	err := s.retry.do(ctx, func() error {
+		_, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+			Bucket: aws.String(s.bucket),
+			Key:    aws.String(key),
+		})
+		return err
 	})
 	if err != nil {
 		return fmt.Errorf("delete s3 object: %w", err)
@@ -135,10 +165,27 @@ func (s *S3Store) Delete(ctx context.Context, key string) error {
 	return nil
 }
 
+// Check 列出存储桶中最多一个对象，验证端点、凭证与存储桶是否可用；不做额外重试，以便尽快给出结果。
+func (s *S3Store) Check(ctx context.Context) error {
+	_, err := s.client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
+		Bucket:  aws.String(s.bucket),
+		MaxKeys: aws.Int32(1),
+	})
+	if err != nil {
+		return fmt.Errorf("list s3 bucket %s: %w", s.bucket, err)
+	}
+	return nil
+}
+
 func (s *S3Store) HeadSize(ctx context.Context, key string) (int64, error) {
-	output, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{
-		Bucket: aws.String(s.bucket),
-		Key:    aws.String(key),
+	var output *s3.HeadObjectOutput
+	err := s.retry.do(ctx, func() error {
+		var err error
+		output, err = s.client.HeadObject(ctx, &s3.HeadObjectInput{
+			Bucket: aws.String(s.bucket),
+			Key:    aws.String(key),
+		})
+		return err
 	})
 	if err != nil {
 		return 0, fmt.Errorf("head s3 object: %w", err)
@@ -149,6 +196,36 @@ func (s *S3Store) HeadSize(ctx context.Context, key string) (int64, error) {
 	return *output.ContentLength, nil
 }
 
+// HeadChecksumSHA256 读取 S3 为对象保存的整对象 SHA-256 校验和并转为十六进制；
+// 对象未带校验和，或只有分片组合校验和（形如 "xxx-3"）时返回空串，由调用方自行下载计算。
+func (s *S3Store) HeadChecksumSHA256(ctx context.Context, key string) (string, error) {
+	var output *s3.HeadObjectOutput
+	err := s.retry.do(ctx, func() error {
+		var err error
+		output, err = s.client.HeadObject(ctx, &s3.HeadObjectInput{
+			Bucket:       aws.String(s.bucket),
+			Key:          aws.String(key),
+			ChecksumMode: types.ChecksumModeEnabled,
+		})
+		return err
+	})
+	if err != nil {
+		return "", fmt.Errorf("head s3 object checksum: %w", err)
+	}
+	if output.ChecksumSHA256 == nil || output.ChecksumType == types.ChecksumTypeComposite {
+		return "", nil
+	}
+	encoded := strings.TrimSpace(*output.ChecksumSHA256)
+	if strings.Contains(encoded, "-") {
+		return "", nil
+	}
+	decoded, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(decoded) != sha256.Size {
+		return "", nil
+	}
+	return hex.EncodeToString(decoded), nil
+}
+
 func (s *S3Store) PresignPutObjectURL(ctx context.Context, key string, contentType string, expires time.Duration) (string, error) {
 	if expires <= 0 {
 		expires = 15 * time.Minute
@@ -168,6 +245,11 @@ func (s *S3Store) PresignPutObjectURL(ctx context.Context, key string, contentTy
 }
 
 func (s *S3Store) PresignGetObjectURL(ctx context.Context, key string, expires time.Duration) (string, error) {
+	return s.PresignGetObjectURLWithDisposition(ctx, key, expires, "")
+}
+
+// PresignGetObjectURLWithDisposition 生成预签名下载地址；contentDisposition 非空时让 S3 以该值作为响应的 Content-Disposition。
+func (s *S3Store) PresignGetObjectURLWithDisposition(ctx context.Context, key string, expires time.Duration, contentDisposition string) (string, error) {
 	if expires <= 0 {
 		expires = 5 * time.Minute
 	}
@@ -175,6 +257,9 @@ func (s *S3Store) PresignGetObjectURL(ctx context.Context, key string, expires t
 		Bucket: aws.String(s.bucket),
 		Key:    aws.String(key),
 	}
+	if contentDisposition != "" {
+		input.ResponseContentDisposition = aws.String(contentDisposition)
+	}
 	req, err := s.presignClient.PresignGetObject(ctx, input, func(options *s3.PresignOptions) {
 		options.Expires = expires
 	})
@@ -190,7 +275,12 @@ func (s *S3Store) CreateMultipartUpload(ctx context.Context, key string, content
 		Key:         aws.String(key),
 		ContentType: aws.String(contentType),
 	}
-	output, err := s.client.CreateMultipartUpload(ctx, input)
+	var output *s3.CreateMultipartUploadOutput
+	err := s.retry.do(ctx, func() error {
+		var err error
+		output, err = s.client.CreateMultipartUpload(ctx, input)
+		return err
+	})
 	if err != nil {
 		if isMultipartUnsupportedError(err) {
 			return "", ErrS3MultipartUnsupported
@@ -247,7 +337,12 @@ func (s *S3Store) ListMultipartUploadedParts(ctx context.Context, key string, up
 
 	parts := make([]S3UploadedPart, 0)
 	for paginator.HasMorePages() {
-		page, err := paginator.NextPage(ctx)
+		var page *s3.ListPartsOutput
+		err := s.retry.do(ctx, func() error {
+			var err error
+			page, err = paginator.NextPage(ctx)
+			return err
+		})
 		if err != nil {
 			return nil, fmt.Errorf("list multipart uploaded parts: %w", err)
 		}
@@ -295,13 +390,16 @@ func (s *S3Store) CompleteMultipartUpload(
 		})
 	}
 
-	_, err := s.client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
-		Bucket:   aws.String(s.bucket),
-		Key:      aws.String(key),
-		UploadId: aws.String(uploadID),
-		MultipartUpload: &types.CompletedMultipartUpload{
-			Parts: completedParts,
-		},
+	err := s.retry.do(ctx, func() error {
+		_, err := s.client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+			Bucket:   aws.String(s.bucket),
+			Key:      aws.String(key),
+			UploadId: aws.String(uploadID),
+			MultipartUpload: &types.CompletedMultipartUpload{
+				Parts: completedParts,
+			},
+		})
+		return err
 	})
 	if err != nil {
 		return fmt.Errorf("complete multipart upload: %w", err)
@@ -313,10 +411,13 @@ func (s *S3Store) AbortMultipartUpload(ctx context.Context, key string, uploadID
 	if strings.TrimSpace(uploadID) == "" {
 		return nil
 	}
-	_, err := s.client.AbortMultipartUpload(ctx, &s3.AbortMultipartUploadInput{
-		Bucket:   aws.String(s.bucket),
-		Key:      aws.String(key),
-		UploadId: aws.String(uploadID),
+	err := s.retry.do(ctx, func() error {
+		_, err := s.client.AbortMultipartUpload(ctx, &s3.AbortMultipartUploadInput{
+			Bucket:   aws.String(s.bucket),
+			Key:      aws.String(key),
+			UploadId: aws.String(uploadID),
+		})
+		return err
 	})
 	if err != nil {
 		return fmt.Errorf("abort multipart upload: %w", err)
@@ -0,0 +1,99 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"time"
+)
+
+const (
+	s3RetryBaseDelay = 200 * time.Millisecond
+	s3RetryMaxDelay  = 5 * time.Second
+)
+
+// s3RetryPolicy 控制 S3 调用在 SDK 自带重试之外的额外重试；maxAttempts <= 1 表示不重试。
+type s3RetryPolicy struct {
+	maxAttempts int
+	maxElapsed  time.Duration
+	baseDelay   time.Duration
+}
+
+// SetRetryPolicy 为 S3 调用开启带退避的重试：最多尝试 maxAttempts 次，累计耗时不超过 maxElapsed（0 表示不限）。
+// 403、404 等明确的客户端错误不会重试，请求上下文取消时立即返回。
+func (s *S3Store) SetRetryPolicy(maxAttempts int, maxElapsed time.Duration) {
+	s.retry = s3RetryPolicy{
+		maxAttempts: maxAttempts,
+		maxElapsed:  maxElapsed,
+		baseDelay:   s3RetryBaseDelay,
+	}
+}
+
+func (p s3RetryPolicy) do(ctx context.Context, op func() error) error {
+	start := time.Now()
+	for attempt := 1; ; attempt++ {
+		err := op()
+		if err == nil || attempt >= p.maxAttempts || !isRetryableS3Error(err) {
+			return err
+		}
+		delay := p.backoff(attempt)
+		if p.maxElapsed > 0 && time.Since(start)+delay > p.maxElapsed {
+			return err
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// backoff 返回第 attempt 次失败后的等待时间：指数增长并封顶，再取 [d/2, d) 的随机抖动。
+func (p s3RetryPolicy) backoff(attempt int) time.Duration {
+	delay := p.baseDelay
+	for i := 1; i < attempt && delay < s3RetryMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > s3RetryMaxDelay {
+		delay = s3RetryMaxDelay
+	}
+	if delay <= 1 {
+		return delay
+	}
+	half := delay / 2
+	return half + rand.N(delay-half)
+}
+
+// rewindForRetry 在重试上传前把请求体倒回起点；不可回退的流无法安全重放，返回 false。
+func rewindForRetry(reader io.Reader) bool {
+	seeker, ok := reader.(io.Seeker)
+	if !ok {
+		return false
+	}
+	_, err := seeker.Seek(0, io.SeekStart)
+	return err == nil
+}
+
+func isRetryableS3Error(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var statusErr interface{ HTTPStatusCode() int }
+	if errors.As(err, &statusErr) {
+		status := statusErr.HTTPStatusCode()
+		switch {
+		case status == http.StatusRequestTimeout, status == http.StatusTooManyRequests:
+			return true
+		case status >= 500:
+			return status != http.StatusNotImplemented
+		case status > 0:
+			return false
+		}
+	}
+	// 没有 HTTP 状态码的错误通常是连接重置、超时等网络问题
+	return true
+}
@@ -0,0 +1,55 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+type statusError int
+
+func (e statusError) Error() string       { return http.StatusText(int(e)) }
+func (e statusError) HTTPStatusCode() int { return int(e) }
+
+func TestS3RetryPolicy(t *testing.T) {
+	policy := s3RetryPolicy{maxAttempts: 4, baseDelay: time.Millisecond}
+
+	cases := []struct {
+		name      string
+		errs      []error
+		wantCalls int
+		wantErr   bool
+	}{
+		{name: "transient then success", errs: []error{statusError(http.StatusServiceUnavailable), errors.New("connection reset"), nil}, wantCalls: 3},
+		{name: "forbidden fails fast", errs: []error{statusError(http.StatusForbidden)}, wantCalls: 1, wantErr: true},
+		{name: "not found fails fast", errs: []error{statusError(http.StatusNotFound)}, wantCalls: 1, wantErr: true},
+		{name: "attempts capped", errs: []error{statusError(500), statusError(500), statusError(500), statusError(500), nil}, wantCalls: 4, wantErr: true},
+	}
+	for _, tc := range cases {
+		calls := 0
+		err := policy.do(context.Background(), func() error {
+			err := tc.errs[calls]
+			calls++
+			return err
+		})
+		if calls != tc.wantCalls || (err != nil) != tc.wantErr {
+			t.Fatalf("%s: calls=%d err=%v, want calls=%d wantErr=%v", tc.name, calls, err, tc.wantCalls, tc.wantErr)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	calls := 0
+	slow := s3RetryPolicy{maxAttempts: 5, baseDelay: time.Hour}
+	if err := slow.do(ctx, func() error { calls++; return statusError(http.StatusBadGateway) }); err == nil || calls != 1 {
+		t.Fatalf("expected canceled context to stop retries, got calls=%d err=%v", calls, err)
+	}
+
+	calls = 0
+	capped := s3RetryPolicy{maxAttempts: 5, maxElapsed: time.Millisecond, baseDelay: time.Second}
+	if err := capped.do(context.Background(), func() error { calls++; return statusError(http.StatusBadGateway) }); err == nil || calls != 1 {
+		t.Fatalf("expected max elapsed to stop retries, got calls=%d err=%v", calls, err)
+	}
+}
@@ -3,6 +3,7 @@ package storage
 import (
 	"context"
 	"io"
+	"time"
 )
 
 type Store interface {
@@ -12,4 +13,13 @@ type Store interface {
 	// OpenRange opens [start, end] (inclusive). If end is negative, it reads to EOF.
 	OpenRange(ctx context.Context, key string, start int64, end int64) (io.ReadCloser, error)
 	Delete(ctx context.Context, key string) error
+	// Check 验证后端可用（目录可写、凭证与存储桶可访问），不可用时返回原因。
+	Check(ctx context.Context) error
+}
+
+// Presigner 由支持生成签名下载地址的对象存储（S3、GCS）实现，客户端可凭地址直接下载对象。
+type Presigner interface {
+	PresignGetObjectURL(ctx context.Context, key string, expires time.Duration) (string, error)
+	// PresignGetObjectURLWithDisposition 在 contentDisposition 非空时让存储以该值作为响应的 Content-Disposition。
+	PresignGetObjectURLWithDisposition(ctx context.Context, key string, expires time.Duration, contentDisposition string) (string, error)
 }
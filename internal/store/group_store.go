@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"slices"
 	"strings"
 	"time"
 
@@ -133,7 +134,7 @@ func (s *SQLStore) ListGroupsByUser(ctx context.Context, userID int64) ([]models
 func (s *SQLStore) ListGroupMembers(ctx context.Context, groupID int64) ([]models.User, error) {
 	rows, err := s.db.QueryContext(
 		ctx,
-		`SELECT u.id, u.username, u.display_name, u.avatar_url, u.password_hash, u.role, u.default_visibility, u.create_time, u.update_time
+		`SELECT u.id, u.username, u.display_name, u.avatar_url, u.password_hash, u.role, u.default_visibility, u.default_page_size, u.create_time, u.update_time
 		FROM group_members gm
 		JOIN users u ON u.id = gm.user_id
 		WHERE gm.group_id = ?
@@ -159,6 +160,7 @@ func (s *SQLStore) ListGroupMembers(ctx context.Context, groupID int64) ([]model
 			&user.PasswordHash,
 			&user.Role,
 			&defaultVisibility,
+			&user.DefaultPageSize,
 			&createTime,
 			&updateTime,
 		); err != nil {
@@ -411,9 +413,11 @@ func (s *SQLStore) GetGroupMessageByID(ctx context.Context, messageID int64) (mo
 	if err != nil {
 		return models.GroupMessage{}, err
 	}
-	if err := s.hydrateGroupMessageTags(ctx, []models.GroupMessage{msg}); err != nil {
+	msgs := []models.GroupMessage{msg}
+	if err := s.hydrateGroupMessageTags(ctx, msgs); err != nil {
 		return models.GroupMessage{}, err
 	}
+	msg = msgs[0]
 	msg.Tags = normalizeGroupTags(msg.Tags)
 	return msg, nil
 }
@@ -448,9 +452,73 @@ func (s *SQLStore) ListGroupMessagesPage(
 	if err != nil {
 		return nil, -1, err
 	}
+	result, err := scanGroupMessageRows(rows)
+	if err != nil {
+		return nil, -1, err
+	}
+
+	nextOffset := -1
+	if len(result) > limit {
+		result = result[:limit]
+		nextOffset = offset + limit
+	}
+	if err := s.hydrateGroupMessageTags(ctx, result); err != nil {
+		return nil, -1, err
+	}
+	for i := range result {
+		result[i].Tags = normalizeGroupTags(result[i].Tags)
+	}
+	return result, nextOffset, nil
+}
+
+// ListGroupMessages returns up to limit messages older than beforeID (or the
+// latest messages when beforeID is 0) in chronological order.
+func (s *SQLStore) ListGroupMessages(
+	ctx context.Context,
+	groupID int64,
+	limit int,
+	beforeID int64,
+) ([]models.GroupMessage, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+	if limit > 200 {
+		limit = 200
+	}
+
+	query := `SELECT id, group_id, creator_id, content, create_time, update_time
+		FROM group_messages
+		WHERE group_id = ?`
+	args := []any{groupID}
+	if beforeID > 0 {
+		query += ` AND id < ?`
+		args = append(args, beforeID)
+	}
+	query += ` ORDER BY id DESC LIMIT ?`
+	args = append(args, limit)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	result, err := scanGroupMessageRows(rows)
+	if err != nil {
+		return nil, err
+	}
+	slices.Reverse(result)
+	if err := s.hydrateGroupMessageTags(ctx, result); err != nil {
+		return nil, err
+	}
+	for i := range result {
+		result[i].Tags = normalizeGroupTags(result[i].Tags)
+	}
+	return result, nil
+}
+
+func scanGroupMessageRows(rows *sql.Rows) ([]models.GroupMessage, error) {
 	defer rows.Close()
 
-	result := make([]models.GroupMessage, 0, limit+1)
+	result := make([]models.GroupMessage, 0)
 	for rows.Next() {
 		var msg models.GroupMessage
 		var createTime string
@@ -463,34 +531,23 @@ func (s *SQLStore) ListGroupMessagesPage(
 			&createTime,
 			&updateTime,
 		); err != nil {
-			return nil, -1, err
+			return nil, err
 		}
+		var err error
 		msg.CreateTime, err = parseTime(createTime)
 		if err != nil {
-			return nil, -1, err
+			return nil, err
 		}
 		msg.UpdateTime, err = parseTime(updateTime)
 		if err != nil {
-			return nil, -1, err
+			return nil, err
 		}
 		result = append(result, msg)
 	}
 	if err := rows.Err(); err != nil {
-		return nil, -1, err
-	}
-
-	nextOffset := -1
-	if len(result) > limit {
-		result = result[:limit]
-		nextOffset = offset + limit
-	}
-	if err := s.hydrateGroupMessageTags(ctx, result); err != nil {
-		return nil, -1, err
-	}
-	for i := range result {
-		result[i].Tags = normalizeGroupTags(result[i].Tags)
+		return nil, err
 	}
-	return result, nextOffset, nil
+	return result, nil
 }
 
 func (s *SQLStore) hydrateGroupMessageTags(ctx context.Context, messages []models.GroupMessage) error {
@@ -540,6 +597,58 @@ func (s *SQLStore) hydrateGroupMessageTags(ctx context.Context, messages []model
 	return rows.Err()
 }
 
+func (s *SQLStore) ListMemoGroupShares(ctx context.Context, memoID int64) (map[int64]map[int64]struct{}, error) {
+	rows, err := s.db.QueryContext(
+		ctx,
+		`SELECT group_id, user_id FROM memo_group_shares WHERE memo_id = ?`,
+		memoID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make(map[int64]map[int64]struct{})
+	for rows.Next() {
+		var groupID int64
+		var userID int64
+		if err := rows.Scan(&groupID, &userID); err != nil {
+			return nil, err
+		}
+		if result[groupID] == nil {
+			result[groupID] = make(map[int64]struct{})
+		}
+		result[groupID][userID] = struct{}{}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func setMemoGroupShareInTx(ctx context.Context, tx *sql.Tx, memoID int64, share MemoGroupShare) error {
+	if _, err := tx.ExecContext(
+		ctx,
+		`DELETE FROM memo_group_shares WHERE memo_id = ? AND group_id = ?`,
+		memoID,
+		share.GroupID,
+	); err != nil {
+		return err
+	}
+	for _, userID := range share.UserIDs {
+		if _, err := tx.ExecContext(
+			ctx,
+			`INSERT OR IGNORE INTO memo_group_shares (memo_id, group_id, user_id) VALUES (?, ?, ?)`,
+			memoID,
+			share.GroupID,
+			userID,
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func normalizeGroupTags(tags []string) []string {
 	if len(tags) == 0 {
 		return []string{}
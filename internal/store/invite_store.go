@@ -0,0 +1,80 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/shinyes/keer/internal/models"
+)
+
+func (s *SQLStore) CreateInviteToken(ctx context.Context, rawToken string, expiresAt time.Time) error {
+	_, err := s.db.ExecContext(
+		ctx,
+		`INSERT INTO invite_tokens (token_hash, created_at, expires_at)
+		VALUES (?, ?, ?)`,
+		HashToken(rawToken),
+		time.Now().UTC().Format(time.RFC3339Nano),
+		expiresAt.UTC().Format(time.RFC3339Nano),
+	)
+	return err
+}
+
+// InviteTokenUsable 判断邀请令牌是否存在、未过期且尚未被使用。
+func (s *SQLStore) InviteTokenUsable(ctx context.Context, rawToken string) (bool, error) {
+	var count int
+	err := s.db.QueryRowContext(
+		ctx,
+		`SELECT COUNT(1) FROM invite_tokens
+		WHERE token_hash = ? AND consumed_at IS NULL AND expires_at > ?`,
+		HashToken(rawToken),
+		time.Now().UTC().Format(time.RFC3339Nano),
+	).Scan(&count)
+	return count > 0, err
+}
+
+// CreateUserWithInviteToken 在同一事务中消费邀请令牌并创建用户；令牌无效时返回 sql.ErrNoRows，创建失败时令牌不会被消费。
+func (s *SQLStore) CreateUserWithInviteToken(ctx context.Context, rawToken string, username string, displayName string, passwordHash string, role string) (models.User, error) {
+	var userID int64
+	err := withTx(ctx, s.db, func(tx *sql.Tx) error {
+		now := time.Now().UTC().Format(time.RFC3339Nano)
+		var inviteID int64
+		if err := tx.QueryRowContext(
+			ctx,
+			`UPDATE invite_tokens SET consumed_at = ?
+			WHERE token_hash = ? AND consumed_at IS NULL AND expires_at > ?
+			RETURNING id`,
+			now,
+			HashToken(rawToken),
+			now,
+		).Scan(&inviteID); err != nil {
+			return err
+		}
+		res, err := tx.ExecContext(
+			ctx,
+			`INSERT INTO users (username, display_name, avatar_url, password_hash, role, default_visibility, create_time, update_time)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+			username,
+			displayName,
+			"",
+			passwordHash,
+			role,
+			models.VisibilityPrivate,
+			now,
+			now,
+		)
+		if err != nil {
+			return err
+		}
+		userID, err = res.LastInsertId()
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `UPDATE invite_tokens SET consumed_by = ? WHERE id = ?`, userID, inviteID)
+		return err
+	})
+	if err != nil {
+		return models.User{}, err
+	}
+	return s.GetUserByID(ctx, userID)
+}
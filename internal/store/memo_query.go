@@ -1,6 +1,10 @@
 package store
 
-import "github.com/shinyes/keer/internal/models"
+import (
+	"time"
+
+	"github.com/shinyes/keer/internal/models"
+)
 
 type TagMatchKind int
 
@@ -33,6 +37,23 @@ type MemoSQLPrefilter struct {
 
 	TagGroups        []TagMatchGroup
 	ExcludeTagGroups []TagMatchGroup
+
+	CreateTime TimeRange
+	UpdateTime TimeRange
+
+	// ContentMatches 为 FTS5 MATCH 表达式，逐条与查询取交集。分词匹配与子串匹配语义不同，调用方需要在内存中复核。
+	ContentMatches []string
+}
+
+// TimeRange 为时间列的闭区间边界，nil 表示该侧不限。存储的时间字符串精度不一，
+// 存储层按秒放宽比较，结果是精确区间的超集，调用方需要在内存中复核。
+type TimeRange struct {
+	From *time.Time
+	To   *time.Time
+}
+
+func (r TimeRange) IsEmpty() bool {
+	return r.From == nil && r.To == nil
 }
 
 func EmptyMemoPrefilter() MemoSQLPrefilter {
@@ -0,0 +1,23 @@
+package store
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTagGroupExistsClause_GroupsPrefixOptions(t *testing.T) {
+	clause, args := tagGroupExistsClause(TagMatchGroup{Options: []TagMatchOption{
+		{Kind: TagMatchPrefix, Value: "a/"},
+		{Kind: TagMatchPrefix, Value: "b/"},
+	}})
+	if !strings.Contains(clause, "mt.memo_id = m.id AND (t.name LIKE ? OR t.name LIKE ?)") {
+		t.Fatalf("expected both prefixes inside the correlated condition, got %s", clause)
+	}
+	if len(args) != 2 || args[0] != "a/%" || args[1] != "b/%" {
+		t.Fatalf("unexpected args: %v", args)
+	}
+
+	if clause, args := tagGroupExistsClause(TagMatchGroup{}); clause != "" || args != nil {
+		t.Fatalf("expected empty group to produce no clause, got %q %v", clause, args)
+	}
+}
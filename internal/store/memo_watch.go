@@ -0,0 +1,96 @@
+package store
+
+import (
+	"sync"
+
+	"github.com/shinyes/keer/internal/models"
+)
+
+// memoChangeHub 在备忘录写事务提交后唤醒受影响的订阅者。只传递“有变化”的信号，
+// 订阅者收到后自行按可见性查询变更窗口；写入方只需给出可能看到这次变化的用户范围。
+type memoChangeHub struct {
+	mu          sync.Mutex
+	subscribers map[chan struct{}]int64
+}
+
+func newMemoChangeHub() *memoChangeHub {
+	return &memoChangeHub{subscribers: make(map[chan struct{}]int64)}
+}
+
+// memoAudience 为一次写入可能影响到的用户：公开或登录可见的备忘录对所有人广播，
+// 私有备忘录只涉及创建者与协作者。
+type memoAudience struct {
+	broadcast bool
+	userIDs   map[int64]struct{}
+}
+
+func broadcastMemoAudience() memoAudience {
+	return memoAudience{broadcast: true}
+}
+
+// addMemo 把一条备忘录在某个版本下的可见者加入范围；可见性或协作者变化时，新旧版本都要加入。
+func (a *memoAudience) addMemo(visibility models.Visibility, creatorID int64, collaboratorIDs map[int64]struct{}) {
+	if a.broadcast {
+		return
+	}
+	if visibility != models.VisibilityPrivate {
+		a.broadcast = true
+		a.userIDs = nil
+		return
+	}
+	if a.userIDs == nil {
+		a.userIDs = make(map[int64]struct{}, len(collaboratorIDs)+1)
+	}
+	a.userIDs[creatorID] = struct{}{}
+	for collaboratorID := range collaboratorIDs {
+		a.userIDs[collaboratorID] = struct{}{}
+	}
+}
+
+func (a memoAudience) empty() bool {
+	return !a.broadcast && len(a.userIDs) == 0
+}
+
+func (a memoAudience) includes(userID int64) bool {
+	if a.broadcast {
+		return true
+	}
+	_, ok := a.userIDs[userID]
+	return ok
+}
+
+// SubscribeMemoChanges 订阅 viewerID 可能看到的备忘录变更信号；多次变更在被读取前会合并为一次。
+// 调用方结束时必须调用返回的 cancel 释放订阅。
+func (s *SQLStore) SubscribeMemoChanges(viewerID int64) (<-chan struct{}, func()) {
+	ch := make(chan struct{}, 1)
+	s.memoChanges.mu.Lock()
+	s.memoChanges.subscribers[ch] = viewerID
+	s.memoChanges.mu.Unlock()
+
+	var once sync.Once
+	cancel := func() {
+		once.Do(func() {
+			s.memoChanges.mu.Lock()
+			delete(s.memoChanges.subscribers, ch)
+			s.memoChanges.mu.Unlock()
+		})
+	}
+	return ch, cancel
+}
+
+func (s *SQLStore) notifyMemoChanged(audience memoAudience) {
+	if audience.empty() {
+		return
+	}
+	s.memoChanges.mu.Lock()
+	defer s.memoChanges.mu.Unlock()
+	for ch, viewerID := range s.memoChanges.subscribers {
+		if !audience.includes(viewerID) {
+			continue
+		}
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
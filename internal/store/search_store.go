@@ -0,0 +1,186 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+
+	"github.com/shinyes/keer/internal/db"
+	"github.com/shinyes/keer/internal/models"
+)
+
+var memoSearchTokenizerPattern = regexp.MustCompile(`tokenize\s*=\s*'([^']*)'`)
+
+type MemoSearchScope string
+
+const (
+	MemoSearchScopeVisible MemoSearchScope = "visible"
+	MemoSearchScopeOwn     MemoSearchScope = "own"
+)
+
+func (s MemoSearchScope) IsValid() bool {
+	return s == MemoSearchScopeVisible || s == MemoSearchScopeOwn
+}
+
+func (s *SQLStore) SearchMemos(
+	ctx context.Context,
+	viewerID int64,
+	scope MemoSearchScope,
+	state *models.MemoState,
+	match string,
+	limit int,
+	offset int,
+) ([]models.Memo, error) {
+	// 先按可见性（及作用域）圈定 memo，再与 FTS 匹配结果连接并排序，
+	// 避免其他用户的私有内容进入候选集合
+	visiblePredicate, args := visibleMemoPredicate(viewerID)
+	query := `WITH scoped AS (
+			SELECT m.id
+			FROM memos m
+			WHERE ` + visiblePredicate
+	if scope == MemoSearchScopeOwn {
+		query += ` AND m.creator_id = ?`
+		args = append(args, viewerID)
+	}
+	if state != nil {
+		query += ` AND m.state = ?`
+		args = append(args, *state)
+	}
+	query += `
+		)
+		SELECT m.id, m.creator_id, m.content, m.visibility, m.state, m.pinned, m.create_time, m.update_time, m.display_time, m.latitude, m.longitude, m.has_link, m.has_task_list, m.has_code, m.has_incomplete_tasks
+		FROM memos_fts
+		JOIN scoped ON scoped.id = memos_fts.rowid
+		JOIN memos m ON m.id = memos_fts.rowid
+		WHERE memos_fts MATCH ?
+		ORDER BY memos_fts.rank, m.create_time DESC, m.id DESC`
+	args = append(args, match)
+	if limit > 0 {
+		query += ` LIMIT ? OFFSET ?`
+		args = append(args, limit, offset)
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	memos := make([]models.Memo, 0)
+	for rows.Next() {
+		memo, err := scanMemo(rows)
+		if err != nil {
+			return nil, err
+		}
+		memos = append(memos, memo)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return memos, nil
+}
+
+func (s *SQLStore) ValidateMemoSearchMatch(ctx context.Context, match string) error {
+	var one int
+	err := s.db.QueryRowContext(ctx, `SELECT 1 FROM memos_fts WHERE memos_fts MATCH ? LIMIT 1`, match).Scan(&one)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	return err
+}
+
+func (s *SQLStore) MemoSearchIndexTokenizer(ctx context.Context) (string, error) {
+	var schema string
+	if err := s.db.QueryRowContext(
+		ctx,
+		`SELECT sql FROM sqlite_master WHERE type = 'table' AND name = 'memos_fts'`,
+	).Scan(&schema); err != nil {
+		return "", err
+	}
+	matches := memoSearchTokenizerPattern.FindStringSubmatch(schema)
+	if len(matches) != 2 {
+		return db.DefaultMemoSearchTokenizer, nil
+	}
+	return matches[1], nil
+}
+
+func (s *SQLStore) RebuildMemoSearchIndex(
+	ctx context.Context,
+	tokenizer string,
+	batchSize int,
+	progress func(indexed int64, total int64),
+) (int64, error) {
+	if tokenizer == "" {
+		tokenizer = db.DefaultMemoSearchTokenizer
+	}
+	schema, err := db.MemoSearchIndexSchema(tokenizer)
+	if err != nil {
+		return 0, err
+	}
+	if batchSize <= 0 {
+		batchSize = 500
+	}
+
+	var maxID int64
+	var total int64
+	if err := withTx(ctx, s.db, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, `DROP TABLE IF EXISTS memos_fts`); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, schema); err != nil {
+			return err
+		}
+		return tx.QueryRowContext(ctx, `SELECT COALESCE(MAX(id), 0), COUNT(1) FROM memos`).Scan(&maxID, &total)
+	}); err != nil {
+		return 0, err
+	}
+
+	// Memos created during the rebuild are indexed by the insert trigger, so
+	// only rows up to the current max id are copied, skipping any the update
+	// trigger already re-indexed.
+	var indexed int64
+	var cursor int64
+	for cursor < maxID {
+		var batchMax sql.NullInt64
+		var batchCount int64
+		if err := withTx(ctx, s.db, func(tx *sql.Tx) error {
+			if err := tx.QueryRowContext(
+				ctx,
+				`SELECT MAX(id), COUNT(1) FROM (
+					SELECT id FROM memos WHERE id > ? AND id <= ? ORDER BY id LIMIT ?
+				)`,
+				cursor,
+				maxID,
+				batchSize,
+			).Scan(&batchMax, &batchCount); err != nil {
+				return err
+			}
+			if !batchMax.Valid {
+				return nil
+			}
+			_, err := tx.ExecContext(
+				ctx,
+				`INSERT INTO memos_fts (rowid, content)
+				SELECT m.id, m.content
+				FROM memos m
+				WHERE m.id > ? AND m.id <= ?
+					AND NOT EXISTS (SELECT 1 FROM memos_fts f WHERE f.rowid = m.id)`,
+				cursor,
+				batchMax.Int64,
+			)
+			return err
+		}); err != nil {
+			return indexed, err
+		}
+		if !batchMax.Valid {
+			break
+		}
+		cursor = batchMax.Int64
+		indexed += batchCount
+		if progress != nil {
+			progress(indexed, total)
+		}
+	}
+	return indexed, nil
+}
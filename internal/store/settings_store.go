@@ -37,3 +37,52 @@ func (s *SQLStore) DeleteSetting(ctx context.Context, key string) error {
 	}
 	return nil
 }
+
+func (s *SQLStore) UpsertUserSetting(ctx context.Context, userID int64, key string, value string) error {
+	_, err := s.db.ExecContext(
+		ctx,
+		`INSERT INTO user_settings (user_id, key, value, update_time)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT(user_id, key) DO UPDATE SET
+			value = excluded.value,
+			update_time = excluded.update_time`,
+		userID,
+		key,
+		value,
+		time.Now().UTC().Format(time.RFC3339Nano),
+	)
+	return err
+}
+
+func (s *SQLStore) GetUserSetting(ctx context.Context, userID int64, key string) (string, error) {
+	var value string
+	err := s.db.QueryRowContext(ctx, `SELECT value FROM user_settings WHERE user_id = ? AND key = ?`, userID, key).Scan(&value)
+	if err != nil {
+		return "", err
+	}
+	return value, nil
+}
+
+// ListUserSettings 返回用户的全部键值设置，不存在任何设置时返回空 map。
+func (s *SQLStore) ListUserSettings(ctx context.Context, userID int64) (map[string]string, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT key, value FROM user_settings WHERE user_id = ?`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	settings := make(map[string]string)
+	for rows.Next() {
+		var key, value string
+		if err := rows.Scan(&key, &value); err != nil {
+			return nil, err
+		}
+		settings[key] = value
+	}
+	return settings, rows.Err()
+}
+
+func (s *SQLStore) DeleteUserSetting(ctx context.Context, userID int64, key string) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM user_settings WHERE user_id = ? AND key = ?`, userID, key)
+	return err
+}
@@ -5,6 +5,7 @@ import (
 	"crypto/sha256"
 	"database/sql"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"strconv"
 	"strings"
@@ -14,17 +15,84 @@ import (
 )
 
 type SQLStore struct {
-	db *sql.DB
+	db                    *sql.DB
+	millisecondTimestamps bool
+	tokenPrefixLength     int
+	memoChanges           *memoChangeHub
 }
 
+// DefaultTokenPrefixLength 为令牌展示前缀的默认长度。
+const DefaultTokenPrefixLength = 8
+
 func New(db *sql.DB) *SQLStore {
-	return &SQLStore{db: db}
+	return &SQLStore{db: db, tokenPrefixLength: DefaultTokenPrefixLength, memoChanges: newMemoChangeHub()}
+}
+
+// memoMillisecondTimeLayout 固定三位小数，保证按字符串比较与按时间比较的结果一致。
+const memoMillisecondTimeLayout = "2006-01-02T15:04:05.000Z07:00"
+
+// memoSecondPrefixLayout 是两种存储格式共同的秒级前缀，用于按秒放宽的范围比较。
+const memoSecondPrefixLayout = "2006-01-02T15:04:05"
+
+// SetMillisecondTimestamps 开启后备忘录时间与变更事件时间在写入和比较时都截断到毫秒。
+func (s *SQLStore) SetMillisecondTimestamps(enabled bool) {
+	s.millisecondTimestamps = enabled
+}
+
+// SetTokenPrefixLength 设置新建与轮换令牌时保存的展示前缀长度，只影响之后写入的令牌。
+// 前缀仅用于人工辨认，不保证唯一，定位令牌一律使用 ID。
+func (s *SQLStore) SetTokenPrefixLength(length int) {
+	if length <= 0 {
+		length = DefaultTokenPrefixLength
+	}
+	s.tokenPrefixLength = length
+}
+
+func (s *SQLStore) tokenPrefix(rawToken string) string {
+	if len(rawToken) > s.tokenPrefixLength {
+		return rawToken[:s.tokenPrefixLength]
+	}
+	return rawToken
+}
+
+func (s *SQLStore) formatMemoTime(t time.Time) string {
+	if s.millisecondTimestamps {
+		return t.UTC().Truncate(time.Millisecond).Format(memoMillisecondTimeLayout)
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+// NormalizeMemoTimestamps 将已有备忘录与变更事件的时间改写为毫秒精度的定长格式，
+// 避免旧数据与新写入的时间混合比较时出现边界重复或遗漏。
+func (s *SQLStore) NormalizeMemoTimestamps(ctx context.Context) error {
+	return withTx(ctx, s.db, func(tx *sql.Tx) error {
+		statements := []string{
+			`UPDATE memos SET
+				create_time = strftime('%Y-%m-%dT%H:%M:%fZ', create_time),
+				update_time = strftime('%Y-%m-%dT%H:%M:%fZ', update_time),
+				display_time = strftime('%Y-%m-%dT%H:%M:%fZ', display_time)
+			WHERE length(create_time) != 24 OR length(update_time) != 24 OR length(display_time) != 24`,
+			`UPDATE memo_change_events SET event_time = strftime('%Y-%m-%dT%H:%M:%fZ', event_time)
+			WHERE length(event_time) != 24`,
+		}
+		for _, statement := range statements {
+			if _, err := tx.ExecContext(ctx, statement); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 }
 
 func (s *SQLStore) DB() *sql.DB {
 	return s.db
 }
 
+func (s *SQLStore) Ping(ctx context.Context) error {
+	var one int
+	return s.db.QueryRowContext(ctx, `SELECT 1`).Scan(&one)
+}
+
 type MemoUpdate struct {
 	Content      *string
 	Visibility   *models.Visibility
@@ -35,16 +103,30 @@ type MemoUpdate struct {
 	LongitudeSet bool
 	Longitude    *float64
 	Payload      *models.MemoPayload
+	GroupShare   *MemoGroupShare
+	CreateTime   *time.Time
+}
+
+// MemoGroupShare records which collaborators were added to a memo by sharing
+// it with a group, so a later resync only removes tags the share created.
+type MemoGroupShare struct {
+	GroupID int64
+	UserIDs []int64
 }
 
 type MemoQueryBounds struct {
 	UpdatedAfter         *time.Time
 	UpdatedBeforeOrEqual *time.Time
+	// BeforeCreateTime/BeforeID seek past a keyset cursor in create_time DESC, id DESC order.
+	BeforeCreateTime *time.Time
+	BeforeID         int64
 }
 
 const (
 	memoChangeEventTypeDelete            = "DELETE"
 	memoChangeEventTypeVisibilityRevoked = "VISIBILITY_REVOKED"
+	memoChangeEventTypeArchive           = "ARCHIVE"
+	memoChangeEventTypeRestore           = "RESTORE"
 )
 
 func (s *SQLStore) CreateUser(ctx context.Context, username string, displayName string, role string) (models.User, error) {
@@ -83,7 +165,7 @@ func (s *SQLStore) GetUserByID(ctx context.Context, id int64) (models.User, erro
 	var updateTime string
 	err := s.db.QueryRowContext(
 		ctx,
-		`SELECT id, username, display_name, avatar_url, password_hash, role, default_visibility, create_time, update_time
+		`SELECT id, username, display_name, avatar_url, password_hash, role, default_visibility, default_page_size, create_time, update_time
 		FROM users
 		WHERE id = ?`,
 		id,
@@ -95,6 +177,7 @@ func (s *SQLStore) GetUserByID(ctx context.Context, id int64) (models.User, erro
 		&user.PasswordHash,
 		&user.Role,
 		&defaultVisibility,
+		&user.DefaultPageSize,
 		&createTime,
 		&updateTime,
 	)
@@ -120,7 +203,7 @@ func (s *SQLStore) GetUserByUsername(ctx context.Context, username string) (mode
 	var updateTime string
 	err := s.db.QueryRowContext(
 		ctx,
-		`SELECT id, username, display_name, avatar_url, password_hash, role, default_visibility, create_time, update_time
+		`SELECT id, username, display_name, avatar_url, password_hash, role, default_visibility, default_page_size, create_time, update_time
 		FROM users
 		WHERE username = ? COLLATE NOCASE`,
 		username,
@@ -132,6 +215,7 @@ func (s *SQLStore) GetUserByUsername(ctx context.Context, username string) (mode
 		&user.PasswordHash,
 		&user.Role,
 		&defaultVisibility,
+		&user.DefaultPageSize,
 		&createTime,
 		&updateTime,
 	)
@@ -155,26 +239,29 @@ func (s *SQLStore) CreatePersonalAccessToken(ctx context.Context, userID int64,
 }
 
 func (s *SQLStore) CreatePersonalAccessTokenWithExpiry(ctx context.Context, userID int64, rawToken string, description string, expiresAt *time.Time) (models.PersonalAccessToken, error) {
+	return s.CreatePersonalAccessTokenWithScopes(ctx, userID, rawToken, description, expiresAt, nil)
+}
+
+// CreatePersonalAccessTokenWithScopes 创建限定权限范围的令牌，scopes 为空表示完整权限。
+func (s *SQLStore) CreatePersonalAccessTokenWithScopes(ctx context.Context, userID int64, rawToken string, description string, expiresAt *time.Time, scopes []string) (models.PersonalAccessToken, error) {
 	now := time.Now().UTC()
 	tokenHash := HashToken(rawToken)
-	tokenPrefix := rawToken
-	if len(tokenPrefix) > 8 {
-		tokenPrefix = tokenPrefix[:8]
-	}
+	tokenPrefix := s.tokenPrefix(rawToken)
 	var expiresValue any
 	if expiresAt != nil {
 		expiresValue = expiresAt.UTC().Format(time.RFC3339Nano)
 	}
 	res, err := s.db.ExecContext(
 		ctx,
-		`INSERT INTO personal_access_tokens (user_id, token_prefix, token_hash, description, created_at, expires_at)
-		VALUES (?, ?, ?, ?, ?, ?)`,
+		`INSERT INTO personal_access_tokens (user_id, token_prefix, token_hash, description, created_at, expires_at, scopes)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
 		userID,
 		tokenPrefix,
 		tokenHash,
 		description,
 		now.Format(time.RFC3339Nano),
 		expiresValue,
+		strings.Join(scopes, ","),
 	)
 	if err != nil {
 		return models.PersonalAccessToken{}, err
@@ -192,9 +279,10 @@ func (s *SQLStore) GetPersonalAccessTokenByID(ctx context.Context, id int64) (mo
 	var lastUsedAt sql.NullString
 	var expiresAt sql.NullString
 	var revokedAt sql.NullString
+	var scopes string
 	err := s.db.QueryRowContext(
 		ctx,
-		`SELECT id, user_id, token_prefix, token_hash, description, created_at, last_used_at, expires_at, revoked_at
+		`SELECT id, user_id, token_prefix, token_hash, description, created_at, last_used_at, expires_at, revoked_at, scopes, last_used_ip, last_used_user_agent
 		FROM personal_access_tokens WHERE id = ?`,
 		id,
 	).Scan(
@@ -207,10 +295,14 @@ func (s *SQLStore) GetPersonalAccessTokenByID(ctx context.Context, id int64) (mo
 		&lastUsedAt,
 		&expiresAt,
 		&revokedAt,
+		&scopes,
+		&token.LastUsedIP,
+		&token.LastUsedUserAgent,
 	)
 	if err != nil {
 		return models.PersonalAccessToken{}, err
 	}
+	token.Scopes = splitTokenScopes(scopes)
 	var errParse error
 	token.CreatedAt, errParse = parseTime(createdAt)
 	if errParse != nil {
@@ -234,7 +326,7 @@ func (s *SQLStore) GetPersonalAccessTokenByID(ctx context.Context, id int64) (mo
 func (s *SQLStore) ListPersonalAccessTokensByUserID(ctx context.Context, userID int64) ([]models.PersonalAccessToken, error) {
 	rows, err := s.db.QueryContext(
 		ctx,
-		`SELECT id, user_id, token_prefix, token_hash, description, created_at, last_used_at, expires_at, revoked_at
+		`SELECT id, user_id, token_prefix, token_hash, description, created_at, last_used_at, expires_at, revoked_at, scopes, last_used_ip, last_used_user_agent
 		FROM personal_access_tokens
 		WHERE user_id = ?
 		ORDER BY created_at DESC, id DESC`,
@@ -247,46 +339,113 @@ func (s *SQLStore) ListPersonalAccessTokensByUserID(ctx context.Context, userID
 
 	result := make([]models.PersonalAccessToken, 0)
 	for rows.Next() {
-		var token models.PersonalAccessToken
-		var createdAt string
-		var lastUsedAt sql.NullString
-		var expiresAt sql.NullString
-		var revokedAt sql.NullString
-		if err := rows.Scan(
-			&token.ID,
-			&token.UserID,
-			&token.TokenPrefix,
-			&token.TokenHash,
-			&token.Description,
-			&createdAt,
-			&lastUsedAt,
-			&expiresAt,
-			&revokedAt,
-		); err != nil {
+		token, err := scanPersonalAccessToken(rows)
+		if err != nil {
 			return nil, err
 		}
-		var parseErr error
-		token.CreatedAt, parseErr = parseTime(createdAt)
-		if parseErr != nil {
-			return nil, parseErr
-		}
-		token.LastUsedAt, parseErr = parseNullableTime(lastUsedAt)
-		if parseErr != nil {
-			return nil, parseErr
-		}
-		token.ExpiresAt, parseErr = parseNullableTime(expiresAt)
-		if parseErr != nil {
-			return nil, parseErr
-		}
-		token.RevokedAt, parseErr = parseNullableTime(revokedAt)
-		if parseErr != nil {
-			return nil, parseErr
-		}
 		result = append(result, token)
 	}
 	return result, rows.Err()
 }
 
+func (s *SQLStore) ListPersonalAccessTokensByUserIDPaged(ctx context.Context, userID int64, includeRevoked bool, limit int, offset int) ([]models.PersonalAccessToken, int64, error) {
+	where := `user_id = ?`
+	if !includeRevoked {
+		where += ` AND revoked_at IS NULL`
+	}
+
+	var total int64
+	if err := s.db.QueryRowContext(
+		ctx,
+		`SELECT COUNT(1) FROM personal_access_tokens WHERE `+where,
+		userID,
+	).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := s.db.QueryContext(
+		ctx,
+		`SELECT id, user_id, token_prefix, token_hash, description, created_at, last_used_at, expires_at, revoked_at, scopes, last_used_ip, last_used_user_agent
+		FROM personal_access_tokens
+		WHERE `+where+`
+		ORDER BY created_at DESC, id DESC
+		LIMIT ? OFFSET ?`,
+		userID,
+		limit,
+		offset,
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	result := make([]models.PersonalAccessToken, 0)
+	for rows.Next() {
+		token, err := scanPersonalAccessToken(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		result = append(result, token)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	return result, total, nil
+}
+
+func scanPersonalAccessToken(scanner interface {
+	Scan(dest ...any) error
+}) (models.PersonalAccessToken, error) {
+	var token models.PersonalAccessToken
+	var createdAt string
+	var lastUsedAt sql.NullString
+	var expiresAt sql.NullString
+	var revokedAt sql.NullString
+	var scopes string
+	if err := scanner.Scan(
+		&token.ID,
+		&token.UserID,
+		&token.TokenPrefix,
+		&token.TokenHash,
+		&token.Description,
+		&createdAt,
+		&lastUsedAt,
+		&expiresAt,
+		&revokedAt,
+		&scopes,
+		&token.LastUsedIP,
+		&token.LastUsedUserAgent,
+	); err != nil {
+		return models.PersonalAccessToken{}, err
+	}
+	token.Scopes = splitTokenScopes(scopes)
+	var parseErr error
+	token.CreatedAt, parseErr = parseTime(createdAt)
+	if parseErr != nil {
+		return models.PersonalAccessToken{}, parseErr
+	}
+	token.LastUsedAt, parseErr = parseNullableTime(lastUsedAt)
+	if parseErr != nil {
+		return models.PersonalAccessToken{}, parseErr
+	}
+	token.ExpiresAt, parseErr = parseNullableTime(expiresAt)
+	if parseErr != nil {
+		return models.PersonalAccessToken{}, parseErr
+	}
+	token.RevokedAt, parseErr = parseNullableTime(revokedAt)
+	if parseErr != nil {
+		return models.PersonalAccessToken{}, parseErr
+	}
+	return token, nil
+}
+
+func splitTokenScopes(raw string) []string {
+	if strings.TrimSpace(raw) == "" {
+		return nil
+	}
+	return strings.Split(raw, ",")
+}
+
 func (s *SQLStore) RevokePersonalAccessToken(ctx context.Context, tokenID int64) error {
 	res, err := s.db.ExecContext(
 		ctx,
@@ -309,6 +468,133 @@ func (s *SQLStore) RevokePersonalAccessToken(ctx context.Context, tokenID int64)
 	return nil
 }
 
+func (s *SQLStore) RotatePersonalAccessToken(ctx context.Context, tokenID int64, rawToken string, expiresAt *time.Time) (models.PersonalAccessToken, error) {
+	now := time.Now().UTC()
+	tokenPrefix := s.tokenPrefix(rawToken)
+	var expiresValue any
+	if expiresAt != nil {
+		expiresValue = expiresAt.UTC().Format(time.RFC3339Nano)
+	}
+
+	var newID int64
+	err := withTx(ctx, s.db, func(tx *sql.Tx) error {
+		var userID int64
+		var description string
+		var scopes string
+		if err := tx.QueryRowContext(
+			ctx,
+			`SELECT user_id, description, scopes FROM personal_access_tokens WHERE id = ? AND revoked_at IS NULL`,
+			tokenID,
+		).Scan(&userID, &description, &scopes); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(
+			ctx,
+			`UPDATE personal_access_tokens SET revoked_at = ? WHERE id = ?`,
+			now.Format(time.RFC3339Nano),
+			tokenID,
+		); err != nil {
+			return err
+		}
+		res, err := tx.ExecContext(
+			ctx,
+			`INSERT INTO personal_access_tokens (user_id, token_prefix, token_hash, description, created_at, expires_at, scopes)
+			VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			userID,
+			tokenPrefix,
+			HashToken(rawToken),
+			description,
+			now.Format(time.RFC3339Nano),
+			expiresValue,
+			scopes,
+		)
+		if err != nil {
+			return err
+		}
+		newID, err = res.LastInsertId()
+		return err
+	})
+	if err != nil {
+		return models.PersonalAccessToken{}, err
+	}
+	return s.GetPersonalAccessTokenByID(ctx, newID)
+}
+
+func (s *SQLStore) CreatePasswordResetToken(ctx context.Context, userID int64, rawToken string, expiresAt time.Time) error {
+	_, err := s.db.ExecContext(
+		ctx,
+		`INSERT INTO password_reset_tokens (user_id, token_hash, created_at, expires_at)
+		VALUES (?, ?, ?, ?)`,
+		userID,
+		HashToken(rawToken),
+		time.Now().UTC().Format(time.RFC3339Nano),
+		expiresAt.UTC().Format(time.RFC3339Nano),
+	)
+	return err
+}
+
+// ResetPasswordWithToken 在同一事务内消费重置令牌、写入新的密码哈希，并吊销该用户现有的访问令牌与其余重置令牌，
+// 使重置前泄露的凭据一并失效。令牌无效或过期时返回 sql.ErrNoRows。
+func (s *SQLStore) ResetPasswordWithToken(ctx context.Context, rawToken string, passwordHash string) (int64, error) {
+	var userID int64
+	err := withTx(ctx, s.db, func(tx *sql.Tx) error {
+		now := time.Now().UTC().Format(time.RFC3339Nano)
+		if err := tx.QueryRowContext(
+			ctx,
+			`DELETE FROM password_reset_tokens
+			WHERE token_hash = ? AND expires_at > ?
+			RETURNING user_id`,
+			HashToken(rawToken),
+			now,
+		).Scan(&userID); err != nil {
+			return err
+		}
+		res, err := tx.ExecContext(
+			ctx,
+			`UPDATE users SET password_hash = ?, update_time = ? WHERE id = ?`,
+			passwordHash,
+			now,
+			userID,
+		)
+		if err != nil {
+			return err
+		}
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if affected == 0 {
+			return sql.ErrNoRows
+		}
+		if _, err := tx.ExecContext(
+			ctx,
+			`UPDATE personal_access_tokens SET revoked_at = ? WHERE user_id = ? AND revoked_at IS NULL`,
+			now,
+			userID,
+		); err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `DELETE FROM password_reset_tokens WHERE user_id = ?`, userID)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return userID, nil
+}
+
+func (s *SQLStore) DeleteExpiredPasswordResetTokens(ctx context.Context, now time.Time) (int64, error) {
+	res, err := s.db.ExecContext(
+		ctx,
+		`DELETE FROM password_reset_tokens WHERE expires_at <= ?`,
+		now.UTC().Format(time.RFC3339Nano),
+	)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
 func (s *SQLStore) GetUserByToken(ctx context.Context, rawToken string) (models.User, models.PersonalAccessToken, error) {
 	tokenHash := HashToken(rawToken)
 	now := time.Now().UTC().Format(time.RFC3339Nano)
@@ -321,12 +607,13 @@ func (s *SQLStore) GetUserByToken(ctx context.Context, rawToken string) (models.
 	var lastUsedAt sql.NullString
 	var expiresAt sql.NullString
 	var revokedAt sql.NullString
+	var scopes string
 
 	err := s.db.QueryRowContext(
 		ctx,
 		`SELECT
-			u.id, u.username, u.display_name, u.avatar_url, u.password_hash, u.role, u.default_visibility, u.create_time, u.update_time,
-			t.id, t.user_id, t.token_prefix, t.token_hash, t.description, t.created_at, t.last_used_at, t.expires_at, t.revoked_at
+			u.id, u.username, u.display_name, u.avatar_url, u.password_hash, u.role, u.default_visibility, u.default_page_size, u.create_time, u.update_time,
+			t.id, t.user_id, t.token_prefix, t.token_hash, t.description, t.created_at, t.last_used_at, t.expires_at, t.revoked_at, t.scopes, t.last_used_ip, t.last_used_user_agent
 		FROM personal_access_tokens t
 		JOIN users u ON u.id = t.user_id
 		WHERE t.token_hash = ?
@@ -342,6 +629,7 @@ func (s *SQLStore) GetUserByToken(ctx context.Context, rawToken string) (models.
 		&user.PasswordHash,
 		&user.Role,
 		&defaultVisibility,
+		&user.DefaultPageSize,
 		&userCreateTime,
 		&userUpdateTime,
 		&token.ID,
@@ -353,10 +641,14 @@ func (s *SQLStore) GetUserByToken(ctx context.Context, rawToken string) (models.
 		&lastUsedAt,
 		&expiresAt,
 		&revokedAt,
+		&scopes,
+		&token.LastUsedIP,
+		&token.LastUsedUserAgent,
 	)
 	if err != nil {
 		return models.User{}, models.PersonalAccessToken{}, err
 	}
+	token.Scopes = splitTokenScopes(scopes)
 
 	user.DefaultVisibility = models.Visibility(defaultVisibility)
 	var errParse error
@@ -403,94 +695,409 @@ func (s *SQLStore) UpdateUserAvatar(ctx context.Context, userID int64, avatarURL
 	return s.GetUserByID(ctx, userID)
 }
 
-func (s *SQLStore) CountUsers(ctx context.Context) (int64, error) {
-	var count int64
-	if err := s.db.QueryRowContext(ctx, `SELECT COUNT(1) FROM users`).Scan(&count); err != nil {
-		return 0, err
-	}
-	return count, nil
-}
-
-func (s *SQLStore) TouchPersonalAccessToken(ctx context.Context, tokenID int64) error {
+func (s *SQLStore) UpdateUserDefaultPageSize(ctx context.Context, userID int64, pageSize int) (models.User, error) {
 	_, err := s.db.ExecContext(
 		ctx,
-		`UPDATE personal_access_tokens SET last_used_at = ? WHERE id = ?`,
+		`UPDATE users
+		SET default_page_size = ?, update_time = ?
+		WHERE id = ?`,
+		pageSize,
 		time.Now().UTC().Format(time.RFC3339Nano),
-		tokenID,
+		userID,
 	)
-	return err
+	if err != nil {
+		return models.User{}, err
+	}
+	return s.GetUserByID(ctx, userID)
 }
 
-func (s *SQLStore) CreateMemo(ctx context.Context, creatorID int64, content string, visibility models.Visibility, state models.MemoState, pinned bool, payload models.MemoPayload, createTime time.Time, latitude *float64, longitude *float64) (models.Memo, error) {
-	return s.CreateMemoWithAttachments(
+func (s *SQLStore) UpdateUserDefaultVisibility(ctx context.Context, userID int64, visibility models.Visibility) (models.User, error) {
+	_, err := s.db.ExecContext(
 		ctx,
-		creatorID,
-		content,
-		visibility,
-		state,
-		pinned,
-		payload,
-		createTime,
-		latitude,
-		longitude,
-		[]int64{},
+		`UPDATE users
+		SET default_visibility = ?, update_time = ?
+		WHERE id = ?`,
+		string(visibility),
+		time.Now().UTC().Format(time.RFC3339Nano),
+		userID,
 	)
-}
-
-func (s *SQLStore) CreateMemoWithAttachments(ctx context.Context, creatorID int64, content string, visibility models.Visibility, state models.MemoState, pinned bool, payload models.MemoPayload, createTime time.Time, latitude *float64, longitude *float64, attachmentIDs []int64) (models.Memo, error) {
-	now := time.Now().UTC()
-	pinnedInt := 0
-	if pinned {
-		pinnedInt = 1
-	}
-
-	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
-		return models.Memo{}, err
+		return models.User{}, err
 	}
-	defer tx.Rollback() //nolint:errcheck
+	return s.GetUserByID(ctx, userID)
+}
 
-	res, err := tx.ExecContext(
-		ctx,
-		`INSERT INTO memos (
-			creator_id, content, visibility, state, pinned, create_time, update_time, display_time,
-			latitude, longitude, has_link, has_task_list, has_code, has_incomplete_tasks
+// UpdateUserRole 修改用户角色；把最后一个管理员改为非管理员角色时不做修改并返回 ErrLastSuperUser。
+func (s *SQLStore) UpdateUserRole(ctx context.Context, userID int64, role string) error {
+	return withTx(ctx, s.db, func(tx *sql.Tx) error {
+		var exists int
+		if err := tx.QueryRowContext(ctx, `SELECT 1 FROM users WHERE id = ?`, userID).Scan(&exists); err != nil {
+			return err
+		}
+		args := []any{role, time.Now().UTC().Format(time.RFC3339Nano), userID, role}
+		args = append(args, superUserRoles...)
+		args = append(args, superUserRoles...)
+		args = append(args, superUserRoles...)
+		res, err := tx.ExecContext(
+			ctx,
+			`UPDATE users
+			SET role = ?, update_time = ?
+			WHERE id = ? AND (? IN (?, ?) OR `+lastSuperUserGuard+`)`,
+			args...,
 		)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
-		creatorID,
-		content,
-		visibility,
-		state,
-		pinnedInt,
-		createTime.UTC().Format(time.RFC3339Nano),
-		now.Format(time.RFC3339Nano),
-		createTime.UTC().Format(time.RFC3339Nano),
-		latitude,
-		longitude,
-		boolToSQLiteInt(payload.Property.HasLink),
-		boolToSQLiteInt(payload.Property.HasTaskList),
-		boolToSQLiteInt(payload.Property.HasCode),
-		boolToSQLiteInt(payload.Property.HasIncompleteTasks),
+		if err != nil {
+			return err
+		}
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if affected == 0 {
+			return ErrLastSuperUser
+		}
+		return nil
+	})
+}
+
+func (s *SQLStore) UpdateUserPasswordHash(ctx context.Context, userID int64, passwordHash string) error {
+	res, err := s.db.ExecContext(
+		ctx,
+		`UPDATE users
+		SET password_hash = ?, update_time = ?
+		WHERE id = ?`,
+		passwordHash,
+		time.Now().UTC().Format(time.RFC3339Nano),
+		userID,
 	)
 	if err != nil {
-		return models.Memo{}, err
+		return err
 	}
-	memoID, err := res.LastInsertId()
+	affected, err := res.RowsAffected()
 	if err != nil {
-		return models.Memo{}, err
-	}
-	if err := setMemoAttachmentsInTx(ctx, tx, memoID, attachmentIDs); err != nil {
-		return models.Memo{}, err
+		return err
 	}
-	if err := setMemoTagsInTx(ctx, tx, creatorID, memoID, payload.Tags); err != nil {
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// superUserRoles 为拥有管理权限的角色；实例中最后一个此类用户不能被删除或降级。
+var superUserRoles = []any{"ADMIN", "HOST"}
+
+// ErrLastSuperUser 表示操作会移除实例中最后一个管理员。
+var ErrLastSuperUser = errors.New("cannot remove the last admin user")
+
+// lastSuperUserGuard 是 users 上的条件：目标不是管理员，或者除它以外仍有其他管理员。
+// 判断与写入在同一条语句内完成，并发删除或降级两个管理员时不会都成功。
+const lastSuperUserGuard = `(role NOT IN (?, ?) OR EXISTS (SELECT 1 FROM users other WHERE other.id <> users.id AND other.role IN (?, ?)))`
+
+// DeleteUser 删除用户及其级联数据；为其名下的备忘录写入 DELETE 变更事件，
+// 使协作者和其他可见者的增量同步能移除这些备忘录。目标是最后一个管理员时返回 ErrLastSuperUser。
+func (s *SQLStore) DeleteUser(ctx context.Context, userID int64) error {
+	var audience memoAudience
+	err := withTx(ctx, s.db, func(tx *sql.Tx) error {
+		var exists int
+		if err := tx.QueryRowContext(ctx, `SELECT 1 FROM users WHERE id = ?`, userID).Scan(&exists); err != nil {
+			return err
+		}
+
+		rows, err := tx.QueryContext(ctx, `SELECT id, visibility FROM memos WHERE creator_id = ? ORDER BY id ASC`, userID)
+		if err != nil {
+			return err
+		}
+		type ownedMemo struct {
+			id         int64
+			visibility models.Visibility
+		}
+		memos := make([]ownedMemo, 0)
+		for rows.Next() {
+			var memo ownedMemo
+			if err := rows.Scan(&memo.id, &memo.visibility); err != nil {
+				rows.Close()
+				return err
+			}
+			memos = append(memos, memo)
+		}
+		if err := rows.Close(); err != nil {
+			return err
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		now := time.Now().UTC()
+		for _, memo := range memos {
+			tagNames, err := listMemoTagNamesInTx(ctx, tx, memo.id)
+			if err != nil {
+				return err
+			}
+			collaboratorIDs := collaboratorIDSetFromTags(tagNames)
+			audience.addMemo(memo.visibility, userID, collaboratorIDs)
+			if err := s.appendMemoViewerEventInTx(
+				ctx,
+				tx,
+				memo.id,
+				userID,
+				memo.visibility,
+				collaboratorIDs,
+				memoChangeEventTypeDelete,
+				now,
+			); err != nil {
+				return err
+			}
+		}
+
+		args := append([]any{userID}, superUserRoles...)
+		args = append(args, superUserRoles...)
+		res, err := tx.ExecContext(ctx, `DELETE FROM users WHERE id = ? AND `+lastSuperUserGuard, args...)
+		if err != nil {
+			return err
+		}
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if affected == 0 {
+			return ErrLastSuperUser
+		}
+		// 用户已删除，其他人备忘录上的 collab/<id> 标签直接清理，不写变更事件
+		collaboratorTag := fmt.Sprintf("collab/%d", userID)
+		if _, err := tx.ExecContext(
+			ctx,
+			`DELETE FROM memo_tags WHERE tag_id IN (SELECT id FROM tags WHERE name = ?)`,
+			collaboratorTag,
+		); err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `DELETE FROM tags WHERE name = ?`, collaboratorTag)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	s.notifyMemoChanged(audience)
+	return nil
+}
+
+func (s *SQLStore) CountMemosByCreator(ctx context.Context, creatorID int64) (int64, error) {
+	var count int64
+	if err := s.db.QueryRowContext(ctx, `SELECT COUNT(1) FROM memos WHERE creator_id = ?`, creatorID).Scan(&count); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func (s *SQLStore) CountAttachmentsByCreator(ctx context.Context, creatorID int64) (int64, error) {
+	var count int64
+	if err := s.db.QueryRowContext(ctx, `SELECT COUNT(1) FROM attachments WHERE creator_id = ?`, creatorID).Scan(&count); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func (s *SQLStore) CountUsers(ctx context.Context) (int64, error) {
+	var count int64
+	if err := s.db.QueryRowContext(ctx, `SELECT COUNT(1) FROM users`).Scan(&count); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func (s *SQLStore) ListUsers(ctx context.Context, limit int, offset int) ([]models.User, error) {
+	rows, err := s.db.QueryContext(
+		ctx,
+		`SELECT id, username, display_name, avatar_url, password_hash, role, default_visibility, default_page_size, create_time, update_time
+		FROM users
+		ORDER BY id ASC
+		LIMIT ? OFFSET ?`,
+		limit,
+		offset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]models.User, 0)
+	for rows.Next() {
+		var user models.User
+		var defaultVisibility string
+		var createTime string
+		var updateTime string
+		if err := rows.Scan(
+			&user.ID,
+			&user.Username,
+			&user.DisplayName,
+			&user.AvatarURL,
+			&user.PasswordHash,
+			&user.Role,
+			&defaultVisibility,
+			&user.DefaultPageSize,
+			&createTime,
+			&updateTime,
+		); err != nil {
+			return nil, err
+		}
+		user.DefaultVisibility = models.Visibility(defaultVisibility)
+		user.CreateTime, err = parseTime(createTime)
+		if err != nil {
+			return nil, err
+		}
+		user.UpdateTime, err = parseTime(updateTime)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, user)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// TouchPersonalAccessToken 记录令牌最近一次使用的时间；ip、userAgent 为空时保留原值。
+func (s *SQLStore) TouchPersonalAccessToken(ctx context.Context, tokenID int64, ip string, userAgent string) error {
+	_, err := s.db.ExecContext(
+		ctx,
+		`UPDATE personal_access_tokens
+		SET last_used_at = ?,
+			last_used_ip = COALESCE(NULLIF(?, ''), last_used_ip),
+			last_used_user_agent = COALESCE(NULLIF(?, ''), last_used_user_agent)
+		WHERE id = ?`,
+		time.Now().UTC().Format(time.RFC3339Nano),
+		ip,
+		userAgent,
+		tokenID,
+	)
+	return err
+}
+
+func (s *SQLStore) CreateMemo(ctx context.Context, creatorID int64, content string, visibility models.Visibility, state models.MemoState, pinned bool, payload models.MemoPayload, createTime time.Time, latitude *float64, longitude *float64) (models.Memo, error) {
+	return s.CreateMemoWithAttachments(
+		ctx,
+		creatorID,
+		content,
+		visibility,
+		state,
+		pinned,
+		payload,
+		createTime,
+		latitude,
+		longitude,
+		[]int64{},
+	)
+}
+
+func (s *SQLStore) CreateMemoWithAttachments(ctx context.Context, creatorID int64, content string, visibility models.Visibility, state models.MemoState, pinned bool, payload models.MemoPayload, createTime time.Time, latitude *float64, longitude *float64, attachmentIDs []int64) (models.Memo, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return models.Memo{}, err
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	memoID, err := s.insertMemoInTx(ctx, tx, creatorID, MemoCreate{
+		Content:       content,
+		Visibility:    visibility,
+		State:         state,
+		Pinned:        pinned,
+		Payload:       payload,
+		CreateTime:    createTime,
+		Latitude:      latitude,
+		Longitude:     longitude,
+		AttachmentIDs: attachmentIDs,
+	}, time.Now().UTC())
+	if err != nil {
 		return models.Memo{}, err
 	}
 	if err := tx.Commit(); err != nil {
 		return models.Memo{}, err
 	}
+	var audience memoAudience
+	audience.addMemo(visibility, creatorID, collaboratorIDSetFromTags(payload.Tags))
+	s.notifyMemoChanged(audience)
 	return s.GetMemoByID(ctx, memoID)
 }
 
+type MemoCreate struct {
+	Content       string
+	Visibility    models.Visibility
+	State         models.MemoState
+	Pinned        bool
+	Payload       models.MemoPayload
+	CreateTime    time.Time
+	Latitude      *float64
+	Longitude     *float64
+	AttachmentIDs []int64
+}
+
+// CreateMemosBatch 在同一个事务中创建多条备忘录，任意一条失败则全部回滚，返回新建的备忘录 ID。
+func (s *SQLStore) CreateMemosBatch(ctx context.Context, creatorID int64, memos []MemoCreate) ([]int64, error) {
+	ids := make([]int64, 0, len(memos))
+	err := withTx(ctx, s.db, func(tx *sql.Tx) error {
+		now := time.Now().UTC()
+		for _, memo := range memos {
+			memoID, err := s.insertMemoInTx(ctx, tx, creatorID, memo, now)
+			if err != nil {
+				return err
+			}
+			ids = append(ids, memoID)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	var audience memoAudience
+	for _, memo := range memos {
+		audience.addMemo(memo.Visibility, creatorID, collaboratorIDSetFromTags(memo.Payload.Tags))
+	}
+	s.notifyMemoChanged(audience)
+	return ids, nil
+}
+
+func (s *SQLStore) insertMemoInTx(ctx context.Context, tx *sql.Tx, creatorID int64, memo MemoCreate, now time.Time) (int64, error) {
+	pinnedInt := 0
+	if memo.Pinned {
+		pinnedInt = 1
+	}
+	res, err := tx.ExecContext(
+		ctx,
+		`INSERT INTO memos (
+			creator_id, content, visibility, state, pinned, create_time, update_time, display_time,
+			latitude, longitude, has_link, has_task_list, has_code, has_incomplete_tasks
+		)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		creatorID,
+		memo.Content,
+		memo.Visibility,
+		memo.State,
+		pinnedInt,
+		s.formatMemoTime(memo.CreateTime),
+		s.formatMemoTime(now),
+		s.formatMemoTime(memo.CreateTime),
+		memo.Latitude,
+		memo.Longitude,
+		boolToSQLiteInt(memo.Payload.Property.HasLink),
+		boolToSQLiteInt(memo.Payload.Property.HasTaskList),
+		boolToSQLiteInt(memo.Payload.Property.HasCode),
+		boolToSQLiteInt(memo.Payload.Property.HasIncompleteTasks),
+	)
+	if err != nil {
+		return 0, err
+	}
+	memoID, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	if err := setMemoAttachmentsInTx(ctx, tx, memoID, memo.AttachmentIDs); err != nil {
+		return 0, err
+	}
+	if err := setMemoTagsInTx(ctx, tx, creatorID, memoID, memo.Payload.Tags); err != nil {
+		return 0, err
+	}
+	return memoID, nil
+}
+
 func (s *SQLStore) GetMemoByID(ctx context.Context, id int64) (models.Memo, error) {
 	row := s.db.QueryRowContext(
 		ctx,
@@ -503,15 +1110,11 @@ func (s *SQLStore) GetMemoByID(ctx context.Context, id int64) (models.Memo, erro
 	if err != nil {
 		return models.Memo{}, err
 	}
-	tagsByMemoID, err := s.listMemoTagsByMemoIDs(ctx, []int64{memo.ID})
-	if err != nil {
+	memos := []models.Memo{memo}
+	if err := s.LoadMemoTags(ctx, memos); err != nil {
 		return models.Memo{}, err
 	}
-	memo.Payload.Tags = tagsByMemoID[memo.ID]
-	if memo.Payload.Tags == nil {
-		memo.Payload.Tags = []string{}
-	}
-	return memo, nil
+	return memos[0], nil
 }
 
 func (s *SQLStore) UpdateMemo(ctx context.Context, memoID int64, update MemoUpdate) (models.Memo, error) {
@@ -526,17 +1129,23 @@ func (s *SQLStore) UpdateMemoWithAttachments(ctx context.Context, memoID int64,
 	defer tx.Rollback() //nolint:errcheck
 
 	var creatorID int64
-	var previousCollaboratorIDs map[int64]struct{}
-	if update.Payload != nil {
-		if err := tx.QueryRowContext(ctx, `SELECT creator_id FROM memos WHERE id = ?`, memoID).Scan(&creatorID); err != nil {
-			return models.Memo{}, err
-		}
-		previousTags, err := listMemoTagNamesInTx(ctx, tx, memoID)
-		if err != nil {
-			return models.Memo{}, err
-		}
-		previousCollaboratorIDs = collaboratorIDSetFromTags(previousTags)
+	var previousState string
+	var previousVisibility string
+	if err := tx.QueryRowContext(
+		ctx,
+		`SELECT creator_id, state, visibility FROM memos WHERE id = ?`,
+		memoID,
+	).Scan(&creatorID, &previousState, &previousVisibility); err != nil {
+		return models.Memo{}, err
+	}
+	previousTags, err := listMemoTagNamesInTx(ctx, tx, memoID)
+	if err != nil {
+		return models.Memo{}, err
 	}
+	previousCollaboratorIDs := collaboratorIDSetFromTags(previousTags)
+	// 旧版本与新版本的可见者都要被唤醒：失去可见性的用户也需要拉取到移除事件
+	var audience memoAudience
+	audience.addMemo(models.Visibility(previousVisibility), creatorID, previousCollaboratorIDs)
 
 	assignments := make([]string, 0, 8)
 	args := make([]any, 0, 8)
@@ -577,6 +1186,10 @@ func (s *SQLStore) UpdateMemoWithAttachments(ctx context.Context, memoID int64,
 			args = append(args, nil)
 		}
 	}
+	if update.CreateTime != nil {
+		assignments = append(assignments, "create_time = ?", "display_time = ?")
+		args = append(args, s.formatMemoTime(*update.CreateTime), s.formatMemoTime(*update.CreateTime))
+	}
 	if update.Payload != nil {
 		assignments = append(assignments, "has_link = ?")
 		args = append(args, boolToSQLiteInt(update.Payload.Property.HasLink))
@@ -589,7 +1202,7 @@ func (s *SQLStore) UpdateMemoWithAttachments(ctx context.Context, memoID int64,
 	}
 
 	assignments = append(assignments, "update_time = ?")
-	args = append(args, time.Now().UTC().Format(time.RFC3339Nano))
+	args = append(args, s.formatMemoTime(time.Now()))
 	args = append(args, memoID)
 
 	query := fmt.Sprintf(`UPDATE memos SET %s WHERE id = ?`, strings.Join(assignments, ", "))
@@ -617,7 +1230,7 @@ func (s *SQLStore) UpdateMemoWithAttachments(ctx context.Context, memoID int64,
 			}
 			revokedRecipientIDs = append(revokedRecipientIDs, collaboratorID)
 		}
-		if err := appendMemoChangeEventInTx(
+		if err := s.appendMemoChangeEventInTx(
 			ctx,
 			tx,
 			memoID,
@@ -629,13 +1242,116 @@ func (s *SQLStore) UpdateMemoWithAttachments(ctx context.Context, memoID int64,
 			return models.Memo{}, err
 		}
 	}
+	if update.GroupShare != nil {
+		if err := setMemoGroupShareInTx(ctx, tx, memoID, *update.GroupShare); err != nil {
+			return models.Memo{}, err
+		}
+	}
+	if update.State != nil && models.MemoState(previousState) != *update.State {
+		eventType := memoChangeEventTypeRestore
+		if *update.State == models.MemoStateArchived {
+			eventType = memoChangeEventTypeArchive
+		}
+		if err := s.appendMemoViewerEventInTx(
+			ctx,
+			tx,
+			memoID,
+			creatorID,
+			models.Visibility(previousVisibility),
+			previousCollaboratorIDs,
+			eventType,
+			time.Now().UTC(),
+		); err != nil {
+			return models.Memo{}, err
+		}
+	}
+
+	if err := addMemoAudienceInTx(ctx, tx, &audience, memoID); err != nil {
+		return models.Memo{}, err
+	}
 
 	if err := tx.Commit(); err != nil {
 		return models.Memo{}, err
 	}
+	s.notifyMemoChanged(audience)
 	return s.GetMemoByID(ctx, memoID)
 }
 
+// appendMemoViewerEventInTx 向能看到备忘录的用户写入事件：私有备忘录只记录创建者与协作者，
+// 公开或登录可见的备忘录改为广播事件，避免为实例内每个用户写一条接收者记录。
+func (s *SQLStore) appendMemoViewerEventInTx(
+	ctx context.Context,
+	tx *sql.Tx,
+	memoID int64,
+	creatorID int64,
+	visibility models.Visibility,
+	collaboratorIDs map[int64]struct{},
+	eventType string,
+	eventTime time.Time,
+) error {
+	if visibility != models.VisibilityPrivate {
+		return s.appendMemoBroadcastEventInTx(ctx, tx, memoID, creatorID, eventType, eventTime)
+	}
+	recipientIDs := make([]int64, 0, len(collaboratorIDs)+1)
+	recipientIDs = append(recipientIDs, creatorID)
+	for collaboratorID := range collaboratorIDs {
+		recipientIDs = append(recipientIDs, collaboratorID)
+	}
+	return s.appendMemoChangeEventInTx(ctx, tx, memoID, creatorID, eventType, recipientIDs, eventTime)
+}
+
+func (s *SQLStore) SetMemoVisibility(ctx context.Context, memoID int64, visibility models.Visibility) (bool, error) {
+	changed := false
+	err := withTx(ctx, s.db, func(tx *sql.Tx) error {
+		var creatorID int64
+		var current string
+		if err := tx.QueryRowContext(
+			ctx,
+			`SELECT creator_id, visibility FROM memos WHERE id = ?`,
+			memoID,
+		).Scan(&creatorID, &current); err != nil {
+			return err
+		}
+		if models.Visibility(current) == visibility {
+			return nil
+		}
+
+		now := time.Now().UTC()
+		if _, err := tx.ExecContext(
+			ctx,
+			`UPDATE memos SET visibility = ?, update_time = ? WHERE id = ?`,
+			visibility,
+			s.formatMemoTime(now),
+			memoID,
+		); err != nil {
+			return err
+		}
+		changed = true
+
+		if visibility != models.VisibilityPrivate || models.Visibility(current) == models.VisibilityPrivate {
+			return nil
+		}
+		// 公开或登录可见的备忘录任何用户都可能看到，事件不逐个写入接收者；
+		// 创建者与仍保留访问权的协作者会在同一同步窗口内收到该备忘录的更新，从而抵消这条撤销事件。
+		return s.appendMemoBroadcastEventInTx(
+			ctx,
+			tx,
+			memoID,
+			creatorID,
+			memoChangeEventTypeVisibilityRevoked,
+			now,
+		)
+	})
+	if err != nil {
+		return false, err
+	}
+	if changed {
+		// 可见性确有变化时新旧两者至少一个不是私有，所有订阅者都可能受影响
+		s.notifyMemoChanged(broadcastMemoAudience())
+	}
+	return changed, nil
+}
+
 func (s *SQLStore) DeleteMemo(ctx context.Context, memoID int64) error {
 	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
@@ -650,6 +1366,122 @@ func (s *SQLStore) DeleteMemo(ctx context.Context, memoID int64) error {
 		}
 		return err
 	}
+	var audience memoAudience
+	if err := addMemoAudienceInTx(ctx, tx, &audience, memoID); err != nil {
+		return err
+	}
+	if err := s.appendMemoDeleteEventInTx(ctx, tx, memoID, creatorID, time.Now().UTC()); err != nil {
+		return err
+	}
+
+	res, err := tx.ExecContext(ctx, `DELETE FROM memos WHERE id = ?`, memoID)
+	if err != nil {
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	s.notifyMemoChanged(audience)
+	return nil
+}
+
+// archivedMemoPurgeBatchSize 为每个事务删除的归档备忘录数量，避免一次长事务长时间占用唯一的数据库连接。
+const archivedMemoPurgeBatchSize = 200
+
+// DeleteArchivedMemosOlderThan 分批删除最后更新时间早于 cutoff 的归档备忘录，每批一个事务，
+// 并像 DeleteMemo 一样为创建者与协作者写入 DELETE 变更事件，返回删除数量。
+// 中途出错时已提交的批次保持删除，下次执行会继续处理剩余部分。
+func (s *SQLStore) DeleteArchivedMemosOlderThan(ctx context.Context, cutoff time.Time) (int64, error) {
+	var deleted int64
+	for {
+		batchDeleted, more, err := s.deleteArchivedMemoBatch(ctx, cutoff)
+		deleted += batchDeleted
+		if err != nil {
+			return deleted, err
+		}
+		if !more {
+			return deleted, nil
+		}
+	}
+}
+
+// deleteArchivedMemoBatch 在一个事务内删除至多 archivedMemoPurgeBatchSize 条过期归档备忘录；
+// more 表示这一批已满，可能还有剩余。时间按 julianday 比较，兼容秒级与毫秒级两种存储格式。
+func (s *SQLStore) deleteArchivedMemoBatch(ctx context.Context, cutoff time.Time) (int64, bool, error) {
+	var deleted int64
+	var audience memoAudience
+	more := false
+	err := withTx(ctx, s.db, func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(
+			ctx,
+			`SELECT id, creator_id FROM memos
+			WHERE state = ? AND julianday(update_time) < julianday(?)
+			ORDER BY id ASC
+			LIMIT ?`,
+			string(models.MemoStateArchived),
+			cutoff.UTC().Format(time.RFC3339Nano),
+			archivedMemoPurgeBatchSize,
+		)
+		if err != nil {
+			return err
+		}
+		type archivedMemo struct {
+			id        int64
+			creatorID int64
+		}
+		memos := make([]archivedMemo, 0)
+		for rows.Next() {
+			var memo archivedMemo
+			if err := rows.Scan(&memo.id, &memo.creatorID); err != nil {
+				rows.Close()
+				return err
+			}
+			memos = append(memos, memo)
+		}
+		if err := rows.Close(); err != nil {
+			return err
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		more = len(memos) == archivedMemoPurgeBatchSize
+
+		now := time.Now().UTC()
+		for _, memo := range memos {
+			if err := addMemoAudienceInTx(ctx, tx, &audience, memo.id); err != nil {
+				return err
+			}
+			if err := s.appendMemoDeleteEventInTx(ctx, tx, memo.id, memo.creatorID, now); err != nil {
+				return err
+			}
+			res, err := tx.ExecContext(ctx, `DELETE FROM memos WHERE id = ?`, memo.id)
+			if err != nil {
+				return err
+			}
+			affected, err := res.RowsAffected()
+			if err != nil {
+				return err
+			}
+			deleted += affected
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, false, err
+	}
+	s.notifyMemoChanged(audience)
+	return deleted, more, nil
+}
+
+func (s *SQLStore) appendMemoDeleteEventInTx(ctx context.Context, tx *sql.Tx, memoID int64, creatorID int64, now time.Time) error {
 	tagNames, err := listMemoTagNamesInTx(ctx, tx, memoID)
 	if err != nil {
 		return err
@@ -663,31 +1495,40 @@ func (s *SQLStore) DeleteMemo(ctx context.Context, memoID int64) error {
 		}
 		recipientIDs = append(recipientIDs, collaboratorID)
 	}
-	if err := appendMemoChangeEventInTx(
+	return s.appendMemoChangeEventInTx(
 		ctx,
 		tx,
 		memoID,
 		creatorID,
 		memoChangeEventTypeDelete,
 		recipientIDs,
-		time.Now().UTC(),
-	); err != nil {
-		return err
-	}
+		now,
+	)
+}
 
-	res, err := tx.ExecContext(ctx, `DELETE FROM memos WHERE id = ?`, memoID)
-	if err != nil {
-		return err
-	}
-	affected, err := res.RowsAffected()
-	if err != nil {
-		return err
-	}
-	if affected == 0 {
-		return sql.ErrNoRows
-	}
+// AnonymousViewerID 表示未登录的访问者，只能看到 PUBLIC 备忘录。
+const AnonymousViewerID int64 = 0
 
-	return tx.Commit()
+// visibleMemoPredicate 是 viewerID 可见备忘录（别名 m）的唯一定义：PROTECTED 仅对登录用户可见，匿名访问者只看到 PUBLIC；
+// 列表、搜索与按创建者查询都必须通过它过滤。
+func visibleMemoPredicate(viewerID int64) (string, []any) {
+	return memoVisibilityPredicate(viewerID, viewerID == AnonymousViewerID)
+}
+
+func memoVisibilityPredicate(viewerID int64, includePublicOnly bool) (string, []any) {
+	if includePublicOnly {
+		return `m.visibility = 'PUBLIC'`, nil
+	}
+	return `(
+			m.creator_id = ?
+			OR m.visibility IN ('PUBLIC', 'PROTECTED')
+			OR EXISTS (
+				SELECT 1
+				FROM memo_tags mt
+				JOIN tags t ON t.id = mt.tag_id
+				WHERE mt.memo_id = m.id AND t.name = ?
+			)
+		)`, []any{viewerID, fmt.Sprintf("collab/%d", viewerID)}
 }
 
 func (s *SQLStore) ListVisibleMemos(
@@ -703,20 +1544,10 @@ func (s *SQLStore) ListVisibleMemos(
 		return []models.Memo{}, nil
 	}
 
-	collaboratorTag := fmt.Sprintf("collab/%d", viewerID)
+	visiblePredicate, args := visibleMemoPredicate(viewerID)
 	query := `SELECT m.id, m.creator_id, m.content, m.visibility, m.state, m.pinned, m.create_time, m.update_time, m.display_time, m.latitude, m.longitude, m.has_link, m.has_task_list, m.has_code, m.has_incomplete_tasks
 		FROM memos m
-		WHERE (
-			m.creator_id = ?
-			OR m.visibility IN ('PUBLIC', 'PROTECTED')
-			OR EXISTS (
-				SELECT 1
-				FROM memo_tags mt
-				JOIN tags t ON t.id = mt.tag_id
-				WHERE mt.memo_id = m.id AND t.name = ?
-			)
-		)`
-	args := []any{viewerID, collaboratorTag}
+		WHERE ` + visiblePredicate
 
 	if state != nil {
 		query += ` AND m.state = ?`
@@ -724,11 +1555,16 @@ func (s *SQLStore) ListVisibleMemos(
 	}
 	if bounds != nil && bounds.UpdatedAfter != nil {
 		query += ` AND m.update_time > ?`
-		args = append(args, bounds.UpdatedAfter.UTC().Format(time.RFC3339Nano))
+		args = append(args, s.formatMemoTime(*bounds.UpdatedAfter))
 	}
 	if bounds != nil && bounds.UpdatedBeforeOrEqual != nil {
 		query += ` AND m.update_time <= ?`
-		args = append(args, bounds.UpdatedBeforeOrEqual.UTC().Format(time.RFC3339Nano))
+		args = append(args, s.formatMemoTime(*bounds.UpdatedBeforeOrEqual))
+	}
+	if bounds != nil && bounds.BeforeCreateTime != nil {
+		beforeCreateTime := s.formatMemoTime(*bounds.BeforeCreateTime)
+		query += ` AND (m.create_time < ? OR (m.create_time = ? AND m.id < ?))`
+		args = append(args, beforeCreateTime, beforeCreateTime, bounds.BeforeID)
 	}
 
 	if len(prefilter.CreatorIDs) > 0 {
@@ -755,6 +1591,21 @@ func (s *SQLStore) ListVisibleMemos(
 		}
 	}
 
+	// 只比较到秒：毫秒定长格式与 RFC3339Nano 的前 19 个字符一致，按字符串比较不会漏掉边界内的 memo。
+	// 与增量同步的 bounds 条件一样直接 AND 进查询，两者互不覆盖。
+	addTimeRangeConstraint := func(column string, r TimeRange) {
+		if r.From != nil {
+			query += fmt.Sprintf(` AND m.%s >= ?`, column)
+			args = append(args, r.From.UTC().Format(memoSecondPrefixLayout))
+		}
+		if r.To != nil {
+			query += fmt.Sprintf(` AND m.%s < ?`, column)
+			args = append(args, r.To.UTC().Truncate(time.Second).Add(time.Second).Format(memoSecondPrefixLayout))
+		}
+	}
+	addTimeRangeConstraint("create_time", prefilter.CreateTime)
+	addTimeRangeConstraint("update_time", prefilter.UpdateTime)
+
 	if prefilter.Pinned != nil {
 		query += ` AND m.pinned = ?`
 		args = append(args, boolToSQLiteInt(*prefilter.Pinned))
@@ -773,54 +1624,20 @@ func (s *SQLStore) ListVisibleMemos(
 	addPropertyConstraint("has_incomplete_tasks", prefilter.HasIncompleteTasks)
 
 	for _, group := range prefilter.TagGroups {
-		if len(group.Options) == 0 {
-			continue
-		}
-		query += ` AND EXISTS (
-			SELECT 1
-			FROM memo_tags mt
-			JOIN tags t ON t.id = mt.tag_id
-			WHERE mt.memo_id = m.id AND `
-		groupClauses := make([]string, 0, len(group.Options))
-		for _, option := range group.Options {
-			switch option.Kind {
-			case TagMatchExact:
-				groupClauses = append(groupClauses, `t.name = ?`)
-				args = append(args, option.Value)
-			case TagMatchPrefix:
-				groupClauses = append(groupClauses, `t.name LIKE ?`)
-				args = append(args, option.Value+"%")
-			}
+		if clause, clauseArgs := tagGroupExistsClause(group); clause != "" {
+			query += ` AND ` + clause
+			args = append(args, clauseArgs...)
 		}
-		if len(groupClauses) == 0 {
-			continue
-		}
-		query += strings.Join(groupClauses, " OR ") + `)`
 	}
 	for _, group := range prefilter.ExcludeTagGroups {
-		if len(group.Options) == 0 {
-			continue
+		if clause, clauseArgs := tagGroupExistsClause(group); clause != "" {
+			query += ` AND NOT ` + clause
+			args = append(args, clauseArgs...)
 		}
-		query += ` AND NOT EXISTS (
-			SELECT 1
-			FROM memo_tags mt
-			JOIN tags t ON t.id = mt.tag_id
-			WHERE mt.memo_id = m.id AND `
-		groupClauses := make([]string, 0, len(group.Options))
-		for _, option := range group.Options {
-			switch option.Kind {
-			case TagMatchExact:
-				groupClauses = append(groupClauses, `t.name = ?`)
-				args = append(args, option.Value)
-			case TagMatchPrefix:
-				groupClauses = append(groupClauses, `t.name LIKE ?`)
-				args = append(args, option.Value+"%")
-			}
-		}
-		if len(groupClauses) == 0 {
-			continue
-		}
-		query += strings.Join(groupClauses, " OR ") + `)`
+	}
+	for _, match := range prefilter.ContentMatches {
+		query += ` AND m.id IN (SELECT rowid FROM memos_fts WHERE memos_fts MATCH ?)`
+		args = append(args, match)
 	}
 
 	if bounds != nil && (bounds.UpdatedAfter != nil || bounds.UpdatedBeforeOrEqual != nil) {
@@ -828,56 +1645,148 @@ func (s *SQLStore) ListVisibleMemos(
 	} else {
 		query += ` ORDER BY m.create_time DESC, m.id DESC`
 	}
-	if limit > 0 {
-		query += ` LIMIT ? OFFSET ?`
-		args = append(args, limit, offset)
+	if limit > 0 {
+		query += ` LIMIT ? OFFSET ?`
+		args = append(args, limit, offset)
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	memos := make([]models.Memo, 0)
+	for rows.Next() {
+		memo, err := scanMemo(rows)
+		if err != nil {
+			return nil, err
+		}
+		memos = append(memos, memo)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return memos, nil
+}
+
+// tagGroupExistsClause 生成“memo 存在任一匹配标签”的 EXISTS 子查询；
+// 多个选项必须整体加括号，否则 OR 会越过 mt.memo_id = m.id 的关联条件。
+func tagGroupExistsClause(group TagMatchGroup) (string, []any) {
+	optionClauses := make([]string, 0, len(group.Options))
+	args := make([]any, 0, len(group.Options))
+	for _, option := range group.Options {
+		switch option.Kind {
+		case TagMatchExact:
+			optionClauses = append(optionClauses, `t.name = ?`)
+			args = append(args, option.Value)
+		case TagMatchPrefix:
+			optionClauses = append(optionClauses, `t.name LIKE ?`)
+			args = append(args, option.Value+"%")
+		}
+	}
+	if len(optionClauses) == 0 {
+		return "", nil
 	}
+	return `EXISTS (
+			SELECT 1
+			FROM memo_tags mt
+			JOIN tags t ON t.id = mt.tag_id
+			WHERE mt.memo_id = m.id AND (` + strings.Join(optionClauses, " OR ") + `))`, args
+}
+
+func (s *SQLStore) PinMemoForTag(ctx context.Context, memoID int64, tag string) error {
+	_, err := s.db.ExecContext(
+		ctx,
+		`INSERT OR IGNORE INTO memo_tag_pins (memo_id, tag, create_time) VALUES (?, ?, ?)`,
+		memoID,
+		tag,
+		time.Now().UTC().Format(time.RFC3339Nano),
+	)
+	return err
+}
+
+func (s *SQLStore) UnpinMemoForTag(ctx context.Context, memoID int64, tag string) error {
+	_, err := s.db.ExecContext(
+		ctx,
+		`DELETE FROM memo_tag_pins WHERE memo_id = ? AND tag = ?`,
+		memoID,
+		tag,
+	)
+	return err
+}
 
+// ListVisibleMemoIDsPinnedForTag 返回在该标签下置顶、且 viewerID 在列表中能看到的备忘录 ID（可按 state 过滤），
+// 只关联当前列表可能出现的备忘录，不会加载其他用户不可见的置顶记录。
+func (s *SQLStore) ListVisibleMemoIDsPinnedForTag(ctx context.Context, viewerID int64, state *models.MemoState, tag string) (map[int64]struct{}, error) {
+	visiblePredicate, visibleArgs := visibleMemoPredicate(viewerID)
+	query := `SELECT p.memo_id
+		FROM memo_tag_pins p
+		JOIN memos m ON m.id = p.memo_id
+		WHERE p.tag = ? AND ` + visiblePredicate
+	args := append([]any{tag}, visibleArgs...)
+	if state != nil {
+		query += ` AND m.state = ?`
+		args = append(args, *state)
+	}
 	rows, err := s.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	memos := make([]models.Memo, 0)
+	memoIDs := make(map[int64]struct{})
 	for rows.Next() {
-		memo, err := scanMemo(rows)
-		if err != nil {
+		var memoID int64
+		if err := rows.Scan(&memoID); err != nil {
 			return nil, err
 		}
-		memos = append(memos, memo)
+		memoIDs[memoID] = struct{}{}
 	}
 	if err := rows.Err(); err != nil {
 		return nil, err
 	}
-	if err := s.hydrateMemoTags(ctx, memos); err != nil {
-		return nil, err
-	}
-	return memos, nil
+	return memoIDs, nil
 }
 
 func (s *SQLStore) ListDeletedVisibleMemoNames(
 	ctx context.Context,
 	viewerID int64,
+	state *models.MemoState,
 	deletedAfter time.Time,
 	deletedBeforeOrEqual time.Time,
 	limit int,
 ) ([]string, error) {
+	eventTypes := []any{memoChangeEventTypeDelete, memoChangeEventTypeVisibilityRevoked}
+	if state != nil {
+		switch *state {
+		case models.MemoStateNormal:
+			eventTypes = append(eventTypes, memoChangeEventTypeArchive)
+		case models.MemoStateArchived:
+			eventTypes = append(eventTypes, memoChangeEventTypeRestore)
+		}
+	}
+	placeholders := strings.TrimRight(strings.Repeat("?,", len(eventTypes)), ",")
 	query := `SELECT DISTINCT mce.memo_name
 		FROM memo_change_events mce
-		JOIN memo_change_event_recipients mcer ON mcer.event_id = mce.id
 		WHERE mce.event_time > ?
 			AND mce.event_time <= ?
-			AND mcer.user_id = ?
-			AND mce.event_type IN (?, ?)
+			AND (
+				mce.broadcast = 1
+				OR EXISTS (
+					SELECT 1
+					FROM memo_change_event_recipients mcer
+					WHERE mcer.event_id = mce.id AND mcer.user_id = ?
+				)
+			)
+			AND mce.event_type IN (` + placeholders + `)
 		ORDER BY mce.event_time ASC, mce.id ASC`
 	args := []any{
-		deletedAfter.UTC().Format(time.RFC3339Nano),
-		deletedBeforeOrEqual.UTC().Format(time.RFC3339Nano),
+		s.formatMemoTime(deletedAfter),
+		s.formatMemoTime(deletedBeforeOrEqual),
 		viewerID,
-		memoChangeEventTypeDelete,
-		memoChangeEventTypeVisibilityRevoked,
 	}
+	args = append(args, eventTypes...)
 	if limit > 0 {
 		query += ` LIMIT ?`
 		args = append(args, limit)
@@ -903,25 +1812,127 @@ func (s *SQLStore) ListDeletedVisibleMemoNames(
 	return result, nil
 }
 
+func (s *SQLStore) ListMemoChangeEventsByMemoID(ctx context.Context, memoID int64, creatorID int64) ([]models.MemoChangeEvent, error) {
+	rows, err := s.db.QueryContext(
+		ctx,
+		`SELECT mce.id, mce.memo_id, mce.memo_name, mce.creator_id, mce.event_type, mce.event_time, mce.broadcast, COALESCE(GROUP_CONCAT(mcer.user_id), '')
+		FROM memo_change_events mce
+		LEFT JOIN memo_change_event_recipients mcer ON mcer.event_id = mce.id
+		WHERE mce.memo_id = ? AND mce.creator_id = ?
+		GROUP BY mce.id
+		ORDER BY mce.event_time DESC, mce.id DESC`,
+		memoID,
+		creatorID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]models.MemoChangeEvent, 0)
+	for rows.Next() {
+		var event models.MemoChangeEvent
+		var eventTime string
+		var broadcast int
+		var recipientsRaw string
+		if err := rows.Scan(
+			&event.ID,
+			&event.MemoID,
+			&event.MemoName,
+			&event.CreatorID,
+			&event.EventType,
+			&eventTime,
+			&broadcast,
+			&recipientsRaw,
+		); err != nil {
+			return nil, err
+		}
+		event.Broadcast = broadcast == 1
+		event.EventTime, err = parseTime(eventTime)
+		if err != nil {
+			return nil, err
+		}
+		event.RecipientIDs = make([]int64, 0)
+		for _, item := range strings.Split(recipientsRaw, ",") {
+			if item == "" {
+				continue
+			}
+			recipientID, err := strconv.ParseInt(item, 10, 64)
+			if err != nil {
+				return nil, err
+			}
+			event.RecipientIDs = append(event.RecipientIDs, recipientID)
+		}
+		result = append(result, event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// CountSharedMemosUpdatedAfter 统计他人通过 collab/<userID> 标签共享给用户、且在 after 之后更新的正常状态备忘录；
+// after 为零值时统计全部共享备忘录。
+func (s *SQLStore) CountSharedMemosUpdatedAfter(ctx context.Context, userID int64, after time.Time) (int64, error) {
+	query := `SELECT COUNT(1)
+		FROM memo_tags mt
+		JOIN tags t ON t.id = mt.tag_id
+		JOIN memos m ON m.id = mt.memo_id
+		WHERE t.name = ? AND m.creator_id != ? AND m.state = ?`
+	args := []any{fmt.Sprintf("collab/%d", userID), userID, string(models.MemoStateNormal)}
+	if !after.IsZero() {
+		query += ` AND m.update_time > ?`
+		args = append(args, s.formatMemoTime(after))
+	}
+	var count int64
+	if err := s.db.QueryRowContext(ctx, query, args...).Scan(&count); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
 func (s *SQLStore) ListVisibleMemosByCreator(ctx context.Context, creatorID int64, viewerID int64, state models.MemoState) ([]models.Memo, error) {
+	visiblePredicate, visibleArgs := visibleMemoPredicate(viewerID)
+	query := `SELECT m.id, m.creator_id, m.content, m.visibility, m.state, m.pinned, m.create_time, m.update_time, m.display_time, m.latitude, m.longitude, m.has_link, m.has_task_list, m.has_code, m.has_incomplete_tasks
+		FROM memos m
+		WHERE m.creator_id = ? AND m.state = ? AND ` + visiblePredicate + `
+		ORDER BY m.create_time DESC, m.id DESC`
+	args := append([]any{creatorID, state}, visibleArgs...)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]models.Memo, 0)
+	for rows.Next() {
+		memo, err := scanMemo(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, memo)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// ListPublicMemosByCreator 按 create_time DESC, id DESC 返回创建者处于正常状态的 PUBLIC 备忘录，供未登录访问者浏览；
+// bounds 仅使用 BeforeCreateTime/BeforeID 作为分页游标。
+func (s *SQLStore) ListPublicMemosByCreator(ctx context.Context, creatorID int64, limit int, bounds *MemoQueryBounds) ([]models.Memo, error) {
 	query := `SELECT id, creator_id, content, visibility, state, pinned, create_time, update_time, display_time, latitude, longitude, has_link, has_task_list, has_code, has_incomplete_tasks
 		FROM memos
-		WHERE creator_id = ? AND state = ?`
-	args := []any{creatorID, state}
-	if creatorID != viewerID {
-		collaboratorTag := fmt.Sprintf("collab/%d", viewerID)
-		query += ` AND (
-			visibility IN ('PUBLIC', 'PROTECTED')
-			OR EXISTS (
-				SELECT 1
-				FROM memo_tags mt
-				JOIN tags t ON t.id = mt.tag_id
-				WHERE mt.memo_id = memos.id AND t.name = ?
-			)
-		)`
-		args = append(args, collaboratorTag)
+		WHERE creator_id = ? AND state = ? AND visibility = ?`
+	args := []any{creatorID, models.MemoStateNormal, models.VisibilityPublic}
+	if bounds != nil && bounds.BeforeCreateTime != nil {
+		beforeCreateTime := s.formatMemoTime(*bounds.BeforeCreateTime)
+		query += ` AND (create_time < ? OR (create_time = ? AND id < ?))`
+		args = append(args, beforeCreateTime, beforeCreateTime, bounds.BeforeID)
 	}
-	query += ` ORDER BY create_time DESC, id DESC`
+	query += ` ORDER BY create_time DESC, id DESC LIMIT ?`
+	args = append(args, limit)
 
 	rows, err := s.db.QueryContext(ctx, query, args...)
 	if err != nil {
@@ -940,7 +1951,74 @@ func (s *SQLStore) ListVisibleMemosByCreator(ctx context.Context, creatorID int6
 	if err := rows.Err(); err != nil {
 		return nil, err
 	}
-	if err := s.hydrateMemoTags(ctx, result); err != nil {
+	return result, nil
+}
+
+// StreamMemosByCreator 按 id 升序以 batchSize 为一批遍历创建者的备忘录并逐批交给 fn，fn 返回错误即停止。
+// 每批查询完成后才回调，不会在回调期间占用数据库连接，回调里可以继续查询。
+func (s *SQLStore) StreamMemosByCreator(
+	ctx context.Context,
+	creatorID int64,
+	state *models.MemoState,
+	batchSize int,
+	fn func([]models.Memo) error,
+) error {
+	if batchSize <= 0 {
+		batchSize = 200
+	}
+	var afterID int64
+	for {
+		memos, err := s.ListMemosByCreatorAfterID(ctx, creatorID, state, afterID, batchSize)
+		if err != nil {
+			return err
+		}
+		if len(memos) == 0 {
+			return nil
+		}
+		if err := fn(memos); err != nil {
+			return err
+		}
+		if len(memos) < batchSize {
+			return nil
+		}
+		afterID = memos[len(memos)-1].ID
+	}
+}
+
+// ListMemosByCreatorAfterID 按 id 升序分批返回创建者本人的备忘录，用于导出等全量遍历。
+func (s *SQLStore) ListMemosByCreatorAfterID(
+	ctx context.Context,
+	creatorID int64,
+	state *models.MemoState,
+	afterID int64,
+	limit int,
+) ([]models.Memo, error) {
+	query := `SELECT id, creator_id, content, visibility, state, pinned, create_time, update_time, display_time, latitude, longitude, has_link, has_task_list, has_code, has_incomplete_tasks
+		FROM memos
+		WHERE creator_id = ? AND id > ?`
+	args := []any{creatorID, afterID}
+	if state != nil {
+		query += ` AND state = ?`
+		args = append(args, string(*state))
+	}
+	query += ` ORDER BY id ASC LIMIT ?`
+	args = append(args, limit)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]models.Memo, 0, limit)
+	for rows.Next() {
+		memo, err := scanMemo(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, memo)
+	}
+	if err := rows.Err(); err != nil {
 		return nil, err
 	}
 	return result, nil
@@ -969,7 +2047,7 @@ func (s *SQLStore) ListAllMemos(ctx context.Context) ([]models.Memo, error) {
 	if err := rows.Err(); err != nil {
 		return nil, err
 	}
-	if err := s.hydrateMemoTags(ctx, result); err != nil {
+	if err := s.LoadMemoTags(ctx, result); err != nil {
 		return nil, err
 	}
 	return result, nil
@@ -1059,6 +2137,21 @@ func (s *SQLStore) UpdateAttachmentThumbnail(
 	return err
 }
 
+func (s *SQLStore) UpdateAttachmentFilename(ctx context.Context, attachmentID int64, filename string) error {
+	res, err := s.db.ExecContext(ctx, `UPDATE attachments SET filename = ? WHERE id = ?`, filename, attachmentID)
+	if err != nil {
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
 func (s *SQLStore) CreateAttachmentUploadSession(ctx context.Context, session models.AttachmentUploadSession) (models.AttachmentUploadSession, error) {
 	if session.ID == "" {
 		return models.AttachmentUploadSession{}, fmt.Errorf("upload session id is required")
@@ -1086,10 +2179,11 @@ func (s *SQLStore) CreateAttachmentUploadSession(ctx context.Context, session mo
 			thumbnail_filename,
 			thumbnail_type,
 			thumbnail_temp_path,
+			expected_sha256,
 			received_size,
 			create_time,
 			update_time
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
 		session.ID,
 		session.CreatorID,
 		session.Filename,
@@ -1100,6 +2194,7 @@ func (s *SQLStore) CreateAttachmentUploadSession(ctx context.Context, session mo
 		session.ThumbnailFilename,
 		session.ThumbnailType,
 		session.ThumbnailTempPath,
+		session.ExpectedSHA256,
 		session.ReceivedSize,
 		createTime.Format(time.RFC3339Nano),
 		updateTime.Format(time.RFC3339Nano),
@@ -1128,6 +2223,7 @@ func (s *SQLStore) GetAttachmentUploadSessionByID(ctx context.Context, id string
 			thumbnail_filename,
 			thumbnail_type,
 			thumbnail_temp_path,
+			expected_sha256,
 			received_size,
 			create_time,
 			update_time
@@ -1145,6 +2241,7 @@ func (s *SQLStore) GetAttachmentUploadSessionByID(ctx context.Context, id string
 		&session.ThumbnailFilename,
 		&session.ThumbnailType,
 		&session.ThumbnailTempPath,
+		&session.ExpectedSHA256,
 		&session.ReceivedSize,
 		&createTime,
 		&updateTime,
@@ -1207,6 +2304,7 @@ func (s *SQLStore) ListAttachmentUploadSessionsUpdatedBefore(ctx context.Context
 			thumbnail_filename,
 			thumbnail_type,
 			thumbnail_temp_path,
+			expected_sha256,
 			received_size,
 			create_time,
 			update_time
@@ -1239,6 +2337,7 @@ func (s *SQLStore) ListAttachmentUploadSessionsUpdatedBefore(ctx context.Context
 			&session.ThumbnailFilename,
 			&session.ThumbnailType,
 			&session.ThumbnailTempPath,
+			&session.ExpectedSHA256,
 			&session.ReceivedSize,
 			&createTime,
 			&updateTime,
@@ -1271,12 +2370,29 @@ func (s *SQLStore) DeleteAttachmentUploadSessionByID(ctx context.Context, id str
 	return err
 }
 
+// SumAttachmentSizesByCreator 统计用户附件占用的存储字节数；去重后共用同一存储对象的附件只计一次。
+func (s *SQLStore) SumAttachmentSizesByCreator(ctx context.Context, creatorID int64) (int64, error) {
+	var total int64
+	err := s.db.QueryRowContext(
+		ctx,
+		`SELECT COALESCE(SUM(size), 0)
+		FROM (
+			SELECT MAX(size) AS size
+			FROM attachments
+			WHERE creator_id = ? AND storage_key != ''
+			GROUP BY storage_type, storage_key
+		)`,
+		creatorID,
+	).Scan(&total)
+	return total, err
+}
+
 func (s *SQLStore) FindAttachmentByContentHash(ctx context.Context, creatorID int64, contentHash string) (models.Attachment, bool, error) {
 	var attachment models.Attachment
 	var createTime string
 	err := s.db.QueryRowContext(
 		ctx,
-		`SELECT id, creator_id, filename, external_link, type, size, storage_type, storage_key, thumbnail_filename, thumbnail_type, thumbnail_size, thumbnail_storage_type, thumbnail_storage_key, create_time
+		`SELECT id, creator_id, filename, external_link, type, size, content_hash, storage_type, storage_key, thumbnail_filename, thumbnail_type, thumbnail_size, thumbnail_storage_type, thumbnail_storage_key, create_time
 		FROM attachments
 		WHERE creator_id = ? AND content_hash = ?
 		ORDER BY id DESC
@@ -1290,6 +2406,7 @@ func (s *SQLStore) FindAttachmentByContentHash(ctx context.Context, creatorID in
 		&attachment.ExternalLink,
 		&attachment.Type,
 		&attachment.Size,
+		&attachment.ContentHash,
 		&attachment.StorageType,
 		&attachment.StorageKey,
 		&attachment.ThumbnailFilename,
@@ -1318,7 +2435,7 @@ func (s *SQLStore) ListAttachmentCandidates(ctx context.Context, creatorID int64
 	}
 	rows, err := s.db.QueryContext(
 		ctx,
-		`SELECT id, creator_id, filename, external_link, type, size, storage_type, storage_key, thumbnail_filename, thumbnail_type, thumbnail_size, thumbnail_storage_type, thumbnail_storage_key, create_time
+		`SELECT id, creator_id, filename, external_link, type, size, content_hash, storage_type, storage_key, thumbnail_filename, thumbnail_type, thumbnail_size, thumbnail_storage_type, thumbnail_storage_key, create_time
 		FROM attachments
 		WHERE creator_id = ? AND filename = ? AND type = ? AND size = ?
 		ORDER BY id DESC
@@ -1350,7 +2467,7 @@ func (s *SQLStore) GetAttachmentByID(ctx context.Context, id int64) (models.Atta
 	var createTime string
 	err := s.db.QueryRowContext(
 		ctx,
-		`SELECT id, creator_id, filename, external_link, type, size, storage_type, storage_key, thumbnail_filename, thumbnail_type, thumbnail_size, thumbnail_storage_type, thumbnail_storage_key, create_time
+		`SELECT id, creator_id, filename, external_link, type, size, content_hash, storage_type, storage_key, thumbnail_filename, thumbnail_type, thumbnail_size, thumbnail_storage_type, thumbnail_storage_key, create_time
 		FROM attachments
 		WHERE id = ?`,
 		id,
@@ -1361,6 +2478,7 @@ func (s *SQLStore) GetAttachmentByID(ctx context.Context, id int64) (models.Atta
 		&attachment.ExternalLink,
 		&attachment.Type,
 		&attachment.Size,
+		&attachment.ContentHash,
 		&attachment.StorageType,
 		&attachment.StorageKey,
 		&attachment.ThumbnailFilename,
@@ -1383,7 +2501,7 @@ func (s *SQLStore) GetAttachmentByID(ctx context.Context, id int64) (models.Atta
 func (s *SQLStore) ListAttachmentsByCreator(ctx context.Context, creatorID int64) ([]models.Attachment, error) {
 	rows, err := s.db.QueryContext(
 		ctx,
-		`SELECT id, creator_id, filename, external_link, type, size, storage_type, storage_key, thumbnail_filename, thumbnail_type, thumbnail_size, thumbnail_storage_type, thumbnail_storage_key, create_time
+		`SELECT id, creator_id, filename, external_link, type, size, content_hash, storage_type, storage_key, thumbnail_filename, thumbnail_type, thumbnail_size, thumbnail_storage_type, thumbnail_storage_key, create_time
 		FROM attachments
 		WHERE creator_id = ?
 		ORDER BY id DESC`,
@@ -1405,6 +2523,50 @@ func (s *SQLStore) ListAttachmentsByCreator(ctx context.Context, creatorID int64
 	return result, rows.Err()
 }
 
+// ListAttachmentsAfterID 按 id 升序分页返回全部附件，afterID 为上一页最后一条的 id。
+func (s *SQLStore) ListAttachmentsAfterID(ctx context.Context, afterID int64, limit int) ([]models.Attachment, error) {
+	rows, err := s.db.QueryContext(
+		ctx,
+		`SELECT id, creator_id, filename, external_link, type, size, content_hash, storage_type, storage_key, thumbnail_filename, thumbnail_type, thumbnail_size, thumbnail_storage_type, thumbnail_storage_key, create_time
+		FROM attachments
+		WHERE id > ?
+		ORDER BY id ASC
+		LIMIT ?`,
+		afterID,
+		limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]models.Attachment, 0, limit)
+	for rows.Next() {
+		attachment, err := scanAttachment(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, attachment)
+	}
+	return result, rows.Err()
+}
+
+// UpdateAttachmentStorage 改写附件原文件与缩略图所在的存储类型和键，用于在存储后端之间迁移。
+func (s *SQLStore) UpdateAttachmentStorage(ctx context.Context, attachmentID int64, storageType string, storageKey string, thumbnailStorageType string, thumbnailStorageKey string) error {
+	_, err := s.db.ExecContext(
+		ctx,
+		`UPDATE attachments
+		SET storage_type = ?, storage_key = ?, thumbnail_storage_type = ?, thumbnail_storage_key = ?
+		WHERE id = ?`,
+		storageType,
+		storageKey,
+		thumbnailStorageType,
+		thumbnailStorageKey,
+		attachmentID,
+	)
+	return err
+}
+
 func (s *SQLStore) DeleteAttachment(ctx context.Context, attachmentID int64) error {
 	_, err := s.db.ExecContext(ctx, `DELETE FROM attachments WHERE id = ?`, attachmentID)
 	return err
@@ -1418,6 +2580,148 @@ func (s *SQLStore) CountAttachmentsByStorageKey(ctx context.Context, storageKey
 	return count, nil
 }
 
+// ListMemoIDsByAttachmentID 返回引用该附件、且 managerID 作为创建者或协作者可以管理的 memo id，按 id 升序。
+func (s *SQLStore) ListMemoIDsByAttachmentID(ctx context.Context, attachmentID int64, managerID int64) ([]int64, error) {
+	rows, err := s.db.QueryContext(
+		ctx,
+		`SELECT m.id
+		FROM memo_attachments ma
+		JOIN memos m ON m.id = ma.memo_id
+		WHERE ma.attachment_id = ?
+			AND (
+				m.creator_id = ?
+				OR EXISTS (
+					SELECT 1
+					FROM memo_tags mt
+					JOIN tags t ON t.id = mt.tag_id
+					WHERE mt.memo_id = m.id AND t.name = ?
+				)
+			)
+		ORDER BY m.id ASC`,
+		attachmentID,
+		managerID,
+		fmt.Sprintf("collab/%d", managerID),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	memoIDs := make([]int64, 0)
+	for rows.Next() {
+		var memoID int64
+		if err := rows.Scan(&memoID); err != nil {
+			return nil, err
+		}
+		memoIDs = append(memoIDs, memoID)
+	}
+	return memoIDs, rows.Err()
+}
+
+// CanUserAccessAttachment 判断附件是否被 userID 可见的 memo 引用（创建者、协作者或公开/登录可见）。
+// 未被任何 memo 引用的附件返回 false，归属判断由调用方负责。
+func (s *SQLStore) CanUserAccessAttachment(ctx context.Context, attachmentID int64, userID int64) (bool, error) {
+	visiblePredicate, visibleArgs := visibleMemoPredicate(userID)
+	var exists int
+	if err := s.db.QueryRowContext(
+		ctx,
+		`SELECT EXISTS (
+			SELECT 1
+			FROM memo_attachments ma
+			JOIN memos m ON m.id = ma.memo_id
+			WHERE ma.attachment_id = ? AND `+visiblePredicate+`
+		)`,
+		append([]any{attachmentID}, visibleArgs...)...,
+	).Scan(&exists); err != nil {
+		return false, err
+	}
+	return exists == 1, nil
+}
+
+type AttachmentDedupGroup struct {
+	ContentHash string
+	StorageKey  string
+	References  int64
+	Size        int64
+	BytesSaved  int64
+}
+
+type AttachmentDedupSummary struct {
+	Groups     int64
+	References int64
+	BytesSaved int64
+}
+
+// ListAttachmentDedupGroups 按共享同一存储对象的附件行分组，只返回被引用多于一次的分组，
+// 按节省字节数从大到小排序。
+func (s *SQLStore) ListAttachmentDedupGroups(ctx context.Context, limit int, offset int) ([]AttachmentDedupGroup, int, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+	if limit > 200 {
+		limit = 200
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	rows, err := s.db.QueryContext(
+		ctx,
+		`SELECT content_hash, storage_key, COUNT(1) AS refs, MAX(size) AS size
+		FROM attachments
+		WHERE storage_key <> ''
+		GROUP BY storage_key, content_hash
+		HAVING COUNT(1) > 1
+		ORDER BY MAX(size) * (COUNT(1) - 1) DESC, storage_key ASC
+		LIMIT ? OFFSET ?`,
+		limit+1,
+		offset,
+	)
+	if err != nil {
+		return nil, -1, err
+	}
+	defer rows.Close()
+
+	result := make([]AttachmentDedupGroup, 0, limit+1)
+	for rows.Next() {
+		var group AttachmentDedupGroup
+		if err := rows.Scan(&group.ContentHash, &group.StorageKey, &group.References, &group.Size); err != nil {
+			return nil, -1, err
+		}
+		group.BytesSaved = group.Size * (group.References - 1)
+		result = append(result, group)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, -1, err
+	}
+
+	nextOffset := -1
+	if len(result) > limit {
+		result = result[:limit]
+		nextOffset = offset + limit
+	}
+	return result, nextOffset, nil
+}
+
+func (s *SQLStore) SummarizeAttachmentDedup(ctx context.Context) (AttachmentDedupSummary, error) {
+	var summary AttachmentDedupSummary
+	err := s.db.QueryRowContext(
+		ctx,
+		`SELECT COUNT(1), COALESCE(SUM(refs), 0), COALESCE(SUM(size * (refs - 1)), 0)
+		FROM (
+			SELECT COUNT(1) AS refs, MAX(size) AS size
+			FROM attachments
+			WHERE storage_key <> ''
+			GROUP BY storage_key, content_hash
+			HAVING COUNT(1) > 1
+		)`,
+	).Scan(&summary.Groups, &summary.References, &summary.BytesSaved)
+	if err != nil {
+		return AttachmentDedupSummary{}, err
+	}
+	return summary, nil
+}
+
 func (s *SQLStore) SetMemoAttachments(ctx context.Context, memoID int64, attachmentIDs []int64) error {
 	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
@@ -1457,7 +2761,7 @@ func setMemoTagsInTx(ctx context.Context, tx *sql.Tx, creatorID int64, memoID in
 	if len(normalized) == 0 {
 		_, err := tx.ExecContext(
 			ctx,
-			`DELETE FROM tags WHERE creator_id = ? AND id NOT IN (SELECT DISTINCT tag_id FROM memo_tags)`,
+			`DELETE FROM tags WHERE creator_id = ? AND explicit = 0 AND id NOT IN (SELECT DISTINCT tag_id FROM memo_tags)`,
 			creatorID,
 		)
 		return err
@@ -1501,12 +2805,271 @@ func setMemoTagsInTx(ctx context.Context, tx *sql.Tx, creatorID int64, memoID in
 
 	_, err := tx.ExecContext(
 		ctx,
-		`DELETE FROM tags WHERE creator_id = ? AND id NOT IN (SELECT DISTINCT tag_id FROM memo_tags)`,
+		`DELETE FROM tags WHERE creator_id = ? AND explicit = 0 AND id NOT IN (SELECT DISTINCT tag_id FROM memo_tags)`,
 		creatorID,
 	)
 	return err
 }
 
+// CreateExplicitTag 创建用户显式声明的标签；显式标签即使没有 memo 引用也不会被自动清理。
+// 标签已存在时只将其标记为显式，返回 created=false。
+func (s *SQLStore) CreateExplicitTag(ctx context.Context, creatorID int64, name string) (bool, error) {
+	now := time.Now().UTC().Format(time.RFC3339Nano)
+	res, err := s.db.ExecContext(
+		ctx,
+		`INSERT INTO tags (creator_id, name, explicit, create_time, update_time)
+		VALUES (?, ?, 1, ?, ?)
+		ON CONFLICT(creator_id, name) DO NOTHING`,
+		creatorID,
+		name,
+		now,
+		now,
+	)
+	if err != nil {
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if affected > 0 {
+		return true, nil
+	}
+	_, err = s.db.ExecContext(ctx, `UPDATE tags SET explicit = 1 WHERE creator_id = ? AND name = ?`, creatorID, name)
+	return false, err
+}
+
+type TagCount struct {
+	Name      string
+	MemoCount int64
+}
+
+// CountTagsByCreator 按标签聚合创建者正常状态 memo 的数量，不含 collab/ 协作标签；
+// 显式创建但暂未被引用的标签计数为 0。结果按名称排序。
+func (s *SQLStore) CountTagsByCreator(ctx context.Context, creatorID int64) ([]TagCount, error) {
+	rows, err := s.db.QueryContext(
+		ctx,
+		`SELECT t.name, COUNT(m.id)
+		FROM tags t
+		LEFT JOIN memo_tags mt ON mt.tag_id = t.id
+		LEFT JOIN memos m ON m.id = mt.memo_id AND m.state = ?
+		WHERE t.creator_id = ? AND substr(t.name, 1, 7) <> 'collab/'
+		GROUP BY t.id, t.name
+		ORDER BY t.name ASC`,
+		string(models.MemoStateNormal),
+		creatorID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make([]TagCount, 0)
+	for rows.Next() {
+		var count TagCount
+		if err := rows.Scan(&count.Name, &count.MemoCount); err != nil {
+			return nil, err
+		}
+		counts = append(counts, count)
+	}
+	return counts, rows.Err()
+}
+
+// RenameTag 将创建者的 oldName 标签改名为 newName，并刷新受影响 memo 的更新时间。
+// newName 已存在时合并：memo_tags 改指向目标标签后删除旧标签，显式标记取两者之并。
+// oldName 不存在时返回 sql.ErrNoRows。
+func (s *SQLStore) RenameTag(ctx context.Context, creatorID int64, oldName string, newName string) error {
+	var audience memoAudience
+	err := withTx(ctx, s.db, func(tx *sql.Tx) error {
+		var oldID int64
+		var oldExplicit int
+		if err := tx.QueryRowContext(
+			ctx,
+			`SELECT id, explicit FROM tags WHERE creator_id = ? AND name = ?`,
+			creatorID,
+			oldName,
+		).Scan(&oldID, &oldExplicit); err != nil {
+			return err
+		}
+		if oldName == newName {
+			return nil
+		}
+		if err := addTaggedMemosAudienceInTx(ctx, tx, &audience, oldID); err != nil {
+			return err
+		}
+		// 改名为 collab/<id> 会让该用户成为这些备忘录的协作者
+		if collaboratorID, ok := collaboratorIDFromTag(newName); ok && !audience.empty() {
+			audience.addMemo(models.VisibilityPrivate, collaboratorID, nil)
+		}
+
+		now := time.Now()
+		if _, err := tx.ExecContext(
+			ctx,
+			`UPDATE memos SET update_time = ? WHERE id IN (SELECT memo_id FROM memo_tags WHERE tag_id = ?)`,
+			s.formatMemoTime(now),
+			oldID,
+		); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(
+			ctx,
+			`UPDATE OR IGNORE memo_tag_pins SET tag = ?
+			WHERE tag = ? AND memo_id IN (SELECT memo_id FROM memo_tags WHERE tag_id = ?)`,
+			newName,
+			oldName,
+			oldID,
+		); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(
+			ctx,
+			`DELETE FROM memo_tag_pins WHERE tag = ? AND memo_id IN (SELECT memo_id FROM memo_tags WHERE tag_id = ?)`,
+			oldName,
+			oldID,
+		); err != nil {
+			return err
+		}
+
+		var targetID int64
+		err := tx.QueryRowContext(
+			ctx,
+			`SELECT id FROM tags WHERE creator_id = ? AND name = ?`,
+			creatorID,
+			newName,
+		).Scan(&targetID)
+		if err == sql.ErrNoRows {
+			_, err = tx.ExecContext(
+				ctx,
+				`UPDATE tags SET name = ?, update_time = ? WHERE id = ?`,
+				newName,
+				now.UTC().Format(time.RFC3339Nano),
+				oldID,
+			)
+			return err
+		}
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.ExecContext(
+			ctx,
+			`INSERT OR IGNORE INTO memo_tags (memo_id, tag_id, create_time)
+			SELECT memo_id, ?, create_time FROM memo_tags WHERE tag_id = ?`,
+			targetID,
+			oldID,
+		); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM memo_tags WHERE tag_id = ?`, oldID); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM tags WHERE id = ?`, oldID); err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(
+			ctx,
+			`UPDATE tags SET explicit = MAX(explicit, ?), update_time = ? WHERE id = ?`,
+			oldExplicit,
+			now.UTC().Format(time.RFC3339Nano),
+			targetID,
+		)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	s.notifyMemoChanged(audience)
+	return nil
+}
+
+// DeleteTag 删除创建者的标签并从所有 memo 上移除，受影响 memo 的更新时间随之刷新、按该标签的置顶一并清理。
+// 标签不存在时返回 sql.ErrNoRows。
+func (s *SQLStore) DeleteTag(ctx context.Context, creatorID int64, name string) error {
+	var audience memoAudience
+	err := withTx(ctx, s.db, func(tx *sql.Tx) error {
+		var tagID int64
+		if err := tx.QueryRowContext(
+			ctx,
+			`SELECT id FROM tags WHERE creator_id = ? AND name = ?`,
+			creatorID,
+			name,
+		).Scan(&tagID); err != nil {
+			return err
+		}
+		if err := addTaggedMemosAudienceInTx(ctx, tx, &audience, tagID); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(
+			ctx,
+			`UPDATE memos SET update_time = ? WHERE id IN (SELECT memo_id FROM memo_tags WHERE tag_id = ?)`,
+			s.formatMemoTime(time.Now()),
+			tagID,
+		); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(
+			ctx,
+			`DELETE FROM memo_tag_pins WHERE tag = ? AND memo_id IN (SELECT memo_id FROM memo_tags WHERE tag_id = ?)`,
+			name,
+			tagID,
+		); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM memo_tags WHERE tag_id = ?`, tagID); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, `DELETE FROM tags WHERE id = ?`, tagID)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	s.notifyMemoChanged(audience)
+	return nil
+}
+
+// ListMissingTagNames 返回 names 中创建者尚未拥有的标签，保持输入顺序。
+func (s *SQLStore) ListMissingTagNames(ctx context.Context, creatorID int64, names []string) ([]string, error) {
+	names = normalizeTagNames(names)
+	if len(names) == 0 {
+		return nil, nil
+	}
+	placeholders := strings.TrimRight(strings.Repeat("?,", len(names)), ",")
+	args := make([]any, 0, len(names)+1)
+	args = append(args, creatorID)
+	for _, name := range names {
+		args = append(args, name)
+	}
+	rows, err := s.db.QueryContext(
+		ctx,
+		`SELECT name FROM tags WHERE creator_id = ? AND name IN (`+placeholders+`)`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	existing := make(map[string]struct{}, len(names))
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		existing[name] = struct{}{}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	missing := make([]string, 0)
+	for _, name := range names {
+		if _, ok := existing[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	return missing, nil
+}
+
 func listMemoTagNamesInTx(ctx context.Context, tx *sql.Tx, memoID int64) ([]string, error) {
 	rows, err := tx.QueryContext(
 		ctx,
@@ -1535,6 +3098,61 @@ func listMemoTagNamesInTx(ctx context.Context, tx *sql.Tx, memoID int64) ([]stri
 	return tags, nil
 }
 
+// addMemoAudienceInTx 按事务内备忘录的当前可见性与协作者标签扩充唤醒范围。
+func addMemoAudienceInTx(ctx context.Context, tx *sql.Tx, audience *memoAudience, memoID int64) error {
+	var creatorID int64
+	var visibility string
+	if err := tx.QueryRowContext(
+		ctx,
+		`SELECT creator_id, visibility FROM memos WHERE id = ?`,
+		memoID,
+	).Scan(&creatorID, &visibility); err != nil {
+		return err
+	}
+	if models.Visibility(visibility) != models.VisibilityPrivate {
+		audience.addMemo(models.Visibility(visibility), creatorID, nil)
+		return nil
+	}
+	tagNames, err := listMemoTagNamesInTx(ctx, tx, memoID)
+	if err != nil {
+		return err
+	}
+	audience.addMemo(models.Visibility(visibility), creatorID, collaboratorIDSetFromTags(tagNames))
+	return nil
+}
+
+// addTaggedMemosAudienceInTx 把带有 tagID 标签的所有备忘录的可见者加入唤醒范围。
+func addTaggedMemosAudienceInTx(ctx context.Context, tx *sql.Tx, audience *memoAudience, tagID int64) error {
+	rows, err := tx.QueryContext(ctx, `SELECT memo_id FROM memo_tags WHERE tag_id = ? ORDER BY memo_id ASC`, tagID)
+	if err != nil {
+		return err
+	}
+	memoIDs := make([]int64, 0)
+	for rows.Next() {
+		var memoID int64
+		if err := rows.Scan(&memoID); err != nil {
+			rows.Close()
+			return err
+		}
+		memoIDs = append(memoIDs, memoID)
+	}
+	if err := rows.Close(); err != nil {
+		return err
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for _, memoID := range memoIDs {
+		if audience.broadcast {
+			return nil
+		}
+		if err := addMemoAudienceInTx(ctx, tx, audience, memoID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func collaboratorIDSetFromTags(tags []string) map[int64]struct{} {
 	result := make(map[int64]struct{})
 	for _, tag := range tags {
@@ -1547,7 +3165,7 @@ func collaboratorIDSetFromTags(tags []string) map[int64]struct{} {
 	return result
 }
 
-func appendMemoChangeEventInTx(
+func (s *SQLStore) appendMemoChangeEventInTx(
 	ctx context.Context,
 	tx *sql.Tx,
 	memoID int64,
@@ -1576,21 +3194,7 @@ func appendMemoChangeEventInTx(
 		return nil
 	}
 
-	memoName := "memos/" + models.Int64ToString(memoID)
-	res, err := tx.ExecContext(
-		ctx,
-		`INSERT INTO memo_change_events (memo_id, memo_name, creator_id, event_type, event_time)
-		VALUES (?, ?, ?, ?, ?)`,
-		memoID,
-		memoName,
-		creatorID,
-		eventType,
-		eventTime.UTC().Format(time.RFC3339Nano),
-	)
-	if err != nil {
-		return err
-	}
-	eventID, err := res.LastInsertId()
+	eventID, err := s.insertMemoChangeEventInTx(ctx, tx, memoID, creatorID, eventType, eventTime, false)
 	if err != nil {
 		return err
 	}
@@ -1608,6 +3212,46 @@ func appendMemoChangeEventInTx(
 	return nil
 }
 
+// appendMemoBroadcastEventInTx 写入面向所有用户的事件，读取时对任意用户都可见，避免按实例用户数扇出接收者记录。
+func (s *SQLStore) appendMemoBroadcastEventInTx(
+	ctx context.Context,
+	tx *sql.Tx,
+	memoID int64,
+	creatorID int64,
+	eventType string,
+	eventTime time.Time,
+) error {
+	_, err := s.insertMemoChangeEventInTx(ctx, tx, memoID, creatorID, eventType, eventTime, true)
+	return err
+}
+
+func (s *SQLStore) insertMemoChangeEventInTx(
+	ctx context.Context,
+	tx *sql.Tx,
+	memoID int64,
+	creatorID int64,
+	eventType string,
+	eventTime time.Time,
+	broadcast bool,
+) (int64, error) {
+	memoName := "memos/" + models.Int64ToString(memoID)
+	res, err := tx.ExecContext(
+		ctx,
+		`INSERT INTO memo_change_events (memo_id, memo_name, creator_id, event_type, event_time, broadcast)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		memoID,
+		memoName,
+		creatorID,
+		eventType,
+		s.formatMemoTime(eventTime),
+		boolToSQLiteInt(broadcast),
+	)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
 func (s *SQLStore) ListAttachmentsByMemoIDs(ctx context.Context, memoIDs []int64) (map[int64][]models.Attachment, error) {
 	result := make(map[int64][]models.Attachment)
 	if len(memoIDs) == 0 {
@@ -1622,7 +3266,7 @@ func (s *SQLStore) ListAttachmentsByMemoIDs(ctx context.Context, memoIDs []int64
 	}
 
 	query := fmt.Sprintf(
-		`SELECT ma.memo_id, a.id, a.creator_id, a.filename, a.external_link, a.type, a.size, a.storage_type, a.storage_key, a.thumbnail_filename, a.thumbnail_type, a.thumbnail_size, a.thumbnail_storage_type, a.thumbnail_storage_key, a.create_time
+		`SELECT ma.memo_id, a.id, a.creator_id, a.filename, a.external_link, a.type, a.size, a.content_hash, a.storage_type, a.storage_key, a.thumbnail_filename, a.thumbnail_type, a.thumbnail_size, a.thumbnail_storage_type, a.thumbnail_storage_key, a.create_time
 		FROM memo_attachments ma
 		JOIN attachments a ON a.id = ma.attachment_id
 		WHERE ma.memo_id IN (%s)
@@ -1647,6 +3291,7 @@ func (s *SQLStore) ListAttachmentsByMemoIDs(ctx context.Context, memoIDs []int64
 			&attachment.ExternalLink,
 			&attachment.Type,
 			&attachment.Size,
+			&attachment.ContentHash,
 			&attachment.StorageType,
 			&attachment.StorageKey,
 			&attachment.ThumbnailFilename,
@@ -1667,15 +3312,47 @@ func (s *SQLStore) ListAttachmentsByMemoIDs(ctx context.Context, memoIDs []int64
 	return result, rows.Err()
 }
 
-func (s *SQLStore) AttachmentBelongsToUser(ctx context.Context, attachmentID int64, userID int64) (bool, error) {
-	var count int
-	err := s.db.QueryRowContext(
+// FilterAttachmentIDsOwnedBy 用一次查询返回 ids 中属于 userID 的附件 ID 集合。
+func (s *SQLStore) FilterAttachmentIDsOwnedBy(ctx context.Context, ids []int64, userID int64) (map[int64]struct{}, error) {
+	owned := make(map[int64]struct{}, len(ids))
+	if len(ids) == 0 {
+		return owned, nil
+	}
+	placeholders := strings.TrimRight(strings.Repeat("?,", len(ids)), ",")
+	args := make([]any, 0, len(ids)+1)
+	args = append(args, userID)
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	rows, err := s.db.QueryContext(
 		ctx,
-		`SELECT COUNT(1) FROM attachments WHERE id = ? AND creator_id = ?`,
-		attachmentID,
-		userID,
-	).Scan(&count)
-	return count > 0, err
+		`SELECT id FROM attachments WHERE creator_id = ? AND id IN (`+placeholders+`)`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		owned[id] = struct{}{}
+	}
+	return owned, rows.Err()
+}
+
+func (s *SQLStore) GetVisibleMemoByID(ctx context.Context, viewerID int64, memoID int64) (models.Memo, error) {
+	visiblePredicate, args := visibleMemoPredicate(viewerID)
+	row := s.db.QueryRowContext(
+		ctx,
+		`SELECT m.id, m.creator_id, m.content, m.visibility, m.state, m.pinned, m.create_time, m.update_time, m.display_time, m.latitude, m.longitude, m.has_link, m.has_task_list, m.has_code, m.has_incomplete_tasks
+		FROM memos m
+		WHERE m.id = ? AND `+visiblePredicate,
+		append([]any{memoID}, args...)...,
+	)
+	return scanMemo(row)
 }
 
 func (s *SQLStore) GetMemoByIDAndCreator(ctx context.Context, memoID int64, creatorID int64) (models.Memo, error) {
@@ -1691,15 +3368,11 @@ func (s *SQLStore) GetMemoByIDAndCreator(ctx context.Context, memoID int64, crea
 	if err != nil {
 		return models.Memo{}, err
 	}
-	tagsByMemoID, err := s.listMemoTagsByMemoIDs(ctx, []int64{memo.ID})
-	if err != nil {
+	memos := []models.Memo{memo}
+	if err := s.LoadMemoTags(ctx, memos); err != nil {
 		return models.Memo{}, err
 	}
-	memo.Payload.Tags = tagsByMemoID[memo.ID]
-	if memo.Payload.Tags == nil {
-		memo.Payload.Tags = []string{}
-	}
-	return memo, nil
+	return memos[0], nil
 }
 
 func (s *SQLStore) listMemoTagsByMemoIDs(ctx context.Context, memoIDs []int64) (map[int64][]string, error) {
@@ -1743,7 +3416,39 @@ func (s *SQLStore) listMemoTagsByMemoIDs(ctx context.Context, memoIDs []int64) (
 	return result, nil
 }
 
-func (s *SQLStore) hydrateMemoTags(ctx context.Context, memos []models.Memo) error {
+// HydrateMemos 是备忘录补齐标签与附件的统一入口：标签与附件各用一次 IN 查询批量加载，
+// 标签直接写回 memos，附件按备忘录 ID 分组返回。列表与搜索查询只扫描备忘录本身，不加载标签（Tags 为 nil）；
+// 需要在分页前按标签过滤的调用方已用 LoadMemoTags 加载过的备忘录不会重复查询。
+func (s *SQLStore) HydrateMemos(ctx context.Context, memos []models.Memo) (map[int64][]models.Attachment, error) {
+	memoIDs := make([]int64, 0, len(memos))
+	missingTags := make([]int64, 0)
+	for _, memo := range memos {
+		memoIDs = append(memoIDs, memo.ID)
+		if memo.Payload.Tags == nil {
+			missingTags = append(missingTags, memo.ID)
+		}
+	}
+	if len(missingTags) > 0 {
+		tagsByMemoID, err := s.listMemoTagsByMemoIDs(ctx, missingTags)
+		if err != nil {
+			return nil, err
+		}
+		for i := range memos {
+			if memos[i].Payload.Tags != nil {
+				continue
+			}
+			tags := tagsByMemoID[memos[i].ID]
+			if tags == nil {
+				tags = []string{}
+			}
+			memos[i].Payload.Tags = tags
+		}
+	}
+	return s.ListAttachmentsByMemoIDs(ctx, memoIDs)
+}
+
+// LoadMemoTags 为备忘录加载标签，供需要在 HydrateMemos 之前读取标签的场景（如在内存中执行过滤条件、统计标签）使用。
+func (s *SQLStore) LoadMemoTags(ctx context.Context, memos []models.Memo) error {
 	memoIDs := make([]int64, 0, len(memos))
 	for _, memo := range memos {
 		memoIDs = append(memoIDs, memo.ID)
@@ -1845,7 +3550,6 @@ func scanMemo(scanner interface {
 		HasCode:            hasCode == 1,
 		HasIncompleteTasks: hasIncompleteTasks == 1,
 	}
-	memo.Payload.Tags = []string{}
 	return memo, nil
 }
 
@@ -1861,6 +3565,7 @@ func scanAttachment(scanner interface {
 		&attachment.ExternalLink,
 		&attachment.Type,
 		&attachment.Size,
+		&attachment.ContentHash,
 		&attachment.StorageType,
 		&attachment.StorageKey,
 		&attachment.ThumbnailFilename,